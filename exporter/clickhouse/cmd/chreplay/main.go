@@ -0,0 +1,176 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+// Command chreplay re-inserts the batches of dead letter queue files, or of
+// the JSON files written by the file exporter, through the exporter, with the
+// same tables, attribute filters and routing as the collector.
+//
+// Each insert carries a deduplication token derived from its batch, so that a
+// replay interrupted and run again doesn't duplicate the rows of the tables
+// deduplicating inserts: replicated tables, or tables created with the
+// non_replicated_deduplication_window setting. The sending queue, dead letter
+// queue and write-ahead log of the configuration are disabled, a batch failing
+// after its retries stopping the replay.
+//
+//	chreplay -config collector.yaml -exporter clickhouse/logs -rate 5000 \
+//	  -delete /var/lib/otelcol/clickhouse-dlq
+package main // import "github.com/foyer-work/otel-distribution/exporter/clickhouse/cmd/chreplay"
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/confmap"
+	"go.opentelemetry.io/collector/exporter"
+	"go.uber.org/zap"
+
+	clickhouseexporter "github.com/foyer-work/otel-distribution/exporter/clickhouse"
+	"github.com/foyer-work/otel-distribution/exporter/clickhouse/internal/replay"
+)
+
+func main() {
+	if err := run(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	var (
+		configFile = flag.String("config", "", "collector configuration file, or file holding only the exporter configuration")
+		exporterID = flag.String("exporter", "clickhouse", "id of the exporter in the exporters section of the collector configuration")
+		opts       replay.Options
+	)
+	flag.Float64Var(&opts.Rate, "rate", 0, "maximum number of log records, spans and datapoints replayed per second, 0 for no limit")
+	flag.BoolVar(&opts.Delete, "delete", false, "delete each file once replayed")
+	flag.Parse()
+
+	if *configFile == "" {
+		return errors.New("-config is required")
+	}
+	if flag.NArg() == 0 {
+		return errors.New("expected the dead letter queue directories or files to replay")
+	}
+
+	logger, err := zap.NewProduction()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	factory := clickhouseexporter.NewFactory()
+	cfg, err := loadConfig(factory, *configFile, *exporterID)
+	if err != nil {
+		return err
+	}
+	var id component.ID
+	if err := id.UnmarshalText([]byte(*exporterID)); err != nil {
+		return err
+	}
+	set := exporter.Settings{
+		ID:                id,
+		TelemetrySettings: componenttest.NewNopTelemetrySettings(),
+		BuildInfo:         component.NewDefaultBuildInfo(),
+	}
+	set.Logger = logger
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	consumers, components, err := createExporters(ctx, factory, set, cfg)
+	defer func() {
+		for _, c := range components {
+			_ = c.Shutdown(context.Background())
+		}
+	}()
+	if err != nil {
+		return err
+	}
+	return replay.NewReplayer(consumers, logger, opts).Run(ctx, flag.Args())
+}
+
+// loadConfig loads the configuration of the exporter exporterID from the collector configuration file, or the
+// whole file if it has no exporters section, disabling the queues writing the batches elsewhere than ClickHouse.
+func loadConfig(factory exporter.Factory, file, exporterID string) (*clickhouseexporter.Config, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	retrieved, err := confmap.NewRetrievedFromYAML(content)
+	if err != nil {
+		return nil, err
+	}
+	conf, err := retrieved.AsConf()
+	if err != nil {
+		return nil, err
+	}
+	if conf.IsSet("exporters") {
+		if !conf.IsSet("exporters::" + exporterID) {
+			return nil, fmt.Errorf("no exporter %q in %s", exporterID, file)
+		}
+		conf, err = conf.Sub("exporters::" + exporterID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	cfg := factory.CreateDefaultConfig().(*clickhouseexporter.Config)
+	if err := conf.Unmarshal(cfg); err != nil {
+		return nil, err
+	}
+	cfg.QueueSettings.Enabled = false
+	cfg.DeadLetterQueue.Enabled = false
+	cfg.WriteAheadLog.Enabled = false
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// createExporters creates and starts the exporters of the enabled signals, returning the started ones to shut down.
+func createExporters(ctx context.Context, factory exporter.Factory, set exporter.Settings, cfg *clickhouseexporter.Config) (replay.Consumers, []component.Component, error) {
+	var (
+		consumers  replay.Consumers
+		components []component.Component
+	)
+	start := func(c component.Component, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := c.Start(ctx, componenttest.NewNopHost()); err != nil {
+			return err
+		}
+		components = append(components, c)
+		return nil
+	}
+	if cfg.Logs.Enabled {
+		logs, err := factory.CreateLogs(ctx, set, cfg)
+		if err := start(logs, err); err != nil {
+			return consumers, components, err
+		}
+		consumers.Logs = logs
+	}
+	if cfg.Traces.Enabled {
+		traces, err := factory.CreateTraces(ctx, set, cfg)
+		if err := start(traces, err); err != nil {
+			return consumers, components, err
+		}
+		consumers.Traces = traces
+	}
+	if cfg.Metrics.Enabled {
+		metrics, err := factory.CreateMetrics(ctx, set, cfg)
+		if err := start(metrics, err); err != nil {
+			return consumers, components, err
+		}
+		consumers.Metrics = metrics
+	}
+	return consumers, components, nil
+}
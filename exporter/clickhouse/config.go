@@ -4,16 +4,30 @@
 package clickhouseexporter // import "github.com/foyer-work/otel-distribution/exporter/clickhouse"
 
 import (
+	"context"
 	"database/sql"
+	"database/sql/driver"
 	"errors"
 	"fmt"
+	"maps"
+	"net"
 	"net/url"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/ClickHouse/clickhouse-go/v2"
+	"go.opentelemetry.io/collector/component"
 	"go.opentelemetry.io/collector/config/configopaque"
 	"go.opentelemetry.io/collector/config/configretry"
+	"go.opentelemetry.io/collector/config/configtls"
 	"go.opentelemetry.io/collector/exporter/exporterhelper"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.uber.org/zap"
 
 	"github.com/foyer-work/otel-distribution/exporter/clickhouse/internal"
 )
@@ -22,25 +36,99 @@ import (
 type Config struct {
 	// collectorVersion is the build version of the collector. This is overridden when an exporter is initialized.
 	collectorVersion string
-	driverName       string // for testing
+	// collectorInstanceID is the service.instance.id of the collector, set when an exporter is initialized.
+	collectorInstanceID string
+	driverName          string // for testing
+	// jsonFallback creates the JSON columns as String, set on start if the server lacks the JSON type.
+	jsonFallback bool
 
 	TimeoutSettings           exporterhelper.TimeoutConfig `mapstructure:",squash"`
 	configretry.BackOffConfig `mapstructure:"retry_on_failure"`
 	QueueSettings             exporterhelper.QueueBatchConfig `mapstructure:"sending_queue"`
+	// DeadLetterQueue writes the batches failing after all retries to a local directory instead of dropping them.
+	DeadLetterQueue DeadLetterQueueConfig `mapstructure:"dead_letter_queue"`
+	// TooManyPartsBackoff slows down inserts while clickhouse rejects them with TOO_MANY_PARTS.
+	TooManyPartsBackoff TooManyPartsBackoffConfig `mapstructure:"too_many_parts_backoff"`
+	// WriteAheadLog acknowledges batches once written to a local directory and inserts them in the background.
+	WriteAheadLog WriteAheadLogConfig `mapstructure:"write_ahead_log"`
 
-	// Endpoint is the clickhouse endpoint.
+	// Endpoint is the clickhouse endpoint in DSN format.
+	//
+	// Deprecated: encoding credentials and TLS flags into a DSN is kept as a fallback,
+	// use Endpoints, Username, Password, Database and TLS instead. Credentials in the DSN are not redacted.
 	Endpoint string `mapstructure:"endpoint"`
+	// Endpoints are the `host:port` addresses of the clickhouse servers using the native protocol.
+	Endpoints []string `mapstructure:"endpoints"`
+	// Failover sends all inserts to one of Endpoints at a time instead of spreading connections over them.
+	Failover FailoverConfig `mapstructure:"failover"`
+	// Mirror writes a copy of every batch into a secondary cluster, e.g. to migrate to a new cluster without
+	// downtime or for cross-region redundancy.
+	Mirror MirrorConfig `mapstructure:"mirror"`
+	// Shadow writes a sample of the batches into tables with a candidate schema, so that a schema change can be
+	// validated on real data before switching to it.
+	Shadow ShadowConfig `mapstructure:"shadow"`
+	// LazyConnect if true starts the exporter without waiting for clickhouse. Connecting and creating the
+	// schema are retried in the background and batches fail until they succeed. Default is `false`.
+	LazyConnect bool `mapstructure:"lazy_connect"`
+	// HealthCheckInterval is how often clickhouse is pinged to report its health through the component status,
+	// along with the outcome of inserts. Zero disables the pings. Default is 30s.
+	HealthCheckInterval time.Duration `mapstructure:"health_check_interval"`
+	// DebugEndpoint is the `host:port` address serving a debug page at /debug/clickhouse, with the rows inserted,
+	// last batch size, last flush and last error of each table, and the batches being inserted. The zpages
+	// extension doesn't serve pages of components, e.g. set it to `localhost:55680` next to zpages.
+	// The page is shared by the exporters with the same endpoint. Disabled if empty, the default.
+	DebugEndpoint string `mapstructure:"debug_endpoint"`
+	// StartupRetry retries connecting and creating the schema on start.
+	StartupRetry StartupRetryConfig `mapstructure:"startup_retry"`
+	// TLS configures the connection to the clickhouse servers. TLS is disabled if unset.
+	// Setting cert_file and key_file presents a client certificate for mutual TLS.
+	TLS *configtls.ClientConfig `mapstructure:"tls"`
 	// Username is the authentication username.
 	Username string `mapstructure:"username"`
-	// Password is the authentication password.
+	// Password is the authentication password. It is redacted when the configuration is printed or marshaled,
+	// reference it through a confmap provider, e.g. `${env:CLICKHOUSE_PASSWORD}`, to keep it out of the config file.
 	Password configopaque.String `mapstructure:"password"`
+	// PasswordFile is read for the password instead of Password. It is read again whenever a new connection
+	// is rejected for its credentials, so that the password can be rotated without restarting the collector.
+	// Passwords referenced through a confmap provider are only resolved when the configuration is loaded.
+	PasswordFile string `mapstructure:"password_file"`
+	// Auth replaces Username and Password with ClickHouse Cloud credentials.
+	Auth AuthConfig `mapstructure:"auth"`
 	// Database is the database name to export.
 	Database string `mapstructure:"database"`
+	// LogsDatabase is the database name for logs. default is `database`.
+	LogsDatabase string `mapstructure:"logs_database"`
+	// TracesDatabase is the database name for traces. default is `database`.
+	TracesDatabase string `mapstructure:"traces_database"`
+	// MetricsDatabase is the database name for metrics. default is `database`.
+	MetricsDatabase string `mapstructure:"metrics_database"`
 	// ConnectionParams is the extra connection parameters with map format. for example compression/dial_timeout
+	// They are passed to the driver as DSN parameters and take priority over the endpoint and exporter options,
+	// so driver options such as connection_open_strategy or skip_verify can be set without exporter support.
+	// Parameters unknown to the driver are sent to clickhouse as query settings.
 	ConnectionParams map[string]string `mapstructure:"connection_params"`
+	// InsertSettings are clickhouse settings sent with every INSERT of the exporter, e.g.
+	// `async_insert_busy_timeout_ms` or `insert_null_as_default`, without applying them to other queries.
+	InsertSettings map[string]string `mapstructure:"insert_settings"`
+	// SkipInvalidRows if true skips the rows that fail to convert to the column types of their table
+	// instead of failing the whole batch. Skipped rows are logged and counted by the
+	// `otelcol_exporter_clickhouse_skipped_rows` metric. Default is `false`.
+	SkipInvalidRows bool `mapstructure:"skip_invalid_rows"`
+	// MaxAttributeValueBytes truncates the string and bytes attribute values longer than this many bytes before
+	// insert, strings on a UTF-8 character boundary followed by `...[truncated]`, including those nested in maps
+	// and slices. Truncated values are counted by the `otelcol_exporter_clickhouse_truncated_attribute_values`
+	// metric. 0 (default) doesn't truncate values.
+	MaxAttributeValueBytes int `mapstructure:"max_attribute_value_bytes"`
+	// FlattenAttributes replaces the map attribute values by an attribute per entry, their keys joined with dots,
+	// e.g. `k8s.labels.app` for the `app` entry of the `k8s.labels` map, recursively, so that they are addressed
+	// like the other attributes. The attributes include and exclude patterns apply to both. Default is `false`.
+	FlattenAttributes bool `mapstructure:"flatten_attributes"`
 	// LogsTableName is the table name for logs. default is `otel_logs`.
+	// It may contain the placeholders `%Y`, `%m`, `%d`, `%H` and `{resource.attribute}`, resolved per record,
+	// in which case tables are created when first written to.
 	LogsTableName string `mapstructure:"logs_table_name"`
 	// TracesTableName is the table name for traces. default is `otel_traces`.
+	// It may contain the same placeholders as LogsTableName, resolved per span.
 	TracesTableName string `mapstructure:"traces_table_name"`
 	// MetricsTableName is the table name for metrics. default is `otel_metrics`.
 	//
@@ -50,20 +138,748 @@ type Config struct {
 	MetricsTableName string `mapstructure:"metrics_table_name"`
 	// TTL is The data time-to-live example 30m, 48h. 0 means no ttl.
 	TTL time.Duration `mapstructure:"ttl"`
+	// TTLRollups collapse the rows of the logs, traces and metrics tables older than their after into a row
+	// per group rather than keeping them as is until the ttl deletes them. They are added to the TTL of the
+	// tables, including tables that already exist.
+	TTLRollups []TTLRollupConfig `mapstructure:"ttl_rollups"`
+	// Rotation writes the logs and traces into a table per calendar period instead of deleting their rows by ttl.
+	Rotation RotationConfig `mapstructure:"rotation"`
 	// TableEngine is the table engine to use. default is `MergeTree()`.
 	TableEngine TableEngine `mapstructure:"table_engine"`
 	// ClusterName if set will append `ON CLUSTER` with the provided name when creating tables.
 	ClusterName string `mapstructure:"cluster_name"`
+	// DatabaseEngine is the engine of the database created by create_schema, the server default if unset.
+	DatabaseEngine DatabaseEngine `mapstructure:"database_engine"`
+	// DistributedDDL configures how the ON CLUSTER DDL waits for the hosts of the cluster.
+	DistributedDDL DistributedDDLConfig `mapstructure:"distributed_ddl"`
 	// CreateSchema if set to true will run the DDL for creating the database and tables. default is true.
 	CreateSchema bool `mapstructure:"create_schema"`
+	// CreateSchemaObjects selects the kinds of schema objects created when create_schema is enabled.
+	CreateSchemaObjects SchemaObjectsConfig `mapstructure:"create_schema_objects"`
+	// SchemaFallback if true creates the JSON columns as String holding the same serialized JSON
+	// when the server doesn't support the JSON type or it is disabled, instead of failing to start.
+	// The server is checked on start. Default is `true`.
+	SchemaFallback bool `mapstructure:"schema_fallback"`
+	// IDEncoding is how the trace and span ids are stored: `hex` (default) as hex strings in String columns,
+	// or `binary` as raw bytes in FixedString(16) trace id and FixedString(8) span id columns, halving
+	// their size. Switching an existing schema requires migrating its id columns.
+	IDEncoding string `mapstructure:"id_encoding"`
+	// SchemaCompat renders the logs and traces tables and their INSERTs as the given version of the upstream
+	// opentelemetry-collector-contrib ClickHouse exporter does, with Map attribute columns, so that the tables it
+	// created are written as they are. The only version is `contrib-v0.126`. The options changing the columns,
+	// engine or settings of those tables don't apply. It requires metrics::enabled false, the otel traces schema
+	// with nested events and links, and no tenant, dimensions, rotation, error logs, table routes or templated table names.
+	// Disabled if empty, the default.
+	SchemaCompat string `mapstructure:"schema_compat"`
 	// Compress controls the compression algorithm. Valid options: `none` (disabled), `zstd`, `lz4` (default), `gzip`, `deflate`, `br`, `true` (lz4).
 	Compress string `mapstructure:"compress"`
+	// QueryIDPrefix starts the query_id of every query run by the exporter, followed by the operation
+	// and a random suffix, e.g. `<prefix>-insert_logs-<uuid>`. Default is `otelcol-<service.instance.id>`.
+	QueryIDPrefix string `mapstructure:"query_id_prefix"`
 	// AsyncInsert if true will enable async inserts. Default is `true`.
 	// Ignored if async inserts are configured in the `endpoint` or `connection_params`.
 	// Async inserts may still be overridden server-side.
 	AsyncInsert bool `mapstructure:"async_insert"`
 	// MetricsTables defines the table names for metric types.
 	MetricsTables MetricTablesConfig `mapstructure:"metrics_tables"`
+	// Logs defines log specific schema options.
+	Logs LogsConfig `mapstructure:"logs"`
+	// Traces defines trace specific schema options.
+	Traces TracesConfig `mapstructure:"traces"`
+	// Metrics defines metric specific schema options.
+	Metrics MetricsConfig `mapstructure:"metrics"`
+	// PartitionBy is the partition granularity of all tables, one of `hourly`, `daily` (default), `weekly` or `monthly`.
+	PartitionBy string `mapstructure:"partition_by"`
+	// ColumnCodecs overrides the compression codecs used when creating tables.
+	// Keys are a column name, applied to every table having that column, or `<table>.<column>`.
+	// Values are the codec list, e.g. `DoubleDelta, ZSTD(3)`.
+	ColumnCodecs map[string]string `mapstructure:"column_codecs"`
+	// LowCardinality toggles the LowCardinality wrapping of String columns when creating tables,
+	// e.g. `SpanName: false` for deployments with a very large number of distinct span names.
+	// Keys are a column name, applied to every table having that column, or `<table>.<column>`.
+	LowCardinality map[string]bool `mapstructure:"low_cardinality"`
+	// TableSettings are added to the SETTINGS of the created tables, replacing those generated, keyed by table
+	// name, e.g. `otel_logs: {min_age_to_force_merge_seconds: 3600, parts_to_throw_insert: 600}`. They apply to
+	// the tables created afterwards.
+	TableSettings map[string]map[string]string `mapstructure:"table_settings"`
+	// Projections are added to the generated tables with ALTER TABLE ... ADD PROJECTION,
+	// including tables that already exist.
+	Projections []ProjectionConfig `mapstructure:"projections"`
+	// Routing writes the logs and traces of each tenant into the database and tables of its route.
+	// Metrics are not routed.
+	Routing RoutingConfig `mapstructure:"routing"`
+	// Tenant writes the tenant of the client that sent the data into a column of the logs, traces and metrics rows.
+	Tenant TenantConfig `mapstructure:"tenant"`
+	// Quotas limits the rows and bytes each tenant writes per second.
+	Quotas QuotasConfig `mapstructure:"quotas"`
+	// ServiceMetadata maintains a dictionary of the metadata of each service, looked up by columns of the
+	// logs and traces tables.
+	ServiceMetadata ServiceMetadataConfig `mapstructure:"service_metadata"`
+	// Dimensions writes each distinct resource and scope once into dimension tables keyed by a fingerprint,
+	// instead of repeating their attributes on every log, span and datapoint row.
+	Dimensions DimensionsConfig `mapstructure:"dimensions"`
+}
+
+// AuthConfig defines the ClickHouse Cloud authentication alternatives to a database user.
+type AuthConfig struct {
+	// APIKeyID is the id of the ClickHouse Cloud API key used instead of Username.
+	APIKeyID string `mapstructure:"api_key_id"`
+	// APIKeySecret is the secret of the ClickHouse Cloud API key used instead of Password.
+	APIKeySecret configopaque.String `mapstructure:"api_key_secret"`
+	// JWT authenticates new connections with a bearer token instead of a user and password.
+	JWT *JWTConfig `mapstructure:"jwt"`
+}
+
+// JWTConfig defines where the JSON Web Token used for authentication is read from.
+type JWTConfig struct {
+	// Token is a static token.
+	Token configopaque.String `mapstructure:"token"`
+	// TokenFile is read for the token, again whenever the token expires so that
+	// it can be rotated by an external process.
+	TokenFile string `mapstructure:"token_file"`
+	// RefreshInterval re-reads TokenFile at least this often. 0 re-reads it only when the token expires,
+	// or for every new connection if the token has no exp claim.
+	RefreshInterval time.Duration `mapstructure:"refresh_interval"`
+}
+
+// FailoverConfig defines failover between the configured endpoints.
+type FailoverConfig struct {
+	// Enabled connects to the first healthy endpoint in Endpoints order.
+	Enabled bool `mapstructure:"enabled"`
+	// MaxFailures is the number of consecutive connection failures after which the next endpoint is used.
+	MaxFailures int `mapstructure:"max_failures"`
+	// ProbeInterval is how often the endpoints preceding the active one are probed for recovery.
+	ProbeInterval time.Duration `mapstructure:"probe_interval"`
+}
+
+// MirrorConfig defines the secondary cluster receiving a copy of every batch. The batches are written into it
+// with the configuration of the exporter, tables and schema included, its connection replaced by these settings.
+type MirrorConfig struct {
+	// Enabled writes every batch into the mirror once written into the primary cluster.
+	Enabled bool `mapstructure:"enabled"`
+	// Endpoints are the `host:port` addresses of the clickhouse servers of the mirror using the native protocol.
+	Endpoints []string `mapstructure:"endpoints"`
+	// Username is the authentication username of the mirror.
+	Username string `mapstructure:"username"`
+	// Password is the authentication password of the mirror.
+	Password configopaque.String `mapstructure:"password"`
+	// TLS configures the connection to the mirror. TLS is disabled if unset.
+	TLS *configtls.ClientConfig `mapstructure:"tls"`
+	// Required fails the batches failing to be written into the mirror, retrying them on the mirror only.
+	// Otherwise the mirror is best-effort: its failures are logged and counted by the
+	// `otelcol_exporter_clickhouse_mirror_failed_batches` metric, and it connects in the background
+	// as with lazy_connect. Default is `false`.
+	Required bool `mapstructure:"required"`
+}
+
+// ShadowConfig defines the shadow tables receiving a sample of the batches with a candidate schema. They are
+// created and written in Database with the configuration of the exporter, table names included, the schema
+// settings set here replacing those of the exporter. Shadow writes are best-effort: their failures are logged
+// and counted by the `otelcol_exporter_clickhouse_shadow_failed_batches` metric.
+type ShadowConfig struct {
+	// Enabled writes a sample of the batches into the shadow tables once written into the tables of the exporter.
+	Enabled bool `mapstructure:"enabled"`
+	// Database holds the shadow tables. It must differ from the databases of the exporter.
+	Database string `mapstructure:"database"`
+	// SampleRatio is the fraction of the batches written into the shadow tables, between 0 and 1. Default is 0.01.
+	SampleRatio float64 `mapstructure:"sample_ratio"`
+	// IDEncoding replaces `id_encoding` in the shadow tables if set.
+	IDEncoding string `mapstructure:"id_encoding"`
+	// PartitionBy replaces `partition_by` and its signal overrides in the shadow tables if set.
+	PartitionBy string `mapstructure:"partition_by"`
+	// TableEngine replaces `table_engine` and its signal overrides in the shadow tables if set.
+	TableEngine TableEngine `mapstructure:"table_engine"`
+	// MetricsSchema replaces `metrics::schema` in the shadow tables if set.
+	MetricsSchema string `mapstructure:"metrics_schema"`
+	// ColumnCodecs are added to `column_codecs` in the shadow tables, replacing the codecs of the same columns.
+	ColumnCodecs map[string]string `mapstructure:"column_codecs"`
+	// LowCardinality is added to `low_cardinality` in the shadow tables, replacing the toggles of the same columns.
+	LowCardinality map[string]bool `mapstructure:"low_cardinality"`
+	// TableSettings are added to `table_settings` in the shadow tables, replacing the settings of the same tables.
+	TableSettings map[string]map[string]string `mapstructure:"table_settings"`
+}
+
+// StartupRetryConfig defines how connecting and creating the schema on start are retried.
+// Failed attempts are reported through the component status.
+type StartupRetryConfig struct {
+	// MaxAttempts is the number of attempts before start fails, 1 (default) doesn't retry and 0 retries until Timeout.
+	// With lazy_connect the attempts continue in the background until they succeed.
+	MaxAttempts int `mapstructure:"max_attempts"`
+	// Interval is the delay between attempts. Default is 5s.
+	Interval time.Duration `mapstructure:"interval"`
+	// Timeout bounds the time spent in all attempts, unlimited if zero. It doesn't apply with lazy_connect.
+	Timeout time.Duration `mapstructure:"timeout"`
+}
+
+// DeadLetterQueueConfig defines where the batches failing after all retries are kept.
+// Each batch is written to its own `<unix nano>-<signal>.pb` file, OTLP protobuf encoded,
+// and can be replayed once clickhouse recovers.
+type DeadLetterQueueConfig struct {
+	// Enabled writes failed batches to Directory. The exporter then runs the retries itself,
+	// applying the timeout to each attempt.
+	Enabled bool `mapstructure:"enabled"`
+	// Directory is where batches are written to, created if missing.
+	Directory string `mapstructure:"directory"`
+	// MaxFiles is the number of batches kept per signal, the oldest are removed first. Zero means unlimited.
+	MaxFiles int `mapstructure:"max_files"`
+	// MaxSizeMiB is the total size of the kept batches per signal, the oldest are removed first. Zero means unlimited.
+	MaxSizeMiB int64 `mapstructure:"max_size_mib"`
+}
+
+// TooManyPartsBackoffConfig defines how inserts are delayed after clickhouse rejected them with
+// TOO_MANY_PARTS (code 252), giving the merges time to catch up while larger batches accumulate.
+type TooManyPartsBackoffConfig struct {
+	// Enabled delays inserts after a TOO_MANY_PARTS error. Default is `true`.
+	Enabled bool `mapstructure:"enabled"`
+	// InitialInterval is the delay after the first rejection, doubled on each further rejection
+	// and halved on each successful insert. Default is 1s.
+	InitialInterval time.Duration `mapstructure:"initial_interval"`
+	// MaxInterval is the upper bound of the delay. Default is 1m.
+	MaxInterval time.Duration `mapstructure:"max_interval"`
+}
+
+// WriteAheadLogConfig defines the on-disk log batches are appended to before being inserted,
+// giving at-least-once delivery across collector restarts.
+// Each batch is written to its own `<unix nano>-<signal>.pb` file, OTLP protobuf encoded,
+// and removed once inserted. Batches left by a previous run are inserted on start.
+type WriteAheadLogConfig struct {
+	// Enabled appends batches to Directory and acknowledges them, inserting them in the background.
+	Enabled bool `mapstructure:"enabled"`
+	// Directory is where batches are written to, created if missing. It must not be shared with other exporters
+	// or the dead letter queue.
+	Directory string `mapstructure:"directory"`
+	// RetryInterval is the delay before retrying a batch that failed to be inserted. Default is 5s.
+	RetryInterval time.Duration `mapstructure:"retry_interval"`
+}
+
+// ProjectionConfig defines a projection of a table.
+type ProjectionConfig struct {
+	// Table is the name of the table the projection belongs to.
+	Table string `mapstructure:"table"`
+	// Name is the name of the projection.
+	Name string `mapstructure:"name"`
+	// Query is the projection query, e.g. `SELECT * ORDER BY TraceId`.
+	Query string `mapstructure:"query"`
+	// Materialize builds the projection for the existing parts of the table once it is added.
+	Materialize bool `mapstructure:"materialize"`
+}
+
+// RotationConfig defines the rotation of the logs and traces tables, so that the tables of past periods can be
+// detached or archived whole rather than their rows deleted by ttl mutations.
+type RotationConfig struct {
+	// Enabled writes the logs and traces, including those of the table routes, into a table per period, named
+	// after their table followed by the period, e.g. `otel_logs_202610` or `otel_logs_2026w42` for the ISO week.
+	// The table of a period is created when first written to, that of the current period on start, without ttl.
+	// A Merge table named after their table followed by `_all`, e.g. `otel_logs_all`, reads the tables of all
+	// periods. Default is `false`.
+	Enabled bool `mapstructure:"enabled"`
+	// Period is the calendar period of the tables, one of `weekly`, `monthly`. Default is `monthly`.
+	Period string `mapstructure:"period"`
+}
+
+// TTLRollupConfig defines a TTL GROUP BY clause of a table.
+type TTLRollupConfig struct {
+	// Table is the name of the logs, traces or metrics table rolled up.
+	Table string `mapstructure:"table"`
+	// After is the age of the rows rolled up, e.g. 168h.
+	After time.Duration `mapstructure:"after"`
+	// GroupBy are the columns whose rows are collapsed into one, a prefix of the ORDER BY of the table, e.g.
+	// `[ServiceName, SpanName, toDateTime(Timestamp)]` keeping a row per second and span name of the traces table.
+	GroupBy []string `mapstructure:"group_by"`
+	// Set are the aggregates written into other columns, e.g. `Duration: max(Duration)`. The columns neither
+	// grouped nor set keep the value of any row of the group.
+	Set map[string]string `mapstructure:"set"`
+	// Where restricts the rows rolled up, e.g. `StatusCode != 'Error'`. All rows are rolled up if empty.
+	Where string `mapstructure:"where"`
+}
+
+// TenantConfig defines the column holding the tenant of the client that sent the data, read from the client
+// information the receivers and their authenticators attach to it rather than from its resource attributes.
+// The column is added to the logs, traces and metrics tables, except those of the jaeger
+// schema, and isn't copied into the derived tables. With sending_queue batching, only the metadata keys listed
+// in its metadata_keys are kept, the authentication data of the clients being lost.
+type TenantConfig struct {
+	// Enabled adds the tenant column to the created tables and writes it in every row. Default is `false`.
+	Enabled bool `mapstructure:"enabled"`
+	// Column is the name of the column. Default is `Tenant`.
+	Column string `mapstructure:"column"`
+	// AuthAttribute is the attribute of the authentication data holding the tenant, e.g. `subject`,
+	// as documented by the authenticator extension of the receiver.
+	AuthAttribute string `mapstructure:"auth_attribute"`
+	// MetadataKey is the client metadata key holding the tenant, e.g. `x-tenant-id`, read if the client
+	// has no AuthAttribute. The receiver must include the metadata.
+	MetadataKey string `mapstructure:"metadata_key"`
+	// Partition prefixes the partition key of the tables holding the column with it, e.g.
+	// `(Tenant, toDate(TimestampTime))`, so that the data of a tenant is deleted by dropping its partitions
+	// rather than by a mutation. Inserts then write a part per tenant and partition period of their rows,
+	// batches should hold few tenants. It applies to the tables created afterwards. Default is `false`.
+	Partition bool `mapstructure:"partition"`
+}
+
+// ServiceMetadataConfig defines the dictionary of the ownership, team or tier of each service, so that
+// dashboards can enrich the logs and traces by their ServiceName without joining a table.
+type ServiceMetadataConfig struct {
+	// Enabled creates the source table unless it exists, a dictionary loading it, and for each attribute an
+	// ALIAS column `Service<Attribute>`, e.g. `ServiceTeam`, looking it up on the logs, traces and root spans
+	// tables, including tables that already exist. Default is `false`.
+	Enabled bool `mapstructure:"enabled"`
+	// SourceTable is the table maintained with the metadata, keyed by ServiceName with a String column per
+	// attribute. Default is `otel_service_metadata`.
+	SourceTable string `mapstructure:"source_table"`
+	// Dictionary is the name of the dictionary. Default is `otel_service_metadata_dict`.
+	Dictionary string `mapstructure:"dictionary"`
+	// Attributes are the metadata columns of the source table. Default is `owner`, `team` and `tier`.
+	Attributes []string `mapstructure:"attributes"`
+	// Lifetime is the longest the dictionary serves the metadata before reloading the source table,
+	// in whole seconds. Default is 5m.
+	Lifetime time.Duration `mapstructure:"lifetime"`
+}
+
+// DimensionsConfig defines the dimension tables of the resources and scopes, with a row per distinct resource and
+// scope keyed by the fingerprint of its schema url and attributes, for the scope also of its name and version.
+type DimensionsConfig struct {
+	// Enabled adds the ResourceFingerprint and ScopeFingerprint columns to the logs, traces and metrics tables
+	// created afterwards, and writes their ResourceAttributes and ScopeAttributes columns as empty objects, the
+	// resources and scopes being joined from the dimension tables by fingerprint. Each row of the dimension
+	// tables is written again hourly, keeping it past the ttl of the rows referencing it. Default is `false`.
+	Enabled bool `mapstructure:"enabled"`
+	// ResourcesTableName is the table of the resources. Default is `otel_resources`.
+	ResourcesTableName string `mapstructure:"resources_table_name"`
+	// ScopesTableName is the table of the scopes. Default is `otel_scopes`.
+	ScopesTableName string `mapstructure:"scopes_table_name"`
+}
+
+// QuotasConfig limits the rate at which each tenant, the tenant of the client as read by `tenant`, writes rows
+// and bytes, so that a noisy tenant doesn't starve the others sharing the exporter. The quotas of each signal
+// are separate, and apply to whole batches before they are written, the bytes being the size of their OTLP
+// encoding. Batches replayed from the write ahead log have no tenant.
+type QuotasConfig struct {
+	// Enabled enforces the quotas. Default is `false`.
+	Enabled bool `mapstructure:"enabled"`
+	// RowsPerSecond is the rate of log records, spans or datapoints of each tenant, unlimited if zero.
+	RowsPerSecond float64 `mapstructure:"rows_per_second"`
+	// BytesPerSecond is the rate of bytes of each tenant, unlimited if zero.
+	BytesPerSecond float64 `mapstructure:"bytes_per_second"`
+	// Burst is how long the quota of an idle tenant accumulates, the batches of a tenant being written
+	// while it has quota left. Default is 1s.
+	Burst time.Duration `mapstructure:"burst"`
+	// Action is what happens to the batches of the tenants over their quota: `drop` drops them and `defer`
+	// (default) fails them with a retryable error, retried by retry_on_failure once the quota refills.
+	Action string `mapstructure:"action"`
+	// Tenants overrides the rates of some tenants.
+	Tenants map[string]TenantQuotaConfig `mapstructure:"tenants"`
+}
+
+// TenantQuotaConfig defines the rates of a tenant, unlimited if zero.
+type TenantQuotaConfig struct {
+	RowsPerSecond  float64 `mapstructure:"rows_per_second"`
+	BytesPerSecond float64 `mapstructure:"bytes_per_second"`
+}
+
+// rates returns the rows and bytes per second tenant may write.
+func (cfg QuotasConfig) rates(tenant string) (rows, bytes float64) {
+	if quota, ok := cfg.Tenants[tenant]; ok {
+		return quota.RowsPerSecond, quota.BytesPerSecond
+	}
+	return cfg.RowsPerSecond, cfg.BytesPerSecond
+}
+
+// RoutingConfig defines the routing of the data of each tenant, identified by a resource attribute,
+// into its own database and tables, created when first written to.
+type RoutingConfig struct {
+	// Attribute is the resource attribute holding the tenant, e.g. `tenant.id`.
+	Attribute string `mapstructure:"attribute"`
+	// Routes maps the tenants to their database and tables. The data of the other tenants, and without
+	// the attribute, is written into the database and tables of the exporter.
+	Routes map[string]RouteConfig `mapstructure:"routes"`
+}
+
+// RouteConfig defines where the data of a tenant is written to. The tables of the tenant have the schema
+// of the exporter tables, including their derived tables.
+type RouteConfig struct {
+	// Database is the database of the tenant. Default is the database of the signal.
+	Database string `mapstructure:"database"`
+	// LogsTableName is the logs table of the tenant, with the placeholders of `logs_table_name`.
+	// Default is `logs_table_name`.
+	LogsTableName string `mapstructure:"logs_table_name"`
+	// TracesTableName is the traces table of the tenant, with the placeholders of `traces_table_name`.
+	// It doesn't apply to the jaeger schema, whose tables are only routed to the database of the tenant.
+	// Default is `traces_table_name`.
+	TracesTableName string `mapstructure:"traces_table_name"`
+}
+
+// SchemaObjectsConfig selects the kinds of schema objects the exporter creates.
+type SchemaObjectsConfig struct {
+	// Database creates the database. Default is `true`.
+	Database bool `mapstructure:"database"`
+	// Tables creates the tables. Default is `true`.
+	Tables bool `mapstructure:"tables"`
+	// MaterializedViews creates the materialized views filling lookup tables, e.g. the trace id timestamp table.
+	// Default is `true`.
+	MaterializedViews bool `mapstructure:"materialized_views"`
+	// Indexes keeps the data skipping indexes of created tables and adds the configured projections.
+	// Default is `true`.
+	Indexes bool `mapstructure:"indexes"`
+}
+
+// SignalConfig overrides the shared settings for the tables of a single signal.
+type SignalConfig struct {
+	// Enabled controls whether pipelines of this signal may use the exporter. Default is `true`.
+	Enabled bool `mapstructure:"enabled"`
+	// CreateSchema overrides `create_schema` for the database and tables of this signal.
+	CreateSchema *bool `mapstructure:"create_schema"`
+	// ClusterName overrides `cluster_name` for the tables of this signal.
+	ClusterName string `mapstructure:"cluster_name"`
+	// TableEngine overrides `table_engine` for the tables of this signal.
+	TableEngine TableEngine `mapstructure:"table_engine"`
+	// PartitionBy overrides `partition_by` for the tables of this signal.
+	PartitionBy string `mapstructure:"partition_by"`
+	// QueueFullPolicy is what happens to a batch of this signal when the sending queue is full:
+	// `block` blocks the receiver until there is room, `drop_newest` rejects the batch and `drop_oldest`
+	// keeps it in a buffer of the batches waiting for room, dropping the oldest of them when the buffer is full.
+	// Default is `sending_queue::block_on_overflow`.
+	QueueFullPolicy string `mapstructure:"queue_full_policy"`
+	// Attributes filters the attribute keys written into the tables of this signal.
+	Attributes AttributeKeysConfig `mapstructure:"attributes"`
+}
+
+// AttributeKeysConfig filters the keys of the resource, scope and record attributes before they are written,
+// e.g. to keep secrets or high volume keys out of ClickHouse. Patterns match whole keys, `*` matching any
+// characters, e.g. `http.request.header.*`.
+type AttributeKeysConfig struct {
+	// Include keeps only the keys matching one of these patterns. Default is all keys.
+	Include []string `mapstructure:"include"`
+	// Exclude removes the keys matching one of these patterns, after Include.
+	Exclude []string `mapstructure:"exclude"`
+}
+
+// LogsConfig defines log specific schema options.
+type LogsConfig struct {
+	SignalConfig `mapstructure:",squash"`
+	// ErrorLogs routes the log records at or above a severity to a separate table.
+	ErrorLogs ErrorLogsConfig `mapstructure:"error_logs"`
+	// TraceIDLookup creates a `<logs_table_name>_trace_id_ts` table, filled by a materialized view on the logs table,
+	// holding the services and timestamp range of the log records of each trace id, as the traces table has,
+	// for looking up the logs of a trace without scanning the logs table. Default is `false`.
+	TraceIDLookup bool `mapstructure:"trace_id_lookup"`
+	// MaxBodyBytes truncates the log bodies longer than this many bytes, on a UTF-8 character boundary,
+	// setting the `log.body.truncated` log attribute of their records. 0 (default) doesn't truncate bodies.
+	MaxBodyBytes int `mapstructure:"max_body_bytes"`
+	// BodyJSONColumns are String columns of the logs table holding values extracted on insert from the bodies
+	// that are JSON objects, e.g. for bodies not parsed upstream. Values other than strings are JSON encoded,
+	// missing values are empty. Extraction applies to the whole body, before max_body_bytes truncates it.
+	BodyJSONColumns []BodyJSONColumnConfig `mapstructure:"body_json_columns"`
+	// RawRecord stores the log records as received, before truncation, in a RawRecord column.
+	RawRecord RawRecordConfig `mapstructure:"raw_record"`
+	// Patterns mines the patterns of the log bodies into PatternId and Pattern columns.
+	Patterns PatternsConfig `mapstructure:"patterns"`
+	// TableRoutes writes the log records matching OTTL conditions into other tables, each record into the
+	// table of the first route it matches. The other records are written into the logs or error logs table.
+	TableRoutes []TableRouteConfig `mapstructure:"table_routes"`
+}
+
+// TableRouteConfig writes the log records or spans matching OTTL conditions into a table.
+type TableRouteConfig struct {
+	// Conditions are OTTL conditions in the log or span context, e.g. `attributes["signal.tier"] == "audit"`,
+	// a record matching the route if it matches any of them. Conditions failing to evaluate don't match.
+	Conditions []string `mapstructure:"conditions"`
+	// TableName is the table of the matching records, with the placeholders of `logs_table_name` or
+	// `traces_table_name`. It is created with the schema of the logs or traces table, including its derived tables.
+	TableName string `mapstructure:"table_name"`
+}
+
+// PatternsConfig defines the mining of the log body patterns.
+type PatternsConfig struct {
+	// Enabled adds a Pattern column holding the pattern of each log body, mined on insert with the Drain
+	// algorithm, and a PatternId column holding its hash. Patterns are mined by each exporter from the bodies
+	// it writes, truncated to max_body_bytes, so they may differ between collectors and restarts. Default is `false`.
+	Enabled bool `mapstructure:"enabled"`
+	// SimilarityThreshold is the share of the tokens of a body equal to those of a pattern for the body
+	// to join it, between 0 and 1. Default is 0.4.
+	SimilarityThreshold float64 `mapstructure:"similarity_threshold"`
+	// MaxPatterns is the number of patterns kept in memory. Default is 10000.
+	MaxPatterns int `mapstructure:"max_patterns"`
+}
+
+// RawRecordConfig defines the column holding the log records as received.
+type RawRecordConfig struct {
+	// Enabled adds a RawRecord column holding each log record, with its resource and scope, serialized
+	// as an OTLP ExportLogsServiceRequest for lossless retention. Default is `false`.
+	Enabled bool `mapstructure:"enabled"`
+	// Encoding is either `proto` (default) for the OTLP protobuf encoding or `json` for OTLP JSON.
+	Encoding string `mapstructure:"encoding"`
+}
+
+// BodyJSONColumnConfig defines a column extracted from the log bodies.
+type BodyJSONColumnConfig struct {
+	// Name is the column name, distinct from the other columns of the logs table.
+	Name string `mapstructure:"name"`
+	// Path is the path of the value in the body, a chain of object members like `$.level` or `$.request.id`.
+	Path string `mapstructure:"path"`
+}
+
+// ErrorLogsConfig defines the table of the log records at or above a severity.
+type ErrorLogsConfig struct {
+	// Enabled writes the log records whose severity number is at least MinSeverity into their own table,
+	// with the schema of the logs table and its own TTL, instead of the logs table. Default is `false`.
+	Enabled bool `mapstructure:"enabled"`
+	// MinSeverity is the lowest severity routed to the table, one of TRACE, DEBUG, INFO, WARN, ERROR, FATAL,
+	// optionally followed by 2 to 4, e.g. ERROR2. Records without a severity number are not routed. Default is `ERROR`.
+	MinSeverity string `mapstructure:"min_severity"`
+	// TableName is the table name for error logs. default is `<logs_table_name>_errors`.
+	TableName string `mapstructure:"table_name"`
+	// TTL is the data time-to-live of the error logs table, usually longer than ttl. 0 means the exporter TTL is used.
+	TTL time.Duration `mapstructure:"ttl"`
+}
+
+// TracesConfig defines trace specific schema options.
+type TracesConfig struct {
+	SignalConfig `mapstructure:",squash"`
+	// Schema is either `otel` (default) to write the spans into the traces table, or `jaeger` to write them into
+	// the spans, index and operations tables of the jaeger-clickhouse plugin with its json encoding, so that Jaeger
+	// queries them as written by the plugin. The other traces options only apply to the otel schema.
+	Schema string `mapstructure:"schema"`
+	// Jaeger defines the tables of the jaeger schema.
+	Jaeger JaegerSchemaConfig `mapstructure:"jaeger"`
+	// EventsLinks controls where span events and links are stored.
+	EventsLinks EventsLinksConfig `mapstructure:"events_links"`
+	// ServiceGraph maintains a table of the calls between services, for dependency maps.
+	ServiceGraph ServiceGraphConfig `mapstructure:"service_graph"`
+	// Deduplicate creates the traces table as a ReplacingMergeTree with TraceId and SpanId ending its sorting key,
+	// so that the spans written again, by retries or by collectors running side by side, are merged away, and
+	// sends a token identifying the spans of each insert, so that the retries of an insert are skipped outright.
+	// Until merged, duplicates are only hidden by queries with FINAL. Default is `false`.
+	Deduplicate bool `mapstructure:"deduplicate"`
+	// SpanNames normalizes the span names holding ids, which would make the cardinality of SpanName explode.
+	SpanNames SpanNamesConfig `mapstructure:"span_names"`
+	// EnumColumns creates the SpanKind and StatusCode columns of the traces table as Enum8 rather than
+	// LowCardinality(String), their values being written as the numbers of the OTLP enums. Default is `false`.
+	EnumColumns bool `mapstructure:"enum_columns"`
+	// TraceSummary maintains a table of the spans, errors, timespan and services of each trace, for trace lists.
+	TraceSummary TraceSummaryConfig `mapstructure:"trace_summary"`
+	// DurationRollup maintains a table of the per minute duration percentiles of each service and span name.
+	DurationRollup DurationRollupConfig `mapstructure:"duration_rollup"`
+	// RootSpans maintains a table of the root spans, to search traces without scanning all their spans.
+	RootSpans RootSpansConfig `mapstructure:"root_spans"`
+	// TableRoutes writes the spans matching OTTL conditions into other tables, each span into the table of
+	// the first route it matches. The other spans are written into the traces table.
+	TableRoutes []TableRouteConfig `mapstructure:"table_routes"`
+}
+
+// EventsLinksConfig defines how span events and links are stored.
+type EventsLinksConfig struct {
+	// Mode is either `nested` (default) to store events and links as Nested columns of the traces table,
+	// or `separate_tables` to write them into their own tables keyed by TraceId/SpanId.
+	Mode string `mapstructure:"mode"`
+	// EventsTableName is the table name for span events. default is `<traces_table_name>_events`.
+	EventsTableName string `mapstructure:"events_table_name"`
+	// LinksTableName is the table name for span links. default is `<traces_table_name>_links`.
+	LinksTableName string `mapstructure:"links_table_name"`
+	// TTL is the data time-to-live of the events and links tables. 0 means the exporter TTL is used.
+	TTL time.Duration `mapstructure:"ttl"`
+}
+
+// JaegerSchemaConfig defines the tables of the jaeger traces schema, named as configured for the jaeger-clickhouse plugin.
+type JaegerSchemaConfig struct {
+	// SpansTableName is the table of the encoded spans. default is `jaeger_spans_local`.
+	SpansTableName string `mapstructure:"spans_table_name"`
+	// IndexTableName is the table of the searchable fields and tags of the spans. default is `jaeger_index_local`.
+	IndexTableName string `mapstructure:"index_table_name"`
+	// OperationsTableName is the materialized view of the operations of each service. default is `jaeger_operations_local`.
+	OperationsTableName string `mapstructure:"operations_table_name"`
+}
+
+// ServiceGraphConfig defines the service edges table of the traces table.
+type ServiceGraphConfig struct {
+	// Enabled creates a SummingMergeTree table filled by a materialized view on the traces table with, per minute,
+	// the calls, errors and total duration of the client and producer spans from their service, the caller, to the
+	// service they call, the callee. The callee is the peer.service attribute of the span, spans without it being
+	// skipped, as the server spans of the callee are not in the same insert as their parent. Default is `false`.
+	Enabled bool `mapstructure:"enabled"`
+	// TableName is the table name for service edges, shared by the traces tables. default is `otel_service_edges`.
+	TableName string `mapstructure:"table_name"`
+	// TTL is the data time-to-live of the service edges table. 0 means the exporter TTL is used.
+	TTL time.Duration `mapstructure:"ttl"`
+}
+
+// SpanNamesConfig defines the normalization of the span names.
+type SpanNamesConfig struct {
+	// Rules replace the matches of regular expressions in the span names, in order.
+	Rules []SpanNameRuleConfig `mapstructure:"rules"`
+	// PathSegments replaces the '/' separated segments of the span names made of digits, of a UUID or of at least
+	// 16 hex digits with `{id}`, after the rules. Default is `false`.
+	PathSegments bool `mapstructure:"path_segments"`
+	// OriginalAttribute is the span attribute written with the name of the spans renamed. default is `span.original_name`.
+	OriginalAttribute string `mapstructure:"original_attribute"`
+}
+
+// SpanNameRuleConfig replaces the matches of a regular expression in the span names.
+type SpanNameRuleConfig struct {
+	// Pattern is the regular expression, in the RE2 syntax.
+	Pattern string `mapstructure:"pattern"`
+	// Replacement replaces the matches of Pattern, `$1` referring to its first submatch.
+	Replacement string `mapstructure:"replacement"`
+}
+
+// TraceSummaryConfig defines the trace summary table of the traces table.
+type TraceSummaryConfig struct {
+	// Enabled creates a `<traces_table_name>_trace_summary` AggregatingMergeTree table filled by a materialized
+	// view on the traces table with, per TraceId, the number of spans and of spans with the Error status code,
+	// the earliest start and latest end of the spans, the services of the spans, and the service and name of the
+	// root span. The rows of a trace are aggregated on merges, so queries group them by TraceId. Default is `false`.
+	Enabled bool `mapstructure:"enabled"`
+	// TTL is the data time-to-live of the trace summary table. 0 means the exporter TTL is used.
+	TTL time.Duration `mapstructure:"ttl"`
+}
+
+// DurationRollupConfig defines the span duration rollup table of the traces table.
+type DurationRollupConfig struct {
+	// Enabled creates a `<traces_table_name>_duration_1m` AggregatingMergeTree table filled by a materialized view
+	// on the traces table with, per ServiceName, SpanName and minute, the number of spans and of spans with the Error
+	// status code, and a t-digest of the span durations, read with
+	// `quantilesTDigestMerge(0.5, 0.9, 0.95, 0.99)(Duration)`. Default is `false`.
+	Enabled bool `mapstructure:"enabled"`
+	// TTL is the data time-to-live of the duration rollup table. 0 means the exporter TTL is used.
+	TTL time.Duration `mapstructure:"ttl"`
+}
+
+// RootSpansConfig defines the root spans table of the traces table.
+type RootSpansConfig struct {
+	// Enabled creates a `<traces_table_name>_root_spans` table, filled by a materialized view on the traces table
+	// with the TraceId, ServiceName, SpanName, Duration and StatusCode of the spans without ParentSpanId.
+	// Default is `false`.
+	Enabled bool `mapstructure:"enabled"`
+	// TTL is the data time-to-live of the root spans table. 0 means the exporter TTL is used.
+	TTL time.Duration `mapstructure:"ttl"`
+}
+
+// MetricsConfig defines metric specific schema options.
+type MetricsConfig struct {
+	SignalConfig `mapstructure:",squash"`
+	// Exemplars controls where datapoint exemplars are stored.
+	Exemplars ExemplarsConfig `mapstructure:"exemplars"`
+	// DeltaToCumulative converts delta sums to cumulative before they are written.
+	DeltaToCumulative DeltaToCumulativeConfig `mapstructure:"delta_to_cumulative"`
+	// Metadata maintains a table describing the metrics, to list them without scanning the datapoint tables.
+	Metadata MetricsMetadataConfig `mapstructure:"metadata"`
+	// Rollups aggregates the gauge and sum datapoints into tables of coarser resolution.
+	Rollups MetricsRollupsConfig `mapstructure:"rollups"`
+	// CounterRates maintains a table of the per minute increase of the monotonic sums.
+	CounterRates MetricsCounterRatesConfig `mapstructure:"counter_rates"`
+	// CardinalityLimit limits the number of series written per metric name.
+	CardinalityLimit CardinalityLimitConfig `mapstructure:"cardinality_limit"`
+	// NonFiniteValues is how the NaN, +Inf and -Inf values of gauge and sum datapoints, and the sum, min and max
+	// of histogram datapoints, are written: `keep` (default) unchanged, `drop` the datapoint, `clamp` to the
+	// largest and smallest Float64 and 0 for NaN, or `null` with the value columns created Nullable.
+	NonFiniteValues string `mapstructure:"non_finite_values"`
+	// StaleDataPoints is how the datapoints with the NoRecordedValue flag, which mark a series as stale, are written:
+	// `keep` (default) unchanged, `drop` the datapoint, `null` with the value columns created Nullable, or `column`
+	// unchanged with an IsStale column set, so that the staleness markers can be told apart from real values.
+	StaleDataPoints string `mapstructure:"stale_datapoints"`
+	// ExponentialHistogramMaxBuckets downscales the exponential histogram datapoints whose positive and negative
+	// buckets hold more entries, merging adjacent buckets until they fit. 0 (default) writes the buckets as they are.
+	ExponentialHistogramMaxBuckets int `mapstructure:"exponential_histogram_max_buckets"`
+	// SummaryMode is either `nested` (default) to write summaries into the summary table with their quantiles
+	// in the ValueAtQuantiles Nested column, or `gauges` to write them into the gauge table as in the Prometheus
+	// data model: a row per quantile with a `quantile` attribute, and the <name>_sum and <name>_count series.
+	SummaryMode string `mapstructure:"summary_mode"`
+	// HistogramMode is either `arrays` (default) to write histograms into the histogram table with their buckets
+	// in the BucketCounts and ExplicitBounds Array columns, or `buckets` to write them into the sum table as in the
+	// Prometheus data model: a <name>_bucket row per bucket with an `le` attribute and the cumulative count,
+	// and the <name>_sum and <name>_count series.
+	HistogramMode string `mapstructure:"histogram_mode"`
+	// Schema is either `per_type` (default) to write the datapoints into a table per metric type, see metrics_tables,
+	// or `unified` to write all of them into the single table metrics_table_name, default `otel_metrics`, with a
+	// MetricType column and the columns of every type, those of the other types holding their default values.
+	Schema string `mapstructure:"schema"`
+	// NullableHistogramStats creates the Sum, Min and Max columns of the histogram and exponential histogram
+	// tables Nullable, writing NULL instead of 0 for the sum, min and max not set on a datapoint.
+	NullableHistogramStats bool `mapstructure:"nullable_histogram_stats"`
+	// AttributesHash adds a hash of the datapoint attributes to the metric tables.
+	AttributesHash AttributesHashConfig `mapstructure:"attributes_hash"`
+}
+
+// AttributesHashConfig defines the AttrHash column of the metric tables.
+type AttributesHashConfig struct {
+	// Enabled adds an AttrHash UInt64 column holding a 64-bit hash of the datapoint attributes,
+	// to count and group series without reading the attributes. Default is `false`.
+	Enabled bool `mapstructure:"enabled"`
+	// OrderBy adds AttrHash to the sorting key of the created tables, before the attributes. Default is `false`.
+	OrderBy bool `mapstructure:"order_by"`
+}
+
+// MetricsRollupsConfig defines the rollup tables of the gauge and sum tables.
+type MetricsRollupsConfig struct {
+	// Enabled creates an AggregatingMergeTree table per interval for the gauge and sum tables, e.g.
+	// `otel_metrics_gauge_5m`, filled by a materialized view with the min, max, sum, count and last
+	// value of the datapoints of each series in the interval. Default is `false`.
+	Enabled bool `mapstructure:"enabled"`
+	// Intervals are the resolutions of the rollup tables, whole seconds. Default is 1m, 5m and 1h.
+	Intervals []time.Duration `mapstructure:"intervals"`
+	// TTL is the data time-to-live of the rollup tables, usually longer than ttl. 0 means the exporter TTL is used.
+	TTL time.Duration `mapstructure:"ttl"`
+}
+
+// CardinalityLimitConfig defines the limit of the number of series per metric name.
+type CardinalityLimitConfig struct {
+	// Enabled counts the distinct resource and datapoint attribute sets of each metric name in memory,
+	// the series above MaxSeriesPerMetric being limited by Action. Default is `false`.
+	// Each collector counts the series it exports, so the limit applies per collector.
+	Enabled bool `mapstructure:"enabled"`
+	// MaxSeriesPerMetric is the number of series written per metric name. Default is 10000.
+	MaxSeriesPerMetric int `mapstructure:"max_series_per_metric"`
+	// MaxStale is how long a series is counted without new datapoints. Default is 1h.
+	MaxStale time.Duration `mapstructure:"max_stale"`
+	// Action is either `drop` (default) to drop the datapoints of the series above the limit, or `aggregate`
+	// to write them into a single series of their metric with the `otel.metric.overflow` attribute only.
+	Action string `mapstructure:"action"`
+}
+
+// MetricsCounterRatesConfig defines the counter rates table of the sum table.
+type MetricsCounterRatesConfig struct {
+	// Enabled creates an AggregatingMergeTree table filled by a materialized view on the sum table with,
+	// per series and minute, the increase of the monotonic delta sums and the last value of the monotonic
+	// cumulative sums, whose increase is the difference with the previous minute of the same StartTimeUnix,
+	// a new StartTimeUnix marking a reset. Default is `false`.
+	Enabled bool `mapstructure:"enabled"`
+	// TableName is the table name for counter rates. default is `<sum table>_rate`.
+	TableName string `mapstructure:"table_name"`
+	// TTL is the data time-to-live of the counter rates table. 0 means the exporter TTL is used.
+	TTL time.Duration `mapstructure:"ttl"`
+}
+
+// MetricsMetadataConfig defines the metrics metadata table.
+type MetricsMetadataConfig struct {
+	// Enabled writes a row per distinct metric name, type, unit, description, temporality and monotonicity
+	// into the metadata table, refreshed hourly. Default is `false`.
+	Enabled bool `mapstructure:"enabled"`
+	// TableName is the table name for metrics metadata. default is `<metrics_table_name>_metadata`.
+	TableName string `mapstructure:"table_name"`
+}
+
+// DeltaToCumulativeConfig defines the conversion of delta sums to cumulative.
+type DeltaToCumulativeConfig struct {
+	// Enabled writes delta sums as cumulative, their value being the running total of each series
+	// since its first datapoint. Datapoints older than the last one of their series are dropped.
+	// The running totals are kept in memory, so each series should be exported by a single collector.
+	Enabled bool `mapstructure:"enabled"`
+	// MaxStale is how long the running total of a series is kept without new datapoints, after which
+	// the series starts again from zero. Default is 5m.
+	MaxStale time.Duration `mapstructure:"max_stale"`
+}
+
+// ExemplarsConfig defines how datapoint exemplars are stored.
+type ExemplarsConfig struct {
+	// Mode is either `inline` (default) to store exemplars as a Nested column of each metric table,
+	// `separate_table` to write them into a dedicated table, or `drop` to discard them.
+	Mode string `mapstructure:"mode"`
+	// TableName is the table name for exemplars. default is `<metrics_table_name>_exemplars`.
+	TableName string `mapstructure:"table_name"`
+	// TTL is the data time-to-live of the exemplars table. 0 means the exporter TTL is used.
+	TTL time.Duration `mapstructure:"ttl"`
+	// MaxPerDataPoint is the number of exemplars stored per datapoint, keeping the latest ones.
+	// 0 (default) stores all of them.
+	MaxPerDataPoint int `mapstructure:"max_per_datapoint"`
 }
 
 type MetricTablesConfig struct {
@@ -79,53 +895,906 @@ type MetricTablesConfig struct {
 	ExponentialHistogram internal.MetricTypeConfig `mapstructure:"exponential_histogram"`
 }
 
-// TableEngine defines the ENGINE string value when creating the table.
-type TableEngine struct {
-	Name   string `mapstructure:"name"`
-	Params string `mapstructure:"params"`
+// TableEngine defines the ENGINE string value when creating the table.
+type TableEngine struct {
+	Name   string `mapstructure:"name"`
+	Params string `mapstructure:"params"`
+}
+
+// DatabaseEngine defines the ENGINE of the created database.
+type DatabaseEngine struct {
+	// Name is `Atomic` or `Replicated`.
+	Name string `mapstructure:"name"`
+	// ZooPath is the Keeper path shared by the replicas of a Replicated database, `{database}` being
+	// replaced with the database name. Default is `/clickhouse/databases/{database}`.
+	ZooPath string `mapstructure:"zoo_path"`
+	// ShardName is the shard of the replica of a Replicated database. Default is the `{shard}` macro.
+	ShardName string `mapstructure:"shard_name"`
+	// ReplicaName is the name of the replica of a Replicated database. Default is the `{replica}` macro.
+	ReplicaName string `mapstructure:"replica_name"`
+}
+
+// DistributedDDLConfig defines the distributed_ddl settings sent with the queries of the exporter when a
+// cluster_name is set. The settings given in the endpoint or connection_params take precedence.
+type DistributedDDLConfig struct {
+	// TaskTimeout is how long each ON CLUSTER DDL waits for all the hosts to complete it, in whole seconds.
+	// Zero doesn't wait. Default is 180s.
+	TaskTimeout time.Duration `mapstructure:"task_timeout"`
+	// OutputMode is the distributed_ddl_output_mode. The default `throw` fails the DDL, and start, unless it
+	// completed on all the hosts in time, so that inserts don't race replicas still creating the tables.
+	OutputMode string `mapstructure:"output_mode"`
+}
+
+var distributedDDLOutputModes = []string{
+	"throw", "none", "null_status_on_timeout", "never_throw",
+	"throw_only_active", "null_status_on_timeout_only_active", "none_only_active",
+}
+
+const (
+	databaseEngineAtomic     = "Atomic"
+	databaseEngineReplicated = "Replicated"
+
+	defaultDatabaseZooPath     = "/clickhouse/databases/{database}"
+	defaultDatabaseShardName   = "{shard}"
+	defaultDatabaseReplicaName = "{replica}"
+)
+
+const (
+	defaultDatabase            = "default"
+	defaultTableEngineName     = "MergeTree"
+	defaultMetricTableName     = "otel_metrics"
+	defaultGaugeSuffix         = "_gauge"
+	defaultSumSuffix           = "_sum"
+	defaultSummarySuffix       = "_summary"
+	defaultHistogramSuffix     = "_histogram"
+	defaultExpHistogramSuffix  = "_exponential_histogram"
+	defaultEventsSuffix        = "_events"
+	defaultLinksSuffix         = "_links"
+	defaultExemplarsSuffix     = "_exemplars"
+	defaultMetadataSuffix      = "_metadata"
+	defaultCounterRatesSuffix  = "_rate"
+	defaultErrorLogsSuffix     = "_errors"
+	defaultTraceIDTsSuffix     = "_trace_id_ts"
+	defaultServiceEdgesTable   = "otel_service_edges"
+	defaultRootSpansSuffix     = "_root_spans"
+	defaultTraceSummarySuffix  = "_trace_summary"
+	defaultDurationSuffix      = "_duration_1m"
+	defaultServiceMetadata     = "otel_service_metadata"
+	defaultServiceMetadataDict = "otel_service_metadata_dict"
+	defaultResourcesTable      = "otel_resources"
+	defaultScopesTable         = "otel_scopes"
+	defaultJaegerSpansTable    = "jaeger_spans_local"
+	defaultJaegerIndexTable    = "jaeger_index_local"
+	defaultJaegerOpsTable      = "jaeger_operations_local"
+)
+
+const (
+	queueFullPolicyBlock      = "block"
+	queueFullPolicyDropNewest = "drop_newest"
+	queueFullPolicyDropOldest = "drop_oldest"
+)
+
+const (
+	eventsLinksModeNested         = "nested"
+	eventsLinksModeSeparateTables = "separate_tables"
+)
+
+const (
+	tracesSchemaOTel   = "otel"
+	tracesSchemaJaeger = "jaeger"
+)
+
+const (
+	metricsSchemaPerType = "per_type"
+	metricsSchemaUnified = "unified"
+)
+
+const (
+	rawRecordEncodingProto = "proto"
+	rawRecordEncodingJSON  = "json"
+)
+
+const (
+	summaryModeNested = "nested"
+	summaryModeGauges = "gauges"
+)
+
+const (
+	histogramModeArrays  = "arrays"
+	histogramModeBuckets = "buckets"
+)
+
+// mergeTreeEngines are the table engines whose MergeTree family variants, e.g. AggregatingMergeTree, create the
+// tables of some features.
+const mergeTreeEngines = "a MergeTree, ReplicatedMergeTree or SharedMergeTree table engine"
+
+var (
+	errConfigNoEndpoint       = errors.New("endpoint or endpoints must be specified")
+	errConfigEndpointsDSN     = errors.New("endpoint and endpoints are mutually exclusive")
+	errConfigNoSignals        = errors.New("at least one of logs, traces or metrics must be enabled")
+	errSignalDisabled         = errors.New("signal is disabled")
+	errConfigAuth             = errors.New("only one of username, auth::api_key_id or auth::jwt can be set")
+	errConfigPasswordFile     = errors.New("password_file cannot be combined with password, auth::api_key_id or auth::jwt")
+	errConfigJWT              = errors.New("exactly one of auth::jwt::token or auth::jwt::token_file must be set")
+	errConfigFailover         = errors.New("failover requires at least two endpoints, a positive max_failures and probe_interval")
+	errConfigDeadLetterQueue  = errors.New("dead_letter_queue requires a directory and non-negative max_files and max_size_mib")
+	errConfigTooManyParts     = errors.New("too_many_parts_backoff::initial_interval must be positive and not exceed max_interval")
+	errConfigWriteAheadLog    = errors.New("write_ahead_log requires a directory and a non-negative retry_interval")
+	errConfigWriteAheadLogDir = errors.New("write_ahead_log::directory must differ from dead_letter_queue::directory")
+	errConfigMirror           = errors.New("mirror requires endpoints")
+	errConfigShadow           = errors.New("shadow requires a database other than those of the exporter and a sample_ratio between 0 and 1")
+	errConfigHealthCheck      = errors.New("health_check_interval must not be negative")
+	errConfigDebugEndpoint    = errors.New("debug_endpoint must be a host:port address")
+	errConfigStartupRetry     = errors.New("startup_retry::max_attempts, interval and timeout must not be negative")
+	errConfigInvalidEndpoint  = errors.New("invalid endpoint")
+	errConfigQueueFullPolicy  = errors.New("queue_full_policy must be one of block, drop_newest, drop_oldest")
+	errConfigAttributeKeys    = errors.New("attributes::include and attributes::exclude patterns must not be empty")
+	errConfigMaxAttrValue     = errors.New("max_attribute_value_bytes must not be negative")
+	errConfigIDEncoding       = errors.New("id_encoding must be one of hex, binary")
+	errConfigSchemaCompat     = errors.New("schema_compat must be contrib-v0.126 and requires metrics::enabled false, the otel traces schema with nested events and links, and no tenant, dimensions, rotation, error logs, table routes or templated table names")
+	errConfigEventsLinksMode  = errors.New("traces::events_links::mode must be one of nested, separate_tables")
+	errConfigExemplarsMode    = errors.New("metrics::exemplars::mode must be one of inline, separate_table, drop")
+	errConfigExemplarsMax     = errors.New("metrics::exemplars::max_per_datapoint must not be negative")
+	errConfigDeltaCumulative  = errors.New("metrics::delta_to_cumulative::max_stale must be positive")
+	errConfigCardinality      = errors.New("metrics::cardinality_limit requires a positive max_series_per_metric and max_stale, and action one of drop, aggregate")
+	errConfigNonFinite        = errors.New("metrics::non_finite_values must be one of keep, drop, clamp, null")
+	errConfigTracesSchema     = errors.New("traces::schema must be one of otel, jaeger, the jaeger schema requiring " + mergeTreeEngines)
+	errConfigMetricsSchema    = errors.New("metrics::schema must be one of per_type, unified")
+	errConfigStaleness        = errors.New("metrics::stale_datapoints must be one of keep, drop, null, column")
+	errConfigExpHistogramMax  = errors.New("metrics::exponential_histogram_max_buckets must not be negative")
+	errConfigSummaryMode      = errors.New("metrics::summary_mode must be one of nested, gauges")
+	errConfigHistogramMode    = errors.New("metrics::histogram_mode must be one of arrays, buckets")
+	errConfigRollups          = errors.New("metrics::rollups requires distinct intervals of whole seconds and " + mergeTreeEngines)
+	errConfigErrorLogs        = errors.New("logs::error_logs requires a min_severity one of TRACE, DEBUG, INFO, WARN, ERROR, FATAL, optionally followed by 2 to 4, and a table name without placeholders")
+	errConfigMaxBodyBytes     = errors.New("logs::max_body_bytes must not be negative")
+	errConfigBodyJSONColumns  = errors.New("logs::body_json_columns require distinct column names, made of letters, digits and '_', not used by the logs table, and paths like $.request.id")
+	errConfigRawRecord        = errors.New("logs::raw_record::encoding must be one of proto, json")
+	errConfigPatterns         = errors.New("logs::patterns requires a similarity_threshold between 0 and 1 and a positive max_patterns")
+	errConfigCounterRates     = errors.New("metrics::counter_rates requires " + mergeTreeEngines)
+	errConfigDeduplicate      = errors.New("traces::deduplicate requires " + mergeTreeEngines)
+	errConfigTraceSummary     = errors.New("traces::trace_summary requires " + mergeTreeEngines)
+	errConfigDurationRollup   = errors.New("traces::duration_rollup requires " + mergeTreeEngines)
+	errConfigSpanNames        = errors.New("traces::span_names requires valid rule patterns and an original_attribute")
+	errConfigTableRoutes      = errors.New("table_routes require valid OTTL conditions and a table_name, and don't apply to the jaeger schema")
+	errConfigServiceGraph     = errors.New("traces::service_graph requires " + mergeTreeEngines)
+	errConfigTableSettings    = errors.New("table_settings require setting names made of letters, digits and '_', and values without ',' or ';'")
+	errConfigTTLRollups       = errors.New("ttl_rollups require a table, a positive after, group_by columns and set aggregates")
+	errConfigProjection       = errors.New("projections require table, name and query")
+	errConfigRouting          = errors.New("routing::routes require routing::attribute")
+	errConfigTenant           = errors.New("tenant requires a column made of letters, digits and '_', and an auth_attribute or metadata_key")
+	errConfigTenantPartition  = errors.New("tenant::partition requires tenant::enabled")
+	errConfigQuotas           = errors.New("quotas require tenant::auth_attribute or tenant::metadata_key, rates not negative, a positive burst and an action one of drop, defer")
+	errConfigServiceMetadata  = errors.New("service_metadata requires distinct attributes made of letters, digits and '_', and a lifetime of positive whole seconds")
+	errConfigDimensions       = errors.New("dimensions don't apply to the jaeger traces schema")
+	errConfigRotation         = errors.New("rotation requires a period one of weekly, monthly, logs and traces table names without placeholders, and doesn't apply to the jaeger traces schema")
+	errConfigPartitionBy      = errors.New("partition_by must be one of hourly, daily, weekly, monthly")
+	errConfigIdentifier       = errors.New("invalid identifier, only letters, digits, '_' and '-' are allowed")
+	errConfigTableName        = errors.New("table name must not be empty")
+	errConfigTTL              = errors.New("invalid ttl")
+	errConfigClusterEngine    = errors.New("tables created on a cluster require a Replicated or Shared table engine")
+	errConfigDistributedDDL   = errors.New("distributed_ddl requires a task_timeout of non-negative whole seconds and a valid output_mode")
+	errConfigDatabaseEngine   = errors.New("database_engine::name must be one of Atomic, Replicated, zoo_path, shard_name and replica_name only applying to Replicated")
+)
+
+var (
+	identifierRegexp = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_-]*$`)
+	columnNameRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	// clusterRegexp additionally allows server macros such as `{cluster}`.
+	clusterRegexp = regexp.MustCompile(`^[A-Za-z0-9_{][A-Za-z0-9_{}-]*$`)
+)
+
+// Validate the ClickHouse server configuration.
+func (cfg *Config) Validate() error {
+	err := errors.Join(cfg.validateConnection(), cfg.validateDelivery())
+	cfg.buildMetricTableNames()
+	return errors.Join(err,
+		cfg.validateSignals(),
+		cfg.validateLogs(),
+		cfg.validateTraces(),
+		cfg.validateMetrics(),
+		cfg.validateSchema(),
+		cfg.validateTenancy(),
+		cfg.validateIdentifiers(),
+		cfg.validateTables(),
+	)
+}
+
+// validateConnection checks the endpoints, authentication, failover and probes of the connection.
+func (cfg *Config) validateConnection() (err error) {
+	switch {
+	case cfg.Endpoint == "" && len(cfg.Endpoints) == 0:
+		err = errors.Join(err, errConfigNoEndpoint)
+	case cfg.Endpoint != "" && len(cfg.Endpoints) != 0:
+		err = errors.Join(err, errConfigEndpointsDSN)
+	}
+	err = errors.Join(err, cfg.validateAuth())
+	if cfg.Failover.Enabled && (len(cfg.Endpoints) < 2 || cfg.Failover.MaxFailures <= 0 || cfg.Failover.ProbeInterval <= 0) {
+		err = errors.Join(err, errConfigFailover)
+	}
+	if cfg.HealthCheckInterval < 0 {
+		err = errors.Join(err, errConfigHealthCheck)
+	}
+	if cfg.DebugEndpoint != "" {
+		if _, _, e := net.SplitHostPort(cfg.DebugEndpoint); e != nil {
+			err = errors.Join(err, errConfigDebugEndpoint)
+		}
+	}
+	if retry := cfg.StartupRetry; retry.MaxAttempts < 0 || retry.Interval < 0 || retry.Timeout < 0 {
+		err = errors.Join(err, errConfigStartupRetry)
+	}
+	if cfg.Endpoint == "" && len(cfg.Endpoints) == 0 {
+		return err
+	}
+	dsn, e := cfg.buildDSN()
+	if e != nil {
+		return errors.Join(err, e)
+	}
+	err = errors.Join(err, cfg.validateEndpoint())
+	// Validate DSN with clickhouse driver.
+	// Last chance to catch invalid config.
+	if _, e := clickhouse.ParseDSN(dsn); e != nil {
+		err = errors.Join(err, e)
+	}
+	return err
+}
+
+// validateDelivery checks the mirror, shadow, dead letter queue, write-ahead log and backoff of the inserts.
+func (cfg *Config) validateDelivery() (err error) {
+	if cfg.Mirror.Enabled {
+		if len(cfg.Mirror.Endpoints) == 0 {
+			err = errors.Join(err, errConfigMirror)
+		} else if _, e := cfg.mirrorConfig().buildDSN(); e != nil {
+			err = errors.Join(err, fmt.Errorf("mirror: %w", e))
+		}
+	}
+	if cfg.Shadow.Enabled {
+		err = errors.Join(err, cfg.validateShadow())
+	}
+	if dlq := cfg.DeadLetterQueue; dlq.Enabled && (dlq.Directory == "" || dlq.MaxFiles < 0 || dlq.MaxSizeMiB < 0) {
+		err = errors.Join(err, errConfigDeadLetterQueue)
+	}
+	if backoff := cfg.TooManyPartsBackoff; backoff.Enabled && (backoff.InitialInterval <= 0 || backoff.MaxInterval < backoff.InitialInterval) {
+		err = errors.Join(err, errConfigTooManyParts)
+	}
+	if wal := cfg.WriteAheadLog; wal.Enabled && (wal.Directory == "" || wal.RetryInterval < 0) {
+		err = errors.Join(err, errConfigWriteAheadLog)
+	}
+	if wal, dlq := cfg.WriteAheadLog, cfg.DeadLetterQueue; wal.Enabled && dlq.Enabled && wal.Directory != "" && filepath.Clean(wal.Directory) == filepath.Clean(dlq.Directory) {
+		err = errors.Join(err, errConfigWriteAheadLogDir)
+	}
+	return err
+}
+
+// validateSignals checks the settings shared by the signals.
+func (cfg *Config) validateSignals() (err error) {
+	if !cfg.Logs.Enabled && !cfg.Traces.Enabled && !cfg.Metrics.Enabled {
+		err = errors.Join(err, errConfigNoSignals)
+	}
+	for name, signal := range map[string]SignalConfig{"logs": cfg.Logs.SignalConfig, "traces": cfg.Traces.SignalConfig, "metrics": cfg.Metrics.SignalConfig} {
+		switch signal.QueueFullPolicy {
+		case "", queueFullPolicyBlock, queueFullPolicyDropNewest, queueFullPolicyDropOldest:
+		default:
+			err = errors.Join(err, fmt.Errorf("%s::%w", name, errConfigQueueFullPolicy))
+		}
+		if slices.Contains(signal.Attributes.Include, "") || slices.Contains(signal.Attributes.Exclude, "") {
+			err = errors.Join(err, fmt.Errorf("%s::%w", name, errConfigAttributeKeys))
+		}
+	}
+	if cfg.MaxAttributeValueBytes < 0 {
+		err = errors.Join(err, errConfigMaxAttrValue)
+	}
+	if idEncoding := cfg.idEncoding(); idEncoding != internal.IDEncodingHex && idEncoding != internal.IDEncodingBinary {
+		err = errors.Join(err, errConfigIDEncoding)
+	}
+	if cfg.SchemaCompat != "" && !cfg.validSchemaCompat() {
+		err = errors.Join(err, errConfigSchemaCompat)
+	}
+	return errors.Join(err, cfg.validateTableRoutes())
+}
+
+// validateLogs checks the logs settings.
+func (cfg *Config) validateLogs() (err error) {
+	if cfg.Logs.ErrorLogs.Enabled {
+		if _, ok := parseSeverity(cfg.Logs.ErrorLogs.MinSeverity); !ok || internal.TableTemplate(cfg.errorLogsTableName()).IsTemplate() {
+			err = errors.Join(err, errConfigErrorLogs)
+		}
+	}
+	if cfg.Logs.MaxBodyBytes < 0 {
+		err = errors.Join(err, errConfigMaxBodyBytes)
+	}
+	err = errors.Join(err, cfg.validateBodyJSONColumns())
+	if patterns := cfg.Logs.Patterns; patterns.Enabled && (patterns.SimilarityThreshold < 0 || patterns.SimilarityThreshold > 1 || patterns.MaxPatterns <= 0) {
+		err = errors.Join(err, errConfigPatterns)
+	}
+	if rawRecord := cfg.Logs.RawRecord; rawRecord.Enabled && rawRecord.Encoding != rawRecordEncodingProto && rawRecord.Encoding != rawRecordEncodingJSON {
+		err = errors.Join(err, errConfigRawRecord)
+	}
+	return err
+}
+
+// validateTraces checks the traces settings and the table engines of the traces features.
+func (cfg *Config) validateTraces() (err error) {
+	switch cfg.Traces.EventsLinks.Mode {
+	case "", eventsLinksModeNested, eventsLinksModeSeparateTables:
+	default:
+		err = errors.Join(err, errConfigEventsLinksMode)
+	}
+	switch cfg.Traces.Schema {
+	case "", tracesSchemaOTel:
+	case tracesSchemaJaeger:
+		err = errors.Join(err, cfg.validateMergeTreeVariant(cfg.Traces.SignalConfig, "Summing", errConfigTracesSchema))
+	default:
+		err = errors.Join(err, errConfigTracesSchema)
+	}
+	_, spanNamesErr := cfg.spanNameNormalizer()
+	err = errors.Join(err, spanNamesErr)
+	if cfg.Traces.Deduplicate {
+		err = errors.Join(err, cfg.validateMergeTreeVariant(cfg.Traces.SignalConfig, "Replacing", errConfigDeduplicate))
+	}
+	if cfg.Traces.TraceSummary.Enabled {
+		err = errors.Join(err, cfg.validateMergeTreeVariant(cfg.Traces.SignalConfig, "Aggregating", errConfigTraceSummary))
+	}
+	if cfg.Traces.DurationRollup.Enabled {
+		err = errors.Join(err, cfg.validateMergeTreeVariant(cfg.Traces.SignalConfig, "Aggregating", errConfigDurationRollup))
+	}
+	if cfg.Traces.ServiceGraph.Enabled {
+		err = errors.Join(err, cfg.validateMergeTreeVariant(cfg.Traces.SignalConfig, "Summing", errConfigServiceGraph))
+	}
+	return err
+}
+
+// validateMetrics checks the metrics settings and the table engines of the metrics features.
+func (cfg *Config) validateMetrics() (err error) {
+	switch internal.ExemplarsMode(cfg.Metrics.Exemplars.Mode) {
+	case "", internal.ExemplarsModeInline, internal.ExemplarsModeSeparateTable, internal.ExemplarsModeDrop:
+	default:
+		err = errors.Join(err, errConfigExemplarsMode)
+	}
+	if cfg.Metrics.Exemplars.MaxPerDataPoint < 0 {
+		err = errors.Join(err, errConfigExemplarsMax)
+	}
+	if cfg.Metrics.ExponentialHistogramMaxBuckets < 0 {
+		err = errors.Join(err, errConfigExpHistogramMax)
+	}
+	switch cfg.Metrics.Schema {
+	case "", metricsSchemaPerType, metricsSchemaUnified:
+	default:
+		err = errors.Join(err, errConfigMetricsSchema)
+	}
+	switch cfg.Metrics.SummaryMode {
+	case "", summaryModeNested, summaryModeGauges:
+	default:
+		err = errors.Join(err, errConfigSummaryMode)
+	}
+	switch cfg.Metrics.HistogramMode {
+	case "", histogramModeArrays, histogramModeBuckets:
+	default:
+		err = errors.Join(err, errConfigHistogramMode)
+	}
+	switch internal.NonFinitePolicy(cfg.Metrics.NonFiniteValues) {
+	case "", internal.NonFiniteKeep, internal.NonFiniteDrop, internal.NonFiniteClamp, internal.NonFiniteNull:
+	default:
+		err = errors.Join(err, errConfigNonFinite)
+	}
+	switch internal.StalenessPolicy(cfg.Metrics.StaleDataPoints) {
+	case "", internal.StaleKeep, internal.StaleDrop, internal.StaleNull, internal.StaleColumn:
+	default:
+		err = errors.Join(err, errConfigStaleness)
+	}
+	if cfg.Metrics.DeltaToCumulative.Enabled && cfg.Metrics.DeltaToCumulative.MaxStale <= 0 {
+		err = errors.Join(err, errConfigDeltaCumulative)
+	}
+	if cfg.Metrics.Rollups.Enabled {
+		err = errors.Join(err, cfg.validateRollups())
+	}
+	if cfg.Metrics.CounterRates.Enabled {
+		err = errors.Join(err, cfg.validateMergeTreeVariant(cfg.Metrics.SignalConfig, "Aggregating", errConfigCounterRates))
+	}
+	if limit := cfg.Metrics.CardinalityLimit; limit.Enabled {
+		switch internal.CardinalityAction(limit.Action) {
+		case "", internal.CardinalityDrop, internal.CardinalityAggregate:
+			if limit.MaxSeriesPerMetric <= 0 || limit.MaxStale <= 0 {
+				err = errors.Join(err, errConfigCardinality)
+			}
+		default:
+			err = errors.Join(err, errConfigCardinality)
+		}
+	}
+	return err
+}
+
+// validateSchema checks the settings of the databases, tables and schema objects created.
+func (cfg *Config) validateSchema() (err error) {
+	if engine := cfg.DatabaseEngine; !slices.Contains([]string{"", databaseEngineAtomic, databaseEngineReplicated}, engine.Name) ||
+		engine.Name != databaseEngineReplicated && engine != (DatabaseEngine{Name: engine.Name}) {
+		err = errors.Join(err, errConfigDatabaseEngine)
+	}
+	if ddl := cfg.DistributedDDL; ddl.TaskTimeout < 0 || ddl.TaskTimeout%time.Second != 0 || !slices.Contains(distributedDDLOutputModes, ddl.OutputMode) {
+		err = errors.Join(err, errConfigDistributedDDL)
+	}
+	if !validTableSettings(cfg.TableSettings) {
+		err = errors.Join(err, errConfigTableSettings)
+	}
+	for _, rollup := range cfg.TTLRollups {
+		if rollup.Table == "" || rollup.After <= 0 || len(rollup.GroupBy) == 0 || len(rollup.Set) == 0 {
+			err = errors.Join(err, errConfigTTLRollups)
+			break
+		}
+	}
+	for _, projection := range cfg.Projections {
+		if projection.Table == "" || projection.Name == "" || projection.Query == "" {
+			err = errors.Join(err, errConfigProjection)
+			break
+		}
+	}
+	if metadata := cfg.ServiceMetadata; metadata.Enabled && !validServiceMetadata(metadata) {
+		err = errors.Join(err, errConfigServiceMetadata)
+	}
+	if cfg.Rotation.Enabled && !cfg.validRotation() {
+		err = errors.Join(err, errConfigRotation)
+	}
+	if cfg.Dimensions.Enabled && cfg.jaegerSchema() {
+		err = errors.Join(err, errConfigDimensions)
+	}
+	for _, partitionBy := range []string{cfg.PartitionBy, cfg.Logs.PartitionBy, cfg.Traces.PartitionBy, cfg.Metrics.PartitionBy} {
+		switch internal.PartitionGranularity(partitionBy) {
+		case "", internal.PartitionHourly, internal.PartitionDaily, internal.PartitionWeekly, internal.PartitionMonthly:
+		default:
+			err = errors.Join(err, errConfigPartitionBy)
+		}
+	}
+	return err
+}
+
+// validateTenancy checks the routing of the tenants to their databases, the tenant column and the quotas.
+func (cfg *Config) validateTenancy() (err error) {
+	if len(cfg.Routing.Routes) != 0 && cfg.Routing.Attribute == "" {
+		err = errors.Join(err, errConfigRouting)
+	}
+	if tenant := cfg.Tenant; tenant.Enabled && (!columnNameRegexp.MatchString(tenant.Column) || tenant.AuthAttribute == "" && tenant.MetadataKey == "") {
+		err = errors.Join(err, errConfigTenant)
+	}
+	if cfg.Tenant.Partition && !cfg.Tenant.Enabled {
+		err = errors.Join(err, errConfigTenantPartition)
+	}
+	if cfg.Quotas.Enabled {
+		err = errors.Join(err, cfg.validateQuotas())
+	}
+	return err
+}
+
+// validateMergeTreeVariant returns errFeature, wrapped with the table engine of signal, if the engine has no
+// variant of the MergeTree family, e.g. ReplacingMergeTree for variant Replacing.
+func (cfg *Config) validateMergeTreeVariant(signal SignalConfig, variant string, errFeature error) error {
+	if _, ok := cfg.mergeTreeVariantFor(signal, variant); ok {
+		return nil
+	}
+	engine, _ := cfg.tableEngineFor(signal)
+	return fmt.Errorf("table engine %s: %w", engine, errFeature)
+}
+
+// validateQuotas checks that the tenant of the clients is read and the rates, burst and action of the quotas.
+func (cfg *Config) validateQuotas() error {
+	quotas := cfg.Quotas
+	if cfg.Tenant.AuthAttribute == "" && cfg.Tenant.MetadataKey == "" || quotas.Burst <= 0 {
+		return errConfigQuotas
+	}
+	if quotas.Action != quotaActionDrop && quotas.Action != quotaActionDefer {
+		return errConfigQuotas
+	}
+	rates := []TenantQuotaConfig{{RowsPerSecond: quotas.RowsPerSecond, BytesPerSecond: quotas.BytesPerSecond}}
+	for _, quota := range quotas.Tenants {
+		rates = append(rates, quota)
+	}
+	for _, rate := range rates {
+		if rate.RowsPerSecond < 0 || rate.BytesPerSecond < 0 {
+			return errConfigQuotas
+		}
+	}
+	return nil
+}
+
+// validateRollups checks the intervals of the rollup tables and that the metrics table engine has an Aggregating variant.
+func (cfg *Config) validateRollups() error {
+	if len(cfg.Metrics.Rollups.Intervals) == 0 {
+		return errConfigRollups
+	}
+	seen := map[time.Duration]bool{}
+	for _, interval := range cfg.Metrics.Rollups.Intervals {
+		if interval <= 0 || interval%time.Second != 0 || seen[interval] {
+			return fmt.Errorf("interval %s: %w", interval, errConfigRollups)
+		}
+		seen[interval] = true
+	}
+	return cfg.validateMergeTreeVariant(cfg.Metrics.SignalConfig, "Aggregating", errConfigRollups)
+}
+
+// validateBodyJSONColumns checks the names and paths of the columns extracted from the log bodies.
+func (cfg *Config) validateBodyJSONColumns() error {
+	seen := map[string]bool{}
+	for _, column := range cfg.Logs.BodyJSONColumns {
+		_, used := logsColumnComments[column.Name]
+		if _, ok := internal.ParseJSONPath(column.Path); !ok || used || seen[column.Name] || !columnNameRegexp.MatchString(column.Name) {
+			return fmt.Errorf("column %q: %w", column.Name, errConfigBodyJSONColumns)
+		}
+		seen[column.Name] = true
+	}
+	return nil
+}
+
+// bodyJSONPaths returns the paths of the columns extracted from the log bodies, in the order of the columns.
+func (cfg *Config) bodyJSONPaths() []internal.JSONPath {
+	var paths []internal.JSONPath
+	for _, column := range cfg.Logs.BodyJSONColumns {
+		path, _ := internal.ParseJSONPath(column.Path)
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+// attributeFilter returns the filter of the attribute keys and values written for signal, nil if they are all
+// written unchanged.
+func (cfg *Config) attributeFilter(signal SignalConfig) *internal.AttributeFilter {
+	return internal.NewAttributeFilter(signal.Attributes.Include, signal.Attributes.Exclude, cfg.MaxAttributeValueBytes, cfg.FlattenAttributes)
+}
+
+// spanNameNormalizer returns the normalizer of the span names, nil if they are not normalized.
+func (cfg *Config) spanNameNormalizer() (*internal.SpanNameNormalizer, error) {
+	spanNames := cfg.Traces.SpanNames
+	if len(spanNames.Rules) == 0 && !spanNames.PathSegments {
+		return nil, nil
+	}
+	if spanNames.OriginalAttribute == "" {
+		return nil, errConfigSpanNames
+	}
+	rules := make([]internal.SpanNameRule, 0, len(spanNames.Rules))
+	for _, rule := range spanNames.Rules {
+		pattern, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("pattern %q: %w", rule.Pattern, errors.Join(errConfigSpanNames, err))
+		}
+		rules = append(rules, internal.SpanNameRule{Pattern: pattern, Replacement: rule.Replacement})
+	}
+	return internal.NewSpanNameNormalizer(rules, spanNames.PathSegments), nil
+}
+
+// validateTableRoutes checks the table names and parses the conditions of the logs and traces table routes.
+func (cfg *Config) validateTableRoutes() error {
+	settings := component.TelemetrySettings{Logger: zap.NewNop()}
+	if len(cfg.Traces.TableRoutes) != 0 && cfg.jaegerSchema() {
+		return errConfigTableRoutes
+	}
+	for _, route := range slices.Concat(cfg.Logs.TableRoutes, cfg.Traces.TableRoutes) {
+		if len(route.Conditions) == 0 || route.TableName == "" {
+			return errConfigTableRoutes
+		}
+	}
+	_, logsErr := parseLogsTableRoutes(settings, cfg.Logs.TableRoutes)
+	_, tracesErr := parseTracesTableRoutes(settings, cfg.Traces.TableRoutes)
+	return errors.Join(logsErr, tracesErr)
+}
+
+// tableRouteConfig returns a copy of the configuration writing into the table of a table route instead of
+// the logs and traces tables, without routing the records any further.
+func (cfg *Config) tableRouteConfig(route TableRouteConfig) *Config {
+	routeCfg := *cfg
+	routeCfg.LogsTableName = route.TableName
+	routeCfg.TracesTableName = route.TableName
+	routeCfg.Logs.ErrorLogs = ErrorLogsConfig{}
+	routeCfg.Logs.TableRoutes = nil
+	routeCfg.Traces.TableRoutes = nil
+	return &routeCfg
+}
+
+// mirrorConfig returns a copy of the configuration writing into the mirror cluster. The mirror doesn't probe
+// its health nor serve a debug page, and connects in the background unless it is required.
+func (cfg *Config) mirrorConfig() *Config {
+	mirrorCfg := *cfg
+	mirrorCfg.Endpoint = ""
+	mirrorCfg.Endpoints = cfg.Mirror.Endpoints
+	mirrorCfg.Failover = FailoverConfig{}
+	mirrorCfg.Username = cfg.Mirror.Username
+	mirrorCfg.Password = cfg.Mirror.Password
+	mirrorCfg.PasswordFile = ""
+	mirrorCfg.Auth = AuthConfig{}
+	mirrorCfg.TLS = cfg.Mirror.TLS
+	mirrorCfg.LazyConnect = cfg.LazyConnect || !cfg.Mirror.Required
+	mirrorCfg.HealthCheckInterval = 0
+	mirrorCfg.DebugEndpoint = ""
+	mirrorCfg.Mirror = MirrorConfig{}
+	return &mirrorCfg
+}
+
+// validateShadow checks the database and sample ratio of the shadow tables, and their configuration.
+func (cfg *Config) validateShadow() error {
+	shadow := cfg.Shadow
+	if shadow.Database == "" || slices.Contains([]string{cfg.Database, cfg.LogsDatabase, cfg.TracesDatabase, cfg.MetricsDatabase}, shadow.Database) ||
+		shadow.SampleRatio < 0 || shadow.SampleRatio > 1 {
+		return errConfigShadow
+	}
+	if err := cfg.shadowConfig().Validate(); err != nil {
+		return fmt.Errorf("shadow: %w", err)
+	}
+	return nil
+}
+
+// shadowConfig returns a copy of the configuration writing into the shadow tables. The shadow tables are written
+// like those of the exporter, without mirror, routing nor health probes, connecting in the background.
+func (cfg *Config) shadowConfig() *Config {
+	shadow := cfg.Shadow
+	shadowCfg := *cfg
+	shadowCfg.Database = shadow.Database
+	shadowCfg.LogsDatabase = ""
+	shadowCfg.TracesDatabase = ""
+	shadowCfg.MetricsDatabase = ""
+	shadowCfg.Routing = RoutingConfig{}
+	shadowCfg.LazyConnect = true
+	shadowCfg.HealthCheckInterval = 0
+	shadowCfg.DebugEndpoint = ""
+	shadowCfg.Mirror = MirrorConfig{}
+	shadowCfg.Shadow = ShadowConfig{}
+	if shadow.IDEncoding != "" {
+		shadowCfg.IDEncoding = shadow.IDEncoding
+	}
+	if shadow.PartitionBy != "" {
+		shadowCfg.PartitionBy = shadow.PartitionBy
+		shadowCfg.Logs.PartitionBy = ""
+		shadowCfg.Traces.PartitionBy = ""
+		shadowCfg.Metrics.PartitionBy = ""
+	}
+	if shadow.TableEngine.Name != "" {
+		shadowCfg.TableEngine = shadow.TableEngine
+		shadowCfg.Logs.TableEngine = TableEngine{}
+		shadowCfg.Traces.TableEngine = TableEngine{}
+		shadowCfg.Metrics.TableEngine = TableEngine{}
+	}
+	if shadow.MetricsSchema != "" {
+		shadowCfg.Metrics.Schema = shadow.MetricsSchema
+	}
+	shadowCfg.ColumnCodecs = withOverrides(cfg.ColumnCodecs, shadow.ColumnCodecs)
+	shadowCfg.LowCardinality = withOverrides(cfg.LowCardinality, shadow.LowCardinality)
+	shadowCfg.TableSettings = withOverrides(cfg.TableSettings, shadow.TableSettings)
+	return &shadowCfg
+}
+
+// withOverrides returns a copy of m with the entries of overrides, m itself if there are none.
+func withOverrides[K comparable, V any](m, overrides map[K]V) map[K]V {
+	if len(overrides) == 0 {
+		return m
+	}
+	merged := make(map[K]V, len(m)+len(overrides))
+	maps.Copy(merged, m)
+	maps.Copy(merged, overrides)
+	return merged
+}
+
+// rawRecordMarshaler returns the marshaler of the RawRecord column, nil if the column is disabled.
+func (cfg *Config) rawRecordMarshaler() plog.Marshaler {
+	switch {
+	case !cfg.Logs.RawRecord.Enabled:
+		return nil
+	case cfg.Logs.RawRecord.Encoding == rawRecordEncodingJSON:
+		return &plog.JSONMarshaler{}
+	default:
+		return &plog.ProtoMarshaler{}
+	}
+}
+
+// validateEndpoint checks the scheme and host of the deprecated endpoint DSN.
+func (cfg *Config) validateEndpoint() error {
+	if cfg.Endpoint == "" {
+		return nil
+	}
+	dsnURL, err := cfg.endpointURL()
+	if err != nil {
+		return err
+	}
+	switch dsnURL.Scheme {
+	case "clickhouse", "tcp", "http", "https":
+	default:
+		return fmt.Errorf("endpoint: %w: scheme %q must be one of clickhouse, tcp, http, https", errConfigInvalidEndpoint, dsnURL.Scheme)
+	}
+	if dsnURL.Hostname() == "" {
+		return fmt.Errorf("endpoint: %w: missing host", errConfigInvalidEndpoint)
+	}
+	return nil
+}
+
+// validateTables checks the table names, TTLs and engines of the enabled signals.
+// Metric table names fall back to their defaults and are never empty.
+func (cfg *Config) validateTables() (err error) {
+	if cfg.Logs.Enabled && cfg.LogsTableName == "" {
+		err = errors.Join(err, fmt.Errorf("logs_table_name: %w", errConfigTableName))
+	}
+	if cfg.Traces.Enabled && cfg.TracesTableName == "" {
+		err = errors.Join(err, fmt.Errorf("traces_table_name: %w", errConfigTableName))
+	}
+
+	ttls := []struct {
+		field  string
+		ttl    time.Duration
+		signal SignalConfig
+		name   string
+		check  bool
+	}{
+		{"ttl", cfg.TTL, cfg.Logs.SignalConfig, "logs", cfg.Logs.Enabled},
+		{"ttl", cfg.TTL, cfg.Traces.SignalConfig, "traces", cfg.Traces.Enabled},
+		{"ttl", cfg.TTL, cfg.Metrics.SignalConfig, "metrics", cfg.Metrics.Enabled},
+		{"traces::events_links::ttl", cfg.Traces.EventsLinks.TTL, cfg.Traces.SignalConfig, "traces", cfg.Traces.Enabled && cfg.separateEventsLinks()},
+		{"metrics::exemplars::ttl", cfg.Metrics.Exemplars.TTL, cfg.Metrics.SignalConfig, "metrics", cfg.Metrics.Enabled && cfg.Metrics.Exemplars.Mode == string(internal.ExemplarsModeSeparateTable)},
+		{"metrics::rollups::ttl", cfg.Metrics.Rollups.TTL, cfg.Metrics.SignalConfig, "metrics", cfg.Metrics.Enabled && cfg.Metrics.Rollups.Enabled},
+	}
+	for _, ttl := range ttls {
+		switch partitionBy := cfg.partitionByFor(ttl.signal); {
+		case ttl.ttl < 0:
+			err = errors.Join(err, fmt.Errorf("%s %s: %w, must not be negative", ttl.field, ttl.ttl, errConfigTTL))
+		case ttl.check && ttl.ttl > 0 && ttl.ttl < partitionBy.Duration():
+			// Tables drop whole parts only, a partition outlives a shorter TTL.
+			err = errors.Join(err, fmt.Errorf("%s %s: %w, shorter than the %s partitions of the %s tables, increase it or use a finer %s",
+				ttl.field, ttl.ttl, errConfigTTL, partitionByName(partitionBy), ttl.name, partitionByField(ttl.signal, ttl.name)))
+		}
+	}
+
+	signals := []struct {
+		name    string
+		signal  SignalConfig
+		enabled bool
+	}{
+		{"logs", cfg.Logs.SignalConfig, cfg.Logs.Enabled},
+		{"traces", cfg.Traces.SignalConfig, cfg.Traces.Enabled},
+		{"metrics", cfg.Metrics.SignalConfig, cfg.Metrics.Enabled},
+	}
+	for _, signal := range signals {
+		if !signal.enabled || cfg.clusterStringFor(signal.signal) == "" {
+			continue
+		}
+		clusterField, engineField, engine := "cluster_name", "table_engine::name", cfg.TableEngine.Name
+		if signal.signal.ClusterName != "" {
+			clusterField = signal.name + "::cluster_name"
+		}
+		if signal.signal.TableEngine.Name != "" {
+			engineField, engine = signal.name+"::table_engine::name", signal.signal.TableEngine.Name
+		}
+		if engine == "" {
+			engine = defaultTableEngineName
+		}
+		if !strings.HasPrefix(engine, "Replicated") && !strings.HasPrefix(engine, "Shared") {
+			err = errors.Join(err, fmt.Errorf("%s is set but %s is %s: %w", clusterField, engineField, engine, errConfigClusterEngine))
+		}
+	}
+	return err
+}
+
+// validTableSettings returns whether the table settings are named like settings and have values that
+// can't end the SETTINGS clause they are written into.
+func validTableSettings(tableSettings map[string]map[string]string) bool {
+	for _, settings := range tableSettings {
+		for name, value := range settings {
+			if !columnNameRegexp.MatchString(name) || value == "" || strings.ContainsAny(value, ",;\n") {
+				return false
+			}
+		}
+	}
+	return true
 }
 
-const (
-	defaultDatabase           = "default"
-	defaultTableEngineName    = "MergeTree"
-	defaultMetricTableName    = "otel_metrics"
-	defaultGaugeSuffix        = "_gauge"
-	defaultSumSuffix          = "_sum"
-	defaultSummarySuffix      = "_summary"
-	defaultHistogramSuffix    = "_histogram"
-	defaultExpHistogramSuffix = "_exponential_histogram"
-)
+// partitionByName returns the configured name of a partition granularity.
+func partitionByName(partitionBy internal.PartitionGranularity) string {
+	if partitionBy == "" {
+		return string(internal.PartitionDaily)
+	}
+	return string(partitionBy)
+}
 
-var (
-	errConfigNoEndpoint      = errors.New("endpoint must be specified")
-	errConfigInvalidEndpoint = errors.New("endpoint must be url format")
-)
+// partitionByField returns the config key setting the partition granularity of a signal.
+func partitionByField(signal SignalConfig, name string) string {
+	if signal.PartitionBy != "" {
+		return name + "::partition_by"
+	}
+	return "partition_by"
+}
 
-// Validate the ClickHouse server configuration.
-func (cfg *Config) Validate() (err error) {
-	if cfg.Endpoint == "" {
-		err = errors.Join(err, errConfigNoEndpoint)
+// validateAuth checks that a single authentication method is configured.
+func (cfg *Config) validateAuth() (err error) {
+	methods := 0
+	for _, set := range []bool{cfg.Username != "", cfg.Auth.APIKeyID != "", cfg.Auth.JWT != nil} {
+		if set {
+			methods++
+		}
 	}
-	dsn, e := cfg.buildDSN()
-	if e != nil {
-		err = errors.Join(err, e)
+	if methods > 1 {
+		err = errors.Join(err, errConfigAuth)
+	}
+	if cfg.PasswordFile != "" && (cfg.Password != "" || cfg.Auth.APIKeyID != "" || cfg.Auth.JWT != nil) {
+		err = errors.Join(err, errConfigPasswordFile)
+	}
+	if jwt := cfg.Auth.JWT; jwt != nil && (jwt.Token == "") == (jwt.TokenFile == "") {
+		err = errors.Join(err, errConfigJWT)
 	}
+	return err
+}
 
-	cfg.buildMetricTableNames()
+// validateIdentifiers checks the database, table and cluster names interpolated into SQL.
+func (cfg *Config) validateIdentifiers() (err error) {
+	identifiers := [][2]string{
+		{"database", cfg.Database},
+		{"logs_database", cfg.LogsDatabase},
+		{"traces_database", cfg.TracesDatabase},
+		{"metrics_database", cfg.MetricsDatabase},
+		{"metrics_table_name", cfg.MetricsTableName},
+		{"metrics_tables::gauge::name", cfg.MetricsTables.Gauge.Name},
+		{"metrics_tables::sum::name", cfg.MetricsTables.Sum.Name},
+		{"metrics_tables::summary::name", cfg.MetricsTables.Summary.Name},
+		{"metrics_tables::histogram::name", cfg.MetricsTables.Histogram.Name},
+		{"metrics_tables::exponential_histogram::name", cfg.MetricsTables.ExponentialHistogram.Name},
+		{"traces::events_links::events_table_name", cfg.Traces.EventsLinks.EventsTableName},
+		{"traces::events_links::links_table_name", cfg.Traces.EventsLinks.LinksTableName},
+		{"metrics::exemplars::table_name", cfg.Metrics.Exemplars.TableName},
+		{"traces::service_graph::table_name", cfg.Traces.ServiceGraph.TableName},
+		{"service_metadata::source_table", cfg.ServiceMetadata.SourceTable},
+		{"service_metadata::dictionary", cfg.ServiceMetadata.Dictionary},
+		{"dimensions::resources_table_name", cfg.Dimensions.ResourcesTableName},
+		{"dimensions::scopes_table_name", cfg.Dimensions.ScopesTableName},
+		{"traces::jaeger::spans_table_name", cfg.Traces.Jaeger.SpansTableName},
+		{"traces::jaeger::index_table_name", cfg.Traces.Jaeger.IndexTableName},
+		{"traces::jaeger::operations_table_name", cfg.Traces.Jaeger.OperationsTableName},
+	}
+	for i, rollup := range cfg.TTLRollups {
+		identifiers = append(identifiers, [2]string{fmt.Sprintf("ttl_rollups::%d::table", i), rollup.Table})
+	}
+	for _, table := range slices.Sorted(maps.Keys(cfg.TableSettings)) {
+		identifiers = append(identifiers, [2]string{"table_settings::" + table, table})
+	}
+	for i, projection := range cfg.Projections {
+		identifiers = append(identifiers,
+			[2]string{fmt.Sprintf("projections::%d::table", i), projection.Table},
+			[2]string{fmt.Sprintf("projections::%d::name", i), projection.Name},
+		)
+	}
+	for _, tenant := range slices.Sorted(maps.Keys(cfg.Routing.Routes)) {
+		identifiers = append(identifiers, [2]string{fmt.Sprintf("routing::routes::%s::database", tenant), cfg.Routing.Routes[tenant].Database})
+	}
+	for _, id := range identifiers {
+		if id[1] != "" && !identifierRegexp.MatchString(id[1]) {
+			err = errors.Join(err, fmt.Errorf("%s %q: %w", id[0], id[1], errConfigIdentifier))
+		}
+	}
 
-	// Validate DSN with clickhouse driver.
-	// Last chance to catch invalid config.
-	if _, e := clickhouse.ParseDSN(dsn); e != nil {
-		err = errors.Join(err, e)
+	// Logs and traces table names may be templates, their placeholders render to valid identifiers.
+	templates := [][2]string{
+		{"logs_table_name", cfg.LogsTableName},
+		{"traces_table_name", cfg.TracesTableName},
+	}
+	for i, route := range cfg.Logs.TableRoutes {
+		templates = append(templates, [2]string{fmt.Sprintf("logs::table_routes::%d::table_name", i), route.TableName})
+	}
+	for i, route := range cfg.Traces.TableRoutes {
+		templates = append(templates, [2]string{fmt.Sprintf("traces::table_routes::%d::table_name", i), route.TableName})
+	}
+	for _, id := range templates {
+		if id[1] != "" && !identifierRegexp.MatchString(internal.TableTemplate(id[1]).Render(time.Time{}, pcommon.NewMap())) {
+			err = errors.Join(err, fmt.Errorf("%s %q: %w", id[0], id[1], errConfigIdentifier))
+		}
 	}
 
+	clusters := [][2]string{
+		{"cluster_name", cfg.ClusterName},
+		{"logs::cluster_name", cfg.Logs.ClusterName},
+		{"traces::cluster_name", cfg.Traces.ClusterName},
+		{"metrics::cluster_name", cfg.Metrics.ClusterName},
+	}
+	for _, id := range clusters {
+		if id[1] != "" && !clusterRegexp.MatchString(id[1]) {
+			err = errors.Join(err, fmt.Errorf("%s %q: %w", id[0], id[1], errConfigIdentifier))
+		}
+	}
 	return err
 }
 
 func (cfg *Config) buildDSN() (string, error) {
-	dsnURL, err := url.Parse(cfg.Endpoint)
+	dsnURL, err := cfg.endpointURL()
 	if err != nil {
-		return "", fmt.Errorf("%w: %s", errConfigInvalidEndpoint, err.Error())
+		return "", err
 	}
 
 	queryParams := dsnURL.Query()
@@ -140,6 +1809,23 @@ func (cfg *Config) buildDSN() (string, error) {
 		queryParams.Set("secure", "true")
 	}
 
+	if cfg.TLS != nil && !cfg.TLS.Insecure {
+		queryParams.Set("secure", "true")
+		if cfg.TLS.InsecureSkipVerify {
+			queryParams.Set("skip_verify", "true")
+		}
+	}
+
+	// Wait for the ON CLUSTER DDL to complete on all the hosts unless specified in DSN.
+	if cfg.onCluster() {
+		if !queryParams.Has("distributed_ddl_task_timeout") {
+			queryParams.Set("distributed_ddl_task_timeout", strconv.FormatInt(int64(cfg.DistributedDDL.TaskTimeout/time.Second), 10))
+		}
+		if !queryParams.Has("distributed_ddl_output_mode") {
+			queryParams.Set("distributed_ddl_output_mode", cfg.DistributedDDL.OutputMode)
+		}
+	}
+
 	// Use async_insert from config if not specified in DSN.
 	if !queryParams.Has("async_insert") {
 		queryParams.Set("async_insert", fmt.Sprintf("%t", cfg.AsyncInsert))
@@ -169,27 +1855,193 @@ func (cfg *Config) buildDSN() (string, error) {
 	if cfg.Username != "" {
 		dsnURL.User = url.UserPassword(cfg.Username, string(cfg.Password))
 	}
+	if cfg.Auth.APIKeyID != "" {
+		dsnURL.User = url.UserPassword(cfg.Auth.APIKeyID, string(cfg.Auth.APIKeySecret))
+	}
 
 	dsnURL.RawQuery = queryParams.Encode()
 
 	return dsnURL.String(), nil
 }
 
+// endpointURL returns the DSN of the structured endpoints, or the parsed deprecated endpoint.
+func (cfg *Config) endpointURL() (*url.URL, error) {
+	if len(cfg.Endpoints) == 0 {
+		dsnURL, err := url.Parse(cfg.Endpoint)
+		if err != nil {
+			// The url error quotes the endpoint, which may hold credentials.
+			var urlErr *url.Error
+			if errors.As(err, &urlErr) {
+				err = urlErr.Err
+			}
+			return nil, fmt.Errorf("endpoint: %w: %s", errConfigInvalidEndpoint, err.Error())
+		}
+		return dsnURL, nil
+	}
+	for i, endpoint := range cfg.Endpoints {
+		host, port, err := net.SplitHostPort(endpoint)
+		if err == nil && host == "" {
+			err = errors.New("missing host")
+		}
+		if err == nil {
+			if n, e := strconv.Atoi(port); e != nil || n <= 0 || n > 65535 {
+				err = fmt.Errorf("invalid port %q", port)
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("endpoints::%d %q: %w: %s", i, endpoint, errConfigInvalidEndpoint, err.Error())
+		}
+	}
+	return &url.URL{Scheme: "clickhouse", Host: strings.Join(cfg.Endpoints, ",")}, nil
+}
+
 func (cfg *Config) buildDB() (*sql.DB, error) {
+	if cfg.Failover.Enabled {
+		return cfg.buildFailoverDB()
+	}
+	connector, err := cfg.buildConnector()
+	if err != nil {
+		return nil, err
+	}
+	return sql.OpenDB(connector), nil
+}
+
+// buildFailoverDB returns a database connecting to a single endpoint at a time.
+// Connections are recycled every probe interval so that a recovered endpoint is used again.
+func (cfg *Config) buildFailoverDB() (*sql.DB, error) {
+	connectors := make([]driver.Connector, len(cfg.Endpoints))
+	for i, endpoint := range cfg.Endpoints {
+		endpointCfg := *cfg
+		endpointCfg.Endpoints = []string{endpoint}
+		connector, err := endpointCfg.buildConnector()
+		if err != nil {
+			return nil, err
+		}
+		connectors[i] = connector
+	}
+	db := sql.OpenDB(newFailoverConnector(connectors, cfg.Failover))
+	db.SetConnMaxLifetime(cfg.Failover.ProbeInterval)
+	return db, nil
+}
+
+func (cfg *Config) buildConnector() (driver.Connector, error) {
+	if cfg.PasswordFile != "" {
+		return newPasswordFileConnector(cfg)
+	}
 	dsn, err := cfg.buildDSN()
 	if err != nil {
 		return nil, err
 	}
 
+	if (cfg.TLS != nil && !cfg.TLS.Insecure) || cfg.Auth.JWT != nil {
+		opts, err := cfg.buildOptions(dsn)
+		if err != nil {
+			return nil, err
+		}
+		return clickhouse.Connector(opts), nil
+	}
+
 	// ClickHouse sql driver will read clickhouse settings from the DSN string.
 	// It also ensures defaults.
 	// See https://github.com/ClickHouse/clickhouse-go/blob/08b27884b899f587eb5c509769cd2bdf74a9e2a1/clickhouse_std.go#L189
-	conn, err := sql.Open(cfg.driverName, dsn)
+	db, err := sql.Open(cfg.driverName, dsn)
+	if err != nil {
+		return nil, err
+	}
+	// The database is only opened to look up the registered driver, it holds no connections yet.
+	defer db.Close()
+	return dsnConnector{dsn: dsn, driver: db.Driver()}, nil
+}
+
+// buildOptions returns the driver options of dsn with the settings a DSN cannot express:
+// the client certificate, CA bundle and TLS versions of the tls settings, and JWT authentication.
+func (cfg *Config) buildOptions(dsn string) (*clickhouse.Options, error) {
+	opts, err := clickhouse.ParseDSN(dsn)
 	if err != nil {
 		return nil, err
 	}
+	if cfg.TLS != nil && !cfg.TLS.Insecure {
+		tlsConfig, err := cfg.TLS.LoadTLSConfig(context.Background())
+		if err != nil {
+			return nil, fmt.Errorf("load tls config: %w", err)
+		}
+		opts.TLS = tlsConfig
+	}
+	if cfg.Auth.JWT != nil {
+		opts.GetJWT = newJWTSource(*cfg.Auth.JWT).getJWT
+	}
+	return opts, nil
+}
+
+// withDatabase returns a copy of the configuration using database instead of the shared one.
+// It is used to connect to and create the schema of a signal stored in its own database.
+func (cfg *Config) withDatabase(database string) *Config {
+	if database == "" || database == cfg.Database {
+		return cfg
+	}
+	signalCfg := *cfg
+	signalCfg.Database = database
+	return &signalCfg
+}
+
+// exporterConfig returns a copy of the configuration owned by an exporter, using database instead of
+// the shared one if set. The copy is adapted to the features of the server on start, see adaptSchema.
+func (cfg *Config) exporterConfig(database string) *Config {
+	exporterCfg := *cfg.withDatabase(database)
+	return &exporterCfg
+}
+
+// route returns the tenant of resource if it has a route.
+func (cfg *Config) route(resource pcommon.Resource) (string, bool) {
+	if cfg.Routing.Attribute == "" {
+		return "", false
+	}
+	value, ok := resource.Attributes().Get(cfg.Routing.Attribute)
+	if !ok {
+		return "", false
+	}
+	tenant := value.AsString()
+	if _, ok := cfg.Routing.Routes[tenant]; !ok {
+		return "", false
+	}
+	return tenant, true
+}
+
+// routeConfig returns a copy of the configuration writing into the database and tables of the route of tenant,
+// routing nothing further.
+func (cfg *Config) routeConfig(tenant string) *Config {
+	route := cfg.Routing.Routes[tenant]
+	routeCfg := *cfg.withDatabase(route.Database)
+	routeCfg.Routing = RoutingConfig{}
+	if route.LogsTableName != "" {
+		routeCfg.LogsTableName = route.LogsTableName
+	}
+	if route.TracesTableName != "" {
+		routeCfg.TracesTableName = route.TracesTableName
+	}
+	return &routeCfg
+}
+
+// tenantColumn returns the name of the tenant column, empty if disabled.
+func (cfg *Config) tenantColumn() string {
+	if !cfg.Tenant.Enabled {
+		return ""
+	}
+	return cfg.Tenant.Column
+}
+
+// tenantPartitionExprFor returns the PARTITION BY expression of the time column of the tables of a signal
+// holding the tenant column, prefixed by the tenant column if partitioned by tenant.
+func (cfg *Config) tenantPartitionExprFor(signal SignalConfig, column string) string {
+	if tenantColumn := cfg.tenantColumn(); tenantColumn != "" && cfg.Tenant.Partition {
+		return internal.TenantPartitionExpr(cfg.partitionByFor(signal), tenantColumn, column)
+	}
+	return internal.PartitionExpr(cfg.partitionByFor(signal), column)
+}
 
-	return conn, nil
+// tenant returns the tenant of the client that sent the data of ctx.
+func (cfg *Config) tenant(ctx context.Context) string {
+	return internal.ClientTenant(ctx, cfg.Tenant.AuthAttribute, cfg.Tenant.MetadataKey)
 }
 
 // shouldCreateSchema returns true if the exporter should run the DDL for creating database/tables.
@@ -197,6 +2049,43 @@ func (cfg *Config) shouldCreateSchema() bool {
 	return cfg.CreateSchema
 }
 
+// queryIDPrefix returns the prefix of the query_id of the exporter queries.
+func (cfg *Config) queryIDPrefix() string {
+	if cfg.QueryIDPrefix != "" {
+		return cfg.QueryIDPrefix
+	}
+	if cfg.collectorInstanceID != "" {
+		return "otelcol-" + cfg.collectorInstanceID
+	}
+	return "otelcol"
+}
+
+// queryContext returns a copy of ctx carrying the query_id prefix of the exporter queries
+// and the settings of its inserts.
+func (cfg *Config) queryContext(ctx context.Context) context.Context {
+	return internal.WithInsertSettings(internal.WithQueryIDPrefix(ctx, cfg.queryIDPrefix()), cfg.InsertSettings)
+}
+
+// tableDDL removes the data skipping indexes from the CREATE TABLE statement ddl unless indexes are created.
+func (o SchemaObjectsConfig) tableDDL(ddl string) string {
+	if o.Indexes {
+		return ddl
+	}
+	return internal.RemoveIndexes(ddl)
+}
+
+// schemaObjectsFor returns the schema objects created for the given signal, none if its schema is not created.
+func (cfg *Config) schemaObjectsFor(signal SignalConfig) SchemaObjectsConfig {
+	createSchema := cfg.shouldCreateSchema()
+	if signal.CreateSchema != nil {
+		createSchema = *signal.CreateSchema
+	}
+	if !createSchema {
+		return SchemaObjectsConfig{}
+	}
+	return cfg.CreateSchemaObjects
+}
+
 func (cfg *Config) buildMetricTableNames() {
 	tableName := defaultMetricTableName
 
@@ -231,22 +2120,453 @@ func (cfg *Config) areMetricTableNamesSet() bool {
 
 // tableEngineString generates the ENGINE string.
 func (cfg *Config) tableEngineString() string {
-	engine := cfg.TableEngine.Name
-	params := cfg.TableEngine.Params
+	return cfg.tableEngineStringFor(SignalConfig{})
+}
+
+// tableEngineStringFor generates the ENGINE string for the tables of a signal.
+func (cfg *Config) tableEngineStringFor(signal SignalConfig) string {
+	engine, params := cfg.tableEngineFor(signal)
+	return fmt.Sprintf("%s(%s)", engine, params)
+}
 
-	if cfg.TableEngine.Name == "" {
-		engine = defaultTableEngineName
-		params = ""
+// replacingTableEngineStringFor generates the ENGINE string of the Replacing variant of the table engine
+// of a signal, keeping the rows with the greatest version. Engines without such a variant are unchanged.
+func (cfg *Config) replacingTableEngineStringFor(signal SignalConfig, version string) string {
+	if engine, ok := cfg.mergeTreeVariantFor(signal, "Replacing", version); ok {
+		return engine
 	}
+	return cfg.tableEngineStringFor(signal)
+}
 
-	return fmt.Sprintf("%s(%s)", engine, params)
+// mergeTreeVariantFor generates the ENGINE string of a variant of the table engine of a signal, e.g.
+// Aggregating, with params appended. It returns false unless the engine is a plain MergeTree,
+// ReplicatedMergeTree or SharedMergeTree.
+func (cfg *Config) mergeTreeVariantFor(signal SignalConfig, variant string, params ...string) (string, bool) {
+	engine, engineParams := cfg.tableEngineFor(signal)
+	for _, family := range []string{"", "Replicated", "Shared"} {
+		if engine == family+"MergeTree" {
+			if engineParams != "" {
+				params = append([]string{engineParams}, params...)
+			}
+			return fmt.Sprintf("%s%sMergeTree(%s)", family, variant, strings.Join(params, ", ")), true
+		}
+	}
+	return "", false
+}
+
+// tableEngineFor returns the name and params of the table engine of a signal.
+func (cfg *Config) tableEngineFor(signal SignalConfig) (engine, params string) {
+	tableEngine := cfg.TableEngine
+	if signal.TableEngine.Name != "" {
+		tableEngine = signal.TableEngine
+	}
+	if tableEngine.Name == "" {
+		return defaultTableEngineName, ""
+	}
+	return tableEngine.Name, tableEngine.Params
+}
+
+// ttlExprFor generates the TTL clause of table, deleting its rows ttl after timeField, 0 meaning no deletion,
+// and rolling them up as configured by ttl_rollups.
+func (cfg *Config) ttlExprFor(table string, ttl time.Duration, timeField string) string {
+	var clauses []string
+	if ttlExpr := generateTTLExpr(ttl, timeField); ttlExpr != "" {
+		clauses = append(clauses, strings.TrimPrefix(ttlExpr, "TTL "))
+	}
+	for _, rollup := range cfg.TTLRollups {
+		if rollup.Table != table {
+			continue
+		}
+		clause := strings.TrimPrefix(generateTTLExpr(rollup.After, timeField), "TTL ")
+		if rollup.Where != "" {
+			clause += " WHERE " + rollup.Where
+		}
+		set := make([]string, 0, len(rollup.Set))
+		for _, column := range slices.Sorted(maps.Keys(rollup.Set)) {
+			set = append(set, fmt.Sprintf("%s = %s", column, rollup.Set[column]))
+		}
+		clauses = append(clauses, fmt.Sprintf("%s GROUP BY %s SET %s", clause, strings.Join(rollup.GroupBy, ", "), strings.Join(set, ", ")))
+	}
+	if len(clauses) == 0 {
+		return ""
+	}
+	return "TTL " + strings.Join(clauses, ", ")
+}
+
+// partitionByFor returns the partition granularity of the tables of a signal.
+func (cfg *Config) partitionByFor(signal SignalConfig) internal.PartitionGranularity {
+	if signal.PartitionBy != "" {
+		return internal.PartitionGranularity(signal.PartitionBy)
+	}
+	return internal.PartitionGranularity(cfg.PartitionBy)
 }
 
 // clusterString generates the ON CLUSTER string. Returns empty string if not set.
 func (cfg *Config) clusterString() string {
-	if cfg.ClusterName == "" {
+	return cfg.clusterStringFor(SignalConfig{})
+}
+
+// onCluster returns whether the tables of any signal are created on a cluster.
+func (cfg *Config) onCluster() bool {
+	return cfg.ClusterName != "" || cfg.Logs.ClusterName != "" || cfg.Traces.ClusterName != "" || cfg.Metrics.ClusterName != ""
+}
+
+// clusterStringFor generates the ON CLUSTER string for the tables of a signal. Returns empty string if not set.
+func (cfg *Config) clusterStringFor(signal SignalConfig) string {
+	clusterName := cfg.ClusterName
+	if signal.ClusterName != "" {
+		clusterName = signal.ClusterName
+	}
+	if clusterName == "" {
+		return ""
+	}
+
+	return fmt.Sprintf("ON CLUSTER %s", internal.QuoteIdentifier(clusterName))
+}
+
+// databaseEngineString generates the ENGINE clause of the database named database. Returns empty string if
+// not set.
+func (cfg *Config) databaseEngineString(database string) string {
+	engine := cfg.DatabaseEngine
+	if engine.Name != databaseEngineReplicated {
+		if engine.Name == "" {
+			return ""
+		}
+		return "ENGINE = " + engine.Name
+	}
+	zooPath, shardName, replicaName := engine.ZooPath, engine.ShardName, engine.ReplicaName
+	if zooPath == "" {
+		zooPath = defaultDatabaseZooPath
+	}
+	if shardName == "" {
+		shardName = defaultDatabaseShardName
+	}
+	if replicaName == "" {
+		replicaName = defaultDatabaseReplicaName
+	}
+	return fmt.Sprintf("ENGINE = %s(%s, %s, %s)", databaseEngineReplicated,
+		internal.QuoteString(strings.ReplaceAll(zooPath, "{database}", database)),
+		internal.QuoteString(shardName),
+		internal.QuoteString(replicaName))
+}
+
+// queueSettingsFor returns the sending queue settings of a signal, blocking on overflow as its queue full policy requires.
+// With drop_oldest the queue blocks the buffer in front of it, not the receivers.
+func (cfg *Config) queueSettingsFor(signal SignalConfig) exporterhelper.QueueBatchConfig {
+	queueSettings := cfg.QueueSettings
+	switch signal.QueueFullPolicy {
+	case queueFullPolicyBlock, queueFullPolicyDropOldest:
+		queueSettings.BlockOnOverflow = true
+	case queueFullPolicyDropNewest:
+		queueSettings.BlockOnOverflow = false
+	}
+	return queueSettings
+}
+
+// separateEventsLinks returns true if span events and links are written to their own tables.
+func (cfg *Config) separateEventsLinks() bool {
+	return cfg.Traces.EventsLinks.Mode == eventsLinksModeSeparateTables
+}
+
+func (cfg *Config) traceIDTsTableName() string {
+	return cfg.TracesTableName + defaultTraceIDTsSuffix
+}
+
+func (cfg *Config) logsTraceIDTsTableName() string {
+	return cfg.LogsTableName + defaultTraceIDTsSuffix
+}
+
+func (cfg *Config) eventsTableName() string {
+	if cfg.Traces.EventsLinks.EventsTableName != "" {
+		return cfg.Traces.EventsLinks.EventsTableName
+	}
+	return cfg.TracesTableName + defaultEventsSuffix
+}
+
+func (cfg *Config) linksTableName() string {
+	if cfg.Traces.EventsLinks.LinksTableName != "" {
+		return cfg.Traces.EventsLinks.LinksTableName
+	}
+	return cfg.TracesTableName + defaultLinksSuffix
+}
+
+func (cfg *Config) errorLogsTableName() string {
+	if cfg.Logs.ErrorLogs.TableName != "" {
+		return cfg.Logs.ErrorLogs.TableName
+	}
+	return cfg.LogsTableName + defaultErrorLogsSuffix
+}
+
+func (cfg *Config) errorLogsTTL() time.Duration {
+	if cfg.Logs.ErrorLogs.TTL > 0 {
+		return cfg.Logs.ErrorLogs.TTL
+	}
+	return cfg.TTL
+}
+
+// severityNumbers are the lowest severity numbers of the severity names.
+var severityNumbers = map[string]plog.SeverityNumber{
+	"TRACE": plog.SeverityNumberTrace,
+	"DEBUG": plog.SeverityNumberDebug,
+	"INFO":  plog.SeverityNumberInfo,
+	"WARN":  plog.SeverityNumberWarn,
+	"ERROR": plog.SeverityNumberError,
+	"FATAL": plog.SeverityNumberFatal,
+}
+
+// parseSeverity returns the severity number of a severity name such as ERROR or WARN2, case insensitive.
+func parseSeverity(name string) (plog.SeverityNumber, bool) {
+	name = strings.ToUpper(name)
+	var step plog.SeverityNumber
+	if n := len(name); n > 1 && name[n-1] >= '2' && name[n-1] <= '4' {
+		step = plog.SeverityNumber(name[n-1] - '1')
+		name = name[:n-1]
+	}
+	severity, ok := severityNumbers[name]
+	return severity + step, ok
+}
+
+func (cfg *Config) eventsLinksTTL() time.Duration {
+	if cfg.Traces.EventsLinks.TTL > 0 {
+		return cfg.Traces.EventsLinks.TTL
+	}
+	return cfg.TTL
+}
+
+func (cfg *Config) serviceEdgesTableName() string {
+	if cfg.Traces.ServiceGraph.TableName != "" {
+		return cfg.Traces.ServiceGraph.TableName
+	}
+	return defaultServiceEdgesTable
+}
+
+func (cfg *Config) resourcesTableName() string {
+	if cfg.Dimensions.ResourcesTableName != "" {
+		return cfg.Dimensions.ResourcesTableName
+	}
+	return defaultResourcesTable
+}
+
+func (cfg *Config) scopesTableName() string {
+	if cfg.Dimensions.ScopesTableName != "" {
+		return cfg.Dimensions.ScopesTableName
+	}
+	return defaultScopesTable
+}
+
+func (cfg *Config) serviceMetadataTableName() string {
+	if cfg.ServiceMetadata.SourceTable != "" {
+		return cfg.ServiceMetadata.SourceTable
+	}
+	return defaultServiceMetadata
+}
+
+func (cfg *Config) serviceMetadataDictName() string {
+	if cfg.ServiceMetadata.Dictionary != "" {
+		return cfg.ServiceMetadata.Dictionary
+	}
+	return defaultServiceMetadataDict
+}
+
+func (cfg *Config) serviceGraphTTL() time.Duration {
+	if cfg.Traces.ServiceGraph.TTL > 0 {
+		return cfg.Traces.ServiceGraph.TTL
+	}
+	return cfg.TTL
+}
+
+func (cfg *Config) jaegerSchema() bool {
+	return cfg.Traces.Schema == tracesSchemaJaeger
+}
+
+func (cfg *Config) jaegerSpansTableName() string {
+	if cfg.Traces.Jaeger.SpansTableName != "" {
+		return cfg.Traces.Jaeger.SpansTableName
+	}
+	return defaultJaegerSpansTable
+}
+
+func (cfg *Config) jaegerIndexTableName() string {
+	if cfg.Traces.Jaeger.IndexTableName != "" {
+		return cfg.Traces.Jaeger.IndexTableName
+	}
+	return defaultJaegerIndexTable
+}
+
+func (cfg *Config) jaegerOperationsTableName() string {
+	if cfg.Traces.Jaeger.OperationsTableName != "" {
+		return cfg.Traces.Jaeger.OperationsTableName
+	}
+	return defaultJaegerOpsTable
+}
+
+func (cfg *Config) traceSummaryTableName() string {
+	return cfg.TracesTableName + defaultTraceSummarySuffix
+}
+
+func (cfg *Config) traceSummaryTTL() time.Duration {
+	if cfg.Traces.TraceSummary.TTL > 0 {
+		return cfg.Traces.TraceSummary.TTL
+	}
+	return cfg.TTL
+}
+
+func (cfg *Config) durationRollupTableName() string {
+	return cfg.TracesTableName + defaultDurationSuffix
+}
+
+func (cfg *Config) durationRollupTTL() time.Duration {
+	if cfg.Traces.DurationRollup.TTL > 0 {
+		return cfg.Traces.DurationRollup.TTL
+	}
+	return cfg.TTL
+}
+
+func (cfg *Config) rootSpansTableName() string {
+	return cfg.TracesTableName + defaultRootSpansSuffix
+}
+
+func (cfg *Config) rootSpansTTL() time.Duration {
+	if cfg.Traces.RootSpans.TTL > 0 {
+		return cfg.Traces.RootSpans.TTL
+	}
+	return cfg.TTL
+}
+
+func (cfg *Config) exemplarsTableName() string {
+	if cfg.Metrics.Exemplars.TableName != "" {
+		return cfg.Metrics.Exemplars.TableName
+	}
+	if len(cfg.MetricsTableName) != 0 {
+		return cfg.MetricsTableName + defaultExemplarsSuffix
+	}
+	return defaultMetricTableName + defaultExemplarsSuffix
+}
+
+func (cfg *Config) metadataTableName() string {
+	if cfg.Metrics.Metadata.TableName != "" {
+		return cfg.Metrics.Metadata.TableName
+	}
+	if len(cfg.MetricsTableName) != 0 {
+		return cfg.MetricsTableName + defaultMetadataSuffix
+	}
+	return defaultMetricTableName + defaultMetadataSuffix
+}
+
+func (cfg *Config) rollupsTTL() time.Duration {
+	if cfg.Metrics.Rollups.TTL > 0 {
+		return cfg.Metrics.Rollups.TTL
+	}
+	return cfg.TTL
+}
+
+// rollups returns the rollup tables of the gauge and sum tables, nil if disabled.
+func (cfg *Config) rollups() []internal.Rollup {
+	if !cfg.Metrics.Rollups.Enabled {
+		return nil
+	}
+	var rollups []internal.Rollup
+	if table := cfg.unifiedMetricsTableName(); table != "" {
+		for _, interval := range cfg.Metrics.Rollups.Intervals {
+			rollup := internal.NewRollup(table, interval)
+			rollup.Filter = "MetricType IN ('Gauge', 'Sum')"
+			rollups = append(rollups, rollup)
+		}
+		return rollups
+	}
+	for _, source := range []string{cfg.MetricsTables.Gauge.Name, cfg.MetricsTables.Sum.Name} {
+		for _, interval := range cfg.Metrics.Rollups.Intervals {
+			rollups = append(rollups, internal.NewRollup(source, interval))
+		}
+	}
+	return rollups
+}
+
+// counterRates returns the counter rates table of the sum table, false if disabled.
+func (cfg *Config) counterRates() (internal.CounterRates, bool) {
+	if !cfg.Metrics.CounterRates.Enabled {
+		return internal.CounterRates{}, false
+	}
+	source := cfg.MetricsTables.Sum.Name
+	if unified := cfg.unifiedMetricsTableName(); unified != "" {
+		source = unified
+	}
+	table := cfg.Metrics.CounterRates.TableName
+	if table == "" {
+		table = source + defaultCounterRatesSuffix
+	}
+	rates := internal.NewCounterRates(source, table)
+	if source != cfg.MetricsTables.Sum.Name {
+		rates.Filter = "MetricType = 'Sum'"
+	}
+	return rates, true
+}
+
+func (cfg *Config) counterRatesTTL() time.Duration {
+	if cfg.Metrics.CounterRates.TTL > 0 {
+		return cfg.Metrics.CounterRates.TTL
+	}
+	return cfg.TTL
+}
+
+// unifiedMetricsTableName returns the table of all datapoints of the unified metrics schema, empty for the per type schema.
+func (cfg *Config) unifiedMetricsTableName() string {
+	if cfg.Metrics.Schema != metricsSchemaUnified {
 		return ""
 	}
+	if len(cfg.MetricsTableName) != 0 {
+		return cfg.MetricsTableName
+	}
+	return defaultMetricTableName
+}
+
+func (cfg *Config) exemplarsTTL() time.Duration {
+	if cfg.Metrics.Exemplars.TTL > 0 {
+		return cfg.Metrics.Exemplars.TTL
+	}
+	return cfg.TTL
+}
+
+// metricsSettings returns the schema options shared by all metric tables.
+func (cfg *Config) metricsSettings() internal.MetricsSettings {
+	return internal.MetricsSettings{
+		ExemplarsMode:            internal.ExemplarsMode(cfg.Metrics.Exemplars.Mode),
+		ExemplarsTableName:       cfg.exemplarsTableName(),
+		ExemplarsTTLExpr:         generateTTLExpr(cfg.exemplarsTTL(), "toDateTime(TimeUnix)"),
+		ExemplarsMaxPerDataPoint: cfg.Metrics.Exemplars.MaxPerDataPoint,
+		PartitionBy:              cfg.partitionByFor(cfg.Metrics.SignalConfig),
+		Columns:                  cfg.columnOptions(),
+		NoIndexes:                !cfg.schemaObjectsFor(cfg.Metrics.SignalConfig).Indexes,
+		NonFinite:                internal.NonFinitePolicy(cfg.Metrics.NonFiniteValues),
+		Staleness:                internal.StalenessPolicy(cfg.Metrics.StaleDataPoints),
+		ExpHistogramMaxBuckets:   cfg.Metrics.ExponentialHistogramMaxBuckets,
+		NullableHistogramStats:   cfg.Metrics.NullableHistogramStats,
+		AttrHash:                 cfg.Metrics.AttributesHash.Enabled,
+		AttrHashOrderBy:          cfg.Metrics.AttributesHash.Enabled && cfg.Metrics.AttributesHash.OrderBy,
+		Attributes:               cfg.attributeFilter(cfg.Metrics.SignalConfig),
+		IDEncoding:               cfg.idEncoding(),
+		UnifiedTable:             cfg.unifiedMetricsTableName(),
+		TenantColumn:             cfg.tenantColumn(),
+		Dimensions:               cfg.Dimensions.Enabled,
+		TenantPartition:          cfg.Tenant.Partition,
+	}
+}
 
-	return fmt.Sprintf("ON CLUSTER %s", cfg.ClusterName)
+// columnOptions returns the user overrides applied to generated column definitions.
+func (cfg *Config) columnOptions() internal.ColumnOptions {
+	return internal.ColumnOptions{
+		Codecs:         cfg.ColumnCodecs,
+		LowCardinality: cfg.LowCardinality,
+		StringJSON:     cfg.jsonFallback,
+		BinaryIDs:      cfg.idEncoding() == internal.IDEncodingBinary,
+		TableSettings:  cfg.TableSettings,
+	}
+}
+
+// idEncoding returns how the trace and span ids are written.
+func (cfg *Config) idEncoding() internal.IDEncoding {
+	if cfg.IDEncoding == "" {
+		return internal.IDEncodingHex
+	}
+	return internal.IDEncoding(cfg.IDEncoding)
 }
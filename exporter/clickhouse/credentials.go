@@ -0,0 +1,107 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package clickhouseexporter // import "github.com/foyer-work/otel-distribution/exporter/clickhouse"
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"go.opentelemetry.io/collector/config/configopaque"
+)
+
+// authExceptionCodes are the clickhouse error codes of a connection rejected for its credentials.
+var authExceptionCodes = map[int32]bool{
+	192: true, // UNKNOWN_USER
+	193: true, // WRONG_PASSWORD
+	516: true, // AUTHENTICATION_FAILED
+}
+
+func isAuthError(err error) bool {
+	var exception *clickhouse.Exception
+	return errors.As(err, &exception) && authExceptionCodes[exception.Code]
+}
+
+// passwordFileConnector connects with the password read from a file. When a connection is
+// rejected for its credentials, the file is read again and the connection retried with the new
+// password, so that rotated passwords are picked up without restarting the collector.
+// Established connections keep their session, only new connections authenticate.
+type passwordFileConnector struct {
+	file  string
+	build func(password configopaque.String) (driver.Connector, error)
+
+	mu        sync.Mutex
+	password  configopaque.String
+	connector driver.Connector
+}
+
+// newPasswordFileConnector returns a connector of cfg using the password of cfg.PasswordFile.
+func newPasswordFileConnector(cfg *Config) (*passwordFileConnector, error) {
+	c := &passwordFileConnector{
+		file: cfg.PasswordFile,
+		build: func(password configopaque.String) (driver.Connector, error) {
+			passwordCfg := *cfg
+			passwordCfg.Password, passwordCfg.PasswordFile = password, ""
+			return passwordCfg.buildConnector()
+		},
+	}
+	if _, err := c.refresh(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *passwordFileConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	c.mu.Lock()
+	connector := c.connector
+	c.mu.Unlock()
+
+	conn, err := connector.Connect(ctx)
+	if err == nil || !isAuthError(err) {
+		return conn, err
+	}
+	changed, refreshErr := c.refresh()
+	if refreshErr != nil {
+		return nil, errors.Join(err, refreshErr)
+	}
+	if !changed {
+		return nil, err
+	}
+	c.mu.Lock()
+	connector = c.connector
+	c.mu.Unlock()
+	return connector.Connect(ctx)
+}
+
+func (c *passwordFileConnector) Driver() driver.Driver {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.connector.Driver()
+}
+
+// refresh reads the password file and rebuilds the connector if the password changed.
+func (c *passwordFileConnector) refresh() (bool, error) {
+	data, err := os.ReadFile(c.file)
+	if err != nil {
+		return false, fmt.Errorf("read password file: %w", err)
+	}
+	password := configopaque.String(strings.TrimSpace(string(data)))
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.connector != nil && password == c.password {
+		return false, nil
+	}
+	connector, err := c.build(password)
+	if err != nil {
+		return false, err
+	}
+	c.password, c.connector = password, connector
+	return true, nil
+}
@@ -0,0 +1,80 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package clickhouseexporter
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/config/configopaque"
+)
+
+// passwordConnector accepts connections only with the current password.
+type passwordConnector struct {
+	driver.Connector
+	password configopaque.String
+	current  *configopaque.String
+}
+
+func (c passwordConnector) Connect(context.Context) (driver.Conn, error) {
+	if c.password != *c.current {
+		return nil, &clickhouse.Exception{Code: 516, Message: "default: Authentication failed"}
+	}
+	return &testClickhouseDriverConn{}, nil
+}
+
+func TestPasswordFileConnector(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "password")
+	require.NoError(t, os.WriteFile(file, []byte("first\n"), 0o600))
+
+	current := configopaque.String("first")
+	var builds []configopaque.String
+	connector := &passwordFileConnector{
+		file: file,
+		build: func(password configopaque.String) (driver.Connector, error) {
+			builds = append(builds, password)
+			return passwordConnector{password: password, current: &current}, nil
+		},
+	}
+	_, err := connector.refresh()
+	require.NoError(t, err)
+
+	_, err = connector.Connect(context.Background())
+	require.NoError(t, err)
+
+	// The password is rotated on the server before the file is updated.
+	current = "second"
+	_, err = connector.Connect(context.Background())
+	require.True(t, isAuthError(err))
+	require.Equal(t, []configopaque.String{"first"}, builds, "unchanged password is not rebuilt")
+
+	require.NoError(t, os.WriteFile(file, []byte("second\n"), 0o600))
+	_, err = connector.Connect(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []configopaque.String{"first", "second"}, builds)
+
+	t.Run("config", func(t *testing.T) {
+		cfg := withDefaultConfig(func(cfg *Config) {
+			cfg.Endpoint = defaultEndpoint
+			cfg.PasswordFile = file
+		})
+		require.NoError(t, cfg.Validate())
+		_, err := newPasswordFileConnector(cfg)
+		require.NoError(t, err)
+
+		cfg.PasswordFile = filepath.Join(t.TempDir(), "missing")
+		_, err = newPasswordFileConnector(cfg)
+		require.ErrorContains(t, err, "read password file")
+
+		cfg.Password = "secret"
+		require.ErrorIs(t, cfg.Validate(), errConfigPasswordFile)
+		require.False(t, isAuthError(errors.New("connection refused")))
+	})
+}
@@ -0,0 +1,160 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package clickhouseexporter // import "github.com/foyer-work/otel-distribution/exporter/clickhouse"
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+	"go.opentelemetry.io/collector/config/configretry"
+	"go.opentelemetry.io/collector/consumer/consumererror"
+	"go.opentelemetry.io/collector/exporter/exporterhelper"
+	"go.uber.org/zap"
+)
+
+const deadLetterFileExt = ".pb"
+
+// deadLetterQueue writes the batches failing after all retries to a directory,
+// removing the oldest batches beyond its limits.
+type deadLetterQueue struct {
+	cfg     DeadLetterQueueConfig
+	signal  string
+	retry   configretry.BackOffConfig
+	timeout time.Duration
+	logger  *zap.Logger
+}
+
+// deadLetterMu serializes the writes and rotation of the directory shared by the signals.
+var deadLetterMu sync.Mutex
+
+// withDeadLetterQueue wraps push to retry it and write the batches still failing to the dead letter queue,
+// returning the exporterhelper options to use instead of the retry and timeout ones of cfg.
+// push is returned unchanged with the options of cfg if the dead letter queue is disabled.
+func withDeadLetterQueue[T any](cfg *Config, signal string, logger *zap.Logger, push func(context.Context, T) error, marshal func(T) ([]byte, error)) (func(context.Context, T) error, []exporterhelper.Option) {
+	if !cfg.DeadLetterQueue.Enabled {
+		return push, []exporterhelper.Option{exporterhelper.WithTimeout(cfg.TimeoutSettings), exporterhelper.WithRetry(cfg.BackOffConfig)}
+	}
+	q := &deadLetterQueue{
+		cfg:     cfg.DeadLetterQueue,
+		signal:  signal,
+		retry:   cfg.BackOffConfig,
+		timeout: cfg.TimeoutSettings.Timeout,
+		logger:  logger,
+	}
+	wrapped := func(ctx context.Context, data T) error {
+		err := q.pushWithRetry(ctx, func(ctx context.Context) error { return push(ctx, data) })
+		if err == nil {
+			return nil
+		}
+		body, e := marshal(data)
+		if e == nil {
+			e = q.write(body)
+		}
+		if e != nil {
+			return errors.Join(err, fmt.Errorf("write to dead letter queue: %w", e))
+		}
+		logger.Warn("batch written to dead letter queue", zap.String("signal", signal), zap.Error(err))
+		return nil
+	}
+	return wrapped, []exporterhelper.Option{
+		exporterhelper.WithTimeout(exporterhelper.TimeoutConfig{}),
+		exporterhelper.WithRetry(configretry.BackOffConfig{Enabled: false}),
+	}
+}
+
+// pushWithRetry runs push with the timeout of each attempt, retrying it as configured.
+func (q *deadLetterQueue) pushWithRetry(ctx context.Context, push func(context.Context) error) error {
+	attempt := func() error {
+		attemptCtx := ctx
+		if q.timeout > 0 {
+			var cancel context.CancelFunc
+			attemptCtx, cancel = context.WithTimeout(ctx, q.timeout)
+			defer cancel()
+		}
+		err := push(attemptCtx)
+		if consumererror.IsPermanent(err) {
+			return backoff.Permanent(err)
+		}
+		return err
+	}
+	if !q.retry.Enabled {
+		return attempt()
+	}
+	b := backoff.NewExponentialBackOff()
+	b.InitialInterval = q.retry.InitialInterval
+	b.RandomizationFactor = q.retry.RandomizationFactor
+	b.Multiplier = q.retry.Multiplier
+	b.MaxInterval = q.retry.MaxInterval
+	b.MaxElapsedTime = q.retry.MaxElapsedTime
+	return backoff.Retry(attempt, backoff.WithContext(b, ctx))
+}
+
+// write stores a batch in a new file and rotates the directory.
+func (q *deadLetterQueue) write(body []byte) error {
+	deadLetterMu.Lock()
+	defer deadLetterMu.Unlock()
+
+	if err := os.MkdirAll(q.cfg.Directory, 0o750); err != nil {
+		return err
+	}
+	name := filepath.Join(q.cfg.Directory, fmt.Sprintf("%020d-%s%s", time.Now().UnixNano(), q.signal, deadLetterFileExt))
+	// Write to a temporary file first so that readers never see partial batches.
+	tmp := name + ".tmp"
+	if err := os.WriteFile(tmp, body, 0o600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, name); err != nil {
+		return err
+	}
+	return q.rotate()
+}
+
+// rotate removes the oldest batches of the signal beyond MaxFiles or MaxSizeMiB, the limits applying to each
+// signal writing to the directory.
+func (q *deadLetterQueue) rotate() error {
+	entries, err := os.ReadDir(q.cfg.Directory)
+	if err != nil {
+		return err
+	}
+	type batch struct {
+		name string
+		size int64
+	}
+	var (
+		batches []batch
+		size    int64
+	)
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), "-"+q.signal+deadLetterFileExt) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		batches = append(batches, batch{name: entry.Name(), size: info.Size()})
+		size += info.Size()
+	}
+	// File names start with the zero padded write time.
+	slices.SortFunc(batches, func(a, b batch) int { return strings.Compare(a.name, b.name) })
+
+	maxSize := q.cfg.MaxSizeMiB << 20
+	for len(batches) > 1 && ((q.cfg.MaxFiles > 0 && len(batches) > q.cfg.MaxFiles) || (maxSize > 0 && size > maxSize)) {
+		if err := os.Remove(filepath.Join(q.cfg.Directory, batches[0].name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		q.logger.Warn("dead letter queue full, removed oldest batch", zap.String("file", batches[0].name))
+		size -= batches[0].size
+		batches = batches[1:]
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package clickhouseexporter
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumererror"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestDeadLetterQueue(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "dlq")
+	cfg := withDefaultConfig(func(cfg *Config) {
+		cfg.Endpoint = defaultEndpoint
+		cfg.BackOffConfig.Enabled = false
+		cfg.DeadLetterQueue = DeadLetterQueueConfig{Enabled: true, Directory: dir, MaxFiles: 2}
+	})
+	require.NoError(t, cfg.Validate())
+
+	var attempts int
+	push, options := withDeadLetterQueue(cfg, "logs", zaptest.NewLogger(t), func(context.Context, plog.Logs) error {
+		attempts++
+		return consumererror.NewPermanent(errors.New("clickhouse is down"))
+	}, (&plog.ProtoMarshaler{}).MarshalLogs)
+	require.Len(t, options, 2)
+
+	for i := 1; i <= 3; i++ {
+		require.NoError(t, push(context.Background(), simpleLogs(i)))
+	}
+	require.Equal(t, 3, attempts)
+
+	files, err := filepath.Glob(filepath.Join(dir, "*-logs.pb"))
+	require.NoError(t, err)
+	require.Len(t, files, 2, "the oldest batch is removed")
+
+	body, err := os.ReadFile(files[1])
+	require.NoError(t, err)
+	ld, err := (&plog.ProtoUnmarshaler{}).UnmarshalLogs(body)
+	require.NoError(t, err)
+	require.Equal(t, 3, ld.LogRecordCount())
+
+	t.Run("per signal", func(t *testing.T) {
+		traces, _ := withDeadLetterQueue(cfg, "traces", zaptest.NewLogger(t), func(context.Context, plog.Logs) error {
+			return consumererror.NewPermanent(errors.New("clickhouse is down"))
+		}, (&plog.ProtoMarshaler{}).MarshalLogs)
+		for i := 1; i <= 3; i++ {
+			require.NoError(t, traces(context.Background(), simpleLogs(i)))
+		}
+
+		logsFiles, err := filepath.Glob(filepath.Join(dir, "*-logs.pb"))
+		require.NoError(t, err)
+		require.Equal(t, files, logsFiles, "the batches of the other signals are kept")
+		tracesFiles, err := filepath.Glob(filepath.Join(dir, "*-traces.pb"))
+		require.NoError(t, err)
+		require.Len(t, tracesFiles, 2)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		cfg.DeadLetterQueue.Enabled = false
+		failing := func(context.Context, plog.Logs) error { return errors.New("clickhouse is down") }
+		push, _ := withDeadLetterQueue(cfg, "logs", zaptest.NewLogger(t), failing, (&plog.ProtoMarshaler{}).MarshalLogs)
+		require.Error(t, push(context.Background(), simpleLogs(1)))
+
+		cfg.DeadLetterQueue = DeadLetterQueueConfig{Enabled: true}
+		require.ErrorIs(t, cfg.Validate(), errConfigDeadLetterQueue)
+	})
+}
@@ -0,0 +1,246 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package clickhouseexporter // import "github.com/foyer-work/otel-distribution/exporter/clickhouse"
+
+import (
+	"context"
+	"errors"
+	"html/template"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/collector/component"
+	"go.uber.org/zap"
+)
+
+// debugPath is the path of the debug page on the debug endpoint.
+const debugPath = "/debug/clickhouse"
+
+// debugStats keeps the insert statistics of an exporter shown on the debug page. A nil debugStats keeps nothing.
+type debugStats struct {
+	id       component.ID
+	signal   string
+	endpoint string
+	// queueSize is the capacity of the sending queue, zero if disabled.
+	queueSize int64
+	// exporting is the number of batches taken from the sending queue and being inserted.
+	exporting atomic.Int64
+
+	mu     sync.Mutex
+	tables map[string]*debugTableStats
+}
+
+// debugTableStats are the insert statistics of a table.
+type debugTableStats struct {
+	Table         string
+	InsertedRows  int64
+	FailedInserts int64
+	LastBatchRows int64
+	LastFlush     time.Time
+	LastError     string
+	LastErrorTime time.Time
+	LastDuration  time.Duration
+}
+
+// newDebugStats returns the statistics of the exporter id of signal, nil if cfg has no debug endpoint.
+func newDebugStats(cfg *Config, id component.ID, signal string) *debugStats {
+	if cfg.DebugEndpoint == "" {
+		return nil
+	}
+	var queueSize int64
+	if cfg.QueueSettings.Enabled {
+		queueSize = cfg.QueueSettings.QueueSize
+	}
+	return &debugStats{
+		id:        id,
+		signal:    signal,
+		endpoint:  cfg.DebugEndpoint,
+		queueSize: queueSize,
+		tables:    map[string]*debugTableStats{},
+	}
+}
+
+// recordInsert updates the statistics of table with the outcome of an insert of rows.
+func (d *debugStats) recordInsert(table string, rows int64, duration time.Duration, err error) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	stats, ok := d.tables[table]
+	if !ok {
+		stats = &debugTableStats{Table: table}
+		d.tables[table] = stats
+	}
+	now := time.Now()
+	stats.LastBatchRows = rows
+	stats.LastDuration = duration
+	if err != nil {
+		stats.FailedInserts++
+		stats.LastError = err.Error()
+		stats.LastErrorTime = now
+		return
+	}
+	stats.InsertedRows += rows
+	stats.LastFlush = now
+}
+
+// snapshot returns a copy of the statistics of each table, sorted by table name.
+func (d *debugStats) snapshot() []debugTableStats {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	tables := make([]debugTableStats, 0, len(d.tables))
+	for _, stats := range d.tables {
+		tables = append(tables, *stats)
+	}
+	slices.SortFunc(tables, func(a, b debugTableStats) int { return strings.Compare(a.Table, b.Table) })
+	return tables
+}
+
+// register shows the statistics on the debug page of the endpoint, serving it if not yet served.
+func (d *debugStats) register(logger *zap.Logger) error {
+	if d == nil {
+		return nil
+	}
+	return debugServers.register(d, logger)
+}
+
+// unregister removes the statistics from the debug page, which is no longer served once empty.
+func (d *debugStats) unregister() {
+	if d == nil {
+		return
+	}
+	debugServers.unregister(d)
+}
+
+// withDebugStats wraps push to count the batches being inserted. push is returned unchanged if d is nil.
+func withDebugStats[T any](d *debugStats, push func(context.Context, T) error) func(context.Context, T) error {
+	if d == nil {
+		return push
+	}
+	return func(ctx context.Context, data T) error {
+		d.exporting.Add(1)
+		defer d.exporting.Add(-1)
+		return push(ctx, data)
+	}
+}
+
+// debugServer serves the debug page of the exporters sharing an endpoint, usually the logs,
+// traces and metrics exporters of a single component.
+type debugServer struct {
+	server *http.Server
+	addr   net.Addr
+
+	mu    sync.Mutex
+	stats []*debugStats
+}
+
+// debugServerRegistry holds the debug servers by endpoint.
+type debugServerRegistry struct {
+	mu      sync.Mutex
+	servers map[string]*debugServer
+}
+
+var debugServers = &debugServerRegistry{servers: map[string]*debugServer{}}
+
+func (r *debugServerRegistry) register(d *debugStats, logger *zap.Logger) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	server, ok := r.servers[d.endpoint]
+	if !ok {
+		listener, err := net.Listen("tcp", d.endpoint)
+		if err != nil {
+			return err
+		}
+		server = &debugServer{addr: listener.Addr()}
+		mux := http.NewServeMux()
+		mux.Handle(debugPath, server)
+		server.server = &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+		go func() {
+			if err := server.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				logger.Warn("debug endpoint stopped", zap.String("endpoint", d.endpoint), zap.Error(err))
+			}
+		}()
+		r.servers[d.endpoint] = server
+	}
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	server.stats = append(server.stats, d)
+	return nil
+}
+
+func (r *debugServerRegistry) unregister(d *debugStats) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	server, ok := r.servers[d.endpoint]
+	if !ok {
+		return
+	}
+	server.mu.Lock()
+	server.stats = slices.DeleteFunc(server.stats, func(stats *debugStats) bool { return stats == d })
+	empty := len(server.stats) == 0
+	server.mu.Unlock()
+	if empty {
+		_ = server.server.Close()
+		delete(r.servers, d.endpoint)
+	}
+}
+
+// debugPage is the data rendered by debugTemplate for an exporter.
+type debugPage struct {
+	ID        string
+	Signal    string
+	Exporting int64
+	QueueSize int64
+	Tables    []debugTableStats
+}
+
+var debugTemplate = template.Must(template.New("debug").Funcs(template.FuncMap{
+	"since": func(t time.Time) string {
+		if t.IsZero() {
+			return "never"
+		}
+		return time.Since(t).Truncate(time.Millisecond).String() + " ago"
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head><title>ClickHouse exporter</title></head>
+<body>
+<h1>ClickHouse exporter</h1>
+{{range .}}
+<h2>{{.ID}} ({{.Signal}})</h2>
+<p>Batches being inserted: {{.Exporting}}{{if .QueueSize}}, sending queue capacity: {{.QueueSize}}{{end}}</p>
+<table border="1">
+<tr><th>Table</th><th>Inserted rows</th><th>Last batch rows</th><th>Last insert duration</th><th>Last flush</th><th>Failed inserts</th><th>Last error</th></tr>
+{{range .Tables}}<tr><td>{{.Table}}</td><td>{{.InsertedRows}}</td><td>{{.LastBatchRows}}</td><td>{{.LastDuration}}</td><td>{{since .LastFlush}}</td><td>{{.FailedInserts}}</td><td>{{if .LastError}}{{.LastError}} ({{since .LastErrorTime}}){{end}}</td></tr>
+{{end}}</table>
+{{end}}
+</body>
+</html>
+`))
+
+// ServeHTTP renders the statistics of the exporters of the server.
+func (s *debugServer) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	s.mu.Lock()
+	pages := make([]debugPage, 0, len(s.stats))
+	for _, stats := range s.stats {
+		pages = append(pages, debugPage{
+			ID:        stats.id.String(),
+			Signal:    stats.signal,
+			Exporting: stats.exporting.Load(),
+			QueueSize: stats.queueSize,
+			Tables:    stats.snapshot(),
+		})
+	}
+	s.mu.Unlock()
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := debugTemplate.Execute(w, pages); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package clickhouseexporter
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/foyer-work/otel-distribution/exporter/clickhouse/internal/metadata"
+)
+
+func TestDebugStats(t *testing.T) {
+	cfg := withDefaultConfig(func(cfg *Config) {
+		cfg.Endpoint = defaultEndpoint
+		cfg.DebugEndpoint = "localhost:0"
+	})
+	require.NoError(t, cfg.Validate())
+	require.Nil(t, newDebugStats(createDefaultConfig().(*Config), component.NewID(metadata.Type), "logs"))
+
+	logs := newDebugStats(cfg, component.NewID(metadata.Type), "logs")
+	traces := newDebugStats(cfg, component.NewID(metadata.Type), "traces")
+	logs.recordInsert("otel_logs", 3, time.Millisecond, nil)
+	logs.recordInsert("otel_logs", 2, time.Millisecond, errors.New("connection reset"))
+	traces.recordInsert("otel_traces", 5, time.Millisecond, nil)
+	require.Equal(t, []debugTableStats{{
+		Table:         "otel_logs",
+		InsertedRows:  3,
+		FailedInserts: 1,
+		LastBatchRows: 2,
+		LastFlush:     logs.snapshot()[0].LastFlush,
+		LastError:     "connection reset",
+		LastErrorTime: logs.snapshot()[0].LastErrorTime,
+		LastDuration:  time.Millisecond,
+	}}, logs.snapshot())
+
+	push := withDebugStats(logs, func(context.Context, int) error {
+		require.Equal(t, int64(1), logs.exporting.Load())
+		return nil
+	})
+	require.NoError(t, push(context.Background(), 0))
+	require.Zero(t, logs.exporting.Load())
+
+	require.NoError(t, logs.register(zaptest.NewLogger(t)))
+	require.NoError(t, traces.register(zaptest.NewLogger(t)), "the exporters share the endpoint")
+	server := debugServers.servers["localhost:0"]
+	resp, err := http.Get("http://" + server.addr.String() + debugPath)
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Contains(t, string(body), "<h2>clickhouse (logs)</h2>")
+	require.Contains(t, string(body), "<td>otel_logs</td><td>3</td><td>2</td>")
+	require.Contains(t, string(body), "connection reset")
+	require.Contains(t, string(body), "<h2>clickhouse (traces)</h2>")
+
+	logs.unregister()
+	require.Contains(t, debugServers.servers, "localhost:0")
+	traces.unregister()
+	require.NotContains(t, debugServers.servers, "localhost:0", "the page is no longer served")
+
+	cfg.DebugEndpoint = "55680"
+	require.ErrorIs(t, cfg.Validate(), errConfigDebugEndpoint)
+}
@@ -0,0 +1,104 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package clickhouseexporter // import "github.com/foyer-work/otel-distribution/exporter/clickhouse"
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+
+	"github.com/foyer-work/otel-distribution/exporter/clickhouse/internal"
+)
+
+// newDimensions returns the writer of the dimension tables of cfg, nil unless enabled.
+func newDimensions(cfg *Config) *internal.Dimensions {
+	if !cfg.Dimensions.Enabled {
+		return nil
+	}
+	return internal.NewDimensions(cfg.resourcesTableName(), cfg.scopesTableName())
+}
+
+// dimensionsTTL returns the ttl of the dimension tables, outliving the rows referencing their resources and
+// scopes by the interval their rows are written again.
+func (cfg *Config) dimensionsTTL() time.Duration {
+	if cfg.TTL <= 0 {
+		return 0
+	}
+	return cfg.TTL + internal.DimensionsRefreshInterval
+}
+
+// createDimensions creates the dimension tables of the database of cfg if enabled.
+func createDimensions(ctx context.Context, cfg *Config, db *sql.DB, signal SignalConfig) error {
+	if !cfg.Dimensions.Enabled || !cfg.schemaObjectsFor(signal).Tables {
+		return nil
+	}
+	ttlExpr := generateTTLExpr(cfg.dimensionsTTL(), "LastSeen")
+	engine := cfg.replacingTableEngineStringFor(signal, "LastSeen")
+	if err := newDimensions(cfg).CreateTables(ctx, cfg.clusterStringFor(signal), engine, ttlExpr, cfg.columnOptions(), db); err != nil {
+		return err
+	}
+	for _, table := range []string{cfg.resourcesTableName(), cfg.scopesTableName()} {
+		if err := updateTTL(ctx, cfg, db, signal, table, ttlExpr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addResource adds the resource with schemaURL to rows, its attributes serialized by attributes.
+func addResource(rows *internal.DimensionRows, attributes *internal.AttributeFilter, schemaURL string, resource pcommon.Resource) {
+	rows.AddResource(schemaURL, attributes.JSON(resource.Attributes()), internal.GetServiceName(resource.Attributes()))
+}
+
+// addScope adds the scope with schemaURL to rows, its attributes serialized by attributes.
+func addScope(rows *internal.DimensionRows, attributes *internal.AttributeFilter, schemaURL string, scope pcommon.InstrumentationScope) {
+	rows.AddScope(schemaURL, scope.Name(), scope.Version(), attributes.JSON(scope.Attributes()))
+}
+
+// logsDimensions returns the resources and scopes of ld, their attributes serialized by attributes.
+func logsDimensions(ld plog.Logs, attributes *internal.AttributeFilter) *internal.DimensionRows {
+	rows := internal.NewDimensionRows()
+	for i := range ld.ResourceLogs().Len() {
+		rl := ld.ResourceLogs().At(i)
+		addResource(rows, attributes, rl.SchemaUrl(), rl.Resource())
+		for j := range rl.ScopeLogs().Len() {
+			sl := rl.ScopeLogs().At(j)
+			addScope(rows, attributes, sl.SchemaUrl(), sl.Scope())
+		}
+	}
+	return rows
+}
+
+// tracesDimensions returns the resources and scopes of td, their attributes serialized by attributes.
+func tracesDimensions(td ptrace.Traces, attributes *internal.AttributeFilter) *internal.DimensionRows {
+	rows := internal.NewDimensionRows()
+	for i := range td.ResourceSpans().Len() {
+		rs := td.ResourceSpans().At(i)
+		addResource(rows, attributes, rs.SchemaUrl(), rs.Resource())
+		for j := range rs.ScopeSpans().Len() {
+			ss := rs.ScopeSpans().At(j)
+			addScope(rows, attributes, ss.SchemaUrl(), ss.Scope())
+		}
+	}
+	return rows
+}
+
+// metricsDimensions returns the resources and scopes of md, their attributes serialized by attributes.
+func metricsDimensions(md pmetric.Metrics, attributes *internal.AttributeFilter) *internal.DimensionRows {
+	rows := internal.NewDimensionRows()
+	for i := range md.ResourceMetrics().Len() {
+		rm := md.ResourceMetrics().At(i)
+		addResource(rows, attributes, rm.SchemaUrl(), rm.Resource())
+		for j := range rm.ScopeMetrics().Len() {
+			sm := rm.ScopeMetrics().At(j)
+			addScope(rows, attributes, sm.SchemaUrl(), sm.Scope())
+		}
+	}
+	return rows
+}
@@ -0,0 +1,84 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package clickhouseexporter
+
+import (
+	"database/sql/driver"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/foyer-work/otel-distribution/exporter/clickhouse/internal"
+)
+
+func TestLogsExporter_dimensions(t *testing.T) {
+	var queries []string
+	inserts := map[string][]driver.Value{}
+	initClickhouseTestServer(t, func(query string, values []driver.Value) error {
+		switch {
+		case strings.HasPrefix(query, "INSERT"):
+			inserts[getQueryFirstLine(query)] = values
+		case !strings.HasPrefix(query, "SELECT"):
+			queries = append(queries, query)
+		}
+		return nil
+	})
+	exporter := newTestLogsExporter(t, defaultEndpoint, withDriverName(t.Name()), func(cfg *Config) {
+		cfg.TTL = 72 * time.Hour
+		cfg.Dimensions.Enabled = true
+		cfg.Tenant = TenantConfig{Enabled: true, Column: "Tenant", MetadataKey: "x-tenant-id"}
+	})
+	require.Len(t, queries, 3)
+	require.Contains(t, queries[0], "\tServiceName LowCardinality(String) COMMENT 'Resource attribute service.name' CODEC(ZSTD(1)),\n")
+	require.Contains(t, queries[0], "\tResourceFingerprint UInt64 COMMENT 'Fingerprint of the resource schema url and attributes' CODEC(ZSTD(1)),\n"+
+		"\tScopeFingerprint UInt64 COMMENT 'Fingerprint of the scope schema url, name, version and attributes' CODEC(ZSTD(1)),\n"+
+		"\tTenant LowCardinality(String)")
+	require.Contains(t, queries[1], "CREATE TABLE IF NOT EXISTS `otel_resources`")
+	require.Contains(t, queries[1], ") ENGINE = ReplacingMergeTree(LastSeen)\nORDER BY ResourceFingerprint\nTTL LastSeen + toIntervalHour(73)\n")
+	require.Contains(t, queries[2], "CREATE TABLE IF NOT EXISTS `otel_scopes`")
+	require.Contains(t, queries[2], "\tScopeAttributes String COMMENT 'InstrumentationScope.attributes' CODEC(ZSTD(1)),\n")
+
+	mustPushLogsData(t, exporter, simpleLogs(1))
+	resources, scopes := inserts["INSERT INTO `otel_resources`"], inserts["INSERT INTO `otel_scopes`"]
+	require.Equal(t, []driver.Value{"https://opentelemetry.io/schemas/1.4.0", "test-service"}, []driver.Value{resources[1], resources[3]})
+	require.Equal(t, []driver.Value{"https://opentelemetry.io/schemas/1.7.0", "io.opentelemetry.contrib.clickhouse", "1.0.0"}, scopes[1:4])
+	resource := internal.ResourceFingerprint(resources[1].(string), resources[2].(string))
+	scope := internal.ScopeFingerprint(scopes[1].(string), scopes[2].(string), scopes[3].(string), scopes[4].(string))
+	require.Equal(t, []driver.Value{resource, scope}, []driver.Value{resources[0], scopes[0]})
+	values := inserts["INSERT INTO `otel_logs`"]
+	require.Equal(t, internal.EmptyAttributes, values[9])
+	require.Equal(t, internal.EmptyAttributes, values[13])
+	require.Equal(t, []driver.Value{resource, scope, ""}, values[19:])
+
+	clear(inserts)
+	mustPushLogsData(t, exporter, simpleLogs(1))
+	require.Len(t, inserts, 1, "the resources and scopes are written hourly")
+}
+
+func TestTracesExporter_dimensions(t *testing.T) {
+	var ddl string
+	inserts := map[string][]driver.Value{}
+	initClickhouseTestServer(t, func(query string, values []driver.Value) error {
+		switch {
+		case strings.HasPrefix(query, "INSERT"):
+			inserts[getQueryFirstLine(query)] = values
+		case strings.Contains(query, "CREATE TABLE IF NOT EXISTS `otel_traces`"):
+			ddl = query
+		}
+		return nil
+	})
+	exporter := newTestTracesExporter(t, defaultEndpoint, withDriverName(t.Name()), func(cfg *Config) {
+		cfg.Dimensions.Enabled = true
+	})
+	require.Contains(t, ddl, "\tServiceName LowCardinality(String) COMMENT 'Resource attribute service.name' CODEC(ZSTD(1)),\n\tResourceFingerprint UInt64")
+
+	mustPushTracesData(t, exporter, simpleTraces(1))
+	require.Contains(t, inserts, "INSERT INTO `otel_resources`")
+	require.Contains(t, inserts, "INSERT INTO `otel_scopes`")
+	resources, scopes, values := inserts["INSERT INTO `otel_resources`"], inserts["INSERT INTO `otel_scopes`"], inserts["INSERT INTO `otel_traces`"]
+	require.Equal(t, internal.EmptyAttributes, values[8])
+	require.Equal(t, []driver.Value{resources[0], scopes[0]}, values[len(values)-2:])
+}
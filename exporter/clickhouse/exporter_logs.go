@@ -6,11 +6,19 @@ package clickhouseexporter // import "github.com/foyer-work/otel-distribution/ex
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	_ "github.com/ClickHouse/clickhouse-go/v2" // For register database driver.
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/ottl"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/ottl/contexts/ottllog"
 	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/pdata/pcommon"
 	"go.opentelemetry.io/collector/pdata/plog"
 	"go.uber.org/zap"
 
@@ -20,48 +28,256 @@ import (
 type logsExporter struct {
 	client    *sql.DB
 	insertSQL string
+	// table is the templated logs table name, tables holds an exporter per rendered name.
+	table  internal.TableTemplate
+	tables internal.OnceMap[*logsExporter]
+	// errorLogs writes the log records at or above minSeverity into the error logs table if enabled.
+	errorLogs   *logsExporter
+	minSeverity plog.SeverityNumber
+	// patterns mines the patterns of the log bodies if enabled, shared with the child exporters.
+	patterns *internal.PatternMiner
+	// routes holds an exporter per routed tenant, writing into the database and tables of its route.
+	routes internal.OnceMap[*logsExporter]
+	// tableRoutes write the log records matching their conditions into their tables, in order.
+	tableRoutes []logsTableRoute
+	// dimensions writes the resources and scopes into the dimension tables if enabled, shared with the error
+	// logs, table route and table exporters.
+	dimensions *internal.Dimensions
 
-	logger *zap.Logger
-	cfg    *Config
+	logger    *zap.Logger
+	telemetry *exporterTelemetry
+	startup   startupRunner
+	health    healthReporter
+	server    serverInfo
+	debug     *debugStats
+	cfg       *Config
 }
 
 func newLogsExporter(logger *zap.Logger, cfg *Config) (*logsExporter, error) {
+	cfg = cfg.exporterConfig(cfg.LogsDatabase)
 	client, err := newClickhouseClient(cfg)
 	if err != nil {
 		return nil, err
 	}
 
-	return &logsExporter{
-		client:    client,
-		insertSQL: renderInsertLogsSQL(cfg),
-		logger:    logger,
-		cfg:       cfg,
-	}, nil
+	var patterns *internal.PatternMiner
+	if cfg.Logs.Patterns.Enabled {
+		patterns = internal.NewPatternMiner(cfg.Logs.Patterns.SimilarityThreshold, cfg.Logs.Patterns.MaxPatterns)
+	}
+	tableRoutes, err := parseLogsTableRoutes(component.TelemetrySettings{Logger: logger}, cfg.Logs.TableRoutes)
+	if err != nil {
+		return nil, err
+	}
+	return buildLogsExporter(logger, cfg, client, patterns, tableRoutes), nil
 }
 
-func (e *logsExporter) start(ctx context.Context, _ component.Host) error {
-	if !e.cfg.shouldCreateSchema() {
-		return nil
+// buildLogsExporter returns the exporter writing into the logs tables of cfg through client, tableRoutes
+// being the conditions of the table routes of cfg.
+func buildLogsExporter(logger *zap.Logger, cfg *Config, client *sql.DB, patterns *internal.PatternMiner, tableRoutes []ottl.ConditionSequence[ottllog.TransformContext]) *logsExporter {
+	if cfg.contribSchema() {
+		return &logsExporter{
+			client:    client,
+			insertSQL: renderInsertContribLogsSQL(cfg),
+			logger:    logger,
+			cfg:       cfg,
+		}
+	}
+	exporter := &logsExporter{
+		client:     client,
+		insertSQL:  renderInsertLogsSQL(cfg),
+		table:      internal.TableTemplate(cfg.rotatedTableName(cfg.LogsTableName)),
+		patterns:   patterns,
+		dimensions: newDimensions(cfg),
+		logger:     logger,
+		cfg:        cfg,
+	}
+	if cfg.Logs.ErrorLogs.Enabled {
+		errorsCfg := *cfg
+		errorsCfg.LogsTableName = cfg.errorLogsTableName()
+		errorsCfg.TTL = cfg.errorLogsTTL()
+		errorsCfg.Logs.ErrorLogs = ErrorLogsConfig{}
+		exporter.errorLogs = &logsExporter{
+			client:     client,
+			insertSQL:  renderInsertLogsSQL(&errorsCfg),
+			table:      internal.TableTemplate(errorsCfg.LogsTableName),
+			patterns:   patterns,
+			dimensions: exporter.dimensions,
+			logger:     logger,
+			cfg:        &errorsCfg,
+		}
+		exporter.minSeverity, _ = parseSeverity(cfg.Logs.ErrorLogs.MinSeverity)
+	}
+	for i, conditions := range tableRoutes {
+		routeExporter := buildLogsExporter(logger, cfg.tableRouteConfig(cfg.Logs.TableRoutes[i]), client, patterns, nil)
+		routeExporter.dimensions = exporter.dimensions
+		exporter.tableRoutes = append(exporter.tableRoutes, logsTableRoute{
+			conditions: conditions,
+			exporter:   routeExporter,
+		})
+	}
+	return exporter
+}
+
+// setTelemetry sets the telemetry of the exporter and of its error logs and table route exporters.
+func (e *logsExporter) setTelemetry(telemetry *exporterTelemetry) {
+	e.telemetry = telemetry
+	if e.errorLogs != nil {
+		e.errorLogs.telemetry = telemetry
+	}
+	for _, route := range e.tableRoutes {
+		route.exporter.telemetry = telemetry
 	}
+}
 
-	if err := createDatabase(ctx, e.cfg); err != nil {
+func (e *logsExporter) start(ctx context.Context, host component.Host) error {
+	if err := e.startup.start(ctx, host, e.logger, e.cfg, func(ctx context.Context) error {
+		return e.setup(ctx, host)
+	}); err != nil {
 		return err
 	}
+	if err := e.debug.register(e.logger); err != nil {
+		return err
+	}
+	e.health.start(host, e.logger, e.cfg.HealthCheckInterval, e.cfg.TimeoutSettings.Timeout, e.client.PingContext)
+	return nil
+}
+
+// setup creates the schema of the exporter.
+func (e *logsExporter) setup(ctx context.Context, host component.Host) error {
+	ctx = e.cfg.queryContext(ctx)
+	e.server.detect(ctx, e.logger, e.client)
+	if err := adaptSchema(ctx, e.logger, e.cfg, e.client, &e.server, e.cfg.Logs.SignalConfig); err != nil {
+		return err
+	}
+	if e.cfg.schemaObjectsFor(e.cfg.Logs.SignalConfig).Database {
+		if err := createDatabase(ctx, e.cfg, e.cfg.clusterStringFor(e.cfg.Logs.SignalConfig)); err != nil {
+			return err
+		}
+	}
+	if err := e.createRoutedTables(ctx, createLogsTable); err != nil {
+		return err
+	}
+	if e.table.IsTemplate() {
+		verifyStart(ctx, host, e.logger, e.cfg, e.client, e.cfg.Logs.SignalConfig, []string{allTables})
+		return e.createRotationTables(ctx)
+	}
+	tables := []string{e.cfg.LogsTableName}
+	if e.errorLogs != nil {
+		tables = append(tables, e.errorLogs.cfg.LogsTableName)
+	}
+	for _, route := range e.tableRoutes {
+		if !route.exporter.table.IsTemplate() {
+			tables = append(tables, route.exporter.cfg.LogsTableName)
+		}
+	}
+	verifyStart(ctx, host, e.logger, e.cfg, e.client, e.cfg.Logs.SignalConfig, tables)
 
 	return createLogsTable(ctx, e.cfg, e.client)
 }
 
 // shutdown will shut down the exporter.
 func (e *logsExporter) shutdown(_ context.Context) error {
+	e.health.stop()
+	e.startup.stop()
+	e.debug.unregister()
+	var err error
+	e.routes.Range(func(_ string, exporter *logsExporter) bool {
+		if client := exporter.client; client != e.client {
+			err = errors.Join(err, client.Close())
+		}
+		return true
+	})
 	if e.client != nil {
-		return e.client.Close()
+		err = errors.Join(err, e.client.Close())
 	}
-	return nil
+	return err
 }
 
 func (e *logsExporter) pushLogsData(ctx context.Context, ld plog.Logs) error {
+	if err := e.startup.ready(); err != nil {
+		return err
+	}
+	ctx, reportSkipped := e.telemetry.skipInvalidRows(ctx, e.cfg, e.logger, "logs")
+	defer reportSkipped()
+	ctx = e.telemetry.observeInserts(ctx, e.logger, &e.server, e.debug)
+	if len(e.cfg.Routing.Routes) != 0 {
+		routes := splitLogsByRoute(e.cfg, ld)
+		for tenant, logs := range routes {
+			if tenant == "" {
+				continue
+			}
+			exporter, err := e.routeExporter(ctx, tenant)
+			if err != nil {
+				return err
+			}
+			if err := exporter.pushLogsData(ctx, logs); err != nil {
+				return err
+			}
+		}
+		logs, ok := routes[""]
+		if !ok {
+			return nil
+		}
+		ld = logs
+	}
+	if len(e.tableRoutes) != 0 {
+		routed := splitLogsByTableRoute(ctx, e.tableRoutes, ld)
+		for i, route := range e.tableRoutes {
+			if logs, ok := routed[strconv.Itoa(i)]; ok {
+				if err := route.exporter.pushLogsData(ctx, logs); err != nil {
+					return err
+				}
+			}
+		}
+		logs, ok := routed[""]
+		if !ok {
+			return nil
+		}
+		ld = logs
+	}
+	if e.errorLogs != nil {
+		routed := splitLogs(ld, func(_ plog.ResourceLogs, _ plog.ScopeLogs, r plog.LogRecord) string {
+			if r.SeverityNumber() >= e.minSeverity {
+				return e.errorLogs.cfg.LogsTableName
+			}
+			return e.cfg.LogsTableName
+		})
+		if errorLogs, ok := routed[e.errorLogs.cfg.LogsTableName]; ok {
+			if err := e.errorLogs.pushLogsData(ctx, errorLogs); err != nil {
+				return err
+			}
+		}
+		logs, ok := routed[e.cfg.LogsTableName]
+		if !ok {
+			return nil
+		}
+		ld = logs
+	}
+	if e.cfg.contribSchema() {
+		return e.pushContribLogs(ctx, ld)
+	}
+	if e.table.IsTemplate() {
+		return e.pushTemplatedLogs(ctx, ld)
+	}
+	// the resources and scopes are written first, the log records referencing them by fingerprint.
+	if e.dimensions != nil {
+		if err := e.dimensions.Write(e.cfg.queryContext(ctx), e.client, logsDimensions(ld, e.cfg.attributeFilter(e.cfg.Logs.SignalConfig))); err != nil {
+			return err
+		}
+	}
+	ctx, observe := internal.ObserveInsert(internal.InsertContext(e.cfg.queryContext(ctx), "insert_logs"), e.cfg.LogsTableName)
 	start := time.Now()
-	err := doWithTx(ctx, e.client, func(tx *sql.Tx) error {
+	var truncatedBodies int64
+	bodyPaths := e.cfg.bodyJSONPaths()
+	rawRecordMarshaler := e.cfg.rawRecordMarshaler()
+	tenantColumn := e.cfg.tenantColumn() != ""
+	tenant := e.cfg.tenant(ctx)
+	var attributes *internal.AttributeFilter
+	ids := e.cfg.idEncoding()
+	err := doWithTx(ctx, e.client, func(ctx context.Context, tx *sql.Tx) error {
+		// the truncations are counted again by each attempt of the insert.
+		truncatedBodies = 0
+		attributes = e.cfg.attributeFilter(e.cfg.Logs.SignalConfig)
 		statement, err := tx.PrepareContext(ctx, e.insertSQL)
 		if err != nil {
 			return fmt.Errorf("PrepareContext:%w", err)
@@ -74,15 +290,27 @@ func (e *logsExporter) pushLogsData(ctx context.Context, ld plog.Logs) error {
 			logs := ld.ResourceLogs().At(i)
 			res := logs.Resource()
 			resURL := logs.SchemaUrl()
-			resAttr := internal.AttributesToJSON(res.Attributes())
+			resAttr := attributes.JSON(res.Attributes())
 			serviceName := internal.GetServiceName(res.Attributes())
+			resDropped := res.DroppedAttributesCount()
+			var resFingerprint uint64
+			if e.dimensions != nil {
+				resFingerprint = internal.ResourceFingerprint(resURL, resAttr)
+				resAttr = internal.EmptyAttributes
+			}
 
 			for j := range logs.ScopeLogs().Len() {
 				rs := logs.ScopeLogs().At(j).LogRecords()
 				scopeURL := logs.ScopeLogs().At(j).SchemaUrl()
 				scopeName := logs.ScopeLogs().At(j).Scope().Name()
 				scopeVersion := logs.ScopeLogs().At(j).Scope().Version()
-				scopeAttr := internal.AttributesToJSON(logs.ScopeLogs().At(j).Scope().Attributes())
+				scopeAttr := attributes.JSON(logs.ScopeLogs().At(j).Scope().Attributes())
+				scopeDropped := logs.ScopeLogs().At(j).Scope().DroppedAttributesCount()
+				var scopeFingerprint uint64
+				if e.dimensions != nil {
+					scopeFingerprint = internal.ScopeFingerprint(scopeURL, scopeName, scopeVersion, scopeAttr)
+					scopeAttr = internal.EmptyAttributes
+				}
 
 				for k := range rs.Len() {
 					r := rs.At(k)
@@ -92,16 +320,27 @@ func (e *logsExporter) pushLogsData(ctx context.Context, ld plog.Logs) error {
 						timestamp = r.ObservedTimestamp()
 					}
 
-					logAttr := internal.AttributesToJSON(r.Attributes())
-					_, err = statement.ExecContext(ctx,
+					rawBody := r.Body().AsString()
+					body, truncated := internal.TruncateUTF8(rawBody, e.cfg.Logs.MaxBodyBytes)
+					logAttr := attributes.JSON(r.Attributes())
+					if truncated {
+						if !internal.RowSkipped(ctx) {
+							truncatedBodies++
+						}
+						attrs := pcommon.NewMap()
+						r.Attributes().CopyTo(attrs)
+						attrs.PutBool(bodyTruncatedAttribute, true)
+						logAttr = attributes.JSON(attrs)
+					}
+					row := []any{
 						timestamp.AsTime(),
-						internal.TraceIDToHexOrEmptyString(r.TraceID()),
-						internal.SpanIDToHexOrEmptyString(r.SpanID()),
+						ids.TraceID(r.TraceID()),
+						ids.SpanID(r.SpanID()),
 						uint32(r.Flags()),
 						r.SeverityText(),
 						int32(r.SeverityNumber()),
 						serviceName,
-						r.Body().AsString(),
+						body,
 						resURL,
 						resAttr,
 						scopeURL,
@@ -109,7 +348,32 @@ func (e *logsExporter) pushLogsData(ctx context.Context, ld plog.Logs) error {
 						scopeVersion,
 						scopeAttr,
 						logAttr,
-					)
+						r.ObservedTimestamp().AsTime(),
+						resDropped,
+						scopeDropped,
+						r.DroppedAttributesCount(),
+					}
+					if rawRecordMarshaler != nil {
+						rawRecord, err := marshalLogRecord(rawRecordMarshaler, logs, logs.ScopeLogs().At(j), r)
+						if err != nil {
+							return fmt.Errorf("marshal raw log record: %w", err)
+						}
+						row = append(row, string(rawRecord))
+					}
+					if e.patterns != nil {
+						patternID, pattern := e.patterns.Pattern(body)
+						row = append(row, patternID, pattern)
+					}
+					if len(bodyPaths) > 0 {
+						row = append(row, internal.ExtractJSONPaths(rawBody, bodyPaths)...)
+					}
+					if e.dimensions != nil {
+						row = append(row, resFingerprint, scopeFingerprint)
+					}
+					if tenantColumn {
+						row = append(row, tenant)
+					}
+					_, err = internal.ExecRow(ctx, statement, row...)
 					if err != nil {
 						return fmt.Errorf("ExecContext:%w", err)
 					}
@@ -118,40 +382,264 @@ func (e *logsExporter) pushLogsData(ctx context.Context, ld plog.Logs) error {
 		}
 		return nil
 	})
+	observe(err)
+	if err == nil {
+		e.telemetry.recordTruncatedBodies(ctx, e.cfg.LogsTableName, truncatedBodies)
+		e.telemetry.recordTruncatedAttributeValues(ctx, "logs", attributes)
+	}
 	duration := time.Since(start)
 	e.logger.Debug("insert logs", zap.Int("records", ld.LogRecordCount()),
 		zap.String("cost", duration.String()))
 	return err
 }
 
+// rawRecordColumn is the optional column holding the log records as received.
+const rawRecordColumn = "RawRecord"
+
+// bodyTruncatedAttribute is the log attribute set on the records whose body was truncated to max_body_bytes.
+const bodyTruncatedAttribute = "log.body.truncated"
+
+// marshalLogRecord serializes r, with the resource and scope of rl and sl, as logs with marshaler.
+func marshalLogRecord(marshaler plog.Marshaler, rl plog.ResourceLogs, sl plog.ScopeLogs, r plog.LogRecord) ([]byte, error) {
+	ld := plog.NewLogs()
+	resourceLogs := ld.ResourceLogs().AppendEmpty()
+	rl.Resource().CopyTo(resourceLogs.Resource())
+	resourceLogs.SetSchemaUrl(rl.SchemaUrl())
+	scopeLogs := resourceLogs.ScopeLogs().AppendEmpty()
+	sl.Scope().CopyTo(scopeLogs.Scope())
+	scopeLogs.SetSchemaUrl(sl.SchemaUrl())
+	r.CopyTo(scopeLogs.LogRecords().AppendEmpty())
+	return marshaler.MarshalLogs(ld)
+}
+
+// pushTemplatedLogs writes each log record into the table its timestamp and resource render the template to.
+// Tables are created when first written to.
+func (e *logsExporter) pushTemplatedLogs(ctx context.Context, ld plog.Logs) error {
+	for table, logs := range splitLogsByTable(e.table, ld) {
+		exporter, err := e.tableExporter(ctx, table)
+		if err != nil {
+			return err
+		}
+		if err := exporter.pushLogsData(ctx, logs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tableExporter returns the exporter writing into table, creating the table on first use unless it exists.
+// Concurrent first uses of a table wait for a single creation.
+func (e *logsExporter) tableExporter(ctx context.Context, table string) (*logsExporter, error) {
+	return e.tables.Get(ctx, table, func() (*logsExporter, error) {
+		cfg := *e.cfg
+		cfg.LogsTableName = table
+		if cfg.Rotation.Enabled {
+			// the tables of past periods are detached or archived whole rather than their rows deleted.
+			cfg.TTL = 0
+		}
+		if err := createMissingLogsTable(e.cfg.queryContext(ctx), &cfg, e.client); err != nil {
+			return nil, err
+		}
+		return &logsExporter{
+			client:     e.client,
+			insertSQL:  renderInsertLogsSQL(&cfg),
+			patterns:   e.patterns,
+			dimensions: e.dimensions,
+			logger:     e.logger,
+			telemetry:  e.telemetry,
+			cfg:        &cfg,
+		}, nil
+	})
+}
+
+// routeExporter returns the exporter writing the logs of tenant into the database and tables of its route,
+// creating those missing on first use. Concurrent first uses of a route wait for a single creation.
+// Routes to another database connect to it with their own client.
+func (e *logsExporter) routeExporter(ctx context.Context, tenant string) (*logsExporter, error) {
+	return e.routes.Get(ctx, tenant, func() (*logsExporter, error) {
+		cfg := e.cfg.routeConfig(tenant)
+		client := e.client
+		if cfg.Database != e.cfg.Database {
+			var err error
+			if client, err = newClickhouseClient(cfg); err != nil {
+				return nil, err
+			}
+		}
+		exporter := buildLogsExporter(e.logger, cfg, client, e.patterns, e.tableRouteConditions())
+		exporter.setTelemetry(e.telemetry)
+		if err := exporter.createRouteSchema(e.cfg.queryContext(ctx)); err != nil {
+			closeRouteClient(e.client, client)
+			return nil, err
+		}
+		return exporter, nil
+	})
+}
+
+// createRouteSchema creates the database and the missing tables of a route exporter.
+func (e *logsExporter) createRouteSchema(ctx context.Context) error {
+	if e.cfg.schemaObjectsFor(e.cfg.Logs.SignalConfig).Database {
+		if err := createDatabase(ctx, e.cfg, e.cfg.clusterStringFor(e.cfg.Logs.SignalConfig)); err != nil {
+			return err
+		}
+	}
+	if err := e.createRoutedTables(ctx, createMissingLogsTable); err != nil {
+		return err
+	}
+	if e.table.IsTemplate() {
+		return e.createRotationTables(ctx)
+	}
+	return createMissingLogsTable(ctx, e.cfg, e.client)
+}
+
+// createRoutedTables creates the error logs table and the tables of the table routes with create, except
+// templated ones created when first written to.
+func (e *logsExporter) createRoutedTables(ctx context.Context, create func(context.Context, *Config, *sql.DB) error) error {
+	if e.errorLogs != nil {
+		if err := create(ctx, e.errorLogs.cfg, e.client); err != nil {
+			return err
+		}
+	}
+	for _, route := range e.tableRoutes {
+		if route.exporter.table.IsTemplate() {
+			continue
+		}
+		if err := create(ctx, route.exporter.cfg, e.client); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createRotationTables creates the tables of the current period of the exporter and its table routes, and the
+// Merge tables reading the tables of all periods, if the tables are rotated.
+func (e *logsExporter) createRotationTables(ctx context.Context) error {
+	if !e.cfg.Rotation.Enabled {
+		return nil
+	}
+	exporters := []*logsExporter{e}
+	for _, route := range e.tableRoutes {
+		exporters = append(exporters, route.exporter)
+	}
+	for _, exporter := range exporters {
+		current, err := exporter.tableExporter(ctx, exporter.table.Render(time.Now(), pcommon.NewMap()))
+		if err != nil {
+			return err
+		}
+		if err := createRotationMergeTable(ctx, e.cfg, e.client, e.cfg.Logs.SignalConfig, exporter.cfg.LogsTableName, current.cfg.LogsTableName); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tableRouteConditions returns the conditions of the table routes of the exporter.
+func (e *logsExporter) tableRouteConditions() []ottl.ConditionSequence[ottllog.TransformContext] {
+	var conditions []ottl.ConditionSequence[ottllog.TransformContext]
+	for _, route := range e.tableRoutes {
+		conditions = append(conditions, route.conditions)
+	}
+	return conditions
+}
+
+// splitLogsByRoute groups the resource logs of ld by the tenant they are routed to, "" for those without a route.
+func splitLogsByRoute(cfg *Config, ld plog.Logs) map[string]plog.Logs {
+	routes := map[string]plog.Logs{}
+	for i := range ld.ResourceLogs().Len() {
+		rl := ld.ResourceLogs().At(i)
+		tenant, _ := cfg.route(rl.Resource())
+		logs, ok := routes[tenant]
+		if !ok {
+			logs = plog.NewLogs()
+			routes[tenant] = logs
+		}
+		rl.CopyTo(logs.ResourceLogs().AppendEmpty())
+	}
+	return routes
+}
+
+// splitLogsByTable groups the log records of ld by the table name they render table to.
+func splitLogsByTable(table internal.TableTemplate, ld plog.Logs) map[string]plog.Logs {
+	return splitLogs(ld, func(rl plog.ResourceLogs, _ plog.ScopeLogs, r plog.LogRecord) string {
+		timestamp := r.Timestamp()
+		if timestamp == 0 {
+			timestamp = r.ObservedTimestamp()
+		}
+		return table.Render(timestamp.AsTime(), rl.Resource().Attributes())
+	})
+}
+
+// splitLogs groups the log records of ld by the name key returns for them, their resource and scope logs.
+func splitLogs(ld plog.Logs, key func(rl plog.ResourceLogs, sl plog.ScopeLogs, r plog.LogRecord) string) map[string]plog.Logs {
+	tables := map[string]plog.Logs{}
+	for i := range ld.ResourceLogs().Len() {
+		rl := ld.ResourceLogs().At(i)
+		resources := map[string]plog.ResourceLogs{}
+		for j := range rl.ScopeLogs().Len() {
+			sl := rl.ScopeLogs().At(j)
+			scopes := map[string]plog.ScopeLogs{}
+			for k := range sl.LogRecords().Len() {
+				r := sl.LogRecords().At(k)
+				name := key(rl, sl, r)
+
+				scope, ok := scopes[name]
+				if !ok {
+					resource, ok := resources[name]
+					if !ok {
+						logs, ok := tables[name]
+						if !ok {
+							logs = plog.NewLogs()
+							tables[name] = logs
+						}
+						resource = logs.ResourceLogs().AppendEmpty()
+						rl.Resource().CopyTo(resource.Resource())
+						resource.SetSchemaUrl(rl.SchemaUrl())
+						resources[name] = resource
+					}
+					scope = resource.ScopeLogs().AppendEmpty()
+					sl.Scope().CopyTo(scope.Scope())
+					scope.SetSchemaUrl(sl.SchemaUrl())
+					scopes[name] = scope
+				}
+				r.CopyTo(scope.LogRecords().AppendEmpty())
+			}
+		}
+	}
+	return tables
+}
+
 const (
 	// language=ClickHouse SQL
 	createLogsTableSQL = `
 CREATE TABLE IF NOT EXISTS %s %s (
 	Timestamp DateTime64(9) CODEC(Delta(8), ZSTD(1)),
 	TimestampTime DateTime DEFAULT toDateTime(Timestamp),
+	ObservedTimestamp DateTime64(9) CODEC(Delta(8), ZSTD(1)),
 	TraceId String CODEC(ZSTD(1)),
 	SpanId String CODEC(ZSTD(1)),
 	TraceFlags UInt8,
+	IsSampled Bool MATERIALIZED bitTest(TraceFlags, 0),
 	SeverityText LowCardinality(String) CODEC(ZSTD(1)),
 	SeverityNumber UInt8,
 	ServiceName LowCardinality(String) CODEC(ZSTD(1)),
 	Body String CODEC(ZSTD(1)),
 	ResourceSchemaUrl LowCardinality(String) CODEC(ZSTD(1)),
 	ResourceAttributes JSON,
+	ResourceDroppedAttrCount UInt32 CODEC(ZSTD(1)),
 	ScopeSchemaUrl LowCardinality(String) CODEC(ZSTD(1)),
 	ScopeName String CODEC(ZSTD(1)),
 	ScopeVersion LowCardinality(String) CODEC(ZSTD(1)),
 	ScopeAttributes JSON,
+	ScopeDroppedAttrCount UInt32 CODEC(ZSTD(1)),
 	LogAttributes JSON,
-
+	LogDroppedAttrCount UInt32 CODEC(ZSTD(1)),
+%s
 	INDEX idx_trace_id TraceId TYPE bloom_filter(0.001) GRANULARITY 1,
 
 
 
 	INDEX idx_body Body TYPE tokenbf_v1(32768, 3, 0) GRANULARITY 8
 ) ENGINE = %s
-PARTITION BY toDate(TimestampTime)
+PARTITION BY %s
 PRIMARY KEY (ServiceName, TimestampTime)
 ORDER BY (ServiceName, TimestampTime, Timestamp)
 %s
@@ -173,7 +661,11 @@ SETTINGS index_granularity = 8192, ttl_only_drop_parts = 1;
                         ScopeName,
                         ScopeVersion,
                         ScopeAttributes,
-                        LogAttributes
+                        LogAttributes,
+                        ObservedTimestamp,
+                        ResourceDroppedAttrCount,
+                        ScopeDroppedAttrCount,
+                        LogDroppedAttrCount%s
                         ) VALUES (
                                   ?,
                                   ?,
@@ -189,10 +681,94 @@ SETTINGS index_granularity = 8192, ttl_only_drop_parts = 1;
                                   ?,
                                   ?,
                                   ?,
-                                  ?
+                                  ?,
+                                  ?,
+                                  ?,
+                                  ?,
+                                  ?%s
                                   )`
+	// language=ClickHouse SQL
+	addProjectionSQL = `ALTER TABLE %s %s ADD PROJECTION IF NOT EXISTS %s (%s)`
+	// language=ClickHouse SQL
+	materializeProjectionSQL = `ALTER TABLE %s %s MATERIALIZE PROJECTION %s`
+	// language=ClickHouse SQL
+	selectTableEngineSQL = `SELECT engine_full FROM system.tables WHERE database = ? AND name = ?`
+	// language=ClickHouse SQL
+	modifyTTLSQL = `ALTER TABLE %s %s MODIFY %s`
+	// language=ClickHouse SQL
+	removeTTLSQL = `ALTER TABLE %s %s REMOVE TTL`
+	// language=ClickHouse SQL
+	selectTableExistsSQL = `SELECT 1 FROM system.tables WHERE database = ? AND name = ?`
 )
 
+const (
+	// language=ClickHouse SQL
+	createLogsTraceIDTsTableSQL = `
+CREATE TABLE IF NOT EXISTS %s %s (
+	TraceId String CODEC(ZSTD(1)),
+	ServiceName LowCardinality(String) CODEC(ZSTD(1)),
+	Start DateTime CODEC(Delta, ZSTD(1)),
+	End DateTime CODEC(Delta, ZSTD(1)),
+	INDEX idx_trace_id TraceId TYPE bloom_filter(0.01) GRANULARITY 1
+) ENGINE = %s
+PARTITION BY %s
+ORDER BY (TraceId, ServiceName, Start)
+%s
+SETTINGS index_granularity=8192, ttl_only_drop_parts = 1;
+`
+	// language=ClickHouse SQL
+	createLogsTraceIDTsMaterializedViewSQL = `
+CREATE MATERIALIZED VIEW IF NOT EXISTS %s %s
+TO %s.%s
+AS SELECT
+	TraceId,
+	ServiceName,
+	min(TimestampTime) as Start,
+	max(TimestampTime) as End
+FROM
+%s.%s
+WHERE notEmpty(TraceId)
+GROUP BY TraceId, ServiceName;
+`
+)
+
+// logsColumnComments describes the columns of the logs table.
+var logsColumnComments = map[string]string{
+	"Timestamp":                "LogRecord.time_unix_nano, or observed_time_unix_nano if unset",
+	"TimestampTime":            "Timestamp truncated to seconds",
+	"ObservedTimestamp":        "LogRecord.observed_time_unix_nano",
+	"TraceId":                  "LogRecord.trace_id as hex",
+	"SpanId":                   "LogRecord.span_id as hex",
+	"TraceFlags":               "LogRecord.flags",
+	"IsSampled":                "LogRecord.flags has the W3C trace context sampled flag",
+	"SeverityText":             "LogRecord.severity_text",
+	"SeverityNumber":           "LogRecord.severity_number",
+	"ServiceName":              "Resource attribute service.name",
+	"Body":                     "LogRecord.body",
+	"ResourceSchemaUrl":        "ResourceLogs.schema_url",
+	"ResourceAttributes":       "Resource.attributes",
+	"ScopeSchemaUrl":           "ScopeLogs.schema_url",
+	"ScopeName":                "InstrumentationScope.name",
+	"ScopeVersion":             "InstrumentationScope.version",
+	"ScopeAttributes":          "InstrumentationScope.attributes",
+	"LogAttributes":            "LogRecord.attributes",
+	"ResourceDroppedAttrCount": "Resource.dropped_attributes_count",
+	"ScopeDroppedAttrCount":    "InstrumentationScope.dropped_attributes_count",
+	"LogDroppedAttrCount":      "LogRecord.dropped_attributes_count",
+	"PatternId":                "64-bit FNV-1a hash of Pattern",
+	"Pattern":                  "Pattern of Body mined with the Drain algorithm, its variable tokens replaced by <*>",
+	rawRecordColumn:            "LogRecord with its Resource and InstrumentationScope as received, serialized as an OTLP ExportLogsServiceRequest",
+}
+
+// logsTraceIDTsColumnComments describes the columns of the trace id timestamp lookup table of the logs table.
+// Start and End bound the TimestampTime of the log records of the trace and service.
+var logsTraceIDTsColumnComments = map[string]string{
+	"TraceId":     "LogRecord.trace_id as hex",
+	"ServiceName": "Resource attribute service.name",
+	"Start":       "Earliest TimestampTime of the log records of the trace",
+	"End":         "Latest TimestampTime of the log records of the trace",
+}
+
 // newClickhouseClient create a clickhouse client.
 func newClickhouseClient(cfg *Config) (*sql.DB, error) {
 	db, err := cfg.buildDB()
@@ -202,7 +778,15 @@ func newClickhouseClient(cfg *Config) (*sql.DB, error) {
 	return db, nil
 }
 
-func createDatabase(ctx context.Context, cfg *Config) error {
+// closeRouteClient closes the client of a route exporter unless it is the client of the exporter.
+func closeRouteClient(exporterClient, client *sql.DB) {
+	if client != exporterClient {
+		_ = client.Close()
+	}
+}
+
+// createDatabase creates the configured database with the configured engine on the given ON CLUSTER string.
+func createDatabase(ctx context.Context, cfg *Config, cluster string) error {
 	// use default database to create new database
 	if cfg.Database == defaultDatabase {
 		return nil
@@ -221,40 +805,230 @@ func createDatabase(ctx context.Context, cfg *Config) error {
 	defer func() {
 		_ = db.Close()
 	}()
-	query := fmt.Sprintf("CREATE DATABASE IF NOT EXISTS %s %s", cfg.Database, cfg.clusterString())
-	_, err = db.ExecContext(ctx, query)
+	query := fmt.Sprintf("CREATE DATABASE IF NOT EXISTS %s %s", internal.QuoteIdentifier(cfg.Database), cluster)
+	if engine := cfg.databaseEngineString(cfg.Database); engine != "" {
+		query += " " + engine
+	}
+	_, err = db.ExecContext(internal.QueryContext(ctx, "create_database"), query)
 	if err != nil {
 		return fmt.Errorf("create database: %w", err)
 	}
 	return nil
 }
 
-func createLogsTable(ctx context.Context, cfg *Config, db *sql.DB) error {
-	if _, err := db.ExecContext(ctx, renderCreateLogsTableSQL(cfg)); err != nil {
-		return fmt.Errorf("exec create logs table sql: %w", err)
+// addProjections adds the configured projections of the given tables.
+// Projections that already exist are left untouched.
+func addProjections(ctx context.Context, cfg *Config, db *sql.DB, signal SignalConfig, tables ...string) error {
+	if !cfg.schemaObjectsFor(signal).Indexes {
+		return nil
+	}
+	cluster := cfg.clusterStringFor(signal)
+	for _, projection := range cfg.Projections {
+		if !slices.Contains(tables, projection.Table) {
+			continue
+		}
+		query := fmt.Sprintf(addProjectionSQL, internal.QuoteIdentifier(projection.Table), cluster, internal.QuoteIdentifier(projection.Name), projection.Query)
+		if _, err := db.ExecContext(internal.QueryContext(ctx, "add_projection"), query); err != nil {
+			return fmt.Errorf("add projection %s to %s: %w", projection.Name, projection.Table, err)
+		}
+		if !projection.Materialize {
+			continue
+		}
+		query = fmt.Sprintf(materializeProjectionSQL, internal.QuoteIdentifier(projection.Table), cluster, internal.QuoteIdentifier(projection.Name))
+		if _, err := db.ExecContext(internal.QueryContext(ctx, "materialize_projection"), query); err != nil {
+			return fmt.Errorf("materialize projection %s of %s: %w", projection.Name, projection.Table, err)
+		}
 	}
 	return nil
 }
 
-func renderCreateLogsTableSQL(cfg *Config) string {
-	ttlExpr := generateTTLExpr(cfg.TTL, "TimestampTime")
-	return fmt.Sprintf(createLogsTableSQL, cfg.LogsTableName, cfg.clusterString(), cfg.tableEngineString(), ttlExpr)
+// updateTTL applies ttlExpr to an existing table whose TTL differs from it, since
+// CREATE TABLE IF NOT EXISTS leaves tables created with a previous TTL untouched.
+// The TTL of a table is removed if ttlExpr is empty.
+func updateTTL(ctx context.Context, cfg *Config, db *sql.DB, signal SignalConfig, table, ttlExpr string) error {
+	var engine string
+	err := db.QueryRowContext(internal.QueryContext(ctx, "select_ttl"), selectTableEngineSQL, cfg.Database, table).Scan(&engine)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("get ttl of %s: %w", table, err)
+	}
+	if compactTTL(tableTTL(engine)) == compactTTL(ttlExpr) {
+		return nil
+	}
+	if ttlExpr == "" {
+		query := fmt.Sprintf(removeTTLSQL, internal.QuoteIdentifier(table), cfg.clusterStringFor(signal))
+		if _, err := db.ExecContext(internal.QueryContext(ctx, "remove_ttl"), query); err != nil {
+			return fmt.Errorf("remove ttl of %s: %w", table, err)
+		}
+		return nil
+	}
+	query := fmt.Sprintf(modifyTTLSQL, internal.QuoteIdentifier(table), cfg.clusterStringFor(signal), ttlExpr)
+	if _, err := db.ExecContext(internal.QueryContext(ctx, "modify_ttl"), query); err != nil {
+		return fmt.Errorf("modify ttl of %s: %w", table, err)
+	}
+	return nil
 }
 
-func renderInsertLogsSQL(cfg *Config) string {
-	return fmt.Sprintf(insertLogsSQLTemplate, cfg.LogsTableName)
+// tableExists reports whether table exists in the database of cfg.
+func tableExists(ctx context.Context, cfg *Config, db *sql.DB, table string) (bool, error) {
+	var exists uint8
+	err := db.QueryRowContext(internal.QueryContext(ctx, "select_table"), selectTableExistsSQL, cfg.Database, table).Scan(&exists)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("check table %s: %w", table, err)
+	}
+	return true, nil
 }
 
-func doWithTx(_ context.Context, db *sql.DB, fn func(tx *sql.Tx) error) error {
-	tx, err := db.Begin()
-	if err != nil {
-		return fmt.Errorf("db.Begin: %w", err)
+// tableTTL extracts the TTL clause from the engine_full of system.tables.
+func tableTTL(engine string) string {
+	_, ttl, found := strings.Cut(engine, " TTL ")
+	if !found {
+		return ""
 	}
-	defer func() {
-		_ = tx.Rollback()
-	}()
-	if err := fn(tx); err != nil {
+	ttl, _, _ = strings.Cut(ttl, " SETTINGS ")
+	return "TTL " + ttl
+}
+
+// compactTTL removes the whitespace ClickHouse reformats in stored TTL expressions.
+func compactTTL(ttl string) string {
+	return strings.Join(strings.Fields(ttl), "")
+}
+
+func createLogsTable(ctx context.Context, cfg *Config, db *sql.DB) error {
+	if cfg.contribSchema() {
+		return createContribLogsTable(ctx, cfg, db)
+	}
+	if objects := cfg.schemaObjectsFor(cfg.Logs.SignalConfig); objects.Tables {
+		if _, err := db.ExecContext(internal.QueryContext(ctx, "create_table"), objects.tableDDL(renderCreateLogsTableSQL(cfg))); err != nil {
+			return fmt.Errorf("exec create logs table sql: %w", err)
+		}
+		if err := updateTTL(ctx, cfg, db, cfg.Logs.SignalConfig, cfg.LogsTableName, cfg.ttlExprFor(cfg.LogsTableName, cfg.TTL, "TimestampTime")); err != nil {
+			return err
+		}
+	}
+	if err := createServiceMetadata(ctx, cfg, db, cfg.Logs.SignalConfig, cfg.LogsTableName); err != nil {
 		return err
 	}
-	return tx.Commit()
+	if err := createDimensions(ctx, cfg, db, cfg.Logs.SignalConfig); err != nil {
+		return err
+	}
+	if cfg.Logs.TraceIDLookup {
+		if err := createLogsTraceIDTsTable(ctx, cfg, db); err != nil {
+			return err
+		}
+	}
+	return addProjections(ctx, cfg, db, cfg.Logs.SignalConfig, cfg.LogsTableName, cfg.logsTraceIDTsTableName())
+}
+
+// createMissingLogsTable creates the logs table of cfg and its derived tables unless the logs table exists, for
+// the tables created on first write, whose existing tables, e.g. provisioned ahead for a tenant, are used as is.
+func createMissingLogsTable(ctx context.Context, cfg *Config, db *sql.DB) error {
+	if exists, err := tableExists(ctx, cfg, db, cfg.LogsTableName); err != nil || exists {
+		return err
+	}
+	return createLogsTable(ctx, cfg, db)
+}
+
+// createLogsTraceIDTsTable creates the trace id lookup table of the logs table and its materialized view.
+func createLogsTraceIDTsTable(ctx context.Context, cfg *Config, db *sql.DB) error {
+	objects := cfg.schemaObjectsFor(cfg.Logs.SignalConfig)
+	if objects.Tables {
+		if _, err := db.ExecContext(internal.QueryContext(ctx, "create_table"), objects.tableDDL(renderCreateLogsTraceIDTsTableSQL(cfg))); err != nil {
+			return fmt.Errorf("exec create logs traceID timestamp table sql: %w", err)
+		}
+		if err := updateTTL(ctx, cfg, db, cfg.Logs.SignalConfig, cfg.logsTraceIDTsTableName(), generateTTLExpr(cfg.TTL, "Start")); err != nil {
+			return err
+		}
+	}
+	if objects.MaterializedViews {
+		if _, err := db.ExecContext(internal.QueryContext(ctx, "create_view"), renderLogsTraceIDTsMaterializedViewSQL(cfg)); err != nil {
+			return fmt.Errorf("exec create logs traceID timestamp view sql: %w", err)
+		}
+	}
+	return nil
+}
+
+func renderCreateLogsTableSQL(cfg *Config) string {
+	ttlExpr := cfg.ttlExprFor(cfg.LogsTableName, cfg.TTL, "TimestampTime")
+	partitionBy := cfg.tenantPartitionExprFor(cfg.Logs.SignalConfig, "TimestampTime")
+	comments := logsColumnComments
+	var optionalColumns strings.Builder
+	if columns := logsOptionalColumns(cfg); len(columns) > 0 {
+		comments = maps.Clone(logsColumnComments)
+		for _, column := range columns {
+			fmt.Fprintf(&optionalColumns, "\t%s %s CODEC(%s),\n", column.Name, column.Type, column.Codec)
+			if column.Comment != "" {
+				comments[column.Name] = column.Comment
+			}
+		}
+	}
+	ddl := fmt.Sprintf(createLogsTableSQL, internal.QuoteIdentifier(cfg.LogsTableName), cfg.clusterStringFor(cfg.Logs.SignalConfig), optionalColumns.String(), cfg.tableEngineStringFor(cfg.Logs.SignalConfig), partitionBy, ttlExpr)
+	return cfg.columnOptions().Apply(cfg.LogsTableName, internal.CommentColumns(ddl, comments))
+}
+
+// logsOptionalColumns returns the configured optional columns of the logs table, following the other
+// columns in this order in the table and the INSERT.
+func logsOptionalColumns(cfg *Config) []internal.ColumnDef {
+	var columns []internal.ColumnDef
+	if cfg.Logs.RawRecord.Enabled {
+		columns = append(columns, internal.ColumnDef{Name: rawRecordColumn, Type: "String", Codec: "ZSTD(1)"})
+	}
+	if cfg.Logs.Patterns.Enabled {
+		columns = append(columns,
+			internal.ColumnDef{Name: "PatternId", Type: "UInt64", Codec: "ZSTD(1)"},
+			internal.ColumnDef{Name: "Pattern", Type: "LowCardinality(String)", Codec: "ZSTD(1)"})
+	}
+	for _, column := range cfg.Logs.BodyJSONColumns {
+		columns = append(columns, internal.ColumnDef{Name: column.Name, Type: "String", Comment: "Value at " + column.Path + " of LogRecord.body", Codec: "ZSTD(1)"})
+	}
+	if cfg.Dimensions.Enabled {
+		columns = append(columns, internal.FingerprintColumns()...)
+	}
+	if column := cfg.tenantColumn(); column != "" {
+		columns = append(columns, internal.TenantColumn(column))
+	}
+	return columns
+}
+
+func renderCreateLogsTraceIDTsTableSQL(cfg *Config) string {
+	ttlExpr := generateTTLExpr(cfg.TTL, "Start")
+	ddl := fmt.Sprintf(createLogsTraceIDTsTableSQL, internal.QuoteIdentifier(cfg.logsTraceIDTsTableName()), cfg.clusterStringFor(cfg.Logs.SignalConfig), cfg.tableEngineStringFor(cfg.Logs.SignalConfig), internal.PartitionExpr(cfg.partitionByFor(cfg.Logs.SignalConfig), "Start"), ttlExpr)
+	return cfg.columnOptions().Apply(cfg.logsTraceIDTsTableName(), internal.CommentColumns(ddl, logsTraceIDTsColumnComments))
+}
+
+func renderLogsTraceIDTsMaterializedViewSQL(cfg *Config) string {
+	database := internal.QuoteIdentifier(cfg.Database)
+	return fmt.Sprintf(createLogsTraceIDTsMaterializedViewSQL, internal.QuoteIdentifier(cfg.logsTraceIDTsTableName()+"_mv"),
+		cfg.clusterStringFor(cfg.Logs.SignalConfig), database, internal.QuoteIdentifier(cfg.logsTraceIDTsTableName()),
+		database, internal.QuoteIdentifier(cfg.LogsTableName))
+}
+
+func renderInsertLogsSQL(cfg *Config) string {
+	var columns, values strings.Builder
+	for _, column := range logsOptionalColumns(cfg) {
+		columns.WriteString(",\n                        " + column.Name)
+		values.WriteString(",\n                                  ?")
+	}
+	return fmt.Sprintf(insertLogsSQLTemplate, internal.QuoteIdentifier(cfg.LogsTableName), columns.String(), values.String())
+}
+
+func doWithTx(ctx context.Context, db *sql.DB, fn func(ctx context.Context, tx *sql.Tx) error) error {
+	return internal.RetrySkippedRows(ctx, func(ctx context.Context) error {
+		tx, err := db.Begin()
+		if err != nil {
+			return fmt.Errorf("db.Begin: %w", err)
+		}
+		defer func() {
+			_ = tx.Rollback()
+		}()
+		if err := fn(ctx, tx); err != nil {
+			return err
+		}
+		return tx.Commit()
+	})
 }
@@ -8,17 +8,33 @@ import (
 	"database/sql"
 	"database/sql/driver"
 	"errors"
+	"fmt"
+	"io"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/ClickHouse/clickhouse-go/v2"
 	"github.com/ClickHouse/clickhouse-go/v2/lib/column/orderedmap"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component/componenttest"
 	"go.opentelemetry.io/collector/pdata/pcommon"
 	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 	conventions "go.opentelemetry.io/otel/semconv/v1.27.0"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zaptest"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/foyer-work/otel-distribution/exporter/clickhouse/internal"
+	"github.com/foyer-work/otel-distribution/exporter/clickhouse/internal/metadata"
 )
 
 func TestLogsExporter_New(t *testing.T) {
@@ -126,6 +142,44 @@ func TestExporter_pushLogsData(t *testing.T) {
 		exporter := newTestLogsExporter(t, defaultEndpoint, withDriverName(t.Name()))
 		mustPushLogsData(t, exporter, simpleLogsWithNoTimestamp(1))
 	})
+	t.Run("observed timestamp", func(t *testing.T) {
+		var timestamps, observed []driver.Value
+		initClickhouseTestServer(t, func(query string, values []driver.Value) error {
+			if strings.HasPrefix(query, "INSERT") {
+				timestamps = append(timestamps, values[0])
+				observed = append(observed, values[15])
+			}
+			return nil
+		})
+
+		ld := simpleLogs(1)
+		r := ld.ResourceLogs().At(0).ScopeLogs().At(0).LogRecords().At(0)
+		r.SetObservedTimestamp(r.Timestamp() + pcommon.Timestamp(3*time.Second))
+		exporter := newTestLogsExporter(t, defaultEndpoint, withDriverName(t.Name()))
+		mustPushLogsData(t, exporter, ld)
+		require.Equal(t, []driver.Value{r.Timestamp().AsTime()}, timestamps)
+		require.Equal(t, []driver.Value{r.ObservedTimestamp().AsTime()}, observed)
+	})
+	t.Run("dropped attributes counts", func(t *testing.T) {
+		var counts [][]driver.Value
+		initClickhouseTestServer(t, func(query string, values []driver.Value) error {
+			if strings.HasPrefix(query, "INSERT") {
+				counts = append(counts, values[16:19])
+			}
+			return nil
+		})
+
+		ld := simpleLogs(2)
+		ld.ResourceLogs().At(0).Resource().SetDroppedAttributesCount(1)
+		ld.ResourceLogs().At(0).ScopeLogs().At(0).Scope().SetDroppedAttributesCount(2)
+		ld.ResourceLogs().At(0).ScopeLogs().At(0).LogRecords().At(1).SetDroppedAttributesCount(3)
+		exporter := newTestLogsExporter(t, defaultEndpoint, withDriverName(t.Name()))
+		mustPushLogsData(t, exporter, ld)
+		require.Equal(t, [][]driver.Value{
+			{uint32(1), uint32(2), uint32(0)},
+			{uint32(1), uint32(2), uint32(3)},
+		}, counts)
+	})
 	t.Run("test with 2 log records with different service.name", func(t *testing.T) {
 		initClickhouseTestServer(t, func(query string, values []driver.Value) error {
 			if strings.HasPrefix(query, "INSERT") {
@@ -160,6 +214,49 @@ func TestLogsTableEngineConfig(t *testing.T) {
 	})
 }
 
+func TestLogsExporter_addProjections(t *testing.T) {
+	var queries []string
+	initClickhouseTestServer(t, func(query string, _ []driver.Value) error {
+		if strings.HasPrefix(query, "ALTER TABLE") {
+			queries = append(queries, query)
+		}
+		return nil
+	})
+	newTestLogsExporter(t, defaultEndpoint, withDriverName(t.Name()), func(cfg *Config) {
+		cfg.Projections = []ProjectionConfig{
+			{Table: "otel_logs", Name: "by_trace_id", Query: "SELECT * ORDER BY TraceId", Materialize: true},
+			{Table: "otel_traces", Name: "by_trace_id", Query: "SELECT * ORDER BY TraceId"},
+		}
+	})
+
+	require.Equal(t, []string{
+		"ALTER TABLE `otel_logs`  ADD PROJECTION IF NOT EXISTS `by_trace_id` (SELECT * ORDER BY TraceId)",
+		"ALTER TABLE `otel_logs`  MATERIALIZE PROJECTION `by_trace_id`",
+	}, queries)
+}
+
+func TestRenderCreateLogsTableSQL_isSampled(t *testing.T) {
+	ddl := renderCreateLogsTableSQL(withDefaultConfig())
+	require.Contains(t, ddl, "\tIsSampled Bool MATERIALIZED bitTest(TraceFlags, 0) COMMENT 'LogRecord.flags has the W3C trace context sampled flag',\n")
+}
+
+func TestRenderCreateLogsTableSQL_columnCodecs(t *testing.T) {
+	cfg := withDefaultConfig()
+	require.Equal(t, renderCreateLogsTableSQL(cfg), internal.RewriteColumns(renderCreateLogsTableSQL(cfg), func(*internal.ColumnDef) {}))
+	require.Contains(t, renderCreateLogsTableSQL(cfg), "\nCOMMENT 'Created by the OpenTelemetry ClickHouse exporter, schema version 1';\n")
+
+	cfg.ColumnCodecs = map[string]string{
+		"Timestamp":           "DoubleDelta, ZSTD(3)",
+		"otel_logs.Body":      "ZSTD(6)",
+		"otel_traces.TraceId": "LZ4",
+	}
+	ddl := renderCreateLogsTableSQL(cfg)
+	require.Contains(t, ddl, "\tTimestamp DateTime64(9) COMMENT 'LogRecord.time_unix_nano, or observed_time_unix_nano if unset' CODEC(DoubleDelta, ZSTD(3)),\n")
+	require.Contains(t, ddl, "\tBody String COMMENT 'LogRecord.body' CODEC(ZSTD(6)),\n")
+	require.Contains(t, ddl, "\tTraceId String COMMENT 'LogRecord.trace_id as hex' CODEC(ZSTD(1)),\n")
+	require.Contains(t, ddl, "\tTimestampTime DateTime DEFAULT toDateTime(Timestamp) COMMENT 'Timestamp truncated to seconds',\n")
+}
+
 func newTestLogsExporter(t *testing.T, dsn string, fns ...func(*Config)) *logsExporter {
 	exporter, err := newLogsExporter(zaptest.NewLogger(t), withTestExporterConfig(fns...)(dsn))
 	require.NoError(t, err)
@@ -260,32 +357,71 @@ func mustPushLogsData(t *testing.T, exporter *logsExporter, ld plog.Logs) {
 	require.NoError(t, err)
 }
 
+// newTestTelemetry returns exporter telemetry whose metrics are collected by reader.
+func newTestTelemetry(t *testing.T, reader sdkmetric.Reader) *exporterTelemetry {
+	settings := componenttest.NewNopTelemetrySettings()
+	settings.MeterProvider = sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	telemetry, err := newExporterTelemetry(settings)
+	require.NoError(t, err)
+	return telemetry
+}
+
 func initClickhouseTestServer(t *testing.T, recorder recorder) {
+	initClickhouseTestServerWithRows(t, recorder, nil)
+}
+
+// initClickhouseTestServerWithRows registers a test driver whose queries return
+// the single column rows produced by rows.
+func initClickhouseTestServerWithRows(t *testing.T, recorder recorder, rows rowsFunc) {
 	sql.Register(t.Name(), &testClickhouseDriver{
 		recorder: recorder,
+		rows:     rows,
+	})
+}
+
+// initClickhouseTestBatchServer registers a test driver whose transactions
+// bind the rows of their INSERT statements to a batch like the clickhouse
+// driver: a row failing to bind invalidates the batch, failing the following
+// rows and the commit with clickhouse.ErrBatchInvalid. The query and rows of
+// committed batches are passed to committed.
+func initClickhouseTestBatchServer(t *testing.T, bind recorder, committed func(query string, rows [][]driver.Value)) {
+	sql.Register(t.Name(), &testClickhouseDriver{
+		recorder:  bind,
+		committed: committed,
 	})
 }
 
 type recorder func(query string, values []driver.Value) error
 
+type rowsFunc func(query string, values []driver.Value) []string
+
 type testClickhouseDriver struct {
-	recorder recorder
+	recorder  recorder
+	rows      rowsFunc
+	committed func(query string, rows [][]driver.Value)
 }
 
 func (t *testClickhouseDriver) Open(_ string) (driver.Conn, error) {
 	return &testClickhouseDriverConn{
-		recorder: t.recorder,
+		recorder:  t.recorder,
+		rows:      t.rows,
+		committed: t.committed,
 	}, nil
 }
 
 type testClickhouseDriverConn struct {
-	recorder recorder
+	recorder  recorder
+	rows      rowsFunc
+	committed func(query string, rows [][]driver.Value)
+	batch     *testClickhouseDriverBatch
 }
 
 func (t *testClickhouseDriverConn) Prepare(query string) (driver.Stmt, error) {
 	return &testClickhouseDriverStmt{
 		query:    query,
 		recorder: t.recorder,
+		rows:     t.rows,
+		conn:     t,
 	}, nil
 }
 
@@ -293,8 +429,12 @@ func (*testClickhouseDriverConn) Close() error {
 	return nil
 }
 
-func (*testClickhouseDriverConn) Begin() (driver.Tx, error) {
-	return &testClickhouseDriverTx{}, nil
+func (t *testClickhouseDriverConn) Begin() (driver.Tx, error) {
+	if t.committed == nil {
+		return &testClickhouseDriverTx{}, nil
+	}
+	t.batch = &testClickhouseDriverBatch{conn: t}
+	return t.batch, nil
 }
 
 func (*testClickhouseDriverConn) CheckNamedValue(_ *driver.NamedValue) error {
@@ -304,6 +444,8 @@ func (*testClickhouseDriverConn) CheckNamedValue(_ *driver.NamedValue) error {
 type testClickhouseDriverStmt struct {
 	query    string
 	recorder recorder
+	rows     rowsFunc
+	conn     *testClickhouseDriverConn
 }
 
 func (*testClickhouseDriverStmt) Close() error {
@@ -315,11 +457,38 @@ func (t *testClickhouseDriverStmt) NumInput() int {
 }
 
 func (t *testClickhouseDriverStmt) Exec(args []driver.Value) (driver.Result, error) {
+	if batch := t.conn.batch; batch != nil && strings.HasPrefix(t.query, "INSERT") {
+		return nil, batch.append(t.query, args, t.recorder(t.query, args))
+	}
 	return nil, t.recorder(t.query, args)
 }
 
-func (t *testClickhouseDriverStmt) Query(_ []driver.Value) (driver.Rows, error) {
-	return nil, nil
+func (t *testClickhouseDriverStmt) Query(args []driver.Value) (driver.Rows, error) {
+	rows := &testClickhouseDriverRows{}
+	if t.rows != nil {
+		rows.values = t.rows(t.query, args)
+	}
+	return rows, nil
+}
+
+type testClickhouseDriverRows struct {
+	values []string
+}
+
+func (*testClickhouseDriverRows) Columns() []string {
+	return []string{"value"}
+}
+
+func (*testClickhouseDriverRows) Close() error {
+	return nil
+}
+
+func (r *testClickhouseDriverRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	dest[0], r.values = r.values[0], r.values[1:]
+	return nil
 }
 
 type testClickhouseDriverTx struct{}
@@ -331,3 +500,627 @@ func (*testClickhouseDriverTx) Commit() error {
 func (*testClickhouseDriverTx) Rollback() error {
 	return nil
 }
+
+type testClickhouseDriverBatch struct {
+	conn  *testClickhouseDriverConn
+	query string
+	rows  [][]driver.Value
+	err   error
+}
+
+func (b *testClickhouseDriverBatch) append(query string, row []driver.Value, err error) error {
+	switch {
+	case b.err != nil:
+		return b.err
+	case err != nil:
+		b.err = fmt.Errorf("%w: %w", clickhouse.ErrBatchInvalid, err)
+		return err
+	}
+	b.query = query
+	b.rows = append(b.rows, row)
+	return nil
+}
+
+func (b *testClickhouseDriverBatch) Commit() error {
+	b.conn.batch = nil
+	if b.err != nil {
+		return b.err
+	}
+	b.conn.committed(b.query, b.rows)
+	return nil
+}
+
+func (b *testClickhouseDriverBatch) Rollback() error {
+	b.conn.batch = nil
+	return nil
+}
+
+func TestLogsExporter_tableTemplate(t *testing.T) {
+	var queries []string
+	inserts := map[string]int{}
+	initClickhouseTestServer(t, func(query string, _ []driver.Value) error {
+		if strings.HasPrefix(query, "INSERT INTO") {
+			inserts[strings.Fields(query)[2]]++
+		} else {
+			queries = append(queries, getQueryFirstLine(query))
+		}
+		return nil
+	})
+	exporter := newTestLogsExporter(t, defaultEndpoint, withDriverName(t.Name()), func(cfg *Config) {
+		cfg.LogsTableName = "otel_logs_%Y%m_{deployment.environment}"
+	})
+	require.Empty(t, queries)
+
+	ld := simpleLogs(2)
+	ld.ResourceLogs().At(0).Resource().Attributes().PutStr("deployment.environment", "prod")
+	next := ld.ResourceLogs().At(0).ScopeLogs().At(0).LogRecords().AppendEmpty()
+	next.SetTimestamp(pcommon.NewTimestampFromTime(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)))
+	mustPushLogsData(t, exporter, ld)
+	mustPushLogsData(t, exporter, ld)
+
+	require.ElementsMatch(t, []string{
+		"CREATE TABLE IF NOT EXISTS `otel_logs_202312_prod`",
+		"CREATE TABLE IF NOT EXISTS `otel_logs_202401_prod`",
+	}, queries)
+	require.Equal(t, map[string]int{"`otel_logs_202312_prod`": 4, "`otel_logs_202401_prod`": 2}, inserts)
+}
+
+func TestLogsExporter_routing(t *testing.T) {
+	var queries []string
+	inserts := map[string]int{}
+	initClickhouseTestServer(t, func(query string, _ []driver.Value) error {
+		if strings.HasPrefix(query, "INSERT INTO") {
+			inserts[strings.Fields(query)[2]]++
+		} else {
+			queries = append(queries, getQueryFirstLine(query))
+		}
+		return nil
+	})
+	exporter := newTestLogsExporter(t, defaultEndpoint, withDriverName(t.Name()), func(cfg *Config) {
+		cfg.LogsTableName = "otel_logs_{service.name}"
+		cfg.Routing = RoutingConfig{
+			Attribute: "tenant.id",
+			Routes: map[string]RouteConfig{
+				"acme":   {Database: "acme", LogsTableName: "logs"},
+				"globex": {LogsTableName: "globex_{service.name}"},
+			},
+		}
+	})
+	require.Empty(t, queries)
+
+	ld := simpleLogs(2)
+	for _, tenant := range []string{"acme", "globex", "initech"} {
+		rl := ld.ResourceLogs().AppendEmpty()
+		ld.ResourceLogs().At(0).CopyTo(rl)
+		rl.Resource().Attributes().PutStr("tenant.id", tenant)
+	}
+	mustPushLogsData(t, exporter, ld)
+	mustPushLogsData(t, exporter, ld)
+
+	require.ElementsMatch(t, []string{
+		"CREATE DATABASE IF NOT EXISTS `acme`",
+		"CREATE TABLE IF NOT EXISTS `logs`",
+		"CREATE TABLE IF NOT EXISTS `globex_test_service`",
+		"CREATE TABLE IF NOT EXISTS `otel_logs_test_service`",
+	}, queries)
+	require.Equal(t, map[string]int{"`logs`": 4, "`globex_test_service`": 4, "`otel_logs_test_service`": 8}, inserts)
+}
+
+func TestLogsExporter_routingLazySchema(t *testing.T) {
+	var mu sync.Mutex
+	var queries []string
+	initClickhouseTestServerWithRows(t, func(query string, _ []driver.Value) error {
+		if !strings.HasPrefix(query, "INSERT INTO") {
+			mu.Lock()
+			queries = append(queries, getQueryFirstLine(query))
+			mu.Unlock()
+		}
+		return nil
+	}, func(query string, values []driver.Value) []string {
+		// The table of globex was provisioned ahead.
+		if query == selectTableExistsSQL && values[0] == "globex" {
+			return []string{"1"}
+		}
+		return nil
+	})
+	exporter := newTestLogsExporter(t, defaultEndpoint, withDriverName(t.Name()), func(cfg *Config) {
+		cfg.Routing = RoutingConfig{
+			Attribute: "tenant.id",
+			Routes: map[string]RouteConfig{
+				"acme":   {Database: "acme"},
+				"globex": {Database: "globex"},
+			},
+		}
+	})
+	queries = nil
+
+	ld := simpleLogs(1)
+	ld.ResourceLogs().At(0).Resource().Attributes().PutStr("tenant.id", "acme")
+	ld.ResourceLogs().At(0).CopyTo(ld.ResourceLogs().AppendEmpty())
+	ld.ResourceLogs().At(1).Resource().Attributes().PutStr("tenant.id", "globex")
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, exporter.pushLogsData(context.Background(), ld))
+		}()
+	}
+	wg.Wait()
+
+	require.ElementsMatch(t, []string{
+		"CREATE DATABASE IF NOT EXISTS `acme`",
+		"CREATE DATABASE IF NOT EXISTS `globex`",
+		"CREATE TABLE IF NOT EXISTS `otel_logs`",
+	}, queries)
+}
+
+func TestLogsExporter_tableRoutes(t *testing.T) {
+	var queries []string
+	inserts := map[string]int{}
+	initClickhouseTestServer(t, func(query string, _ []driver.Value) error {
+		if strings.HasPrefix(query, "INSERT INTO") {
+			inserts[strings.Fields(query)[2]]++
+		} else if line := getQueryFirstLine(query); strings.HasPrefix(line, "CREATE TABLE") {
+			queries = append(queries, line)
+		}
+		return nil
+	})
+	exporter := newTestLogsExporter(t, defaultEndpoint, withDriverName(t.Name()), func(cfg *Config) {
+		cfg.Logs.ErrorLogs = ErrorLogsConfig{Enabled: true, MinSeverity: "error"}
+		cfg.Logs.TableRoutes = []TableRouteConfig{
+			{Conditions: []string{`attributes["signal.tier"] == "audit"`}, TableName: "otel_audit_logs"},
+			{Conditions: []string{`resource.attributes["service.name"] == "billing"`, `severity_number < SEVERITY_NUMBER_ERROR`}, TableName: "otel_logs_{service.name}"},
+		}
+	})
+	require.Equal(t, []string{
+		"CREATE TABLE IF NOT EXISTS `otel_logs_errors`",
+		"CREATE TABLE IF NOT EXISTS `otel_audit_logs`",
+		"CREATE TABLE IF NOT EXISTS `otel_logs`",
+	}, queries)
+
+	ld := simpleLogs(4)
+	records := ld.ResourceLogs().At(0).ScopeLogs().At(0).LogRecords()
+	records.At(0).Attributes().PutStr("signal.tier", "audit")
+	records.At(1).SetSeverityNumber(plog.SeverityNumberInfo)
+	rl := ld.ResourceLogs().AppendEmpty()
+	ld.ResourceLogs().At(0).CopyTo(rl)
+	rl.Resource().Attributes().PutStr("service.name", "billing")
+	mustPushLogsData(t, exporter, ld)
+
+	require.Len(t, queries, 5)
+	// The tables of the templated route are created in no particular order.
+	require.ElementsMatch(t, []string{
+		"CREATE TABLE IF NOT EXISTS `otel_logs_test_service`",
+		"CREATE TABLE IF NOT EXISTS `otel_logs_billing`",
+	}, queries[3:])
+	require.Equal(t, map[string]int{"`otel_audit_logs`": 2, "`otel_logs_test_service`": 1, "`otel_logs_billing`": 3, "`otel_logs_errors`": 2}, inserts)
+}
+
+func TestLogsExporter_errorLogs(t *testing.T) {
+	var queries []string
+	inserts := map[string][]driver.Value{}
+	initClickhouseTestServer(t, func(query string, values []driver.Value) error {
+		if strings.HasPrefix(query, "INSERT INTO") {
+			table := strings.Fields(query)[2]
+			inserts[table] = append(inserts[table], values[5])
+		} else if strings.Contains(query, "CREATE TABLE") {
+			queries = append(queries, query)
+		}
+		return nil
+	})
+	exporter := newTestLogsExporter(t, defaultEndpoint, withDriverName(t.Name()), func(cfg *Config) {
+		cfg.TTL = 72 * time.Hour
+		cfg.Logs.ErrorLogs = ErrorLogsConfig{Enabled: true, MinSeverity: "warn", TTL: 90 * 24 * time.Hour}
+	})
+	require.Len(t, queries, 2)
+	require.Contains(t, queries[0], "CREATE TABLE IF NOT EXISTS `otel_logs_errors`")
+	require.Contains(t, queries[0], "TTL TimestampTime + toIntervalDay(90)")
+	require.Contains(t, queries[1], "CREATE TABLE IF NOT EXISTS `otel_logs`")
+	require.Contains(t, queries[1], "TTL TimestampTime + toIntervalDay(3)")
+
+	ld := simpleLogs(1)
+	records := ld.ResourceLogs().At(0).ScopeLogs().At(0).LogRecords()
+	records.AppendEmpty().SetSeverityNumber(plog.SeverityNumberInfo)
+	records.AppendEmpty().SetSeverityNumber(plog.SeverityNumberWarn)
+	records.AppendEmpty()
+	mustPushLogsData(t, exporter, ld)
+
+	require.Equal(t, map[string][]driver.Value{
+		"`otel_logs_errors`": {int32(plog.SeverityNumberError2), int32(plog.SeverityNumberWarn)},
+		"`otel_logs`":        {int32(plog.SeverityNumberInfo), int32(plog.SeverityNumberUnspecified)},
+	}, inserts)
+}
+
+func TestLogsExporter_traceIDLookup(t *testing.T) {
+	var queries []string
+	initClickhouseTestServer(t, func(query string, _ []driver.Value) error {
+		queries = append(queries, query)
+		return nil
+	})
+	exporter := newTestLogsExporter(t, defaultEndpoint, withDriverName(t.Name()), func(cfg *Config) {
+		cfg.Database = "otel"
+		cfg.TTL = 72 * time.Hour
+		cfg.Logs.TraceIDLookup = true
+	})
+	require.Len(t, queries, 4)
+	require.Equal(t, "CREATE TABLE IF NOT EXISTS `otel_logs_trace_id_ts`", getQueryFirstLine(queries[2]))
+	require.Contains(t, queries[2], "TTL Start + toIntervalDay(3)")
+	require.Contains(t, queries[2], "ORDER BY (TraceId, ServiceName, Start)")
+	require.Equal(t, "CREATE MATERIALIZED VIEW IF NOT EXISTS `otel_logs_trace_id_ts_mv`", getQueryFirstLine(queries[3]))
+	require.Contains(t, queries[3], "TO `otel`.`otel_logs_trace_id_ts`")
+	require.Contains(t, queries[3], "FROM\n`otel`.`otel_logs`")
+	mustPushLogsData(t, exporter, simpleLogs(1))
+
+	driverName := t.Name()
+	t.Run("error logs", func(t *testing.T) {
+		queries = nil
+		newTestLogsExporter(t, defaultEndpoint, withDriverName(driverName), func(cfg *Config) {
+			cfg.Logs.ErrorLogs = ErrorLogsConfig{Enabled: true, MinSeverity: "ERROR"}
+			cfg.Logs.TraceIDLookup = true
+		})
+		var firstLines []string
+		for _, query := range queries {
+			firstLines = append(firstLines, getQueryFirstLine(query))
+		}
+		require.Equal(t, []string{
+			"CREATE TABLE IF NOT EXISTS `otel_logs_errors`",
+			"CREATE TABLE IF NOT EXISTS `otel_logs_errors_trace_id_ts`",
+			"CREATE MATERIALIZED VIEW IF NOT EXISTS `otel_logs_errors_trace_id_ts_mv`",
+			"CREATE TABLE IF NOT EXISTS `otel_logs`",
+			"CREATE TABLE IF NOT EXISTS `otel_logs_trace_id_ts`",
+			"CREATE MATERIALIZED VIEW IF NOT EXISTS `otel_logs_trace_id_ts_mv`",
+		}, firstLines)
+	})
+}
+
+func TestLogsExporter_maxBodyBytes(t *testing.T) {
+	var bodies, attributes []driver.Value
+	initClickhouseTestServer(t, func(query string, values []driver.Value) error {
+		if strings.HasPrefix(query, "INSERT INTO") {
+			bodies = append(bodies, values[7])
+			attributes = append(attributes, values[14])
+		}
+		return nil
+	})
+	reader := sdkmetric.NewManualReader()
+	exporter := newTestLogsExporter(t, defaultEndpoint, withDriverName(t.Name()), func(cfg *Config) {
+		cfg.Logs.MaxBodyBytes = 8
+	})
+	exporter.telemetry = newTestTelemetry(t, reader)
+
+	ld := simpleLogs(1)
+	ld.ResourceLogs().At(0).ScopeLogs().At(0).LogRecords().AppendEmpty().Body().SetStr("short")
+	mustPushLogsData(t, exporter, ld)
+
+	require.Equal(t, []driver.Value{"error me", "short"}, bodies)
+	require.JSONEq(t, `{"service_namespace":"default","log_body_truncated":true}`, attributes[0].(string))
+	require.JSONEq(t, `{}`, attributes[1].(string))
+	_, ok := ld.ResourceLogs().At(0).ScopeLogs().At(0).LogRecords().At(0).Attributes().Get(bodyTruncatedAttribute)
+	require.False(t, ok, "the pushed logs are not modified")
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	var truncated int64
+	for _, m := range rm.ScopeMetrics[0].Metrics {
+		if m.Name == "otelcol_exporter_clickhouse_truncated_log_bodies" {
+			truncated += m.Data.(metricdata.Sum[int64]).DataPoints[0].Value
+		}
+	}
+	require.Equal(t, int64(1), truncated)
+}
+
+func TestLogsExporter_bodyJSONColumns(t *testing.T) {
+	var ddl, insert string
+	var rows [][]driver.Value
+	initClickhouseTestServer(t, func(query string, values []driver.Value) error {
+		switch {
+		case strings.HasPrefix(query, "INSERT INTO"):
+			insert = query
+			rows = append(rows, values[19:])
+		case strings.Contains(query, "CREATE TABLE"):
+			ddl = query
+		}
+		return nil
+	})
+	exporter := newTestLogsExporter(t, defaultEndpoint, withDriverName(t.Name()), func(cfg *Config) {
+		cfg.Logs.MaxBodyBytes = 4
+		cfg.Logs.BodyJSONColumns = []BodyJSONColumnConfig{
+			{Name: "Level", Path: "$.level"},
+			{Name: "RequestId", Path: "$.request.id"},
+		}
+	})
+	require.Contains(t, ddl, "\tLevel String COMMENT 'Value at $.level of LogRecord.body' CODEC(ZSTD(1)),\n")
+	require.Contains(t, ddl, "\tRequestId String COMMENT 'Value at $.request.id of LogRecord.body' CODEC(ZSTD(1)),\n")
+
+	ld := simpleLogs(1)
+	ld.ResourceLogs().At(0).ScopeLogs().At(0).LogRecords().AppendEmpty().Body().SetStr(`{"level":"warn","request":{"id":42}}`)
+	mustPushLogsData(t, exporter, ld)
+	require.Contains(t, insert, "LogDroppedAttrCount,\n                        Level,\n                        RequestId\n")
+	require.Equal(t, [][]driver.Value{{"", ""}, {"warn", "42"}}, rows, "values are extracted from the bodies before truncation")
+}
+
+func TestLogsExporter_attributeKeys(t *testing.T) {
+	var resAttrs, logAttrs []driver.Value
+	initClickhouseTestServer(t, func(query string, values []driver.Value) error {
+		if strings.HasPrefix(query, "INSERT INTO") {
+			resAttrs = append(resAttrs, values[9])
+			logAttrs = append(logAttrs, values[14])
+		}
+		return nil
+	})
+	exporter := newTestLogsExporter(t, defaultEndpoint, withDriverName(t.Name()), func(cfg *Config) {
+		cfg.Logs.Attributes.Exclude = []string{"http.request.header.*", "service.namespace"}
+	})
+
+	ld := simpleLogs(1)
+	ld.ResourceLogs().At(0).Resource().Attributes().PutStr("http.request.header.authorization", "Bearer secret")
+	attrs := ld.ResourceLogs().At(0).ScopeLogs().At(0).LogRecords().At(0).Attributes()
+	attrs.PutStr("http.request.header.cookie", "session=secret")
+	attrs.PutStr("http.request.method", "GET")
+	mustPushLogsData(t, exporter, ld)
+
+	require.JSONEq(t, `{"service_name":"test-service"}`, resAttrs[0].(string))
+	require.JSONEq(t, `{"http_request_method":"GET"}`, logAttrs[0].(string))
+	_, ok := attrs.Get("http.request.header.cookie")
+	require.True(t, ok, "the pushed logs are not modified")
+}
+
+func TestLogsExporter_rawRecord(t *testing.T) {
+	var ddl string
+	var rows [][]driver.Value
+	initClickhouseTestServer(t, func(query string, values []driver.Value) error {
+		switch {
+		case strings.HasPrefix(query, "INSERT INTO"):
+			rows = append(rows, values[19:])
+		case strings.Contains(query, "CREATE TABLE"):
+			ddl = query
+		}
+		return nil
+	})
+	exporter := newTestLogsExporter(t, defaultEndpoint, withDriverName(t.Name()), func(cfg *Config) {
+		cfg.Logs.MaxBodyBytes = 4
+		cfg.Logs.RawRecord.Enabled = true
+		cfg.Logs.BodyJSONColumns = []BodyJSONColumnConfig{{Name: "Level", Path: "$.level"}}
+	})
+	require.Contains(t, ddl, "\tRawRecord String COMMENT 'LogRecord with its Resource and InstrumentationScope as received, serialized as an OTLP ExportLogsServiceRequest' CODEC(ZSTD(1)),\n\tLevel String")
+
+	ld := simpleLogs(1)
+	mustPushLogsData(t, exporter, ld)
+	require.Len(t, rows, 1)
+	require.Len(t, rows[0], 2)
+	expected, err := (&plog.ProtoMarshaler{}).MarshalLogs(ld)
+	require.NoError(t, err)
+	require.Equal(t, string(expected), rows[0][0], "the record is stored before truncation")
+
+	driverName := t.Name()
+	t.Run("json", func(t *testing.T) {
+		rows = nil
+		exporter := newTestLogsExporter(t, defaultEndpoint, withDriverName(driverName), func(cfg *Config) {
+			cfg.Logs.RawRecord = RawRecordConfig{Enabled: true, Encoding: "json"}
+		})
+		mustPushLogsData(t, exporter, ld)
+		expected, err := (&plog.JSONMarshaler{}).MarshalLogs(ld)
+		require.NoError(t, err)
+		require.Equal(t, []driver.Value{string(expected)}, rows[0])
+	})
+}
+
+func TestLogsExporter_patterns(t *testing.T) {
+	var ddl string
+	patterns := map[string][]driver.Value{}
+	initClickhouseTestServer(t, func(query string, values []driver.Value) error {
+		switch {
+		case strings.HasPrefix(query, "INSERT INTO"):
+			table := strings.Fields(query)[2]
+			patterns[table] = append(patterns[table], values[20])
+		case strings.Contains(query, "CREATE TABLE IF NOT EXISTS `otel_logs`"):
+			ddl = query
+		}
+		return nil
+	})
+	exporter := newTestLogsExporter(t, defaultEndpoint, withDriverName(t.Name()), func(cfg *Config) {
+		cfg.Logs.Patterns = PatternsConfig{Enabled: true, SimilarityThreshold: 0.5, MaxPatterns: 10}
+		cfg.Logs.ErrorLogs = ErrorLogsConfig{Enabled: true, MinSeverity: "ERROR"}
+	})
+	require.Contains(t, ddl, "\tPatternId UInt64 COMMENT '64-bit FNV-1a hash of Pattern' CODEC(ZSTD(1)),\n")
+	require.Contains(t, ddl, "\tPattern LowCardinality(String) COMMENT 'Pattern of Body mined with the Drain algorithm, its variable tokens replaced by <*>' CODEC(ZSTD(1)),\n")
+
+	ld := simpleLogs(0)
+	records := ld.ResourceLogs().At(0).ScopeLogs().At(0).LogRecords()
+	for _, body := range []string{"user alice logged in", "user bob logged in"} {
+		r := records.AppendEmpty()
+		r.Body().SetStr(body)
+		r.SetSeverityNumber(plog.SeverityNumberInfo)
+	}
+	r := records.AppendEmpty()
+	r.Body().SetStr("user carol logged in")
+	r.SetSeverityNumber(plog.SeverityNumberError)
+	mustPushLogsData(t, exporter, ld)
+
+	require.Equal(t, map[string][]driver.Value{
+		"`otel_logs_errors`": {"user carol logged in"},
+		"`otel_logs`":        {"user <*> logged in", "user <*> logged in"},
+	}, patterns, "the error logs, written first, share the patterns")
+}
+
+func TestLogsExporter_updateTTL(t *testing.T) {
+	var queries []string
+	engine := "MergeTree PARTITION BY toDate(TimestampTime) ORDER BY (ServiceName, TimestampTime) TTL TimestampTime + toIntervalDay(3) SETTINGS index_granularity = 8192"
+	initClickhouseTestServerWithRows(t, func(query string, _ []driver.Value) error {
+		queries = append(queries, getQueryFirstLine(query))
+		return nil
+	}, func(query string, values []driver.Value) []string {
+		if !strings.Contains(query, "system.tables") {
+			return nil
+		}
+		require.Equal(t, []driver.Value{"default", "otel_logs"}, values)
+		return []string{engine}
+	})
+	newTestLogsExporter(t, defaultEndpoint, withDriverName(t.Name()), func(cfg *Config) {
+		cfg.TTL = 7 * 24 * time.Hour
+	})
+	require.Equal(t, []string{
+		"CREATE TABLE IF NOT EXISTS `otel_logs`",
+		"ALTER TABLE `otel_logs`  MODIFY TTL TimestampTime + toIntervalDay(7)",
+	}, queries)
+
+	driverName := t.Name()
+	t.Run("unchanged ttl", func(t *testing.T) {
+		queries = nil
+		newTestLogsExporter(t, defaultEndpoint, withDriverName(driverName), func(cfg *Config) {
+			cfg.TTL = 3 * 24 * time.Hour
+		})
+		require.Equal(t, []string{"CREATE TABLE IF NOT EXISTS `otel_logs`"}, queries)
+	})
+	t.Run("removed ttl", func(t *testing.T) {
+		queries = nil
+		newTestLogsExporter(t, defaultEndpoint, withDriverName(driverName), func(cfg *Config) {
+			cfg.TTL = 0
+		})
+		require.Equal(t, []string{
+			"CREATE TABLE IF NOT EXISTS `otel_logs`",
+			"ALTER TABLE `otel_logs`  REMOVE TTL",
+		}, queries)
+	})
+	t.Run("no ttl", func(t *testing.T) {
+		queries = nil
+		engine = "MergeTree PARTITION BY toDate(TimestampTime) ORDER BY (ServiceName, TimestampTime) SETTINGS index_granularity = 8192"
+		newTestLogsExporter(t, defaultEndpoint, withDriverName(driverName), func(cfg *Config) {
+			cfg.TTL = 0
+		})
+		require.Equal(t, []string{"CREATE TABLE IF NOT EXISTS `otel_logs`"}, queries)
+	})
+}
+
+func TestLogsExporter_skipInvalidRows(t *testing.T) {
+	var inserted int
+	initClickhouseTestBatchServer(t, func(query string, values []driver.Value) error {
+		// The second record of simpleLogs fails to bind.
+		if strings.HasPrefix(query, "INSERT") && values[1] == "01020301000000000000000000000000" {
+			return errors.New("cannot convert")
+		}
+		return nil
+	}, func(_ string, rows [][]driver.Value) {
+		inserted += len(rows)
+	})
+	reader := sdkmetric.NewManualReader()
+	telemetry := newTestTelemetry(t, reader)
+	exporter := newTestLogsExporter(t, defaultEndpoint, withDriverName(t.Name()), func(cfg *Config) {
+		cfg.SkipInvalidRows = true
+	})
+	exporter.telemetry = telemetry
+
+	mustPushLogsData(t, exporter, simpleLogs(3))
+	require.Equal(t, 2, inserted)
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	require.Len(t, rm.ScopeMetrics, 1)
+	sum := rm.ScopeMetrics[0].Metrics[0].Data.(metricdata.Sum[int64])
+	require.Equal(t, int64(1), sum.DataPoints[0].Value)
+
+	t.Run("disabled", func(t *testing.T) {
+		exporter.cfg.SkipInvalidRows = false
+		require.ErrorContains(t, exporter.pushLogsData(context.Background(), simpleLogs(3)), "cannot convert")
+	})
+}
+
+func TestLogsExporter_insertTelemetry(t *testing.T) {
+	var fail bool
+	initClickhouseTestServer(t, func(query string, _ []driver.Value) error {
+		if fail && strings.HasPrefix(query, "INSERT") {
+			return errors.New("connection reset")
+		}
+		return nil
+	})
+	reader := sdkmetric.NewManualReader()
+	telemetry := newTestTelemetry(t, reader)
+	exporter := newTestLogsExporter(t, defaultEndpoint, withDriverName(t.Name()))
+	exporter.telemetry = telemetry
+
+	mustPushLogsData(t, exporter, simpleLogs(3))
+	fail = true
+	require.Error(t, exporter.pushLogsData(context.Background(), simpleLogs(1)))
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	sums := map[string]int64{}
+	var durations uint64
+	for _, m := range rm.ScopeMetrics[0].Metrics {
+		switch data := m.Data.(type) {
+		case metricdata.Sum[int64]:
+			for _, dp := range data.DataPoints {
+				table, _ := dp.Attributes.Value("table")
+				require.Equal(t, "otel_logs", table.AsString())
+				sums[m.Name] += dp.Value
+			}
+		case metricdata.Histogram[float64]:
+			for _, dp := range data.DataPoints {
+				durations += dp.Count
+			}
+		}
+	}
+	require.Equal(t, int64(3), sums["otelcol_exporter_clickhouse_inserted_rows"])
+	require.Positive(t, sums["otelcol_exporter_clickhouse_inserted_bytes"])
+	require.Zero(t, sums["otelcol_exporter_clickhouse_failed_rows"], "the failing row is not bound")
+	require.Equal(t, uint64(2), durations)
+}
+
+func TestLogsExporter_insertErrorLogging(t *testing.T) {
+	initClickhouseTestServerWithRows(t, func(query string, _ []driver.Value) error {
+		if strings.HasPrefix(query, "INSERT") {
+			return &clickhouse.Exception{Code: 241, Name: "DB::Exception", Message: "Memory limit exceeded"}
+		}
+		return nil
+	}, func(query string, _ []driver.Value) []string {
+		if query == selectServerSQL {
+			return []string{"clickhouse-01"}
+		}
+		return nil
+	})
+	core, logs := observer.New(zap.WarnLevel)
+	exporter, err := newLogsExporter(zap.New(core), withTestExporterConfig(withDriverName(t.Name()))(defaultEndpoint))
+	require.NoError(t, err)
+	require.NoError(t, exporter.start(context.Background(), nil))
+	t.Cleanup(func() { _ = exporter.shutdown(context.Background()) })
+
+	require.Error(t, exporter.pushLogsData(context.Background(), simpleLogs(1)))
+
+	failed := logs.FilterMessage("insert failed").All()
+	require.Len(t, failed, 1)
+	fields := failed[0].ContextMap()
+	require.Equal(t, "otel_logs", fields["table"])
+	require.Regexp(t, `^otelcol-insert_logs-`, fields["query_id"])
+	require.Equal(t, "clickhouse-01", fields["server_display_name"])
+	require.Equal(t, int32(241), fields["error_code"])
+	require.Equal(t, "DB::Exception", fields["error_name"])
+	require.Equal(t, "Memory limit exceeded", fields["error_message"])
+}
+
+func TestLogsExporter_insertSpans(t *testing.T) {
+	initClickhouseTestServer(t, func(string, []driver.Value) error { return nil })
+	recorder := tracetest.NewSpanRecorder()
+	telemetry := newTestTelemetry(t, sdkmetric.NewManualReader())
+	telemetry.tracer = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer(metadata.ScopeName)
+	exporter := newTestLogsExporter(t, defaultEndpoint, withDriverName(t.Name()))
+	exporter.telemetry = telemetry
+
+	mustPushLogsData(t, exporter, simpleLogs(3))
+
+	spans := recorder.Ended()
+	var names []string
+	for _, span := range spans {
+		names = append(names, span.Name())
+	}
+	require.Equal(t, []string{"prepare", "bind", "commit", "insert otel_logs"}, names)
+	insert := spans[3]
+	require.Equal(t, trace.SpanKindClient, insert.SpanKind())
+	require.Contains(t, insert.Attributes(), attribute.String("db.collection.name", "otel_logs"))
+	require.Contains(t, insert.Attributes(), attribute.Int64("clickhouse.insert.rows", 3))
+	for _, phase := range spans[:3] {
+		require.Equal(t, insert.SpanContext().SpanID(), phase.Parent().SpanID())
+	}
+	require.Equal(t, insert.StartTime(), spans[0].StartTime())
+	require.Equal(t, insert.EndTime(), spans[2].EndTime())
+}
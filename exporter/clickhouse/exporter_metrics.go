@@ -19,42 +19,166 @@ type metricsExporter struct {
 	client *sql.DB
 
 	logger       *zap.Logger
+	telemetry    *exporterTelemetry
+	startup      startupRunner
+	health       healthReporter
+	server       serverInfo
+	debug        *debugStats
 	cfg          *Config
 	tablesConfig internal.MetricTablesConfigMapper
+	// cumulative keeps the running totals of delta sums if converted to cumulative.
+	cumulative *internal.DeltaToCumulative
+	// metadata writes the metrics metadata table if enabled.
+	metadata *internal.MetricsMetadata
+	// cardinality limits the series per metric if enabled.
+	cardinality *internal.CardinalityLimiter
+	// dimensions writes the resources and scopes into the dimension tables if enabled.
+	dimensions *internal.Dimensions
 }
 
 func newMetricsExporter(logger *zap.Logger, cfg *Config) (*metricsExporter, error) {
+	cfg = cfg.exporterConfig(cfg.MetricsDatabase)
 	client, err := newClickhouseClient(cfg)
 	if err != nil {
 		return nil, err
 	}
 
 	tablesConfig := generateMetricTablesConfigMapper(cfg)
+	var cumulative *internal.DeltaToCumulative
+	if cfg.Metrics.DeltaToCumulative.Enabled {
+		cumulative = internal.NewDeltaToCumulative(cfg.Metrics.DeltaToCumulative.MaxStale)
+	}
+	var metadata *internal.MetricsMetadata
+	if cfg.Metrics.Metadata.Enabled {
+		metadata = internal.NewMetricsMetadata(cfg.metadataTableName(), cfg.Metrics.DeltaToCumulative.Enabled)
+	}
+
+	var cardinality *internal.CardinalityLimiter
+	if limit := cfg.Metrics.CardinalityLimit; limit.Enabled {
+		cardinality = internal.NewCardinalityLimiter(limit.MaxSeriesPerMetric, limit.MaxStale, internal.CardinalityAction(limit.Action))
+	}
 
 	return &metricsExporter{
 		client:       client,
 		logger:       logger,
 		cfg:          cfg,
 		tablesConfig: tablesConfig,
+		cumulative:   cumulative,
+		metadata:     metadata,
+		cardinality:  cardinality,
+		dimensions:   newDimensions(cfg),
 	}, nil
 }
 
-func (e *metricsExporter) start(ctx context.Context, _ component.Host) error {
+func (e *metricsExporter) start(ctx context.Context, host component.Host) error {
+	if err := e.startup.start(ctx, host, e.logger, e.cfg, func(ctx context.Context) error {
+		return e.setup(ctx, host)
+	}); err != nil {
+		return err
+	}
+	if err := e.debug.register(e.logger); err != nil {
+		return err
+	}
+	e.health.start(host, e.logger, e.cfg.HealthCheckInterval, e.cfg.TimeoutSettings.Timeout, e.client.PingContext)
+	return nil
+}
+
+// setup creates the schema of the exporter.
+func (e *metricsExporter) setup(ctx context.Context, host component.Host) error {
+	ctx = e.cfg.queryContext(ctx)
+	e.server.detect(ctx, e.logger, e.client)
+	if err := adaptSchema(ctx, e.logger, e.cfg, e.client, &e.server, e.cfg.Metrics.SignalConfig); err != nil {
+		return err
+	}
 	internal.SetLogger(e.logger)
 
-	if !e.cfg.shouldCreateSchema() {
-		return nil
+	objects := e.cfg.schemaObjectsFor(e.cfg.Metrics.SignalConfig)
+	if objects.Database {
+		if err := createDatabase(ctx, e.cfg, e.cfg.clusterStringFor(e.cfg.Metrics.SignalConfig)); err != nil {
+			return err
+		}
+	}
+
+	settings := e.cfg.metricsSettings()
+	insertTables := e.tablesConfig.Tables()
+	if settings.ExemplarsMode == internal.ExemplarsModeSeparateTable {
+		insertTables = append(insertTables, settings.ExemplarsTableName)
+	}
+	if e.metadata != nil {
+		insertTables = append(insertTables, e.cfg.metadataTableName())
+	}
+	verifyStart(ctx, host, e.logger, e.cfg, e.client, e.cfg.Metrics.SignalConfig, insertTables)
+
+	if objects.Tables {
+		ttlExpr := func(table string) string {
+			return e.cfg.ttlExprFor(table, e.cfg.TTL, "toDateTime(TimeUnix)")
+		}
+		if err := internal.NewMetricsTable(ctx, e.tablesConfig, settings, e.cfg.clusterStringFor(e.cfg.Metrics.SignalConfig), e.cfg.tableEngineStringFor(e.cfg.Metrics.SignalConfig), ttlExpr, e.client); err != nil {
+			return err
+		}
+		for _, table := range e.tablesConfig.Tables() {
+			if err := updateTTL(ctx, e.cfg, e.client, e.cfg.Metrics.SignalConfig, table, ttlExpr(table)); err != nil {
+				return err
+			}
+		}
+		if settings.ExemplarsMode == internal.ExemplarsModeSeparateTable {
+			if err := updateTTL(ctx, e.cfg, e.client, e.cfg.Metrics.SignalConfig, settings.ExemplarsTableName, settings.ExemplarsTTLExpr); err != nil {
+				return err
+			}
+		}
+		if rollups := e.cfg.rollups(); len(rollups) != 0 {
+			engine, _ := e.cfg.mergeTreeVariantFor(e.cfg.Metrics.SignalConfig, "Aggregating")
+			rollupsTTLExpr := generateTTLExpr(e.cfg.rollupsTTL(), "TimeUnix")
+			if err := internal.NewRollupTables(ctx, rollups, settings, e.cfg.Database, e.cfg.clusterStringFor(e.cfg.Metrics.SignalConfig), engine, rollupsTTLExpr, e.client); err != nil {
+				return err
+			}
+			for _, rollup := range rollups {
+				if err := updateTTL(ctx, e.cfg, e.client, e.cfg.Metrics.SignalConfig, rollup.Table, rollupsTTLExpr); err != nil {
+					return err
+				}
+			}
+		}
+		if rates, ok := e.cfg.counterRates(); ok {
+			engine, _ := e.cfg.mergeTreeVariantFor(e.cfg.Metrics.SignalConfig, "Aggregating")
+			ratesTTLExpr := generateTTLExpr(e.cfg.counterRatesTTL(), "TimeUnix")
+			if err := rates.Create(ctx, settings, e.cfg.Database, e.cfg.clusterStringFor(e.cfg.Metrics.SignalConfig), engine, ratesTTLExpr, e.client); err != nil {
+				return err
+			}
+			if err := updateTTL(ctx, e.cfg, e.client, e.cfg.Metrics.SignalConfig, rates.Table, ratesTTLExpr); err != nil {
+				return err
+			}
+		}
+		if e.metadata != nil {
+			metadataTTLExpr := generateTTLExpr(e.cfg.TTL, "LastSeen")
+			engine := e.cfg.replacingTableEngineStringFor(e.cfg.Metrics.SignalConfig, "LastSeen")
+			if err := e.metadata.CreateTable(ctx, settings, e.cfg.clusterStringFor(e.cfg.Metrics.SignalConfig), engine, metadataTTLExpr, e.client); err != nil {
+				return err
+			}
+			if err := updateTTL(ctx, e.cfg, e.client, e.cfg.Metrics.SignalConfig, e.cfg.metadataTableName(), metadataTTLExpr); err != nil {
+				return err
+			}
+		}
 	}
 
-	if err := createDatabase(ctx, e.cfg); err != nil {
+	if err := createDimensions(ctx, e.cfg, e.client, e.cfg.Metrics.SignalConfig); err != nil {
 		return err
 	}
 
-	ttlExpr := generateTTLExpr(e.cfg.TTL, "toDateTime(TimeUnix)")
-	return internal.NewMetricsTable(ctx, e.tablesConfig, e.cfg.clusterString(), e.cfg.tableEngineString(), ttlExpr, e.client)
+	tables := append([]string{settings.ExemplarsTableName}, e.tablesConfig.Tables()...)
+	return addProjections(ctx, e.cfg, e.client, e.cfg.Metrics.SignalConfig, tables...)
 }
 
 func generateMetricTablesConfigMapper(cfg *Config) internal.MetricTablesConfigMapper {
+	if table := cfg.unifiedMetricsTableName(); table != "" {
+		unified := internal.MetricTypeConfig{Name: table}
+		return internal.MetricTablesConfigMapper{
+			pmetric.MetricTypeGauge:                unified,
+			pmetric.MetricTypeSum:                  unified,
+			pmetric.MetricTypeSummary:              unified,
+			pmetric.MetricTypeHistogram:            unified,
+			pmetric.MetricTypeExponentialHistogram: unified,
+		}
+	}
 	return internal.MetricTablesConfigMapper{
 		pmetric.MetricTypeGauge:                cfg.MetricsTables.Gauge,
 		pmetric.MetricTypeSum:                  cfg.MetricsTables.Sum,
@@ -66,6 +190,9 @@ func generateMetricTablesConfigMapper(cfg *Config) internal.MetricTablesConfigMa
 
 // shutdown will shut down the exporter.
 func (e *metricsExporter) shutdown(_ context.Context) error {
+	e.health.stop()
+	e.startup.stop()
+	e.debug.unregister()
 	if e.client != nil {
 		return e.client.Close()
 	}
@@ -73,7 +200,21 @@ func (e *metricsExporter) shutdown(_ context.Context) error {
 }
 
 func (e *metricsExporter) pushMetricsData(ctx context.Context, md pmetric.Metrics) error {
-	metricsMap := internal.NewMetricsModel(e.tablesConfig)
+	if err := e.startup.ready(); err != nil {
+		return err
+	}
+	ctx, reportSkipped := e.telemetry.skipInvalidRows(ctx, e.cfg, e.logger, "metrics")
+	defer reportSkipped()
+	ctx = e.telemetry.observeInserts(ctx, e.logger, &e.server, e.debug)
+	ctx = e.cfg.queryContext(ctx)
+	settings := e.cfg.metricsSettings()
+	settings.DeltaToCumulative = e.cumulative
+	if settings.TenantColumn != "" {
+		settings.Tenant = e.cfg.tenant(ctx)
+	}
+	metricsMap := internal.NewMetricsModel(e.tablesConfig, settings)
+	md, limits := e.cardinality.Limit(md)
+	e.telemetry.recordCardinalityLimits(ctx, e.logger, e.cfg.Metrics.CardinalityLimit, limits)
 	for i := 0; i < md.ResourceMetrics().Len(); i++ {
 		metrics := md.ResourceMetrics().At(i)
 		resAttr := metrics.Resource().Attributes()
@@ -91,10 +232,30 @@ func (e *metricsExporter) pushMetricsData(ctx context.Context, md pmetric.Metric
 				case pmetric.MetricTypeSum:
 					errs = errors.Join(errs, metricsMap[pmetric.MetricTypeSum].Add(resAttr, metrics.SchemaUrl(), scopeInstr, scopeURL, r.Sum(), r.Name(), r.Description(), r.Unit()))
 				case pmetric.MetricTypeHistogram:
+					if e.cfg.Metrics.HistogramMode == histogramModeBuckets {
+						buckets, sum, count := internal.HistogramSums(r.Histogram())
+						sums := metricsMap[pmetric.MetricTypeSum]
+						errs = errors.Join(errs,
+							sums.Add(resAttr, metrics.SchemaUrl(), scopeInstr, scopeURL, buckets, r.Name()+"_bucket", r.Description(), ""),
+							sums.Add(resAttr, metrics.SchemaUrl(), scopeInstr, scopeURL, sum, r.Name()+"_sum", r.Description(), r.Unit()),
+							sums.Add(resAttr, metrics.SchemaUrl(), scopeInstr, scopeURL, count, r.Name()+"_count", r.Description(), ""),
+						)
+						break
+					}
 					errs = errors.Join(errs, metricsMap[pmetric.MetricTypeHistogram].Add(resAttr, metrics.SchemaUrl(), scopeInstr, scopeURL, r.Histogram(), r.Name(), r.Description(), r.Unit()))
 				case pmetric.MetricTypeExponentialHistogram:
 					errs = errors.Join(errs, metricsMap[pmetric.MetricTypeExponentialHistogram].Add(resAttr, metrics.SchemaUrl(), scopeInstr, scopeURL, r.ExponentialHistogram(), r.Name(), r.Description(), r.Unit()))
 				case pmetric.MetricTypeSummary:
+					if e.cfg.Metrics.SummaryMode == summaryModeGauges {
+						quantiles, sum, count := internal.SummaryGauges(r.Summary())
+						gauges := metricsMap[pmetric.MetricTypeGauge]
+						errs = errors.Join(errs,
+							gauges.Add(resAttr, metrics.SchemaUrl(), scopeInstr, scopeURL, quantiles, r.Name(), r.Description(), r.Unit()),
+							gauges.Add(resAttr, metrics.SchemaUrl(), scopeInstr, scopeURL, sum, r.Name()+"_sum", r.Description(), r.Unit()),
+							gauges.Add(resAttr, metrics.SchemaUrl(), scopeInstr, scopeURL, count, r.Name()+"_count", r.Description(), ""),
+						)
+						break
+					}
 					errs = errors.Join(errs, metricsMap[pmetric.MetricTypeSummary].Add(resAttr, metrics.SchemaUrl(), scopeInstr, scopeURL, r.Summary(), r.Name(), r.Description(), r.Unit()))
 				case pmetric.MetricTypeEmpty:
 					return errors.New("metrics type is unset")
@@ -107,6 +268,20 @@ func (e *metricsExporter) pushMetricsData(ctx context.Context, md pmetric.Metric
 			}
 		}
 	}
+	// the resources and scopes are written first, the datapoints referencing them by fingerprint.
+	if e.dimensions != nil {
+		if err := e.dimensions.Write(ctx, e.client, metricsDimensions(md, e.cfg.attributeFilter(e.cfg.Metrics.SignalConfig))); err != nil {
+			return err
+		}
+	}
 	// batch insert https://clickhouse.com/docs/en/about-us/performance/#performance-when-inserting-data
-	return internal.InsertMetrics(ctx, e.client, metricsMap)
+	if err := internal.InsertMetrics(ctx, e.client, metricsMap); err != nil {
+		return err
+	}
+	e.telemetry.recordTruncatedAttributeValues(ctx, "metrics", settings.Attributes)
+	// the datapoints are written, failing the batch would only insert them again.
+	if err := e.metadata.Write(ctx, e.client, md); err != nil {
+		e.logger.Warn("failed to write metrics metadata", zap.Error(err))
+	}
+	return nil
 }
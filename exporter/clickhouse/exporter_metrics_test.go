@@ -8,6 +8,7 @@ import (
 	"database/sql/driver"
 	"errors"
 	"fmt"
+	"math"
 	"strings"
 	"sync/atomic"
 	"testing"
@@ -99,31 +100,31 @@ func TestExporter_pushMetricsData(t *testing.T) {
 		initClickhouseTestServer(t, func(query string, values []driver.Value) error {
 			if strings.HasPrefix(query, "INSERT") {
 				items.Add(1)
-				if strings.HasPrefix(query, "INSERT INTO otel_metrics_exponential_histogram") {
+				if strings.HasPrefix(query, "INSERT INTO `otel_metrics_exponential_histogram`") {
 					idx := itemIdxs["otel_metrics_exponential_histogram"]
 					require.Equal(t, fmt.Sprintf("Resource SchemaUrl %d", resourceSchemaIdx[idx.Load()]), values[1])
 					require.Equal(t, fmt.Sprintf("Scope name %d", scopeNameIdx[idx.Load()]), values[2])
 					idx.Add(1)
 				}
-				if strings.HasPrefix(query, "INSERT INTO otel_metrics_gauge") {
+				if strings.HasPrefix(query, "INSERT INTO `otel_metrics_gauge`") {
 					idx := itemIdxs["otel_metrics_gauge"]
 					require.Equal(t, fmt.Sprintf("Resource SchemaUrl %d", resourceSchemaIdx[idx.Load()]), values[1])
 					require.Equal(t, fmt.Sprintf("Scope name %d", scopeNameIdx[idx.Load()]), values[2])
 					idx.Add(1)
 				}
-				if strings.HasPrefix(query, "INSERT INTO otel_metrics_histogram") {
+				if strings.HasPrefix(query, "INSERT INTO `otel_metrics_histogram`") {
 					idx := itemIdxs["otel_metrics_histogram"]
 					require.Equal(t, fmt.Sprintf("Resource SchemaUrl %d", resourceSchemaIdx[idx.Load()]), values[1])
 					require.Equal(t, fmt.Sprintf("Scope name %d", scopeNameIdx[idx.Load()]), values[2])
 					idx.Add(1)
 				}
-				if strings.HasPrefix(query, "INSERT INTO otel_metrics_sum (") {
+				if strings.HasPrefix(query, "INSERT INTO `otel_metrics_sum` (") {
 					idx := itemIdxs["otel_metrics_sum"]
 					require.Equal(t, fmt.Sprintf("Resource SchemaUrl %d", resourceSchemaIdx[idx.Load()]), values[1])
 					require.Equal(t, fmt.Sprintf("Scope name %d", scopeNameIdx[idx.Load()]), values[2])
 					idx.Add(1)
 				}
-				if strings.HasPrefix(query, "INSERT INTO otel_metrics_summary") {
+				if strings.HasPrefix(query, "INSERT INTO `otel_metrics_summary`") {
 					idx := itemIdxs["otel_metrics_summary"]
 					require.Equal(t, fmt.Sprintf("Resource SchemaUrl %d", resourceSchemaIdx[idx.Load()]), values[1])
 					require.Equal(t, fmt.Sprintf("Scope name %d", scopeNameIdx[idx.Load()]), values[2])
@@ -139,19 +140,19 @@ func TestExporter_pushMetricsData(t *testing.T) {
 	})
 	t.Run("check traceID and spanID", func(t *testing.T) {
 		initClickhouseTestServer(t, func(query string, values []driver.Value) error {
-			if strings.HasPrefix(query, "INSERT INTO otel_metrics_gauge") {
+			if strings.HasPrefix(query, "INSERT INTO `otel_metrics_gauge`") {
 				require.Equal(t, clickhouse.ArraySet{"0102030000000000"}, values[19])
 				require.Equal(t, clickhouse.ArraySet{"01020300000000000000000000000000"}, values[20])
 			}
-			if strings.HasPrefix(query, "INSERT INTO otel_metrics_histogram") {
+			if strings.HasPrefix(query, "INSERT INTO `otel_metrics_histogram`") {
 				require.Equal(t, clickhouse.ArraySet{"0102030000000000"}, values[21])
 				require.Equal(t, clickhouse.ArraySet{"01020300000000000000000000000000"}, values[22])
 			}
-			if strings.HasPrefix(query, "INSERT INTO otel_metrics_sum ") {
+			if strings.HasPrefix(query, "INSERT INTO `otel_metrics_sum` ") {
 				require.Equal(t, clickhouse.ArraySet{"0102030000000000"}, values[19])
 				require.Equal(t, clickhouse.ArraySet{"01020300000000000000000000000000"}, values[20])
 			}
-			if strings.HasPrefix(query, "INSERT INTO otel_metrics_exponential_histogram") {
+			if strings.HasPrefix(query, "INSERT INTO `otel_metrics_exponential_histogram`") {
 				require.Equal(t, clickhouse.ArraySet{"0102030000000000"}, values[25])
 				require.Equal(t, clickhouse.ArraySet{"01020300000000000000000000000000"}, values[26])
 			}
@@ -160,6 +161,526 @@ func TestExporter_pushMetricsData(t *testing.T) {
 		exporter := newTestMetricsExporter(t, defaultEndpoint, withDriverName(t.Name()))
 		mustPushMetricsData(t, exporter, simpleMetrics(1))
 	})
+	t.Run("exemplars in separate table", func(t *testing.T) {
+		var exemplars atomic.Int32
+		initClickhouseTestServer(t, func(query string, values []driver.Value) error {
+			if strings.HasPrefix(query, "INSERT INTO `otel_metrics_gauge`") {
+				require.NotContains(t, query, "Exemplars")
+				require.Len(t, values, 16)
+			}
+			if strings.HasPrefix(query, "INSERT INTO `otel_metrics_exemplars`") {
+				require.Equal(t, "0102030000000000", values[8])
+				require.Equal(t, "01020300000000000000000000000000", values[9])
+				exemplars.Add(1)
+			}
+			return nil
+		})
+		exporter := newTestMetricsExporter(t, defaultEndpoint, withDriverName(t.Name()), func(cfg *Config) {
+			cfg.Metrics.Exemplars.Mode = string(internal.ExemplarsModeSeparateTable)
+		})
+		mustPushMetricsData(t, exporter, simpleMetrics(1))
+
+		require.Equal(t, int32(12), exemplars.Load())
+	})
+	t.Run("separate table exemplars skipping invalid rows", func(t *testing.T) {
+		var exemplars [][]driver.Value
+		initClickhouseTestBatchServer(t, func(query string, values []driver.Value) error {
+			if strings.HasPrefix(query, "INSERT INTO `otel_metrics_sum` ") && values[8] == "invalid" {
+				return errors.New("cannot convert")
+			}
+			return nil
+		}, func(query string, rows [][]driver.Value) {
+			if strings.HasPrefix(query, "INSERT INTO `otel_metrics_exemplars`") {
+				exemplars = append(exemplars, rows...)
+			}
+		})
+		exporter := newTestMetricsExporter(t, defaultEndpoint, withDriverName(t.Name()), func(cfg *Config) {
+			cfg.Metrics.Exemplars.Mode = string(internal.ExemplarsModeSeparateTable)
+			cfg.SkipInvalidRows = true
+		})
+		md := deltaSums(
+			deltaSumPoint{"invalid", 0, 10, 5},
+			deltaSumPoint{"requests", 0, 10, 1},
+		)
+		metrics := md.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics()
+		for i := range metrics.Len() {
+			metrics.At(i).Sum().DataPoints().At(0).Exemplars().AppendEmpty().SetIntValue(1)
+		}
+		mustPushMetricsData(t, exporter, md)
+
+		require.Len(t, exemplars, 1, "the exemplars are written once, without those of the skipped rows")
+		require.Equal(t, "requests", exemplars[0][1])
+	})
+	t.Run("max exemplars per datapoint", func(t *testing.T) {
+		var times []driver.Value
+		initClickhouseTestServer(t, func(query string, values []driver.Value) error {
+			if strings.HasPrefix(query, "INSERT INTO `otel_metrics_gauge`") {
+				times = append(times, values[17])
+			}
+			return nil
+		})
+		exporter := newTestMetricsExporter(t, defaultEndpoint, withDriverName(t.Name()), func(cfg *Config) {
+			cfg.Metrics.Exemplars.MaxPerDataPoint = 2
+		})
+		md := pmetric.NewMetrics()
+		dps := md.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics().AppendEmpty().SetEmptyGauge().DataPoints()
+		dp := dps.AppendEmpty()
+		for _, ts := range []int64{30, 10, 20} {
+			dp.Exemplars().AppendEmpty().SetTimestamp(pcommon.Timestamp(ts))
+		}
+		dps.AppendEmpty().Exemplars().AppendEmpty().SetTimestamp(40)
+		mustPushMetricsData(t, exporter, md)
+
+		require.Equal(t, []driver.Value{
+			clickhouse.ArraySet{time.Unix(0, 20).UTC(), time.Unix(0, 30).UTC()},
+			clickhouse.ArraySet{time.Unix(0, 40).UTC()},
+		}, times, "the latest exemplars are kept in time order")
+	})
+	t.Run("drop exemplars", func(t *testing.T) {
+		initClickhouseTestServer(t, func(query string, values []driver.Value) error {
+			require.NotContains(t, query, "exemplars")
+			if strings.HasPrefix(query, "INSERT INTO `otel_metrics_sum` ") {
+				require.NotContains(t, query, "Exemplars")
+				require.Len(t, values, 18)
+			}
+			return nil
+		})
+		exporter := newTestMetricsExporter(t, defaultEndpoint, withDriverName(t.Name()), func(cfg *Config) {
+			cfg.Metrics.Exemplars.Mode = string(internal.ExemplarsModeDrop)
+		})
+		mustPushMetricsData(t, exporter, simpleMetrics(1))
+	})
+	t.Run("delta sums to cumulative", func(t *testing.T) {
+		var rows [][]driver.Value
+		initClickhouseTestServer(t, func(query string, values []driver.Value) error {
+			if strings.HasPrefix(query, "INSERT INTO `otel_metrics_sum` ") {
+				rows = append(rows, values)
+			}
+			return nil
+		})
+		exporter := newTestMetricsExporter(t, defaultEndpoint, withDriverName(t.Name()), func(cfg *Config) {
+			cfg.Metrics.Exemplars.Mode = string(internal.ExemplarsModeDrop)
+			cfg.Metrics.DeltaToCumulative.Enabled = true
+		})
+		deltaSum := func(start, end int64, value int64) pmetric.Metrics {
+			md := pmetric.NewMetrics()
+			m := md.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics().AppendEmpty()
+			m.SetName("requests")
+			sum := m.SetEmptySum()
+			sum.SetAggregationTemporality(pmetric.AggregationTemporalityDelta)
+			sum.SetIsMonotonic(true)
+			dp := sum.DataPoints().AppendEmpty()
+			dp.SetStartTimestamp(pcommon.Timestamp(start))
+			dp.SetTimestamp(pcommon.Timestamp(end))
+			dp.SetIntValue(value)
+			return md
+		}
+		mustPushMetricsData(t, exporter, deltaSum(10, 20, 2))
+		mustPushMetricsData(t, exporter, deltaSum(20, 30, 3))
+		mustPushMetricsData(t, exporter, deltaSum(20, 30, 3))
+		mustPushMetricsData(t, exporter, deltaSum(15, 25, 7))
+
+		require.Len(t, rows, 3, "the datapoint older than the series is dropped")
+		for i, want := range []float64{2, 5, 5} {
+			require.Equal(t, time.Unix(0, 10).UTC(), rows[i][12].(time.Time).UTC())
+			require.Equal(t, want, rows[i][14])
+			require.Equal(t, int32(pmetric.AggregationTemporalityCumulative), rows[i][16])
+		}
+	})
+	t.Run("delta sums to cumulative skipping invalid rows", func(t *testing.T) {
+		var values []driver.Value
+		initClickhouseTestBatchServer(t, func(query string, values []driver.Value) error {
+			if strings.HasPrefix(query, "INSERT INTO `otel_metrics_sum` ") && values[8] == "invalid" {
+				return errors.New("cannot convert")
+			}
+			return nil
+		}, func(query string, rows [][]driver.Value) {
+			if strings.HasPrefix(query, "INSERT INTO `otel_metrics_sum` ") {
+				for _, row := range rows {
+					values = append(values, row[14])
+				}
+			}
+		})
+		exporter := newTestMetricsExporter(t, defaultEndpoint, withDriverName(t.Name()), func(cfg *Config) {
+			cfg.Metrics.Exemplars.Mode = string(internal.ExemplarsModeDrop)
+			cfg.Metrics.DeltaToCumulative.Enabled = true
+			cfg.SkipInvalidRows = true
+		})
+		mustPushMetricsData(t, exporter, deltaSums(
+			deltaSumPoint{"requests", 0, 10, 1},
+			deltaSumPoint{"invalid", 0, 10, 5},
+			deltaSumPoint{"requests", 10, 20, 2},
+		))
+		require.Equal(t, []driver.Value{1.0, 3.0}, values, "the valid datapoints are converted once")
+	})
+	t.Run("delta sums to cumulative retried", func(t *testing.T) {
+		var (
+			values []driver.Value
+			binds  int
+		)
+		initClickhouseTestBatchServer(t, func(query string, _ []driver.Value) error {
+			if strings.HasPrefix(query, "INSERT INTO `otel_metrics_sum` ") {
+				if binds++; binds == 3 {
+					return errors.New("connection reset")
+				}
+			}
+			return nil
+		}, func(query string, rows [][]driver.Value) {
+			if strings.HasPrefix(query, "INSERT INTO `otel_metrics_sum` ") {
+				for _, row := range rows {
+					values = append(values, row[14])
+				}
+			}
+		})
+		exporter := newTestMetricsExporter(t, defaultEndpoint, withDriverName(t.Name()), func(cfg *Config) {
+			cfg.Metrics.Exemplars.Mode = string(internal.ExemplarsModeDrop)
+			cfg.Metrics.DeltaToCumulative.Enabled = true
+		})
+		md := deltaSums(
+			deltaSumPoint{"requests", 0, 10, 1},
+			deltaSumPoint{"requests", 10, 20, 2},
+			deltaSumPoint{"requests", 20, 30, 3},
+		)
+		require.Error(t, exporter.pushMetricsData(context.Background(), md))
+		require.Empty(t, values)
+		mustPushMetricsData(t, exporter, md)
+		require.Equal(t, []driver.Value{1.0, 3.0, 6.0}, values, "the failed insert doesn't advance the series")
+	})
+	t.Run("non-finite values", func(t *testing.T) {
+		var nullable bool
+		var values []driver.Value
+		initClickhouseTestServer(t, func(query string, args []driver.Value) error {
+			if strings.Contains(query, "CREATE TABLE IF NOT EXISTS `otel_metrics_gauge`") {
+				nullable = strings.Contains(query, "Value Nullable(Float64)")
+			}
+			if strings.HasPrefix(query, "INSERT INTO `otel_metrics_gauge`") {
+				values = append(values, args[14])
+			}
+			return nil
+		})
+		gauge := pmetric.NewMetrics()
+		dps := gauge.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics().AppendEmpty().SetEmptyGauge().DataPoints()
+		dps.AppendEmpty().SetDoubleValue(math.NaN())
+		dps.AppendEmpty().SetDoubleValue(math.Inf(-1))
+		dps.AppendEmpty().SetDoubleValue(1)
+
+		for _, tt := range []struct {
+			policy   internal.NonFinitePolicy
+			nullable bool
+			values   []driver.Value
+		}{
+			{policy: internal.NonFiniteDrop, values: []driver.Value{1.0}},
+			{policy: internal.NonFiniteClamp, values: []driver.Value{0.0, -math.MaxFloat64, 1.0}},
+			{policy: internal.NonFiniteNull, nullable: true, values: []driver.Value{nil, nil, 1.0}},
+		} {
+			values = nil
+			exporter := newTestMetricsExporter(t, defaultEndpoint, withDriverName(t.Name()), func(cfg *Config) {
+				cfg.Metrics.NonFiniteValues = string(tt.policy)
+			})
+			mustPushMetricsData(t, exporter, gauge)
+			require.Equal(t, tt.nullable, nullable, tt.policy)
+			require.Equal(t, tt.values, values, tt.policy)
+		}
+	})
+	t.Run("stale datapoints", func(t *testing.T) {
+		var ddl string
+		var rows [][]driver.Value
+		initClickhouseTestServer(t, func(query string, args []driver.Value) error {
+			if strings.Contains(query, "CREATE TABLE IF NOT EXISTS `otel_metrics_gauge`") {
+				ddl = query
+			}
+			if strings.HasPrefix(query, "INSERT INTO `otel_metrics_gauge`") {
+				rows = append(rows, args)
+			}
+			return nil
+		})
+		gauge := pmetric.NewMetrics()
+		dps := gauge.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics().AppendEmpty().SetEmptyGauge().DataPoints()
+		stale := dps.AppendEmpty()
+		stale.SetDoubleValue(0)
+		stale.SetFlags(pmetric.DefaultDataPointFlags.WithNoRecordedValue(true))
+		dps.AppendEmpty().SetDoubleValue(0)
+
+		for _, tt := range []struct {
+			policy internal.StalenessPolicy
+			column string
+			values []driver.Value
+			stale  []driver.Value
+		}{
+			{policy: internal.StaleKeep, values: []driver.Value{0.0, 0.0}},
+			{policy: internal.StaleDrop, values: []driver.Value{0.0}},
+			{policy: internal.StaleNull, column: "Value Nullable(Float64)", values: []driver.Value{nil, 0.0}},
+			{policy: internal.StaleColumn, column: "IsStale Bool", values: []driver.Value{0.0, 0.0}, stale: []driver.Value{true, false}},
+		} {
+			rows = nil
+			exporter := newTestMetricsExporter(t, defaultEndpoint, withDriverName(t.Name()), func(cfg *Config) {
+				cfg.Metrics.StaleDataPoints = string(tt.policy)
+			})
+			mustPushMetricsData(t, exporter, gauge)
+			if tt.column != "" {
+				require.Contains(t, ddl, tt.column, tt.policy)
+			}
+			var values, isStale []driver.Value
+			for _, row := range rows {
+				values = append(values, row[14])
+				if tt.policy == internal.StaleColumn {
+					isStale = append(isStale, row[len(row)-1])
+				}
+			}
+			require.Equal(t, tt.values, values, tt.policy)
+			require.Equal(t, tt.stale, isStale, tt.policy)
+		}
+	})
+	t.Run("exponential histogram max buckets", func(t *testing.T) {
+		var buckets []driver.Value
+		initClickhouseTestServer(t, func(query string, values []driver.Value) error {
+			if strings.HasPrefix(query, "INSERT INTO `otel_metrics_exponential_histogram`") {
+				buckets = values[16:22]
+			}
+			return nil
+		})
+		metrics := pmetric.NewMetrics()
+		dp := metrics.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics().AppendEmpty().SetEmptyExponentialHistogram().DataPoints().AppendEmpty()
+		dp.SetScale(20)
+		dp.Positive().SetOffset(4)
+		dp.Positive().BucketCounts().FromRaw([]uint64{1, 2, 3, 4, 5, 6, 7, 8})
+		exporter := newTestMetricsExporter(t, defaultEndpoint, withDriverName(t.Name()), func(cfg *Config) {
+			cfg.Metrics.ExponentialHistogramMaxBuckets = 2
+		})
+		mustPushMetricsData(t, exporter, metrics)
+
+		require.Equal(t, []driver.Value{int32(18), uint64(0), int32(1), clickhouse.ArraySet{uint64(10), uint64(26)}, int32(0), clickhouse.ArraySet(nil)}, buckets)
+	})
+	t.Run("summaries as gauges", func(t *testing.T) {
+		var summaryRows int
+		gauges := map[string][]driver.Value{}
+		initClickhouseTestServer(t, func(query string, values []driver.Value) error {
+			if strings.HasPrefix(query, "INSERT INTO `otel_metrics_summary`") {
+				summaryRows++
+			}
+			if strings.HasPrefix(query, "INSERT INTO `otel_metrics_gauge`") {
+				name := values[8].(string)
+				gauges[name] = append(gauges[name], values[14])
+				if name == "latency" {
+					require.Contains(t, values[11], `"quantile":"0.99"`)
+				}
+			}
+			return nil
+		})
+		metrics := pmetric.NewMetrics()
+		m := metrics.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics().AppendEmpty()
+		m.SetName("latency")
+		dp := m.SetEmptySummary().DataPoints().AppendEmpty()
+		dp.SetCount(3)
+		dp.SetSum(1.5)
+		quantile := dp.QuantileValues().AppendEmpty()
+		quantile.SetQuantile(0.99)
+		quantile.SetValue(0.9)
+		exporter := newTestMetricsExporter(t, defaultEndpoint, withDriverName(t.Name()), func(cfg *Config) {
+			cfg.Metrics.SummaryMode = summaryModeGauges
+		})
+		mustPushMetricsData(t, exporter, metrics)
+
+		require.Zero(t, summaryRows)
+		require.Equal(t, map[string][]driver.Value{
+			"latency":       {0.9},
+			"latency_sum":   {1.5},
+			"latency_count": {3.0},
+		}, gauges)
+	})
+	t.Run("histograms as buckets", func(t *testing.T) {
+		var histogramRows int
+		sums := map[string][]driver.Value{}
+		initClickhouseTestServer(t, func(query string, values []driver.Value) error {
+			if strings.HasPrefix(query, "INSERT INTO `otel_metrics_histogram`") {
+				histogramRows++
+			}
+			if strings.HasPrefix(query, "INSERT INTO `otel_metrics_sum`") {
+				name := values[8].(string)
+				sums[name] = append(sums[name], values[14])
+			}
+			return nil
+		})
+		metrics := pmetric.NewMetrics()
+		m := metrics.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics().AppendEmpty()
+		m.SetName("latency")
+		histogram := m.SetEmptyHistogram()
+		histogram.SetAggregationTemporality(pmetric.AggregationTemporalityCumulative)
+		dp := histogram.DataPoints().AppendEmpty()
+		dp.SetCount(5)
+		dp.SetSum(2.5)
+		dp.ExplicitBounds().FromRaw([]float64{0.5})
+		dp.BucketCounts().FromRaw([]uint64{2, 3})
+		exporter := newTestMetricsExporter(t, defaultEndpoint, withDriverName(t.Name()), func(cfg *Config) {
+			cfg.Metrics.HistogramMode = histogramModeBuckets
+		})
+		mustPushMetricsData(t, exporter, metrics)
+
+		require.Zero(t, histogramRows)
+		require.Equal(t, map[string][]driver.Value{
+			"latency_bucket": {2.0, 5.0},
+			"latency_sum":    {2.5},
+			"latency_count":  {5.0},
+		}, sums)
+	})
+	t.Run("cardinality limit", func(t *testing.T) {
+		var rows atomic.Int32
+		initClickhouseTestServer(t, func(query string, _ []driver.Value) error {
+			if strings.HasPrefix(query, "INSERT INTO `otel_metrics_gauge`") {
+				rows.Add(1)
+			}
+			return nil
+		})
+		metrics := pmetric.NewMetrics()
+		dps := metrics.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics().AppendEmpty().SetEmptyGauge().DataPoints()
+		for i := range 5 {
+			dps.AppendEmpty().Attributes().PutInt("id", int64(i))
+		}
+		exporter := newTestMetricsExporter(t, defaultEndpoint, withDriverName(t.Name()), func(cfg *Config) {
+			cfg.Metrics.CardinalityLimit.Enabled = true
+			cfg.Metrics.CardinalityLimit.MaxSeriesPerMetric = 2
+		})
+		mustPushMetricsData(t, exporter, metrics)
+
+		require.Equal(t, int32(2), rows.Load())
+		require.Equal(t, 5, metrics.DataPointCount(), "the pushed metrics are not modified")
+	})
+	t.Run("nullable histogram stats", func(t *testing.T) {
+		var nullable bool
+		var stats []driver.Value
+		initClickhouseTestServer(t, func(query string, values []driver.Value) error {
+			if strings.Contains(query, "CREATE TABLE IF NOT EXISTS `otel_metrics_histogram`") {
+				nullable = strings.Contains(query, "Sum Nullable(Float64)") && strings.Contains(query, "Min Nullable(Float64)") && strings.Contains(query, "Max Nullable(Float64)")
+			}
+			if strings.HasPrefix(query, "INSERT INTO `otel_metrics_histogram`") {
+				stats = []driver.Value{values[15], values[19], values[20]}
+			}
+			return nil
+		})
+		exporter := newTestMetricsExporter(t, defaultEndpoint, withDriverName(t.Name()), func(cfg *Config) {
+			cfg.Metrics.Exemplars.Mode = string(internal.ExemplarsModeDrop)
+			cfg.Metrics.NullableHistogramStats = true
+		})
+		require.True(t, nullable)
+
+		md := pmetric.NewMetrics()
+		dp := md.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics().AppendEmpty().SetEmptyHistogram().DataPoints().AppendEmpty()
+		dp.SetCount(2)
+		dp.SetMax(3)
+		mustPushMetricsData(t, exporter, md)
+		require.Equal(t, []driver.Value{nil, nil, 3.0}, stats, "the unset sum and min are NULL")
+	})
+	t.Run("attributes hash", func(t *testing.T) {
+		var sortedTables, hashedRows atomic.Int32
+		initClickhouseTestServer(t, func(query string, values []driver.Value) error {
+			if strings.Contains(query, "ORDER BY (ServiceName, MetricName, AttrHash, Attributes,") {
+				sortedTables.Add(1)
+			}
+			if strings.HasPrefix(query, "INSERT INTO `otel_metrics_") {
+				require.Contains(t, query, "AttrHash) VALUES (")
+				require.Equal(t, strings.Count(query, "?"), len(values))
+				require.IsType(t, uint64(0), values[len(values)-1])
+				hashedRows.Add(1)
+			}
+			return nil
+		})
+		exporter := newTestMetricsExporter(t, defaultEndpoint, withDriverName(t.Name()), func(cfg *Config) {
+			cfg.Metrics.AttributesHash = AttributesHashConfig{Enabled: true, OrderBy: true}
+		})
+		mustPushMetricsData(t, exporter, simpleMetrics(1))
+
+		require.Equal(t, int32(5), sortedTables.Load())
+		require.Equal(t, int32(15), hashedRows.Load())
+	})
+	t.Run("unified schema", func(t *testing.T) {
+		var tables []string
+		rows := map[string]int{}
+		initClickhouseTestServer(t, func(query string, values []driver.Value) error {
+			if strings.Contains(query, "CREATE TABLE IF NOT EXISTS `otel_metrics") {
+				tables = append(tables, query)
+			}
+			if strings.HasPrefix(query, "INSERT INTO `otel_metrics") {
+				require.True(t, strings.HasPrefix(query, "INSERT INTO `otel_metrics` ("))
+				require.Contains(t, query, "MetricType) VALUES (")
+				require.Equal(t, strings.Count(query, "?"), len(values))
+				rows[values[len(values)-1].(string)]++
+			}
+			return nil
+		})
+		exporter := newTestMetricsExporter(t, defaultEndpoint, withDriverName(t.Name()), func(cfg *Config) {
+			cfg.Metrics.Schema = metricsSchemaUnified
+		})
+		mustPushMetricsData(t, exporter, simpleMetrics(1))
+
+		require.Len(t, tables, 1)
+		require.Contains(t, tables[0], "CREATE TABLE IF NOT EXISTS `otel_metrics`")
+		require.Contains(t, tables[0], "MetricType LowCardinality(String)")
+		require.Equal(t, map[string]int{"Gauge": 3, "Sum": 3, "Histogram": 3, "ExponentialHistogram": 3, "Summary": 3}, rows)
+	})
+	t.Run("rollups", func(t *testing.T) {
+		var queries []string
+		initClickhouseTestServer(t, func(query string, _ []driver.Value) error {
+			if strings.Contains(query, "CREATE TABLE IF NOT EXISTS `otel_metrics_gauge_") ||
+				strings.Contains(query, "CREATE MATERIALIZED VIEW") {
+				queries = append(queries, query)
+			}
+			return nil
+		})
+		newTestMetricsExporter(t, defaultEndpoint, withDriverName(t.Name()), func(cfg *Config) {
+			cfg.Metrics.Rollups.Enabled = true
+			cfg.Metrics.Rollups.Intervals = []time.Duration{5 * time.Minute}
+		})
+
+		require.Len(t, queries, 3, "the 5m gauge rollup table and the views of the gauge and sum tables")
+		require.Contains(t, queries[0], "ENGINE = AggregatingMergeTree()")
+		require.Contains(t, queries[1], "CREATE MATERIALIZED VIEW IF NOT EXISTS `otel_metrics_gauge_5m_mv`")
+		require.Contains(t, queries[1], "TO `default`.`otel_metrics_gauge_5m`")
+		require.Contains(t, queries[1], "INTERVAL 300 SECOND")
+		require.Contains(t, queries[1], "FROM `default`.`otel_metrics_gauge`")
+		require.Contains(t, queries[2], "CREATE MATERIALIZED VIEW IF NOT EXISTS `otel_metrics_sum_5m_mv`")
+	})
+	t.Run("counter rates", func(t *testing.T) {
+		var queries []string
+		initClickhouseTestServer(t, func(query string, _ []driver.Value) error {
+			if strings.Contains(query, "`otel_metrics_sum_rate") {
+				queries = append(queries, query)
+			}
+			return nil
+		})
+		newTestMetricsExporter(t, defaultEndpoint, withDriverName(t.Name()), func(cfg *Config) {
+			cfg.Metrics.CounterRates.Enabled = true
+		})
+
+		require.Len(t, queries, 2, "the counter rates table and its view")
+		require.Contains(t, queries[0], "CREATE TABLE IF NOT EXISTS `otel_metrics_sum_rate`")
+		require.Contains(t, queries[0], "ENGINE = AggregatingMergeTree()")
+		require.Contains(t, queries[1], "CREATE MATERIALIZED VIEW IF NOT EXISTS `otel_metrics_sum_rate_mv`")
+		require.Contains(t, queries[1], "FROM `default`.`otel_metrics_sum`")
+		require.Contains(t, queries[1], "WHERE IsMonotonic AND isNotNull(Value)\n")
+	})
+	t.Run("metrics metadata", func(t *testing.T) {
+		var created bool
+		var rows [][]driver.Value
+		initClickhouseTestServer(t, func(query string, values []driver.Value) error {
+			if strings.Contains(query, "CREATE TABLE IF NOT EXISTS `otel_metrics_metadata`") {
+				require.Contains(t, query, "ENGINE = ReplacingMergeTree(LastSeen)")
+				created = true
+			}
+			if strings.HasPrefix(query, "INSERT INTO `otel_metrics_metadata`") {
+				rows = append(rows, values)
+			}
+			return nil
+		})
+		exporter := newTestMetricsExporter(t, defaultEndpoint, withDriverName(t.Name()), func(cfg *Config) {
+			cfg.Metrics.Metadata.Enabled = true
+		})
+		require.True(t, created)
+		mustPushMetricsData(t, exporter, simpleMetrics(1))
+		mustPushMetricsData(t, exporter, simpleMetrics(1))
+
+		require.Len(t, rows, 6, "a row per distinct metric, written once per refresh interval")
+		require.Equal(t, []driver.Value{"gauge metrics", "Gauge", "count", "This is a gauge metrics", int32(0), false}, rows[0][:6])
+	})
 }
 
 func Benchmark_pushMetricsData(b *testing.B) {
@@ -516,6 +1037,31 @@ func simpleMetrics(count int) pmetric.Metrics {
 	return metrics
 }
 
+// deltaSumPoint is a datapoint of the delta sum named name.
+type deltaSumPoint struct {
+	name       string
+	start, end int64
+	value      int64
+}
+
+// deltaSums returns a metric per datapoint, the metrics of the same name being the same series.
+func deltaSums(points ...deltaSumPoint) pmetric.Metrics {
+	md := pmetric.NewMetrics()
+	metrics := md.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics()
+	for _, p := range points {
+		m := metrics.AppendEmpty()
+		m.SetName(p.name)
+		sum := m.SetEmptySum()
+		sum.SetAggregationTemporality(pmetric.AggregationTemporalityDelta)
+		sum.SetIsMonotonic(true)
+		dp := sum.DataPoints().AppendEmpty()
+		dp.SetStartTimestamp(pcommon.Timestamp(p.start))
+		dp.SetTimestamp(pcommon.Timestamp(p.end))
+		dp.SetIntValue(p.value)
+	}
+	return md
+}
+
 func mustPushMetricsData(t *testing.T, exporter *metricsExporter, md pmetric.Metrics) {
 	err := exporter.pushMetricsData(context.TODO(), md)
 	require.NoError(t, err)
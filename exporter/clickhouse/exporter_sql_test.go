@@ -45,7 +45,7 @@ func getQueryFirstLine(query string) string {
 func checkClusterQueryDefinition(query string, clusterName string) error {
 	line := getQueryFirstLine(query)
 	lowercasedLine := strings.ToLower(line)
-	suffix := fmt.Sprintf("ON CLUSTER %s", clusterName)
+	suffix := fmt.Sprintf("ON CLUSTER `%s`", clusterName)
 	prefixes := []string{"create database", "create table", "create materialized view"}
 	for _, prefix := range prefixes {
 		if strings.HasPrefix(lowercasedLine, prefix) {
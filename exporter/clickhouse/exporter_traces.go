@@ -5,12 +5,21 @@ package clickhouseexporter // import "github.com/foyer-work/otel-distribution/ex
 
 import (
 	"context"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/ottl"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/ottl/contexts/ottlspan"
 	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/pdata/pcommon"
 	"go.opentelemetry.io/collector/pdata/ptrace"
 	"go.uber.org/zap"
 
@@ -18,50 +27,259 @@ import (
 )
 
 type tracesExporter struct {
-	client    *sql.DB
-	insertSQL string
+	client          *sql.DB
+	insertSQL       string
+	insertEventsSQL string
+	insertLinksSQL  string
+	// insertIndexSQL inserts into the index table of the jaeger schema.
+	insertIndexSQL string
+	// table is the templated traces table name, tables holds an exporter per rendered name.
+	table  internal.TableTemplate
+	tables internal.OnceMap[*tracesExporter]
+	// spanNames normalizes the span names if enabled, shared with the table exporters.
+	spanNames *internal.SpanNameNormalizer
+	// routes holds an exporter per routed tenant, writing into the database and tables of its route.
+	routes internal.OnceMap[*tracesExporter]
+	// tableRoutes write the spans matching their conditions into their tables, in order.
+	tableRoutes []tracesTableRoute
+	// dimensions writes the resources and scopes into the dimension tables if enabled, shared with the table
+	// route and table exporters.
+	dimensions *internal.Dimensions
+	// written holds the deduplication tokens of the event and link inserts recently written.
+	written writtenInserts
 
-	logger *zap.Logger
-	cfg    *Config
+	logger    *zap.Logger
+	telemetry *exporterTelemetry
+	startup   startupRunner
+	health    healthReporter
+	server    serverInfo
+	debug     *debugStats
+	cfg       *Config
 }
 
 func newTracesExporter(logger *zap.Logger, cfg *Config) (*tracesExporter, error) {
+	cfg = cfg.exporterConfig(cfg.TracesDatabase)
 	client, err := newClickhouseClient(cfg)
 	if err != nil {
 		return nil, err
 	}
 
-	return &tracesExporter{
-		client:    client,
-		insertSQL: renderInsertTracesSQL(cfg),
-		logger:    logger,
-		cfg:       cfg,
-	}, nil
+	var spanNames *internal.SpanNameNormalizer
+	var tableRoutes []ottl.ConditionSequence[ottlspan.TransformContext]
+	if !cfg.jaegerSchema() {
+		if spanNames, err = cfg.spanNameNormalizer(); err != nil {
+			return nil, err
+		}
+		if tableRoutes, err = parseTracesTableRoutes(component.TelemetrySettings{Logger: logger}, cfg.Traces.TableRoutes); err != nil {
+			return nil, err
+		}
+	}
+	return buildTracesExporter(logger, cfg, client, spanNames, tableRoutes), nil
 }
 
-func (e *tracesExporter) start(ctx context.Context, _ component.Host) error {
-	if !e.cfg.shouldCreateSchema() {
-		return nil
+// buildTracesExporter returns the exporter writing into the traces tables of cfg through client, tableRoutes
+// being the conditions of the table routes of cfg.
+func buildTracesExporter(logger *zap.Logger, cfg *Config, client *sql.DB, spanNames *internal.SpanNameNormalizer, tableRoutes []ottl.ConditionSequence[ottlspan.TransformContext]) *tracesExporter {
+	if cfg.jaegerSchema() {
+		return &tracesExporter{
+			client:         client,
+			insertSQL:      renderInsertJaegerSpansSQL(cfg),
+			insertIndexSQL: renderInsertJaegerIndexSQL(cfg),
+			logger:         logger,
+			cfg:            cfg,
+		}
+	}
+	if cfg.contribSchema() {
+		return &tracesExporter{
+			client:    client,
+			insertSQL: renderInsertContribTracesSQL(cfg),
+			spanNames: spanNames,
+			logger:    logger,
+			cfg:       cfg,
+		}
 	}
+	exporter := &tracesExporter{
+		client:          client,
+		insertSQL:       renderInsertTracesSQL(cfg),
+		insertEventsSQL: renderInsertTraceEventsSQL(cfg),
+		insertLinksSQL:  renderInsertTraceLinksSQL(cfg),
+		table:           internal.TableTemplate(cfg.rotatedTableName(cfg.TracesTableName)),
+		spanNames:       spanNames,
+		dimensions:      newDimensions(cfg),
+		logger:          logger,
+		cfg:             cfg,
+	}
+	for i, conditions := range tableRoutes {
+		routeExporter := buildTracesExporter(logger, cfg.tableRouteConfig(cfg.Traces.TableRoutes[i]), client, spanNames, nil)
+		routeExporter.dimensions = exporter.dimensions
+		exporter.tableRoutes = append(exporter.tableRoutes, tracesTableRoute{
+			conditions: conditions,
+			exporter:   routeExporter,
+		})
+	}
+	return exporter
+}
+
+// setTelemetry sets the telemetry of the exporter and of its table route exporters.
+func (e *tracesExporter) setTelemetry(telemetry *exporterTelemetry) {
+	e.telemetry = telemetry
+	for _, route := range e.tableRoutes {
+		route.exporter.telemetry = telemetry
+	}
+}
 
-	if err := createDatabase(ctx, e.cfg); err != nil {
+func (e *tracesExporter) start(ctx context.Context, host component.Host) error {
+	if err := e.startup.start(ctx, host, e.logger, e.cfg, func(ctx context.Context) error {
+		return e.setup(ctx, host)
+	}); err != nil {
 		return err
 	}
+	if err := e.debug.register(e.logger); err != nil {
+		return err
+	}
+	e.health.start(host, e.logger, e.cfg.HealthCheckInterval, e.cfg.TimeoutSettings.Timeout, e.client.PingContext)
+	return nil
+}
+
+// setup creates the schema of the exporter.
+func (e *tracesExporter) setup(ctx context.Context, host component.Host) error {
+	ctx = e.cfg.queryContext(ctx)
+	e.server.detect(ctx, e.logger, e.client)
+	if err := adaptSchema(ctx, e.logger, e.cfg, e.client, &e.server, e.cfg.Traces.SignalConfig); err != nil {
+		return err
+	}
+	if e.cfg.schemaObjectsFor(e.cfg.Traces.SignalConfig).Database {
+		if err := createDatabase(ctx, e.cfg, e.cfg.clusterStringFor(e.cfg.Traces.SignalConfig)); err != nil {
+			return err
+		}
+	}
+	if e.cfg.jaegerSchema() {
+		verifyStart(ctx, host, e.logger, e.cfg, e.client, e.cfg.Traces.SignalConfig, []string{e.cfg.jaegerSpansTableName(), e.cfg.jaegerIndexTableName()})
+		return createJaegerTables(ctx, e.cfg, e.client)
+	}
+	if e.cfg.contribSchema() {
+		verifyStart(ctx, host, e.logger, e.cfg, e.client, e.cfg.Traces.SignalConfig, []string{e.cfg.TracesTableName}, featureFlattenNested)
+		return createContribTracesTable(ctx, e.cfg, e.client)
+	}
+	var features []serverFeature
+	tables := []string{e.cfg.TracesTableName}
+	if e.cfg.separateEventsLinks() {
+		tables = append(tables, e.cfg.eventsTableName(), e.cfg.linksTableName())
+	} else {
+		features = append(features, featureFlattenNested)
+	}
+	for _, route := range e.tableRoutes {
+		if !route.exporter.table.IsTemplate() {
+			tables = append(tables, route.exporter.cfg.TracesTableName)
+		}
+	}
+	if e.table.IsTemplate() {
+		tables = []string{allTables}
+	}
+	verifyStart(ctx, host, e.logger, e.cfg, e.client, e.cfg.Traces.SignalConfig, tables, features...)
+	if err := e.createTableRouteTables(ctx, createTracesTable); err != nil {
+		return err
+	}
+	if e.table.IsTemplate() {
+		return e.createRotationTables(ctx)
+	}
 
 	return createTracesTable(ctx, e.cfg, e.client)
 }
 
 // shutdown will shut down the exporter.
 func (e *tracesExporter) shutdown(_ context.Context) error {
+	e.health.stop()
+	e.startup.stop()
+	e.debug.unregister()
+	var err error
+	e.routes.Range(func(_ string, exporter *tracesExporter) bool {
+		if client := exporter.client; client != e.client {
+			err = errors.Join(err, client.Close())
+		}
+		return true
+	})
 	if e.client != nil {
-		return e.client.Close()
+		err = errors.Join(err, e.client.Close())
 	}
-	return nil
+	return err
 }
 
 func (e *tracesExporter) pushTraceData(ctx context.Context, td ptrace.Traces) error {
+	if err := e.startup.ready(); err != nil {
+		return err
+	}
+	ctx, reportSkipped := e.telemetry.skipInvalidRows(ctx, e.cfg, e.logger, "traces")
+	defer reportSkipped()
+	ctx = e.telemetry.observeInserts(ctx, e.logger, &e.server, e.debug)
+	if len(e.cfg.Routing.Routes) != 0 {
+		routes := splitTracesByRoute(e.cfg, td)
+		for tenant, traces := range routes {
+			if tenant == "" {
+				continue
+			}
+			exporter, err := e.routeExporter(ctx, tenant)
+			if err != nil {
+				return err
+			}
+			if err := exporter.pushTraceData(ctx, traces); err != nil {
+				return err
+			}
+		}
+		traces, ok := routes[""]
+		if !ok {
+			return nil
+		}
+		td = traces
+	}
+	if e.cfg.jaegerSchema() {
+		return e.pushJaegerSpans(ctx, td)
+	}
+	if e.cfg.contribSchema() {
+		return e.pushContribSpans(ctx, td)
+	}
+	if len(e.tableRoutes) != 0 {
+		routed := splitTracesByTableRoute(ctx, e.tableRoutes, td)
+		for i, route := range e.tableRoutes {
+			if traces, ok := routed[strconv.Itoa(i)]; ok {
+				if err := route.exporter.pushTraceData(ctx, traces); err != nil {
+					return err
+				}
+			}
+		}
+		traces, ok := routed[""]
+		if !ok {
+			return nil
+		}
+		td = traces
+	}
+	if e.table.IsTemplate() {
+		return e.pushTemplatedTraces(ctx, td)
+	}
+	// the resources and scopes are written first, the spans referencing them by fingerprint.
+	if e.dimensions != nil {
+		if err := e.dimensions.Write(e.cfg.queryContext(ctx), e.client, tracesDimensions(td, e.cfg.attributeFilter(e.cfg.Traces.SignalConfig))); err != nil {
+			return err
+		}
+	}
 	start := time.Now()
-	err := doWithTx(ctx, e.client, func(tx *sql.Tx) error {
+	attributes := e.cfg.attributeFilter(e.cfg.Traces.SignalConfig)
+	// The events and links are written before the spans, so that a batch failing on them is retried without its
+	// spans written twice.
+	if e.cfg.separateEventsLinks() {
+		if err := e.pushSpanEventsAndLinks(e.cfg.queryContext(ctx), td, attributes); err != nil {
+			return err
+		}
+	}
+	insertCtx := e.cfg.queryContext(ctx)
+	if e.cfg.Traces.Deduplicate {
+		insertCtx = internal.WithInsertDeduplicationToken(insertCtx, spansDeduplicationToken(td))
+	}
+	ctx, observe := internal.ObserveInsert(internal.InsertContext(insertCtx, "insert_spans"), e.cfg.TracesTableName)
+	tenantColumn := e.cfg.tenantColumn() != ""
+	tenant := e.cfg.tenant(ctx)
+	ids := e.cfg.idEncoding()
+	err := doWithTx(ctx, e.client, func(ctx context.Context, tx *sql.Tx) error {
 		statement, err := tx.PrepareContext(ctx, e.insertSQL)
 		if err != nil {
 			return fmt.Errorf("PrepareContext:%w", err)
@@ -72,43 +290,69 @@ func (e *tracesExporter) pushTraceData(ctx context.Context, td ptrace.Traces) er
 		for i := range td.ResourceSpans().Len() {
 			spans := td.ResourceSpans().At(i)
 			res := spans.Resource()
-			resAttr := internal.AttributesToJSON(res.Attributes())
+			resAttr := attributes.JSON(res.Attributes())
 			serviceName := internal.GetServiceName(res.Attributes())
+			var resFingerprint uint64
+			if e.dimensions != nil {
+				resFingerprint = internal.ResourceFingerprint(spans.SchemaUrl(), resAttr)
+				resAttr = internal.EmptyAttributes
+			}
 
 			for j := range spans.ScopeSpans().Len() {
 				rs := spans.ScopeSpans().At(j).Spans()
 				scopeName := spans.ScopeSpans().At(j).Scope().Name()
 				scopeVersion := spans.ScopeSpans().At(j).Scope().Version()
+				var scopeFingerprint uint64
+				if e.dimensions != nil {
+					scopeAttr := attributes.JSON(spans.ScopeSpans().At(j).Scope().Attributes())
+					scopeFingerprint = internal.ScopeFingerprint(spans.ScopeSpans().At(j).SchemaUrl(), scopeName, scopeVersion, scopeAttr)
+				}
 				for k := range rs.Len() {
 					r := rs.At(k)
-					spanAttr := internal.AttributesToJSON(r.Attributes())
+					name, spanAttributes := e.spanName(r)
+					spanAttr := attributes.JSON(spanAttributes)
 					status := r.Status()
-					eventTimes, eventNames, eventAttrs := convertEvents(r.Events())
-					linksTraceIDs, linksSpanIDs, linksTraceStates, linksAttrs := convertLinks(r.Links())
-					_, err = statement.ExecContext(ctx,
+					values := []any{
 						r.StartTimestamp().AsTime(),
-						internal.TraceIDToHexOrEmptyString(r.TraceID()),
-						internal.SpanIDToHexOrEmptyString(r.SpanID()),
-						internal.SpanIDToHexOrEmptyString(r.ParentSpanID()),
+						ids.TraceID(r.TraceID()),
+						ids.SpanID(r.SpanID()),
+						ids.SpanID(r.ParentSpanID()),
 						r.TraceState().AsRaw(),
-						r.Name(),
-						r.Kind().String(),
+						name,
+						e.enumValue(int8(r.Kind()), r.Kind().String()),
 						serviceName,
 						resAttr,
 						scopeName,
 						scopeVersion,
 						spanAttr,
 						r.EndTimestamp().AsTime().Sub(r.StartTimestamp().AsTime()).Nanoseconds(),
-						status.Code().String(),
+						e.enumValue(int8(status.Code()), status.Code().String()),
 						status.Message(),
-						eventTimes,
-						eventNames,
-						eventAttrs,
-						linksTraceIDs,
-						linksSpanIDs,
-						linksTraceStates,
-						linksAttrs,
-					)
+						r.DroppedAttributesCount(),
+						r.DroppedEventsCount(),
+						r.DroppedLinksCount(),
+						r.Flags(),
+					}
+					if !e.cfg.separateEventsLinks() {
+						eventTimes, eventNames, eventAttrs := convertEvents(r.Events(), attributes)
+						linksTraceIDs, linksSpanIDs, linksTraceStates, linksAttrs := convertLinks(r.Links(), attributes, ids)
+						values = append(values,
+							eventTimes,
+							eventNames,
+							eventAttrs,
+							linksTraceIDs,
+							linksSpanIDs,
+							linksTraceStates,
+							linksAttrs,
+						)
+					}
+					if tenantColumn {
+						values = append(values, tenant)
+					}
+					if e.dimensions != nil {
+						values = append(values, resFingerprint, scopeFingerprint)
+					}
+					_, err = internal.ExecRow(ctx, statement, values...)
 					if err != nil {
 						return fmt.Errorf("ExecContext:%w", err)
 					}
@@ -117,29 +361,403 @@ func (e *tracesExporter) pushTraceData(ctx context.Context, td ptrace.Traces) er
 		}
 		return nil
 	})
+	observe(err)
+	if err == nil {
+		e.telemetry.recordTruncatedAttributeValues(ctx, "traces", attributes)
+	}
 	duration := time.Since(start)
 	e.logger.Debug("insert traces", zap.Int("records", td.SpanCount()),
 		zap.String("cost", duration.String()))
 	return err
 }
 
-func convertEvents(events ptrace.SpanEventSlice) (times []time.Time, names []string, attrs []string) {
+// spanName returns the normalized name of span and its attributes, with its original name added to a copy of
+// them if renamed.
+func (e *tracesExporter) spanName(span ptrace.Span) (string, pcommon.Map) {
+	if e.spanNames == nil {
+		return span.Name(), span.Attributes()
+	}
+	name, renamed := e.spanNames.Normalize(span.Name())
+	if !renamed {
+		return name, span.Attributes()
+	}
+	attributes := pcommon.NewMap()
+	span.Attributes().CopyTo(attributes)
+	attributes.PutStr(e.cfg.Traces.SpanNames.OriginalAttribute, span.Name())
+	return name, attributes
+}
+
+// spansDeduplicationToken returns the hex SHA-256 of the trace and span ids of the spans of td, identifying the
+// rows inserted for td.
+func spansDeduplicationToken(td ptrace.Traces) string {
+	h := sha256.New()
+	_ = forEachSpan(td, func(_ string, span ptrace.Span) error {
+		traceID, spanID := span.TraceID(), span.SpanID()
+		h.Write(traceID[:])
+		h.Write(spanID[:])
+		return nil
+	})
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// enumValue returns the value written to an enum column of the traces table: its number if the column is created
+// as Enum8, else its name.
+func (e *tracesExporter) enumValue(number int8, name string) any {
+	if e.cfg.Traces.EnumColumns {
+		return number
+	}
+	return name
+}
+
+// maxWrittenInserts bounds the deduplication tokens of the event and link inserts a traces exporter remembers,
+// the oldest being forgotten first.
+const maxWrittenInserts = 128
+
+// writtenInserts remembers the deduplication tokens of the inserts written, so that a batch retried once its links
+// or spans failed skips the inserts already done without sending them, the deduplication window of the tables
+// skipping them otherwise, e.g. once the exporter restarted.
+type writtenInserts struct {
+	mu     sync.Mutex
+	tokens []string
+}
+
+func (w *writtenInserts) contains(token string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return slices.Contains(w.tokens, token)
+}
+
+func (w *writtenInserts) add(token string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.tokens) == maxWrittenInserts {
+		w.tokens = slices.Delete(w.tokens, 0, 1)
+	}
+	w.tokens = append(w.tokens, token)
+}
+
+// pushSpanEventsAndLinks writes span events and links into their own tables.
+// Each table is written in its own transaction, and skipped if the batch has no rows for it.
+// The inserts carry a deduplication token derived from the spans of td and are skipped once written, so that
+// a retried batch doesn't write the events again once its links failed.
+// The attributes are filtered by attributes.
+func (e *tracesExporter) pushSpanEventsAndLinks(ctx context.Context, td ptrace.Traces, attributes *internal.AttributeFilter) error {
+	token := spansDeduplicationToken(td)
+	ctx = internal.WithInsertDeduplication(ctx, token)
+	ids := e.cfg.idEncoding()
+	var events, links int
+	_ = forEachSpan(td, func(_ string, span ptrace.Span) error {
+		events += span.Events().Len()
+		links += span.Links().Len()
+		return nil
+	})
+
+	if events > 0 && !e.written.contains(token+"-events") {
+		ctx, observe := internal.ObserveInsert(internal.InsertContext(ctx, "insert_span_events"), e.cfg.eventsTableName())
+		err := doWithTx(ctx, e.client, func(ctx context.Context, tx *sql.Tx) error {
+			statement, err := tx.PrepareContext(ctx, e.insertEventsSQL)
+			if err != nil {
+				return fmt.Errorf("PrepareContext:%w", err)
+			}
+			defer func() {
+				_ = statement.Close()
+			}()
+			return forEachSpan(td, func(serviceName string, span ptrace.Span) error {
+				traceID := ids.TraceID(span.TraceID())
+				spanID := ids.SpanID(span.SpanID())
+				for i := range span.Events().Len() {
+					event := span.Events().At(i)
+					_, err := internal.ExecRow(ctx, statement,
+						event.Timestamp().AsTime(),
+						traceID,
+						spanID,
+						serviceName,
+						event.Name(),
+						attributes.JSON(event.Attributes()),
+					)
+					if err != nil {
+						return fmt.Errorf("ExecContext:%w", err)
+					}
+				}
+				return nil
+			})
+		})
+		observe(err)
+		if err != nil {
+			return fmt.Errorf("insert span events: %w", err)
+		}
+		e.written.add(token + "-events")
+	}
+
+	if links > 0 && !e.written.contains(token+"-links") {
+		ctx, observe := internal.ObserveInsert(internal.InsertContext(ctx, "insert_span_links"), e.cfg.linksTableName())
+		err := doWithTx(ctx, e.client, func(ctx context.Context, tx *sql.Tx) error {
+			statement, err := tx.PrepareContext(ctx, e.insertLinksSQL)
+			if err != nil {
+				return fmt.Errorf("PrepareContext:%w", err)
+			}
+			defer func() {
+				_ = statement.Close()
+			}()
+			return forEachSpan(td, func(serviceName string, span ptrace.Span) error {
+				traceID := ids.TraceID(span.TraceID())
+				spanID := ids.SpanID(span.SpanID())
+				for i := range span.Links().Len() {
+					link := span.Links().At(i)
+					_, err := internal.ExecRow(ctx, statement,
+						span.StartTimestamp().AsTime(),
+						traceID,
+						spanID,
+						serviceName,
+						ids.TraceID(link.TraceID()),
+						ids.SpanID(link.SpanID()),
+						link.TraceState().AsRaw(),
+						attributes.JSON(link.Attributes()),
+					)
+					if err != nil {
+						return fmt.Errorf("ExecContext:%w", err)
+					}
+				}
+				return nil
+			})
+		})
+		observe(err)
+		if err != nil {
+			return fmt.Errorf("insert span links: %w", err)
+		}
+		e.written.add(token + "-links")
+	}
+	return nil
+}
+
+// pushTemplatedTraces writes each span into the table its start time and resource render the template to.
+// Tables are created when first written to.
+func (e *tracesExporter) pushTemplatedTraces(ctx context.Context, td ptrace.Traces) error {
+	for table, traces := range splitTracesByTable(e.table, td) {
+		exporter, err := e.tableExporter(ctx, table)
+		if err != nil {
+			return err
+		}
+		if err := exporter.pushTraceData(ctx, traces); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tableExporter returns the exporter writing into table, creating the table on first use unless it exists.
+// Concurrent first uses of a table wait for a single creation.
+func (e *tracesExporter) tableExporter(ctx context.Context, table string) (*tracesExporter, error) {
+	return e.tables.Get(ctx, table, func() (*tracesExporter, error) {
+		cfg := *e.cfg
+		cfg.TracesTableName = table
+		if cfg.Rotation.Enabled {
+			// the tables of past periods are detached or archived whole rather than their rows deleted.
+			cfg.TTL = 0
+		}
+		if err := createMissingTracesTable(e.cfg.queryContext(ctx), &cfg, e.client); err != nil {
+			return nil, err
+		}
+		return &tracesExporter{
+			client:          e.client,
+			insertSQL:       renderInsertTracesSQL(&cfg),
+			insertEventsSQL: renderInsertTraceEventsSQL(&cfg),
+			insertLinksSQL:  renderInsertTraceLinksSQL(&cfg),
+			spanNames:       e.spanNames,
+			dimensions:      e.dimensions,
+			logger:          e.logger,
+			telemetry:       e.telemetry,
+			cfg:             &cfg,
+		}, nil
+	})
+}
+
+// routeExporter returns the exporter writing the spans of tenant into the database and tables of its route,
+// creating those missing on first use. Concurrent first uses of a route wait for a single creation.
+// Routes to another database connect to it with their own client.
+func (e *tracesExporter) routeExporter(ctx context.Context, tenant string) (*tracesExporter, error) {
+	return e.routes.Get(ctx, tenant, func() (*tracesExporter, error) {
+		cfg := e.cfg.routeConfig(tenant)
+		client := e.client
+		if cfg.Database != e.cfg.Database {
+			var err error
+			if client, err = newClickhouseClient(cfg); err != nil {
+				return nil, err
+			}
+		}
+		exporter := buildTracesExporter(e.logger, cfg, client, e.spanNames, e.tableRouteConditions())
+		exporter.setTelemetry(e.telemetry)
+		if err := exporter.createRouteSchema(e.cfg.queryContext(ctx)); err != nil {
+			closeRouteClient(e.client, client)
+			return nil, err
+		}
+		return exporter, nil
+	})
+}
+
+// createRouteSchema creates the database and the missing tables of a route exporter.
+func (e *tracesExporter) createRouteSchema(ctx context.Context) error {
+	if e.cfg.schemaObjectsFor(e.cfg.Traces.SignalConfig).Database {
+		if err := createDatabase(ctx, e.cfg, e.cfg.clusterStringFor(e.cfg.Traces.SignalConfig)); err != nil {
+			return err
+		}
+	}
+	if e.cfg.jaegerSchema() {
+		if exists, err := tableExists(ctx, e.cfg, e.client, e.cfg.jaegerSpansTableName()); err != nil || exists {
+			return err
+		}
+		return createJaegerTables(ctx, e.cfg, e.client)
+	}
+	if err := e.createTableRouteTables(ctx, createMissingTracesTable); err != nil {
+		return err
+	}
+	if e.table.IsTemplate() {
+		return e.createRotationTables(ctx)
+	}
+	return createMissingTracesTable(ctx, e.cfg, e.client)
+}
+
+// createTableRouteTables creates the tables of the table routes with create, except templated ones created
+// when first written to.
+func (e *tracesExporter) createTableRouteTables(ctx context.Context, create func(context.Context, *Config, *sql.DB) error) error {
+	for _, route := range e.tableRoutes {
+		if route.exporter.table.IsTemplate() {
+			continue
+		}
+		if err := create(ctx, route.exporter.cfg, e.client); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createRotationTables creates the tables of the current period of the exporter and its table routes, and the
+// Merge tables reading the tables of all periods, if the tables are rotated.
+func (e *tracesExporter) createRotationTables(ctx context.Context) error {
+	if !e.cfg.Rotation.Enabled {
+		return nil
+	}
+	exporters := []*tracesExporter{e}
+	for _, route := range e.tableRoutes {
+		exporters = append(exporters, route.exporter)
+	}
+	for _, exporter := range exporters {
+		current, err := exporter.tableExporter(ctx, exporter.table.Render(time.Now(), pcommon.NewMap()))
+		if err != nil {
+			return err
+		}
+		if err := createRotationMergeTable(ctx, e.cfg, e.client, e.cfg.Traces.SignalConfig, exporter.cfg.TracesTableName, current.cfg.TracesTableName); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tableRouteConditions returns the conditions of the table routes of the exporter.
+func (e *tracesExporter) tableRouteConditions() []ottl.ConditionSequence[ottlspan.TransformContext] {
+	var conditions []ottl.ConditionSequence[ottlspan.TransformContext]
+	for _, route := range e.tableRoutes {
+		conditions = append(conditions, route.conditions)
+	}
+	return conditions
+}
+
+// splitTracesByRoute groups the resource spans of td by the tenant they are routed to, "" for those without a route.
+func splitTracesByRoute(cfg *Config, td ptrace.Traces) map[string]ptrace.Traces {
+	routes := map[string]ptrace.Traces{}
+	for i := range td.ResourceSpans().Len() {
+		rs := td.ResourceSpans().At(i)
+		tenant, _ := cfg.route(rs.Resource())
+		traces, ok := routes[tenant]
+		if !ok {
+			traces = ptrace.NewTraces()
+			routes[tenant] = traces
+		}
+		rs.CopyTo(traces.ResourceSpans().AppendEmpty())
+	}
+	return routes
+}
+
+// splitTracesByTable groups the spans of td by the table name they render table to.
+func splitTracesByTable(table internal.TableTemplate, td ptrace.Traces) map[string]ptrace.Traces {
+	return splitTraces(td, func(rs ptrace.ResourceSpans, _ ptrace.ScopeSpans, span ptrace.Span) string {
+		return table.Render(span.StartTimestamp().AsTime(), rs.Resource().Attributes())
+	})
+}
+
+// splitTraces groups the spans of td by the name key returns for them, their resource and scope spans.
+func splitTraces(td ptrace.Traces, key func(rs ptrace.ResourceSpans, ss ptrace.ScopeSpans, span ptrace.Span) string) map[string]ptrace.Traces {
+	tables := map[string]ptrace.Traces{}
+	for i := range td.ResourceSpans().Len() {
+		rs := td.ResourceSpans().At(i)
+		resources := map[string]ptrace.ResourceSpans{}
+		for j := range rs.ScopeSpans().Len() {
+			ss := rs.ScopeSpans().At(j)
+			scopes := map[string]ptrace.ScopeSpans{}
+			for k := range ss.Spans().Len() {
+				span := ss.Spans().At(k)
+				name := key(rs, ss, span)
+
+				scope, ok := scopes[name]
+				if !ok {
+					resource, ok := resources[name]
+					if !ok {
+						traces, ok := tables[name]
+						if !ok {
+							traces = ptrace.NewTraces()
+							tables[name] = traces
+						}
+						resource = traces.ResourceSpans().AppendEmpty()
+						rs.Resource().CopyTo(resource.Resource())
+						resource.SetSchemaUrl(rs.SchemaUrl())
+						resources[name] = resource
+					}
+					scope = resource.ScopeSpans().AppendEmpty()
+					ss.Scope().CopyTo(scope.Scope())
+					scope.SetSchemaUrl(ss.SchemaUrl())
+					scopes[name] = scope
+				}
+				span.CopyTo(scope.Spans().AppendEmpty())
+			}
+		}
+	}
+	return tables
+}
+
+// forEachSpan calls fn for every span in td together with its resource service name.
+func forEachSpan(td ptrace.Traces, fn func(serviceName string, span ptrace.Span) error) error {
+	for i := range td.ResourceSpans().Len() {
+		spans := td.ResourceSpans().At(i)
+		serviceName := internal.GetServiceName(spans.Resource().Attributes())
+		for j := range spans.ScopeSpans().Len() {
+			rs := spans.ScopeSpans().At(j).Spans()
+			for k := range rs.Len() {
+				if err := fn(serviceName, rs.At(k)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func convertEvents(events ptrace.SpanEventSlice, filter *internal.AttributeFilter) (times []time.Time, names []string, attrs []string) {
 	for i := range events.Len() {
 		event := events.At(i)
 		times = append(times, event.Timestamp().AsTime())
 		names = append(names, event.Name())
-		attrs = append(attrs, internal.AttributesToJSON(event.Attributes()))
+		attrs = append(attrs, filter.JSON(event.Attributes()))
 	}
 	return
 }
 
-func convertLinks(links ptrace.SpanLinkSlice) (traceIDs []string, spanIDs []string, states []string, attrs []string) {
+func convertLinks(links ptrace.SpanLinkSlice, filter *internal.AttributeFilter, ids internal.IDEncoding) (traceIDs []string, spanIDs []string, states []string, attrs []string) {
 	for i := range links.Len() {
 		link := links.At(i)
-		traceIDs = append(traceIDs, internal.TraceIDToHexOrEmptyString(link.TraceID()))
-		spanIDs = append(spanIDs, internal.SpanIDToHexOrEmptyString(link.SpanID()))
+		traceIDs = append(traceIDs, ids.TraceID(link.TraceID()))
+		spanIDs = append(spanIDs, ids.SpanID(link.SpanID()))
 		states = append(states, link.TraceState().AsRaw())
-		attrs = append(attrs, internal.AttributesToJSON(link.Attributes()))
+		attrs = append(attrs, filter.JSON(link.Attributes()))
 	}
 	return
 }
@@ -153,6 +771,7 @@ CREATE TABLE IF NOT EXISTS %s %s (
 	SpanId String CODEC(ZSTD(1)),
 	ParentSpanId String CODEC(ZSTD(1)),
 	TraceState String CODEC(ZSTD(1)),
+	Flags UInt32 CODEC(ZSTD(1)),
 	SpanName LowCardinality(String) CODEC(ZSTD(1)),
 	SpanKind LowCardinality(String) CODEC(ZSTD(1)),
 	ServiceName LowCardinality(String) CODEC(ZSTD(1)),
@@ -161,26 +780,19 @@ CREATE TABLE IF NOT EXISTS %s %s (
 	ScopeVersion String CODEC(ZSTD(1)),
 	SpanAttributes JSON,
 	Duration UInt64 CODEC(ZSTD(1)),
+	EndTimestamp DateTime64(9) MATERIALIZED addNanoseconds(Timestamp, Duration) CODEC(Delta, ZSTD(1)),
 	StatusCode LowCardinality(String) CODEC(ZSTD(1)),
 	StatusMessage String CODEC(ZSTD(1)),
-	Events Nested (
-		Timestamp DateTime64(9),
-		Name LowCardinality(String),
-		Attributes JSON
-	) CODEC(ZSTD(1)),
-	Links Nested (
-		TraceId String,
-		SpanId String,
-		TraceState String,
-		Attributes JSON
-	) CODEC(ZSTD(1)),
-	INDEX idx_trace_id TraceId TYPE bloom_filter(0.001) GRANULARITY 1,
+	DroppedAttributesCount UInt32 CODEC(ZSTD(1)),
+	DroppedEventsCount UInt32 CODEC(ZSTD(1)),
+	DroppedLinksCount UInt32 CODEC(ZSTD(1)),
+%s	INDEX idx_trace_id TraceId TYPE bloom_filter(0.001) GRANULARITY 1,
 	INDEX idx_duration Duration TYPE minmax GRANULARITY 1
 ) ENGINE = %s
-PARTITION BY toDate(Timestamp)
-ORDER BY (ServiceName, SpanName, toDateTime(Timestamp))
+PARTITION BY %s
+ORDER BY (ServiceName, SpanName, toDateTime(Timestamp)%s)
 %s
-SETTINGS index_granularity=8192, ttl_only_drop_parts = 1;
+SETTINGS index_granularity=8192, ttl_only_drop_parts = 1%s;
 `
 	// language=ClickHouse SQL
 	insertTracesSQLTemplate = `INSERT INTO %s (
@@ -199,94 +811,641 @@ SETTINGS index_granularity=8192, ttl_only_drop_parts = 1;
                         Duration,
                         StatusCode,
                         StatusMessage,
+                        DroppedAttributesCount,
+                        DroppedEventsCount,
+                        DroppedLinksCount,
+                        Flags%s
+                        ) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?%s)`
+	// language=ClickHouse SQL
+	tracesEventsLinksColumnsSQL = `	Events Nested (
+		Timestamp DateTime64(9),
+		Name LowCardinality(String),
+		Attributes JSON
+	) CODEC(ZSTD(1)),
+	Links Nested (
+		TraceId String,
+		SpanId String,
+		TraceState String,
+		Attributes JSON
+	) CODEC(ZSTD(1)),
+`
+	insertTracesEventsLinksColumns = `,
                         Events.Timestamp,
                         Events.Name,
                         Events.Attributes,
                         Links.TraceId,
                         Links.SpanId,
                         Links.TraceState,
-                        Links.Attributes
-                        ) VALUES (
-                                  ?,
-                                  ?,
-                                  ?,
-                                  ?,
-                                  ?,
-                                  ?,
-                                  ?,
-                                  ?,
-                                  ?,
-                                  ?,
-                                  ?,
-                                  ?,
-                                  ?,
-                                  ?,
-                                  ?,
-                                  ?,
-                                  ?,
-                                  ?,
-                                  ?,
-                                  ?,
-                                  ?,
-                                  ?
-                                  )`
+                        Links.Attributes`
+	insertTracesEventsLinksValues = `, ?, ?, ?, ?, ?, ?, ?`
+)
+
+// The events and links tables keep a deduplication window, their inserts carrying the deduplication token
+// of the spans, which non replicated tables ignore by default.
+const (
+	// language=ClickHouse SQL
+	createTraceEventsTableSQL = `
+CREATE TABLE IF NOT EXISTS %s %s (
+	Timestamp DateTime64(9) CODEC(Delta, ZSTD(1)),
+	TraceId String CODEC(ZSTD(1)),
+	SpanId String CODEC(ZSTD(1)),
+	ServiceName LowCardinality(String) CODEC(ZSTD(1)),
+	Name LowCardinality(String) CODEC(ZSTD(1)),
+	Attributes JSON
+) ENGINE = %s
+PARTITION BY %s
+ORDER BY (TraceId, SpanId, Timestamp)
+%s
+SETTINGS index_granularity=8192, ttl_only_drop_parts = 1, non_replicated_deduplication_window = 1000;
+`
+	// language=ClickHouse SQL
+	insertTraceEventsSQLTemplate = `INSERT INTO %s (
+                        Timestamp,
+                        TraceId,
+                        SpanId,
+                        ServiceName,
+                        Name,
+                        Attributes
+                        ) VALUES (?, ?, ?, ?, ?, ?)`
+	// language=ClickHouse SQL
+	createTraceLinksTableSQL = `
+CREATE TABLE IF NOT EXISTS %s %s (
+	Timestamp DateTime64(9) CODEC(Delta, ZSTD(1)),
+	TraceId String CODEC(ZSTD(1)),
+	SpanId String CODEC(ZSTD(1)),
+	ServiceName LowCardinality(String) CODEC(ZSTD(1)),
+	LinkedTraceId String CODEC(ZSTD(1)),
+	LinkedSpanId String CODEC(ZSTD(1)),
+	LinkedTraceState String CODEC(ZSTD(1)),
+	Attributes JSON,
+	INDEX idx_linked_trace_id LinkedTraceId TYPE bloom_filter(0.001) GRANULARITY 1
+) ENGINE = %s
+PARTITION BY %s
+ORDER BY (TraceId, SpanId, Timestamp)
+%s
+SETTINGS index_granularity=8192, ttl_only_drop_parts = 1, non_replicated_deduplication_window = 1000;
+`
+	// language=ClickHouse SQL
+	insertTraceLinksSQLTemplate = `INSERT INTO %s (
+                        Timestamp,
+                        TraceId,
+                        SpanId,
+                        ServiceName,
+                        LinkedTraceId,
+                        LinkedSpanId,
+                        LinkedTraceState,
+                        Attributes
+                        ) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
 )
 
 const (
 	createTraceIDTsTableSQL = `
-CREATE TABLE IF NOT EXISTS %s_trace_id_ts %s (
+CREATE TABLE IF NOT EXISTS %s %s (
      TraceId String CODEC(ZSTD(1)),
      Start DateTime CODEC(Delta, ZSTD(1)),
      End DateTime CODEC(Delta, ZSTD(1)),
      INDEX idx_trace_id TraceId TYPE bloom_filter(0.01) GRANULARITY 1
 ) ENGINE = %s
-PARTITION BY toDate(Start)
+PARTITION BY %s
 ORDER BY (TraceId, Start)
 %s
 SETTINGS index_granularity=8192, ttl_only_drop_parts = 1;
 `
 	createTraceIDTsMaterializedViewSQL = `
-CREATE MATERIALIZED VIEW IF NOT EXISTS %s_trace_id_ts_mv %s
-TO %s.%s_trace_id_ts
+CREATE MATERIALIZED VIEW IF NOT EXISTS %s %s
+TO %s.%s
 AS SELECT
 	TraceId,
 	min(Timestamp) as Start,
 	max(Timestamp) as End
 FROM
 %s.%s
-WHERE TraceId != ''
+WHERE notEmpty(TraceId)
+GROUP BY TraceId;
+`
+	createTraceSummaryTableSQL = `
+CREATE TABLE IF NOT EXISTS %s %s (
+	TraceId String CODEC(ZSTD(1)),
+	Start SimpleAggregateFunction(min, DateTime64(9)) CODEC(Delta, ZSTD(1)),
+	End SimpleAggregateFunction(max, DateTime64(9)) CODEC(Delta, ZSTD(1)),
+	SpanCount SimpleAggregateFunction(sum, UInt64) CODEC(ZSTD(1)),
+	ErrorCount SimpleAggregateFunction(sum, UInt64) CODEC(ZSTD(1)),
+	ServiceNames SimpleAggregateFunction(groupUniqArrayArray, Array(LowCardinality(String))) CODEC(ZSTD(1)),
+	RootServiceName SimpleAggregateFunction(max, String) CODEC(ZSTD(1)),
+	RootSpanName SimpleAggregateFunction(max, String) CODEC(ZSTD(1))
+) ENGINE = %s
+PARTITION BY %s
+ORDER BY TraceId
+%s
+SETTINGS index_granularity=8192, ttl_only_drop_parts = 1;
+`
+	// The root span fields are empty for the other spans, so the greatest one is the root span's.
+	createTraceSummaryMaterializedViewSQL = `
+CREATE MATERIALIZED VIEW IF NOT EXISTS %s %s
+TO %s.%s
+AS SELECT
+	TraceId,
+	min(Timestamp) AS Start,
+	max(addNanoseconds(Timestamp, Duration)) AS End,
+	count() AS SpanCount,
+	countIf(StatusCode = 'Error') AS ErrorCount,
+	groupUniqArray(ServiceName) AS ServiceNames,
+	maxIf(ServiceName, empty(ParentSpanId)) AS RootServiceName,
+	maxIf(SpanName, empty(ParentSpanId)) AS RootSpanName
+FROM %s.%s
+WHERE notEmpty(TraceId)
 GROUP BY TraceId;
 `
+	createDurationRollupTableSQL = `
+CREATE TABLE IF NOT EXISTS %s %s (
+	ServiceName LowCardinality(String) CODEC(ZSTD(1)),
+	SpanName LowCardinality(String) CODEC(ZSTD(1)),
+	TimeUnix DateTime CODEC(Delta, ZSTD(1)),
+	Calls SimpleAggregateFunction(sum, UInt64) CODEC(ZSTD(1)),
+	Errors SimpleAggregateFunction(sum, UInt64) CODEC(ZSTD(1)),
+	Duration AggregateFunction(quantilesTDigest(0.5, 0.9, 0.95, 0.99), UInt64)
+) ENGINE = %s
+PARTITION BY %s
+ORDER BY (ServiceName, SpanName, TimeUnix)
+%s
+SETTINGS index_granularity=8192, ttl_only_drop_parts = 1;
+`
+	// The durations are renamed, their state being aliased as the column it is inserted into.
+	createDurationRollupMaterializedViewSQL = `
+CREATE MATERIALIZED VIEW IF NOT EXISTS %s %s
+TO %s.%s
+AS SELECT
+	ServiceName,
+	SpanName,
+	toStartOfMinute(Timestamp) AS TimeUnix,
+	count() AS Calls,
+	countIf(StatusCode = 'Error') AS Errors,
+	quantilesTDigestState(0.5, 0.9, 0.95, 0.99)(SpanDuration) AS Duration
+FROM (
+	SELECT ServiceName, SpanName, Timestamp, StatusCode, Duration AS SpanDuration
+	FROM %s.%s
 )
+GROUP BY ServiceName, SpanName, TimeUnix;
+`
+	createRootSpansTableSQL = `
+CREATE TABLE IF NOT EXISTS %s %s (
+	Timestamp DateTime64(9) CODEC(Delta, ZSTD(1)),
+	TraceId String CODEC(ZSTD(1)),
+	ServiceName LowCardinality(String) CODEC(ZSTD(1)),
+	SpanName LowCardinality(String) CODEC(ZSTD(1)),
+	Duration UInt64 CODEC(ZSTD(1)),
+	StatusCode LowCardinality(String) CODEC(ZSTD(1)),
+	INDEX idx_trace_id TraceId TYPE bloom_filter(0.001) GRANULARITY 1,
+	INDEX idx_duration Duration TYPE minmax GRANULARITY 1
+) ENGINE = %s
+PARTITION BY %s
+ORDER BY (ServiceName, SpanName, toDateTime(Timestamp))
+%s
+SETTINGS index_granularity=8192, ttl_only_drop_parts = 1;
+`
+	createRootSpansMaterializedViewSQL = `
+CREATE MATERIALIZED VIEW IF NOT EXISTS %s %s
+TO %s.%s
+AS SELECT
+	Timestamp,
+	TraceId,
+	ServiceName,
+	SpanName,
+	Duration,
+	StatusCode
+FROM %s.%s
+WHERE empty(ParentSpanId);
+`
+	createServiceEdgesTableSQL = `
+CREATE TABLE IF NOT EXISTS %s %s (
+	TimeUnix DateTime CODEC(Delta, ZSTD(1)),
+	Caller LowCardinality(String) CODEC(ZSTD(1)),
+	Callee LowCardinality(String) CODEC(ZSTD(1)),
+	SpanKind LowCardinality(String) CODEC(ZSTD(1)),
+	Calls UInt64 CODEC(ZSTD(1)),
+	Errors UInt64 CODEC(ZSTD(1)),
+	DurationSum UInt64 CODEC(ZSTD(1))
+) ENGINE = %s
+PARTITION BY %s
+ORDER BY (Caller, Callee, SpanKind, TimeUnix)
+%s
+SETTINGS index_granularity=8192, ttl_only_drop_parts = 1;
+`
+	// The attributes are stored with '_' in place of '.', so peer.service is the peer_service member.
+	createServiceEdgesMaterializedViewSQL = `
+CREATE MATERIALIZED VIEW IF NOT EXISTS %s %s
+TO %s.%s
+AS SELECT
+	toStartOfMinute(Timestamp) AS TimeUnix,
+	ServiceName AS Caller,
+	JSONExtractString(%s, 'peer_service') AS Callee,
+	SpanKind,
+	count() AS Calls,
+	countIf(StatusCode = 'Error') AS Errors,
+	sum(Duration) AS DurationSum
+FROM %s.%s
+WHERE SpanKind IN ('Client', 'Producer') AND Callee != ''
+GROUP BY TimeUnix, Caller, Callee, SpanKind;
+`
+)
+
+// spanEnumTypes are the Enum8 types of the SpanKind and StatusCode columns created with traces::enum_columns,
+// their values the numbers of the OTLP enums.
+var spanEnumTypes = map[string]string{
+	"SpanKind":   "Enum8('Unspecified' = 0, 'Internal' = 1, 'Server' = 2, 'Client' = 3, 'Producer' = 4, 'Consumer' = 5)",
+	"StatusCode": "Enum8('Unset' = 0, 'Ok' = 1, 'Error' = 2)",
+}
+
+// tracesColumnComments describes the columns of the traces table.
+var tracesColumnComments = map[string]string{
+	"Timestamp":              "Span.start_time_unix_nano",
+	"TraceId":                "Span.trace_id as hex",
+	"SpanId":                 "Span.span_id as hex",
+	"ParentSpanId":           "Span.parent_span_id as hex",
+	"TraceState":             "Span.trace_state",
+	"Flags":                  "Span.flags, with the W3C trace flags and whether the parent is remote",
+	"SpanName":               "Span.name",
+	"SpanKind":               "Span.kind",
+	"ServiceName":            "Resource attribute service.name",
+	"ResourceAttributes":     "Resource.attributes",
+	"ScopeName":              "InstrumentationScope.name",
+	"ScopeVersion":           "InstrumentationScope.version",
+	"SpanAttributes":         "Span.attributes",
+	"Duration":               "Span.end_time_unix_nano - Span.start_time_unix_nano in nanoseconds",
+	"EndTimestamp":           "Span.end_time_unix_nano",
+	"StatusCode":             "Span.status.code",
+	"StatusMessage":          "Span.status.message",
+	"DroppedAttributesCount": "Span.dropped_attributes_count",
+	"DroppedEventsCount":     "Span.dropped_events_count",
+	"DroppedLinksCount":      "Span.dropped_links_count",
+	"Events":                 "Span.events",
+	"Links":                  "Span.links",
+}
+
+// traceEventsColumnComments describes the columns of the span events table.
+var traceEventsColumnComments = map[string]string{
+	"Timestamp":   "Span.Event.time_unix_nano",
+	"TraceId":     "Span.trace_id as hex",
+	"SpanId":      "Span.span_id as hex",
+	"ServiceName": "Resource attribute service.name",
+	"Name":        "Span.Event.name",
+	"Attributes":  "Span.Event.attributes",
+}
+
+// traceLinksColumnComments describes the columns of the span links table.
+var traceLinksColumnComments = map[string]string{
+	"Timestamp":        "Span.start_time_unix_nano",
+	"TraceId":          "Span.trace_id as hex",
+	"SpanId":           "Span.span_id as hex",
+	"ServiceName":      "Resource attribute service.name",
+	"LinkedTraceId":    "Span.Link.trace_id as hex",
+	"LinkedSpanId":     "Span.Link.span_id as hex",
+	"LinkedTraceState": "Span.Link.trace_state",
+	"Attributes":       "Span.Link.attributes",
+}
+
+// traceSummaryColumnComments describes the columns of the trace summary table.
+var traceSummaryColumnComments = map[string]string{
+	"TraceId":         "Span.trace_id as hex",
+	"Start":           "Earliest span start of the trace",
+	"End":             "Latest span end of the trace",
+	"SpanCount":       "Number of spans of the trace",
+	"ErrorCount":      "Number of spans of the trace with the Error status code",
+	"ServiceNames":    "Resource attribute service.name of the spans of the trace",
+	"RootServiceName": "Resource attribute service.name of the root span",
+	"RootSpanName":    "Span.name of the root span",
+}
+
+// durationRollupColumnComments describes the columns of the span duration rollup table.
+var durationRollupColumnComments = map[string]string{
+	"ServiceName": "Resource attribute service.name",
+	"SpanName":    "Span.name",
+	"TimeUnix":    "Start of the minute of the spans",
+	"Calls":       "Number of spans",
+	"Errors":      "Number of spans with the Error status code",
+	"Duration":    "T-digest of the span durations in nanoseconds, read with quantilesTDigestMerge(0.5, 0.9, 0.95, 0.99)",
+}
+
+// rootSpansColumnComments describes the columns of the root spans table.
+var rootSpansColumnComments = map[string]string{
+	"Timestamp":   "Span.start_time_unix_nano of the root span",
+	"TraceId":     "Span.trace_id as hex",
+	"ServiceName": "Resource attribute service.name of the root span",
+	"SpanName":    "Span.name of the root span",
+	"Duration":    "Span.end_time_unix_nano - Span.start_time_unix_nano of the root span in nanoseconds",
+	"StatusCode":  "Span.status.code of the root span",
+}
+
+// serviceEdgesColumnComments describes the columns of the service edges table.
+var serviceEdgesColumnComments = map[string]string{
+	"TimeUnix":    "Start of the minute of the spans",
+	"Caller":      "Resource attribute service.name of the client and producer spans",
+	"Callee":      "Span attribute peer.service",
+	"SpanKind":    "Span.kind, Client or Producer",
+	"Calls":       "Number of spans",
+	"Errors":      "Number of spans with the Error status code",
+	"DurationSum": "Sum of the durations of the spans in nanoseconds",
+}
+
+// traceIDTsColumnComments describes the columns of the trace id timestamp lookup table.
+var traceIDTsColumnComments = map[string]string{
+	"TraceId": "Span.trace_id as hex",
+	"Start":   "Earliest span start of the trace",
+	"End":     "Latest span start of the trace",
+}
+
+// createMissingTracesTable creates the traces table of cfg and its derived tables unless the traces table exists,
+// for the tables created on first write, whose existing tables, e.g. provisioned ahead for a tenant, are used as is.
+func createMissingTracesTable(ctx context.Context, cfg *Config, db *sql.DB) error {
+	if exists, err := tableExists(ctx, cfg, db, cfg.TracesTableName); err != nil || exists {
+		return err
+	}
+	return createTracesTable(ctx, cfg, db)
+}
 
 func createTracesTable(ctx context.Context, cfg *Config, db *sql.DB) error {
-	if _, err := db.ExecContext(ctx, renderCreateTracesTableSQL(cfg)); err != nil {
-		return fmt.Errorf("exec create traces table sql: %w", err)
+	if cfg.contribSchema() {
+		return createContribTracesTable(ctx, cfg, db)
 	}
-	if _, err := db.ExecContext(ctx, renderCreateTraceIDTsTableSQL(cfg)); err != nil {
-		return fmt.Errorf("exec create traceID timestamp table sql: %w", err)
+	objects := cfg.schemaObjectsFor(cfg.Traces.SignalConfig)
+	if objects.Tables {
+		if _, err := db.ExecContext(internal.QueryContext(ctx, "create_table"), objects.tableDDL(renderCreateTracesTableSQL(cfg))); err != nil {
+			return fmt.Errorf("exec create traces table sql: %w", err)
+		}
+		if _, err := db.ExecContext(internal.QueryContext(ctx, "create_table"), objects.tableDDL(renderCreateTraceIDTsTableSQL(cfg))); err != nil {
+			return fmt.Errorf("exec create traceID timestamp table sql: %w", err)
+		}
+		if err := updateTTL(ctx, cfg, db, cfg.Traces.SignalConfig, cfg.TracesTableName, cfg.ttlExprFor(cfg.TracesTableName, cfg.TTL, "toDateTime(Timestamp)")); err != nil {
+			return err
+		}
+		if err := updateTTL(ctx, cfg, db, cfg.Traces.SignalConfig, cfg.traceIDTsTableName(), generateTTLExpr(cfg.TTL, "toDateTime(Start)")); err != nil {
+			return err
+		}
 	}
-	if _, err := db.ExecContext(ctx, renderTraceIDTsMaterializedViewSQL(cfg)); err != nil {
-		return fmt.Errorf("exec create traceID timestamp view sql: %w", err)
+	if objects.MaterializedViews {
+		if _, err := db.ExecContext(internal.QueryContext(ctx, "create_view"), renderTraceIDTsMaterializedViewSQL(cfg)); err != nil {
+			return fmt.Errorf("exec create traceID timestamp view sql: %w", err)
+		}
+	}
+	if cfg.Traces.TraceSummary.Enabled {
+		if err := createTraceSummaryTable(ctx, cfg, db); err != nil {
+			return err
+		}
+	}
+	if cfg.Traces.DurationRollup.Enabled {
+		if err := createDurationRollupTable(ctx, cfg, db); err != nil {
+			return err
+		}
+	}
+	if cfg.Traces.RootSpans.Enabled {
+		if err := createRootSpansTable(ctx, cfg, db); err != nil {
+			return err
+		}
+	}
+	if cfg.Traces.ServiceGraph.Enabled {
+		if err := createServiceEdgesTable(ctx, cfg, db); err != nil {
+			return err
+		}
+	}
+	metadataTables := []string{cfg.TracesTableName}
+	if cfg.Traces.RootSpans.Enabled {
+		metadataTables = append(metadataTables, cfg.rootSpansTableName())
+	}
+	if err := createServiceMetadata(ctx, cfg, db, cfg.Traces.SignalConfig, metadataTables...); err != nil {
+		return err
+	}
+	if err := createDimensions(ctx, cfg, db, cfg.Traces.SignalConfig); err != nil {
+		return err
+	}
+	if objects.Tables && cfg.separateEventsLinks() {
+		if _, err := db.ExecContext(internal.QueryContext(ctx, "create_table"), objects.tableDDL(renderCreateTraceEventsTableSQL(cfg))); err != nil {
+			return fmt.Errorf("exec create trace events table sql: %w", err)
+		}
+		if _, err := db.ExecContext(internal.QueryContext(ctx, "create_table"), objects.tableDDL(renderCreateTraceLinksTableSQL(cfg))); err != nil {
+			return fmt.Errorf("exec create trace links table sql: %w", err)
+		}
+		ttlExpr := generateTTLExpr(cfg.eventsLinksTTL(), "toDateTime(Timestamp)")
+		for _, table := range []string{cfg.eventsTableName(), cfg.linksTableName()} {
+			if err := updateTTL(ctx, cfg, db, cfg.Traces.SignalConfig, table, ttlExpr); err != nil {
+				return err
+			}
+		}
+	}
+	return addProjections(ctx, cfg, db, cfg.Traces.SignalConfig, cfg.TracesTableName, cfg.traceIDTsTableName(), cfg.eventsTableName(), cfg.linksTableName(), cfg.rootSpansTableName(), cfg.traceSummaryTableName(), cfg.durationRollupTableName())
+}
+
+// createTraceSummaryTable creates the trace summary table and its materialized view on the traces table.
+func createTraceSummaryTable(ctx context.Context, cfg *Config, db *sql.DB) error {
+	objects := cfg.schemaObjectsFor(cfg.Traces.SignalConfig)
+	if objects.Tables {
+		if _, err := db.ExecContext(internal.QueryContext(ctx, "create_table"), objects.tableDDL(renderCreateTraceSummaryTableSQL(cfg))); err != nil {
+			return fmt.Errorf("exec create trace summary table sql: %w", err)
+		}
+		if err := updateTTL(ctx, cfg, db, cfg.Traces.SignalConfig, cfg.traceSummaryTableName(), generateTTLExpr(cfg.traceSummaryTTL(), "toDateTime(Start)")); err != nil {
+			return err
+		}
+	}
+	if objects.MaterializedViews {
+		if _, err := db.ExecContext(internal.QueryContext(ctx, "create_view"), renderTraceSummaryMaterializedViewSQL(cfg)); err != nil {
+			return fmt.Errorf("exec create trace summary view sql: %w", err)
+		}
+	}
+	return nil
+}
+
+// createDurationRollupTable creates the span duration rollup table and its materialized view on the traces table.
+func createDurationRollupTable(ctx context.Context, cfg *Config, db *sql.DB) error {
+	objects := cfg.schemaObjectsFor(cfg.Traces.SignalConfig)
+	if objects.Tables {
+		if _, err := db.ExecContext(internal.QueryContext(ctx, "create_table"), objects.tableDDL(renderCreateDurationRollupTableSQL(cfg))); err != nil {
+			return fmt.Errorf("exec create span duration rollup table sql: %w", err)
+		}
+		if err := updateTTL(ctx, cfg, db, cfg.Traces.SignalConfig, cfg.durationRollupTableName(), generateTTLExpr(cfg.durationRollupTTL(), "TimeUnix")); err != nil {
+			return err
+		}
+	}
+	if objects.MaterializedViews {
+		if _, err := db.ExecContext(internal.QueryContext(ctx, "create_view"), renderDurationRollupMaterializedViewSQL(cfg)); err != nil {
+			return fmt.Errorf("exec create span duration rollup view sql: %w", err)
+		}
+	}
+	return nil
+}
+
+// createRootSpansTable creates the root spans table and its materialized view on the traces table.
+func createRootSpansTable(ctx context.Context, cfg *Config, db *sql.DB) error {
+	objects := cfg.schemaObjectsFor(cfg.Traces.SignalConfig)
+	if objects.Tables {
+		if _, err := db.ExecContext(internal.QueryContext(ctx, "create_table"), objects.tableDDL(renderCreateRootSpansTableSQL(cfg))); err != nil {
+			return fmt.Errorf("exec create root spans table sql: %w", err)
+		}
+		if err := updateTTL(ctx, cfg, db, cfg.Traces.SignalConfig, cfg.rootSpansTableName(), generateTTLExpr(cfg.rootSpansTTL(), "toDateTime(Timestamp)")); err != nil {
+			return err
+		}
+	}
+	if objects.MaterializedViews {
+		if _, err := db.ExecContext(internal.QueryContext(ctx, "create_view"), renderRootSpansMaterializedViewSQL(cfg)); err != nil {
+			return fmt.Errorf("exec create root spans view sql: %w", err)
+		}
+	}
+	return nil
+}
+
+// createServiceEdgesTable creates the service edges table and its materialized view on the traces table.
+func createServiceEdgesTable(ctx context.Context, cfg *Config, db *sql.DB) error {
+	objects := cfg.schemaObjectsFor(cfg.Traces.SignalConfig)
+	if objects.Tables {
+		if _, err := db.ExecContext(internal.QueryContext(ctx, "create_table"), objects.tableDDL(renderCreateServiceEdgesTableSQL(cfg))); err != nil {
+			return fmt.Errorf("exec create service edges table sql: %w", err)
+		}
+		if err := updateTTL(ctx, cfg, db, cfg.Traces.SignalConfig, cfg.serviceEdgesTableName(), generateTTLExpr(cfg.serviceGraphTTL(), "TimeUnix")); err != nil {
+			return err
+		}
+	}
+	if objects.MaterializedViews {
+		if _, err := db.ExecContext(internal.QueryContext(ctx, "create_view"), renderServiceEdgesMaterializedViewSQL(cfg)); err != nil {
+			return fmt.Errorf("exec create service edges view sql: %w", err)
+		}
 	}
 	return nil
 }
 
 func renderInsertTracesSQL(cfg *Config) string {
-	return fmt.Sprintf(strings.ReplaceAll(insertTracesSQLTemplate, "'", "`"), cfg.TracesTableName)
+	columns, values := insertTracesEventsLinksColumns, insertTracesEventsLinksValues
+	if cfg.separateEventsLinks() {
+		columns, values = "", ""
+	}
+	if column := cfg.tenantColumn(); column != "" {
+		columns += ",\n                        " + column
+		values += ", ?"
+	}
+	if cfg.Dimensions.Enabled {
+		for _, column := range internal.FingerprintColumns() {
+			columns += ",\n                        " + column.Name
+			values += ", ?"
+		}
+	}
+	return fmt.Sprintf(strings.ReplaceAll(insertTracesSQLTemplate, "'", "`"), internal.QuoteIdentifier(cfg.TracesTableName), columns, values)
 }
 
 func renderCreateTracesTableSQL(cfg *Config) string {
-	ttlExpr := generateTTLExpr(cfg.TTL, "toDateTime(Timestamp)")
-	return fmt.Sprintf(createTracesTableSQL, cfg.TracesTableName, cfg.clusterString(), cfg.tableEngineString(), ttlExpr)
+	columns := tracesEventsLinksColumnsSQL
+	if cfg.separateEventsLinks() {
+		columns = ""
+	}
+	engine, orderBy, settings := cfg.tableEngineStringFor(cfg.Traces.SignalConfig), "", ""
+	if cfg.Traces.Deduplicate {
+		// The deduplication window of non replicated tables is 0, which disables the deduplication tokens.
+		engine, _ = cfg.mergeTreeVariantFor(cfg.Traces.SignalConfig, "Replacing")
+		orderBy, settings = ", TraceId, SpanId", ", non_replicated_deduplication_window = 1000"
+	}
+	ttlExpr := cfg.ttlExprFor(cfg.TracesTableName, cfg.TTL, "toDateTime(Timestamp)")
+	ddl := fmt.Sprintf(createTracesTableSQL, internal.QuoteIdentifier(cfg.TracesTableName), cfg.clusterStringFor(cfg.Traces.SignalConfig), columns, engine, cfg.tenantPartitionExprFor(cfg.Traces.SignalConfig, "Timestamp"), orderBy, ttlExpr, settings)
+	if cfg.Traces.EnumColumns {
+		ddl = internal.RewriteColumns(ddl, func(col *internal.ColumnDef) {
+			if enumType, ok := spanEnumTypes[col.Name]; ok {
+				col.Type = enumType
+			}
+		})
+	}
+	if column := cfg.tenantColumn(); column != "" {
+		ddl = internal.AddTenantColumn(ddl, column)
+	}
+	if cfg.Dimensions.Enabled {
+		ddl = internal.AddFingerprintColumns(ddl)
+	}
+	return cfg.columnOptions().Apply(cfg.TracesTableName, internal.CommentColumns(ddl, tracesColumnComments))
+}
+
+func renderCreateTraceEventsTableSQL(cfg *Config) string {
+	ttlExpr := generateTTLExpr(cfg.eventsLinksTTL(), "toDateTime(Timestamp)")
+	ddl := fmt.Sprintf(createTraceEventsTableSQL, internal.QuoteIdentifier(cfg.eventsTableName()), cfg.clusterStringFor(cfg.Traces.SignalConfig), cfg.tableEngineStringFor(cfg.Traces.SignalConfig), internal.PartitionExpr(cfg.partitionByFor(cfg.Traces.SignalConfig), "Timestamp"), ttlExpr)
+	return cfg.columnOptions().Apply(cfg.eventsTableName(), internal.CommentColumns(ddl, traceEventsColumnComments))
+}
+
+func renderCreateTraceLinksTableSQL(cfg *Config) string {
+	ttlExpr := generateTTLExpr(cfg.eventsLinksTTL(), "toDateTime(Timestamp)")
+	ddl := fmt.Sprintf(createTraceLinksTableSQL, internal.QuoteIdentifier(cfg.linksTableName()), cfg.clusterStringFor(cfg.Traces.SignalConfig), cfg.tableEngineStringFor(cfg.Traces.SignalConfig), internal.PartitionExpr(cfg.partitionByFor(cfg.Traces.SignalConfig), "Timestamp"), ttlExpr)
+	return cfg.columnOptions().Apply(cfg.linksTableName(), internal.CommentColumns(ddl, traceLinksColumnComments))
+}
+
+func renderInsertTraceEventsSQL(cfg *Config) string {
+	return fmt.Sprintf(insertTraceEventsSQLTemplate, internal.QuoteIdentifier(cfg.eventsTableName()))
+}
+
+func renderInsertTraceLinksSQL(cfg *Config) string {
+	return fmt.Sprintf(insertTraceLinksSQLTemplate, internal.QuoteIdentifier(cfg.linksTableName()))
 }
 
 func renderCreateTraceIDTsTableSQL(cfg *Config) string {
 	ttlExpr := generateTTLExpr(cfg.TTL, "toDateTime(Start)")
-	return fmt.Sprintf(createTraceIDTsTableSQL, cfg.TracesTableName, cfg.clusterString(), cfg.tableEngineString(), ttlExpr)
+	ddl := fmt.Sprintf(createTraceIDTsTableSQL, internal.QuoteIdentifier(cfg.traceIDTsTableName()), cfg.clusterStringFor(cfg.Traces.SignalConfig), cfg.tableEngineStringFor(cfg.Traces.SignalConfig), internal.PartitionExpr(cfg.partitionByFor(cfg.Traces.SignalConfig), "Start"), ttlExpr)
+	return cfg.columnOptions().Apply(cfg.traceIDTsTableName(), internal.CommentColumns(ddl, traceIDTsColumnComments))
 }
 
 func renderTraceIDTsMaterializedViewSQL(cfg *Config) string {
-	return fmt.Sprintf(createTraceIDTsMaterializedViewSQL, cfg.TracesTableName,
-		cfg.clusterString(), cfg.Database, cfg.TracesTableName, cfg.Database, cfg.TracesTableName)
+	database := internal.QuoteIdentifier(cfg.Database)
+	return fmt.Sprintf(createTraceIDTsMaterializedViewSQL, internal.QuoteIdentifier(cfg.TracesTableName+"_trace_id_ts_mv"),
+		cfg.clusterStringFor(cfg.Traces.SignalConfig), database, internal.QuoteIdentifier(cfg.traceIDTsTableName()),
+		database, internal.QuoteIdentifier(cfg.TracesTableName))
+}
+
+func renderCreateTraceSummaryTableSQL(cfg *Config) string {
+	engine, _ := cfg.mergeTreeVariantFor(cfg.Traces.SignalConfig, "Aggregating")
+	ttlExpr := generateTTLExpr(cfg.traceSummaryTTL(), "toDateTime(Start)")
+	ddl := fmt.Sprintf(createTraceSummaryTableSQL, internal.QuoteIdentifier(cfg.traceSummaryTableName()), cfg.clusterStringFor(cfg.Traces.SignalConfig), engine, internal.PartitionExpr(cfg.partitionByFor(cfg.Traces.SignalConfig), "Start"), ttlExpr)
+	return cfg.columnOptions().Apply(cfg.traceSummaryTableName(), internal.CommentColumns(ddl, traceSummaryColumnComments))
+}
+
+func renderTraceSummaryMaterializedViewSQL(cfg *Config) string {
+	database := internal.QuoteIdentifier(cfg.Database)
+	return fmt.Sprintf(createTraceSummaryMaterializedViewSQL, internal.QuoteIdentifier(cfg.traceSummaryTableName()+"_mv"),
+		cfg.clusterStringFor(cfg.Traces.SignalConfig), database, internal.QuoteIdentifier(cfg.traceSummaryTableName()),
+		database, internal.QuoteIdentifier(cfg.TracesTableName))
+}
+
+func renderCreateDurationRollupTableSQL(cfg *Config) string {
+	engine, _ := cfg.mergeTreeVariantFor(cfg.Traces.SignalConfig, "Aggregating")
+	ttlExpr := generateTTLExpr(cfg.durationRollupTTL(), "TimeUnix")
+	ddl := fmt.Sprintf(createDurationRollupTableSQL, internal.QuoteIdentifier(cfg.durationRollupTableName()), cfg.clusterStringFor(cfg.Traces.SignalConfig), engine, internal.PartitionExpr(cfg.partitionByFor(cfg.Traces.SignalConfig), "TimeUnix"), ttlExpr)
+	return cfg.columnOptions().Apply(cfg.durationRollupTableName(), internal.CommentColumns(ddl, durationRollupColumnComments))
+}
+
+func renderDurationRollupMaterializedViewSQL(cfg *Config) string {
+	database := internal.QuoteIdentifier(cfg.Database)
+	return fmt.Sprintf(createDurationRollupMaterializedViewSQL, internal.QuoteIdentifier(cfg.durationRollupTableName()+"_mv"),
+		cfg.clusterStringFor(cfg.Traces.SignalConfig), database, internal.QuoteIdentifier(cfg.durationRollupTableName()),
+		database, internal.QuoteIdentifier(cfg.TracesTableName))
+}
+
+func renderCreateRootSpansTableSQL(cfg *Config) string {
+	ttlExpr := generateTTLExpr(cfg.rootSpansTTL(), "toDateTime(Timestamp)")
+	ddl := fmt.Sprintf(createRootSpansTableSQL, internal.QuoteIdentifier(cfg.rootSpansTableName()), cfg.clusterStringFor(cfg.Traces.SignalConfig), cfg.tableEngineStringFor(cfg.Traces.SignalConfig), internal.PartitionExpr(cfg.partitionByFor(cfg.Traces.SignalConfig), "Timestamp"), ttlExpr)
+	return cfg.columnOptions().Apply(cfg.rootSpansTableName(), internal.CommentColumns(ddl, rootSpansColumnComments))
+}
+
+func renderRootSpansMaterializedViewSQL(cfg *Config) string {
+	database := internal.QuoteIdentifier(cfg.Database)
+	return fmt.Sprintf(createRootSpansMaterializedViewSQL, internal.QuoteIdentifier(cfg.rootSpansTableName()+"_mv"),
+		cfg.clusterStringFor(cfg.Traces.SignalConfig), database, internal.QuoteIdentifier(cfg.rootSpansTableName()),
+		database, internal.QuoteIdentifier(cfg.TracesTableName))
+}
+
+func renderCreateServiceEdgesTableSQL(cfg *Config) string {
+	engine, _ := cfg.mergeTreeVariantFor(cfg.Traces.SignalConfig, "Summing")
+	ttlExpr := generateTTLExpr(cfg.serviceGraphTTL(), "TimeUnix")
+	ddl := fmt.Sprintf(createServiceEdgesTableSQL, internal.QuoteIdentifier(cfg.serviceEdgesTableName()), cfg.clusterStringFor(cfg.Traces.SignalConfig), engine, internal.PartitionExpr(cfg.partitionByFor(cfg.Traces.SignalConfig), "TimeUnix"), ttlExpr)
+	return cfg.columnOptions().Apply(cfg.serviceEdgesTableName(), internal.CommentColumns(ddl, serviceEdgesColumnComments))
+}
+
+func renderServiceEdgesMaterializedViewSQL(cfg *Config) string {
+	// JSON columns are serialized to extract the member, as the String columns created without the JSON type are.
+	attributes := "toJSONString(SpanAttributes)"
+	if cfg.jsonFallback {
+		attributes = "SpanAttributes"
+	}
+	database := internal.QuoteIdentifier(cfg.Database)
+	return fmt.Sprintf(createServiceEdgesMaterializedViewSQL, internal.QuoteIdentifier(cfg.TracesTableName+"_service_edges_mv"),
+		cfg.clusterStringFor(cfg.Traces.SignalConfig), database, internal.QuoteIdentifier(cfg.serviceEdgesTableName()),
+		attributes, database, internal.QuoteIdentifier(cfg.TracesTableName))
 }
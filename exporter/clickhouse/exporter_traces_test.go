@@ -6,13 +6,18 @@ package clickhouseexporter
 import (
 	"context"
 	"database/sql/driver"
+	"errors"
+	"fmt"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/client"
 	"go.opentelemetry.io/collector/pdata/pcommon"
 	"go.opentelemetry.io/collector/pdata/ptrace"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
 	conventions "go.opentelemetry.io/otel/semconv/v1.27.0"
 	"go.uber.org/zap/zaptest"
 )
@@ -46,6 +51,352 @@ func TestExporter_pushTracesData(t *testing.T) {
 		exporter := newTestTracesExporter(t, defaultEndpoint, withDriverName(t.Name()))
 		mustPushTracesData(t, exporter, simpleTraces(1))
 	})
+	t.Run("dropped counts and flags", func(t *testing.T) {
+		var got []driver.Value
+		initClickhouseTestServer(t, func(query string, values []driver.Value) error {
+			if strings.HasPrefix(query, "INSERT") {
+				got = values[15:19]
+			}
+			return nil
+		})
+
+		td := simpleTraces(1)
+		span := td.ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0)
+		span.SetDroppedAttributesCount(1)
+		span.SetDroppedEventsCount(2)
+		span.SetDroppedLinksCount(3)
+		span.SetFlags(0x301)
+		exporter := newTestTracesExporter(t, defaultEndpoint, withDriverName(t.Name()))
+		mustPushTracesData(t, exporter, td)
+		require.Equal(t, []driver.Value{uint32(1), uint32(2), uint32(3), uint32(0x301)}, got)
+	})
+	t.Run("span names", func(t *testing.T) {
+		var got []driver.Value
+		initClickhouseTestServer(t, func(query string, values []driver.Value) error {
+			if strings.HasPrefix(query, "INSERT") {
+				got = []driver.Value{values[5], values[11]}
+			}
+			return nil
+		})
+
+		td := simpleTraces(1)
+		span := td.ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0)
+		span.SetName("GET /users/42")
+		exporter := newTestTracesExporter(t, defaultEndpoint, withDriverName(t.Name()), func(cfg *Config) {
+			cfg.Traces.SpanNames.PathSegments = true
+		})
+		mustPushTracesData(t, exporter, td)
+		require.Equal(t, "GET /users/{id}", got[0])
+		require.JSONEq(t, `{"service_name":"v","span_original_name":"GET /users/42"}`, got[1].(string))
+		_, ok := span.Attributes().Get("span.original_name")
+		require.False(t, ok, "the pushed span is unchanged")
+	})
+	t.Run("enum columns", func(t *testing.T) {
+		var got []driver.Value
+		initClickhouseTestServer(t, func(query string, values []driver.Value) error {
+			if strings.HasPrefix(query, "INSERT") {
+				got = []driver.Value{values[6], values[13]}
+			}
+			return nil
+		})
+
+		td := simpleTraces(1)
+		span := td.ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0)
+		span.SetKind(ptrace.SpanKindClient)
+		span.Status().SetCode(ptrace.StatusCodeError)
+		exporter := newTestTracesExporter(t, defaultEndpoint, withDriverName(t.Name()), func(cfg *Config) {
+			cfg.Traces.EnumColumns = true
+		})
+		mustPushTracesData(t, exporter, td)
+		require.Equal(t, []driver.Value{int8(3), int8(2)}, got)
+	})
+	t.Run("events and links in separate tables", func(t *testing.T) {
+		items := map[string]int{}
+		initClickhouseTestServer(t, func(query string, values []driver.Value) error {
+			switch {
+			case strings.HasPrefix(query, "INSERT INTO `otel_traces_events`"):
+				require.Equal(t, "event1", values[4])
+				items["events"]++
+			case strings.HasPrefix(query, "INSERT INTO `otel_traces_links`"):
+				require.Equal(t, fmt.Sprintf("010205%02x000000000000000000000000", items["links"]), values[4])
+				items["links"]++
+			case strings.HasPrefix(query, "INSERT INTO `otel_traces`"):
+				require.Len(t, values, 19)
+				require.NotContains(t, query, "Events.")
+				items["spans"]++
+			}
+			return nil
+		})
+
+		exporter := newTestTracesExporter(t, defaultEndpoint, withDriverName(t.Name()), func(cfg *Config) {
+			cfg.Traces.EventsLinks.Mode = eventsLinksModeSeparateTables
+		})
+		mustPushTracesData(t, exporter, simpleTraces(2))
+
+		require.Equal(t, map[string]int{"spans": 2, "events": 2, "links": 2}, items)
+	})
+	t.Run("spans written once when links fail", func(t *testing.T) {
+		items := map[string]int{}
+		failLinks := true
+		initClickhouseTestServer(t, func(query string, _ []driver.Value) error {
+			switch {
+			case strings.HasPrefix(query, "INSERT INTO `otel_traces_events`"):
+				items["events"]++
+			case strings.HasPrefix(query, "INSERT INTO `otel_traces_links`"):
+				if failLinks {
+					return errors.New("links unavailable")
+				}
+				items["links"]++
+			case strings.HasPrefix(query, "INSERT INTO `otel_traces`"):
+				items["spans"]++
+			}
+			return nil
+		})
+
+		exporter := newTestTracesExporter(t, defaultEndpoint, withDriverName(t.Name()), func(cfg *Config) {
+			cfg.Traces.EventsLinks.Mode = eventsLinksModeSeparateTables
+		})
+		td := simpleTraces(2)
+		require.Error(t, exporter.pushTraceData(context.Background(), td))
+		require.Zero(t, items["spans"], "the spans aren't written before their events and links")
+
+		require.Equal(t, 2, items["events"])
+
+		failLinks = false
+		mustPushTracesData(t, exporter, td)
+		require.Equal(t, 2, items["spans"])
+		require.Equal(t, 2, items["events"], "the events aren't written again")
+		require.Equal(t, 2, items["links"])
+	})
+}
+
+func TestRenderCreateTracesTableSQL_eventsLinksMode(t *testing.T) {
+	cfg := withDefaultConfig()
+	require.Contains(t, renderCreateTracesTableSQL(cfg), "Events Nested")
+
+	cfg.Traces.EventsLinks.Mode = eventsLinksModeSeparateTables
+	cfg.Traces.EventsLinks.TTL = 24 * time.Hour
+	require.NotContains(t, renderCreateTracesTableSQL(cfg), "Events Nested")
+	require.Contains(t, renderCreateTraceEventsTableSQL(cfg), "CREATE TABLE IF NOT EXISTS `otel_traces_events`")
+	require.Contains(t, renderCreateTraceEventsTableSQL(cfg), "TTL toDateTime(Timestamp) + toIntervalDay(1)")
+	require.Contains(t, renderCreateTraceLinksTableSQL(cfg), "CREATE TABLE IF NOT EXISTS `otel_traces_links`")
+	for _, ddl := range []string{renderCreateTraceEventsTableSQL(cfg), renderCreateTraceLinksTableSQL(cfg)} {
+		require.Contains(t, ddl, "non_replicated_deduplication_window = 1000", "the deduplication tokens aren't ignored")
+	}
+}
+
+func TestRenderCreateTracesTableSQL_endTimestamp(t *testing.T) {
+	ddl := renderCreateTracesTableSQL(withDefaultConfig())
+	require.Contains(t, ddl, "\tEndTimestamp DateTime64(9) MATERIALIZED addNanoseconds(Timestamp, Duration) COMMENT 'Span.end_time_unix_nano' CODEC(Delta, ZSTD(1)),\n")
+}
+
+func TestTracesExporter_traceSummary(t *testing.T) {
+	var queries []string
+	initClickhouseTestServer(t, func(query string, _ []driver.Value) error {
+		queries = append(queries, query)
+		return nil
+	})
+	newTestTracesExporter(t, defaultEndpoint, withDriverName(t.Name()), func(cfg *Config) {
+		cfg.Database = "otel"
+		cfg.TTL = 72 * time.Hour
+		cfg.Traces.TraceSummary.Enabled = true
+	})
+	var table, view string
+	for _, query := range queries {
+		switch getQueryFirstLine(query) {
+		case "CREATE TABLE IF NOT EXISTS `otel_traces_trace_summary`":
+			table = query
+		case "CREATE MATERIALIZED VIEW IF NOT EXISTS `otel_traces_trace_summary_mv`":
+			view = query
+		}
+	}
+	require.Contains(t, table, ") ENGINE = AggregatingMergeTree()\nPARTITION BY toDate(Start)\nORDER BY TraceId\nTTL toDateTime(Start) + toIntervalDay(3)\n")
+	require.Contains(t, view, "TO `otel`.`otel_traces_trace_summary`")
+	require.Contains(t, view, "FROM `otel`.`otel_traces`\nWHERE notEmpty(TraceId)\nGROUP BY TraceId;")
+}
+
+func TestTracesExporter_durationRollup(t *testing.T) {
+	var queries []string
+	initClickhouseTestServer(t, func(query string, _ []driver.Value) error {
+		queries = append(queries, query)
+		return nil
+	})
+	newTestTracesExporter(t, defaultEndpoint, withDriverName(t.Name()), func(cfg *Config) {
+		cfg.Database = "otel"
+		cfg.Traces.DurationRollup = DurationRollupConfig{Enabled: true, TTL: 30 * 24 * time.Hour}
+	})
+	var table, view string
+	for _, query := range queries {
+		switch getQueryFirstLine(query) {
+		case "CREATE TABLE IF NOT EXISTS `otel_traces_duration_1m`":
+			table = query
+		case "CREATE MATERIALIZED VIEW IF NOT EXISTS `otel_traces_duration_1m_mv`":
+			view = query
+		}
+	}
+	require.Contains(t, table, "\tDuration AggregateFunction(quantilesTDigest(0.5, 0.9, 0.95, 0.99), UInt64) COMMENT")
+	require.Contains(t, table, ") ENGINE = AggregatingMergeTree()\nPARTITION BY toDate(TimeUnix)\nORDER BY (ServiceName, SpanName, TimeUnix)\nTTL TimeUnix + toIntervalDay(30)\n")
+	require.Contains(t, view, "TO `otel`.`otel_traces_duration_1m`")
+	require.Contains(t, view, "\tquantilesTDigestState(0.5, 0.9, 0.95, 0.99)(SpanDuration) AS Duration\n")
+	require.Contains(t, view, "\tFROM `otel`.`otel_traces`\n")
+}
+
+func TestTracesExporter_rootSpans(t *testing.T) {
+	var queries []string
+	initClickhouseTestServer(t, func(query string, _ []driver.Value) error {
+		queries = append(queries, query)
+		return nil
+	})
+	newTestTracesExporter(t, defaultEndpoint, withDriverName(t.Name()), func(cfg *Config) {
+		cfg.Database = "otel"
+		cfg.TTL = 72 * time.Hour
+		cfg.Traces.RootSpans = RootSpansConfig{Enabled: true, TTL: 240 * time.Hour}
+	})
+	var table, view string
+	for _, query := range queries {
+		switch getQueryFirstLine(query) {
+		case "CREATE TABLE IF NOT EXISTS `otel_traces_root_spans`":
+			table = query
+		case "CREATE MATERIALIZED VIEW IF NOT EXISTS `otel_traces_root_spans_mv`":
+			view = query
+		}
+	}
+	require.Contains(t, table, "TTL toDateTime(Timestamp) + toIntervalDay(10)")
+	require.Contains(t, table, "\tStatusCode LowCardinality(String) COMMENT 'Span.status.code of the root span' CODEC(ZSTD(1)),\n")
+	require.Contains(t, view, "TO `otel`.`otel_traces_root_spans`")
+	require.Contains(t, view, "FROM `otel`.`otel_traces`\nWHERE empty(ParentSpanId);")
+}
+
+func TestTracesExporter_serviceGraph(t *testing.T) {
+	var queries []string
+	initClickhouseTestServer(t, func(query string, _ []driver.Value) error {
+		queries = append(queries, query)
+		return nil
+	})
+	newTestTracesExporter(t, defaultEndpoint, withDriverName(t.Name()), func(cfg *Config) {
+		cfg.Database = "otel"
+		cfg.TTL = 72 * time.Hour
+		cfg.Traces.ServiceGraph.Enabled = true
+	})
+	var edges, view string
+	for _, query := range queries {
+		switch getQueryFirstLine(query) {
+		case "CREATE TABLE IF NOT EXISTS `otel_service_edges`":
+			edges = query
+		case "CREATE MATERIALIZED VIEW IF NOT EXISTS `otel_traces_service_edges_mv`":
+			view = query
+		}
+	}
+	require.Contains(t, edges, "ENGINE = SummingMergeTree()")
+	require.Contains(t, edges, "TTL TimeUnix + toIntervalDay(3)")
+	require.Contains(t, view, "TO `otel`.`otel_service_edges`")
+	require.Contains(t, view, "JSONExtractString(SpanAttributes, 'peer_service') AS Callee", "the test server lacks the JSON type")
+	require.Contains(t, view, "FROM `otel`.`otel_traces`")
+
+	view = renderServiceEdgesMaterializedViewSQL(withDefaultConfig(func(cfg *Config) {
+		cfg.Traces.ServiceGraph.TableName = "edges"
+	}))
+	require.Contains(t, view, "TO `default`.`edges`")
+	require.Contains(t, view, "JSONExtractString(toJSONString(SpanAttributes), 'peer_service') AS Callee")
+}
+
+func TestRenderCreateTracesTableSQL_enumColumns(t *testing.T) {
+	ddl := renderCreateTracesTableSQL(withDefaultConfig(func(cfg *Config) {
+		cfg.Traces.EnumColumns = true
+		cfg.LowCardinality = map[string]bool{"SpanKind": true}
+	}))
+	require.Contains(t, ddl, "\tSpanKind Enum8('Unspecified' = 0, 'Internal' = 1, 'Server' = 2, 'Client' = 3, 'Producer' = 4, 'Consumer' = 5) COMMENT 'Span.kind' CODEC(ZSTD(1)),\n")
+	require.Contains(t, ddl, "\tStatusCode Enum8('Unset' = 0, 'Ok' = 1, 'Error' = 2) COMMENT 'Span.status.code' CODEC(ZSTD(1)),\n")
+}
+
+func TestRenderCreateTracesTableSQL_deduplicate(t *testing.T) {
+	ddl := renderCreateTracesTableSQL(withDefaultConfig(func(cfg *Config) {
+		cfg.Traces.Deduplicate = true
+	}))
+	require.Contains(t, ddl, ") ENGINE = ReplacingMergeTree()\n")
+	require.Contains(t, ddl, "ORDER BY (ServiceName, SpanName, toDateTime(Timestamp), TraceId, SpanId)\n")
+	require.Contains(t, ddl, "SETTINGS index_granularity=8192, ttl_only_drop_parts = 1, non_replicated_deduplication_window = 1000\n")
+
+	ddl = renderCreateTracesTableSQL(withDefaultConfig())
+	require.Contains(t, ddl, ") ENGINE = MergeTree()\n")
+	require.Contains(t, ddl, "ORDER BY (ServiceName, SpanName, toDateTime(Timestamp))\n")
+}
+
+func TestSpansDeduplicationToken(t *testing.T) {
+	token := spansDeduplicationToken(simpleTraces(2))
+	require.Len(t, token, 64)
+	require.Equal(t, token, spansDeduplicationToken(simpleTraces(2)), "the same spans have the same token")
+	require.NotEqual(t, token, spansDeduplicationToken(simpleTraces(1)))
+}
+
+func TestRenderCreateTracesTableSQL_lowCardinality(t *testing.T) {
+	cfg := withDefaultConfig(func(cfg *Config) {
+		cfg.LowCardinality = map[string]bool{
+			"SpanName":            false,
+			"otel_traces.TraceId": true,
+		}
+	})
+	ddl := renderCreateTracesTableSQL(cfg)
+	require.Contains(t, ddl, "\tSpanName String COMMENT 'Span.name' CODEC(ZSTD(1)),\n")
+	require.Contains(t, ddl, "\tTraceId LowCardinality(String) COMMENT 'Span.trace_id as hex' CODEC(ZSTD(1)),\n")
+	require.Contains(t, ddl, "\tServiceName LowCardinality(String) COMMENT 'Resource attribute service.name' CODEC(ZSTD(1)),\n")
+}
+
+func TestTracesExporter_tracesDatabase(t *testing.T) {
+	var queries []string
+	initClickhouseTestServer(t, func(query string, _ []driver.Value) error {
+		queries = append(queries, query)
+		return nil
+	})
+	exporter := newTestTracesExporter(t, defaultEndpoint, withDriverName(t.Name()), func(cfg *Config) {
+		cfg.Database = "otel"
+		cfg.TracesDatabase = "otel_traces_db"
+	})
+
+	require.Equal(t, "otel_traces_db", exporter.cfg.Database)
+	require.Contains(t, queries, "CREATE DATABASE IF NOT EXISTS `otel_traces_db` ")
+	require.Contains(t, renderTraceIDTsMaterializedViewSQL(exporter.cfg), "TO `otel_traces_db`.`otel_traces_trace_id_ts`")
+}
+
+func TestTracesExporter_ttlRollups(t *testing.T) {
+	cfg := withDefaultConfig(func(cfg *Config) {
+		cfg.TTL = 30 * 24 * time.Hour
+		cfg.TTLRollups = []TTLRollupConfig{{
+			Table:   "otel_traces",
+			After:   7 * 24 * time.Hour,
+			GroupBy: []string{"ServiceName", "SpanName", "toDateTime(Timestamp)"},
+			Set:     map[string]string{"SpanAttributes": "any(SpanAttributes)", "Duration": "max(Duration)"},
+			Where:   "StatusCode != 'Error'",
+		}, {
+			Table:   "otel_logs",
+			After:   time.Hour,
+			GroupBy: []string{"ServiceName"},
+			Set:     map[string]string{"Body": "any(Body)"},
+		}}
+	})
+	require.Contains(t, renderCreateTracesTableSQL(cfg), "TTL toDateTime(Timestamp) + toIntervalDay(30), "+
+		"toDateTime(Timestamp) + toIntervalDay(7) WHERE StatusCode != 'Error' GROUP BY ServiceName, SpanName, toDateTime(Timestamp) "+
+		"SET Duration = max(Duration), SpanAttributes = any(SpanAttributes)\n")
+
+	cfg.TTL = 0
+	require.Equal(t, "TTL TimestampTime + toIntervalHour(1) GROUP BY ServiceName SET Body = any(Body)", cfg.ttlExprFor(cfg.LogsTableName, cfg.TTL, "TimestampTime"))
+	require.Empty(t, cfg.ttlExprFor("otel_metrics_gauge", cfg.TTL, "toDateTime(TimeUnix)"))
+}
+
+func TestTracesExporter_databaseEngine(t *testing.T) {
+	var queries []string
+	initClickhouseTestServer(t, func(query string, _ []driver.Value) error {
+		queries = append(queries, query)
+		return nil
+	})
+	newTestTracesExporter(t, defaultEndpoint, withDriverName(t.Name()), func(cfg *Config) {
+		cfg.Database = "otel"
+		cfg.ClusterName = "cluster"
+		cfg.TableEngine.Name = "ReplicatedMergeTree"
+		cfg.DatabaseEngine.Name = "Replicated"
+	})
+
+	require.Contains(t, queries, "CREATE DATABASE IF NOT EXISTS `otel` ON CLUSTER `cluster` ENGINE = Replicated('/clickhouse/databases/otel', '{shard}', '{replica}')")
 }
 
 func newTestTracesExporter(t *testing.T, dsn string, fns ...func(*Config)) *tracesExporter {
@@ -116,3 +467,207 @@ func TestTracesTableEngineConfig(t *testing.T) {
 		engineTest.verifyConfig(t, exporter.cfg.TableEngine)
 	})
 }
+
+func TestTracesExporter_createSchemaObjects(t *testing.T) {
+	var queries []string
+	initClickhouseTestServer(t, func(query string, _ []driver.Value) error {
+		queries = append(queries, getQueryFirstLine(query))
+		if strings.HasPrefix(getQueryFirstLine(query), "CREATE TABLE") {
+			require.NotContains(t, query, "INDEX ")
+			require.NotContains(t, query, ",\n)")
+		}
+		return nil
+	})
+	newTestTracesExporter(t, defaultEndpoint, withDriverName(t.Name()), func(cfg *Config) {
+		cfg.Database = "otel"
+		cfg.CreateSchemaObjects.Database = false
+		cfg.CreateSchemaObjects.MaterializedViews = false
+		cfg.CreateSchemaObjects.Indexes = false
+	})
+	require.Equal(t, []string{
+		"CREATE TABLE IF NOT EXISTS `otel_traces`",
+		"CREATE TABLE IF NOT EXISTS `otel_traces_trace_id_ts`",
+	}, queries)
+
+	driverName := t.Name()
+	t.Run("signal create_schema", func(t *testing.T) {
+		queries = nil
+		createSchema := false
+		newTestTracesExporter(t, defaultEndpoint, withDriverName(driverName), func(cfg *Config) {
+			cfg.Traces.CreateSchema = &createSchema
+		})
+		require.Empty(t, queries)
+	})
+}
+
+func TestTracesExporter_tableTemplate(t *testing.T) {
+	var queries []string
+	inserts := map[string]int{}
+	initClickhouseTestServer(t, func(query string, _ []driver.Value) error {
+		if strings.HasPrefix(query, "INSERT INTO") {
+			inserts[strings.Fields(query)[2]]++
+		} else {
+			queries = append(queries, getQueryFirstLine(query))
+		}
+		return nil
+	})
+	exporter := newTestTracesExporter(t, defaultEndpoint, withDriverName(t.Name()), func(cfg *Config) {
+		cfg.TracesTableName = "otel_traces_{service.name}"
+	})
+	require.Empty(t, queries)
+
+	mustPushTracesData(t, exporter, simpleTraces(3))
+	require.Equal(t, []string{
+		"CREATE TABLE IF NOT EXISTS `otel_traces_test_service`",
+		"CREATE TABLE IF NOT EXISTS `otel_traces_test_service_trace_id_ts`",
+		"CREATE MATERIALIZED VIEW IF NOT EXISTS `otel_traces_test_service_trace_id_ts_mv`",
+	}, queries)
+	require.Equal(t, map[string]int{"`otel_traces_test_service`": 3}, inserts)
+}
+
+func TestTracesExporter_routing(t *testing.T) {
+	var queries []string
+	inserts := map[string]int{}
+	initClickhouseTestServer(t, func(query string, _ []driver.Value) error {
+		if strings.HasPrefix(query, "INSERT INTO") {
+			inserts[strings.Fields(query)[2]]++
+		} else {
+			queries = append(queries, getQueryFirstLine(query))
+		}
+		return nil
+	})
+	exporter := newTestTracesExporter(t, defaultEndpoint, withDriverName(t.Name()), func(cfg *Config) {
+		cfg.Routing = RoutingConfig{
+			Attribute: "tenant.id",
+			Routes:    map[string]RouteConfig{"acme": {Database: "acme", TracesTableName: "spans"}},
+		}
+	})
+	queries = nil
+
+	td := simpleTraces(2)
+	td.ResourceSpans().At(0).Resource().Attributes().PutStr("tenant.id", "acme")
+	td.ResourceSpans().At(0).CopyTo(td.ResourceSpans().AppendEmpty())
+	td.ResourceSpans().At(1).Resource().Attributes().PutStr("tenant.id", "initech")
+	mustPushTracesData(t, exporter, td)
+	mustPushTracesData(t, exporter, td)
+
+	require.Equal(t, []string{
+		"CREATE DATABASE IF NOT EXISTS `acme`",
+		"CREATE TABLE IF NOT EXISTS `spans`",
+		"CREATE TABLE IF NOT EXISTS `spans_trace_id_ts`",
+		"CREATE MATERIALIZED VIEW IF NOT EXISTS `spans_trace_id_ts_mv`",
+	}, queries)
+	require.Equal(t, map[string]int{"`spans`": 4, "`otel_traces`": 4}, inserts)
+}
+
+func TestTracesExporter_tableRoutes(t *testing.T) {
+	var queries []string
+	inserts := map[string]int{}
+	initClickhouseTestServer(t, func(query string, _ []driver.Value) error {
+		if strings.HasPrefix(query, "INSERT INTO") {
+			inserts[strings.Fields(query)[2]]++
+		} else if line := getQueryFirstLine(query); strings.HasPrefix(line, "CREATE TABLE") {
+			queries = append(queries, line)
+		}
+		return nil
+	})
+	exporter := newTestTracesExporter(t, defaultEndpoint, withDriverName(t.Name()), func(cfg *Config) {
+		cfg.Traces.TableRoutes = []TableRouteConfig{
+			{Conditions: []string{`attributes["signal.tier"] == "audit"`}, TableName: "otel_audit_traces"},
+		}
+	})
+	require.Equal(t, []string{
+		"CREATE TABLE IF NOT EXISTS `otel_audit_traces`",
+		"CREATE TABLE IF NOT EXISTS `otel_audit_traces_trace_id_ts`",
+		"CREATE TABLE IF NOT EXISTS `otel_traces`",
+		"CREATE TABLE IF NOT EXISTS `otel_traces_trace_id_ts`",
+	}, queries)
+
+	td := simpleTraces(3)
+	td.ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(1).Attributes().PutStr("signal.tier", "audit")
+	mustPushTracesData(t, exporter, td)
+
+	require.Equal(t, map[string]int{"`otel_audit_traces`": 1, "`otel_traces`": 2}, inserts)
+}
+
+func TestTracesExporter_tenant(t *testing.T) {
+	var ddl string
+	var tenants []driver.Value
+	initClickhouseTestServer(t, func(query string, values []driver.Value) error {
+		if strings.HasPrefix(query, "INSERT") {
+			tenants = append(tenants, values[len(values)-1])
+		} else if strings.HasPrefix(getQueryFirstLine(query), "CREATE TABLE IF NOT EXISTS `otel_traces`") {
+			ddl = query
+		}
+		return nil
+	})
+	exporter := newTestTracesExporter(t, defaultEndpoint, withDriverName(t.Name()), func(cfg *Config) {
+		cfg.Tenant = TenantConfig{Enabled: true, Column: "Tenant", MetadataKey: "x-tenant-id", Partition: true}
+	})
+	require.Contains(t, ddl, "CODEC(ZSTD(1)),\n\tTenant LowCardinality(String) COMMENT 'Tenant of the client")
+	require.Contains(t, ddl, "PARTITION BY (Tenant, toDate(Timestamp))")
+	require.Contains(t, exporter.insertSQL, "Links.Attributes,\n                        Tenant\n")
+
+	ctx := client.NewContext(context.Background(), client.Info{
+		Metadata: client.NewMetadata(map[string][]string{"x-tenant-id": {"acme"}}),
+	})
+	require.NoError(t, exporter.pushTraceData(ctx, simpleTraces(2)))
+	mustPushTracesData(t, exporter, simpleTraces(1))
+	require.Equal(t, []driver.Value{"acme", "acme", ""}, tenants)
+}
+
+func TestTracesExporter_binaryIDs(t *testing.T) {
+	var ids []driver.Value
+	initClickhouseTestServer(t, func(query string, values []driver.Value) error {
+		if strings.HasPrefix(query, "INSERT") {
+			ids = values[1:4]
+		}
+		return nil
+	})
+	exporter := newTestTracesExporter(t, defaultEndpoint, withDriverName(t.Name()), func(cfg *Config) {
+		cfg.IDEncoding = "binary"
+	})
+	mustPushTracesData(t, exporter, simpleTraces(1))
+
+	require.Equal(t, []driver.Value{
+		string([]byte{1, 2, 3, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}),
+		string([]byte{1, 2, 3, 0, 0, 0, 0, 0}),
+		string([]byte{1, 2, 4, 0, 0, 0, 0, 0}),
+	}, ids)
+
+	ddl := renderCreateTracesTableSQL(exporter.cfg)
+	require.Contains(t, ddl, "\tTraceId FixedString(16) COMMENT 'Span.trace_id as raw bytes' CODEC(ZSTD(1)),\n")
+	require.Contains(t, ddl, "\tParentSpanId FixedString(8) COMMENT 'Span.parent_span_id as raw bytes'")
+	require.NotContains(t, ddl, "Id String")
+}
+
+func TestTracesExporter_maxAttributeValueBytes(t *testing.T) {
+	var spanAttrs []driver.Value
+	initClickhouseTestServer(t, func(query string, values []driver.Value) error {
+		if strings.HasPrefix(query, "INSERT") {
+			spanAttrs = append(spanAttrs, values[11])
+		}
+		return nil
+	})
+	reader := sdkmetric.NewManualReader()
+	exporter := newTestTracesExporter(t, defaultEndpoint, withDriverName(t.Name()), func(cfg *Config) {
+		cfg.MaxAttributeValueBytes = 4
+	})
+	exporter.telemetry = newTestTelemetry(t, reader)
+
+	td := simpleTraces(1)
+	td.ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0).Attributes().PutStr("http.request.body", `{"user":"jane"}`)
+	mustPushTracesData(t, exporter, td)
+
+	require.JSONEq(t, `{"service_name":"v","http_request_body":"{\"us...[truncated]"}`, spanAttrs[0].(string))
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	var truncated int64
+	for _, m := range rm.ScopeMetrics[0].Metrics {
+		if m.Name == "otelcol_exporter_clickhouse_truncated_attribute_values" {
+			truncated += m.Data.(metricdata.Sum[int64]).DataPoints[0].Value
+		}
+	}
+	require.Equal(t, int64(2), truncated, "the span attribute and the service.name resource attribute")
+}
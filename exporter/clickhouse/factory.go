@@ -14,6 +14,11 @@ import (
 	"go.opentelemetry.io/collector/config/configretry"
 	"go.opentelemetry.io/collector/exporter"
 	"go.opentelemetry.io/collector/exporter/exporterhelper"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	conventions "go.opentelemetry.io/otel/semconv/v1.27.0"
+	"go.uber.org/zap"
 
 	"github.com/foyer-work/otel-distribution/exporter/clickhouse/internal"
 	"github.com/foyer-work/otel-distribution/exporter/clickhouse/internal/metadata"
@@ -40,13 +45,41 @@ func createDefaultConfig() component.Config {
 		TimeoutSettings:  exporterhelper.NewDefaultTimeoutConfig(),
 		QueueSettings:    exporterhelper.NewDefaultQueueConfig(),
 		BackOffConfig:    configretry.NewDefaultBackOffConfig(),
+		DeadLetterQueue:  DeadLetterQueueConfig{MaxSizeMiB: 1024},
+		WriteAheadLog:    WriteAheadLogConfig{RetryInterval: 5 * time.Second},
 		ConnectionParams: map[string]string{},
 		Database:         defaultDatabase,
 		LogsTableName:    "otel_logs",
 		TracesTableName:  "otel_traces",
 		TTL:              0,
 		CreateSchema:     true,
-		AsyncInsert:      true,
+		CreateSchemaObjects: SchemaObjectsConfig{
+			Database:          true,
+			Tables:            true,
+			MaterializedViews: true,
+			Indexes:           true,
+		},
+		AsyncInsert:         true,
+		HealthCheckInterval: 30 * time.Second,
+		SchemaFallback:      true,
+		IDEncoding:          "hex",
+		DistributedDDL: DistributedDDLConfig{
+			TaskTimeout: 180 * time.Second,
+			OutputMode:  "throw",
+		},
+		Failover: FailoverConfig{
+			MaxFailures:   3,
+			ProbeInterval: 30 * time.Second,
+		},
+		TooManyPartsBackoff: TooManyPartsBackoffConfig{
+			Enabled:         true,
+			InitialInterval: time.Second,
+			MaxInterval:     time.Minute,
+		},
+		StartupRetry: StartupRetryConfig{
+			MaxAttempts: 1,
+			Interval:    5 * time.Second,
+		},
 		MetricsTables: MetricTablesConfig{
 			Gauge:                internal.MetricTypeConfig{Name: defaultMetricTableName + defaultGaugeSuffix},
 			Sum:                  internal.MetricTypeConfig{Name: defaultMetricTableName + defaultSumSuffix},
@@ -54,6 +87,42 @@ func createDefaultConfig() component.Config {
 			Histogram:            internal.MetricTypeConfig{Name: defaultMetricTableName + defaultHistogramSuffix},
 			ExponentialHistogram: internal.MetricTypeConfig{Name: defaultMetricTableName + defaultExpHistogramSuffix},
 		},
+		Logs: LogsConfig{
+			SignalConfig: SignalConfig{Enabled: true},
+			ErrorLogs:    ErrorLogsConfig{MinSeverity: "ERROR"},
+			RawRecord:    RawRecordConfig{Encoding: rawRecordEncodingProto},
+			Patterns:     PatternsConfig{SimilarityThreshold: 0.4, MaxPatterns: 10000},
+		},
+		Traces: TracesConfig{
+			SignalConfig: SignalConfig{Enabled: true},
+			Schema:       tracesSchemaOTel,
+			EventsLinks:  EventsLinksConfig{Mode: eventsLinksModeNested},
+			SpanNames:    SpanNamesConfig{OriginalAttribute: "span.original_name"},
+		},
+		Metrics: MetricsConfig{
+			SignalConfig:      SignalConfig{Enabled: true},
+			Exemplars:         ExemplarsConfig{Mode: string(internal.ExemplarsModeInline)},
+			DeltaToCumulative: DeltaToCumulativeConfig{MaxStale: 5 * time.Minute},
+			Rollups:           MetricsRollupsConfig{Intervals: []time.Duration{time.Minute, 5 * time.Minute, time.Hour}},
+			NonFiniteValues:   string(internal.NonFiniteKeep),
+			StaleDataPoints:   string(internal.StaleKeep),
+			SummaryMode:       summaryModeNested,
+			HistogramMode:     histogramModeArrays,
+			Schema:            metricsSchemaPerType,
+			CardinalityLimit: CardinalityLimitConfig{
+				MaxSeriesPerMetric: 10000,
+				MaxStale:           time.Hour,
+				Action:             string(internal.CardinalityDrop),
+			},
+		},
+		Tenant: TenantConfig{Column: "Tenant"},
+		Quotas: QuotasConfig{Burst: time.Second, Action: quotaActionDefer},
+		ServiceMetadata: ServiceMetadataConfig{
+			Attributes: []string{"owner", "team", "tier"},
+			Lifetime:   5 * time.Minute,
+		},
+		Rotation: RotationConfig{Period: rotationPeriodMonthly},
+		Shadow:   ShadowConfig{SampleRatio: 0.01},
 	}
 }
 
@@ -65,23 +134,40 @@ func createLogsExporter(
 	cfg component.Config,
 ) (exporter.Logs, error) {
 	c := cfg.(*Config)
+	if !c.Logs.Enabled {
+		return nil, fmt.Errorf("cannot configure clickhouse logs exporter: %w, set logs::enabled to true", errSignalDisabled)
+	}
 	c.collectorVersion = set.BuildInfo.Version
+	c.collectorInstanceID = instanceID(set)
 	exporter, err := newLogsExporter(set.Logger, c)
 	if err != nil {
 		return nil, fmt.Errorf("cannot configure clickhouse logs exporter: %w", err)
 	}
+	telemetry, err := newExporterTelemetry(set.TelemetrySettings)
+	if err != nil {
+		return nil, fmt.Errorf("cannot configure clickhouse logs exporter: %w", err)
+	}
+	exporter.setTelemetry(telemetry)
+
+	exporter.debug = newDebugStats(c, set.ID, "logs")
 
-	return exporterhelper.NewLogs(
+	primary := signalExporter[plog.Logs]{push: exporter.pushLogsData, start: exporter.start, shutdown: exporter.shutdown}
+	push, options, err := wrapPush(c, set, "logs", primary, exporter.telemetry, &exporter.health, exporter.debug, newLogsSignalExporter,
+		(&plog.ProtoMarshaler{}).MarshalLogs, (&plog.ProtoUnmarshaler{}).UnmarshalLogs, logsUsage)
+	if err != nil {
+		return nil, err
+	}
+	exp, err := exporterhelper.NewLogs(
 		ctx,
 		set,
 		cfg,
-		exporter.pushLogsData,
-		exporterhelper.WithStart(exporter.start),
-		exporterhelper.WithShutdown(exporter.shutdown),
-		exporterhelper.WithTimeout(c.TimeoutSettings),
-		exporterhelper.WithQueue(c.QueueSettings),
-		exporterhelper.WithRetry(c.BackOffConfig),
+		push,
+		append(options, exporterhelper.WithQueue(c.queueSettingsFor(c.Logs.SignalConfig)))...,
 	)
+	if err != nil {
+		return nil, err
+	}
+	return withQueueFullPolicyLogs(c, set.Logger, exporter.telemetry, exp), nil
 }
 
 // createTracesExporter creates a new exporter for traces.
@@ -92,23 +178,40 @@ func createTracesExporter(
 	cfg component.Config,
 ) (exporter.Traces, error) {
 	c := cfg.(*Config)
+	if !c.Traces.Enabled {
+		return nil, fmt.Errorf("cannot configure clickhouse traces exporter: %w, set traces::enabled to true", errSignalDisabled)
+	}
 	c.collectorVersion = set.BuildInfo.Version
+	c.collectorInstanceID = instanceID(set)
 	exporter, err := newTracesExporter(set.Logger, c)
 	if err != nil {
 		return nil, fmt.Errorf("cannot configure clickhouse traces exporter: %w", err)
 	}
+	telemetry, err := newExporterTelemetry(set.TelemetrySettings)
+	if err != nil {
+		return nil, fmt.Errorf("cannot configure clickhouse traces exporter: %w", err)
+	}
+	exporter.setTelemetry(telemetry)
 
-	return exporterhelper.NewTraces(
+	exporter.debug = newDebugStats(c, set.ID, "traces")
+
+	primary := signalExporter[ptrace.Traces]{push: exporter.pushTraceData, start: exporter.start, shutdown: exporter.shutdown}
+	push, options, err := wrapPush(c, set, "traces", primary, exporter.telemetry, &exporter.health, exporter.debug, newTracesSignalExporter,
+		(&ptrace.ProtoMarshaler{}).MarshalTraces, (&ptrace.ProtoUnmarshaler{}).UnmarshalTraces, tracesUsage)
+	if err != nil {
+		return nil, err
+	}
+	exp, err := exporterhelper.NewTraces(
 		ctx,
 		set,
 		cfg,
-		exporter.pushTraceData,
-		exporterhelper.WithStart(exporter.start),
-		exporterhelper.WithShutdown(exporter.shutdown),
-		exporterhelper.WithTimeout(c.TimeoutSettings),
-		exporterhelper.WithQueue(c.QueueSettings),
-		exporterhelper.WithRetry(c.BackOffConfig),
+		push,
+		append(options, exporterhelper.WithQueue(c.queueSettingsFor(c.Traces.SignalConfig)))...,
 	)
+	if err != nil {
+		return nil, err
+	}
+	return withQueueFullPolicyTraces(c, set.Logger, exporter.telemetry, exp), nil
 }
 
 func createMetricExporter(
@@ -117,23 +220,125 @@ func createMetricExporter(
 	cfg component.Config,
 ) (exporter.Metrics, error) {
 	c := cfg.(*Config)
+	if !c.Metrics.Enabled {
+		return nil, fmt.Errorf("cannot configure clickhouse metrics exporter: %w, set metrics::enabled to true", errSignalDisabled)
+	}
 	c.collectorVersion = set.BuildInfo.Version
+	c.collectorInstanceID = instanceID(set)
 	exporter, err := newMetricsExporter(set.Logger, c)
 	if err != nil {
 		return nil, fmt.Errorf("cannot configure clickhouse metrics exporter: %w", err)
 	}
+	if exporter.telemetry, err = newExporterTelemetry(set.TelemetrySettings); err != nil {
+		return nil, fmt.Errorf("cannot configure clickhouse metrics exporter: %w", err)
+	}
+
+	exporter.debug = newDebugStats(c, set.ID, "metrics")
 
-	return exporterhelper.NewMetrics(
+	primary := signalExporter[pmetric.Metrics]{push: exporter.pushMetricsData, start: exporter.start, shutdown: exporter.shutdown}
+	push, options, err := wrapPush(c, set, "metrics", primary, exporter.telemetry, &exporter.health, exporter.debug, newMetricsSignalExporter,
+		(&pmetric.ProtoMarshaler{}).MarshalMetrics, (&pmetric.ProtoUnmarshaler{}).UnmarshalMetrics, metricsUsage)
+	if err != nil {
+		return nil, err
+	}
+	exp, err := exporterhelper.NewMetrics(
 		ctx,
 		set,
 		cfg,
-		exporter.pushMetricsData,
-		exporterhelper.WithStart(exporter.start),
-		exporterhelper.WithShutdown(exporter.shutdown),
-		exporterhelper.WithTimeout(c.TimeoutSettings),
-		exporterhelper.WithQueue(c.QueueSettings),
-		exporterhelper.WithRetry(c.BackOffConfig),
+		push,
+		append(options, exporterhelper.WithQueue(c.queueSettingsFor(c.Metrics.SignalConfig)))...,
 	)
+	if err != nil {
+		return nil, err
+	}
+	return withQueueFullPolicyMetrics(c, set.Logger, exporter.telemetry, exp), nil
+}
+
+// signalExporter is the push and lifecycle of an exporter of a signal.
+type signalExporter[T any] struct {
+	push     func(context.Context, T) error
+	start    component.StartFunc
+	shutdown component.ShutdownFunc
+}
+
+// wrapPush wraps the push of the exporter of signal writing into the primary cluster with the mirror, shadow
+// tables, quotas, debug stats, retry count, throttle, dead letter queue and write-ahead log of the configuration.
+// newExporter creates the exporters of the mirror and shadow tables, marshal and unmarshal encode the batches
+// written to disk and usage measures them for the quotas. It returns the wrapped push with the start, shutdown
+// and retry options of the exporterhelper.
+func wrapPush[T comparable](
+	c *Config,
+	set exporter.Settings,
+	signal string,
+	primary signalExporter[T],
+	telemetry *exporterTelemetry,
+	health *healthReporter,
+	debug *debugStats,
+	newExporter func(*zap.Logger, *Config) (signalExporter[T], error),
+	marshal func(T) ([]byte, error),
+	unmarshal func([]byte) (T, error),
+	usage func(T) (rows, bytes int),
+) (func(context.Context, T) error, []exporterhelper.Option, error) {
+	var mirror, shadow *mirror[T]
+	if c.Mirror.Enabled {
+		mirrorExporter, err := newExporter(set.Logger.Named("mirror"), c.mirrorConfig())
+		if err != nil {
+			return nil, nil, fmt.Errorf("cannot configure clickhouse %s exporter mirror: %w", signal, err)
+		}
+		mirror = newMirror(c, signal, set.Logger, telemetry, mirrorExporter.push, mirrorExporter.start, mirrorExporter.shutdown)
+	}
+	if c.Shadow.Enabled {
+		shadowExporter, err := newExporter(set.Logger.Named("shadow"), c.shadowConfig())
+		if err != nil {
+			return nil, nil, fmt.Errorf("cannot configure clickhouse %s exporter shadow: %w", signal, err)
+		}
+		shadow = newShadow(c, signal, set.Logger, telemetry, shadowExporter.push, shadowExporter.start, shadowExporter.shutdown)
+	}
+
+	push := withHealthReport(health, shadow.wrap(mirror.wrap(primary.push)))
+	push = withQuotas(c, signal, set.Logger, telemetry, push, usage)
+	push = withDebugStats(debug, push)
+	push = withRetryCount(telemetry, c, signal, push)
+	push = withThrottle(newInsertThrottle(c.TooManyPartsBackoff, signal, set.Logger, telemetry), push)
+	push, retryOptions := withDeadLetterQueue(c, signal, set.Logger, push, marshal)
+	push, wal := withWriteAheadLog(c, signal, set.Logger, push, marshal, unmarshal)
+	start, shutdown := wal.lifecycle(shadow.lifecycle(mirror.lifecycle(primary.start, primary.shutdown)))
+	return push, append([]exporterhelper.Option{
+		exporterhelper.WithStart(start),
+		exporterhelper.WithShutdown(shutdown),
+	}, retryOptions...), nil
+}
+
+func newLogsSignalExporter(logger *zap.Logger, cfg *Config) (signalExporter[plog.Logs], error) {
+	e, err := newLogsExporter(logger, cfg)
+	if err != nil {
+		return signalExporter[plog.Logs]{}, err
+	}
+	return signalExporter[plog.Logs]{push: e.pushLogsData, start: e.start, shutdown: e.shutdown}, nil
+}
+
+func newTracesSignalExporter(logger *zap.Logger, cfg *Config) (signalExporter[ptrace.Traces], error) {
+	e, err := newTracesExporter(logger, cfg)
+	if err != nil {
+		return signalExporter[ptrace.Traces]{}, err
+	}
+	return signalExporter[ptrace.Traces]{push: e.pushTraceData, start: e.start, shutdown: e.shutdown}, nil
+}
+
+func newMetricsSignalExporter(logger *zap.Logger, cfg *Config) (signalExporter[pmetric.Metrics], error) {
+	e, err := newMetricsExporter(logger, cfg)
+	if err != nil {
+		return signalExporter[pmetric.Metrics]{}, err
+	}
+	return signalExporter[pmetric.Metrics]{push: e.pushMetricsData, start: e.start, shutdown: e.shutdown}, nil
+}
+
+// instanceID returns the service.instance.id of the collector, empty if unknown.
+func instanceID(set exporter.Settings) string {
+	if id, ok := set.Resource.Attributes().Get(string(conventions.ServiceInstanceIDKey)); ok {
+		return id.AsString()
+	}
+	return ""
 }
 
 func generateTTLExpr(ttl time.Duration, timeField string) string {
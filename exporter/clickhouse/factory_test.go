@@ -11,6 +11,7 @@ import (
 	"github.com/stretchr/testify/require"
 	"go.opentelemetry.io/collector/component/componenttest"
 	"go.opentelemetry.io/collector/exporter/exportertest"
+	conventions "go.opentelemetry.io/otel/semconv/v1.27.0"
 
 	"github.com/foyer-work/otel-distribution/exporter/clickhouse/internal/metadata"
 )
@@ -60,3 +61,44 @@ func TestFactory_CreateMetrics(t *testing.T) {
 
 	require.NoError(t, exporter.Shutdown(context.TODO()))
 }
+
+func TestFactory_DisabledSignals(t *testing.T) {
+	factory := NewFactory()
+	cfg := withDefaultConfig(func(cfg *Config) {
+		cfg.Endpoint = defaultEndpoint
+		cfg.Traces.Enabled = false
+		cfg.Metrics.Enabled = false
+	})
+	require.NoError(t, cfg.Validate())
+	params := exportertest.NewNopSettings(metadata.Type)
+
+	_, err := factory.CreateTraces(context.Background(), params, cfg)
+	require.ErrorIs(t, err, errSignalDisabled)
+	_, err = factory.CreateMetrics(context.Background(), params, cfg)
+	require.ErrorIs(t, err, errSignalDisabled)
+
+	exporter, err := factory.CreateLogs(context.Background(), params, cfg)
+	require.NoError(t, err)
+	require.NoError(t, exporter.Shutdown(context.TODO()))
+
+	cfg.Logs.Enabled = false
+	require.ErrorIs(t, cfg.Validate(), errConfigNoSignals)
+}
+
+func TestFactory_QueryIDPrefix(t *testing.T) {
+	factory := NewFactory()
+	cfg := withDefaultConfig(func(cfg *Config) {
+		cfg.Endpoint = defaultEndpoint
+	})
+	require.Equal(t, "otelcol", cfg.queryIDPrefix())
+
+	params := exportertest.NewNopSettings(metadata.Type)
+	params.Resource.Attributes().PutStr(string(conventions.ServiceInstanceIDKey), "627cc493-f310-47de-96bd-71410b7dec09")
+	exporter, err := factory.CreateLogs(context.Background(), params, cfg)
+	require.NoError(t, err)
+	require.NoError(t, exporter.Shutdown(context.TODO()))
+	require.Equal(t, "otelcol-627cc493-f310-47de-96bd-71410b7dec09", cfg.queryIDPrefix())
+
+	cfg.QueryIDPrefix = "ingest-eu1"
+	require.Equal(t, "ingest-eu1", cfg.queryIDPrefix())
+}
@@ -0,0 +1,111 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package clickhouseexporter // import "github.com/foyer-work/otel-distribution/exporter/clickhouse"
+
+import (
+	"context"
+	"database/sql/driver"
+	"sync"
+	"time"
+)
+
+// dsnConnector opens connections of a registered driver by DSN.
+type dsnConnector struct {
+	dsn    string
+	driver driver.Driver
+}
+
+func (c dsnConnector) Connect(context.Context) (driver.Conn, error) {
+	return c.driver.Open(c.dsn)
+}
+
+func (c dsnConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+// failoverConnector connects to a single endpoint at a time, in order of preference.
+// After maxFailures consecutive connection failures it switches to the next endpoint,
+// and every probeInterval it tries the preferred endpoints again before the active one.
+// Broken connections are discarded by the driver, so query errors and timeouts on the
+// active endpoint surface as connection failures once the pool reconnects.
+type failoverConnector struct {
+	connectors    []driver.Connector
+	maxFailures   int
+	probeInterval time.Duration
+
+	mu        sync.Mutex
+	active    int
+	failures  int
+	lastProbe time.Time
+}
+
+func newFailoverConnector(connectors []driver.Connector, cfg FailoverConfig) *failoverConnector {
+	return &failoverConnector{
+		connectors:    connectors,
+		maxFailures:   cfg.MaxFailures,
+		probeInterval: cfg.ProbeInterval,
+	}
+}
+
+func (c *failoverConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	c.mu.Lock()
+	active := c.active
+	probe := active > 0 && time.Since(c.lastProbe) >= c.probeInterval
+	if probe {
+		c.lastProbe = time.Now()
+	}
+	c.mu.Unlock()
+
+	if probe {
+		for i := range active {
+			if conn, err := c.connectors[i].Connect(ctx); err == nil {
+				c.recordSuccess(i)
+				return conn, nil
+			}
+		}
+	}
+
+	conn, err := c.connectors[active].Connect(ctx)
+	if err != nil {
+		c.recordFailure(active)
+		return nil, err
+	}
+	c.recordSuccess(active)
+	return conn, nil
+}
+
+func (c *failoverConnector) Driver() driver.Driver {
+	return c.connectors[0].Driver()
+}
+
+// activeEndpoint returns the index of the endpoint new connections are opened to.
+func (c *failoverConnector) activeEndpoint() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.active
+}
+
+func (c *failoverConnector) recordSuccess(endpoint int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if endpoint <= c.active {
+		c.active = endpoint
+		c.failures = 0
+	}
+}
+
+func (c *failoverConnector) recordFailure(endpoint int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if endpoint != c.active {
+		return
+	}
+	c.failures++
+	if c.failures < c.maxFailures {
+		return
+	}
+	c.active = (c.active + 1) % len(c.connectors)
+	c.failures = 0
+	c.lastProbe = time.Now()
+}
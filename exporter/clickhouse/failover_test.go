@@ -0,0 +1,74 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package clickhouseexporter
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testEndpointConnector struct {
+	down     bool
+	connects int
+}
+
+func (c *testEndpointConnector) Connect(context.Context) (driver.Conn, error) {
+	c.connects++
+	if c.down {
+		return nil, errors.New("connection refused")
+	}
+	return &testClickhouseDriverConn{}, nil
+}
+
+func (*testEndpointConnector) Driver() driver.Driver {
+	return &testClickhouseDriver{}
+}
+
+func TestFailoverConnector(t *testing.T) {
+	primary, replica := &testEndpointConnector{down: true}, &testEndpointConnector{}
+	connector := newFailoverConnector([]driver.Connector{primary, replica}, FailoverConfig{
+		MaxFailures:   2,
+		ProbeInterval: time.Hour,
+	})
+
+	_, err := connector.Connect(context.Background())
+	require.Error(t, err)
+	require.Equal(t, 0, connector.activeEndpoint())
+	_, err = connector.Connect(context.Background())
+	require.Error(t, err)
+	require.Equal(t, 1, connector.activeEndpoint())
+
+	_, err = connector.Connect(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 2, primary.connects, "primary is not probed before the probe interval")
+
+	t.Run("probe recovered endpoint", func(t *testing.T) {
+		primary.down = false
+		connector.probeInterval = 0
+		_, err := connector.Connect(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, 0, connector.activeEndpoint())
+		require.Equal(t, 1, replica.connects)
+	})
+}
+
+func TestConfig_ValidateFailover(t *testing.T) {
+	cfg := withDefaultConfig(func(cfg *Config) {
+		cfg.Endpoints = []string{"ch-0:9000"}
+		cfg.Failover.Enabled = true
+	})
+	require.ErrorIs(t, cfg.Validate(), errConfigFailover)
+
+	cfg.Endpoints = append(cfg.Endpoints, "ch-1:9000")
+	require.NoError(t, cfg.Validate())
+
+	db, err := cfg.buildDB()
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+}
@@ -0,0 +1,121 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package clickhouseexporter // import "github.com/foyer-work/otel-distribution/exporter/clickhouse"
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componentstatus"
+	"go.opentelemetry.io/collector/consumer/consumererror"
+	"go.uber.org/zap"
+)
+
+// permanentExceptionCodes are the clickhouse error codes requiring a configuration or schema change to recover from.
+var permanentExceptionCodes = map[int32]bool{
+	60:  true, // UNKNOWN_TABLE
+	81:  true, // UNKNOWN_DATABASE
+	192: true, // UNKNOWN_USER
+	193: true, // WRONG_PASSWORD
+	497: true, // ACCESS_DENIED
+	516: true, // AUTHENTICATION_FAILED
+}
+
+// healthReporter reports the health of the clickhouse backend through the component status,
+// from the outcome of inserts and from connectivity probes. Only status changes are reported.
+// The zero value doesn't report anything until started.
+type healthReporter struct {
+	mu     sync.Mutex
+	host   component.Host
+	status componentstatus.Status
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// start reports to host from now on, probing the backend with probe every interval unless interval is zero.
+func (h *healthReporter) start(host component.Host, logger *zap.Logger, interval, timeout time.Duration, probe func(context.Context) error) {
+	h.mu.Lock()
+	h.host = host
+	h.mu.Unlock()
+	if interval <= 0 {
+		return
+	}
+
+	var ctx context.Context
+	ctx, h.cancel = context.WithCancel(context.Background())
+	h.done = make(chan struct{})
+	go func() {
+		defer close(h.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			probeCtx, cancel := ctx, context.CancelFunc(func() {})
+			if timeout > 0 {
+				probeCtx, cancel = context.WithTimeout(ctx, timeout)
+			}
+			err := probe(probeCtx)
+			cancel()
+			if ctx.Err() != nil {
+				return
+			}
+			if err != nil {
+				logger.Debug("clickhouse health probe failed", zap.Error(err))
+			}
+			h.report(err)
+		}
+	}()
+}
+
+// stop stops the probes.
+func (h *healthReporter) stop() {
+	if h.cancel == nil {
+		return
+	}
+	h.cancel()
+	<-h.done
+}
+
+// report reports the status matching the outcome of an insert or probe.
+func (h *healthReporter) report(err error) {
+	event := healthEvent(err)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.host == nil || event.Status() == h.status {
+		return
+	}
+	h.status = event.Status()
+	componentstatus.ReportStatus(h.host, event)
+}
+
+// healthEvent classifies err: errors that retrying can't fix are permanent, other errors are recoverable.
+func healthEvent(err error) *componentstatus.Event {
+	if err == nil {
+		return componentstatus.NewEvent(componentstatus.StatusOK)
+	}
+	var exception *clickhouse.Exception
+	if consumererror.IsPermanent(err) || (errors.As(err, &exception) && permanentExceptionCodes[exception.Code]) {
+		return componentstatus.NewPermanentErrorEvent(err)
+	}
+	return componentstatus.NewRecoverableErrorEvent(err)
+}
+
+// withHealthReport wraps push to report the outcome of each insert through h.
+func withHealthReport[T any](h *healthReporter, push func(context.Context, T) error) func(context.Context, T) error {
+	return func(ctx context.Context, data T) error {
+		err := push(ctx, data)
+		if !errors.Is(err, errNotReady) {
+			h.report(err)
+		}
+		return err
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package clickhouseexporter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component/componentstatus"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer/consumererror"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestHealthReporter(t *testing.T) {
+	host := &statusHost{Host: componenttest.NewNopHost()}
+	var health healthReporter
+	health.report(errors.New("not started"))
+
+	health.start(host, zaptest.NewLogger(t), 0, 0, nil)
+	push := withHealthReport(&health, func(_ context.Context, err error) error { return err })
+	for _, err := range []error{
+		nil,
+		nil,
+		errNotReady,
+		errors.New("connection refused"),
+		errors.New("connection reset"),
+		nil,
+		fmt.Errorf("insert: %w", &clickhouse.Exception{Code: 516, Message: "authentication failed"}),
+	} {
+		_ = push(context.Background(), err)
+	}
+	health.stop()
+
+	var statuses []componentstatus.Status
+	for _, event := range host.events {
+		statuses = append(statuses, event.Status())
+	}
+	require.Equal(t, []componentstatus.Status{
+		componentstatus.StatusOK,
+		componentstatus.StatusRecoverableError,
+		componentstatus.StatusOK,
+		componentstatus.StatusPermanentError,
+	}, statuses, "only status changes are reported")
+	require.EqualError(t, host.events[1].Err(), "connection refused")
+
+	t.Run("probe", func(t *testing.T) {
+		host := &statusHost{Host: componenttest.NewNopHost()}
+		var health healthReporter
+		probes := make(chan struct{}, 1)
+		health.start(host, zaptest.NewLogger(t), time.Millisecond, time.Second, func(ctx context.Context) error {
+			_, hasDeadline := ctx.Deadline()
+			require.True(t, hasDeadline)
+			select {
+			case probes <- struct{}{}:
+			default:
+			}
+			return consumererror.NewPermanent(errors.New("access denied"))
+		})
+		<-probes
+		<-probes
+		health.stop()
+
+		require.Len(t, host.events, 1)
+		require.Equal(t, componentstatus.StatusPermanentError, host.events[0].Status())
+	})
+}
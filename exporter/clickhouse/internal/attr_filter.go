@@ -0,0 +1,152 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package internal // import "github.com/foyer-work/otel-distribution/exporter/clickhouse/internal"
+
+import (
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"unicode/utf8"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+)
+
+// TruncatedValueMarker is appended to the string attribute values truncated by an AttributeFilter.
+const TruncatedValueMarker = "...[truncated]"
+
+// AttributeFilter removes attribute keys, truncates attribute values and flattens map values before they are
+// written. Patterns are matched against the whole key, `*` matching any characters, e.g. `http.request.header.*`.
+// A nil AttributeFilter keeps every key and value.
+type AttributeFilter struct {
+	include       *regexp.Regexp
+	exclude       *regexp.Regexp
+	maxValueBytes int
+	flatten       bool
+	// truncated counts the values truncated, the filter being shared by concurrent inserts.
+	truncated atomic.Int64
+}
+
+// NewAttributeFilter returns a filter keeping the keys matching a pattern of include, or every key if include
+// is empty, except those matching a pattern of exclude, and truncating the string and bytes values longer than
+// maxValueBytes, unless 0. If flatten is set, the map values are replaced by an attribute per entry, their keys
+// joined with dots, e.g. `k8s.labels.app`, the patterns applying to both the map and its entries.
+// It returns nil if it would change no attribute.
+func NewAttributeFilter(include, exclude []string, maxValueBytes int, flatten bool) *AttributeFilter {
+	if len(include) == 0 && len(exclude) == 0 && maxValueBytes <= 0 && !flatten {
+		return nil
+	}
+	return &AttributeFilter{
+		include:       globsRegexp(include),
+		exclude:       globsRegexp(exclude),
+		maxValueBytes: maxValueBytes,
+		flatten:       flatten,
+	}
+}
+
+// globsRegexp returns the regexp matching any of patterns, nil if there are none.
+func globsRegexp(patterns []string) *regexp.Regexp {
+	if len(patterns) == 0 {
+		return nil
+	}
+	alternatives := make([]string, 0, len(patterns))
+	for _, pattern := range patterns {
+		alternatives = append(alternatives, strings.ReplaceAll(regexp.QuoteMeta(pattern), `\*`, ".*"))
+	}
+	return regexp.MustCompile("^(?:" + strings.Join(alternatives, "|") + ")$")
+}
+
+// Keep reports whether the attribute key is written.
+func (f *AttributeFilter) Keep(key string) bool {
+	if f == nil {
+		return true
+	}
+	if f.include != nil && !f.include.MatchString(key) {
+		return false
+	}
+	return f.exclude == nil || !f.exclude.MatchString(key)
+}
+
+// flattens reports whether the attribute value v is replaced by an attribute per entry.
+func (f *AttributeFilter) flattens(v pcommon.Value) bool {
+	return f != nil && f.flatten && v.Type() == pcommon.ValueTypeMap
+}
+
+// JSON serializes the attributes kept as AttributesToJSON does, their values truncated and flattened.
+func (f *AttributeFilter) JSON(attributes pcommon.Map) string {
+	return attributesJSON(attributes, f)
+}
+
+// Map returns the attributes kept as the string values of a ClickHouse Map(String, String) column, keyed by
+// their original keys, their values formatted by pcommon.Value.AsString, truncated and flattened.
+func (f *AttributeFilter) Map(attributes pcommon.Map) map[string]string {
+	m := make(map[string]string, attributes.Len())
+	f.putMap(m, "", attributes)
+	return m
+}
+
+// putMap puts the attributes kept into m, their keys prefixed by prefix.
+func (f *AttributeFilter) putMap(m map[string]string, prefix string, attributes pcommon.Map) {
+	for k, v := range attributes.All() {
+		key := prefix + k
+		if !f.Keep(key) {
+			continue
+		}
+		if f.flattens(v) {
+			f.putMap(m, key+".", v.Map())
+			continue
+		}
+		m[key] = f.Truncate(v.AsString()).(string)
+	}
+}
+
+// Truncate returns the raw attribute value v with its strings longer than the maximum cut on a UTF-8 character
+// boundary and followed by TruncatedValueMarker, and its bytes longer than the maximum cut, recursively in the
+// maps and slices.
+func (f *AttributeFilter) Truncate(v any) any {
+	if f == nil || f.maxValueBytes <= 0 {
+		return v
+	}
+	switch v := v.(type) {
+	case string:
+		if s, ok := TruncateUTF8(v, f.maxValueBytes); ok {
+			f.truncated.Add(1)
+			return s + TruncatedValueMarker
+		}
+	case []byte:
+		if len(v) > f.maxValueBytes {
+			f.truncated.Add(1)
+			return v[:f.maxValueBytes]
+		}
+	case map[string]any:
+		for key, value := range v {
+			v[key] = f.Truncate(value)
+		}
+	case []any:
+		for i, value := range v {
+			v[i] = f.Truncate(value)
+		}
+	}
+	return v
+}
+
+// Truncated returns the number of values truncated by the filter.
+func (f *AttributeFilter) Truncated() int64 {
+	if f == nil {
+		return 0
+	}
+	return f.truncated.Load()
+}
+
+// TruncateUTF8 returns s cut to at most maxBytes bytes on a UTF-8 character boundary, and whether it was cut.
+// A maxBytes of 0 keeps s.
+func TruncateUTF8(s string, maxBytes int) (string, bool) {
+	if maxBytes <= 0 || len(s) <= maxBytes {
+		return s, false
+	}
+	end := maxBytes
+	for end > 0 && !utf8.RuneStart(s[end]) {
+		end--
+	}
+	return s[:end], true
+}
@@ -0,0 +1,88 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+)
+
+func TestAttributeFilter(t *testing.T) {
+	attrs := pcommon.NewMap()
+	attrs.PutStr("http.request.header.authorization", "Bearer secret")
+	attrs.PutStr("http.request.method", "GET")
+	attrs.PutStr("user.id", "42")
+	attrs.PutStr("user.email", "jane@example.com")
+
+	var nilFilter *AttributeFilter
+	require.Nil(t, NewAttributeFilter(nil, nil, 0, false))
+	require.Equal(t, AttributesToJSON(attrs), nilFilter.JSON(attrs))
+
+	exclude := NewAttributeFilter(nil, []string{"http.request.header.*", "user.email"}, 0, false)
+	require.JSONEq(t, `{"http_request_method":"GET","user_id":"42"}`, exclude.JSON(attrs))
+
+	include := NewAttributeFilter([]string{"http.*", "user.id"}, []string{"http.request.header.*"}, 0, false)
+	require.JSONEq(t, `{"http_request_method":"GET","user_id":"42"}`, include.JSON(attrs))
+	require.False(t, include.Keep("user.idx"), "patterns match the whole key")
+	require.False(t, include.Keep("user_id"), "dots are literal")
+}
+
+func TestAttributeFilter_maxValueBytes(t *testing.T) {
+	attrs := pcommon.NewMap()
+	attrs.PutStr("payload", "héllo world")
+	attrs.PutStr("method", "GET")
+	attrs.PutInt("size", 1234567)
+	attrs.PutEmptyBytes("raw").FromRaw([]byte("0123456789"))
+	attrs.PutEmptySlice("tags").FromRaw([]any{"short", "much too long"})
+
+	filter := NewAttributeFilter(nil, nil, 5, false)
+	require.JSONEq(t, `{
+		"payload": "h`+"é"+`ll...[truncated]",
+		"method": "GET",
+		"size": 1234567,
+		"raw": "MDEyMzQ=",
+		"tags": ["short", "much ...[truncated]"]
+	}`, filter.JSON(attrs))
+	require.Equal(t, int64(3), filter.Truncated())
+	v, _ := attrs.Get("payload")
+	require.Equal(t, "héllo world", v.Str(), "the attributes are not modified")
+}
+
+func TestAttributeFilter_Map(t *testing.T) {
+	attrs := pcommon.NewMap()
+	attrs.PutStr("http.method", "GET")
+	attrs.PutInt("http.status_code", 200)
+	attrs.PutBool("error", false)
+	attrs.PutStr("user.email", "jane@example.com")
+	labels := attrs.PutEmptyMap("k8s.labels")
+	labels.PutStr("app", "checkout")
+
+	var nilFilter *AttributeFilter
+	require.Equal(t, map[string]string{
+		"http.method":      "GET",
+		"http.status_code": "200",
+		"error":            "false",
+		"user.email":       "jane@example.com",
+		"k8s.labels":       `{"app":"checkout"}`,
+	}, nilFilter.Map(attrs))
+
+	filter := NewAttributeFilter(nil, []string{"user.*"}, 3, true)
+	require.Equal(t, map[string]string{
+		"http.method":      "GET",
+		"http.status_code": "200",
+		"error":            "fal" + TruncatedValueMarker,
+		"k8s.labels.app":   "che" + TruncatedValueMarker,
+	}, filter.Map(attrs))
+}
+
+func TestTruncateUTF8(t *testing.T) {
+	s, truncated := TruncateUTF8("héllo", 2)
+	require.True(t, truncated)
+	require.Equal(t, "h", s)
+	s, truncated = TruncateUTF8("héllo", 0)
+	require.False(t, truncated)
+	require.Equal(t, "héllo", s)
+}
@@ -0,0 +1,42 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package internal // import "github.com/foyer-work/otel-distribution/exporter/clickhouse/internal"
+
+import (
+	"hash/fnv"
+	"regexp"
+	"strings"
+)
+
+// attrHashColumn is the definition of the AttrHash column, added after the Attributes column.
+const attrHashColumn = "AttrHash UInt64 CODEC(ZSTD(1)),"
+
+var attributesColumnRegexp = regexp.MustCompile(`(?m)^(\s*)Attributes .*,$`)
+
+// AttributesHash returns the 64-bit FNV-1a hash of datapoint attributes serialized by AttributesToJSON,
+// which sorts the keys, so that equal attribute sets have the same hash.
+func AttributesHash(attrs string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(attrs))
+	return h.Sum64()
+}
+
+// addAttrHashColumn adds the AttrHash column after the Attributes column of the CREATE TABLE statement
+// ddl of a metric table, and to its sorting key before Attributes if orderBy is set.
+func addAttrHashColumn(ddl string, orderBy bool) string {
+	ddl = attributesColumnRegexp.ReplaceAllString(ddl, "$0\n${1}"+attrHashColumn)
+	if orderBy {
+		ddl = strings.Replace(ddl, "ORDER BY (ServiceName, MetricName, Attributes,", "ORDER BY (ServiceName, MetricName, AttrHash, Attributes,", 1)
+	}
+	return ddl
+}
+
+// withInsertColumn adds column to the column list of the INSERT statement query, its value being bound last.
+func withInsertColumn(query, column string) string {
+	i := strings.LastIndex(query, ") VALUES (")
+	if i < 0 {
+		return query
+	}
+	return query[:i] + ",\n    " + column + strings.TrimSuffix(query[i:], ")") + ",?)"
+}
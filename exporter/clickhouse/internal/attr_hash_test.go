@@ -0,0 +1,34 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package internal
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+)
+
+func TestAttributesHash(t *testing.T) {
+	a, b := pcommon.NewMap(), pcommon.NewMap()
+	a.PutStr("host", "a")
+	a.PutInt("cpu", 1)
+	b.PutInt("cpu", 1)
+	b.PutStr("host", "a")
+	require.Equal(t, AttributesHash(AttributesToJSON(a)), AttributesHash(AttributesToJSON(b)), "the hash doesn't depend on the order of the attributes")
+	b.PutInt("cpu", 2)
+	require.NotEqual(t, AttributesHash(AttributesToJSON(a)), AttributesHash(AttributesToJSON(b)))
+}
+
+func TestAddAttrHashColumn(t *testing.T) {
+	ddl := fmt.Sprintf(createGaugeTableSQL, "`otel_metrics_gauge`", "", "", "MergeTree()", "", "toDate(TimeUnix)")
+	require.Contains(t, addAttrHashColumn(ddl, false), "\tAttributes JSON,\n\tAttrHash UInt64 CODEC(ZSTD(1)),\n")
+	require.Contains(t, addAttrHashColumn(ddl, false), "ORDER BY (ServiceName, MetricName, Attributes, ")
+	require.Contains(t, addAttrHashColumn(ddl, true), "ORDER BY (ServiceName, MetricName, AttrHash, Attributes, ")
+
+	insert := withInsertColumn(fmt.Sprintf(insertSummaryTableSQL, "`otel_metrics_summary`"), "AttrHash")
+	require.True(t, strings.HasSuffix(insert, "    Flags,\n    AttrHash) VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)"), insert)
+}
@@ -0,0 +1,101 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package internal // import "github.com/foyer-work/otel-distribution/exporter/clickhouse/internal"
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+)
+
+// AttributesToJSON serializes attributes as a JSON object, the dots of their keys replaced by '_'.
+// The encoding is canonical, equal attribute sets being serialized to the same bytes whatever their order:
+// keys are sorted, numbers have their shortest representation, NaN and infinite doubles are written as the
+// strings "NaN", "+Inf" and "-Inf", negative zero as 0, and of the keys equal once their dots replaced the
+// smallest original key wins.
+func AttributesToJSON(attributes pcommon.Map) string {
+	return attributesJSON(attributes, nil)
+}
+
+// attributesJSON serializes the attributes kept by filter as AttributesToJSON.
+func attributesJSON(attributes pcommon.Map, filter *AttributeFilter) string {
+	w := attributesWriter{filter: filter, rawMap: make(map[string]any, attributes.Len())}
+	for k, v := range attributes.All() {
+		w.add(k, k, v)
+	}
+	// encoding/json sorts the keys of maps.
+	jsonString, _ := json.Marshal(w.rawMap)
+	return string(jsonString)
+}
+
+// attributesWriter builds the JSON object of attributes.
+type attributesWriter struct {
+	filter *AttributeFilter
+	rawMap map[string]any
+	// sources holds the source of the keys whose source isn't the key itself, the original key if it had dots,
+	// or the keys of the flattened maps and of their entry joined with '\x00'. Of the attributes having the same
+	// key the one with the smallest source wins.
+	sources map[string]string
+}
+
+// add writes the attribute key from source with value v, or an attribute per entry if v is a map flattened.
+func (w *attributesWriter) add(key, source string, v pcommon.Value) {
+	if !w.filter.Keep(key) {
+		return
+	}
+	if w.filter.flattens(v) {
+		for k, entry := range v.Map().All() {
+			w.add(key+"."+k, source+"\x00"+k, entry)
+		}
+		return
+	}
+	replaced := strings.ReplaceAll(key, ".", "_")
+	if _, ok := w.rawMap[replaced]; ok {
+		previous, ok := w.sources[replaced]
+		if !ok {
+			previous = replaced
+		}
+		if previous < source {
+			return
+		}
+	}
+	if source != replaced {
+		if w.sources == nil {
+			w.sources = map[string]string{}
+		}
+		w.sources[replaced] = source
+	} else {
+		delete(w.sources, replaced)
+	}
+	w.rawMap[replaced] = canonicalValue(w.filter.Truncate(v.AsRaw()))
+}
+
+// canonicalValue replaces the doubles of the raw attribute value v that JSON can't represent, or that have
+// two representations, recursively in the maps and slices.
+func canonicalValue(v any) any {
+	switch v := v.(type) {
+	case float64:
+		switch {
+		case math.IsNaN(v):
+			return "NaN"
+		case math.IsInf(v, 1):
+			return "+Inf"
+		case math.IsInf(v, -1):
+			return "-Inf"
+		case v == 0:
+			return float64(0)
+		}
+	case map[string]any:
+		for key, value := range v {
+			v[key] = canonicalValue(value)
+		}
+	case []any:
+		for i, value := range v {
+			v[i] = canonicalValue(value)
+		}
+	}
+	return v
+}
@@ -0,0 +1,63 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package internal
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+)
+
+func TestAttributesToJSON_canonical(t *testing.T) {
+	a := pcommon.NewMap()
+	a.PutDouble("ratio", 0.5)
+	a.PutStr("http.method", "GET")
+	a.PutStr("http_method", "POST")
+	a.PutDouble("zero", math.Copysign(0, -1))
+	a.PutEmptyMap("nested").FromRaw(map[string]any{"b": 1.0, "a": math.NaN()})
+	a.PutEmptySlice("bounds").FromRaw([]any{math.Inf(-1), 1e21, math.Inf(1)})
+
+	b := pcommon.NewMap()
+	b.PutEmptySlice("bounds").FromRaw([]any{math.Inf(-1), 1e21, math.Inf(1)})
+	b.PutEmptyMap("nested").FromRaw(map[string]any{"a": math.NaN(), "b": 1.0})
+	b.PutDouble("zero", 0)
+	b.PutStr("http_method", "POST")
+	b.PutStr("http.method", "GET")
+	b.PutDouble("ratio", 0.5)
+
+	const expected = `{"bounds":["-Inf",1e+21,"+Inf"],"http_method":"GET","nested":{"a":"NaN","b":1},"ratio":0.5,"zero":0}`
+	require.Equal(t, expected, AttributesToJSON(a))
+	require.Equal(t, expected, AttributesToJSON(b), "equal attribute sets are serialized to the same bytes whatever their order")
+}
+
+func TestAttributesToJSON_flatten(t *testing.T) {
+	attrs := pcommon.NewMap()
+	attrs.PutStr("k8s.pod.name", "api-0")
+	labels := attrs.PutEmptyMap("k8s.labels")
+	labels.PutStr("app", "api")
+	labels.PutStr("secret", "hunter2")
+	labels.PutEmptyMap("team").PutStr("name", "core")
+	attrs.PutEmptySlice("tags").FromRaw([]any{map[string]any{"a": 1}})
+
+	filter := NewAttributeFilter(nil, []string{"k8s.labels.secret"}, 0, true)
+	require.Equal(t, `{"k8s_labels_app":"api","k8s_labels_team_name":"core","k8s_pod_name":"api-0","tags":[{"a":1}]}`, filter.JSON(attrs))
+
+	tags := jaegerTags(attrs, filter)
+	require.Equal(t, []JaegerKeyValue{
+		{Key: "k8s.pod.name", VStr: "api-0"},
+		{Key: "k8s.labels.app", VStr: "api"},
+		{Key: "k8s.labels.team.name", VStr: "core"},
+		{Key: "tags", VStr: `[{"a":1}]`},
+	}, tags)
+
+	collision := pcommon.NewMap()
+	collision.PutStr("a.b", "literal")
+	collision.PutEmptyMap("a").PutStr("b", "flattened")
+	reversed := pcommon.NewMap()
+	reversed.PutEmptyMap("a").PutStr("b", "flattened")
+	reversed.PutStr("a.b", "literal")
+	require.Equal(t, filter.JSON(collision), filter.JSON(reversed), "colliding keys are resolved whatever their order")
+}
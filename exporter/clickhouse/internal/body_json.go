@@ -0,0 +1,62 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package internal // import "github.com/foyer-work/otel-distribution/exporter/clickhouse/internal"
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+)
+
+var jsonPathRegexp = regexp.MustCompile(`^\$(\.[A-Za-z0-9_@-]+)+$`)
+
+// JSONPath is the path of a value in nested JSON objects, its members from the outermost object.
+type JSONPath []string
+
+// ParseJSONPath parses a path of object members like `$.request.id`, returning false if path is not one.
+func ParseJSONPath(path string) (JSONPath, bool) {
+	if !jsonPathRegexp.MatchString(path) {
+		return nil, false
+	}
+	return strings.Split(path, ".")[1:], true
+}
+
+// ExtractJSONPaths returns the value at each of paths of body, a JSON object, strings as is and other values
+// JSON encoded. The value of a path missing from body, or of every path if body isn't a JSON object, is empty.
+func ExtractJSONPaths(body string, paths []JSONPath) []any {
+	values := make([]any, len(paths))
+	var object map[string]any
+	if strings.HasPrefix(strings.TrimSpace(body), "{") {
+		// Numbers are kept as written rather than converted to float64.
+		decoder := json.NewDecoder(strings.NewReader(body))
+		decoder.UseNumber()
+		_ = decoder.Decode(&object)
+	}
+	for i, path := range paths {
+		values[i] = jsonPathValue(object, path)
+	}
+	return values
+}
+
+func jsonPathValue(object map[string]any, path JSONPath) string {
+	var value any = object
+	for _, member := range path {
+		members, ok := value.(map[string]any)
+		if !ok {
+			return ""
+		}
+		if value, ok = members[member]; !ok {
+			return ""
+		}
+	}
+	switch value := value.(type) {
+	case string:
+		return value
+	case nil:
+		return ""
+	default:
+		encoded, _ := json.Marshal(value)
+		return string(encoded)
+	}
+}
@@ -0,0 +1,33 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseJSONPath(t *testing.T) {
+	path, ok := ParseJSONPath("$.request.id")
+	require.True(t, ok)
+	require.Equal(t, JSONPath{"request", "id"}, path)
+	for _, invalid := range []string{"", "$", "level", "$.", "$.a..b", "$.a[0]", "$.a'b"} {
+		_, ok := ParseJSONPath(invalid)
+		require.False(t, ok, invalid)
+	}
+}
+
+func TestExtractJSONPaths(t *testing.T) {
+	var paths []JSONPath
+	for _, path := range []string{"$.level", "$.request.id", "$.request", "$.count", "$.missing", "$.level.nested", "$.empty"} {
+		parsed, _ := ParseJSONPath(path)
+		paths = append(paths, parsed)
+	}
+	require.Equal(t,
+		[]any{"warn", "7", `{"id":"7","size":12345678901234567}`, "3", "", "", ""},
+		ExtractJSONPaths(` {"level":"warn","request":{"id":"7","size":12345678901234567},"count":3,"empty":null}`, paths))
+	require.Equal(t, []any{"", "", "", "", "", "", ""}, ExtractJSONPaths("plain text", paths))
+	require.Equal(t, []any{"", "", "", "", "", "", ""}, ExtractJSONPaths(`{"level":`, paths))
+}
@@ -0,0 +1,204 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package internal // import "github.com/foyer-work/otel-distribution/exporter/clickhouse/internal"
+
+import (
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+// CardinalityAction is what is done with the datapoints of the series above the limit of their metric.
+type CardinalityAction string
+
+const (
+	// CardinalityDrop drops the datapoints.
+	CardinalityDrop CardinalityAction = "drop"
+	// CardinalityAggregate writes the datapoints into a single overflow series of their metric,
+	// their attributes being replaced by OverflowAttribute, as the OpenTelemetry SDKs do.
+	CardinalityAggregate CardinalityAction = "aggregate"
+)
+
+// OverflowAttribute is the only attribute of the datapoints aggregated by CardinalityAggregate.
+const OverflowAttribute = "otel.metric.overflow"
+
+// CardinalityLimiter limits the number of series of each metric name, a series being identified by the hash of its
+// resource and datapoint attributes. The series of a metric are admitted until maxSeries of them were written to
+// within maxStale, the datapoints of the other series being limited by action.
+type CardinalityLimiter struct {
+	maxSeries int
+	maxStale  time.Duration
+	action    CardinalityAction
+
+	mu      sync.Mutex
+	metrics map[string]*metricSeries
+	evicted time.Time
+}
+
+// metricSeries are the admitted series of a metric.
+type metricSeries struct {
+	seen map[seriesKey]time.Time
+	// exceeded is set once a series of the metric was limited, until series are forgotten.
+	exceeded bool
+}
+
+// CardinalityLimits describes the datapoints limited in a batch.
+type CardinalityLimits struct {
+	// DataPoints is the number of datapoints limited per metric name.
+	DataPoints map[string]int
+	// Exceeded are the metrics whose series were limited for the first time since their series were last forgotten.
+	Exceeded []string
+}
+
+// NewCardinalityLimiter returns a limiter admitting maxSeries series per metric, forgetting the series
+// not written to for maxStale.
+func NewCardinalityLimiter(maxSeries int, maxStale time.Duration, action CardinalityAction) *CardinalityLimiter {
+	return &CardinalityLimiter{
+		maxSeries: maxSeries,
+		maxStale:  maxStale,
+		action:    action,
+		metrics:   map[string]*metricSeries{},
+		evicted:   time.Now(),
+	}
+}
+
+// Limit returns md without the datapoints of the series above the limit, or with them moved to the overflow
+// series of their metric, and the datapoints limited. md is returned as is if none is, and copied otherwise.
+func (l *CardinalityLimiter) Limit(md pmetric.Metrics) (pmetric.Metrics, CardinalityLimits) {
+	var limits CardinalityLimits
+	if l == nil {
+		return md, limits
+	}
+	l.mu.Lock()
+	now := time.Now()
+	l.evict(now)
+	var limited []bool
+	found := false
+	forEachDataPoint(md, func(resAttr, metric string, attrs pcommon.Map) {
+		ok := l.admit(metric, newSeriesKey(resAttr, AttributesToJSON(attrs)), now, &limits)
+		limited = append(limited, !ok)
+		found = found || !ok
+	})
+	l.mu.Unlock()
+	if !found {
+		return md, limits
+	}
+
+	out := pmetric.NewMetrics()
+	md.CopyTo(out)
+	i := 0
+	removeDataPoints(out, func(attrs pcommon.Map) bool {
+		drop := limited[i]
+		i++
+		if drop && l.action == CardinalityAggregate {
+			attrs.Clear()
+			attrs.PutBool(OverflowAttribute, true)
+			return false
+		}
+		return drop
+	})
+	return out, limits
+}
+
+// admit returns whether the series key of metric is admitted, recording it in limits otherwise.
+func (l *CardinalityLimiter) admit(metric string, key seriesKey, now time.Time, limits *CardinalityLimits) bool {
+	series, ok := l.metrics[metric]
+	if !ok {
+		series = &metricSeries{seen: map[seriesKey]time.Time{}}
+		l.metrics[metric] = series
+	}
+	if _, ok := series.seen[key]; ok || len(series.seen) < l.maxSeries {
+		series.seen[key] = now
+		return true
+	}
+	if limits.DataPoints == nil {
+		limits.DataPoints = map[string]int{}
+	}
+	limits.DataPoints[metric]++
+	if !series.exceeded {
+		series.exceeded = true
+		limits.Exceeded = append(limits.Exceeded, metric)
+	}
+	return false
+}
+
+// evict forgets the stale series, at most once per maxStale.
+func (l *CardinalityLimiter) evict(now time.Time) {
+	if now.Sub(l.evicted) < l.maxStale {
+		return
+	}
+	l.evicted = now
+	for metric, series := range l.metrics {
+		for key, seen := range series.seen {
+			if now.Sub(seen) >= l.maxStale {
+				delete(series.seen, key)
+				series.exceeded = false
+			}
+		}
+		if len(series.seen) == 0 {
+			delete(l.metrics, metric)
+		}
+	}
+}
+
+// forEachDataPoint calls fn with the resource attributes, metric name and attributes of each datapoint of md, in order.
+func forEachDataPoint(md pmetric.Metrics, fn func(resAttr, metric string, attrs pcommon.Map)) {
+	for _, rm := range md.ResourceMetrics().All() {
+		resAttr := AttributesToJSON(rm.Resource().Attributes())
+		for _, sm := range rm.ScopeMetrics().All() {
+			for _, metric := range sm.Metrics().All() {
+				//exhaustive:enforce
+				switch metric.Type() {
+				case pmetric.MetricTypeGauge:
+					for _, dp := range metric.Gauge().DataPoints().All() {
+						fn(resAttr, metric.Name(), dp.Attributes())
+					}
+				case pmetric.MetricTypeSum:
+					for _, dp := range metric.Sum().DataPoints().All() {
+						fn(resAttr, metric.Name(), dp.Attributes())
+					}
+				case pmetric.MetricTypeHistogram:
+					for _, dp := range metric.Histogram().DataPoints().All() {
+						fn(resAttr, metric.Name(), dp.Attributes())
+					}
+				case pmetric.MetricTypeExponentialHistogram:
+					for _, dp := range metric.ExponentialHistogram().DataPoints().All() {
+						fn(resAttr, metric.Name(), dp.Attributes())
+					}
+				case pmetric.MetricTypeSummary:
+					for _, dp := range metric.Summary().DataPoints().All() {
+						fn(resAttr, metric.Name(), dp.Attributes())
+					}
+				case pmetric.MetricTypeEmpty:
+				}
+			}
+		}
+	}
+}
+
+// removeDataPoints removes the datapoints of md for which remove returns true, called in the order of forEachDataPoint.
+func removeDataPoints(md pmetric.Metrics, remove func(attrs pcommon.Map) bool) {
+	for _, rm := range md.ResourceMetrics().All() {
+		for _, sm := range rm.ScopeMetrics().All() {
+			for _, metric := range sm.Metrics().All() {
+				//exhaustive:enforce
+				switch metric.Type() {
+				case pmetric.MetricTypeGauge:
+					metric.Gauge().DataPoints().RemoveIf(func(dp pmetric.NumberDataPoint) bool { return remove(dp.Attributes()) })
+				case pmetric.MetricTypeSum:
+					metric.Sum().DataPoints().RemoveIf(func(dp pmetric.NumberDataPoint) bool { return remove(dp.Attributes()) })
+				case pmetric.MetricTypeHistogram:
+					metric.Histogram().DataPoints().RemoveIf(func(dp pmetric.HistogramDataPoint) bool { return remove(dp.Attributes()) })
+				case pmetric.MetricTypeExponentialHistogram:
+					metric.ExponentialHistogram().DataPoints().RemoveIf(func(dp pmetric.ExponentialHistogramDataPoint) bool { return remove(dp.Attributes()) })
+				case pmetric.MetricTypeSummary:
+					metric.Summary().DataPoints().RemoveIf(func(dp pmetric.SummaryDataPoint) bool { return remove(dp.Attributes()) })
+				case pmetric.MetricTypeEmpty:
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package internal
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+func cardinalityMetrics(name string, series int) pmetric.Metrics {
+	md := pmetric.NewMetrics()
+	metric := md.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics().AppendEmpty()
+	metric.SetName(name)
+	dps := metric.SetEmptyGauge().DataPoints()
+	for i := range series {
+		dps.AppendEmpty().Attributes().PutStr("id", strconv.Itoa(i))
+	}
+	return md
+}
+
+func gaugeAttributes(md pmetric.Metrics) []map[string]any {
+	var attrs []map[string]any
+	for _, dp := range md.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().At(0).Gauge().DataPoints().All() {
+		attrs = append(attrs, dp.Attributes().AsRaw())
+	}
+	return attrs
+}
+
+func TestCardinalityLimiter(t *testing.T) {
+	var limiter *CardinalityLimiter
+	md := cardinalityMetrics("requests", 3)
+	out, limits := limiter.Limit(md)
+	require.Equal(t, md, out, "a nil limiter limits nothing")
+	require.Empty(t, limits.DataPoints)
+
+	limiter = NewCardinalityLimiter(2, time.Hour, CardinalityDrop)
+	out, limits = limiter.Limit(md)
+	require.Equal(t, []map[string]any{{"id": "0"}, {"id": "1"}}, gaugeAttributes(out))
+	require.Equal(t, map[string]int{"requests": 1}, limits.DataPoints)
+	require.Equal(t, []string{"requests"}, limits.Exceeded)
+	require.Equal(t, 3, md.DataPointCount(), "the batch is not modified")
+
+	out, limits = limiter.Limit(md)
+	require.Equal(t, 2, out.DataPointCount())
+	require.Empty(t, limits.Exceeded, "the metric is reported once")
+
+	out, limits = limiter.Limit(cardinalityMetrics("latency", 2))
+	require.Equal(t, 2, out.DataPointCount(), "each metric has its own limit")
+	require.Empty(t, limits.DataPoints)
+
+	limiter = NewCardinalityLimiter(1, time.Hour, CardinalityAggregate)
+	out, limits = limiter.Limit(md)
+	require.Equal(t, []map[string]any{{"id": "0"}, {OverflowAttribute: true}, {OverflowAttribute: true}}, gaugeAttributes(out))
+	require.Equal(t, map[string]int{"requests": 2}, limits.DataPoints)
+}
+
+func TestCardinalityLimiter_evict(t *testing.T) {
+	limiter := NewCardinalityLimiter(1, time.Minute, CardinalityDrop)
+	_, limits := limiter.Limit(cardinalityMetrics("requests", 2))
+	require.Equal(t, map[string]int{"requests": 1}, limits.DataPoints)
+
+	limiter.evict(time.Now().Add(time.Hour))
+	require.Empty(t, limiter.metrics)
+	_, limits = limiter.Limit(cardinalityMetrics("requests", 2))
+	require.Equal(t, []string{"requests"}, limits.Exceeded)
+}
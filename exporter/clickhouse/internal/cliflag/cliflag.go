@@ -0,0 +1,30 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package cliflag holds the command line flag types shared by the commands of the exporter.
+package cliflag // import "github.com/foyer-work/otel-distribution/exporter/clickhouse/internal/cliflag"
+
+import (
+	"fmt"
+	"strings"
+)
+
+// KeyValue collects repeated key=value flags.
+type KeyValue map[string]string
+
+func (f KeyValue) String() string {
+	pairs := make([]string, 0, len(f))
+	for k, v := range f {
+		pairs = append(pairs, k+"="+v)
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (f KeyValue) Set(s string) error {
+	k, v, ok := strings.Cut(s, "=")
+	if !ok || k == "" {
+		return fmt.Errorf("expected key=value, got %q", s)
+	}
+	f[k] = v
+	return nil
+}
@@ -0,0 +1,24 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package cliflag
+
+import (
+	"flag"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyValue(t *testing.T) {
+	settings := KeyValue{}
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	flags.Var(settings, "setting", "")
+
+	require.NoError(t, flags.Parse([]string{"-setting", "max_threads=4", "-setting", "log_comment=a=b"}))
+	require.Equal(t, KeyValue{"max_threads": "4", "log_comment": "a=b"}, settings)
+	require.Error(t, flags.Parse([]string{"-setting", "max_threads"}))
+	require.Error(t, flags.Parse([]string{"-setting", "=4"}))
+}
@@ -0,0 +1,197 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package internal // import "github.com/foyer-work/otel-distribution/exporter/clickhouse/internal"
+
+import (
+	"regexp"
+	"strings"
+)
+
+// ColumnDef is a single column definition of a CREATE TABLE statement.
+type ColumnDef struct {
+	Name string
+	// Type is the column type including any DEFAULT, MATERIALIZED or ALIAS expression.
+	Type string
+	// Comment is the text of the COMMENT clause, empty if the column has none.
+	Comment string
+	// Codec is the argument list of the CODEC clause, empty if the column has none.
+	Codec string
+}
+
+// ColumnOptions holds user overrides applied to the column definitions and settings of generated DDL.
+// Keys of the column overrides are either a column name, applied to every table, or `<table>.<column>`.
+type ColumnOptions struct {
+	// Codecs overrides the compression codecs of a column, e.g. `DoubleDelta, ZSTD(3)`.
+	Codecs map[string]string
+	// LowCardinality wraps (true) or unwraps (false) the String type of a column in LowCardinality.
+	// Columns of other types are left unchanged.
+	LowCardinality map[string]bool
+	// StringJSON creates the JSON columns as String holding the same serialized JSON,
+	// for servers without the JSON type.
+	StringJSON bool
+	// BinaryIDs creates the trace and span id columns as FixedString for IDEncodingBinary.
+	BinaryIDs bool
+	// TableSettings are merged into the SETTINGS clause of the table they are keyed by, e.g.
+	// `parts_to_throw_insert: 600`.
+	TableSettings map[string]map[string]string
+}
+
+// Apply rewrites the column definitions and settings of the CREATE TABLE statement ddl for table.
+func (o ColumnOptions) Apply(table, ddl string) string {
+	ddl = SetTableSettings(ddl, o.TableSettings[table])
+	if len(o.Codecs) == 0 && len(o.LowCardinality) == 0 && !o.StringJSON && !o.BinaryIDs {
+		return ddl
+	}
+	return RewriteColumns(ddl, func(col *ColumnDef) {
+		if o.StringJSON {
+			setStringJSON(col)
+		}
+		if o.BinaryIDs {
+			setBinaryIDs(col)
+		}
+		if codec, ok := lookupColumn(o.Codecs, table, col.Name); ok {
+			col.Codec = codec
+		}
+		if lowCardinality, ok := lookupColumn(o.LowCardinality, table, col.Name); ok {
+			col.Type = setLowCardinality(col.Type, lowCardinality)
+		}
+	})
+}
+
+var jsonTypeRegexp = regexp.MustCompile(`\bJSON\b`)
+
+// setStringJSON replaces the JSON type of a column definition, or of the fields of a Nested column, with String.
+func setStringJSON(col *ColumnDef) {
+	if col.Type == "JSON" && col.Codec == "" {
+		col.Codec = "ZSTD(1)"
+	}
+	col.Type = jsonTypeRegexp.ReplaceAllString(col.Type, "String")
+}
+
+var lowCardinalityTypeRegexp = regexp.MustCompile(`^(LowCardinality\()?(Nullable\((?:String|FixedString\(\d+\))\)|String|FixedString\(\d+\))(\))?$`)
+
+// setLowCardinality wraps or unwraps the String type of a column definition in LowCardinality,
+// keeping any DEFAULT, MATERIALIZED or ALIAS expression.
+func setLowCardinality(colType string, lowCardinality bool) string {
+	dataType, expr := colType, ""
+	for _, keyword := range []string{" DEFAULT ", " MATERIALIZED ", " ALIAS ", " EPHEMERAL "} {
+		if i := strings.Index(colType, keyword); i >= 0 {
+			dataType, expr = colType[:i], colType[i:]
+			break
+		}
+	}
+	m := lowCardinalityTypeRegexp.FindStringSubmatch(dataType)
+	if m == nil || (m[1] == "") != (m[3] == "") {
+		return colType
+	}
+	if lowCardinality {
+		return "LowCardinality(" + m[2] + ")" + expr
+	}
+	return m[2] + expr
+}
+
+func lookupColumn[T any](m map[string]T, table, column string) (T, bool) {
+	if v, ok := m[table+"."+column]; ok {
+		return v, true
+	}
+	v, ok := m[column]
+	return v, ok
+}
+
+var columnDefRegexp = regexp.MustCompile(`(?s)^(\s*)(\w+) (.*?)(?: COMMENT '((?:[^'\\]|\\.)*)')?(?: CODEC\((.*)\))?(,?)\s*$`)
+
+// RewriteColumns calls fn for each column definition of the CREATE TABLE statement ddl
+// and renders the possibly modified definitions back in place.
+// Indexes, projections and constraints are left untouched.
+func RewriteColumns(ddl string, fn func(col *ColumnDef)) string {
+	lines := strings.Split(ddl, "\n")
+	out := make([]string, 0, len(lines))
+	inColumns := false
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case !inColumns:
+			inColumns = strings.HasPrefix(trimmed, "CREATE TABLE") && strings.HasSuffix(trimmed, "(")
+			out = append(out, line)
+			continue
+		case strings.HasPrefix(trimmed, ")"):
+			inColumns = false
+			out = append(out, line)
+			continue
+		case trimmed == "" || isColumnListKeyword(trimmed):
+			out = append(out, line)
+			continue
+		}
+
+		// A definition spans several lines until its parentheses are balanced, e.g. Nested columns.
+		def := line
+		for depth := parenDepth(line); depth > 0 && i+1 < len(lines); depth += parenDepth(lines[i]) {
+			i++
+			def += "\n" + lines[i]
+		}
+		out = append(out, rewriteColumnDef(def, fn))
+	}
+	return strings.Join(out, "\n")
+}
+
+func rewriteColumnDef(def string, fn func(col *ColumnDef)) string {
+	m := columnDefRegexp.FindStringSubmatch(def)
+	if m == nil {
+		return def
+	}
+	col := &ColumnDef{Name: m[2], Type: m[3], Comment: unescapeString(m[4]), Codec: m[5]}
+	fn(col)
+
+	var b strings.Builder
+	b.WriteString(m[1])
+	b.WriteString(col.Name)
+	b.WriteString(" ")
+	b.WriteString(col.Type)
+	if col.Comment != "" {
+		b.WriteString(" COMMENT ")
+		b.WriteString(QuoteString(col.Comment))
+	}
+	if col.Codec != "" {
+		b.WriteString(" CODEC(")
+		b.WriteString(col.Codec)
+		b.WriteString(")")
+	}
+	b.WriteString(m[6])
+	return b.String()
+}
+
+// RemoveIndexes removes the data skipping indexes from the CREATE TABLE statement ddl.
+func RemoveIndexes(ddl string) string {
+	lines := strings.Split(ddl, "\n")
+	out := make([]string, 0, len(lines))
+	removed := false
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "INDEX ") {
+			removed = true
+			continue
+		}
+		// The definition preceding the removed trailing indexes now ends the list.
+		if removed && strings.HasPrefix(trimmed, ")") && len(out) > 0 {
+			out[len(out)-1] = strings.TrimSuffix(out[len(out)-1], ",")
+		}
+		removed = false
+		out = append(out, line)
+	}
+	return strings.Join(out, "\n")
+}
+
+func isColumnListKeyword(line string) bool {
+	for _, keyword := range []string{"INDEX ", "PROJECTION ", "CONSTRAINT "} {
+		if strings.HasPrefix(line, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+func parenDepth(line string) int {
+	return strings.Count(line, "(") - strings.Count(line, ")")
+}
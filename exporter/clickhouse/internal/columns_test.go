@@ -0,0 +1,147 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package internal
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+)
+
+func TestRewriteColumns_lossless(t *testing.T) {
+	for _, ddl := range []string{createGaugeTableSQL, createSumTableSQL, createHistogramTableSQL, createExpHistogramTableSQL, createSummaryTableSQL, createExemplarsTableSQL} {
+		require.Equal(t, ddl, RewriteColumns(ddl, func(*ColumnDef) {}))
+	}
+}
+
+func TestRewriteColumns(t *testing.T) {
+	ddl := fmt.Sprintf(createGaugeTableSQL, "otel_metrics_gauge", "", exemplarsColumnSQL, "MergeTree()", "", "toDate(TimeUnix)")
+
+	var names []string
+	RewriteColumns(ddl, func(col *ColumnDef) {
+		names = append(names, col.Name)
+		if col.Name == "Exemplars" {
+			require.Equal(t, "ZSTD(1)", col.Codec)
+			require.Contains(t, col.Type, "Nested (")
+		}
+		if col.Name == "TimeUnix" {
+			require.Equal(t, "DateTime64(9)", col.Type)
+			require.Equal(t, "Delta, ZSTD(1)", col.Codec)
+		}
+	})
+	require.Len(t, names, 17)
+	require.Equal(t, "ResourceAttributes", names[0])
+	require.Equal(t, "Exemplars", names[16])
+}
+
+func TestColumnOptions_Apply(t *testing.T) {
+	ddl := fmt.Sprintf(createExemplarsTableSQL, "otel_metrics_exemplars", "", "MergeTree()", "toDate(TimeUnix)", "")
+	opts := ColumnOptions{Codecs: map[string]string{
+		"TimeUnix":                     "DoubleDelta, ZSTD(3)",
+		"Value":                        "Gorilla, ZSTD(1)",
+		"otel_metrics_exemplars.Value": "Gorilla, LZ4",
+		"otel_metrics_gauge.SpanId":    "LZ4",
+	}}
+
+	got := opts.Apply("otel_metrics_exemplars", ddl)
+	require.Contains(t, got, "\tTimeUnix DateTime64(9) CODEC(DoubleDelta, ZSTD(3)),\n")
+	require.Contains(t, got, "\tValue Float64 CODEC(Gorilla, LZ4),\n")
+	require.Contains(t, got, "\tSpanId String CODEC(ZSTD(1)),\n")
+	require.Contains(t, got, "\tDataPointTimeUnix DateTime64(9) CODEC(Delta, ZSTD(1)),\n")
+	require.Contains(t, got, "INDEX idx_trace_id TraceId TYPE bloom_filter(0.001) GRANULARITY 1\n")
+}
+
+func TestColumnOptions_stringJSON(t *testing.T) {
+	ddl := fmt.Sprintf(createGaugeTableSQL, "otel_metrics_gauge", "", exemplarsColumnSQL, "MergeTree()", "", "toDate(TimeUnix)")
+	got := ColumnOptions{StringJSON: true}.Apply("otel_metrics_gauge", ddl)
+	require.NotContains(t, got, "JSON")
+	require.Contains(t, got, "\tResourceAttributes String CODEC(ZSTD(1)),\n")
+	require.Contains(t, got, "FilteredAttributes String")
+}
+
+func TestColumnOptions_binaryIDs(t *testing.T) {
+	ddl := fmt.Sprintf(createGaugeTableSQL, "otel_metrics_gauge", "", exemplarsColumnSQL, "MergeTree()", "", "toDate(TimeUnix)")
+	got := ColumnOptions{BinaryIDs: true}.Apply("otel_metrics_gauge", ddl)
+	require.Contains(t, got, "SpanId FixedString(8),")
+	require.Contains(t, got, "TraceId FixedString(16)")
+	require.NotContains(t, got, "Id String")
+
+	ddl = fmt.Sprintf(createExemplarsTableSQL, "otel_metrics_exemplars", "", "MergeTree()", "toDate(TimeUnix)", "")
+	got = ColumnOptions{BinaryIDs: true}.Apply("otel_metrics_exemplars", CommentColumns(ddl, exemplarsColumnComments))
+	require.Contains(t, got, "\tSpanId FixedString(8) COMMENT 'Exemplar.span_id as raw bytes' CODEC(ZSTD(1)),\n")
+	require.Contains(t, got, "\tTraceId FixedString(16) COMMENT 'Exemplar.trace_id as raw bytes' CODEC(ZSTD(1)),\n")
+}
+
+func TestIDEncoding(t *testing.T) {
+	traceID := pcommon.TraceID([16]byte{0xab, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15})
+	spanID := pcommon.SpanID([8]byte{0xcd, 1, 2, 3, 4, 5, 6, 7})
+	require.Equal(t, "ab0102030405060708090a0b0c0d0e0f", IDEncodingHex.TraceID(traceID))
+	require.Equal(t, "cd01020304050607", IDEncodingHex.SpanID(spanID))
+	require.Equal(t, string(traceID[:]), IDEncodingBinary.TraceID(traceID))
+	require.Equal(t, string(spanID[:]), IDEncodingBinary.SpanID(spanID))
+
+	require.Empty(t, IDEncodingHex.SpanID(pcommon.NewSpanIDEmpty()))
+	require.Equal(t, string(make([]byte, 8)), IDEncodingBinary.SpanID(pcommon.NewSpanIDEmpty()))
+}
+
+func TestSetLowCardinality(t *testing.T) {
+	tests := []struct {
+		colType        string
+		lowCardinality bool
+		want           string
+	}{
+		{"LowCardinality(String)", false, "String"},
+		{"LowCardinality(String)", true, "LowCardinality(String)"},
+		{"String", true, "LowCardinality(String)"},
+		{"Nullable(String)", true, "LowCardinality(Nullable(String))"},
+		{"FixedString(16)", true, "LowCardinality(FixedString(16))"},
+		{"String DEFAULT ''", true, "LowCardinality(String) DEFAULT ''"},
+		{"UInt8", true, "UInt8"},
+		{"Map(LowCardinality(String), String)", false, "Map(LowCardinality(String), String)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.colType, func(t *testing.T) {
+			require.Equal(t, tt.want, setLowCardinality(tt.colType, tt.lowCardinality))
+		})
+	}
+}
+
+func TestCommentColumns(t *testing.T) {
+	ddl := fmt.Sprintf(createSummaryTableSQL, "otel_metrics_summary", "", "MergeTree()", "", "toDate(TimeUnix)")
+	got := CommentColumns(ddl, map[string]string{
+		"MetricName":       "Metric's name",
+		"ValueAtQuantiles": "SummaryDataPoint.quantile_values",
+	})
+	require.Contains(t, got, "\tMetricName String COMMENT 'Metric\\'s name' CODEC(ZSTD(1)),\n")
+	require.Contains(t, got, "\t) COMMENT 'SummaryDataPoint.quantile_values' CODEC(ZSTD(1)),\n")
+	require.Contains(t, got, "\tMetricUnit String CODEC(ZSTD(1)),\n")
+
+	// Existing comments are parsed and kept.
+	RewriteColumns(got, func(col *ColumnDef) {
+		if col.Name == "MetricName" {
+			require.Equal(t, "Metric's name", col.Comment)
+			require.Equal(t, "ZSTD(1)", col.Codec)
+		}
+	})
+	require.Equal(t, got, CommentColumns(strings.TrimSuffix(got, "\nCOMMENT 'Created by the OpenTelemetry ClickHouse exporter, schema version 1';\n")+";\n", nil))
+
+	version, ok := ParseSchemaVersion("Created by the OpenTelemetry ClickHouse exporter, schema version 1")
+	require.True(t, ok)
+	require.Equal(t, SchemaVersion, version)
+	_, ok = ParseSchemaVersion("created by hand")
+	require.False(t, ok)
+}
+
+func TestRemoveIndexes(t *testing.T) {
+	ddl := fmt.Sprintf(createExemplarsTableSQL, "otel_metrics_exemplars", "", "MergeTree()", "toDate(TimeUnix)", "")
+	got := RemoveIndexes(ddl)
+	require.NotContains(t, got, "INDEX")
+	require.Contains(t, got, "\tTraceId String CODEC(ZSTD(1))\n) ENGINE = MergeTree()\n")
+
+	gauge := fmt.Sprintf(createGaugeTableSQL, "otel_metrics_gauge", "", exemplarsColumnSQL, "MergeTree()", "", "toDate(TimeUnix)")
+	require.Equal(t, gauge, RemoveIndexes(gauge))
+}
@@ -0,0 +1,96 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package internal // import "github.com/foyer-work/otel-distribution/exporter/clickhouse/internal"
+
+import (
+	"strconv"
+	"strings"
+)
+
+// SchemaVersion is the generation of the table schemas created by the exporter.
+// It is recorded in the table comment so the schema of an existing table can be detected.
+const SchemaVersion = 1
+
+const tableCommentPrefix = "Created by the OpenTelemetry ClickHouse exporter, schema version "
+
+// CommentColumns adds a COMMENT clause describing the OTLP field of each column of the
+// CREATE TABLE statement ddl found in comments, and a table comment recording SchemaVersion.
+func CommentColumns(ddl string, comments map[string]string) string {
+	ddl = RewriteColumns(ddl, func(col *ColumnDef) {
+		if comment, ok := comments[col.Name]; ok && col.Comment == "" {
+			col.Comment = comment
+		}
+	})
+	i := strings.LastIndex(ddl, ";")
+	if i < 0 {
+		return ddl
+	}
+	return ddl[:i] + "\nCOMMENT " + QuoteString(tableCommentPrefix+strconv.Itoa(SchemaVersion)) + ddl[i:]
+}
+
+// ParseSchemaVersion returns the schema version recorded in a table comment written by CommentColumns.
+func ParseSchemaVersion(tableComment string) (int, bool) {
+	version, ok := strings.CutPrefix(tableComment, tableCommentPrefix)
+	if !ok {
+		return 0, false
+	}
+	v, err := strconv.Atoi(version)
+	if err != nil {
+		return 0, false
+	}
+	return v, true
+}
+
+// metricsColumnComments describes the columns of the metric tables.
+var metricsColumnComments = map[string]string{
+	"ResourceAttributes":     "Resource.attributes",
+	"ResourceSchemaUrl":      "ResourceMetrics.schema_url",
+	"ScopeName":              "InstrumentationScope.name",
+	"ScopeVersion":           "InstrumentationScope.version",
+	"ScopeAttributes":        "InstrumentationScope.attributes",
+	"ScopeDroppedAttrCount":  "InstrumentationScope.dropped_attributes_count",
+	"ScopeSchemaUrl":         "ScopeMetrics.schema_url",
+	"ServiceName":            "Resource attribute service.name",
+	"MetricName":             "Metric.name",
+	"MetricType":             "Type of the metric, the columns of the other types holding their default values",
+	"MetricDescription":      "Metric.description",
+	"MetricUnit":             "Metric.unit",
+	"Attributes":             "DataPoint.attributes",
+	"AttrHash":               "64-bit FNV-1a hash of DataPoint.attributes",
+	"StartTimeUnix":          "DataPoint.start_time_unix_nano",
+	"TimeUnix":               "DataPoint.time_unix_nano",
+	"Value":                  "NumberDataPoint.as_double or as_int",
+	"Flags":                  "DataPoint.flags",
+	"IsStale":                "DataPoint.flags has FLAG_NO_RECORDED_VALUE, the datapoint being a staleness marker",
+	"Exemplars":              "DataPoint.exemplars",
+	"AggregationTemporality": "Sum, Histogram or ExponentialHistogram aggregation_temporality",
+	"IsMonotonic":            "Sum.is_monotonic",
+	"Count":                  "DataPoint.count",
+	"Sum":                    "DataPoint.sum",
+	"Min":                    "DataPoint.min",
+	"Max":                    "DataPoint.max",
+	"BucketCounts":           "HistogramDataPoint.bucket_counts",
+	"ExplicitBounds":         "HistogramDataPoint.explicit_bounds",
+	"Scale":                  "ExponentialHistogramDataPoint.scale",
+	"ZeroCount":              "ExponentialHistogramDataPoint.zero_count",
+	"PositiveOffset":         "ExponentialHistogramDataPoint.positive.offset",
+	"PositiveBucketCounts":   "ExponentialHistogramDataPoint.positive.bucket_counts",
+	"NegativeOffset":         "ExponentialHistogramDataPoint.negative.offset",
+	"NegativeBucketCounts":   "ExponentialHistogramDataPoint.negative.bucket_counts",
+	"ValueAtQuantiles":       "SummaryDataPoint.quantile_values",
+}
+
+// exemplarsColumnComments describes the columns of the exemplars table.
+var exemplarsColumnComments = map[string]string{
+	"ServiceName":        "Resource attribute service.name",
+	"MetricName":         "Metric.name",
+	"MetricType":         "Type of the metric the exemplar belongs to",
+	"Attributes":         "DataPoint.attributes",
+	"DataPointTimeUnix":  "DataPoint.time_unix_nano",
+	"TimeUnix":           "Exemplar.time_unix_nano",
+	"Value":              "Exemplar.as_double or as_int",
+	"FilteredAttributes": "Exemplar.filtered_attributes",
+	"SpanId":             "Exemplar.span_id as hex",
+	"TraceId":            "Exemplar.trace_id as hex",
+}
@@ -0,0 +1,105 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package internal // import "github.com/foyer-work/otel-distribution/exporter/clickhouse/internal"
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+const (
+	// language=ClickHouse SQL
+	createCounterRatesTableSQL = `
+CREATE TABLE IF NOT EXISTS %s %s (
+	ServiceName LowCardinality(String) CODEC(ZSTD(1)),
+	MetricName String CODEC(ZSTD(1)),
+	MetricUnit String CODEC(ZSTD(1)),
+	Attributes JSON,
+	StartTimeUnix DateTime64(9) CODEC(Delta, ZSTD(1)),
+	TimeUnix DateTime CODEC(Delta, ZSTD(1)),
+	Increase SimpleAggregateFunction(sum, Float64) CODEC(ZSTD(1)),
+	Last SimpleAggregateFunction(max, Float64) CODEC(ZSTD(1))
+) ENGINE = %s
+%s
+PARTITION BY %s
+ORDER BY (ServiceName, MetricName, MetricUnit, Attributes, StartTimeUnix, TimeUnix)
+SETTINGS index_granularity=8192, ttl_only_drop_parts = 1;
+`
+	// Delta datapoints are the increase of their series, added up per minute. The value of cumulative
+	// datapoints only grows until the series restarts with a new StartTimeUnix, so the largest one of a
+	// minute is the last one, the increase within the minute being completed at query time by subtracting
+	// the Last of the previous minute of the same StartTimeUnix.
+	// language=ClickHouse SQL
+	createCounterRatesViewSQL = `
+CREATE MATERIALIZED VIEW IF NOT EXISTS %s %s
+TO %s.%s
+AS SELECT
+	ServiceName,
+	MetricName,
+	MetricUnit,
+	Attributes,
+	if(Temporality = 1, toDateTime64(0, 9), Start) AS StartTimeUnix,
+	toStartOfMinute(Time) AS TimeUnix,
+	sumIf(Val, Temporality = 1) AS Increase,
+	maxIf(Val, Temporality = 2) AS Last
+FROM (
+	SELECT ServiceName, MetricName, MetricUnit, Attributes, StartTimeUnix AS Start, TimeUnix AS Time,
+		AggregationTemporality AS Temporality, assumeNotNull(Value) AS Val
+	FROM %s.%s
+	WHERE IsMonotonic AND isNotNull(Value)%s
+)
+GROUP BY ServiceName, MetricName, MetricUnit, Attributes, StartTimeUnix, TimeUnix;
+`
+)
+
+// counterRatesColumnComments describes the columns of the counter rates table.
+var counterRatesColumnComments = map[string]string{
+	"ServiceName":   "Resource attribute service.name",
+	"MetricName":    "Metric.name",
+	"MetricUnit":    "Metric.unit",
+	"Attributes":    "DataPoint.attributes",
+	"StartTimeUnix": "DataPoint.start_time_unix_nano of cumulative sums, a new one marking a reset, 0 for delta sums",
+	"TimeUnix":      "Start of the minute of the datapoints",
+	"Increase":      "Sum of the delta datapoints in the minute, 0 for cumulative sums",
+	"Last":          "Value of the last cumulative datapoint in the minute, whose increase is Last minus the Last of the previous minute with the same StartTimeUnix, or Last for the first minute after StartTimeUnix, 0 for delta sums",
+}
+
+// CounterRates is a table holding the per minute increase of the monotonic sums of a sum table,
+// filled by a materialized view on the sum table.
+type CounterRates struct {
+	// Source is the sum table.
+	Source string
+	// Table is the counter rates table.
+	Table string
+	// View is the materialized view inserting into Table.
+	View string
+	// Filter is an optional condition on the rows of Source to aggregate.
+	Filter string
+}
+
+// NewCounterRates returns the counter rates table of source, named table, with its view named after it.
+func NewCounterRates(source, table string) CounterRates {
+	return CounterRates{Source: source, Table: table, View: table + "_mv"}
+}
+
+// Create creates the counter rates table with engine, an AggregatingMergeTree, and its materialized view.
+func (r CounterRates) Create(ctx context.Context, settings MetricsSettings, database, cluster, engine, ttlExpr string, db *sql.DB) error {
+	query := fmt.Sprintf(createCounterRatesTableSQL, QuoteIdentifier(r.Table), cluster, engine, ttlExpr, PartitionExpr(settings.PartitionBy, "TimeUnix"))
+	query = settings.tableDDL(r.Table, query, counterRatesColumnComments)
+	if _, err := db.ExecContext(QueryContext(ctx, "create_table"), query); err != nil {
+		return fmt.Errorf("exec create counter rates table sql: %w", err)
+	}
+	var filter string
+	if r.Filter != "" {
+		filter = " AND " + r.Filter
+	}
+	quotedDatabase := QuoteIdentifier(database)
+	query = fmt.Sprintf(createCounterRatesViewSQL, QuoteIdentifier(r.View), cluster, quotedDatabase, QuoteIdentifier(r.Table),
+		quotedDatabase, QuoteIdentifier(r.Source), filter)
+	if _, err := db.ExecContext(QueryContext(ctx, "create_view"), query); err != nil {
+		return fmt.Errorf("exec create counter rates view sql: %w", err)
+	}
+	return nil
+}
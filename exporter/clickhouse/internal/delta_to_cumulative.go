@@ -0,0 +1,143 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package internal // import "github.com/foyer-work/otel-distribution/exporter/clickhouse/internal"
+
+import (
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+// seriesKey identifies the series of a datapoint by the hash of its resource, scope, metric and attributes.
+type seriesKey [16]byte
+
+func newSeriesKey(parts ...string) seriesKey {
+	h := fnv.New128a()
+	for _, part := range parts {
+		_, _ = h.Write([]byte(part))
+		_, _ = h.Write([]byte{0})
+	}
+	var key seriesKey
+	h.Sum(key[:0])
+	return key
+}
+
+// cumulativeSeries is the running total of a delta series.
+type cumulativeSeries struct {
+	start pcommon.Timestamp
+	// lastStart and last are the start and end of the last delta added to value.
+	lastStart pcommon.Timestamp
+	last      pcommon.Timestamp
+	value     float64
+	seen      time.Time
+}
+
+// DeltaToCumulative converts the datapoints of delta sums to cumulative, keeping the running total
+// of each series until it isn't written to for maxStale. The datapoints of an insert are converted
+// by a deltaConversion, updating the running totals only once the insert committed, so that the
+// attempts of an insert write the same values. Datapoints older than the last one of their series
+// are dropped, while the last one is converted again, so that a batch pushed again after its insert
+// committed doesn't add its deltas twice.
+type DeltaToCumulative struct {
+	maxStale time.Duration
+
+	mu      sync.Mutex
+	series  map[seriesKey]*cumulativeSeries
+	evicted time.Time
+}
+
+// NewDeltaToCumulative returns a converter forgetting the series not written to for maxStale.
+func NewDeltaToCumulative(maxStale time.Duration) *DeltaToCumulative {
+	return &DeltaToCumulative{
+		maxStale: maxStale,
+		series:   map[seriesKey]*cumulativeSeries{},
+		evicted:  time.Now(),
+	}
+}
+
+// begin returns the conversion of the datapoints of an insert.
+func (c *DeltaToCumulative) begin() *deltaConversion {
+	return &deltaConversion{c: c, series: map[seriesKey]*deltaSeries{}}
+}
+
+// load returns a copy of series key, a new series starting at start if unknown.
+func (c *DeltaToCumulative) load(key seriesKey, start pcommon.Timestamp) *deltaSeries {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.evict(time.Now())
+	if series, ok := c.series[key]; ok {
+		return &deltaSeries{cumulativeSeries: *series}
+	}
+	return &deltaSeries{cumulativeSeries: cumulativeSeries{start: start}}
+}
+
+// deltaConversion converts the delta datapoints of an insert on copies of their series.
+type deltaConversion struct {
+	c      *DeltaToCumulative
+	series map[seriesKey]*deltaSeries
+}
+
+// deltaSeries is the copy of a series converting the datapoints of an insert, with the sum of their deltas.
+type deltaSeries struct {
+	cumulativeSeries
+	added float64
+}
+
+// convert returns the start time and cumulative value of the delta datapoint of series key with value,
+// false if the datapoint is older than the last one of the series and must be dropped.
+func (b *deltaConversion) convert(key seriesKey, dp pmetric.NumberDataPoint, value float64) (pcommon.Timestamp, float64, bool) {
+	series, ok := b.series[key]
+	if !ok {
+		series = b.c.load(key, dp.StartTimestamp())
+		b.series[key] = series
+	}
+	switch {
+	case dp.StartTimestamp() == series.lastStart && dp.Timestamp() == series.last:
+		return series.start, series.value, true
+	case dp.Timestamp() <= series.last:
+		return 0, 0, false
+	}
+	series.lastStart, series.last = dp.StartTimestamp(), dp.Timestamp()
+	series.value += value
+	series.added += value
+	return series.start, series.value, true
+}
+
+// commit adds the deltas of the insert to the running totals of their series once it committed.
+// The deltas are added to the totals as they are by then, those of concurrent inserts included.
+func (b *deltaConversion) commit() {
+	c := b.c
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	for key, converted := range b.series {
+		series, ok := c.series[key]
+		if !ok {
+			series = &converted.cumulativeSeries
+			c.series[key] = series
+		} else {
+			if converted.last > series.last {
+				series.lastStart, series.last = converted.lastStart, converted.last
+			}
+			series.value += converted.added
+		}
+		series.seen = now
+	}
+}
+
+// evict forgets the stale series, at most once per maxStale.
+func (c *DeltaToCumulative) evict(now time.Time) {
+	if now.Sub(c.evicted) < c.maxStale {
+		return
+	}
+	c.evicted = now
+	for key, series := range c.series {
+		if now.Sub(series.seen) >= c.maxStale {
+			delete(c.series, key)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+func TestDeltaToCumulative(t *testing.T) {
+	c := NewDeltaToCumulative(time.Minute)
+	delta := func(start, end pcommon.Timestamp) pmetric.NumberDataPoint {
+		dp := pmetric.NewNumberDataPoint()
+		dp.SetStartTimestamp(start)
+		dp.SetTimestamp(end)
+		return dp
+	}
+	requests, errors := newSeriesKey("requests"), newSeriesKey("errors")
+	require.NotEqual(t, requests, errors)
+	convert := func(key seriesKey, dp pmetric.NumberDataPoint, value float64) (pcommon.Timestamp, float64, bool) {
+		conversion := c.begin()
+		defer conversion.commit()
+		return conversion.convert(key, dp, value)
+	}
+
+	start, value, ok := convert(requests, delta(10, 20), 2)
+	require.True(t, ok)
+	require.Equal(t, pcommon.Timestamp(10), start)
+	require.InDelta(t, 2, value, 0)
+
+	_, value, _ = convert(requests, delta(20, 30), 1.5)
+	require.InDelta(t, 3.5, value, 0)
+	_, value, _ = convert(errors, delta(20, 30), 1)
+	require.InDelta(t, 1, value, 0, "series are independent")
+
+	_, value, ok = convert(requests, delta(20, 30), 1.5)
+	require.True(t, ok, "the last datapoint is converted again")
+	require.InDelta(t, 3.5, value, 0)
+	_, _, ok = convert(requests, delta(15, 25), 1)
+	require.False(t, ok, "older datapoints are dropped")
+
+	c.series[requests].seen = time.Now().Add(-time.Minute)
+	c.evicted = time.Now().Add(-time.Minute)
+	start, value, _ = convert(requests, delta(40, 50), 4)
+	require.Equal(t, pcommon.Timestamp(40), start, "stale series start again")
+	require.InDelta(t, 4, value, 0)
+	require.Len(t, c.series, 2)
+
+	t.Run("uncommitted", func(t *testing.T) {
+		conversion := c.begin()
+		_, value, _ := conversion.convert(requests, delta(50, 60), 1)
+		require.InDelta(t, 5, value, 0)
+		_, value, _ = conversion.convert(requests, delta(60, 70), 2)
+		require.InDelta(t, 7, value, 0, "the datapoints of an insert add up")
+		_, value, _ = c.begin().convert(requests, delta(50, 60), 1)
+		require.InDelta(t, 5, value, 0, "the series is updated once the insert committed")
+
+		conversion.commit()
+		_, value, ok := c.begin().convert(requests, delta(60, 70), 2)
+		require.True(t, ok)
+		require.InDelta(t, 7, value, 0)
+	})
+}
@@ -0,0 +1,276 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package internal // import "github.com/foyer-work/otel-distribution/exporter/clickhouse/internal"
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DimensionsRefreshInterval is how often the row of a resource or scope is written again to its dimension table,
+// updating its LastSeen.
+const DimensionsRefreshInterval = time.Hour
+
+// EmptyAttributes is written into the ResourceAttributes and ScopeAttributes columns of the rows referencing
+// their resource and scope by fingerprint.
+const EmptyAttributes = "{}"
+
+const (
+	// language=ClickHouse SQL
+	createResourcesTableSQL = `
+CREATE TABLE IF NOT EXISTS %s %s (
+	ResourceFingerprint UInt64 CODEC(ZSTD(1)),
+	ResourceSchemaUrl LowCardinality(String) CODEC(ZSTD(1)),
+	ResourceAttributes JSON,
+	ServiceName LowCardinality(String) CODEC(ZSTD(1)),
+	LastSeen DateTime CODEC(Delta, ZSTD(1))
+) ENGINE = %s
+ORDER BY ResourceFingerprint
+%s
+SETTINGS index_granularity=8192;
+`
+	// language=ClickHouse SQL
+	createScopesTableSQL = `
+CREATE TABLE IF NOT EXISTS %s %s (
+	ScopeFingerprint UInt64 CODEC(ZSTD(1)),
+	ScopeSchemaUrl LowCardinality(String) CODEC(ZSTD(1)),
+	ScopeName String CODEC(ZSTD(1)),
+	ScopeVersion String CODEC(ZSTD(1)),
+	ScopeAttributes JSON,
+	LastSeen DateTime CODEC(Delta, ZSTD(1))
+) ENGINE = %s
+ORDER BY ScopeFingerprint
+%s
+SETTINGS index_granularity=8192;
+`
+	// language=ClickHouse SQL
+	insertResourcesSQL = `INSERT INTO %s (
+    ResourceFingerprint,
+    ResourceSchemaUrl,
+    ResourceAttributes,
+    ServiceName,
+    LastSeen) VALUES (?,?,?,?,?)`
+	// language=ClickHouse SQL
+	insertScopesSQL = `INSERT INTO %s (
+    ScopeFingerprint,
+    ScopeSchemaUrl,
+    ScopeName,
+    ScopeVersion,
+    ScopeAttributes,
+    LastSeen) VALUES (?,?,?,?,?,?)`
+)
+
+// dimensionsColumnComments describes the columns of the dimension tables and the fingerprint columns.
+var dimensionsColumnComments = map[string]string{
+	"ResourceFingerprint": "Fingerprint of the resource schema url and attributes",
+	"ResourceSchemaUrl":   "ResourceLogs, ResourceSpans or ResourceMetrics schema_url",
+	"ResourceAttributes":  "Resource.attributes",
+	"ServiceName":         "Resource attribute service.name",
+	"ScopeFingerprint":    "Fingerprint of the scope schema url, name, version and attributes",
+	"ScopeSchemaUrl":      "ScopeLogs, ScopeSpans or ScopeMetrics schema_url",
+	"ScopeName":           "InstrumentationScope.name",
+	"ScopeVersion":        "InstrumentationScope.version",
+	"ScopeAttributes":     "InstrumentationScope.attributes",
+	"LastSeen":            "Last time the row was written, refreshed hourly",
+}
+
+// FingerprintColumns returns the definitions of the ResourceFingerprint and ScopeFingerprint columns of the
+// rows referencing their resource and scope by fingerprint, in this order.
+func FingerprintColumns() []ColumnDef {
+	return []ColumnDef{
+		{Name: "ResourceFingerprint", Type: "UInt64", Comment: dimensionsColumnComments["ResourceFingerprint"], Codec: "ZSTD(1)"},
+		{Name: "ScopeFingerprint", Type: "UInt64", Comment: dimensionsColumnComments["ScopeFingerprint"], Codec: "ZSTD(1)"},
+	}
+}
+
+// AddFingerprintColumns adds the fingerprint columns after the ServiceName column of the CREATE TABLE statement ddl.
+func AddFingerprintColumns(ddl string) string {
+	defs := ""
+	for _, column := range FingerprintColumns() {
+		defs += fmt.Sprintf("\n${1}%s %s COMMENT %s CODEC(%s),", column.Name, column.Type, QuoteString(column.Comment), column.Codec)
+	}
+	return serviceNameColumnRegexp.ReplaceAllString(ddl, "$0"+defs)
+}
+
+// ResourceFingerprint returns the fingerprint of the resource with schemaURL and attrs, its attributes
+// serialized by AttributesToJSON.
+func ResourceFingerprint(schemaURL, attrs string) uint64 {
+	return AttributesHash(schemaURL + "\x00" + attrs)
+}
+
+// ScopeFingerprint returns the fingerprint of the scope with schemaURL, name, version and attrs, its attributes
+// serialized by AttributesToJSON.
+func ScopeFingerprint(schemaURL, name, version, attrs string) uint64 {
+	return AttributesHash(schemaURL + "\x00" + name + "\x00" + version + "\x00" + attrs)
+}
+
+type resourceDimension struct {
+	schemaURL   string
+	attrs       string
+	serviceName string
+}
+
+type scopeDimension struct {
+	schemaURL string
+	name      string
+	version   string
+	attrs     string
+}
+
+// DimensionRows holds the distinct resources and scopes of a batch, keyed by fingerprint.
+type DimensionRows struct {
+	resources map[uint64]resourceDimension
+	scopes    map[uint64]scopeDimension
+}
+
+// NewDimensionRows returns empty DimensionRows.
+func NewDimensionRows() *DimensionRows {
+	return &DimensionRows{resources: map[uint64]resourceDimension{}, scopes: map[uint64]scopeDimension{}}
+}
+
+// AddResource adds the resource with schemaURL, attrs and serviceName and returns its fingerprint.
+func (r *DimensionRows) AddResource(schemaURL, attrs, serviceName string) uint64 {
+	fingerprint := ResourceFingerprint(schemaURL, attrs)
+	r.resources[fingerprint] = resourceDimension{schemaURL: schemaURL, attrs: attrs, serviceName: serviceName}
+	return fingerprint
+}
+
+// AddScope adds the scope with schemaURL, name, version and attrs and returns its fingerprint.
+func (r *DimensionRows) AddScope(schemaURL, name, version, attrs string) uint64 {
+	fingerprint := ScopeFingerprint(schemaURL, name, version, attrs)
+	r.scopes[fingerprint] = scopeDimension{schemaURL: schemaURL, name: name, version: version, attrs: attrs}
+	return fingerprint
+}
+
+// Dimensions maintains the resources and scopes tables, with a row per distinct resource and scope keyed by
+// fingerprint, so that the rows of the logs, traces and metrics tables only hold the fingerprints.
+// Each row is written once per DimensionsRefreshInterval, the table engine replacing the older rows.
+type Dimensions struct {
+	resourcesTable string
+	scopesTable    string
+
+	mu        sync.Mutex
+	resources map[uint64]time.Time
+	scopes    map[uint64]time.Time
+	pruned    time.Time
+}
+
+// NewDimensions returns the writer of the resourcesTable and scopesTable dimension tables.
+func NewDimensions(resourcesTable, scopesTable string) *Dimensions {
+	return &Dimensions{
+		resourcesTable: resourcesTable,
+		scopesTable:    scopesTable,
+		resources:      map[uint64]time.Time{},
+		scopes:         map[uint64]time.Time{},
+	}
+}
+
+// CreateTables creates the dimension tables with engine, columns applying the user overrides of their columns.
+func (d *Dimensions) CreateTables(ctx context.Context, cluster, engine, ttlExpr string, columns ColumnOptions, db *sql.DB) error {
+	for _, table := range []struct{ name, sql string }{
+		{d.resourcesTable, createResourcesTableSQL},
+		{d.scopesTable, createScopesTableSQL},
+	} {
+		query := fmt.Sprintf(table.sql, QuoteIdentifier(table.name), cluster, engine, ttlExpr)
+		query = columns.Apply(table.name, CommentColumns(query, dimensionsColumnComments))
+		if _, err := db.ExecContext(QueryContext(ctx, "create_table"), query); err != nil {
+			return fmt.Errorf("exec create dimension table sql: %w", err)
+		}
+	}
+	return nil
+}
+
+// Write writes the resources and scopes of rows not written within DimensionsRefreshInterval.
+func (d *Dimensions) Write(ctx context.Context, db *sql.DB, rows *DimensionRows) error {
+	if d == nil {
+		return nil
+	}
+	now := time.Now()
+	resources, scopes := d.due(rows, now)
+	if len(resources) != 0 {
+		err := d.insert(ctx, db, "insert_resources", d.resourcesTable, insertResourcesSQL, len(resources), func(ctx context.Context, statement *sql.Stmt, i int) error {
+			row := rows.resources[resources[i]]
+			_, err := ExecRow(ctx, statement, resources[i], row.schemaURL, row.attrs, row.serviceName, now)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if len(scopes) != 0 {
+		err := d.insert(ctx, db, "insert_scopes", d.scopesTable, insertScopesSQL, len(scopes), func(ctx context.Context, statement *sql.Stmt, i int) error {
+			row := rows.scopes[scopes[i]]
+			_, err := ExecRow(ctx, statement, scopes[i], row.schemaURL, row.name, row.version, row.attrs, now)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, fingerprint := range resources {
+		d.resources[fingerprint] = now
+	}
+	for _, fingerprint := range scopes {
+		d.scopes[fingerprint] = now
+	}
+	return nil
+}
+
+// insert writes n rows into table with the INSERT statement template, exec binding the row i.
+func (*Dimensions) insert(ctx context.Context, db *sql.DB, operation, table, template string, n int, exec func(ctx context.Context, statement *sql.Stmt, i int) error) error {
+	ctx, observe := ObserveInsert(InsertContext(ctx, operation), table)
+	err := doWithTx(ctx, db, func(ctx context.Context, tx *sql.Tx) error {
+		statement, err := tx.PrepareContext(ctx, fmt.Sprintf(template, QuoteIdentifier(table)))
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = statement.Close()
+		}()
+		for i := range n {
+			if err := exec(ctx, statement, i); err != nil {
+				return fmt.Errorf("ExecContext:%w", err)
+			}
+		}
+		return nil
+	})
+	observe(err)
+	if err != nil {
+		return fmt.Errorf("insert %s fail:%w", table, err)
+	}
+	return nil
+}
+
+// due returns the fingerprints of the resources and scopes of rows not written within DimensionsRefreshInterval,
+// forgetting those not written for longer once per interval.
+func (d *Dimensions) due(rows *DimensionRows, now time.Time) (resources, scopes []uint64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if now.Sub(d.pruned) >= DimensionsRefreshInterval {
+		for _, written := range []map[uint64]time.Time{d.resources, d.scopes} {
+			for fingerprint, at := range written {
+				if now.Sub(at) >= DimensionsRefreshInterval {
+					delete(written, fingerprint)
+				}
+			}
+		}
+		d.pruned = now
+	}
+	for fingerprint := range rows.resources {
+		if now.Sub(d.resources[fingerprint]) >= DimensionsRefreshInterval {
+			resources = append(resources, fingerprint)
+		}
+	}
+	for fingerprint := range rows.scopes {
+		if now.Sub(d.scopes[fingerprint]) >= DimensionsRefreshInterval {
+			scopes = append(scopes, fingerprint)
+		}
+	}
+	return resources, scopes
+}
@@ -0,0 +1,61 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package internal
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+func TestFingerprints(t *testing.T) {
+	require.Equal(t, ResourceFingerprint("url", `{"service.name":"a"}`), ResourceFingerprint("url", `{"service.name":"a"}`))
+	require.NotEqual(t, ResourceFingerprint("url", `{"service.name":"a"}`), ResourceFingerprint("", `{"service.name":"a"}`))
+	require.NotEqual(t, ScopeFingerprint("", "lib", "1.0", EmptyAttributes), ScopeFingerprint("", "lib1", ".0", EmptyAttributes))
+	require.NotEqual(t, ScopeFingerprint("", "lib", "1.0", EmptyAttributes), ScopeFingerprint("", "lib", "1.0", `{"k":"v"}`))
+}
+
+func TestAddFingerprintColumns(t *testing.T) {
+	ddl := fmt.Sprintf(createGaugeTableSQL, "`otel_metrics_gauge`", "", "", "MergeTree()", "", "toDate(TimeUnix)")
+	require.Contains(t, AddFingerprintColumns(ddl), "\tServiceName LowCardinality(String) CODEC(ZSTD(1)),\n"+
+		"\tResourceFingerprint UInt64 COMMENT 'Fingerprint of the resource schema url and attributes' CODEC(ZSTD(1)),\n"+
+		"\tScopeFingerprint UInt64 COMMENT 'Fingerprint of the scope schema url, name, version and attributes' CODEC(ZSTD(1)),\n")
+
+	settings := MetricsSettings{TenantColumn: "Tenant", Tenant: "acme", Dimensions: true}
+	require.Contains(t, settings.insertSQL(fmt.Sprintf(insertSummaryTableSQL, "`otel_metrics_summary`")), "    Flags,\n    Tenant,\n    ResourceFingerprint,\n    ScopeFingerprint) VALUES (")
+
+	metrics := pmetric.NewResourceMetrics()
+	metrics.Resource().Attributes().PutStr("service.name", "a")
+	metadata := &MetricsMetaData{ResAttr: metrics.Resource().Attributes(), ResURL: "url", ScopeInstr: metrics.ScopeMetrics().AppendEmpty().Scope()}
+	extra := settings.extraColumns(0).withFingerprints(metadata, `{"service.name":"a"}`, EmptyAttributes)
+	require.Equal(t, EmptyAttributes, extra.attributes(`{"service.name":"a"}`))
+	require.Equal(t, []any{"acme", ResourceFingerprint("url", `{"service.name":"a"}`), ScopeFingerprint("", "", "", EmptyAttributes)}, extra.bind(nil, "", 0))
+}
+
+func TestDimensions_due(t *testing.T) {
+	dimensions := NewDimensions("otel_resources", "otel_scopes")
+	rows := NewDimensionRows()
+	resource := rows.AddResource("", `{"service.name":"a"}`, "a")
+	scope := rows.AddScope("", "lib", "1.0", EmptyAttributes)
+	require.Equal(t, resource, rows.AddResource("", `{"service.name":"a"}`, "a"))
+
+	now := time.Now()
+	resources, scopes := dimensions.due(rows, now)
+	require.Equal(t, []uint64{resource}, resources)
+	require.Equal(t, []uint64{scope}, scopes)
+
+	dimensions.resources[resource] = now
+	dimensions.scopes[scope] = now
+	resources, scopes = dimensions.due(rows, now.Add(DimensionsRefreshInterval/2))
+	require.Empty(t, resources)
+	require.Empty(t, scopes)
+
+	resources, scopes = dimensions.due(rows, now.Add(DimensionsRefreshInterval))
+	require.Equal(t, []uint64{resource}, resources)
+	require.Equal(t, []uint64{scope}, scopes)
+	require.Empty(t, dimensions.resources, "pruned")
+}
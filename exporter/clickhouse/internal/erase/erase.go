@@ -0,0 +1,347 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package erase // import "github.com/foyer-work/otel-distribution/exporter/clickhouse/internal/erase"
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/foyer-work/otel-distribution/exporter/clickhouse/internal"
+)
+
+// Options configures an Eraser. The rows deleted match all the criteria set, at least one being required.
+type Options struct {
+	// Database holding the exporter tables.
+	Database string
+	// Cluster runs the deletes ON CLUSTER, for tables that aren't replicated.
+	Cluster string
+	// Tables limits the deletes to these tables. Default is all the MergeTree tables of Database
+	// having the columns of the criteria.
+	Tables []string
+	// TraceIDs matches the rows whose TraceId is one of these hex trace ids.
+	TraceIDs []string
+	// Tenant matches the rows whose TenantColumn is Tenant.
+	Tenant string
+	// TenantColumn is the column holding the tenant. Default is `Tenant`.
+	TenantColumn string
+	// Attributes matches the rows having these attributes in any of their resource, scope, log, span or
+	// datapoint attributes columns.
+	Attributes map[string]string
+	// Where is an optional extra filter.
+	Where string
+	// Settings are appended as a SETTINGS clause to every DELETE.
+	Settings map[string]string
+	// StateFile is where progress is persisted. Deleting resumes from it when present.
+	StateFile string
+	// DryRun logs the statements instead of executing them.
+	DryRun bool
+}
+
+// State is the persisted progress of an erasure, keyed by table.
+type State struct {
+	Criteria  string                   `json:"criteria"`
+	Completed map[string]TableProgress `json:"completed"`
+}
+
+// TableProgress records a table whose matching rows were deleted.
+type TableProgress struct {
+	Rows       uint64    `json:"rows"`
+	FinishedAt time.Time `json:"finished_at"`
+}
+
+// Column is a column of a table as reported by system.columns.
+type Column struct {
+	Name string
+	Type string
+}
+
+// Eraser deletes the rows matching criteria from the exporter tables with lightweight DELETEs, one table
+// per statement, so that a restarted run picks up after the last completed table.
+type Eraser struct {
+	db     *sql.DB
+	logger *zap.Logger
+	opts   Options
+	state  *State
+}
+
+var (
+	errNoCriteria    = errors.New("at least one of trace ids, tenant, attributes or where must be specified")
+	errStateMismatch = errors.New("state file belongs to different criteria")
+)
+
+// NewEraser creates an Eraser and loads previous progress from opts.StateFile.
+func NewEraser(db *sql.DB, logger *zap.Logger, opts Options) (*Eraser, error) {
+	if len(opts.TraceIDs) == 0 && opts.Tenant == "" && len(opts.Attributes) == 0 && opts.Where == "" {
+		return nil, errNoCriteria
+	}
+	if opts.TenantColumn == "" {
+		opts.TenantColumn = "Tenant"
+	}
+	state, err := loadState(opts.StateFile)
+	if err != nil {
+		return nil, err
+	}
+	criteria := opts.criteria()
+	if state.Criteria == "" {
+		state.Criteria = criteria
+	}
+	if state.Criteria != criteria {
+		return nil, fmt.Errorf("%w: %s", errStateMismatch, state.Criteria)
+	}
+
+	return &Eraser{
+		db:     db,
+		logger: logger,
+		opts:   opts,
+		state:  state,
+	}, nil
+}
+
+// Run deletes the matching rows of every table not yet recorded as completed.
+func (e *Eraser) Run(ctx context.Context) error {
+	tables, err := e.tables(ctx)
+	if err != nil {
+		return err
+	}
+	for i, table := range slices.Sorted(maps.Keys(tables)) {
+		if _, ok := e.state.Completed[table]; ok {
+			continue
+		}
+		predicate, ok := e.predicate(tables[table])
+		if !ok {
+			e.logger.Debug("table skipped, missing the columns of the criteria", zap.String("table", table))
+			continue
+		}
+
+		query := e.renderDelete(table, predicate)
+		if e.opts.DryRun {
+			e.logger.Info("dry run", zap.String("table", table), zap.String("query", query))
+			continue
+		}
+		start := time.Now()
+		var rows uint64
+		if err := e.db.QueryRowContext(ctx, e.renderCount(table, predicate)).Scan(&rows); err != nil {
+			return fmt.Errorf("count rows of %s: %w", table, err)
+		}
+		if rows != 0 {
+			if _, err := e.db.ExecContext(ctx, query); err != nil {
+				return fmt.Errorf("delete from %s: %w", table, err)
+			}
+		}
+
+		e.state.Completed[table] = TableProgress{Rows: rows, FinishedAt: time.Now().UTC()}
+		if err := e.saveState(); err != nil {
+			return err
+		}
+		e.logger.Info("table erased",
+			zap.String("table", table),
+			zap.Uint64("rows", rows),
+			zap.Duration("cost", time.Since(start)),
+			zap.String("tables", fmt.Sprintf("%d/%d", i+1, len(tables))),
+		)
+	}
+	return nil
+}
+
+// tables returns the columns of the MergeTree tables of the database, limited to Options.Tables if set.
+func (e *Eraser) tables(ctx context.Context) (map[string][]Column, error) {
+	rows, err := e.db.QueryContext(ctx,
+		"SELECT c.table, c.name, c.type FROM system.columns AS c INNER JOIN system.tables AS t ON t.database = c.database AND t.name = c.table "+
+			"WHERE c.database = ? AND t.engine LIKE '%MergeTree' ORDER BY c.table, c.position",
+		e.opts.database())
+	if err != nil {
+		return nil, fmt.Errorf("list tables: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	tables := map[string][]Column{}
+	for rows.Next() {
+		var table string
+		var col Column
+		if err := rows.Scan(&table, &col.Name, &col.Type); err != nil {
+			return nil, fmt.Errorf("list tables: %w", err)
+		}
+		if len(e.opts.Tables) == 0 || slices.Contains(e.opts.Tables, table) {
+			tables[table] = append(tables[table], col)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list tables: %w", err)
+	}
+	for _, table := range e.opts.Tables {
+		if _, ok := tables[table]; !ok {
+			return nil, fmt.Errorf("MergeTree table %s not found", e.opts.qualified(table))
+		}
+	}
+	return tables, nil
+}
+
+// predicate returns the WHERE clause matching the criteria in a table of columns, false if the table lacks
+// the columns of a criterion.
+func (e *Eraser) predicate(columns []Column) (string, bool) {
+	types := make(map[string]string, len(columns))
+	for _, col := range columns {
+		types[col.Name] = col.Type
+	}
+	var conditions []string
+	if len(e.opts.TraceIDs) != 0 {
+		typ, ok := types["TraceId"]
+		if !ok {
+			return "", false
+		}
+		ids := make([]string, 0, len(e.opts.TraceIDs))
+		for _, id := range e.opts.TraceIDs {
+			if strings.HasPrefix(typ, "FixedString") {
+				ids = append(ids, fmt.Sprintf("unhex(%s)", internal.QuoteString(id)))
+			} else {
+				ids = append(ids, internal.QuoteString(id))
+			}
+		}
+		conditions = append(conditions, fmt.Sprintf("TraceId IN (%s)", strings.Join(ids, ", ")))
+	}
+	if e.opts.Tenant != "" {
+		if _, ok := types[e.opts.TenantColumn]; !ok {
+			return "", false
+		}
+		conditions = append(conditions, fmt.Sprintf("%s = %s", internal.QuoteIdentifier(e.opts.TenantColumn), internal.QuoteString(e.opts.Tenant)))
+	}
+	for _, key := range slices.Sorted(maps.Keys(e.opts.Attributes)) {
+		var matches []string
+		for _, col := range columns {
+			if match, ok := attributeMatch(col, key, e.opts.Attributes[key]); ok {
+				matches = append(matches, match)
+			}
+		}
+		if len(matches) == 0 {
+			return "", false
+		}
+		conditions = append(conditions, "("+strings.Join(matches, " OR ")+")")
+	}
+	if e.opts.Where != "" {
+		conditions = append(conditions, "("+e.opts.Where+")")
+	}
+	return strings.Join(conditions, " AND "), true
+}
+
+// attributeMatch returns the condition of the attribute key being value in col, false if col isn't an
+// attributes column. Attributes are Map(LowCardinality(String), String), JSON or, on servers without
+// the JSON type, String columns holding JSON.
+func attributeMatch(col Column, key, value string) (string, bool) {
+	if !strings.HasSuffix(col.Name, "Attributes") || strings.Contains(col.Name, ".") {
+		return "", false
+	}
+	name := internal.QuoteIdentifier(col.Name)
+	switch {
+	case strings.HasPrefix(col.Type, "Map("):
+		return fmt.Sprintf("%s[%s] = %s", name, internal.QuoteString(key), internal.QuoteString(value)), true
+	case strings.HasPrefix(col.Type, "JSON"):
+		return fmt.Sprintf("toString(%s.%s) = %s", name, internal.QuoteIdentifier(key), internal.QuoteString(value)), true
+	case col.Type == "String":
+		return fmt.Sprintf("JSONExtractString(%s, %s) = %s", name, internal.QuoteString(key), internal.QuoteString(value)), true
+	default:
+		return "", false
+	}
+}
+
+func (e *Eraser) renderDelete(table, predicate string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "DELETE FROM %s", e.opts.qualified(table))
+	if e.opts.Cluster != "" {
+		fmt.Fprintf(&b, " ON CLUSTER %s", internal.QuoteIdentifier(e.opts.Cluster))
+	}
+	fmt.Fprintf(&b, " WHERE %s", predicate)
+	if len(e.opts.Settings) != 0 {
+		keys := slices.Sorted(maps.Keys(e.opts.Settings))
+		pairs := make([]string, 0, len(keys))
+		for _, k := range keys {
+			pairs = append(pairs, k+" = "+e.opts.Settings[k])
+		}
+		fmt.Fprintf(&b, " SETTINGS %s", strings.Join(pairs, ", "))
+	}
+	return b.String()
+}
+
+func (e *Eraser) renderCount(table, predicate string) string {
+	return fmt.Sprintf("SELECT count() FROM %s WHERE %s", e.opts.qualified(table), predicate)
+}
+
+func (e *Eraser) saveState() error {
+	if e.opts.StateFile == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(e.state, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := e.opts.StateFile + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("write state: %w", err)
+	}
+	return os.Rename(tmp, e.opts.StateFile)
+}
+
+func loadState(path string) (*State, error) {
+	state := &State{Completed: map[string]TableProgress{}}
+	if path == "" {
+		return state, nil
+	}
+	data, err := os.ReadFile(filepath.Clean(path))
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read state: %w", err)
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("parse state %s: %w", path, err)
+	}
+	if state.Completed == nil {
+		state.Completed = map[string]TableProgress{}
+	}
+	return state, nil
+}
+
+// criteria describes the rows matched by the options, identifying the erasure in the state file.
+func (o Options) criteria() string {
+	var parts []string
+	if len(o.TraceIDs) != 0 {
+		ids := slices.Clone(o.TraceIDs)
+		slices.Sort(ids)
+		parts = append(parts, "trace_ids="+strings.Join(ids, ","))
+	}
+	if o.Tenant != "" {
+		parts = append(parts, o.TenantColumn+"="+o.Tenant)
+	}
+	for _, key := range slices.Sorted(maps.Keys(o.Attributes)) {
+		parts = append(parts, "attribute:"+key+"="+o.Attributes[key])
+	}
+	if o.Where != "" {
+		parts = append(parts, "where="+o.Where)
+	}
+	return o.database() + ": " + strings.Join(parts, " ")
+}
+
+func (o Options) database() string {
+	if o.Database == "" {
+		return "default"
+	}
+	return o.Database
+}
+
+func (o Options) qualified(table string) string {
+	return internal.QuoteIdentifier(o.database()) + "." + internal.QuoteIdentifier(table)
+}
@@ -0,0 +1,67 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package erase
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestNewEraser_resumesFromState(t *testing.T) {
+	stateFile := filepath.Join(t.TempDir(), "state.json")
+	opts := Options{Database: "otel", Tenant: "acme", StateFile: stateFile}
+
+	e, err := NewEraser(nil, zaptest.NewLogger(t), opts)
+	require.NoError(t, err)
+	e.state.Completed["otel_logs"] = TableProgress{Rows: 42}
+	require.NoError(t, e.saveState())
+
+	e, err = NewEraser(nil, zaptest.NewLogger(t), opts)
+	require.NoError(t, err)
+	require.Equal(t, uint64(42), e.state.Completed["otel_logs"].Rows)
+
+	opts.Tenant = "globex"
+	_, err = NewEraser(nil, zaptest.NewLogger(t), opts)
+	require.ErrorIs(t, err, errStateMismatch)
+
+	_, err = NewEraser(nil, zaptest.NewLogger(t), Options{Database: "otel"})
+	require.ErrorIs(t, err, errNoCriteria)
+}
+
+func TestEraser_predicate(t *testing.T) {
+	e, err := NewEraser(nil, zaptest.NewLogger(t), Options{
+		Database:   "otel",
+		Cluster:    "main",
+		TraceIDs:   []string{"0102"},
+		Tenant:     "acme",
+		Attributes: map[string]string{"user.id": "u'1"},
+		Settings:   map[string]string{"lightweight_deletes_sync": "0"},
+	})
+	require.NoError(t, err)
+
+	predicate, ok := e.predicate([]Column{
+		{Name: "TraceId", Type: "FixedString(16)"},
+		{Name: "Tenant", Type: "LowCardinality(String)"},
+		{Name: "ResourceAttributes", Type: "Map(LowCardinality(String), String)"},
+		{Name: "LogAttributes", Type: "JSON"},
+		{Name: "Events.Attributes", Type: "Array(Map(LowCardinality(String), String))"},
+	})
+	require.True(t, ok)
+	require.Equal(t,
+		"TraceId IN (unhex('0102')) AND `Tenant` = 'acme' AND "+
+			"(`ResourceAttributes`['user.id'] = 'u\\'1' OR toString(`LogAttributes`.`user.id`) = 'u\\'1')",
+		predicate)
+	require.Equal(t,
+		"DELETE FROM `otel`.`otel_logs` ON CLUSTER `main` WHERE TraceId IN ('0102') SETTINGS lightweight_deletes_sync = 0",
+		e.renderDelete("otel_logs", "TraceId IN ('0102')"))
+
+	_, ok = e.predicate([]Column{
+		{Name: "TraceId", Type: "String"},
+		{Name: "Tenant", Type: "String"},
+	})
+	require.False(t, ok, "table without attributes columns")
+}
@@ -0,0 +1,214 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package internal // import "github.com/foyer-work/otel-distribution/exporter/clickhouse/internal"
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+// ExemplarsMode controls how datapoint exemplars are stored.
+type ExemplarsMode string
+
+const (
+	// ExemplarsModeInline stores exemplars in the Exemplars Nested column of each metric table.
+	ExemplarsModeInline ExemplarsMode = "inline"
+	// ExemplarsModeSeparateTable stores exemplars in a dedicated table.
+	ExemplarsModeSeparateTable ExemplarsMode = "separate_table"
+	// ExemplarsModeDrop discards exemplars.
+	ExemplarsModeDrop ExemplarsMode = "drop"
+)
+
+const (
+	// language=ClickHouse SQL
+	exemplarsColumnSQL = `	Exemplars Nested (
+		FilteredAttributes JSON,
+		TimeUnix DateTime64(9),
+		Value Float64,
+		SpanId String,
+		TraceId String
+	) CODEC(ZSTD(1)),
+`
+	insertExemplarsColumns = `,
+    Exemplars.FilteredAttributes,
+    Exemplars.TimeUnix,
+    Exemplars.Value,
+    Exemplars.SpanId,
+    Exemplars.TraceId`
+	insertExemplarsValues = `,?,?,?,?,?`
+
+	// language=ClickHouse SQL
+	createExemplarsTableSQL = `
+CREATE TABLE IF NOT EXISTS %s %s (
+	ServiceName LowCardinality(String) CODEC(ZSTD(1)),
+	MetricName String CODEC(ZSTD(1)),
+	MetricType LowCardinality(String) CODEC(ZSTD(1)),
+	Attributes JSON,
+	DataPointTimeUnix DateTime64(9) CODEC(Delta, ZSTD(1)),
+	TimeUnix DateTime64(9) CODEC(Delta, ZSTD(1)),
+	Value Float64 CODEC(ZSTD(1)),
+	FilteredAttributes JSON,
+	SpanId String CODEC(ZSTD(1)),
+	TraceId String CODEC(ZSTD(1)),
+	INDEX idx_trace_id TraceId TYPE bloom_filter(0.001) GRANULARITY 1
+) ENGINE = %s
+PARTITION BY %s
+ORDER BY (ServiceName, MetricName, toUnixTimestamp64Nano(TimeUnix))
+%s
+SETTINGS index_granularity=8192, ttl_only_drop_parts = 1;
+`
+	// language=ClickHouse SQL
+	insertExemplarsTableSQL = `INSERT INTO %s (
+    ServiceName,
+    MetricName,
+    MetricType,
+    Attributes,
+    DataPointTimeUnix,
+    TimeUnix,
+    Value,
+    FilteredAttributes,
+    SpanId,
+    TraceId) VALUES (?,?,?,?,?,?,?,?,?,?)`
+)
+
+// exemplarsWriter binds datapoint exemplars according to the configured ExemplarsMode.
+// In separate table mode rows are buffered by bind and written by flush, each attempt of the insert of the
+// datapoints buffering them again after reset.
+type exemplarsWriter struct {
+	mode       ExemplarsMode
+	metricType string
+	table      string
+	insertSQL  string
+	rows       [][]any
+	// maxPerDataPoint is the number of exemplars kept per datapoint, 0 for all.
+	maxPerDataPoint int
+	attributes      *AttributeFilter
+	ids             IDEncoding
+}
+
+func newExemplarsWriter(settings MetricsSettings, metricType pmetric.MetricType) *exemplarsWriter {
+	return &exemplarsWriter{
+		mode:       settings.exemplarsMode(),
+		metricType: metricType.String(),
+		table:      settings.ExemplarsTableName,
+		insertSQL:  fmt.Sprintf(insertExemplarsTableSQL, QuoteIdentifier(settings.ExemplarsTableName)),
+
+		maxPerDataPoint: settings.ExemplarsMaxPerDataPoint,
+		attributes:      settings.Attributes,
+		ids:             settings.IDEncoding,
+	}
+}
+
+func (w *exemplarsWriter) inline() bool {
+	return w.mode == ExemplarsModeInline
+}
+
+// reset discards the rows buffered by a previous attempt of the insert.
+func (w *exemplarsWriter) reset() {
+	w.rows = w.rows[:0]
+}
+
+// bind returns the Nested exemplar column values when exemplars are stored inline, nil otherwise.
+// The exemplars of a datapoint whose row is skipped on ctx are not buffered.
+func (w *exemplarsWriter) bind(ctx context.Context, serviceName, metricName, attrs string, timestamp time.Time, exemplars pmetric.ExemplarSlice) []any {
+	if w.mode != ExemplarsModeDrop {
+		exemplars = w.latest(exemplars)
+	}
+	switch w.mode {
+	case ExemplarsModeInline:
+		attrs, times, values, traceIDs, spanIDs := convertExemplars(exemplars, w.attributes, w.ids)
+		return []any{attrs, times, values, spanIDs, traceIDs}
+	case ExemplarsModeSeparateTable:
+		if RowSkipped(ctx) {
+			return nil
+		}
+		for i := range exemplars.Len() {
+			exemplar := exemplars.At(i)
+			w.rows = append(w.rows, []any{
+				serviceName,
+				metricName,
+				w.metricType,
+				attrs,
+				timestamp,
+				exemplar.Timestamp().AsTime(),
+				getValue(exemplar.IntValue(), exemplar.DoubleValue(), exemplar.ValueType()),
+				w.attributes.JSON(exemplar.FilteredAttributes()),
+				w.ids.SpanID(exemplar.SpanID()),
+				w.ids.TraceID(exemplar.TraceID()),
+			})
+		}
+	}
+	return nil
+}
+
+// latest returns the maxPerDataPoint latest of exemplars in time order, exemplars itself if there are no more.
+func (w *exemplarsWriter) latest(exemplars pmetric.ExemplarSlice) pmetric.ExemplarSlice {
+	if w.maxPerDataPoint <= 0 || exemplars.Len() <= w.maxPerDataPoint {
+		return exemplars
+	}
+	indexes := make([]int, exemplars.Len())
+	for i := range indexes {
+		indexes[i] = i
+	}
+	slices.SortStableFunc(indexes, func(a, b int) int {
+		return cmp.Compare(exemplars.At(a).Timestamp(), exemplars.At(b).Timestamp())
+	})
+	latest := pmetric.NewExemplarSlice()
+	latest.EnsureCapacity(w.maxPerDataPoint)
+	for _, i := range indexes[len(indexes)-w.maxPerDataPoint:] {
+		exemplars.At(i).CopyTo(latest.AppendEmpty())
+	}
+	return latest
+}
+
+// flush writes exemplars buffered by bind into the exemplars table.
+func (w *exemplarsWriter) flush(ctx context.Context, db *sql.DB) error {
+	if len(w.rows) == 0 {
+		return nil
+	}
+	ctx, observe := ObserveInsert(InsertContext(ctx, "insert_exemplars"), w.table)
+	err := doWithTx(ctx, db, func(ctx context.Context, tx *sql.Tx) error {
+		statement, err := tx.PrepareContext(ctx, w.insertSQL)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = statement.Close()
+		}()
+		for _, row := range w.rows {
+			if _, err := ExecRow(ctx, statement, row...); err != nil {
+				return fmt.Errorf("ExecContext:%w", err)
+			}
+		}
+		return nil
+	})
+	observe(err)
+	w.rows = nil
+	if err != nil {
+		return fmt.Errorf("insert %s exemplars fail:%w", w.metricType, err)
+	}
+	return nil
+}
+
+// exemplarsColumns returns the DDL for the Exemplars Nested column, or an empty string if exemplars are not stored inline.
+func exemplarsColumns(settings MetricsSettings) string {
+	if settings.exemplarsMode() != ExemplarsModeInline {
+		return ""
+	}
+	return exemplarsColumnSQL
+}
+
+// insertExemplars returns the INSERT column list and placeholders for the Exemplars Nested column.
+func insertExemplars(settings MetricsSettings) (columns string, values string) {
+	if settings.exemplarsMode() != ExemplarsModeInline {
+		return "", ""
+	}
+	return insertExemplarsColumns, insertExemplarsValues
+}
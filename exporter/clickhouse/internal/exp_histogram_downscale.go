@@ -0,0 +1,56 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package internal // import "github.com/foyer-work/otel-distribution/exporter/clickhouse/internal"
+
+import "go.opentelemetry.io/collector/pdata/pmetric"
+
+// minExpHistogramScale is the smallest scale of an exponential histogram, whose buckets cover the float64 range.
+const minExpHistogramScale = -10
+
+// expBuckets are the positive or negative buckets of an exponential histogram datapoint.
+type expBuckets struct {
+	offset int32
+	counts []uint64
+}
+
+func newExpBuckets(buckets pmetric.ExponentialHistogramDataPointBuckets) expBuckets {
+	return expBuckets{offset: buckets.Offset(), counts: buckets.BucketCounts().AsRaw()}
+}
+
+// lenAt returns the number of buckets once the scale is reduced by shift.
+func (b expBuckets) lenAt(shift int32) int {
+	if len(b.counts) == 0 {
+		return 0
+	}
+	first := b.offset >> shift
+	last := (b.offset + int32(len(b.counts)) - 1) >> shift
+	return int(last-first) + 1
+}
+
+// downscale returns the buckets once the scale is reduced by shift, each bucket
+// being merged into the bucket of the reduced scale holding its index >> shift.
+func (b expBuckets) downscale(shift int32) expBuckets {
+	if shift == 0 || len(b.counts) == 0 {
+		return b
+	}
+	out := expBuckets{offset: b.offset >> shift, counts: make([]uint64, b.lenAt(shift))}
+	for i, count := range b.counts {
+		out.counts[(b.offset+int32(i))>>shift-out.offset] += count
+	}
+	return out
+}
+
+// downscaleExpHistogram returns the scale and buckets of dp reduced until the positive and negative
+// buckets hold at most maxBuckets entries, or the smallest scale is reached. 0 keeps the datapoint scale.
+func downscaleExpHistogram(dp pmetric.ExponentialHistogramDataPoint, maxBuckets int) (int32, expBuckets, expBuckets) {
+	scale, positive, negative := dp.Scale(), newExpBuckets(dp.Positive()), newExpBuckets(dp.Negative())
+	if maxBuckets <= 0 {
+		return scale, positive, negative
+	}
+	var shift int32
+	for scale-shift > minExpHistogramScale && positive.lenAt(shift)+negative.lenAt(shift) > maxBuckets {
+		shift++
+	}
+	return scale - shift, positive.downscale(shift), negative.downscale(shift)
+}
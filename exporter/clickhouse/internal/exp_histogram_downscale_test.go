@@ -0,0 +1,38 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+func TestDownscaleExpHistogram(t *testing.T) {
+	dp := pmetric.NewExponentialHistogramDataPoint()
+	dp.SetScale(2)
+	dp.Positive().SetOffset(1)
+	dp.Positive().BucketCounts().FromRaw([]uint64{1, 1, 1, 1, 1})
+	dp.Negative().SetOffset(-3)
+	dp.Negative().BucketCounts().FromRaw([]uint64{1, 1})
+
+	scale, positive, negative := downscaleExpHistogram(dp, 0)
+	require.Equal(t, int32(2), scale)
+	require.Equal(t, expBuckets{offset: 1, counts: []uint64{1, 1, 1, 1, 1}}, positive)
+	require.Equal(t, expBuckets{offset: -3, counts: []uint64{1, 1}}, negative)
+
+	scale, _, _ = downscaleExpHistogram(dp, 7)
+	require.Equal(t, int32(2), scale, "datapoints within the limit are kept")
+
+	scale, positive, negative = downscaleExpHistogram(dp, 4)
+	require.Equal(t, int32(0), scale)
+	require.Equal(t, expBuckets{offset: 0, counts: []uint64{3, 2}}, positive)
+	require.Equal(t, expBuckets{offset: -1, counts: []uint64{2}}, negative)
+
+	scale, positive, negative = downscaleExpHistogram(dp, 1)
+	require.Equal(t, int32(minExpHistogramScale), scale, "the scale is not reduced below the smallest one")
+	require.Equal(t, expBuckets{offset: 0, counts: []uint64{5}}, positive)
+	require.Equal(t, expBuckets{offset: -1, counts: []uint64{2}}, negative)
+}
@@ -41,20 +41,13 @@ CREATE TABLE IF NOT EXISTS %s %s (
 	PositiveBucketCounts Array(UInt64) CODEC(ZSTD(1)),
 	NegativeOffset Int32 CODEC(ZSTD(1)),
 	NegativeBucketCounts Array(UInt64) CODEC(ZSTD(1)),
-	Exemplars Nested (
-		FilteredAttributes JSON,
-		TimeUnix DateTime64(9),
-		Value Float64,
-		SpanId String,
-		TraceId String
-	) CODEC(ZSTD(1)),
-	Flags UInt32  CODEC(ZSTD(1)),
+%s	Flags UInt32  CODEC(ZSTD(1)),
 	Min Float64 CODEC(ZSTD(1)),
 	Max Float64 CODEC(ZSTD(1)),
 	AggregationTemporality Int32 CODEC(ZSTD(1)),
 ) ENGINE = %s
 %s
-PARTITION BY toDate(TimeUnix)
+PARTITION BY %s
 ORDER BY (ServiceName, MetricName, Attributes, toUnixTimestamp64Nano(TimeUnix))
 SETTINGS index_granularity=8192, ttl_only_drop_parts = 1;
 `
@@ -81,16 +74,11 @@ SETTINGS index_granularity=8192, ttl_only_drop_parts = 1;
 		PositiveOffset,
 		PositiveBucketCounts,
 		NegativeOffset,
-		NegativeBucketCounts,
-  	Exemplars.FilteredAttributes,
-		Exemplars.TimeUnix,
-    Exemplars.Value,
-    Exemplars.SpanId,
-    Exemplars.TraceId,
+		NegativeBucketCounts%s,
 		Flags,
 		Min,
 		Max,
-		AggregationTemporality) VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)`
+		AggregationTemporality) VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?%s)`
 )
 
 type expHistogramModel struct {
@@ -103,8 +91,18 @@ type expHistogramModel struct {
 
 type expHistogramMetrics struct {
 	expHistogramModels []*expHistogramModel
+	table              string
 	insertSQL          string
 	count              int
+	exemplars          *exemplarsWriter
+	nonFinite          NonFinitePolicy
+	staleness          StalenessPolicy
+	attributes         *AttributeFilter
+	// nullableStats writes NULL for the sum, min and max not set on a datapoint.
+	nullableStats bool
+	// maxBuckets is the number of positive and negative buckets above which the datapoints are downscaled, 0 for no limit.
+	maxBuckets int
+	extra      extraColumns
 }
 
 func (e *expHistogramMetrics) insert(ctx context.Context, db *sql.DB) error {
@@ -112,8 +110,10 @@ func (e *expHistogramMetrics) insert(ctx context.Context, db *sql.DB) error {
 		return nil
 	}
 
+	ctx, observe := ObserveInsert(ctx, e.table)
 	start := time.Now()
-	err := doWithTx(ctx, db, func(tx *sql.Tx) error {
+	err := doWithTx(ctx, db, func(ctx context.Context, tx *sql.Tx) error {
+		e.exemplars.reset()
 		statement, err := tx.PrepareContext(ctx, e.insertSQL)
 		if err != nil {
 			return err
@@ -124,46 +124,56 @@ func (e *expHistogramMetrics) insert(ctx context.Context, db *sql.DB) error {
 		}()
 
 		for _, model := range e.expHistogramModels {
-			resAttr := AttributesToJSON(model.metadata.ResAttr)
-			scopeAttr := AttributesToJSON(model.metadata.ScopeInstr.Attributes())
+			resAttr := e.attributes.JSON(model.metadata.ResAttr)
+			scopeAttr := e.attributes.JSON(model.metadata.ScopeInstr.Attributes())
 			serviceName := GetServiceName(model.metadata.ResAttr)
+			extra := e.extra.withFingerprints(model.metadata, resAttr, scopeAttr)
 
 			for i := range model.expHistogram.DataPoints().Len() {
 				dp := model.expHistogram.DataPoints().At(i)
-				attrs, times, values, traceIDs, spanIDs := convertExemplars(dp.Exemplars())
-				_, err = statement.ExecContext(ctx,
-					resAttr,
+				stats, ok := e.staleness.applyAll(dp.Flags(), e.nonFinite, dp.Sum(), dp.Min(), dp.Max())
+				if !ok {
+					logger.Debug("dropped stale exponential histogram datapoint or with a non-finite sum, min or max", zap.String("metric", model.metricName))
+					continue
+				}
+				if e.nullableStats {
+					nullUnset(stats, dp.HasSum(), dp.HasMin(), dp.HasMax())
+				}
+				scale, positive, negative := downscaleExpHistogram(dp, e.maxBuckets)
+				attrs := e.attributes.JSON(dp.Attributes())
+				values := []any{
+					extra.attributes(resAttr),
 					model.metadata.ResURL,
 					model.metadata.ScopeInstr.Name(),
 					model.metadata.ScopeInstr.Version(),
-					scopeAttr,
+					extra.attributes(scopeAttr),
 					model.metadata.ScopeInstr.DroppedAttributesCount(),
 					model.metadata.ScopeURL,
 					serviceName,
 					model.metricName,
 					model.metricDescription,
 					model.metricUnit,
-					AttributesToJSON(dp.Attributes()),
+					attrs,
 					dp.StartTimestamp().AsTime(),
 					dp.Timestamp().AsTime(),
 					dp.Count(),
-					dp.Sum(),
-					dp.Scale(),
+					stats[0],
+					scale,
 					dp.ZeroCount(),
-					dp.Positive().Offset(),
-					convertSliceToArraySet(dp.Positive().BucketCounts().AsRaw()),
-					dp.Negative().Offset(),
-					convertSliceToArraySet(dp.Negative().BucketCounts().AsRaw()),
-					attrs,
-					times,
-					values,
-					spanIDs,
-					traceIDs,
+					positive.offset,
+					convertSliceToArraySet(positive.counts),
+					negative.offset,
+					convertSliceToArraySet(negative.counts),
+				}
+				values = append(values, e.exemplars.bind(ctx, serviceName, model.metricName, attrs, dp.Timestamp().AsTime(), dp.Exemplars())...)
+				values = append(values,
 					uint32(dp.Flags()),
-					dp.Min(),
-					dp.Max(),
+					stats[1],
+					stats[2],
 					int32(model.expHistogram.AggregationTemporality()),
 				)
+				values = extra.bind(values, attrs, dp.Flags())
+				_, err = ExecRow(ctx, statement, values...)
 				if err != nil {
 					return fmt.Errorf("ExecContext:%w", err)
 				}
@@ -172,14 +182,17 @@ func (e *expHistogramMetrics) insert(ctx context.Context, db *sql.DB) error {
 		return err
 	})
 	duration := time.Since(start)
+	observe(err)
 	if err != nil {
 		logger.Debug("insert exponential histogram metrics fail", zap.Duration("cost", duration))
 		return fmt.Errorf("insert exponential histogram metrics fail:%w", err)
 	}
 
-	// TODO latency metrics
 	logger.Debug("insert exponential histogram metrics", zap.Int("records", e.count),
 		zap.Duration("cost", duration))
+	if err := e.exemplars.flush(ctx, db); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -35,16 +35,9 @@ CREATE TABLE IF NOT EXISTS %s %s (
 	TimeUnix DateTime64(9) CODEC(Delta, ZSTD(1)),
 	Value Float64 CODEC(ZSTD(1)),
 	Flags UInt32 CODEC(ZSTD(1)),
-	Exemplars Nested (
-		FilteredAttributes JSON,
-		TimeUnix DateTime64(9),
-		Value Float64,
-		SpanId String,
-		TraceId String
-	) CODEC(ZSTD(1)),
-) ENGINE = %s
+%s) ENGINE = %s
 %s
-PARTITION BY toDate(TimeUnix)
+PARTITION BY %s
 ORDER BY (ServiceName, MetricName, Attributes, toUnixTimestamp64Nano(TimeUnix))
 SETTINGS index_granularity=8192, ttl_only_drop_parts = 1;
 `
@@ -65,12 +58,7 @@ SETTINGS index_granularity=8192, ttl_only_drop_parts = 1;
     StartTimeUnix,
     TimeUnix,
     Value,
-    Flags,
-    Exemplars.FilteredAttributes,
-		Exemplars.TimeUnix,
-    Exemplars.Value,
-    Exemplars.SpanId,
-    Exemplars.TraceId) VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)`
+    Flags%s) VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?%s)`
 )
 
 type gaugeModel struct {
@@ -83,16 +71,24 @@ type gaugeModel struct {
 
 type gaugeMetrics struct {
 	gaugeModels []*gaugeModel
+	table       string
 	insertSQL   string
 	count       int
+	exemplars   *exemplarsWriter
+	nonFinite   NonFinitePolicy
+	staleness   StalenessPolicy
+	attributes  *AttributeFilter
+	extra       extraColumns
 }
 
 func (g *gaugeMetrics) insert(ctx context.Context, db *sql.DB) error {
 	if g.count == 0 {
 		return nil
 	}
+	ctx, observe := ObserveInsert(ctx, g.table)
 	start := time.Now()
-	err := doWithTx(ctx, db, func(tx *sql.Tx) error {
+	err := doWithTx(ctx, db, func(ctx context.Context, tx *sql.Tx) error {
+		g.exemplars.reset()
 		statement, err := tx.PrepareContext(ctx, g.insertSQL)
 		if err != nil {
 			return err
@@ -103,36 +99,40 @@ func (g *gaugeMetrics) insert(ctx context.Context, db *sql.DB) error {
 		}()
 
 		for _, model := range g.gaugeModels {
-			resAttr := AttributesToJSON(model.metadata.ResAttr)
-			scopeAttr := AttributesToJSON(model.metadata.ScopeInstr.Attributes())
+			resAttr := g.attributes.JSON(model.metadata.ResAttr)
+			scopeAttr := g.attributes.JSON(model.metadata.ScopeInstr.Attributes())
 			serviceName := GetServiceName(model.metadata.ResAttr)
+			extra := g.extra.withFingerprints(model.metadata, resAttr, scopeAttr)
 
 			for i := range model.gauge.DataPoints().Len() {
 				dp := model.gauge.DataPoints().At(i)
-				attrs, times, values, traceIDs, spanIDs := convertExemplars(dp.Exemplars())
-				_, err = statement.ExecContext(ctx,
-					resAttr,
+				value, ok := g.staleness.apply(dp.Flags(), g.nonFinite, getValue(dp.IntValue(), dp.DoubleValue(), dp.ValueType()))
+				if !ok {
+					logger.Debug("dropped stale or non-finite gauge datapoint", zap.String("metric", model.metricName))
+					continue
+				}
+				attrs := g.attributes.JSON(dp.Attributes())
+				values := []any{
+					extra.attributes(resAttr),
 					model.metadata.ResURL,
 					model.metadata.ScopeInstr.Name(),
 					model.metadata.ScopeInstr.Version(),
-					scopeAttr,
+					extra.attributes(scopeAttr),
 					model.metadata.ScopeInstr.DroppedAttributesCount(),
 					model.metadata.ScopeURL,
 					serviceName,
 					model.metricName,
 					model.metricDescription,
 					model.metricUnit,
-					AttributesToJSON(dp.Attributes()),
+					attrs,
 					dp.StartTimestamp().AsTime(),
 					dp.Timestamp().AsTime(),
-					getValue(dp.IntValue(), dp.DoubleValue(), dp.ValueType()),
+					value,
 					uint32(dp.Flags()),
-					attrs,
-					times,
-					values,
-					spanIDs,
-					traceIDs,
-				)
+				}
+				values = append(values, g.exemplars.bind(ctx, serviceName, model.metricName, attrs, dp.Timestamp().AsTime(), dp.Exemplars())...)
+				values = extra.bind(values, attrs, dp.Flags())
+				_, err = ExecRow(ctx, statement, values...)
 				if err != nil {
 					return fmt.Errorf("ExecContext:%w", err)
 				}
@@ -141,10 +141,14 @@ func (g *gaugeMetrics) insert(ctx context.Context, db *sql.DB) error {
 		return err
 	})
 	duration := time.Since(start)
+	observe(err)
 	if err != nil {
 		logger.Debug("insert gauge metrics fail", zap.Duration("cost", duration))
 		return fmt.Errorf("insert gauge metrics fail:%w", err)
 	}
+	if err := g.exemplars.flush(ctx, db); err != nil {
+		return err
+	}
 	return nil
 }
 
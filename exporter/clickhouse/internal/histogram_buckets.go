@@ -0,0 +1,55 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package internal // import "github.com/foyer-work/otel-distribution/exporter/clickhouse/internal"
+
+import (
+	"strconv"
+
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+// BucketBoundAttribute is the datapoint attribute holding the upper bound of the buckets returned by HistogramSums.
+const BucketBoundAttribute = "le"
+
+// HistogramSums converts histogram to the series of the Prometheus data model: the buckets sum has a datapoint
+// per bucket of each histogram datapoint with the BucketBoundAttribute set, e.g. "0.5" or "+Inf", and the
+// cumulative count of the bucket and the buckets below it, the sum and count sums hold the sum, if set, and count
+// of the histogram datapoints, to be written as <name>_bucket, <name>_sum and <name>_count.
+func HistogramSums(histogram pmetric.Histogram) (buckets, sum, count pmetric.Sum) {
+	buckets, sum, count = pmetric.NewSum(), pmetric.NewSum(), pmetric.NewSum()
+	for _, s := range []pmetric.Sum{buckets, sum, count} {
+		s.SetAggregationTemporality(histogram.AggregationTemporality())
+	}
+	buckets.SetIsMonotonic(true)
+	count.SetIsMonotonic(true)
+	for _, dp := range histogram.DataPoints().All() {
+		bounds := dp.ExplicitBounds()
+		var cumulative uint64
+		for i, bucketCount := range dp.BucketCounts().All() {
+			cumulative += bucketCount
+			bound := "+Inf"
+			if i < bounds.Len() {
+				bound = strconv.FormatFloat(bounds.At(i), 'f', -1, 64)
+			}
+			point := newHistogramSumPoint(buckets, dp)
+			point.SetIntValue(int64(cumulative))
+			point.Attributes().PutStr(BucketBoundAttribute, bound)
+		}
+		if dp.HasSum() {
+			newHistogramSumPoint(sum, dp).SetDoubleValue(dp.Sum())
+		}
+		newHistogramSumPoint(count, dp).SetIntValue(int64(dp.Count()))
+	}
+	return buckets, sum, count
+}
+
+// newHistogramSumPoint appends a datapoint to sum with the attributes, timestamps and flags of dp.
+func newHistogramSumPoint(sum pmetric.Sum, dp pmetric.HistogramDataPoint) pmetric.NumberDataPoint {
+	point := sum.DataPoints().AppendEmpty()
+	dp.Attributes().CopyTo(point.Attributes())
+	point.SetStartTimestamp(dp.StartTimestamp())
+	point.SetTimestamp(dp.Timestamp())
+	point.SetFlags(dp.Flags())
+	return point
+}
@@ -0,0 +1,42 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+func TestHistogramSums(t *testing.T) {
+	histogram := pmetric.NewHistogram()
+	histogram.SetAggregationTemporality(pmetric.AggregationTemporalityDelta)
+	dp := histogram.DataPoints().AppendEmpty()
+	dp.Attributes().PutStr("path", "/")
+	dp.SetCount(6)
+	dp.ExplicitBounds().FromRaw([]float64{0.1, 1})
+	dp.BucketCounts().FromRaw([]uint64{1, 3, 2})
+
+	buckets, sum, count := HistogramSums(histogram)
+	require.Equal(t, pmetric.AggregationTemporalityDelta, buckets.AggregationTemporality())
+	require.True(t, buckets.IsMonotonic())
+	require.Equal(t, 3, buckets.DataPoints().Len())
+	for i, want := range []struct {
+		bound string
+		count int64
+	}{{"0.1", 1}, {"1", 4}, {"+Inf", 6}} {
+		point := buckets.DataPoints().At(i)
+		require.Equal(t, map[string]any{"path": "/", BucketBoundAttribute: want.bound}, point.Attributes().AsRaw())
+		require.Equal(t, want.count, point.IntValue())
+	}
+	require.Zero(t, sum.DataPoints().Len(), "the sum is not set")
+	require.Equal(t, 1, count.DataPoints().Len())
+	require.Equal(t, int64(6), count.DataPoints().At(0).IntValue())
+
+	dp.SetSum(4.5)
+	_, sum, _ = HistogramSums(histogram)
+	require.Equal(t, 1, sum.DataPoints().Len())
+	require.Equal(t, 4.5, sum.DataPoints().At(0).DoubleValue())
+}
@@ -37,20 +37,13 @@ CREATE TABLE IF NOT EXISTS %s %s (
 		Sum Float64 CODEC(ZSTD(1)),
 		BucketCounts Array(UInt64) CODEC(ZSTD(1)),
 		ExplicitBounds Array(Float64) CODEC(ZSTD(1)),
-		Exemplars Nested (
-			FilteredAttributes JSON,
-			TimeUnix DateTime64(9),
-			Value Float64,
-			SpanId String,
-			TraceId String
-		) CODEC(ZSTD(1)),
-		Flags UInt32 CODEC(ZSTD(1)),
+%s		Flags UInt32 CODEC(ZSTD(1)),
 		Min Float64 CODEC(ZSTD(1)),
 		Max Float64 CODEC(ZSTD(1)),
 		AggregationTemporality Int32 CODEC(ZSTD(1)),
 ) ENGINE = %s
 %s
-PARTITION BY toDate(TimeUnix)
+PARTITION BY %s
 ORDER BY (ServiceName, MetricName, Attributes, toUnixTimestamp64Nano(TimeUnix))
 SETTINGS index_granularity=8192, ttl_only_drop_parts = 1;
 `
@@ -73,16 +66,11 @@ SETTINGS index_granularity=8192, ttl_only_drop_parts = 1;
 	Count,
 	Sum,
 	BucketCounts,
-	ExplicitBounds,
-  	Exemplars.FilteredAttributes,
-	Exemplars.TimeUnix,
-    Exemplars.Value,
-    Exemplars.SpanId,
-    Exemplars.TraceId,
+	ExplicitBounds%s,
 	Flags,
 	Min,
 	Max,
-	AggregationTemporality) VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)`
+	AggregationTemporality) VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?%s)`
 )
 
 type histogramModel struct {
@@ -95,16 +83,38 @@ type histogramModel struct {
 
 type histogramMetrics struct {
 	histogramModel []*histogramModel
+	table          string
 	insertSQL      string
 	count          int
+	exemplars      *exemplarsWriter
+	nonFinite      NonFinitePolicy
+	staleness      StalenessPolicy
+	attributes     *AttributeFilter
+	// nullableStats writes NULL for the sum, min and max not set on a datapoint.
+	nullableStats bool
+	extra         extraColumns
+}
+
+// histogramStatsColumns are the columns of the optional sum, min and max of histogram datapoints.
+var histogramStatsColumns = []string{"Sum", "Min", "Max"}
+
+// nullUnset replaces the sum, min and max in stats by NULL where not set on the datapoint.
+func nullUnset(stats []any, hasSum, hasMin, hasMax bool) {
+	for i, set := range []bool{hasSum, hasMin, hasMax} {
+		if !set {
+			stats[i] = nil
+		}
+	}
 }
 
 func (h *histogramMetrics) insert(ctx context.Context, db *sql.DB) error {
 	if h.count == 0 {
 		return nil
 	}
+	ctx, observe := ObserveInsert(ctx, h.table)
 	start := time.Now()
-	err := doWithTx(ctx, db, func(tx *sql.Tx) error {
+	err := doWithTx(ctx, db, func(ctx context.Context, tx *sql.Tx) error {
+		h.exemplars.reset()
 		statement, err := tx.PrepareContext(ctx, h.insertSQL)
 		if err != nil {
 			return err
@@ -115,42 +125,51 @@ func (h *histogramMetrics) insert(ctx context.Context, db *sql.DB) error {
 		}()
 
 		for _, model := range h.histogramModel {
-			resAttr := AttributesToJSON(model.metadata.ResAttr)
-			scopeAttr := AttributesToJSON(model.metadata.ScopeInstr.Attributes())
+			resAttr := h.attributes.JSON(model.metadata.ResAttr)
+			scopeAttr := h.attributes.JSON(model.metadata.ScopeInstr.Attributes())
 			serviceName := GetServiceName(model.metadata.ResAttr)
+			extra := h.extra.withFingerprints(model.metadata, resAttr, scopeAttr)
 
 			for i := range model.histogram.DataPoints().Len() {
 				dp := model.histogram.DataPoints().At(i)
-				attrs, times, values, traceIDs, spanIDs := convertExemplars(dp.Exemplars())
-				_, err = statement.ExecContext(ctx,
-					resAttr,
+				stats, ok := h.staleness.applyAll(dp.Flags(), h.nonFinite, dp.Sum(), dp.Min(), dp.Max())
+				if !ok {
+					logger.Debug("dropped stale histogram datapoint or with a non-finite sum, min or max", zap.String("metric", model.metricName))
+					continue
+				}
+				if h.nullableStats {
+					nullUnset(stats, dp.HasSum(), dp.HasMin(), dp.HasMax())
+				}
+				attrs := h.attributes.JSON(dp.Attributes())
+				values := []any{
+					extra.attributes(resAttr),
 					model.metadata.ResURL,
 					model.metadata.ScopeInstr.Name(),
 					model.metadata.ScopeInstr.Version(),
-					scopeAttr,
+					extra.attributes(scopeAttr),
 					model.metadata.ScopeInstr.DroppedAttributesCount(),
 					model.metadata.ScopeURL,
 					serviceName,
 					model.metricName,
 					model.metricDescription,
 					model.metricUnit,
-					AttributesToJSON(dp.Attributes()),
+					attrs,
 					dp.StartTimestamp().AsTime(),
 					dp.Timestamp().AsTime(),
 					dp.Count(),
-					dp.Sum(),
+					stats[0],
 					convertSliceToArraySet(dp.BucketCounts().AsRaw()),
 					convertSliceToArraySet(dp.ExplicitBounds().AsRaw()),
-					attrs,
-					times,
-					values,
-					spanIDs,
-					traceIDs,
+				}
+				values = append(values, h.exemplars.bind(ctx, serviceName, model.metricName, attrs, dp.Timestamp().AsTime(), dp.Exemplars())...)
+				values = append(values,
 					uint32(dp.Flags()),
-					dp.Min(),
-					dp.Max(),
+					stats[1],
+					stats[2],
 					int32(model.histogram.AggregationTemporality()),
 				)
+				values = extra.bind(values, attrs, dp.Flags())
+				_, err = ExecRow(ctx, statement, values...)
 				if err != nil {
 					return fmt.Errorf("ExecContext:%w", err)
 				}
@@ -159,14 +178,17 @@ func (h *histogramMetrics) insert(ctx context.Context, db *sql.DB) error {
 		return err
 	})
 	duration := time.Since(start)
+	observe(err)
 	if err != nil {
 		logger.Debug("insert histogram metrics fail", zap.Duration("cost", duration))
 		return fmt.Errorf("insert histogram metrics fail:%w", err)
 	}
 
-	// TODO latency metrics
 	logger.Debug("insert histogram metrics", zap.Int("records", h.count),
 		zap.Duration("cost", duration))
+	if err := h.exemplars.flush(ctx, db); err != nil {
+		return err
+	}
 	return nil
 }
 
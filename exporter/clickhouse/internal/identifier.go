@@ -0,0 +1,33 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package internal // import "github.com/foyer-work/otel-distribution/exporter/clickhouse/internal"
+
+import "strings"
+
+// QuoteIdentifier back-quotes a database, table or cluster name for use in SQL.
+func QuoteIdentifier(name string) string {
+	name = strings.ReplaceAll(name, `\`, `\\`)
+	return "`" + strings.ReplaceAll(name, "`", "\\`") + "`"
+}
+
+// QuoteString single-quotes s as a ClickHouse string literal.
+func QuoteString(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return "'" + strings.ReplaceAll(s, "'", `\'`) + "'"
+}
+
+// unescapeString reverses the escaping of QuoteString for the content of a string literal.
+func unescapeString(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
@@ -0,0 +1,57 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package internal // import "github.com/foyer-work/otel-distribution/exporter/clickhouse/internal"
+
+import (
+	"regexp"
+	"strings"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+)
+
+// IDEncoding is how trace and span ids are written.
+type IDEncoding string
+
+const (
+	// IDEncodingHex writes the ids as lowercase hex into String columns, empty ids as empty strings.
+	IDEncodingHex IDEncoding = "hex"
+	// IDEncodingBinary writes the ids as raw bytes into FixedString(16) trace id and FixedString(8) span id
+	// columns, empty ids as zero bytes, halving their size.
+	IDEncodingBinary IDEncoding = "binary"
+)
+
+// TraceID returns the value written for id.
+func (e IDEncoding) TraceID(id pcommon.TraceID) string {
+	if e == IDEncodingBinary {
+		return string(id[:])
+	}
+	return TraceIDToHexOrEmptyString(id)
+}
+
+// SpanID returns the value written for id.
+func (e IDEncoding) SpanID(id pcommon.SpanID) string {
+	if e == IDEncodingBinary {
+		return string(id[:])
+	}
+	return SpanIDToHexOrEmptyString(id)
+}
+
+// idColumnRegexp matches the String trace and span id columns, e.g. ParentSpanId or the TraceId of a Nested
+// column, capturing their name and kind.
+var idColumnRegexp = regexp.MustCompile(`\b(\w*(Trace|Span)Id) String\b`)
+
+// setBinaryIDs replaces the String type of the trace and span id columns of a column definition, or of the
+// fields of a Nested column, with the FixedString of their raw bytes.
+func setBinaryIDs(col *ColumnDef) {
+	def := idColumnRegexp.ReplaceAllStringFunc(col.Name+" "+col.Type, func(match string) string {
+		if strings.HasSuffix(idColumnRegexp.FindStringSubmatch(match)[1], "TraceId") {
+			return strings.TrimSuffix(match, "String") + "FixedString(16)"
+		}
+		return strings.TrimSuffix(match, "String") + "FixedString(8)"
+	})
+	if colType := strings.TrimPrefix(def, col.Name+" "); colType != col.Type {
+		col.Type = colType
+		col.Comment = strings.Replace(col.Comment, " as hex", " as raw bytes", 1)
+	}
+}
@@ -0,0 +1,156 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package internal // import "github.com/foyer-work/otel-distribution/exporter/clickhouse/internal"
+
+import (
+	"context"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type (
+	insertObserverKey struct{}
+	insertTracerKey   struct{}
+	insertStatsKey    struct{}
+)
+
+// InsertObserver is notified of the outcome of each insert into a table, with the number of rows
+// and the approximate uncompressed size in bytes of the values bound by ExecRow.
+type InsertObserver func(ctx context.Context, table string, rows, bytes int64, duration time.Duration, err error)
+
+// insertStats counts the rows bound by ExecRow for a single insert, whose rows are bound sequentially.
+type insertStats struct {
+	rows  int64
+	bytes int64
+	// timed records when the first and last rows were bound, delimiting the phases of the insert.
+	timed     bool
+	firstBind time.Time
+	lastBind  time.Time
+}
+
+func (s *insertStats) add(args []any) {
+	s.rows++
+	for _, arg := range args {
+		s.bytes += valueSize(arg)
+	}
+	if s.timed {
+		s.lastBind = time.Now()
+		if s.rows == 1 {
+			s.firstBind = s.lastBind
+		}
+	}
+}
+
+// reset forgets the rows bound by a failed attempt of the insert.
+func (s *insertStats) reset() {
+	s.rows, s.bytes = 0, 0
+}
+
+// WithInsertObserver returns a copy of ctx on which the inserts observed with ObserveInsert notify observer.
+func WithInsertObserver(ctx context.Context, observer InsertObserver) context.Context {
+	return context.WithValue(ctx, insertObserverKey{}, observer)
+}
+
+// InsertsObserved reports whether ctx carries an observer set with WithInsertObserver.
+func InsertsObserved(ctx context.Context) bool {
+	_, observed := ctx.Value(insertObserverKey{}).(InsertObserver)
+	return observed
+}
+
+// WithInsertTracer returns a copy of ctx on which the inserts observed with ObserveInsert are traced with tracer.
+func WithInsertTracer(ctx context.Context, tracer trace.Tracer) context.Context {
+	return context.WithValue(ctx, insertTracerKey{}, tracer)
+}
+
+// ObserveInsert starts an insert into table, returning the context to bind its rows on and
+// a function notifying the observer of ctx, if any, of its outcome.
+// If ctx carries a tracer the insert is traced by a span with a child span per phase:
+// prepare, until the first row is bound, bind, until the last row is bound, and commit, sending the batch.
+func ObserveInsert(ctx context.Context, table string) (context.Context, func(error)) {
+	observer, observed := ctx.Value(insertObserverKey{}).(InsertObserver)
+	tracer, traced := ctx.Value(insertTracerKey{}).(trace.Tracer)
+	if !observed && !traced {
+		return ctx, func(error) {}
+	}
+	stats := &insertStats{timed: traced}
+	start := time.Now()
+	var span trace.Span
+	if traced {
+		ctx, span = tracer.Start(ctx, "insert "+table,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithTimestamp(start),
+			trace.WithAttributes(
+				attribute.String("db.system", "clickhouse"),
+				attribute.String("db.collection.name", table),
+			))
+		// The spans recorded by clickhouse for the insert are children of its span.
+		if span.SpanContext().IsValid() {
+			ctx = clickhouse.Context(ctx, clickhouse.WithSpan(span.SpanContext()))
+		}
+	}
+	return context.WithValue(ctx, insertStatsKey{}, stats), func(err error) {
+		end := time.Now()
+		if observed {
+			observer(ctx, table, stats.rows, stats.bytes, end.Sub(start), err)
+		}
+		if !traced {
+			return
+		}
+		phase := func(name string, from, to time.Time) {
+			_, phaseSpan := tracer.Start(ctx, name, trace.WithTimestamp(from))
+			phaseSpan.End(trace.WithTimestamp(to))
+		}
+		if stats.rows == 0 {
+			phase("prepare", start, end)
+		} else {
+			phase("prepare", start, stats.firstBind)
+			phase("bind", stats.firstBind, stats.lastBind)
+			phase("commit", stats.lastBind, end)
+		}
+		span.SetAttributes(attribute.Int64("clickhouse.insert.rows", stats.rows), attribute.Int64("clickhouse.insert.bytes", stats.bytes))
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End(trace.WithTimestamp(end))
+	}
+}
+
+// valueSize returns the approximate uncompressed size of a bound value.
+func valueSize(value any) int64 {
+	switch v := value.(type) {
+	case string:
+		return int64(len(v))
+	case []byte:
+		return int64(len(v))
+	case bool, int8, uint8:
+		return 1
+	case int16, uint16:
+		return 2
+	case int32, uint32, float32:
+		return 4
+	case int, uint, int64, uint64, float64, time.Time:
+		return 8
+	case []string:
+		var size int64
+		for _, s := range v {
+			size += int64(len(s))
+		}
+		return size
+	case clickhouse.ArraySet:
+		return valueSize([]any(v))
+	case []any:
+		var size int64
+		for _, elem := range v {
+			size += valueSize(elem)
+		}
+		return size
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,34 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package internal
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestObserveInsert(t *testing.T) {
+	ctx, done := ObserveInsert(context.Background(), "otel_logs")
+	require.Nil(t, ctx.Value(insertStatsKey{}))
+	done(nil)
+
+	var observed []string
+	ctx = WithInsertObserver(context.Background(), func(_ context.Context, table string, rows, bytes int64, _ time.Duration, err error) {
+		require.Equal(t, int64(2), rows)
+		require.Equal(t, int64(2*(8+5+4+3)), bytes)
+		observed = append(observed, table)
+		require.EqualError(t, err, "insert failed")
+	})
+	ctx, done = ObserveInsert(ctx, "otel_logs")
+	for range 2 {
+		ctx.Value(insertStatsKey{}).(*insertStats).add([]any{time.Now(), "error", uint32(1), clickhouse.ArraySet{"a", "bc"}})
+	}
+	done(errors.New("insert failed"))
+	require.Equal(t, []string{"otel_logs"}, observed)
+}
@@ -0,0 +1,98 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package internal // import "github.com/foyer-work/otel-distribution/exporter/clickhouse/internal"
+
+import (
+	"context"
+	"fmt"
+	"maps"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// logCommentSetting is the setting whose value is recorded as log_comment in system.query_log.
+const logCommentSetting = "log_comment"
+
+// deduplicationTokenSetting is the setting identifying the rows of an insert, clickhouse skipping the inserts
+// of the same token within the deduplication window of the table.
+const deduplicationTokenSetting = "insert_deduplication_token"
+
+type insertSettingsKey struct{}
+
+type insertDeduplicationKey struct{}
+
+// WithInsertSettings returns a copy of ctx carrying the settings sent with the queries of InsertContext.
+func WithInsertSettings(ctx context.Context, settings map[string]string) context.Context {
+	if len(settings) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, insertSettingsKey{}, settings)
+}
+
+// WithInsertDeduplicationToken returns a copy of ctx whose insert settings additionally carry token as the
+// insert_deduplication_token, so that the retries of an insert of the same rows are skipped.
+func WithInsertDeduplicationToken(ctx context.Context, token string) context.Context {
+	values, _ := ctx.Value(insertSettingsKey{}).(map[string]string)
+	settings := maps.Clone(values)
+	if settings == nil {
+		settings = map[string]string{}
+	}
+	settings[deduplicationTokenSetting] = token
+	return context.WithValue(ctx, insertSettingsKey{}, settings)
+}
+
+// WithInsertDeduplication returns a copy of ctx whose inserts carry the insert_deduplication_token id followed by
+// their operation unless they set their own, so that inserting a batch again with the same id skips the inserts
+// already done, the operations of a batch inserting into the same table still being distinct.
+func WithInsertDeduplication(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, insertDeduplicationKey{}, id)
+}
+
+// InsertContext returns QueryContext(ctx, operation) additionally carrying the insert settings and
+// the trace context of ctx. The settings are sent with the query rather than as a SETTINGS clause,
+// which the driver strips from prepared INSERT statements.
+// The trace context is sent with the query, so that the spans recorded by clickhouse in
+// system.opentelemetry_span_log continue the trace of the export, and as the log_comment setting
+// unless set in the insert settings, so that the query in system.query_log can be joined to it.
+func InsertContext(ctx context.Context, operation string) context.Context {
+	ctx = QueryContext(ctx, operation)
+	if id, ok := ctx.Value(insertDeduplicationKey{}).(string); ok {
+		if values, _ := ctx.Value(insertSettingsKey{}).(map[string]string); values[deduplicationTokenSetting] == "" {
+			ctx = WithInsertDeduplicationToken(ctx, id+"-"+operation)
+		}
+	}
+	var options []clickhouse.QueryOption
+	if span := trace.SpanContextFromContext(ctx); span.IsValid() {
+		options = append(options, clickhouse.WithSpan(span))
+	}
+	if settings := insertSettings(ctx); len(settings) != 0 {
+		options = append(options, clickhouse.WithSettings(settings))
+	}
+	if len(options) == 0 {
+		return ctx
+	}
+	return clickhouse.Context(ctx, options...)
+}
+
+func insertSettings(ctx context.Context) clickhouse.Settings {
+	values, _ := ctx.Value(insertSettingsKey{}).(map[string]string)
+	span := trace.SpanContextFromContext(ctx)
+	if len(values) == 0 && !span.IsValid() {
+		return nil
+	}
+	settings := make(clickhouse.Settings, len(values)+1)
+	if span.IsValid() {
+		settings[logCommentSetting] = traceParent(span)
+	}
+	for k, v := range values {
+		settings[k] = v
+	}
+	return settings
+}
+
+// traceParent formats span as a W3C traceparent header value.
+func traceParent(span trace.SpanContext) string {
+	return fmt.Sprintf("00-%s-%s-%s", span.TraceID(), span.SpanID(), span.TraceFlags())
+}
@@ -0,0 +1,52 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package internal
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestInsertSettings(t *testing.T) {
+	ctx := context.Background()
+	require.Nil(t, insertSettings(ctx))
+	require.Equal(t, ctx, InsertContext(ctx, "insert_logs"))
+
+	ctx = WithInsertSettings(ctx, map[string]string{"insert_null_as_default": "1"})
+	require.Equal(t, clickhouse.Settings{"insert_null_as_default": "1"}, insertSettings(InsertContext(ctx, "insert_logs")))
+
+	ctx = trace.ContextWithSpanContext(ctx, trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36},
+		SpanID:     trace.SpanID{0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7},
+		TraceFlags: trace.FlagsSampled,
+	}))
+	require.Equal(t, clickhouse.Settings{
+		"insert_null_as_default": "1",
+		"log_comment":            "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+	}, insertSettings(ctx))
+
+	ctx = WithInsertSettings(ctx, map[string]string{"log_comment": "otelcol"})
+	require.Equal(t, clickhouse.Settings{"log_comment": "otelcol"}, insertSettings(ctx), "configured log_comment is kept")
+}
+
+func TestWithInsertDeduplicationToken(t *testing.T) {
+	settings := map[string]string{"insert_null_as_default": "1"}
+	ctx := WithInsertDeduplicationToken(WithInsertSettings(context.Background(), settings), "batch")
+	require.Equal(t, clickhouse.Settings{"insert_null_as_default": "1", "insert_deduplication_token": "batch"}, insertSettings(ctx))
+	require.Equal(t, map[string]string{"insert_null_as_default": "1"}, settings, "the configured settings are unchanged")
+	require.Equal(t, clickhouse.Settings{"insert_deduplication_token": "batch"}, insertSettings(WithInsertDeduplicationToken(context.Background(), "batch")))
+}
+
+func TestWithInsertDeduplication(t *testing.T) {
+	ctx := WithInsertDeduplication(context.Background(), "batch")
+	require.Equal(t, clickhouse.Settings{"insert_deduplication_token": "batch-insert_logs"}, insertSettings(InsertContext(ctx, "insert_logs")))
+	require.Equal(t, clickhouse.Settings{"insert_deduplication_token": "batch-insert_resources"}, insertSettings(InsertContext(ctx, "insert_resources")))
+
+	ctx = WithInsertDeduplicationToken(ctx, "spans")
+	require.Equal(t, clickhouse.Settings{"insert_deduplication_token": "spans"}, insertSettings(InsertContext(ctx, "insert_spans")), "the token of the insert is kept")
+}
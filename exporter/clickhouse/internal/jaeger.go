@@ -0,0 +1,249 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package internal // import "github.com/foyer-work/otel-distribution/exporter/clickhouse/internal"
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// jaegerNoServiceName is the service name of the spans whose resource has no service.name, as set by the
+// OTLP to Jaeger translation of the collector.
+const jaegerNoServiceName = "OTLPResourceNoServiceName"
+
+// Value types and reference types of the Jaeger model.
+const (
+	jaegerString  = 0
+	jaegerBool    = 1
+	jaegerInt64   = 2
+	jaegerFloat64 = 3
+	jaegerBinary  = 4
+
+	jaegerChildOf     = 0
+	jaegerFollowsFrom = 1
+)
+
+// JaegerSpan is a span of the Jaeger model, serialized as the json encoding of the jaeger-clickhouse plugin,
+// the encoding/json encoding of the Go type of the Jaeger model.
+type JaegerSpan struct {
+	TraceID       []byte            `json:"trace_id"`
+	SpanID        []byte            `json:"span_id"`
+	OperationName string            `json:"operation_name,omitempty"`
+	References    []JaegerReference `json:"references,omitempty"`
+	Flags         uint32            `json:"flags,omitempty"`
+	StartTime     time.Time         `json:"start_time"`
+	Duration      time.Duration     `json:"duration"`
+	Tags          []JaegerKeyValue  `json:"tags,omitempty"`
+	Logs          []JaegerLog       `json:"logs,omitempty"`
+	Process       JaegerProcess     `json:"process"`
+}
+
+// JaegerReference is a parent or link of a span of the Jaeger model.
+type JaegerReference struct {
+	TraceID []byte `json:"trace_id"`
+	SpanID  []byte `json:"span_id"`
+	RefType int    `json:"ref_type"`
+}
+
+// JaegerLog is an event of a span of the Jaeger model.
+type JaegerLog struct {
+	Timestamp time.Time        `json:"timestamp"`
+	Fields    []JaegerKeyValue `json:"fields,omitempty"`
+}
+
+// JaegerProcess is the resource of a span of the Jaeger model.
+type JaegerProcess struct {
+	ServiceName string           `json:"service_name"`
+	Tags        []JaegerKeyValue `json:"tags,omitempty"`
+}
+
+// JaegerKeyValue is a tag of the Jaeger model.
+type JaegerKeyValue struct {
+	Key      string  `json:"key"`
+	VType    int     `json:"v_type,omitempty"`
+	VStr     string  `json:"v_str,omitempty"`
+	VBool    bool    `json:"v_bool,omitempty"`
+	VInt64   int64   `json:"v_int64,omitempty"`
+	VFloat64 float64 `json:"v_float64,omitempty"`
+	VBinary  []byte  `json:"v_binary,omitempty"`
+}
+
+// String returns the value of the tag as the jaeger-clickhouse plugin writes it to the tags of its index table.
+func (kv JaegerKeyValue) String() string {
+	switch kv.VType {
+	case jaegerBool:
+		return strconv.FormatBool(kv.VBool)
+	case jaegerInt64:
+		return strconv.FormatInt(kv.VInt64, 10)
+	case jaegerFloat64:
+		return strconv.FormatFloat(kv.VFloat64, 'g', 10, 64)
+	case jaegerBinary:
+		return hex.EncodeToString(kv.VBinary)
+	default:
+		return kv.VStr
+	}
+}
+
+// NewJaegerSpan converts span, of resource and scope, to the Jaeger model as the OTLP to Jaeger translation
+// of the collector: the scope, kind, status and trace state become tags, the parent a child-of reference and
+// the links follows-from references, and the events logs with their name in the `event` field. The attributes
+// removed by filter aren't converted.
+func NewJaegerSpan(resource pcommon.Resource, scope pcommon.InstrumentationScope, span ptrace.Span, filter *AttributeFilter) JaegerSpan {
+	traceID := span.TraceID()
+	spanID := span.SpanID()
+	s := JaegerSpan{
+		TraceID:       traceID[:],
+		SpanID:        spanID[:],
+		OperationName: span.Name(),
+		Flags:         span.Flags() & 0xff,
+		StartTime:     span.StartTimestamp().AsTime(),
+		Duration:      span.EndTimestamp().AsTime().Sub(span.StartTimestamp().AsTime()),
+		Tags:          jaegerTags(span.Attributes(), filter),
+		Process:       JaegerProcess{ServiceName: jaegerNoServiceName},
+	}
+	if parent := span.ParentSpanID(); !parent.IsEmpty() {
+		s.References = append(s.References, JaegerReference{TraceID: traceID[:], SpanID: parent[:], RefType: jaegerChildOf})
+	}
+	for i := range span.Links().Len() {
+		link := span.Links().At(i)
+		linkTraceID, linkSpanID := link.TraceID(), link.SpanID()
+		s.References = append(s.References, JaegerReference{TraceID: linkTraceID[:], SpanID: linkSpanID[:], RefType: jaegerFollowsFrom})
+	}
+	for i := range span.Events().Len() {
+		event := span.Events().At(i)
+		fields := append([]JaegerKeyValue{{Key: "event", VStr: event.Name()}}, jaegerTags(event.Attributes(), filter)...)
+		s.Logs = append(s.Logs, JaegerLog{Timestamp: event.Timestamp().AsTime(), Fields: fields})
+	}
+
+	if name := scope.Name(); name != "" {
+		s.Tags = append(s.Tags, JaegerKeyValue{Key: "otel.scope.name", VStr: name})
+	}
+	if version := scope.Version(); version != "" {
+		s.Tags = append(s.Tags, JaegerKeyValue{Key: "otel.scope.version", VStr: version})
+	}
+	if kind := span.Kind(); kind != ptrace.SpanKindUnspecified {
+		s.Tags = append(s.Tags, JaegerKeyValue{Key: "span.kind", VStr: strings.ToLower(kind.String())})
+	}
+	switch status := span.Status(); status.Code() {
+	case ptrace.StatusCodeOk:
+		s.Tags = append(s.Tags, JaegerKeyValue{Key: "otel.status_code", VStr: "OK"})
+	case ptrace.StatusCodeError:
+		s.Tags = append(s.Tags,
+			JaegerKeyValue{Key: "otel.status_code", VStr: "ERROR"},
+			JaegerKeyValue{Key: "error", VType: jaegerBool, VBool: true})
+		if message := status.Message(); message != "" {
+			s.Tags = append(s.Tags, JaegerKeyValue{Key: "otel.status_description", VStr: message})
+		}
+	}
+	if traceState := span.TraceState().AsRaw(); traceState != "" {
+		s.Tags = append(s.Tags, JaegerKeyValue{Key: "w3c.tracestate", VStr: traceState})
+	}
+
+	for key, value := range resource.Attributes().All() {
+		if key == "service.name" {
+			s.Process.ServiceName = value.AsString()
+			continue
+		}
+		s.Process.Tags = appendJaegerTags(s.Process.Tags, key, value, filter)
+	}
+	return s
+}
+
+func jaegerTags(attributes pcommon.Map, filter *AttributeFilter) []JaegerKeyValue {
+	var tags []JaegerKeyValue
+	for key, value := range attributes.All() {
+		tags = appendJaegerTags(tags, key, value, filter)
+	}
+	return tags
+}
+
+// appendJaegerTags appends the tag of the attribute key to tags unless filter removes it, or a tag per entry if
+// it is a map flattened by filter.
+func appendJaegerTags(tags []JaegerKeyValue, key string, value pcommon.Value, filter *AttributeFilter) []JaegerKeyValue {
+	if !filter.Keep(key) {
+		return tags
+	}
+	if filter.flattens(value) {
+		for k, entry := range value.Map().All() {
+			tags = appendJaegerTags(tags, key+"."+k, entry, filter)
+		}
+		return tags
+	}
+	return append(tags, jaegerTag(key, value, filter))
+}
+
+// jaegerTag converts an attribute to a tag, the maps and slices Jaeger has no type for becoming their JSON string,
+// its value truncated by filter.
+func jaegerTag(key string, value pcommon.Value, filter *AttributeFilter) JaegerKeyValue {
+	switch value.Type() {
+	case pcommon.ValueTypeBool:
+		return JaegerKeyValue{Key: key, VType: jaegerBool, VBool: value.Bool()}
+	case pcommon.ValueTypeInt:
+		return JaegerKeyValue{Key: key, VType: jaegerInt64, VInt64: value.Int()}
+	case pcommon.ValueTypeDouble:
+		return JaegerKeyValue{Key: key, VType: jaegerFloat64, VFloat64: value.Double()}
+	case pcommon.ValueTypeBytes:
+		return JaegerKeyValue{Key: key, VType: jaegerBinary, VBinary: filter.Truncate(value.Bytes().AsRaw()).([]byte)}
+	case pcommon.ValueTypeStr:
+		return JaegerKeyValue{Key: key, VStr: filter.Truncate(value.Str()).(string)}
+	default:
+		return JaegerKeyValue{Key: key, VStr: value.AsString()}
+	}
+}
+
+// TraceIDString returns the trace id as the Jaeger model renders it, the hex of its high and low 64 bits
+// without the leading zeros.
+func (s JaegerSpan) TraceIDString() string {
+	var high, low uint64
+	for i, b := range s.TraceID {
+		if i < 8 {
+			high = high<<8 | uint64(b)
+		} else {
+			low = low<<8 | uint64(b)
+		}
+	}
+	if high == 0 {
+		return fmt.Sprintf("%x", low)
+	}
+	return fmt.Sprintf("%x%016x", high, low)
+}
+
+// IndexTags returns the keys and values of the distinct tags of the span, its process and its logs, sorted,
+// as the jaeger-clickhouse plugin writes them to its index table.
+func (s JaegerSpan) IndexTags() (keys, values []string) {
+	tags := slices.Concat(s.Tags, s.Process.Tags)
+	for _, log := range s.Logs {
+		tags = append(tags, log.Fields...)
+	}
+	type tag struct{ key, value string }
+	pairs := make([]tag, 0, len(tags))
+	for _, kv := range tags {
+		pairs = append(pairs, tag{kv.Key, kv.String()})
+	}
+	slices.SortFunc(pairs, func(a, b tag) int {
+		if c := strings.Compare(a.key, b.key); c != 0 {
+			return c
+		}
+		return strings.Compare(a.value, b.value)
+	})
+	pairs = slices.Compact(pairs)
+	keys, values = make([]string, len(pairs)), make([]string, len(pairs))
+	for i, pair := range pairs {
+		keys[i], values[i] = pair.key, pair.value
+	}
+	return keys, values
+}
+
+// Marshal returns the json encoding of the span.
+func (s JaegerSpan) Marshal() ([]byte, error) {
+	return json.Marshal(s)
+}
@@ -0,0 +1,77 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+func TestNewJaegerSpan(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	resource := pcommon.NewResource()
+	resource.Attributes().PutStr("service.name", "checkout")
+	resource.Attributes().PutStr("host.name", "node-1")
+	scope := pcommon.NewInstrumentationScope()
+	scope.SetName("io.opentelemetry.http")
+	span := ptrace.NewSpan()
+	span.SetTraceID([16]byte{15: 0x2a})
+	span.SetSpanID([8]byte{7: 1})
+	span.SetParentSpanID([8]byte{7: 2})
+	span.SetName("GET /cart")
+	span.SetKind(ptrace.SpanKindServer)
+	span.SetFlags(0x101)
+	span.SetStartTimestamp(pcommon.NewTimestampFromTime(start))
+	span.SetEndTimestamp(pcommon.NewTimestampFromTime(start.Add(1500 * time.Microsecond)))
+	span.Attributes().PutInt("http.status_code", 500)
+	span.Status().SetCode(ptrace.StatusCodeError)
+	event := span.Events().AppendEmpty()
+	event.SetName("exception")
+	event.SetTimestamp(pcommon.NewTimestampFromTime(start))
+	event.Attributes().PutBool("handled", false)
+
+	s := NewJaegerSpan(resource, scope, span, nil)
+	require.Equal(t, "2a", s.TraceIDString())
+	require.Equal(t, uint32(1), s.Flags)
+	require.Equal(t, 1500*time.Microsecond, s.Duration)
+	require.Equal(t, []JaegerReference{{TraceID: s.TraceID, SpanID: []byte{7: 2}, RefType: jaegerChildOf}}, s.References)
+	require.Equal(t, JaegerProcess{ServiceName: "checkout", Tags: []JaegerKeyValue{{Key: "host.name", VStr: "node-1"}}}, s.Process)
+
+	keys, values := s.IndexTags()
+	require.Equal(t, []string{"error", "event", "handled", "host.name", "http.status_code", "otel.scope.name", "otel.status_code", "span.kind"}, keys)
+	require.Equal(t, []string{"true", "exception", "false", "node-1", "500", "io.opentelemetry.http", "ERROR", "server"}, values)
+
+	encoded, err := s.Marshal()
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+		"trace_id": "AAAAAAAAAAAAAAAAAAAAKg==",
+		"span_id": "AAAAAAAAAAE=",
+		"operation_name": "GET /cart",
+		"references": [{"trace_id": "AAAAAAAAAAAAAAAAAAAAKg==", "span_id": "AAAAAAAAAAI=", "ref_type": 0}],
+		"flags": 1,
+		"start_time": "2024-05-01T12:00:00Z",
+		"duration": 1500000,
+		"tags": [
+			{"key": "http.status_code", "v_type": 2, "v_int64": 500},
+			{"key": "otel.scope.name", "v_str": "io.opentelemetry.http"},
+			{"key": "span.kind", "v_str": "server"},
+			{"key": "otel.status_code", "v_str": "ERROR"},
+			{"key": "error", "v_type": 1, "v_bool": true}
+		],
+		"logs": [{"timestamp": "2024-05-01T12:00:00Z", "fields": [
+			{"key": "event", "v_str": "exception"},
+			{"key": "handled", "v_type": 1}
+		]}],
+		"process": {"service_name": "checkout", "tags": [{"key": "host.name", "v_str": "node-1"}]}
+	}`, string(encoded))
+
+	high := NewJaegerSpan(resource, scope, ptrace.NewSpan(), nil)
+	high.TraceID = []byte{7: 1, 15: 2}
+	require.Equal(t, "10000000000000002", high.TraceIDString())
+	require.Equal(t, JaegerProcess{ServiceName: jaegerNoServiceName}, NewJaegerSpan(pcommon.NewResource(), scope, span, nil).Process)
+}
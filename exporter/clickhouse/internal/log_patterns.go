@@ -0,0 +1,107 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package internal // import "github.com/foyer-work/otel-distribution/exporter/clickhouse/internal"
+
+import (
+	"hash/fnv"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// PatternWildcard replaces the variable tokens of the patterns mined by PatternMiner.
+const PatternWildcard = "<*>"
+
+// patternPrefixTokens is the number of leading tokens grouping the patterns of a token count, as the depth
+// of the Drain parse tree. Leading tokens are seldom variables without digits.
+const patternPrefixTokens = 1
+
+// PatternMiner mines the patterns of log bodies with the Drain algorithm: bodies are split into whitespace
+// separated tokens, tokens holding digits are variables, and a body joins the pattern with the same token count
+// and leading tokens sharing most of its tokens, the tokens differing from it becoming variables.
+// Patterns only generalize, so the pattern of a body may be more specific than the one it ends up in.
+type PatternMiner struct {
+	similarity  float64
+	maxPatterns int
+
+	mu       sync.Mutex
+	groups   map[patternGroup][]*logPattern
+	patterns int
+}
+
+// patternGroup is the token count and leading tokens shared by the bodies of a group of patterns.
+type patternGroup struct {
+	tokens int
+	prefix [patternPrefixTokens]string
+}
+
+type logPattern struct {
+	tokens []string
+}
+
+// NewPatternMiner returns a miner joining bodies to the patterns sharing at least similarity of their tokens,
+// keeping at most maxPatterns patterns. Once full, bodies not joining a pattern get their own pattern, not kept.
+func NewPatternMiner(similarity float64, maxPatterns int) *PatternMiner {
+	return &PatternMiner{similarity: similarity, maxPatterns: maxPatterns, groups: map[patternGroup][]*logPattern{}}
+}
+
+// Pattern returns the pattern of body and its id, the 64-bit FNV-1a hash of the pattern.
+func (m *PatternMiner) Pattern(body string) (uint64, string) {
+	tokens := strings.Fields(body)
+	for i, token := range tokens {
+		if strings.ContainsFunc(token, unicode.IsDigit) {
+			tokens[i] = PatternWildcard
+		}
+	}
+	group := patternGroup{tokens: len(tokens)}
+	for i := 0; i < len(tokens) && i < patternPrefixTokens; i++ {
+		group.prefix[i] = tokens[i]
+	}
+
+	m.mu.Lock()
+	pattern := m.match(group, tokens)
+	m.mu.Unlock()
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(pattern))
+	return h.Sum64(), pattern
+}
+
+// match returns the pattern of tokens, generalizing the most similar pattern of group or adding a new one.
+func (m *PatternMiner) match(group patternGroup, tokens []string) string {
+	var best *logPattern
+	bestSimilarity := -1.0
+	for _, pattern := range m.groups[group] {
+		if s := pattern.similarity(tokens); s > bestSimilarity {
+			best, bestSimilarity = pattern, s
+		}
+	}
+	if best != nil && bestSimilarity >= m.similarity {
+		for i, token := range tokens {
+			if best.tokens[i] != token {
+				best.tokens[i] = PatternWildcard
+			}
+		}
+		return strings.Join(best.tokens, " ")
+	}
+	if m.patterns < m.maxPatterns {
+		m.groups[group] = append(m.groups[group], &logPattern{tokens: tokens})
+		m.patterns++
+	}
+	return strings.Join(tokens, " ")
+}
+
+// similarity returns the share of the tokens equal to those of the pattern, the variables of the pattern
+// not counting as equal. Bodies without tokens are all similar.
+func (p *logPattern) similarity(tokens []string) float64 {
+	if len(tokens) == 0 {
+		return 1
+	}
+	var equal int
+	for i, token := range tokens {
+		if p.tokens[i] == token && token != PatternWildcard {
+			equal++
+		}
+	}
+	return float64(equal) / float64(len(tokens))
+}
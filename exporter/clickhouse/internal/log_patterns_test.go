@@ -0,0 +1,34 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPatternMiner(t *testing.T) {
+	miner := NewPatternMiner(0.6, 3)
+	mine := func(body string) string {
+		_, pattern := miner.Pattern(body)
+		return pattern
+	}
+
+	require.Equal(t, "request <*> took <*> ms", mine("request 42 took 12 ms"))
+	require.Equal(t, "user alice logged in", mine("user alice logged in"))
+	require.Equal(t, "user <*> logged in", mine("user bob logged in"))
+	require.Equal(t, "user <*> logged in", mine("user carol  logged\tin"))
+	require.Equal(t, "user alice logged out", mine("user alice logged out"), "below the similarity")
+	require.Equal(t, "user <*> logged out", mine("user bob logged out"))
+	require.Equal(t, "cache miss for key", mine("cache miss for key"), "bodies not joining a pattern once full are not kept")
+	require.Equal(t, "cache miss for <*>", mine("cache miss for <*>"))
+	require.Empty(t, mine(""))
+
+	id, pattern := miner.Pattern("request 7 took 3 ms")
+	require.Equal(t, "request <*> took <*> ms", pattern)
+	other, _ := miner.Pattern("request 8 took 4 ms")
+	require.Equal(t, id, other)
+	require.Equal(t, AttributesHash(pattern), id, "the id is the FNV-1a hash of the pattern")
+}
@@ -0,0 +1,188 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package internal // import "github.com/foyer-work/otel-distribution/exporter/clickhouse/internal"
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+// MetadataRefreshInterval is how often the row of a metric is written again to the metadata table, updating its LastSeen.
+const MetadataRefreshInterval = time.Hour
+
+const (
+	// language=ClickHouse SQL
+	createMetricsMetadataTableSQL = `
+CREATE TABLE IF NOT EXISTS %s %s (
+	MetricName String CODEC(ZSTD(1)),
+	MetricType LowCardinality(String) CODEC(ZSTD(1)),
+	MetricUnit String CODEC(ZSTD(1)),
+	MetricDescription String CODEC(ZSTD(1)),
+	AggregationTemporality Int32 CODEC(ZSTD(1)),
+	IsMonotonic Boolean CODEC(Delta, ZSTD(1)),
+	LastSeen DateTime CODEC(Delta, ZSTD(1))
+) ENGINE = %s
+ORDER BY (MetricName, MetricType, MetricUnit, MetricDescription, AggregationTemporality, IsMonotonic)
+%s
+SETTINGS index_granularity=8192;
+`
+	// language=ClickHouse SQL
+	insertMetricsMetadataTableSQL = `INSERT INTO %s (
+    MetricName,
+    MetricType,
+    MetricUnit,
+    MetricDescription,
+    AggregationTemporality,
+    IsMonotonic,
+    LastSeen) VALUES (?,?,?,?,?,?,?)`
+)
+
+// metricsMetadataColumnComments describes the columns of the metrics metadata table.
+var metricsMetadataColumnComments = map[string]string{
+	"MetricName":             "Metric.name",
+	"MetricType":             "Type of the metric",
+	"MetricUnit":             "Metric.unit",
+	"MetricDescription":      "Metric.description",
+	"AggregationTemporality": "Sum, Histogram or ExponentialHistogram aggregation_temporality, 0 for other types",
+	"IsMonotonic":            "Sum.is_monotonic, false for other types",
+	"LastSeen":               "Last time the metric was written, refreshed hourly",
+}
+
+// metricMetadata is a row of the metrics metadata table.
+type metricMetadata struct {
+	name        string
+	metricType  pmetric.MetricType
+	unit        string
+	description string
+	temporality pmetric.AggregationTemporality
+	monotonic   bool
+}
+
+// MetricsMetadata maintains a table with a row per distinct metric name, type, unit, description,
+// temporality and monotonicity, so that the metrics can be listed without scanning the datapoint tables.
+// Each row is written once per MetadataRefreshInterval, the table engine replacing the older rows.
+type MetricsMetadata struct {
+	table     string
+	insertSQL string
+	// cumulative is set if delta sums are written as cumulative.
+	cumulative bool
+
+	mu      sync.Mutex
+	written map[metricMetadata]time.Time
+}
+
+// NewMetricsMetadata returns the writer of the metadata table, which describes delta sums as cumulative if cumulative is set.
+func NewMetricsMetadata(table string, cumulative bool) *MetricsMetadata {
+	return &MetricsMetadata{
+		table:      table,
+		insertSQL:  fmt.Sprintf(insertMetricsMetadataTableSQL, QuoteIdentifier(table)),
+		cumulative: cumulative,
+		written:    map[metricMetadata]time.Time{},
+	}
+}
+
+// CreateTable creates the metadata table with engine.
+func (m *MetricsMetadata) CreateTable(ctx context.Context, settings MetricsSettings, cluster, engine, ttlExpr string, db *sql.DB) error {
+	query := fmt.Sprintf(createMetricsMetadataTableSQL, QuoteIdentifier(m.table), cluster, engine, ttlExpr)
+	query = settings.tableDDL(m.table, query, metricsMetadataColumnComments)
+	if _, err := db.ExecContext(QueryContext(ctx, "create_table"), query); err != nil {
+		return fmt.Errorf("exec create metrics metadata table sql: %w", err)
+	}
+	return nil
+}
+
+// Write writes the rows of the metrics of md not written within MetadataRefreshInterval.
+func (m *MetricsMetadata) Write(ctx context.Context, db *sql.DB, md pmetric.Metrics) error {
+	if m == nil {
+		return nil
+	}
+	now := time.Now()
+	rows := m.due(md, now)
+	if len(rows) == 0 {
+		return nil
+	}
+	ctx, observe := ObserveInsert(InsertContext(ctx, "insert_metrics_metadata"), m.table)
+	err := doWithTx(ctx, db, func(ctx context.Context, tx *sql.Tx) error {
+		statement, err := tx.PrepareContext(ctx, m.insertSQL)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = statement.Close()
+		}()
+		for _, row := range rows {
+			if _, err := ExecRow(ctx, statement,
+				row.name,
+				row.metricType.String(),
+				row.unit,
+				row.description,
+				int32(row.temporality),
+				row.monotonic,
+				now,
+			); err != nil {
+				return fmt.Errorf("ExecContext:%w", err)
+			}
+		}
+		return nil
+	})
+	observe(err)
+	if err != nil {
+		return fmt.Errorf("insert metrics metadata fail:%w", err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, row := range rows {
+		m.written[row] = now
+	}
+	return nil
+}
+
+// due returns the distinct rows of the metrics of md not written within MetadataRefreshInterval.
+func (m *MetricsMetadata) due(md pmetric.Metrics, now time.Time) []metricMetadata {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	seen := map[metricMetadata]bool{}
+	var rows []metricMetadata
+	for _, rm := range md.ResourceMetrics().All() {
+		for _, sm := range rm.ScopeMetrics().All() {
+			for _, metric := range sm.Metrics().All() {
+				row := m.metadataOf(metric)
+				if seen[row] || now.Sub(m.written[row]) < MetadataRefreshInterval {
+					continue
+				}
+				seen[row] = true
+				rows = append(rows, row)
+			}
+		}
+	}
+	return rows
+}
+
+func (m *MetricsMetadata) metadataOf(metric pmetric.Metric) metricMetadata {
+	row := metricMetadata{
+		name:        metric.Name(),
+		metricType:  metric.Type(),
+		unit:        metric.Unit(),
+		description: metric.Description(),
+	}
+	//exhaustive:enforce
+	switch metric.Type() {
+	case pmetric.MetricTypeSum:
+		row.temporality, row.monotonic = metric.Sum().AggregationTemporality(), metric.Sum().IsMonotonic()
+		if m.cumulative && row.temporality == pmetric.AggregationTemporalityDelta {
+			row.temporality = pmetric.AggregationTemporalityCumulative
+		}
+	case pmetric.MetricTypeHistogram:
+		row.temporality = metric.Histogram().AggregationTemporality()
+	case pmetric.MetricTypeExponentialHistogram:
+		row.temporality = metric.ExponentialHistogram().AggregationTemporality()
+	case pmetric.MetricTypeGauge, pmetric.MetricTypeSummary, pmetric.MetricTypeEmpty:
+	}
+	return row
+}
@@ -6,9 +6,9 @@ package internal // import "github.com/foyer-work/otel-distribution/exporter/cli
 import (
 	"context"
 	"database/sql"
-	"encoding/json"
 	"errors"
 	"fmt"
+	"slices"
 	"strings"
 	"sync"
 
@@ -31,10 +31,202 @@ var logger *zap.Logger
 
 type MetricTablesConfigMapper map[pmetric.MetricType]MetricTypeConfig
 
+// Tables returns the distinct table names of the mapper, sorted.
+func (m MetricTablesConfigMapper) Tables() []string {
+	tables := make([]string, 0, len(m))
+	for _, table := range m {
+		tables = append(tables, table.Name)
+	}
+	slices.Sort(tables)
+	return slices.Compact(tables)
+}
+
 type MetricTypeConfig struct {
 	Name string `mapstructure:"name"`
 }
 
+// MetricsSettings holds schema options shared by all metric tables.
+type MetricsSettings struct {
+	// ExemplarsMode controls how exemplars are stored, defaults to ExemplarsModeInline.
+	ExemplarsMode ExemplarsMode
+	// ExemplarsTableName is the table used by ExemplarsModeSeparateTable.
+	ExemplarsTableName string
+	// ExemplarsTTLExpr is the TTL clause of the exemplars table.
+	ExemplarsTTLExpr string
+	// ExemplarsMaxPerDataPoint is the number of exemplars kept per datapoint, the latest ones, 0 for all.
+	ExemplarsMaxPerDataPoint int
+	// PartitionBy is the partition granularity of metric tables.
+	PartitionBy PartitionGranularity
+	// Columns holds the user overrides applied to the column definitions of metric tables.
+	Columns ColumnOptions
+	// NoIndexes removes the data skipping indexes from created tables.
+	NoIndexes bool
+	// DeltaToCumulative converts delta sums to cumulative if set.
+	DeltaToCumulative *DeltaToCumulative
+	// NonFinite is how NaN and infinite values are written, defaults to NonFiniteKeep.
+	NonFinite NonFinitePolicy
+	// ExpHistogramMaxBuckets is the number of positive and negative buckets above which exponential histogram
+	// datapoints are downscaled, 0 for no limit.
+	ExpHistogramMaxBuckets int
+	// Staleness is how the datapoints with the NoRecordedValue flag are written, defaults to StaleKeep.
+	Staleness StalenessPolicy
+	// NullableHistogramStats creates the Sum, Min and Max columns of the histogram tables Nullable,
+	// writing NULL instead of 0 when the datapoint has no sum, min or max.
+	NullableHistogramStats bool
+	// AttrHash adds the AttrHash column holding the AttributesHash of the datapoint attributes.
+	AttrHash bool
+	// AttrHashOrderBy adds the AttrHash column to the sorting key of created tables, before Attributes.
+	AttrHashOrderBy bool
+	// UnifiedTable is the table all datapoints are written to, with a MetricType column, instead of a table per type.
+	// The tables of the MetricTablesConfigMapper are expected to be UnifiedTable as well.
+	UnifiedTable string
+	// TenantColumn adds a tenant column of this name to the created tables, holding Tenant.
+	TenantColumn string
+	// TenantPartition prefixes the partition key of the metric tables with TenantColumn.
+	TenantPartition bool
+	// Tenant is the tenant of the client that sent the datapoints.
+	Tenant string
+	// Dimensions adds the ResourceFingerprint and ScopeFingerprint columns and writes the ResourceAttributes and
+	// ScopeAttributes columns empty, the resources and scopes being written into the dimension tables.
+	Dimensions bool
+	// Attributes filters the keys of the resource, scope, datapoint and exemplar attributes written.
+	Attributes *AttributeFilter
+	// IDEncoding is how the exemplar trace and span ids are written, defaults to IDEncodingHex.
+	IDEncoding IDEncoding
+}
+
+// tableDDL applies the settings shared by all metric tables to the CREATE TABLE statement ddl of table.
+func (s MetricsSettings) tableDDL(table, ddl string, comments map[string]string) string {
+	ddl = s.Columns.Apply(table, CommentColumns(ddl, comments))
+	if s.NoIndexes {
+		ddl = RemoveIndexes(ddl)
+	}
+	return ddl
+}
+
+// metricPartitionExpr returns the PARTITION BY expression of the metric tables.
+func (s MetricsSettings) metricPartitionExpr() string {
+	if s.TenantPartition && s.TenantColumn != "" {
+		return TenantPartitionExpr(s.PartitionBy, s.TenantColumn, "TimeUnix")
+	}
+	return PartitionExpr(s.PartitionBy, "TimeUnix")
+}
+
+// metricTableDDL applies the settings to the CREATE TABLE statement ddl of a metric table, whose value
+// columns are made Nullable by NonFiniteNull if values is set, by NullableHistogramStats if histogramStats is set,
+// and by StaleNull.
+func (s MetricsSettings) metricTableDDL(table, ddl string, values, histogramStats bool) string {
+	if s.TenantColumn != "" {
+		ddl = AddTenantColumn(ddl, s.TenantColumn)
+	}
+	if s.AttrHash {
+		ddl = addAttrHashColumn(ddl, s.AttrHashOrderBy)
+	}
+	if s.Dimensions {
+		ddl = AddFingerprintColumns(ddl)
+	}
+	ddl = s.tableDDL(table, ddl, metricsColumnComments)
+	if values {
+		ddl = s.NonFinite.tableDDL(ddl)
+	}
+	if s.NullableHistogramStats && histogramStats {
+		ddl = nullableColumns(ddl, histogramStatsColumns...)
+	}
+	return s.Staleness.tableDDL(ddl)
+}
+
+// insertSQL adds the optional columns of the settings to the INSERT statement query of a metric table.
+func (s MetricsSettings) insertSQL(query string) string {
+	if s.AttrHash {
+		query = withInsertColumn(query, "AttrHash")
+	}
+	if s.Staleness == StaleColumn {
+		query = withInsertColumn(query, "IsStale")
+	}
+	if s.UnifiedTable != "" {
+		query = withInsertColumn(query, "MetricType")
+	}
+	if s.TenantColumn != "" {
+		query = withInsertColumn(query, s.TenantColumn)
+	}
+	if s.Dimensions {
+		for _, column := range FingerprintColumns() {
+			query = withInsertColumn(query, column.Name)
+		}
+	}
+	return query
+}
+
+// extraColumns returns the values bound to the optional columns added by insertSQL to the INSERT statement of metricType.
+func (s MetricsSettings) extraColumns(metricType pmetric.MetricType) extraColumns {
+	columns := extraColumns{attrHash: s.AttrHash, isStale: s.Staleness == StaleColumn, tenantColumn: s.TenantColumn != "", tenant: s.Tenant, dimensions: s.Dimensions}
+	if s.UnifiedTable != "" {
+		columns.metricType = metricType.String()
+	}
+	return columns
+}
+
+// extraColumns binds the optional columns of the INSERT statement of a metric table.
+type extraColumns struct {
+	attrHash bool
+	isStale  bool
+	// metricType is the MetricType of the rows of the unified table, empty for the tables of a single type.
+	metricType string
+	// tenantColumn binds tenant to the tenant column.
+	tenantColumn bool
+	tenant       string
+	// dimensions binds the fingerprints of the resource and scope of the datapoint.
+	dimensions          bool
+	resourceFingerprint uint64
+	scopeFingerprint    uint64
+}
+
+// withFingerprints returns c binding the fingerprints of the resource and scope of metadata, their attributes
+// serialized as resAttr and scopeAttr, if the resources and scopes are written into the dimension tables.
+func (c extraColumns) withFingerprints(metadata *MetricsMetaData, resAttr, scopeAttr string) extraColumns {
+	if c.dimensions {
+		c.resourceFingerprint = ResourceFingerprint(metadata.ResURL, resAttr)
+		c.scopeFingerprint = ScopeFingerprint(metadata.ScopeURL, metadata.ScopeInstr.Name(), metadata.ScopeInstr.Version(), scopeAttr)
+	}
+	return c
+}
+
+// attributes returns the value of the ResourceAttributes or ScopeAttributes column for attrs, empty if the
+// resources and scopes are written into the dimension tables.
+func (c extraColumns) attributes(attrs string) string {
+	if c.dimensions {
+		return EmptyAttributes
+	}
+	return attrs
+}
+
+// bind appends the values of the optional columns of the datapoint with attrs and flags to values.
+func (c extraColumns) bind(values []any, attrs string, flags pmetric.DataPointFlags) []any {
+	if c.attrHash {
+		values = append(values, AttributesHash(attrs))
+	}
+	if c.isStale {
+		values = append(values, flags.NoRecordedValue())
+	}
+	if c.metricType != "" {
+		values = append(values, c.metricType)
+	}
+	if c.tenantColumn {
+		values = append(values, c.tenant)
+	}
+	if c.dimensions {
+		values = append(values, c.resourceFingerprint, c.scopeFingerprint)
+	}
+	return values
+}
+
+func (s MetricsSettings) exemplarsMode() ExemplarsMode {
+	if s.ExemplarsMode == "" {
+		return ExemplarsModeInline
+	}
+	return s.ExemplarsMode
+}
+
 // MetricsModel is used to group metric data and insert into clickhouse
 // any type of metrics need implement it.
 type MetricsModel interface {
@@ -57,34 +249,93 @@ func SetLogger(l *zap.Logger) {
 	logger = l
 }
 
-// NewMetricsTable create metric tables with an expiry time to storage metric telemetry data
-func NewMetricsTable(ctx context.Context, tablesConfig MetricTablesConfigMapper, cluster, engine, ttlExpr string, db *sql.DB) error {
-	for key, queryTemplate := range supportedMetricTypes {
-		query := fmt.Sprintf(queryTemplate, tablesConfig[key].Name, cluster, engine, ttlExpr)
-		if _, err := db.ExecContext(ctx, query); err != nil {
+// NewMetricsTable create metric tables with an expiry time to storage metric telemetry data,
+// ttlExpr returning the TTL clause of each table.
+func NewMetricsTable(ctx context.Context, tablesConfig MetricTablesConfigMapper, settings MetricsSettings, cluster, engine string, ttlExpr func(table string) string, db *sql.DB) error {
+	partitionBy := PartitionExpr(settings.PartitionBy, "TimeUnix")
+	metricPartitionBy := settings.metricPartitionExpr()
+	if settings.UnifiedTable != "" {
+		query := fmt.Sprintf(createUnifiedTableSQL, QuoteIdentifier(settings.UnifiedTable), cluster, exemplarsColumns(settings), engine, ttlExpr(settings.UnifiedTable), metricPartitionBy)
+		query = settings.metricTableDDL(settings.UnifiedTable, query, true, true)
+		if _, err := db.ExecContext(QueryContext(ctx, "create_table"), query); err != nil {
 			return fmt.Errorf("exec create metrics table sql: %w", err)
 		}
+	} else {
+		for key, queryTemplate := range supportedMetricTypes {
+			var query string
+			if key == pmetric.MetricTypeSummary {
+				// summary datapoints carry no exemplars
+				query = fmt.Sprintf(queryTemplate, QuoteIdentifier(tablesConfig[key].Name), cluster, engine, ttlExpr(tablesConfig[key].Name), metricPartitionBy)
+			} else {
+				query = fmt.Sprintf(queryTemplate, QuoteIdentifier(tablesConfig[key].Name), cluster, exemplarsColumns(settings), engine, ttlExpr(tablesConfig[key].Name), metricPartitionBy)
+			}
+			query = settings.metricTableDDL(tablesConfig[key].Name, query, key != pmetric.MetricTypeSummary,
+				key == pmetric.MetricTypeHistogram || key == pmetric.MetricTypeExponentialHistogram)
+			if _, err := db.ExecContext(QueryContext(ctx, "create_table"), query); err != nil {
+				return fmt.Errorf("exec create metrics table sql: %w", err)
+			}
+		}
+	}
+	if settings.exemplarsMode() == ExemplarsModeSeparateTable {
+		query := fmt.Sprintf(createExemplarsTableSQL, QuoteIdentifier(settings.ExemplarsTableName), cluster, engine, partitionBy, settings.ExemplarsTTLExpr)
+		query = settings.tableDDL(settings.ExemplarsTableName, query, exemplarsColumnComments)
+		if _, err := db.ExecContext(QueryContext(ctx, "create_table"), query); err != nil {
+			return fmt.Errorf("exec create exemplars table sql: %w", err)
+		}
 	}
 	return nil
 }
 
 // NewMetricsModel create a model for contain different metric data
-func NewMetricsModel(tablesConfig MetricTablesConfigMapper) map[pmetric.MetricType]MetricsModel {
+func NewMetricsModel(tablesConfig MetricTablesConfigMapper, settings MetricsSettings) map[pmetric.MetricType]MetricsModel {
+	exemplarsColumns, exemplarsValues := insertExemplars(settings)
 	return map[pmetric.MetricType]MetricsModel{
 		pmetric.MetricTypeGauge: &gaugeMetrics{
-			insertSQL: fmt.Sprintf(insertGaugeTableSQL, tablesConfig[pmetric.MetricTypeGauge].Name),
+			table:      tablesConfig[pmetric.MetricTypeGauge].Name,
+			insertSQL:  settings.insertSQL(fmt.Sprintf(insertGaugeTableSQL, QuoteIdentifier(tablesConfig[pmetric.MetricTypeGauge].Name), exemplarsColumns, exemplarsValues)),
+			exemplars:  newExemplarsWriter(settings, pmetric.MetricTypeGauge),
+			nonFinite:  settings.NonFinite,
+			staleness:  settings.Staleness,
+			attributes: settings.Attributes,
+			extra:      settings.extraColumns(pmetric.MetricTypeGauge),
 		},
 		pmetric.MetricTypeSum: &sumMetrics{
-			insertSQL: fmt.Sprintf(insertSumTableSQL, tablesConfig[pmetric.MetricTypeSum].Name),
+			table:      tablesConfig[pmetric.MetricTypeSum].Name,
+			insertSQL:  settings.insertSQL(fmt.Sprintf(insertSumTableSQL, QuoteIdentifier(tablesConfig[pmetric.MetricTypeSum].Name), exemplarsColumns, exemplarsValues)),
+			exemplars:  newExemplarsWriter(settings, pmetric.MetricTypeSum),
+			cumulative: settings.DeltaToCumulative,
+			nonFinite:  settings.NonFinite,
+			staleness:  settings.Staleness,
+			attributes: settings.Attributes,
+			extra:      settings.extraColumns(pmetric.MetricTypeSum),
 		},
 		pmetric.MetricTypeHistogram: &histogramMetrics{
-			insertSQL: fmt.Sprintf(insertHistogramTableSQL, tablesConfig[pmetric.MetricTypeHistogram].Name),
+			table:         tablesConfig[pmetric.MetricTypeHistogram].Name,
+			insertSQL:     settings.insertSQL(fmt.Sprintf(insertHistogramTableSQL, QuoteIdentifier(tablesConfig[pmetric.MetricTypeHistogram].Name), exemplarsColumns, exemplarsValues)),
+			exemplars:     newExemplarsWriter(settings, pmetric.MetricTypeHistogram),
+			nonFinite:     settings.NonFinite,
+			nullableStats: settings.NullableHistogramStats,
+			staleness:     settings.Staleness,
+			attributes:    settings.Attributes,
+			extra:         settings.extraColumns(pmetric.MetricTypeHistogram),
 		},
 		pmetric.MetricTypeExponentialHistogram: &expHistogramMetrics{
-			insertSQL: fmt.Sprintf(insertExpHistogramTableSQL, tablesConfig[pmetric.MetricTypeExponentialHistogram].Name),
+			table:         tablesConfig[pmetric.MetricTypeExponentialHistogram].Name,
+			insertSQL:     settings.insertSQL(fmt.Sprintf(insertExpHistogramTableSQL, QuoteIdentifier(tablesConfig[pmetric.MetricTypeExponentialHistogram].Name), exemplarsColumns, exemplarsValues)),
+			exemplars:     newExemplarsWriter(settings, pmetric.MetricTypeExponentialHistogram),
+			nonFinite:     settings.NonFinite,
+			nullableStats: settings.NullableHistogramStats,
+			staleness:     settings.Staleness,
+			attributes:    settings.Attributes,
+			maxBuckets:    settings.ExpHistogramMaxBuckets,
+			extra:         settings.extraColumns(pmetric.MetricTypeExponentialHistogram),
 		},
 		pmetric.MetricTypeSummary: &summaryMetrics{
-			insertSQL: fmt.Sprintf(insertSummaryTableSQL, tablesConfig[pmetric.MetricTypeSummary].Name),
+			table:      tablesConfig[pmetric.MetricTypeSummary].Name,
+			insertSQL:  settings.insertSQL(fmt.Sprintf(insertSummaryTableSQL, QuoteIdentifier(tablesConfig[pmetric.MetricTypeSummary].Name))),
+			staleness:  settings.Staleness,
+			attributes: settings.Attributes,
+			extra:      settings.extraColumns(pmetric.MetricTypeSummary),
 		},
 	}
 }
@@ -93,10 +344,10 @@ func NewMetricsModel(tablesConfig MetricTablesConfigMapper) map[pmetric.MetricTy
 func InsertMetrics(ctx context.Context, db *sql.DB, metricsMap map[pmetric.MetricType]MetricsModel) error {
 	errsChan := make(chan error, len(supportedMetricTypes))
 	wg := &sync.WaitGroup{}
-	for _, m := range metricsMap {
+	for metricType, m := range metricsMap {
 		wg.Add(1)
 		go func(m MetricsModel, wg *sync.WaitGroup) {
-			errsChan <- m.insert(ctx, db)
+			errsChan <- m.insert(InsertContext(ctx, "insert_"+strings.ToLower(metricType.String())), db)
 			wg.Done()
 		}(m, wg)
 	}
@@ -109,7 +360,7 @@ func InsertMetrics(ctx context.Context, db *sql.DB, metricsMap map[pmetric.Metri
 	return errs
 }
 
-func convertExemplars(exemplars pmetric.ExemplarSlice) (clickhouse.ArraySet, clickhouse.ArraySet, clickhouse.ArraySet, clickhouse.ArraySet, clickhouse.ArraySet) {
+func convertExemplars(exemplars pmetric.ExemplarSlice, filter *AttributeFilter, ids IDEncoding) (clickhouse.ArraySet, clickhouse.ArraySet, clickhouse.ArraySet, clickhouse.ArraySet, clickhouse.ArraySet) {
 	var (
 		attrs    clickhouse.ArraySet
 		times    clickhouse.ArraySet
@@ -119,13 +370,13 @@ func convertExemplars(exemplars pmetric.ExemplarSlice) (clickhouse.ArraySet, cli
 	)
 	for i := range exemplars.Len() {
 		exemplar := exemplars.At(i)
-		attrs = append(attrs, AttributesToJSON(exemplar.FilteredAttributes()))
+		attrs = append(attrs, filter.JSON(exemplar.FilteredAttributes()))
 		times = append(times, exemplar.Timestamp().AsTime())
 		values = append(values, getValue(exemplar.IntValue(), exemplar.DoubleValue(), exemplar.ValueType()))
 
 		traceID, spanID := exemplar.TraceID(), exemplar.SpanID()
-		traceIDs = append(traceIDs, TraceIDToHexOrEmptyString(traceID))
-		spanIDs = append(spanIDs, SpanIDToHexOrEmptyString(spanID))
+		traceIDs = append(traceIDs, ids.TraceID(traceID))
+		spanIDs = append(spanIDs, ids.SpanID(spanID))
 	}
 	return attrs, times, values, traceIDs, spanIDs
 }
@@ -166,15 +417,6 @@ func getValue(intValue int64, floatValue float64, dataType any) float64 {
 	}
 }
 
-func AttributesToJSON(attributes pcommon.Map) string {
-	rawMap := make(map[string]any, attributes.Len())
-	for k, v := range attributes.All() {
-		rawMap[strings.ReplaceAll(k, ".", "_")] = v.AsRaw()
-	}
-	jsonString, _ := json.Marshal(rawMap)
-	return string(jsonString)
-}
-
 func GetServiceName(resAttr pcommon.Map) string {
 	var serviceName string
 	if v, ok := resAttr.Get(string(conventions.ServiceNameKey)); ok {
@@ -208,18 +450,20 @@ func convertValueAtQuantile(valueAtQuantile pmetric.SummaryDataPointValueAtQuant
 // doWithTx is a copy of clickhouseexporter.doWithTx, it starts a transaction to exec SQL in fn.
 // This function is in a temporary status, after this PR get merged,
 // there will be a PR to move all db function and tool function to internal package.
-func doWithTx(ctx context.Context, db *sql.DB, fn func(tx *sql.Tx) error) error {
-	tx, err := db.BeginTx(ctx, nil)
-	if err != nil {
-		return fmt.Errorf("db.Begin: %w", err)
-	}
-	defer func() {
-		_ = tx.Rollback()
-	}()
-	if err := fn(tx); err != nil {
-		return err
-	}
-	return tx.Commit()
+func doWithTx(ctx context.Context, db *sql.DB, fn func(ctx context.Context, tx *sql.Tx) error) error {
+	return RetrySkippedRows(ctx, func(ctx context.Context) error {
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("db.Begin: %w", err)
+		}
+		defer func() {
+			_ = tx.Rollback()
+		}()
+		if err := fn(ctx, tx); err != nil {
+			return err
+		}
+		return tx.Commit()
+	})
 }
 
 func newPlaceholder(count int) *string {
@@ -46,7 +46,7 @@ func Test_convertExemplars(t *testing.T) {
 			expectTraceIDs clickhouse.ArraySet
 			expectSpanIDs  clickhouse.ArraySet
 		)
-		attrs, times, values, traceIDs, spanIDs := convertExemplars(exemplars)
+		attrs, times, values, traceIDs, spanIDs := convertExemplars(exemplars, nil, IDEncodingHex)
 		require.Equal(t, expectAttrs, attrs)
 		require.Equal(t, expectTimes, times)
 		require.Equal(t, expectValues, values)
@@ -59,7 +59,7 @@ func Test_convertExemplars(t *testing.T) {
 		exemplar.FilteredAttributes().PutStr("key1", "value1")
 		exemplar.FilteredAttributes().PutStr("key2", "value2")
 
-		attrs, times, values, traceIDs, spanIDs := convertExemplars(exemplars)
+		attrs, times, values, traceIDs, spanIDs := convertExemplars(exemplars, nil, IDEncodingHex)
 		require.Equal(t, clickhouse.ArraySet{orderedmap.FromMap(map[string]string{"key1": "value1", "key2": "value2"})}, attrs)
 		require.Equal(t, clickhouse.ArraySet{time.Date(1970, time.January, 1, 0, 0, 0, 0, time.UTC)}, times)
 		require.Equal(t, clickhouse.ArraySet{0.0}, values)
@@ -71,7 +71,7 @@ func Test_convertExemplars(t *testing.T) {
 		exemplar := exemplars.AppendEmpty()
 		exemplar.SetTimestamp(pcommon.NewTimestampFromTime(time.Unix(1672218930, 0)))
 
-		attrs, times, values, traceIDs, spanIDs := convertExemplars(exemplars)
+		attrs, times, values, traceIDs, spanIDs := convertExemplars(exemplars, nil, IDEncodingHex)
 		require.Equal(t, clickhouse.ArraySet{orderedmap.FromMap(map[string]string{})}, attrs)
 		require.Equal(t, clickhouse.ArraySet{time.Unix(1672218930, 0).UTC()}, times)
 		require.Equal(t, clickhouse.ArraySet{0.0}, values)
@@ -83,7 +83,7 @@ func Test_convertExemplars(t *testing.T) {
 		exemplar := exemplars.AppendEmpty()
 		exemplar.SetDoubleValue(15.0)
 
-		attrs, times, values, traceIDs, spanIDs := convertExemplars(exemplars)
+		attrs, times, values, traceIDs, spanIDs := convertExemplars(exemplars, nil, IDEncodingHex)
 		require.Equal(t, clickhouse.ArraySet{orderedmap.FromMap(map[string]string{})}, attrs)
 		require.Equal(t, clickhouse.ArraySet{time.Date(1970, time.January, 1, 0, 0, 0, 0, time.UTC)}, times)
 		require.Equal(t, clickhouse.ArraySet{15.0}, values)
@@ -95,7 +95,7 @@ func Test_convertExemplars(t *testing.T) {
 		exemplar := exemplars.AppendEmpty()
 		exemplar.SetIntValue(20)
 
-		attrs, times, values, traceIDs, spanIDs := convertExemplars(exemplars)
+		attrs, times, values, traceIDs, spanIDs := convertExemplars(exemplars, nil, IDEncodingHex)
 		require.Equal(t, clickhouse.ArraySet{orderedmap.FromMap(map[string]string{})}, attrs)
 		require.Equal(t, clickhouse.ArraySet{time.Date(1970, time.January, 1, 0, 0, 0, 0, time.UTC)}, times)
 		require.Equal(t, clickhouse.ArraySet{20.0}, values)
@@ -107,7 +107,7 @@ func Test_convertExemplars(t *testing.T) {
 		exemplar := exemplars.AppendEmpty()
 		exemplar.SetSpanID([8]byte{1, 2, 3, 4})
 
-		attrs, times, values, traceIDs, spanIDs := convertExemplars(exemplars)
+		attrs, times, values, traceIDs, spanIDs := convertExemplars(exemplars, nil, IDEncodingHex)
 		require.Equal(t, clickhouse.ArraySet{orderedmap.FromMap(map[string]string{})}, attrs)
 		require.Equal(t, clickhouse.ArraySet{time.Date(1970, time.January, 1, 0, 0, 0, 0, time.UTC)}, times)
 		require.Equal(t, clickhouse.ArraySet{0.0}, values)
@@ -119,7 +119,7 @@ func Test_convertExemplars(t *testing.T) {
 		exemplar := exemplars.AppendEmpty()
 		exemplar.SetTraceID([16]byte{1, 2, 3, 4})
 
-		attrs, times, values, traceIDs, spanIDs := convertExemplars(exemplars)
+		attrs, times, values, traceIDs, spanIDs := convertExemplars(exemplars, nil, IDEncodingHex)
 		require.Equal(t, clickhouse.ArraySet{orderedmap.FromMap(map[string]string{})}, attrs)
 		require.Equal(t, clickhouse.ArraySet{time.Date(1970, time.January, 1, 0, 0, 0, 0, time.UTC)}, times)
 		require.Equal(t, clickhouse.ArraySet{0.0}, values)
@@ -146,7 +146,7 @@ func Test_convertExemplars(t *testing.T) {
 		exemplar.SetSpanID([8]byte{1, 2, 3, 5})
 		exemplar.SetTraceID([16]byte{1, 2, 3, 5})
 
-		attrs, times, values, traceIDs, spanIDs := convertExemplars(exemplars)
+		attrs, times, values, traceIDs, spanIDs := convertExemplars(exemplars, nil, IDEncodingHex)
 		require.Equal(t, clickhouse.ArraySet{orderedmap.FromMap(map[string]string{"key1": "value1", "key2": "value2"}), orderedmap.FromMap(map[string]string{"key3": "value3", "key4": "value4"})}, attrs)
 		require.Equal(t, clickhouse.ArraySet{time.Unix(1672218930, 0).UTC(), time.Unix(1672219930, 0).UTC()}, times)
 		require.Equal(t, clickhouse.ArraySet{20.0, 16.0}, values)
@@ -0,0 +1,128 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package migrate // import "github.com/foyer-work/otel-distribution/exporter/clickhouse/internal/migrate"
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/foyer-work/otel-distribution/exporter/clickhouse/internal"
+)
+
+// Column describes a table column as reported by system.columns.
+type Column struct {
+	Name string
+	Type string
+}
+
+var fixedStringRe = regexp.MustCompile(`^FixedString\((\d+)\)$`)
+
+// ConvertExpr returns a SELECT expression that reads expr of type from and
+// yields a value assignable to a column of type to.
+// Conversions between the schema variants produced by the exporter
+// (Map to JSON attributes, String/FixedString ids, LowCardinality wrapping)
+// are handled explicitly, anything else falls back to CAST. JSON to Map
+// attributes depends on the paths of the data, see JSONToMapExpr.
+func ConvertExpr(expr, from, to string) string {
+	from, to = unwrapLowCardinality(from), unwrapLowCardinality(to)
+	if from == to {
+		return expr
+	}
+
+	fromInner, fromIsArray := unwrapArray(from)
+	toInner, toIsArray := unwrapArray(to)
+	if fromIsArray && toIsArray {
+		inner := ConvertExpr("x", fromInner, toInner)
+		if inner == "x" {
+			return expr
+		}
+		return fmt.Sprintf("arrayMap(x -> %s, %s)", inner, expr)
+	}
+
+	switch {
+	case isMap(from) && isJSON(to):
+		return fmt.Sprintf("CAST(toJSONString(%s), '%s')", expr, to)
+	case from == "String" && fixedStringRe.MatchString(to):
+		n := fixedStringRe.FindStringSubmatch(to)[1]
+		return fmt.Sprintf("toFixedString(unhex(%s), %s)", expr, n)
+	case fixedStringRe.MatchString(from) && to == "String":
+		return fmt.Sprintf("if(%[1]s = repeat('\\0', length(%[1]s)), '', lower(hex(%[1]s)))", expr)
+	case from == "String" && to == "UUID":
+		return fmt.Sprintf("toUUIDOrZero(%s)", expr)
+	case from == "UUID" && to == "String":
+		return fmt.Sprintf("replaceAll(toString(%s), '-', '')", expr)
+	}
+
+	return fmt.Sprintf("CAST(%s, '%s')", expr, to)
+}
+
+// JSONToMapExpr returns a SELECT expression that reads the JSON column expr
+// into a column of the Map type to, keyed by the dotted paths of paths.
+// JSON nests the dotted attribute keys it was written with into objects, so
+// the paths are read one by one as strings rather than from the serialized
+// top level object. The paths absent from a row are left out of its map.
+func JSONToMapExpr(expr, to string, paths []string) string {
+	if len(paths) == 0 {
+		return fmt.Sprintf("CAST(map(), '%s')", to)
+	}
+	pairs := make([]string, 0, len(paths))
+	for _, path := range paths {
+		pairs = append(pairs, fmt.Sprintf("%s, toString(getSubcolumn(%s, %s))", internal.QuoteString(path), expr, internal.QuoteString(path)))
+	}
+	return fmt.Sprintf("CAST(mapFilter((k, v) -> has(JSONAllPaths(%s), k), map(%s)), '%s')", expr, strings.Join(pairs, ", "), to)
+}
+
+// SelectList builds the target column list and the matching SELECT expressions
+// for copying from source into target. Target columns without a counterpart in
+// source are skipped and left to their DEFAULT expressions. overrides replaces
+// the expression used for the given target column. jsonPaths holds the paths
+// of the JSON source columns copied into Map target columns.
+func SelectList(source, target []Column, overrides map[string]string, jsonPaths map[string][]string) (columns []string, exprs []string) {
+	sourceTypes := make(map[string]string, len(source))
+	for _, c := range source {
+		sourceTypes[c.Name] = c.Type
+	}
+
+	for _, c := range target {
+		if expr, ok := overrides[c.Name]; ok {
+			columns = append(columns, internal.QuoteIdentifier(c.Name))
+			exprs = append(exprs, expr)
+			continue
+		}
+		typ, ok := sourceTypes[c.Name]
+		if !ok {
+			continue
+		}
+		columns = append(columns, internal.QuoteIdentifier(c.Name))
+		if isJSON(typ) && isMap(c.Type) {
+			exprs = append(exprs, JSONToMapExpr(internal.QuoteIdentifier(c.Name), c.Type, jsonPaths[c.Name]))
+			continue
+		}
+		exprs = append(exprs, ConvertExpr(internal.QuoteIdentifier(c.Name), typ, c.Type))
+	}
+	return columns, exprs
+}
+
+func unwrapLowCardinality(typ string) string {
+	if strings.HasPrefix(typ, "LowCardinality(") && strings.HasSuffix(typ, ")") {
+		return typ[len("LowCardinality(") : len(typ)-1]
+	}
+	return typ
+}
+
+func unwrapArray(typ string) (string, bool) {
+	if strings.HasPrefix(typ, "Array(") && strings.HasSuffix(typ, ")") {
+		return typ[len("Array(") : len(typ)-1], true
+	}
+	return typ, false
+}
+
+func isJSON(typ string) bool {
+	return typ == "JSON" || strings.HasPrefix(typ, "JSON(") || typ == "Object('json')"
+}
+
+func isMap(typ string) bool {
+	return strings.HasPrefix(typ, "Map(")
+}
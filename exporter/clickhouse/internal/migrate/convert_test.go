@@ -0,0 +1,109 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package migrate
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConvertExpr(t *testing.T) {
+	tests := []struct {
+		name     string
+		from, to string
+		want     string
+	}{
+		{
+			name: "same type",
+			from: "String",
+			to:   "String",
+			want: "c",
+		},
+		{
+			name: "low cardinality is transparent",
+			from: "LowCardinality(String)",
+			to:   "String",
+			want: "c",
+		},
+		{
+			name: "map to json",
+			from: "Map(LowCardinality(String), String)",
+			to:   "JSON",
+			want: "CAST(toJSONString(c), 'JSON')",
+		},
+		{
+			name: "hex string to fixed string",
+			from: "String",
+			to:   "FixedString(16)",
+			want: "toFixedString(unhex(c), 16)",
+		},
+		{
+			name: "fixed string to hex string",
+			from: "FixedString(8)",
+			to:   "String",
+			want: `if(c = repeat('\0', length(c)), '', lower(hex(c)))`,
+		},
+		{
+			name: "array elements are converted",
+			from: "Array(String)",
+			to:   "Array(FixedString(8))",
+			want: "arrayMap(x -> toFixedString(unhex(x), 8), c)",
+		},
+		{
+			name: "array of same element type",
+			from: "Array(LowCardinality(String))",
+			to:   "Array(String)",
+			want: "c",
+		},
+		{
+			name: "fallback to cast",
+			from: "UInt32",
+			to:   "UInt64",
+			want: "CAST(c, 'UInt64')",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, ConvertExpr("c", tt.from, tt.to))
+		})
+	}
+}
+
+func TestJSONToMapExpr(t *testing.T) {
+	// The dotted and quoted keys are read as paths of the JSON column, the values as strings rather than
+	// their escaped JSON serialization.
+	require.Equal(t,
+		"CAST(mapFilter((k, v) -> has(JSONAllPaths(`Attributes`), k), map("+
+			"'http.method', toString(getSubcolumn(`Attributes`, 'http.method')), "+
+			"'user\\'s.name', toString(getSubcolumn(`Attributes`, 'user\\'s.name')))), 'Map(LowCardinality(String), String)')",
+		JSONToMapExpr("`Attributes`", "Map(LowCardinality(String), String)", []string{"http.method", "user's.name"}))
+	require.Equal(t, "CAST(map(), 'Map(String, String)')", JSONToMapExpr("`Attributes`", "Map(String, String)", nil))
+}
+
+func TestSelectList(t *testing.T) {
+	source := []Column{
+		{Name: "Timestamp", Type: "DateTime64(9)"},
+		{Name: "TraceId", Type: "String"},
+		{Name: "Removed", Type: "String"},
+	}
+	target := []Column{
+		{Name: "Timestamp", Type: "DateTime64(9)"},
+		{Name: "TraceId", Type: "FixedString(16)"},
+		{Name: "Added", Type: "String"},
+		{Name: "Computed", Type: "UInt8"},
+	}
+
+	columns, exprs := SelectList(source, target, map[string]string{"Computed": "1"}, nil)
+	require.Equal(t, []string{"`Timestamp`", "`TraceId`", "`Computed`"}, columns)
+	require.Equal(t, []string{"`Timestamp`", "toFixedString(unhex(`TraceId`), 16)", "1"}, exprs)
+
+	source = []Column{{Name: "LogAttributes", Type: "JSON"}}
+	target = []Column{{Name: "LogAttributes", Type: "Map(LowCardinality(String), String)"}}
+	columns, exprs = SelectList(source, target, nil, map[string][]string{"LogAttributes": {"http.method"}})
+	require.Equal(t, []string{"`LogAttributes`"}, columns)
+	require.Equal(t, []string{
+		"CAST(mapFilter((k, v) -> has(JSONAllPaths(`LogAttributes`), k), map('http.method', toString(getSubcolumn(`LogAttributes`, 'http.method')))), 'Map(LowCardinality(String), String)')",
+	}, exprs)
+}
@@ -0,0 +1,150 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package migrate // import "github.com/foyer-work/otel-distribution/exporter/clickhouse/internal/migrate"
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/foyer-work/otel-distribution/exporter/clickhouse/internal"
+)
+
+var (
+	errNoTable      = errors.New("table not found")
+	errNotLegacy    = errors.New("table has no Map attribute columns")
+	errForeignViews = errors.New("table read by views not created by the exporter")
+)
+
+// IsNotLegacy reports whether err is returned by MoveLegacyTable for a table
+// that doesn't exist or already has the JSON attributes schema.
+func IsNotLegacy(err error) bool {
+	return errors.Is(err, errNoTable) || errors.Is(err, errNotLegacy)
+}
+
+// LegacyAttributeColumns returns the attribute columns of the Map schema of
+// the older exporter versions, including those of nested events and links.
+func LegacyAttributeColumns(columns []Column) []string {
+	var names []string
+	for _, col := range columns {
+		typ, _ := unwrapArray(col.Type)
+		if strings.HasSuffix(col.Name, "Attributes") && isMap(typ) {
+			names = append(names, col.Name)
+		}
+	}
+	return names
+}
+
+// MoveLegacyTable renames table of the Map attributes schema to table+suffix,
+// so that the exporter creates the table of the JSON attributes schema in its
+// place, and returns the name of the legacy table to copy the rows from.
+//
+// The materialized views the exporter created on table are dropped before,
+// the exporter recreating them on the new table. The table isn't moved if
+// other views read from it, which are reported, unless opts.DropViews is set.
+// A table moved by a previous run is returned as is, so that an interrupted
+// migration resumes with the copy.
+func MoveLegacyTable(ctx context.Context, db *sql.DB, logger *zap.Logger, opts Options, table, suffix string) (string, error) {
+	legacy := table + suffix
+	if _, err := describe(ctx, db, opts, legacy); err == nil {
+		logger.Info("legacy table already moved", zap.String("table", opts.qualified(legacy)))
+		return legacy, nil
+	} else if !errors.Is(err, errNoTable) {
+		return "", err
+	}
+
+	columns, err := describe(ctx, db, opts, table)
+	if err != nil {
+		return "", err
+	}
+	if len(LegacyAttributeColumns(columns)) == 0 {
+		return "", fmt.Errorf("%s: %w", opts.qualified(table), errNotLegacy)
+	}
+
+	views, others, err := dependentViews(ctx, db, opts, table)
+	if err != nil {
+		return "", err
+	}
+	if len(others) != 0 {
+		if !opts.DropViews {
+			return "", fmt.Errorf("%s: %w: %s", opts.qualified(table), errForeignViews, strings.Join(others, ", "))
+		}
+		views = append(views, others...)
+	}
+	statements := make([]string, 0, len(views)+1)
+	for _, view := range views {
+		statements = append(statements, "DROP VIEW IF EXISTS "+view)
+	}
+	statements = append(statements, fmt.Sprintf("RENAME TABLE %s TO %s", opts.qualified(table), opts.qualified(legacy)))
+	for _, statement := range statements {
+		if opts.DryRun {
+			logger.Info("dry run", zap.String("query", statement))
+			continue
+		}
+		if _, err := db.ExecContext(ctx, statement); err != nil {
+			return "", fmt.Errorf("move %s aside: %w", opts.qualified(table), err)
+		}
+	}
+	logger.Info("legacy table moved", zap.String("table", opts.qualified(table)), zap.String("legacy", opts.qualified(legacy)))
+	return legacy, nil
+}
+
+// dependentViews returns the qualified names of the materialized views reading from table, those created by
+// the exporter and the others.
+func dependentViews(ctx context.Context, db *sql.DB, opts Options, table string) (exporter, others []string, err error) {
+	var databases, tables []string
+	err = db.QueryRowContext(ctx,
+		"SELECT dependencies_database, dependencies_table FROM system.tables WHERE database = ? AND name = ?",
+		opts.database(), table).Scan(&databases, &tables)
+	if err != nil {
+		return nil, nil, fmt.Errorf("list views of %s: %w", opts.qualified(table), err)
+	}
+	for i := range tables {
+		view := internal.QuoteIdentifier(databases[i]) + "." + internal.QuoteIdentifier(tables[i])
+		if isExporterView(opts, table, databases[i], tables[i]) {
+			exporter = append(exporter, view)
+		} else {
+			others = append(others, view)
+		}
+	}
+	return exporter, others, nil
+}
+
+// isExporterView reports whether the view of database reading from table is created by the exporter, its
+// views being in the database of table and named after it with the _mv suffix, e.g. otel_traces_trace_id_ts_mv.
+func isExporterView(opts Options, table, database, view string) bool {
+	return database == opts.database() && strings.HasPrefix(view, table+"_") && strings.HasSuffix(view, "_mv")
+}
+
+func describe(ctx context.Context, db *sql.DB, opts Options, table string) ([]Column, error) {
+	rows, err := db.QueryContext(ctx,
+		"SELECT name, type FROM system.columns WHERE database = ? AND table = ? ORDER BY position",
+		opts.database(), table)
+	if err != nil {
+		return nil, fmt.Errorf("describe %s: %w", table, err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	var columns []Column
+	for rows.Next() {
+		var col Column
+		if err := rows.Scan(&col.Name, &col.Type); err != nil {
+			return nil, fmt.Errorf("describe %s: %w", table, err)
+		}
+		columns = append(columns, col)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("describe %s: %w", table, err)
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("%w: %s", errNoTable, opts.qualified(table))
+	}
+	return columns, nil
+}
@@ -0,0 +1,34 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package migrate
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLegacyAttributeColumns(t *testing.T) {
+	columns := []Column{
+		{Name: "Timestamp", Type: "DateTime64(9)"},
+		{Name: "ResourceAttributes", Type: "Map(LowCardinality(String), String)"},
+		{Name: "SpanAttributes", Type: "JSON"},
+		{Name: "Events.Attributes", Type: "Array(Map(LowCardinality(String), String))"},
+		{Name: "Links.TraceId", Type: "Array(String)"},
+	}
+	require.Equal(t, []string{"ResourceAttributes", "Events.Attributes"}, LegacyAttributeColumns(columns))
+	require.Empty(t, LegacyAttributeColumns(columns[2:3]), "the JSON schema has no legacy columns")
+
+	_, exprs := SelectList(columns[3:4], []Column{{Name: "Events.Attributes", Type: "Array(JSON)"}}, nil, nil)
+	require.Equal(t, []string{"arrayMap(x -> CAST(toJSONString(x), 'JSON'), `Events.Attributes`)"}, exprs,
+		"the attributes of the nested events are converted too")
+}
+
+func TestIsExporterView(t *testing.T) {
+	opts := Options{Database: "otel"}
+	require.True(t, isExporterView(opts, "otel_traces", "otel", "otel_traces_trace_id_ts_mv"))
+	require.True(t, isExporterView(opts, "otel_metrics_gauge", "otel", "otel_metrics_gauge_5m_mv"))
+	require.False(t, isExporterView(opts, "otel_traces", "otel", "errors_by_service"), "views named otherwise aren't the exporter's")
+	require.False(t, isExporterView(opts, "otel_traces", "analytics", "otel_traces_trace_id_ts_mv"), "views of other databases aren't the exporter's")
+}
@@ -0,0 +1,316 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package migrate // import "github.com/foyer-work/otel-distribution/exporter/clickhouse/internal/migrate"
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/foyer-work/otel-distribution/exporter/clickhouse/internal"
+)
+
+// Options configures a Copier.
+type Options struct {
+	// Database holding both the source and the target table.
+	Database string
+	// SourceTable is the table data is read from.
+	SourceTable string
+	// TargetTable is the table data is written to. It must already exist.
+	TargetTable string
+	// Overrides replaces the generated SELECT expression for a target column.
+	Overrides map[string]string
+	// Where is an optional extra filter applied to every chunk.
+	Where string
+	// MaxPartitions limits how many partitions are copied by a single Run. 0 means no limit.
+	MaxPartitions int
+	// Settings are appended as a SETTINGS clause to every INSERT SELECT.
+	Settings map[string]string
+	// StateFile is where progress is persisted. Copying resumes from it when present.
+	StateFile string
+	// DryRun logs the statements instead of executing them.
+	DryRun bool
+	// DropViews drops the views not created by the exporter reading from the table MoveLegacyTable moves aside,
+	// which it refuses to move otherwise.
+	DropViews bool
+}
+
+// State is the persisted progress of a copy, keyed by source partition id.
+type State struct {
+	Source    string                       `json:"source"`
+	Target    string                       `json:"target"`
+	Completed map[string]PartitionProgress `json:"completed"`
+}
+
+// PartitionProgress records a finished partition.
+type PartitionProgress struct {
+	Rows       uint64    `json:"rows"`
+	FinishedAt time.Time `json:"finished_at"`
+}
+
+// Partition is an active source partition as reported by system.parts.
+type Partition struct {
+	ID   string
+	Rows uint64
+}
+
+// Copier copies a table into a table of a different schema variant, one
+// partition per INSERT SELECT, so that every statement is bounded and a
+// restarted run picks up after the last completed partition.
+type Copier struct {
+	db     *sql.DB
+	logger *zap.Logger
+	opts   Options
+	state  *State
+	done   bool
+}
+
+var errStateMismatch = errors.New("state file belongs to a different source/target pair")
+
+// NewCopier creates a Copier and loads previous progress from opts.StateFile.
+func NewCopier(db *sql.DB, logger *zap.Logger, opts Options) (*Copier, error) {
+	if opts.SourceTable == "" || opts.TargetTable == "" {
+		return nil, errors.New("source and target tables must be specified")
+	}
+	state, err := loadState(opts.StateFile)
+	if err != nil {
+		return nil, err
+	}
+	source, target := opts.qualified(opts.SourceTable), opts.qualified(opts.TargetTable)
+	if state.Source == "" && state.Target == "" {
+		state.Source, state.Target = source, target
+	}
+	if state.Source != source || state.Target != target {
+		return nil, fmt.Errorf("%w: %s -> %s", errStateMismatch, state.Source, state.Target)
+	}
+
+	return &Copier{
+		db:     db,
+		logger: logger,
+		opts:   opts,
+		state:  state,
+	}, nil
+}
+
+// Run copies every partition not yet recorded as completed.
+func (c *Copier) Run(ctx context.Context) error {
+	source, err := c.columns(ctx, c.opts.SourceTable)
+	if err != nil {
+		return err
+	}
+	target, err := c.columns(ctx, c.opts.TargetTable)
+	if err != nil {
+		return err
+	}
+	jsonPaths, err := c.jsonPaths(ctx, source, target)
+	if err != nil {
+		return err
+	}
+	columns, exprs := SelectList(source, target, c.opts.Overrides, jsonPaths)
+	if len(columns) == 0 {
+		return errors.New("source and target tables have no columns in common")
+	}
+
+	partitions, err := c.partitions(ctx)
+	if err != nil {
+		return err
+	}
+
+	var totalRows, doneRows uint64
+	for _, p := range partitions {
+		totalRows += p.Rows
+		if done, ok := c.state.Completed[p.ID]; ok {
+			doneRows += done.Rows
+		}
+	}
+
+	copied := 0
+	for i, p := range partitions {
+		if _, ok := c.state.Completed[p.ID]; ok {
+			continue
+		}
+		if c.opts.MaxPartitions > 0 && copied >= c.opts.MaxPartitions {
+			c.logger.Info("partition limit reached, rerun to continue", zap.Int("limit", c.opts.MaxPartitions))
+			return nil
+		}
+
+		query := c.renderInsertSelect(columns, exprs, p.ID)
+		start := time.Now()
+		if c.opts.DryRun {
+			c.logger.Info("dry run", zap.String("partition", p.ID), zap.String("query", query))
+			copied++
+			continue
+		}
+		if _, err := c.db.ExecContext(ctx, query); err != nil {
+			return fmt.Errorf("copy partition %s: %w", p.ID, err)
+		}
+
+		c.state.Completed[p.ID] = PartitionProgress{Rows: p.Rows, FinishedAt: time.Now().UTC()}
+		if err := c.saveState(); err != nil {
+			return err
+		}
+		copied++
+		doneRows += p.Rows
+		c.logger.Info("partition copied",
+			zap.String("partition", p.ID),
+			zap.Uint64("rows", p.Rows),
+			zap.Duration("cost", time.Since(start)),
+			zap.String("partitions", fmt.Sprintf("%d/%d", i+1, len(partitions))),
+			zap.String("rows_done", fmt.Sprintf("%d/%d", doneRows, totalRows)),
+		)
+	}
+	c.done = !c.opts.DryRun
+	return nil
+}
+
+// Done reports whether the last Run copied every partition of the source table.
+func (c *Copier) Done() bool {
+	return c.done
+}
+
+func (c *Copier) columns(ctx context.Context, table string) ([]Column, error) {
+	return describe(ctx, c.db, c.opts, table)
+}
+
+// jsonPaths returns the paths of the JSON source columns copied into Map target columns.
+func (c *Copier) jsonPaths(ctx context.Context, source, target []Column) (map[string][]string, error) {
+	sourceTypes := make(map[string]string, len(source))
+	for _, col := range source {
+		sourceTypes[col.Name] = col.Type
+	}
+	paths := map[string][]string{}
+	for _, col := range target {
+		if _, ok := c.opts.Overrides[col.Name]; ok || !isJSON(sourceTypes[col.Name]) || !isMap(col.Type) {
+			continue
+		}
+		rows, err := c.db.QueryContext(ctx, fmt.Sprintf("SELECT DISTINCT arrayJoin(JSONAllPaths(%s)) AS path FROM %s ORDER BY path",
+			internal.QuoteIdentifier(col.Name), c.opts.qualified(c.opts.SourceTable)))
+		if err != nil {
+			return nil, fmt.Errorf("list paths of %s: %w", col.Name, err)
+		}
+		for rows.Next() {
+			var path string
+			if err := rows.Scan(&path); err != nil {
+				_ = rows.Close()
+				return nil, fmt.Errorf("list paths of %s: %w", col.Name, err)
+			}
+			paths[col.Name] = append(paths[col.Name], path)
+		}
+		err = rows.Err()
+		_ = rows.Close()
+		if err != nil {
+			return nil, fmt.Errorf("list paths of %s: %w", col.Name, err)
+		}
+	}
+	return paths, nil
+}
+
+func (c *Copier) partitions(ctx context.Context) ([]Partition, error) {
+	rows, err := c.db.QueryContext(ctx,
+		"SELECT partition_id, sum(rows) FROM system.parts WHERE database = ? AND table = ? AND active GROUP BY partition_id ORDER BY partition_id",
+		c.opts.database(), c.opts.SourceTable)
+	if err != nil {
+		return nil, fmt.Errorf("list partitions: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	var partitions []Partition
+	for rows.Next() {
+		var p Partition
+		if err := rows.Scan(&p.ID, &p.Rows); err != nil {
+			return nil, fmt.Errorf("list partitions: %w", err)
+		}
+		partitions = append(partitions, p)
+	}
+	return partitions, rows.Err()
+}
+
+func (c *Copier) renderInsertSelect(columns, exprs []string, partitionID string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "INSERT INTO %s (%s)", c.opts.qualified(c.opts.TargetTable), strings.Join(columns, ", "))
+
+	// The dedup token makes a retried partition a no-op on replicated targets
+	// (or targets with non_replicated_deduplication_window set).
+	settings := map[string]string{
+		"insert_deduplication_token": internal.QuoteString(c.state.Source + "/" + partitionID),
+	}
+	for k, v := range c.opts.Settings {
+		settings[k] = v
+	}
+	keys := make([]string, 0, len(settings))
+	for k := range settings {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, k+" = "+settings[k])
+	}
+	fmt.Fprintf(&b, " SETTINGS %s", strings.Join(pairs, ", "))
+
+	fmt.Fprintf(&b, " SELECT %s FROM %s WHERE _partition_id = %s",
+		strings.Join(exprs, ", "), c.opts.qualified(c.opts.SourceTable), internal.QuoteString(partitionID))
+	if c.opts.Where != "" {
+		fmt.Fprintf(&b, " AND (%s)", c.opts.Where)
+	}
+	return b.String()
+}
+
+func (c *Copier) saveState() error {
+	if c.opts.StateFile == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(c.state, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := c.opts.StateFile + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("write state: %w", err)
+	}
+	return os.Rename(tmp, c.opts.StateFile)
+}
+
+func loadState(path string) (*State, error) {
+	state := &State{Completed: map[string]PartitionProgress{}}
+	if path == "" {
+		return state, nil
+	}
+	data, err := os.ReadFile(filepath.Clean(path))
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read state: %w", err)
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("parse state %s: %w", path, err)
+	}
+	if state.Completed == nil {
+		state.Completed = map[string]PartitionProgress{}
+	}
+	return state, nil
+}
+
+func (o Options) database() string {
+	if o.Database == "" {
+		return "default"
+	}
+	return o.Database
+}
+
+func (o Options) qualified(table string) string {
+	return internal.QuoteIdentifier(o.database()) + "." + internal.QuoteIdentifier(table)
+}
@@ -0,0 +1,48 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package migrate
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestNewCopier_resumesFromState(t *testing.T) {
+	stateFile := filepath.Join(t.TempDir(), "state.json")
+	opts := Options{Database: "otel", SourceTable: "otel_logs", TargetTable: "otel_logs_v2", StateFile: stateFile}
+
+	c, err := NewCopier(nil, zaptest.NewLogger(t), opts)
+	require.NoError(t, err)
+	c.state.Completed["20240101"] = PartitionProgress{Rows: 42}
+	require.NoError(t, c.saveState())
+
+	c, err = NewCopier(nil, zaptest.NewLogger(t), opts)
+	require.NoError(t, err)
+	require.Equal(t, uint64(42), c.state.Completed["20240101"].Rows)
+
+	opts.TargetTable = "other"
+	_, err = NewCopier(nil, zaptest.NewLogger(t), opts)
+	require.ErrorIs(t, err, errStateMismatch)
+}
+
+func TestCopier_renderInsertSelect(t *testing.T) {
+	c, err := NewCopier(nil, zaptest.NewLogger(t), Options{
+		Database:    "otel",
+		SourceTable: "otel_logs",
+		TargetTable: "otel_logs_v2",
+		Where:       "ServiceName != ''",
+		Settings:    map[string]string{"max_threads": "4"},
+	})
+	require.NoError(t, err)
+
+	query := c.renderInsertSelect([]string{"`Body`"}, []string{"`Body`"}, "20240101")
+	require.Equal(t,
+		"INSERT INTO `otel`.`otel_logs_v2` (`Body`) "+
+			"SETTINGS insert_deduplication_token = '`otel`.`otel_logs`/20240101', max_threads = 4 "+
+			"SELECT `Body` FROM `otel`.`otel_logs` WHERE _partition_id = '20240101' AND (ServiceName != '')",
+		query)
+}
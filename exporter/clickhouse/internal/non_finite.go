@@ -0,0 +1,80 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package internal // import "github.com/foyer-work/otel-distribution/exporter/clickhouse/internal"
+
+import (
+	"math"
+	"slices"
+)
+
+// NonFinitePolicy is how the NaN, +Inf and -Inf values of gauge and sum datapoints,
+// and the sum, min and max of histogram datapoints, are written.
+type NonFinitePolicy string
+
+const (
+	// NonFiniteKeep writes the values unchanged.
+	NonFiniteKeep NonFinitePolicy = "keep"
+	// NonFiniteDrop drops the datapoints having such a value.
+	NonFiniteDrop NonFinitePolicy = "drop"
+	// NonFiniteClamp writes +Inf and -Inf as the largest and smallest Float64, and NaN as 0.
+	NonFiniteClamp NonFinitePolicy = "clamp"
+	// NonFiniteNull writes NULL, the Float64 columns of the values being created Nullable.
+	NonFiniteNull NonFinitePolicy = "null"
+)
+
+// nonFiniteColumns are the columns holding the values the policy applies to.
+var nonFiniteColumns = []string{"Value", "Sum", "Min", "Max"}
+
+// apply returns the value to write for v, nil for NULL, false if the datapoint must be dropped.
+func (p NonFinitePolicy) apply(v float64) (any, bool) {
+	if !math.IsNaN(v) && !math.IsInf(v, 0) {
+		return v, true
+	}
+	switch p {
+	case NonFiniteDrop:
+		return nil, false
+	case NonFiniteClamp:
+		switch {
+		case math.IsInf(v, 1):
+			return math.MaxFloat64, true
+		case math.IsInf(v, -1):
+			return -math.MaxFloat64, true
+		default:
+			return 0.0, true
+		}
+	case NonFiniteNull:
+		return nil, true
+	default:
+		return v, true
+	}
+}
+
+// applyAll applies the policy to each of values, false if the datapoint must be dropped.
+func (p NonFinitePolicy) applyAll(values ...float64) ([]any, bool) {
+	out := make([]any, len(values))
+	for i, v := range values {
+		var ok bool
+		if out[i], ok = p.apply(v); !ok {
+			return nil, false
+		}
+	}
+	return out, true
+}
+
+// tableDDL makes the Float64 value columns of the CREATE TABLE statement ddl Nullable if the policy writes NULL.
+func (p NonFinitePolicy) tableDDL(ddl string) string {
+	if p != NonFiniteNull {
+		return ddl
+	}
+	return nullableColumns(ddl, nonFiniteColumns...)
+}
+
+// nullableColumns makes the Float64 columns of the CREATE TABLE statement ddl Nullable.
+func nullableColumns(ddl string, columns ...string) string {
+	return RewriteColumns(ddl, func(col *ColumnDef) {
+		if col.Type == "Float64" && slices.Contains(columns, col.Name) {
+			col.Type = "Nullable(Float64)"
+		}
+	})
+}
@@ -0,0 +1,41 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package internal
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNonFinitePolicy(t *testing.T) {
+	nan, inf := math.NaN(), math.Inf(1)
+	for _, policy := range []NonFinitePolicy{"", NonFiniteKeep, NonFiniteDrop, NonFiniteClamp, NonFiniteNull} {
+		value, ok := policy.apply(1.5)
+		require.True(t, ok)
+		require.Equal(t, 1.5, value, "finite values are kept by %q", policy)
+	}
+
+	value, ok := NonFiniteKeep.apply(inf)
+	require.True(t, ok)
+	require.Equal(t, inf, value)
+
+	_, ok = NonFiniteDrop.apply(nan)
+	require.False(t, ok)
+	_, ok = NonFiniteDrop.applyAll(1, 2, -inf)
+	require.False(t, ok, "a single non-finite value drops the datapoint")
+
+	values, ok := NonFiniteClamp.applyAll(inf, -inf, nan)
+	require.True(t, ok)
+	require.Equal(t, []any{math.MaxFloat64, -math.MaxFloat64, 0.0}, values)
+
+	values, ok = NonFiniteNull.applyAll(1, nan)
+	require.True(t, ok)
+	require.Equal(t, []any{1.0, nil}, values)
+
+	ddl := "CREATE TABLE t (\n\tValue Float64 CODEC(ZSTD(1)),\n\tCount UInt64 CODEC(Delta, ZSTD(1)),\n\tMin Float64\n) ENGINE = MergeTree;"
+	require.Equal(t, ddl, NonFiniteClamp.tableDDL(ddl))
+	require.Equal(t, "CREATE TABLE t (\n\tValue Nullable(Float64) CODEC(ZSTD(1)),\n\tCount UInt64 CODEC(Delta, ZSTD(1)),\n\tMin Nullable(Float64)\n) ENGINE = MergeTree;", NonFiniteNull.tableDDL(ddl))
+}
@@ -0,0 +1,75 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package internal // import "github.com/foyer-work/otel-distribution/exporter/clickhouse/internal"
+
+import (
+	"context"
+	"sync"
+)
+
+// OnceMap holds values created on first use, once per key: the concurrent users of a key wait for the creation
+// in flight instead of running their own. Failed creations are forgotten, the next use of their key retrying.
+// The zero value is ready to use.
+type OnceMap[V any] struct {
+	mu      sync.Mutex
+	entries map[string]*onceEntry[V]
+}
+
+type onceEntry[V any] struct {
+	done  chan struct{}
+	value V
+	err   error
+}
+
+// Get returns the value of key, calling create if there is none nor creation in flight. Waiting for the
+// creation of another user returns early with the error of ctx when it is done.
+func (m *OnceMap[V]) Get(ctx context.Context, key string, create func() (V, error)) (V, error) {
+	m.mu.Lock()
+	entry, ok := m.entries[key]
+	if !ok {
+		if m.entries == nil {
+			m.entries = map[string]*onceEntry[V]{}
+		}
+		entry = &onceEntry[V]{done: make(chan struct{})}
+		m.entries[key] = entry
+	}
+	m.mu.Unlock()
+
+	if !ok {
+		entry.value, entry.err = create()
+		if entry.err != nil {
+			m.mu.Lock()
+			delete(m.entries, key)
+			m.mu.Unlock()
+		}
+		close(entry.done)
+		return entry.value, entry.err
+	}
+	select {
+	case <-entry.done:
+		return entry.value, entry.err
+	case <-ctx.Done():
+		var zero V
+		return zero, ctx.Err()
+	}
+}
+
+// Range calls fn with the values created, in no particular order, until fn returns false.
+func (m *OnceMap[V]) Range(fn func(key string, value V) bool) {
+	m.mu.Lock()
+	entries := make(map[string]*onceEntry[V], len(m.entries))
+	for key, entry := range m.entries {
+		entries[key] = entry
+	}
+	m.mu.Unlock()
+	for key, entry := range entries {
+		select {
+		case <-entry.done:
+			if entry.err == nil && !fn(key, entry.value) {
+				return
+			}
+		default:
+		}
+	}
+}
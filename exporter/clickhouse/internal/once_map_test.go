@@ -0,0 +1,76 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package internal
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOnceMap(t *testing.T) {
+	var m OnceMap[int]
+	var creations atomic.Int32
+	release := make(chan struct{})
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			value, err := m.Get(context.Background(), "acme", func() (int, error) {
+				creations.Add(1)
+				<-release
+				return 42, nil
+			})
+			require.NoError(t, err)
+			require.Equal(t, 42, value)
+		}()
+	}
+	close(release)
+	wg.Wait()
+	require.Equal(t, int32(1), creations.Load())
+
+	_, err := m.Get(context.Background(), "globex", func() (int, error) {
+		return 0, errors.New("unavailable")
+	})
+	require.EqualError(t, err, "unavailable")
+	value, err := m.Get(context.Background(), "globex", func() (int, error) {
+		return 7, nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, 7, value)
+
+	values := map[string]int{}
+	m.Range(func(key string, value int) bool {
+		values[key] = value
+		return true
+	})
+	require.Equal(t, map[string]int{"acme": 42, "globex": 7}, values)
+}
+
+func TestOnceMap_cancel(t *testing.T) {
+	var m OnceMap[int]
+	release := make(chan struct{})
+	created := make(chan struct{})
+	go func() {
+		_, _ = m.Get(context.Background(), "acme", func() (int, error) {
+			close(created)
+			<-release
+			return 42, nil
+		})
+	}()
+	<-created
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := m.Get(ctx, "acme", func() (int, error) {
+		t.Fatal("created twice")
+		return 0, nil
+	})
+	require.ErrorIs(t, err, context.Canceled)
+	close(release)
+}
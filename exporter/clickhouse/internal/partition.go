@@ -0,0 +1,54 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package internal // import "github.com/foyer-work/otel-distribution/exporter/clickhouse/internal"
+
+import (
+	"fmt"
+	"time"
+)
+
+// PartitionGranularity is the time span covered by a table partition.
+type PartitionGranularity string
+
+const (
+	PartitionHourly  PartitionGranularity = "hourly"
+	PartitionDaily   PartitionGranularity = "daily"
+	PartitionWeekly  PartitionGranularity = "weekly"
+	PartitionMonthly PartitionGranularity = "monthly"
+)
+
+// PartitionExpr returns the PARTITION BY expression of the time column for granularity.
+// An empty granularity means daily partitions.
+func PartitionExpr(granularity PartitionGranularity, column string) string {
+	switch granularity {
+	case PartitionHourly:
+		return fmt.Sprintf("toStartOfHour(%s)", column)
+	case PartitionWeekly:
+		return fmt.Sprintf("toMonday(%s)", column)
+	case PartitionMonthly:
+		return fmt.Sprintf("toYYYYMM(%s)", column)
+	default:
+		return fmt.Sprintf("toDate(%s)", column)
+	}
+}
+
+// TenantPartitionExpr returns the PARTITION BY expression of the time column for granularity prefixed by
+// tenantColumn, so that the data of a tenant is deleted by dropping its partitions.
+func TenantPartitionExpr(granularity PartitionGranularity, tenantColumn, column string) string {
+	return fmt.Sprintf("(%s, %s)", tenantColumn, PartitionExpr(granularity, column))
+}
+
+// Duration returns the longest time span covered by a partition of granularity.
+func (g PartitionGranularity) Duration() time.Duration {
+	switch g {
+	case PartitionHourly:
+		return time.Hour
+	case PartitionWeekly:
+		return 7 * 24 * time.Hour
+	case PartitionMonthly:
+		return 31 * 24 * time.Hour
+	default:
+		return 24 * time.Hour
+	}
+}
@@ -0,0 +1,31 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPartitionExpr(t *testing.T) {
+	require.Equal(t, "toDate(TimeUnix)", PartitionExpr("", "TimeUnix"))
+	require.Equal(t, "toStartOfHour(TimeUnix)", PartitionExpr(PartitionHourly, "TimeUnix"))
+	require.Equal(t, "toDate(TimeUnix)", PartitionExpr(PartitionDaily, "TimeUnix"))
+	require.Equal(t, "toMonday(TimeUnix)", PartitionExpr(PartitionWeekly, "TimeUnix"))
+	require.Equal(t, "toYYYYMM(TimeUnix)", PartitionExpr(PartitionMonthly, "TimeUnix"))
+}
+
+func TestTenantPartitionExpr(t *testing.T) {
+	require.Equal(t, "(Tenant, toDate(TimeUnix))", TenantPartitionExpr("", "Tenant", "TimeUnix"))
+	require.Equal(t, "(TenantId, toYYYYMM(Timestamp))", TenantPartitionExpr(PartitionMonthly, "TenantId", "Timestamp"))
+}
+
+func TestPartitionGranularity_Duration(t *testing.T) {
+	require.Equal(t, 24*time.Hour, PartitionGranularity("").Duration())
+	require.Equal(t, time.Hour, PartitionHourly.Duration())
+	require.Equal(t, 7*24*time.Hour, PartitionWeekly.Duration())
+	require.Equal(t, 31*24*time.Hour, PartitionMonthly.Duration())
+}
@@ -0,0 +1,44 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package internal // import "github.com/foyer-work/otel-distribution/exporter/clickhouse/internal"
+
+import (
+	"context"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/google/uuid"
+)
+
+type (
+	queryIDPrefixKey struct{}
+	queryIDKey       struct{}
+)
+
+// WithQueryIDPrefix returns a copy of ctx carrying the query_id prefix used by QueryContext.
+func WithQueryIDPrefix(ctx context.Context, prefix string) context.Context {
+	return context.WithValue(ctx, queryIDPrefixKey{}, prefix)
+}
+
+// QueryContext returns a copy of ctx tagging the query run with it with the query_id
+// `<prefix>-<operation>-<uuid>`, so that it can be found in system.query_log.
+// ctx is returned unchanged if it carries no prefix.
+func QueryContext(ctx context.Context, operation string) context.Context {
+	prefix, _ := ctx.Value(queryIDPrefixKey{}).(string)
+	if prefix == "" {
+		return ctx
+	}
+	queryID := NewQueryID(prefix, operation)
+	return clickhouse.Context(context.WithValue(ctx, queryIDKey{}, queryID), clickhouse.WithQueryID(queryID))
+}
+
+// QueryID returns the query_id ctx was tagged with by QueryContext, empty if none.
+func QueryID(ctx context.Context) string {
+	queryID, _ := ctx.Value(queryIDKey{}).(string)
+	return queryID
+}
+
+// NewQueryID returns a unique query_id starting with the deterministic `<prefix>-<operation>-`.
+func NewQueryID(prefix, operation string) string {
+	return prefix + "-" + operation + "-" + uuid.NewString()
+}
@@ -0,0 +1,25 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package internal
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryContext(t *testing.T) {
+	ctx := context.Background()
+	require.Equal(t, ctx, QueryContext(ctx, "insert_logs"))
+	require.Empty(t, QueryID(ctx))
+
+	ctx = WithQueryIDPrefix(ctx, "otelcol-a")
+	require.NotEqual(t, ctx, QueryContext(ctx, "insert_logs"))
+	require.Regexp(t, `^otelcol-a-insert_logs-`, QueryID(QueryContext(ctx, "insert_logs")))
+
+	id := NewQueryID("otelcol-a", "insert_logs")
+	require.Regexp(t, `^otelcol-a-insert_logs-[0-9a-f-]{36}$`, id)
+	require.NotEqual(t, id, NewQueryID("otelcol-a", "insert_logs"))
+}
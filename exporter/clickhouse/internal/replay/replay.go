@@ -0,0 +1,258 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package replay // import "github.com/foyer-work/otel-distribution/exporter/clickhouse/internal/replay"
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.uber.org/zap"
+
+	"github.com/foyer-work/otel-distribution/exporter/clickhouse/internal"
+)
+
+const (
+	// deadLetterFileExt is the extension of the files of the dead letter queue, named
+	// `<write time in nanoseconds>-<signal>.pb` and holding a protobuf batch.
+	deadLetterFileExt = ".pb"
+	// jsonFileExt is the extension of the files of the file exporter, holding a JSON batch per line.
+	jsonFileExt = ".json"
+)
+
+// maxJSONLineBytes bounds the JSON batches read from a line.
+const maxJSONLineBytes = 64 << 20
+
+// Options configures a Replayer.
+type Options struct {
+	// Rate is the maximum number of log records, spans and datapoints replayed per second. 0 means no limit.
+	Rate float64
+	// Delete removes each file once all its batches are replayed.
+	Delete bool
+}
+
+// Consumers are the exporters the batches of each signal are replayed through, nil if the signal isn't replayed.
+type Consumers struct {
+	Logs    consumer.Logs
+	Traces  consumer.Traces
+	Metrics consumer.Metrics
+}
+
+// batch is a batch of a file, decoded by replaying it.
+type batch struct {
+	signal string
+	body   []byte
+	json   bool
+}
+
+// Replayer replays the batches of dead letter queue files and file exporter JSON files through the exporters,
+// each insert carrying a deduplication token derived from the content of its batch, so that the inserts done
+// by an interrupted or repeated replay are skipped by the tables deduplicating inserts.
+type Replayer struct {
+	consumers Consumers
+	logger    *zap.Logger
+	opts      Options
+
+	start    time.Time
+	replayed int
+}
+
+var errUnknownSignal = errors.New("unknown signal")
+
+// NewReplayer creates a Replayer.
+func NewReplayer(consumers Consumers, logger *zap.Logger, opts Options) *Replayer {
+	return &Replayer{consumers: consumers, logger: logger, opts: opts}
+}
+
+// Run replays the files of paths in order, the files of a directory in the order of their names, which is the
+// order the dead letter queue wrote them in.
+func (r *Replayer) Run(ctx context.Context, paths []string) error {
+	files, err := listFiles(paths)
+	if err != nil {
+		return err
+	}
+	r.start = time.Now()
+	for _, file := range files {
+		if err := r.replayFile(ctx, file); err != nil {
+			return fmt.Errorf("replay %s: %w", file, err)
+		}
+	}
+	return nil
+}
+
+// listFiles returns the files of paths, replacing the directories by their dead letter queue and JSON files.
+func listFiles(paths []string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() && (strings.HasSuffix(entry.Name(), deadLetterFileExt) || strings.HasSuffix(entry.Name(), jsonFileExt)) {
+				files = append(files, filepath.Join(path, entry.Name()))
+			}
+		}
+	}
+	return files, nil
+}
+
+// replayFile replays the batches of file, removing it afterwards if configured.
+func (r *Replayer) replayFile(ctx context.Context, file string) error {
+	batches, err := readBatches(file)
+	if err != nil {
+		return err
+	}
+	for i, b := range batches {
+		records, err := r.replayBatch(ctx, b)
+		if err != nil {
+			return fmt.Errorf("batch %d: %w", i+1, err)
+		}
+		r.logger.Info("batch replayed", zap.String("file", file), zap.Int("batch", i+1), zap.String("signal", b.signal), zap.Int("records", records))
+	}
+	if r.opts.Delete {
+		return os.Remove(file)
+	}
+	return nil
+}
+
+// readBatches reads the protobuf batch of a dead letter queue file, or the JSON batch of each line of a file
+// exporter file.
+func readBatches(file string) ([]batch, error) {
+	if strings.HasSuffix(file, deadLetterFileExt) {
+		body, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		// The signal follows the write time in the file name.
+		_, signal, _ := strings.Cut(strings.TrimSuffix(filepath.Base(file), deadLetterFileExt), "-")
+		return []batch{{signal: signal, body: body}}, nil
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	var batches []batch
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, maxJSONLineBytes)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		signal, err := jsonSignal(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", len(batches)+1, err)
+		}
+		batches = append(batches, batch{signal: signal, body: slices.Clone(line), json: true})
+	}
+	return batches, scanner.Err()
+}
+
+// jsonSignal returns the signal of a JSON batch from its top level key.
+func jsonSignal(line []byte) (string, error) {
+	var keys map[string]json.RawMessage
+	if err := json.Unmarshal(line, &keys); err != nil {
+		return "", err
+	}
+	for key, signal := range map[string]string{"resourceLogs": "logs", "resourceSpans": "traces", "resourceMetrics": "metrics"} {
+		if _, ok := keys[key]; ok {
+			return signal, nil
+		}
+	}
+	return "", errUnknownSignal
+}
+
+// replayBatch decodes b and replays it through the exporter of its signal, returning its number of records.
+func (r *Replayer) replayBatch(ctx context.Context, b batch) (int, error) {
+	sum := sha256.Sum256(b.body)
+	ctx = internal.WithInsertDeduplication(ctx, "replay-"+hex.EncodeToString(sum[:]))
+	switch {
+	case b.signal == "logs" && r.consumers.Logs != nil:
+		var unmarshaler plog.Unmarshaler = &plog.ProtoUnmarshaler{}
+		if b.json {
+			unmarshaler = &plog.JSONUnmarshaler{}
+		}
+		ld, err := unmarshaler.UnmarshalLogs(b.body)
+		if err != nil {
+			return 0, err
+		}
+		if err := r.pace(ctx, ld.LogRecordCount()); err != nil {
+			return 0, err
+		}
+		return ld.LogRecordCount(), r.consumers.Logs.ConsumeLogs(ctx, ld)
+	case b.signal == "traces" && r.consumers.Traces != nil:
+		var unmarshaler ptrace.Unmarshaler = &ptrace.ProtoUnmarshaler{}
+		if b.json {
+			unmarshaler = &ptrace.JSONUnmarshaler{}
+		}
+		td, err := unmarshaler.UnmarshalTraces(b.body)
+		if err != nil {
+			return 0, err
+		}
+		if err := r.pace(ctx, td.SpanCount()); err != nil {
+			return 0, err
+		}
+		return td.SpanCount(), r.consumers.Traces.ConsumeTraces(ctx, td)
+	case b.signal == "metrics" && r.consumers.Metrics != nil:
+		var unmarshaler pmetric.Unmarshaler = &pmetric.ProtoUnmarshaler{}
+		if b.json {
+			unmarshaler = &pmetric.JSONUnmarshaler{}
+		}
+		md, err := unmarshaler.UnmarshalMetrics(b.body)
+		if err != nil {
+			return 0, err
+		}
+		if err := r.pace(ctx, md.DataPointCount()); err != nil {
+			return 0, err
+		}
+		return md.DataPointCount(), r.consumers.Metrics.ConsumeMetrics(ctx, md)
+	}
+	return 0, fmt.Errorf("%w %q, or its exporter is disabled", errUnknownSignal, b.signal)
+}
+
+// pace waits until replaying records more records keeps the replay within the configured rate since it started.
+func (r *Replayer) pace(ctx context.Context, records int) error {
+	if r.opts.Rate <= 0 {
+		return nil
+	}
+	wait := time.Until(r.start.Add(time.Duration(float64(r.replayed) / r.opts.Rate * float64(time.Second))))
+	r.replayed += records
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package replay
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.uber.org/zap/zaptest"
+)
+
+func testLogs(n int) plog.Logs {
+	ld := plog.NewLogs()
+	records := ld.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords()
+	for range n {
+		records.AppendEmpty().Body().SetStr("message")
+	}
+	return ld
+}
+
+func writeDeadLetterLogs(t *testing.T, dir, name string, n int) string {
+	body, err := (&plog.ProtoMarshaler{}).MarshalLogs(testLogs(n))
+	require.NoError(t, err)
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(path, body, 0o600))
+	return path
+}
+
+func TestReplayer_Run(t *testing.T) {
+	dir := t.TempDir()
+	writeDeadLetterLogs(t, dir, "00000000000000000002-logs.pb", 2)
+	writeDeadLetterLogs(t, dir, "00000000000000000001-logs.pb", 1)
+
+	td := ptrace.NewTraces()
+	td.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans().AppendEmpty().SetName("span")
+	traces, err := (&ptrace.JSONMarshaler{}).MarshalTraces(td)
+	require.NoError(t, err)
+	md := pmetric.NewMetrics()
+	md.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics().AppendEmpty().SetEmptyGauge().DataPoints().AppendEmpty().SetIntValue(1)
+	metrics, err := (&pmetric.JSONMarshaler{}).MarshalMetrics(md)
+	require.NoError(t, err)
+	jsonFile := filepath.Join(dir, "otlp.json")
+	require.NoError(t, os.WriteFile(jsonFile, append(append(append(traces, '\n'), metrics...), "\n\n"...), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("skipped"), 0o600))
+
+	logs, spans, points := new(consumertest.LogsSink), new(consumertest.TracesSink), new(consumertest.MetricsSink)
+	r := NewReplayer(Consumers{Logs: logs, Traces: spans, Metrics: points}, zaptest.NewLogger(t), Options{Delete: true})
+	require.NoError(t, r.Run(context.Background(), []string{dir}))
+
+	require.Len(t, logs.AllLogs(), 2)
+	require.Equal(t, 1, logs.AllLogs()[0].LogRecordCount(), "the files are replayed in the order of their names")
+	require.Equal(t, 2, logs.AllLogs()[1].LogRecordCount())
+	require.Equal(t, 1, spans.SpanCount())
+	require.Equal(t, 1, points.DataPointCount())
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1, "the replayed files are deleted")
+	require.Equal(t, "notes.txt", entries[0].Name())
+}
+
+func TestReplayer_Run_disabledSignal(t *testing.T) {
+	dir := t.TempDir()
+	path := writeDeadLetterLogs(t, dir, "00000000000000000001-logs.pb", 1)
+
+	r := NewReplayer(Consumers{Traces: new(consumertest.TracesSink)}, zaptest.NewLogger(t), Options{Delete: true})
+	require.ErrorIs(t, r.Run(context.Background(), []string{path}), errUnknownSignal)
+	require.FileExists(t, path, "files failing to replay are kept")
+
+	jsonFile := filepath.Join(dir, "otlp.json")
+	require.NoError(t, os.WriteFile(jsonFile, []byte(`{"resourceProfiles":[]}`), 0o600))
+	require.ErrorIs(t, r.Run(context.Background(), []string{jsonFile}), errUnknownSignal)
+}
+
+func TestReplayer_Run_rate(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"00000000000000000001-logs.pb", "00000000000000000002-logs.pb", "00000000000000000003-logs.pb"} {
+		writeDeadLetterLogs(t, dir, name, 1)
+	}
+
+	logs := new(consumertest.LogsSink)
+	start := time.Now()
+	require.NoError(t, NewReplayer(Consumers{Logs: logs}, zaptest.NewLogger(t), Options{Rate: 20}).Run(context.Background(), []string{dir}))
+	require.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond, "the third record waits for the two first at 20 records per second")
+	require.Equal(t, 3, logs.LogRecordCount())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := NewReplayer(Consumers{Logs: logs}, zaptest.NewLogger(t), Options{Rate: 0.1}).Run(ctx, []string{dir})
+	require.ErrorIs(t, err, context.Canceled)
+}
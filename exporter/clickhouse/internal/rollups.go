@@ -0,0 +1,135 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package internal // import "github.com/foyer-work/otel-distribution/exporter/clickhouse/internal"
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+const (
+	// language=ClickHouse SQL
+	createRollupTableSQL = `
+CREATE TABLE IF NOT EXISTS %s %s (
+	ServiceName LowCardinality(String) CODEC(ZSTD(1)),
+	MetricName String CODEC(ZSTD(1)),
+	MetricUnit String CODEC(ZSTD(1)),
+	Attributes JSON,
+	TimeUnix DateTime CODEC(Delta, ZSTD(1)),
+	Min SimpleAggregateFunction(min, Float64) CODEC(ZSTD(1)),
+	Max SimpleAggregateFunction(max, Float64) CODEC(ZSTD(1)),
+	Sum SimpleAggregateFunction(sum, Float64) CODEC(ZSTD(1)),
+	Count SimpleAggregateFunction(sum, UInt64) CODEC(ZSTD(1)),
+	Last AggregateFunction(argMax, Float64, DateTime64(9)) CODEC(ZSTD(1))
+) ENGINE = %s
+%s
+PARTITION BY %s
+ORDER BY (ServiceName, MetricName, MetricUnit, Attributes, TimeUnix)
+SETTINGS index_granularity=8192, ttl_only_drop_parts = 1;
+`
+	// The datapoint time is renamed in the subquery, as the TimeUnix alias of the
+	// interval would otherwise replace it in the arguments of the aggregate functions.
+	// NULL values, written by NonFiniteNull, are left out.
+	// language=ClickHouse SQL
+	createRollupViewSQL = `
+CREATE MATERIALIZED VIEW IF NOT EXISTS %s %s
+TO %s.%s
+AS SELECT
+	ServiceName,
+	MetricName,
+	MetricUnit,
+	Attributes,
+	toDateTime(toStartOfInterval(Time, INTERVAL %d SECOND)) AS TimeUnix,
+	min(Val) AS Min,
+	max(Val) AS Max,
+	sum(Val) AS Sum,
+	count() AS Count,
+	argMaxState(Val, Time) AS Last
+FROM (
+	SELECT ServiceName, MetricName, MetricUnit, Attributes, TimeUnix AS Time, assumeNotNull(Value) AS Val
+	FROM %s.%s
+	WHERE isNotNull(Value)%s
+)
+GROUP BY ServiceName, MetricName, MetricUnit, Attributes, TimeUnix;
+`
+)
+
+// rollupColumnComments describes the columns of the rollup tables.
+var rollupColumnComments = map[string]string{
+	"ServiceName": "Resource attribute service.name",
+	"MetricName":  "Metric.name",
+	"MetricUnit":  "Metric.unit",
+	"Attributes":  "DataPoint.attributes",
+	"TimeUnix":    "Start of the interval of the datapoints",
+	"Min":         "Minimum value of the datapoints in the interval",
+	"Max":         "Maximum value of the datapoints in the interval",
+	"Sum":         "Sum of the values of the datapoints in the interval, e.g. of delta sums",
+	"Count":       "Number of datapoints in the interval",
+	"Last":        "Value of the last datapoint in the interval, e.g. of cumulative sums and gauges, read with argMaxMerge",
+}
+
+// Rollup is a table aggregating the datapoints of a gauge or sum table per interval,
+// filled by a materialized view on the datapoint table.
+type Rollup struct {
+	// Source is the datapoint table.
+	Source string
+	// Table is the rollup table.
+	Table string
+	// View is the materialized view inserting into Table.
+	View string
+	// Interval is the resolution of the rollup, a whole number of seconds.
+	Interval time.Duration
+	// Filter is an optional condition on the rows of Source to aggregate.
+	Filter string
+}
+
+// NewRollup returns the rollup of source at interval, named after source and interval, e.g. otel_metrics_gauge_5m.
+func NewRollup(source string, interval time.Duration) Rollup {
+	table := source + "_" + RollupSuffix(interval)
+	return Rollup{
+		Source:   source,
+		Table:    table,
+		View:     table + "_mv",
+		Interval: interval,
+	}
+}
+
+// RollupSuffix returns the shortest name of interval, e.g. 1m, 90s or 1d.
+func RollupSuffix(interval time.Duration) string {
+	switch {
+	case interval%(24*time.Hour) == 0:
+		return fmt.Sprintf("%dd", interval/(24*time.Hour))
+	case interval%time.Hour == 0:
+		return fmt.Sprintf("%dh", interval/time.Hour)
+	case interval%time.Minute == 0:
+		return fmt.Sprintf("%dm", interval/time.Minute)
+	default:
+		return fmt.Sprintf("%ds", interval/time.Second)
+	}
+}
+
+// NewRollupTables creates the rollup tables with engine, an AggregatingMergeTree, and their materialized views.
+func NewRollupTables(ctx context.Context, rollups []Rollup, settings MetricsSettings, database, cluster, engine, ttlExpr string, db *sql.DB) error {
+	partitionBy := PartitionExpr(settings.PartitionBy, "TimeUnix")
+	quotedDatabase := QuoteIdentifier(database)
+	for _, rollup := range rollups {
+		query := fmt.Sprintf(createRollupTableSQL, QuoteIdentifier(rollup.Table), cluster, engine, ttlExpr, partitionBy)
+		query = settings.tableDDL(rollup.Table, query, rollupColumnComments)
+		if _, err := db.ExecContext(QueryContext(ctx, "create_table"), query); err != nil {
+			return fmt.Errorf("exec create rollup table sql: %w", err)
+		}
+		var filter string
+		if rollup.Filter != "" {
+			filter = " AND " + rollup.Filter
+		}
+		query = fmt.Sprintf(createRollupViewSQL, QuoteIdentifier(rollup.View), cluster, quotedDatabase, QuoteIdentifier(rollup.Table),
+			rollup.Interval/time.Second, quotedDatabase, QuoteIdentifier(rollup.Source), filter)
+		if _, err := db.ExecContext(QueryContext(ctx, "create_view"), query); err != nil {
+			return fmt.Errorf("exec create rollup view sql: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package internal // import "github.com/foyer-work/otel-distribution/exporter/clickhouse/internal"
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+type (
+	skippedRowsKey struct{}
+	insertRowsKey  struct{}
+)
+
+// errRowSkipped fails an insert attempt on a row failing to bind, the attempt being retried without it.
+var errRowSkipped = errors.New("row skipped")
+
+// SkippedRows counts the rows ExecRow skipped.
+type SkippedRows struct {
+	mu    sync.Mutex
+	count int64
+	err   error
+}
+
+// Count returns the number of skipped rows and the error of the first one.
+func (s *SkippedRows) Count() (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.count, s.err
+}
+
+func (s *SkippedRows) add(count int, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.count += int64(count)
+	if s.err == nil {
+		s.err = err
+	}
+}
+
+// insertRows numbers the rows appended by an attempt of an insert, and holds those failing to bind in the
+// previous attempts.
+type insertRows struct {
+	next    int
+	skipped map[int]bool
+	err     error
+}
+
+// WithSkippedRows returns a copy of ctx on which ExecRow skips the rows failing to bind, counting them in skipped.
+func WithSkippedRows(ctx context.Context, skipped *SkippedRows) context.Context {
+	return context.WithValue(ctx, skippedRowsKey{}, skipped)
+}
+
+// RetrySkippedRows runs the insert attempt, appending the rows of a batch and sending it, until it succeeds
+// without appending a row failing to bind. A row failing to bind invalidates the whole batch of the driver,
+// so if ctx carries SkippedRows, ExecRow fails the attempt on it and the insert is attempted again with a
+// new batch, without the rows failing so far. The skipped rows are counted once the insert succeeds.
+func RetrySkippedRows(ctx context.Context, attempt func(ctx context.Context) error) error {
+	skipped, ok := ctx.Value(skippedRowsKey{}).(*SkippedRows)
+	if !ok {
+		return attempt(ctx)
+	}
+	rows := &insertRows{skipped: map[int]bool{}}
+	ctx = context.WithValue(ctx, insertRowsKey{}, rows)
+	for {
+		rows.next = 0
+		if stats, ok := ctx.Value(insertStatsKey{}).(*insertStats); ok {
+			stats.reset()
+		}
+		err := attempt(ctx)
+		if errors.Is(err, errRowSkipped) {
+			continue
+		}
+		if err == nil && len(rows.skipped) != 0 {
+			skipped.add(len(rows.skipped), rows.err)
+		}
+		return err
+	}
+}
+
+// ExecRow appends a row to the batch of the prepared INSERT statement.
+// Appending only converts the row to the column types, so a failure is specific to the row
+// and, if ctx carries SkippedRows, the row is skipped by the next attempt of the insert run by
+// RetrySkippedRows instead of failing it. Rows rejected by the server, e.g. by a constraint,
+// fail the whole batch when it is sent.
+// The appended rows are counted for the insert observed on ctx by ObserveInsert.
+func ExecRow(ctx context.Context, statement *sql.Stmt, args ...any) (sql.Result, error) {
+	rows, skipping := ctx.Value(insertRowsKey{}).(*insertRows)
+	var row int
+	if skipping {
+		row = rows.next
+		rows.next++
+		if rows.skipped[row] {
+			return nil, nil
+		}
+	}
+	result, err := statement.ExecContext(ctx, args...)
+	if err == nil {
+		if stats, ok := ctx.Value(insertStatsKey{}).(*insertStats); ok {
+			stats.add(args)
+		}
+		return result, nil
+	}
+	if !skipping {
+		return nil, err
+	}
+	rows.skipped[row] = true
+	if rows.err == nil {
+		rows.err = err
+	}
+	return nil, fmt.Errorf("%w: %w", errRowSkipped, err)
+}
+
+// RowSkipped reports whether the next row appended by ExecRow on ctx is skipped, having failed to bind in a
+// previous attempt of the insert.
+func RowSkipped(ctx context.Context) bool {
+	rows, ok := ctx.Value(insertRowsKey{}).(*insertRows)
+	return ok && rows.skipped[rows.next]
+}
+
+// SkippingRows reports whether ExecRow skips the rows failing to bind on ctx.
+func SkippingRows(ctx context.Context) bool {
+	_, ok := ctx.Value(skippedRowsKey{}).(*SkippedRows)
+	return ok
+}
@@ -0,0 +1,60 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package internal // import "github.com/foyer-work/otel-distribution/exporter/clickhouse/internal"
+
+import (
+	"regexp"
+	"strings"
+)
+
+// SpanNameIDPlaceholder replaces the path segments of span names holding ids.
+const SpanNameIDPlaceholder = "{id}"
+
+var (
+	uuidRegexp     = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+	longHexRegexp  = regexp.MustCompile(`^[0-9a-fA-F]{16,}$`)
+	digitsIDRegexp = regexp.MustCompile(`^[0-9]+$`)
+)
+
+// SpanNameRule replaces the matches of Pattern in span names with Replacement, which may refer to the
+// submatches of Pattern as in regexp.Regexp.ReplaceAllString.
+type SpanNameRule struct {
+	Pattern     *regexp.Regexp
+	Replacement string
+}
+
+// SpanNameNormalizer removes the ids from span names, keeping their cardinality low.
+type SpanNameNormalizer struct {
+	rules        []SpanNameRule
+	pathSegments bool
+}
+
+// NewSpanNameNormalizer returns a normalizer applying rules in order, then, if pathSegments is set, replacing
+// the '/' separated segments made of digits, of a UUID or of at least 16 hex digits with SpanNameIDPlaceholder.
+func NewSpanNameNormalizer(rules []SpanNameRule, pathSegments bool) *SpanNameNormalizer {
+	return &SpanNameNormalizer{rules: rules, pathSegments: pathSegments}
+}
+
+// Normalize returns the normalized name, and whether it differs from name.
+func (n *SpanNameNormalizer) Normalize(name string) (string, bool) {
+	normalized := name
+	for _, rule := range n.rules {
+		normalized = rule.Pattern.ReplaceAllString(normalized, rule.Replacement)
+	}
+	if n.pathSegments && strings.Contains(normalized, "/") {
+		segments := strings.Split(normalized, "/")
+		for i, segment := range segments {
+			// The query string of an URL path ends its last segment.
+			id, rest, _ := strings.Cut(segment, "?")
+			if digitsIDRegexp.MatchString(id) || uuidRegexp.MatchString(id) || longHexRegexp.MatchString(id) {
+				segments[i] = SpanNameIDPlaceholder
+				if rest != "" {
+					segments[i] += "?" + rest
+				}
+			}
+		}
+		normalized = strings.Join(segments, "/")
+	}
+	return normalized, normalized != name
+}
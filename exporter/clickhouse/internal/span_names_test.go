@@ -0,0 +1,33 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package internal
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpanNameNormalizer(t *testing.T) {
+	normalizer := NewSpanNameNormalizer([]SpanNameRule{
+		{Pattern: regexp.MustCompile(`^SELECT .* FROM (\w+).*$`), Replacement: "SELECT $1"},
+	}, true)
+	for name, want := range map[string]string{
+		"GET /api/v1/users/42": "GET /api/v1/users/{id}",
+		"GET /orders/123e4567-e89b-12d3-a456-426614174000/items": "GET /orders/{id}/items",
+		"/blobs/0af7651916cd43dd8448eb211c80319c":                "/blobs/{id}",
+		"/search/7?q=shoes":                        "/search/{id}?q=shoes",
+		"SELECT id, name FROM users WHERE id = 42": "SELECT users",
+		"/v2/cafe": "/v2/cafe",
+	} {
+		got, changed := normalizer.Normalize(name)
+		require.Equal(t, want, got, name)
+		require.Equal(t, want != name, changed, name)
+	}
+
+	got, changed := NewSpanNameNormalizer(nil, false).Normalize("GET /users/42")
+	require.Equal(t, "GET /users/42", got)
+	require.False(t, changed)
+}
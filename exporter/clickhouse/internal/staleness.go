@@ -0,0 +1,68 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package internal // import "github.com/foyer-work/otel-distribution/exporter/clickhouse/internal"
+
+import (
+	"regexp"
+
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+// StalenessPolicy is how the datapoints with the NoRecordedValue flag, the staleness markers of a series, are written.
+type StalenessPolicy string
+
+const (
+	// StaleKeep writes the datapoints unchanged.
+	StaleKeep StalenessPolicy = "keep"
+	// StaleDrop drops the datapoints.
+	StaleDrop StalenessPolicy = "drop"
+	// StaleNull writes NULL values, the Float64 columns of the values being created Nullable.
+	StaleNull StalenessPolicy = "null"
+	// StaleColumn writes the datapoints unchanged, with the IsStale column set.
+	StaleColumn StalenessPolicy = "column"
+)
+
+// isStaleColumn is the definition of the IsStale column, added after the Flags column.
+const isStaleColumn = "IsStale Bool CODEC(ZSTD(1)),"
+
+var flagsColumnRegexp = regexp.MustCompile(`(?m)^(\s*)Flags .*,$`)
+
+// apply returns the value to write for the value v of a datapoint with flags, nil for NULL,
+// false if the datapoint must be dropped.
+func (p StalenessPolicy) apply(flags pmetric.DataPointFlags, nonFinite NonFinitePolicy, v float64) (any, bool) {
+	values, ok := p.applyAll(flags, nonFinite, v)
+	if !ok {
+		return nil, false
+	}
+	return values[0], true
+}
+
+// applyAll returns the values to write for the values of a datapoint with flags, after the nonFinite policy
+// for the datapoints that are not stale, false if the datapoint must be dropped.
+func (p StalenessPolicy) applyAll(flags pmetric.DataPointFlags, nonFinite NonFinitePolicy, values ...float64) ([]any, bool) {
+	if !flags.NoRecordedValue() {
+		return nonFinite.applyAll(values...)
+	}
+	switch p {
+	case StaleDrop:
+		return nil, false
+	case StaleNull:
+		return make([]any, len(values)), true
+	default:
+		return nonFinite.applyAll(values...)
+	}
+}
+
+// tableDDL makes the Float64 value columns of the CREATE TABLE statement ddl Nullable if the policy writes NULL,
+// or adds the IsStale column after the Flags column if the policy sets it.
+func (p StalenessPolicy) tableDDL(ddl string) string {
+	switch p {
+	case StaleNull:
+		return nullableColumns(ddl, nonFiniteColumns...)
+	case StaleColumn:
+		return flagsColumnRegexp.ReplaceAllString(ddl, "$0\n${1}"+isStaleColumn)
+	default:
+		return ddl
+	}
+}
@@ -0,0 +1,37 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package internal
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+func TestStalenessPolicy(t *testing.T) {
+	stale := pmetric.DefaultDataPointFlags.WithNoRecordedValue(true)
+	nan := math.NaN()
+	for _, policy := range []StalenessPolicy{"", StaleKeep, StaleDrop, StaleNull, StaleColumn} {
+		value, ok := policy.apply(pmetric.DefaultDataPointFlags, NonFiniteKeep, 1.5)
+		require.True(t, ok)
+		require.Equal(t, 1.5, value, "datapoints that are not stale are kept by %q", policy)
+	}
+
+	_, ok := StaleDrop.apply(stale, NonFiniteKeep, 0)
+	require.False(t, ok)
+
+	values, ok := StaleNull.applyAll(stale, NonFiniteDrop, nan, 1, 2)
+	require.True(t, ok)
+	require.Equal(t, []any{nil, nil, nil}, values, "the non-finite policy does not apply to stale datapoints written as NULL")
+
+	_, ok = StaleColumn.apply(stale, NonFiniteDrop, nan)
+	require.False(t, ok, "the non-finite policy applies to stale datapoints written unchanged")
+
+	ddl := "CREATE TABLE t (\n\tValue Float64 CODEC(ZSTD(1)),\n\tFlags UInt32 CODEC(ZSTD(1)),\n) ENGINE = MergeTree;"
+	require.Equal(t, ddl, StaleDrop.tableDDL(ddl))
+	require.Equal(t, "CREATE TABLE t (\n\tValue Nullable(Float64) CODEC(ZSTD(1)),\n\tFlags UInt32 CODEC(ZSTD(1)),\n) ENGINE = MergeTree;", StaleNull.tableDDL(ddl))
+	require.Equal(t, "CREATE TABLE t (\n\tValue Float64 CODEC(ZSTD(1)),\n\tFlags UInt32 CODEC(ZSTD(1)),\n\tIsStale Bool CODEC(ZSTD(1)),\n) ENGINE = MergeTree;", StaleColumn.tableDDL(ddl))
+}
@@ -8,6 +8,7 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"strconv"
 	"time"
 
 	"go.opentelemetry.io/collector/pdata/pcommon"
@@ -18,7 +19,7 @@ import (
 const (
 	// language=ClickHouse SQL
 	createSumTableSQL = `
-	CREATE TABLE IF NOT EXISTS %s %s (
+CREATE TABLE IF NOT EXISTS %s %s (
 		ResourceAttributes JSON,
 		ResourceSchemaUrl String CODEC(ZSTD(1)),
 		ScopeName String CODEC(ZSTD(1)),
@@ -35,18 +36,11 @@ const (
 		TimeUnix DateTime64(9) CODEC(Delta, ZSTD(1)),
 		Value Float64 CODEC(ZSTD(1)),
 		Flags UInt32  CODEC(ZSTD(1)),
-		Exemplars Nested (
-			FilteredAttributes JSON,
-			TimeUnix DateTime64(9),
-			Value Float64,
-			SpanId String,
-			TraceId String
-		) CODEC(ZSTD(1)),
-		AggregationTemporality Int32 CODEC(ZSTD(1)),
+%s		AggregationTemporality Int32 CODEC(ZSTD(1)),
 		IsMonotonic Boolean CODEC(Delta, ZSTD(1)),
 ) ENGINE = %s
 %s
-PARTITION BY toDate(TimeUnix)
+PARTITION BY %s
 ORDER BY (ServiceName, MetricName, Attributes, toUnixTimestamp64Nano(TimeUnix))
 SETTINGS index_granularity=8192, ttl_only_drop_parts = 1;
 `
@@ -67,14 +61,9 @@ SETTINGS index_granularity=8192, ttl_only_drop_parts = 1;
     StartTimeUnix,
     TimeUnix,
     Value,
-    Flags,
-    Exemplars.FilteredAttributes,
-	Exemplars.TimeUnix,
-    Exemplars.Value,
-    Exemplars.SpanId,
-    Exemplars.TraceId,
+    Flags%s,
 	AggregationTemporality,
-	IsMonotonic) VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)`
+	IsMonotonic) VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?%s)`
 )
 
 type sumModel struct {
@@ -87,16 +76,49 @@ type sumModel struct {
 
 type sumMetrics struct {
 	sumModel  []*sumModel
+	table     string
 	insertSQL string
 	count     int
+	exemplars *exemplarsWriter
+	// cumulative converts delta sums to cumulative if set.
+	cumulative *DeltaToCumulative
+	nonFinite  NonFinitePolicy
+	staleness  StalenessPolicy
+	attributes *AttributeFilter
+	extra      extraColumns
+}
+
+// sumRows are the datapoints of a sum written by an insert.
+type sumRows struct {
+	model       *sumModel
+	resAttr     string
+	scopeAttr   string
+	temporality pmetric.AggregationTemporality
+	points      []sumPoint
+}
+
+// sumPoint is a datapoint of a sum with its start time and value as written, converted to cumulative if delta.
+type sumPoint struct {
+	dp    pmetric.NumberDataPoint
+	attrs string
+	start pcommon.Timestamp
+	value any
 }
 
 func (s *sumMetrics) insert(ctx context.Context, db *sql.DB) error {
 	if s.count == 0 {
 		return nil
 	}
+	ctx, observe := ObserveInsert(ctx, s.table)
 	start := time.Now()
-	err := doWithTx(ctx, db, func(tx *sql.Tx) error {
+	// The deltas are converted once, every attempt of the insert writing the same values.
+	var conversion *deltaConversion
+	if s.cumulative != nil {
+		conversion = s.cumulative.begin()
+	}
+	rows := s.rows(conversion)
+	err := doWithTx(ctx, db, func(ctx context.Context, tx *sql.Tx) error {
+		s.exemplars.reset()
 		statement, err := tx.PrepareContext(ctx, s.insertSQL)
 		if err != nil {
 			return err
@@ -106,39 +128,38 @@ func (s *sumMetrics) insert(ctx context.Context, db *sql.DB) error {
 			_ = statement.Close()
 		}()
 
-		for _, model := range s.sumModel {
-			resAttr := AttributesToJSON(model.metadata.ResAttr)
-			scopeAttr := AttributesToJSON(model.metadata.ScopeInstr.Attributes())
+		for _, r := range rows {
+			model := r.model
 			serviceName := GetServiceName(model.metadata.ResAttr)
+			extra := s.extra.withFingerprints(model.metadata, r.resAttr, r.scopeAttr)
 
-			for i := range model.sum.DataPoints().Len() {
-				dp := model.sum.DataPoints().At(i)
-				attrs, times, values, traceIDs, spanIDs := convertExemplars(dp.Exemplars())
-				_, err = statement.ExecContext(ctx,
-					resAttr,
+			for _, p := range r.points {
+				dp := p.dp
+				values := []any{
+					extra.attributes(r.resAttr),
 					model.metadata.ResURL,
 					model.metadata.ScopeInstr.Name(),
 					model.metadata.ScopeInstr.Version(),
-					scopeAttr,
+					extra.attributes(r.scopeAttr),
 					model.metadata.ScopeInstr.DroppedAttributesCount(),
 					model.metadata.ScopeURL,
 					serviceName,
 					model.metricName,
 					model.metricDescription,
 					model.metricUnit,
-					AttributesToJSON(dp.Attributes()),
-					dp.StartTimestamp().AsTime(),
+					p.attrs,
+					p.start.AsTime(),
 					dp.Timestamp().AsTime(),
-					getValue(dp.IntValue(), dp.DoubleValue(), dp.ValueType()),
+					p.value,
 					uint32(dp.Flags()),
-					attrs,
-					times,
-					values,
-					spanIDs,
-					traceIDs,
-					int32(model.sum.AggregationTemporality()),
+				}
+				values = append(values, s.exemplars.bind(ctx, serviceName, model.metricName, p.attrs, dp.Timestamp().AsTime(), dp.Exemplars())...)
+				values = append(values,
+					int32(r.temporality),
 					model.sum.IsMonotonic(),
 				)
+				values = extra.bind(values, p.attrs, dp.Flags())
+				_, err = ExecRow(ctx, statement, values...)
 				if err != nil {
 					return fmt.Errorf("ExecContext:%w", err)
 				}
@@ -147,17 +168,64 @@ func (s *sumMetrics) insert(ctx context.Context, db *sql.DB) error {
 		return err
 	})
 	duration := time.Since(start)
+	observe(err)
 	if err != nil {
 		logger.Debug("insert sum metrics fail", zap.Duration("cost", duration))
 		return fmt.Errorf("insert sum metrics fail:%w", err)
 	}
+	if conversion != nil {
+		conversion.commit()
+	}
 
-	// TODO latency metrics
 	logger.Debug("insert sum metrics", zap.Int("records", s.count),
 		zap.Duration("cost", duration))
+	if err := s.exemplars.flush(ctx, db); err != nil {
+		return err
+	}
 	return nil
 }
 
+// rows returns the datapoints written by the insert, without the stale or non-finite ones dropped by the
+// policies, the delta sums converted to cumulative by conversion if set.
+func (s *sumMetrics) rows(conversion *deltaConversion) []sumRows {
+	rows := make([]sumRows, 0, len(s.sumModel))
+	for _, model := range s.sumModel {
+		r := sumRows{
+			model:       model,
+			resAttr:     s.attributes.JSON(model.metadata.ResAttr),
+			scopeAttr:   s.attributes.JSON(model.metadata.ScopeInstr.Attributes()),
+			temporality: model.sum.AggregationTemporality(),
+		}
+		convert := conversion != nil && r.temporality == pmetric.AggregationTemporalityDelta
+		if convert {
+			r.temporality = pmetric.AggregationTemporalityCumulative
+		}
+
+		for i := range model.sum.DataPoints().Len() {
+			dp := model.sum.DataPoints().At(i)
+			attrs := s.attributes.JSON(dp.Attributes())
+			start := dp.StartTimestamp()
+			value, ok := s.staleness.apply(dp.Flags(), s.nonFinite, getValue(dp.IntValue(), dp.DoubleValue(), dp.ValueType()))
+			if !ok {
+				logger.Debug("dropped stale or non-finite sum datapoint", zap.String("metric", model.metricName))
+				continue
+			}
+			// NULL values are written as they are, without adding to the running total.
+			if delta, isValue := value.(float64); convert && isValue {
+				key := newSeriesKey(r.resAttr, model.metadata.ScopeInstr.Name(), model.metadata.ScopeInstr.Version(), r.scopeAttr,
+					model.metricName, model.metricUnit, attrs, strconv.FormatBool(model.sum.IsMonotonic()))
+				if start, value, ok = conversion.convert(key, dp, delta); !ok {
+					logger.Debug("dropped delta sum datapoint older than its series", zap.String("metric", model.metricName))
+					continue
+				}
+			}
+			r.points = append(r.points, sumPoint{dp: dp, attrs: attrs, start: start, value: value})
+		}
+		rows = append(rows, r)
+	}
+	return rows
+}
+
 func (s *sumMetrics) Add(resAttr pcommon.Map, resURL string, scopeInstr pcommon.InstrumentationScope, scopeURL string, metrics any, name string, description string, unit string) error {
 	sum, ok := metrics.(pmetric.Sum)
 	if !ok {
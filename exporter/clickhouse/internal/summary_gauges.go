@@ -0,0 +1,40 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package internal // import "github.com/foyer-work/otel-distribution/exporter/clickhouse/internal"
+
+import (
+	"strconv"
+
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+// QuantileAttribute is the datapoint attribute holding the quantile of the gauges returned by SummaryGauges.
+const QuantileAttribute = "quantile"
+
+// SummaryGauges converts summary to the series of the Prometheus data model: the quantiles gauge has a datapoint
+// per quantile of each summary datapoint with the QuantileAttribute set, e.g. "0.99", the sum and count gauges
+// hold the sum and count of the summary datapoints, to be written as <name>_sum and <name>_count.
+func SummaryGauges(summary pmetric.Summary) (quantiles, sum, count pmetric.Gauge) {
+	quantiles, sum, count = pmetric.NewGauge(), pmetric.NewGauge(), pmetric.NewGauge()
+	for _, dp := range summary.DataPoints().All() {
+		for _, quantile := range dp.QuantileValues().All() {
+			point := newSummaryGaugePoint(quantiles, dp)
+			point.SetDoubleValue(quantile.Value())
+			point.Attributes().PutStr(QuantileAttribute, strconv.FormatFloat(quantile.Quantile(), 'f', -1, 64))
+		}
+		newSummaryGaugePoint(sum, dp).SetDoubleValue(dp.Sum())
+		newSummaryGaugePoint(count, dp).SetIntValue(int64(dp.Count()))
+	}
+	return quantiles, sum, count
+}
+
+// newSummaryGaugePoint appends a datapoint to gauge with the attributes, timestamps and flags of dp.
+func newSummaryGaugePoint(gauge pmetric.Gauge, dp pmetric.SummaryDataPoint) pmetric.NumberDataPoint {
+	point := gauge.DataPoints().AppendEmpty()
+	dp.Attributes().CopyTo(point.Attributes())
+	point.SetStartTimestamp(dp.StartTimestamp())
+	point.SetTimestamp(dp.Timestamp())
+	point.SetFlags(dp.Flags())
+	return point
+}
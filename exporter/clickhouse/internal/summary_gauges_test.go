@@ -0,0 +1,43 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+func TestSummaryGauges(t *testing.T) {
+	summary := pmetric.NewSummary()
+	dp := summary.DataPoints().AppendEmpty()
+	dp.Attributes().PutStr("path", "/")
+	dp.SetTimestamp(pcommon.Timestamp(10))
+	dp.SetCount(4)
+	dp.SetSum(2.5)
+	for _, q := range []struct{ quantile, value float64 }{{0.5, 0.4}, {0.99, 1.2}, {1, 1.5}} {
+		quantile := dp.QuantileValues().AppendEmpty()
+		quantile.SetQuantile(q.quantile)
+		quantile.SetValue(q.value)
+	}
+
+	quantiles, sum, count := SummaryGauges(summary)
+	require.Equal(t, 3, quantiles.DataPoints().Len())
+	for i, want := range []struct {
+		quantile string
+		value    float64
+	}{{"0.5", 0.4}, {"0.99", 1.2}, {"1", 1.5}} {
+		point := quantiles.DataPoints().At(i)
+		require.Equal(t, map[string]any{"path": "/", QuantileAttribute: want.quantile}, point.Attributes().AsRaw())
+		require.Equal(t, want.value, point.DoubleValue())
+		require.Equal(t, pcommon.Timestamp(10), point.Timestamp())
+	}
+	require.Equal(t, 1, sum.DataPoints().Len())
+	require.Equal(t, 2.5, sum.DataPoints().At(0).DoubleValue())
+	require.Equal(t, map[string]any{"path": "/"}, sum.DataPoints().At(0).Attributes().AsRaw())
+	require.Equal(t, 1, count.DataPoints().Len())
+	require.Equal(t, int64(4), count.DataPoints().At(0).IntValue())
+}
@@ -42,7 +42,7 @@ CREATE TABLE IF NOT EXISTS %s %s (
 	Flags UInt32  CODEC(ZSTD(1)),
 ) ENGINE = %s
 %s
-PARTITION BY toDate(TimeUnix)
+PARTITION BY %s
 ORDER BY (ServiceName, MetricName, Attributes, toUnixTimestamp64Nano(TimeUnix))
 SETTINGS index_granularity=8192, ttl_only_drop_parts = 1;
 `
@@ -79,16 +79,21 @@ type summaryModel struct {
 
 type summaryMetrics struct {
 	summaryModel []*summaryModel
+	table        string
 	insertSQL    string
 	count        int
+	staleness    StalenessPolicy
+	attributes   *AttributeFilter
+	extra        extraColumns
 }
 
 func (s *summaryMetrics) insert(ctx context.Context, db *sql.DB) error {
 	if s.count == 0 {
 		return nil
 	}
+	ctx, observe := ObserveInsert(ctx, s.table)
 	start := time.Now()
-	err := doWithTx(ctx, db, func(tx *sql.Tx) error {
+	err := doWithTx(ctx, db, func(ctx context.Context, tx *sql.Tx) error {
 		statement, err := tx.PrepareContext(ctx, s.insertSQL)
 		if err != nil {
 			return err
@@ -97,35 +102,43 @@ func (s *summaryMetrics) insert(ctx context.Context, db *sql.DB) error {
 			_ = statement.Close()
 		}()
 		for _, model := range s.summaryModel {
-			resAttr := AttributesToJSON(model.metadata.ResAttr)
-			scopeAttr := AttributesToJSON(model.metadata.ScopeInstr.Attributes())
+			resAttr := s.attributes.JSON(model.metadata.ResAttr)
+			scopeAttr := s.attributes.JSON(model.metadata.ScopeInstr.Attributes())
 			serviceName := GetServiceName(model.metadata.ResAttr)
+			extra := s.extra.withFingerprints(model.metadata, resAttr, scopeAttr)
 
 			for i := range model.summary.DataPoints().Len() {
 				dp := model.summary.DataPoints().At(i)
-				quantiles, values := convertValueAtQuantile(dp.QuantileValues())
-
-				_, err = statement.ExecContext(ctx,
-					resAttr,
+				sum, ok := s.staleness.apply(dp.Flags(), NonFiniteKeep, dp.Sum())
+				if !ok {
+					logger.Debug("dropped stale summary datapoint", zap.String("metric", model.metricName))
+					continue
+				}
+				quantiles, quantileValues := convertValueAtQuantile(dp.QuantileValues())
+				attrs := s.attributes.JSON(dp.Attributes())
+				values := []any{
+					extra.attributes(resAttr),
 					model.metadata.ResURL,
 					model.metadata.ScopeInstr.Name(),
 					model.metadata.ScopeInstr.Version(),
-					scopeAttr,
+					extra.attributes(scopeAttr),
 					model.metadata.ScopeInstr.DroppedAttributesCount(),
 					model.metadata.ScopeURL,
 					serviceName,
 					model.metricName,
 					model.metricDescription,
 					model.metricUnit,
-					AttributesToJSON(dp.Attributes()),
+					attrs,
 					dp.StartTimestamp().AsTime(),
 					dp.Timestamp().AsTime(),
 					dp.Count(),
-					dp.Sum(),
+					sum,
 					quantiles,
-					values,
+					quantileValues,
 					uint32(dp.Flags()),
-				)
+				}
+				values = extra.bind(values, attrs, dp.Flags())
+				_, err = ExecRow(ctx, statement, values...)
 				if err != nil {
 					return fmt.Errorf("ExecContext:%w", err)
 				}
@@ -135,12 +148,12 @@ func (s *summaryMetrics) insert(ctx context.Context, db *sql.DB) error {
 		return err
 	})
 	duration := time.Since(start)
+	observe(err)
 	if err != nil {
 		logger.Debug("insert summary metrics fail", zap.Duration("cost", duration))
 		return fmt.Errorf("insert summary metrics fail:%w", err)
 	}
 
-	// TODO latency metrics
 	logger.Debug("insert summary metrics", zap.Int("records", s.count),
 		zap.Duration("cost", duration))
 	return nil
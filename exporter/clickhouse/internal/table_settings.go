@@ -0,0 +1,37 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package internal // import "github.com/foyer-work/otel-distribution/exporter/clickhouse/internal"
+
+import (
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+var settingsClauseRegexp = regexp.MustCompile(`(?m)^SETTINGS (.*?)(;?)$`)
+
+// SetTableSettings merges settings into the SETTINGS clause of the CREATE TABLE statement ddl, replacing the
+// value of the settings the clause already has. ddl is returned unchanged if it has no SETTINGS clause.
+func SetTableSettings(ddl string, settings map[string]string) string {
+	loc := settingsClauseRegexp.FindStringSubmatchIndex(ddl)
+	if loc == nil || len(settings) == 0 {
+		return ddl
+	}
+	pending := maps.Clone(settings)
+	var clause []string
+	for _, setting := range strings.Split(ddl[loc[2]:loc[3]], ",") {
+		name, _, _ := strings.Cut(setting, "=")
+		name = strings.TrimSpace(name)
+		if value, ok := pending[name]; ok {
+			setting = " " + name + " = " + value
+			delete(pending, name)
+		}
+		clause = append(clause, strings.TrimSpace(setting))
+	}
+	for _, name := range slices.Sorted(maps.Keys(pending)) {
+		clause = append(clause, name+" = "+pending[name])
+	}
+	return ddl[:loc[2]] + strings.Join(clause, ", ") + ddl[loc[3]:]
+}
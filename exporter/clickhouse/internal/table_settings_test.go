@@ -0,0 +1,31 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package internal
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetTableSettings(t *testing.T) {
+	ddl := fmt.Sprintf(createGaugeTableSQL, "otel_metrics_gauge", "", exemplarsColumnSQL, "MergeTree()", "", "toDate(TimeUnix)")
+	got := SetTableSettings(ddl, map[string]string{
+		"parts_to_throw_insert":          "600",
+		"ttl_only_drop_parts":            "0",
+		"min_age_to_force_merge_seconds": "3600",
+	})
+	require.Contains(t, got, "\nSETTINGS index_granularity=8192, ttl_only_drop_parts = 0, min_age_to_force_merge_seconds = 3600, parts_to_throw_insert = 600;\n")
+
+	require.Equal(t, ddl, SetTableSettings(ddl, nil))
+	require.Equal(t, "CREATE VIEW v AS SELECT 1;", SetTableSettings("CREATE VIEW v AS SELECT 1;", map[string]string{"max_parts_in_total": "1000"}))
+}
+
+func TestColumnOptions_tableSettings(t *testing.T) {
+	ddl := fmt.Sprintf(createGaugeTableSQL, "otel_metrics_gauge", "", exemplarsColumnSQL, "MergeTree()", "", "toDate(TimeUnix)")
+	options := ColumnOptions{TableSettings: map[string]map[string]string{"otel_metrics_sum": {"max_parts_in_total": "1000"}}}
+	require.Equal(t, ddl, options.Apply("otel_metrics_gauge", ddl))
+	require.Contains(t, options.Apply("otel_metrics_sum", ddl), "ttl_only_drop_parts = 1, max_parts_in_total = 1000;")
+}
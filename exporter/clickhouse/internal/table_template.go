@@ -0,0 +1,59 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package internal // import "github.com/foyer-work/otel-distribution/exporter/clickhouse/internal"
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+)
+
+var (
+	tableTemplatePlaceholderRegexp = regexp.MustCompile(`%[YmdHGV]|\{[^{}]+\}`)
+	tableTemplateValueRegexp       = regexp.MustCompile(`[^A-Za-z0-9_]+`)
+)
+
+// TableTemplate is a table name with placeholders resolved per record: `%Y`, `%m`, `%d` and `%H`
+// for the UTC year, month, day and hour of the record timestamp, `%G` and `%V` for its ISO 8601 year
+// and week, and `{attribute}` for the value of a resource attribute.
+type TableTemplate string
+
+// IsTemplate reports whether the table name has placeholders.
+func (t TableTemplate) IsTemplate() bool {
+	return tableTemplatePlaceholderRegexp.MatchString(string(t))
+}
+
+// Render returns the table name for a record with the given timestamp and resource attributes.
+// Attribute values are reduced to letters, digits and `_`, missing or empty ones render as `unknown`.
+func (t TableTemplate) Render(timestamp time.Time, resourceAttrs pcommon.Map) string {
+	timestamp = timestamp.UTC()
+	return tableTemplatePlaceholderRegexp.ReplaceAllStringFunc(string(t), func(placeholder string) string {
+		switch placeholder {
+		case "%Y":
+			return timestamp.Format("2006")
+		case "%m":
+			return timestamp.Format("01")
+		case "%d":
+			return timestamp.Format("02")
+		case "%H":
+			return timestamp.Format("15")
+		case "%G":
+			year, _ := timestamp.ISOWeek()
+			return fmt.Sprintf("%04d", year)
+		case "%V":
+			_, week := timestamp.ISOWeek()
+			return fmt.Sprintf("%02d", week)
+		}
+		value := ""
+		if v, ok := resourceAttrs.Get(placeholder[1 : len(placeholder)-1]); ok {
+			value = tableTemplateValueRegexp.ReplaceAllString(v.AsString(), "_")
+		}
+		if value == "" {
+			return "unknown"
+		}
+		return value
+	})
+}
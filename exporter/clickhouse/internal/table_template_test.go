@@ -0,0 +1,37 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+)
+
+func TestTableTemplate(t *testing.T) {
+	attrs := pcommon.NewMap()
+	attrs.PutStr("deployment.environment", "prod-eu.1")
+	timestamp := time.Date(2024, 3, 7, 21, 30, 0, 0, time.FixedZone("UTC+5", 5*60*60))
+
+	tests := []struct {
+		template   TableTemplate
+		isTemplate bool
+		want       string
+	}{
+		{"otel_logs", false, "otel_logs"},
+		{"otel_logs_%Y%m", true, "otel_logs_202403"},
+		{"otel_logs_%Y%m%d_%H", true, "otel_logs_20240307_16"},
+		{"otel_logs_%Gw%V", true, "otel_logs_2024w10"},
+		{"otel_traces_{deployment.environment}", true, "otel_traces_prod_eu_1"},
+		{"otel_traces_{k8s.cluster.name}", true, "otel_traces_unknown"},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.template), func(t *testing.T) {
+			require.Equal(t, tt.isTemplate, tt.template.IsTemplate())
+			require.Equal(t, tt.want, tt.template.Render(timestamp, attrs))
+		})
+	}
+}
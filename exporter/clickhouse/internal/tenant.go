@@ -0,0 +1,84 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package internal // import "github.com/foyer-work/otel-distribution/exporter/clickhouse/internal"
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"go.opentelemetry.io/collector/client"
+)
+
+// tenantColumnComment describes the tenant column.
+const tenantColumnComment = "Tenant of the client that sent the data, from its authentication or metadata"
+
+var serviceNameColumnRegexp = regexp.MustCompile(`(?m)^(\s*)ServiceName .*,$`)
+
+// ClientTenant returns the tenant of the client that sent the data of ctx, as set by the receiver: the
+// authentication attribute authAttribute if set, else the first value of the metadata key metadataKey.
+// It is empty if the client has neither.
+func ClientTenant(ctx context.Context, authAttribute, metadataKey string) string {
+	info := client.FromContext(ctx)
+	if authAttribute != "" && info.Auth != nil {
+		switch value := info.Auth.GetAttribute(authAttribute).(type) {
+		case nil:
+		case string:
+			return value
+		default:
+			return fmt.Sprint(value)
+		}
+	}
+	if metadataKey != "" {
+		if values := info.Metadata.Get(metadataKey); len(values) > 0 {
+			return values[0]
+		}
+	}
+	return ""
+}
+
+// WithClientTenant returns a copy of ctx whose client has tenant as read by ClientTenant with the same
+// authAttribute and metadataKey, so that data pushed again later is attributed to the client that sent it.
+// ctx is returned unchanged if tenant is empty.
+func WithClientTenant(ctx context.Context, tenant, authAttribute, metadataKey string) context.Context {
+	var info client.Info
+	switch {
+	case tenant == "":
+		return ctx
+	case authAttribute != "":
+		info.Auth = tenantAuthData{attribute: authAttribute, tenant: tenant}
+	default:
+		info.Metadata = client.NewMetadata(map[string][]string{metadataKey: {tenant}})
+	}
+	return client.NewContext(ctx, info)
+}
+
+// tenantAuthData is the authentication data of a client holding only its tenant.
+type tenantAuthData struct {
+	attribute string
+	tenant    string
+}
+
+func (d tenantAuthData) GetAttribute(name string) any {
+	if name == d.attribute {
+		return d.tenant
+	}
+	return nil
+}
+
+func (d tenantAuthData) GetAttributeNames() []string {
+	return []string{d.attribute}
+}
+
+// TenantColumn returns the definition of the tenant column named name.
+func TenantColumn(name string) ColumnDef {
+	return ColumnDef{Name: name, Type: "LowCardinality(String)", Comment: tenantColumnComment, Codec: "ZSTD(1)"}
+}
+
+// AddTenantColumn adds the tenant column named name after the ServiceName column of the CREATE TABLE statement ddl.
+func AddTenantColumn(ddl, name string) string {
+	column := TenantColumn(name)
+	def := fmt.Sprintf("%s %s COMMENT %s CODEC(%s),", column.Name, column.Type, QuoteString(column.Comment), column.Codec)
+	return serviceNameColumnRegexp.ReplaceAllString(ddl, "$0\n${1}"+def)
+}
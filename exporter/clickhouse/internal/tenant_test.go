@@ -0,0 +1,59 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package internal
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/client"
+)
+
+type testAuthData map[string]any
+
+func (d testAuthData) GetAttribute(name string) any {
+	return d[name]
+}
+
+func (d testAuthData) GetAttributeNames() []string {
+	names := make([]string, 0, len(d))
+	for name := range d {
+		names = append(names, name)
+	}
+	return names
+}
+
+func TestClientTenant(t *testing.T) {
+	ctx := client.NewContext(context.Background(), client.Info{
+		Auth:     testAuthData{"subject": "acme", "uid": 42},
+		Metadata: client.NewMetadata(map[string][]string{"x-tenant-id": {"globex", "initech"}}),
+	})
+	require.Equal(t, "acme", ClientTenant(ctx, "subject", "x-tenant-id"))
+	require.Equal(t, "42", ClientTenant(ctx, "uid", ""))
+	require.Equal(t, "globex", ClientTenant(ctx, "tenant", "x-tenant-id"))
+	require.Empty(t, ClientTenant(ctx, "tenant", "x-scope-orgid"))
+	require.Empty(t, ClientTenant(context.Background(), "subject", "x-tenant-id"))
+}
+
+func TestWithClientTenant(t *testing.T) {
+	ctx := WithClientTenant(context.Background(), "acme", "subject", "x-tenant-id")
+	require.Equal(t, "acme", ClientTenant(ctx, "subject", "x-tenant-id"))
+	ctx = WithClientTenant(context.Background(), "acme", "", "x-tenant-id")
+	require.Equal(t, "acme", ClientTenant(ctx, "subject", "x-tenant-id"))
+	require.Equal(t, context.Background(), WithClientTenant(context.Background(), "", "subject", "x-tenant-id"))
+}
+
+func TestAddTenantColumn(t *testing.T) {
+	ddl := fmt.Sprintf(createGaugeTableSQL, "`otel_metrics_gauge`", "", "", "MergeTree()", "", "toDate(TimeUnix)")
+	require.Contains(t, AddTenantColumn(ddl, "Tenant"), "\tServiceName LowCardinality(String) CODEC(ZSTD(1)),\n\tTenant LowCardinality(String) COMMENT '"+tenantColumnComment+"' CODEC(ZSTD(1)),\n")
+
+	settings := MetricsSettings{TenantColumn: "Tenant", Tenant: "acme"}
+	require.Contains(t, settings.insertSQL(fmt.Sprintf(insertSummaryTableSQL, "`otel_metrics_summary`")), "    Flags,\n    Tenant) VALUES (")
+	require.Equal(t, []any{"acme"}, settings.extraColumns(0).bind(nil, "", 0))
+	require.Equal(t, "toDate(TimeUnix)", settings.metricPartitionExpr())
+	settings.TenantPartition = true
+	require.Equal(t, "(Tenant, toDate(TimeUnix))", settings.metricPartitionExpr())
+}
@@ -0,0 +1,49 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package internal // import "github.com/foyer-work/otel-distribution/exporter/clickhouse/internal"
+
+// language=ClickHouse SQL
+const createUnifiedTableSQL = `
+CREATE TABLE IF NOT EXISTS %s %s (
+	ResourceAttributes JSON,
+	ResourceSchemaUrl String CODEC(ZSTD(1)),
+	ScopeName String CODEC(ZSTD(1)),
+	ScopeVersion String CODEC(ZSTD(1)),
+	ScopeAttributes JSON,
+	ScopeDroppedAttrCount UInt32 CODEC(ZSTD(1)),
+	ScopeSchemaUrl String CODEC(ZSTD(1)),
+	ServiceName LowCardinality(String) CODEC(ZSTD(1)),
+	MetricName String CODEC(ZSTD(1)),
+	MetricType LowCardinality(String) CODEC(ZSTD(1)),
+	MetricDescription String CODEC(ZSTD(1)),
+	MetricUnit String CODEC(ZSTD(1)),
+	Attributes JSON,
+	StartTimeUnix DateTime64(9) CODEC(Delta, ZSTD(1)),
+	TimeUnix DateTime64(9) CODEC(Delta, ZSTD(1)),
+	Value Float64 CODEC(ZSTD(1)),
+	Count UInt64 CODEC(Delta, ZSTD(1)),
+	Sum Float64 CODEC(ZSTD(1)),
+	BucketCounts Array(UInt64) CODEC(ZSTD(1)),
+	ExplicitBounds Array(Float64) CODEC(ZSTD(1)),
+	Scale Int32 CODEC(ZSTD(1)),
+	ZeroCount UInt64 CODEC(ZSTD(1)),
+	PositiveOffset Int32 CODEC(ZSTD(1)),
+	PositiveBucketCounts Array(UInt64) CODEC(ZSTD(1)),
+	NegativeOffset Int32 CODEC(ZSTD(1)),
+	NegativeBucketCounts Array(UInt64) CODEC(ZSTD(1)),
+	ValueAtQuantiles Nested(
+		Quantile Float64,
+		Value Float64
+	) CODEC(ZSTD(1)),
+%s	Flags UInt32 CODEC(ZSTD(1)),
+	Min Float64 CODEC(ZSTD(1)),
+	Max Float64 CODEC(ZSTD(1)),
+	AggregationTemporality Int32 CODEC(ZSTD(1)),
+	IsMonotonic Boolean CODEC(Delta, ZSTD(1)),
+) ENGINE = %s
+%s
+PARTITION BY %s
+ORDER BY (ServiceName, MetricName, Attributes, toUnixTimestamp64Nano(TimeUnix))
+SETTINGS index_granularity=8192, ttl_only_drop_parts = 1;
+`
@@ -0,0 +1,211 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package clickhouseexporter // import "github.com/foyer-work/otel-distribution/exporter/clickhouse"
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"go.opentelemetry.io/collector/pdata/ptrace"
+
+	"github.com/foyer-work/otel-distribution/exporter/clickhouse/internal"
+)
+
+// The tables of the jaeger schema are those of the jaeger-clickhouse plugin, Jaeger querying them by name.
+const (
+	// language=ClickHouse SQL
+	createJaegerSpansTableSQL = `
+CREATE TABLE IF NOT EXISTS %s %s (
+	timestamp DateTime CODEC(Delta, ZSTD(1)),
+	traceID String CODEC(ZSTD(1)),
+	model String CODEC(ZSTD(3))
+) ENGINE = %s
+PARTITION BY %s
+ORDER BY traceID
+%s
+SETTINGS index_granularity=1024;
+`
+	// language=ClickHouse SQL
+	createJaegerIndexTableSQL = `
+CREATE TABLE IF NOT EXISTS %s %s (
+	timestamp DateTime CODEC(Delta, ZSTD(1)),
+	traceID String CODEC(ZSTD(1)),
+	service LowCardinality(String) CODEC(ZSTD(1)),
+	operation LowCardinality(String) CODEC(ZSTD(1)),
+	durationUs UInt64 CODEC(ZSTD(1)),
+	tags Nested(
+		key LowCardinality(String),
+		value String
+	) CODEC(ZSTD(1)),
+	INDEX idx_tag_keys tags.key TYPE bloom_filter(0.01) GRANULARITY 64,
+	INDEX idx_duration durationUs TYPE minmax GRANULARITY 1
+) ENGINE = %s
+PARTITION BY %s
+ORDER BY (service, -toUnixTimestamp(timestamp))
+%s
+SETTINGS index_granularity=1024;
+`
+	// language=ClickHouse SQL
+	createJaegerOperationsViewSQL = `
+CREATE MATERIALIZED VIEW IF NOT EXISTS %s %s
+ENGINE = %s
+PARTITION BY toYYYYMM(date)
+ORDER BY (date, service, operation)
+%s
+SETTINGS index_granularity=32
+AS SELECT
+	toDate(timestamp) AS date,
+	service,
+	operation,
+	count() AS count,
+	if(has(tags.key, 'span.kind'), tags.value[indexOf(tags.key, 'span.kind')], '') AS spankind
+FROM %s.%s
+GROUP BY date, service, operation, tags.key, tags.value;
+`
+	// language=ClickHouse SQL
+	insertJaegerSpansSQLTemplate = `INSERT INTO %s (timestamp, traceID, model) VALUES (?, ?, ?)`
+	// language=ClickHouse SQL
+	insertJaegerIndexSQLTemplate = `INSERT INTO %s (timestamp, traceID, service, operation, durationUs, tags.key, tags.value) VALUES (?, ?, ?, ?, ?, ?, ?)`
+)
+
+// jaegerSpansColumnComments describes the columns of the jaeger spans table.
+var jaegerSpansColumnComments = map[string]string{
+	"timestamp": "Span.start_time_unix_nano",
+	"traceID":   "Span.trace_id as the hex of the Jaeger trace id",
+	"model":     "Span as the json of the Jaeger model",
+}
+
+// jaegerIndexColumnComments describes the columns of the jaeger index table.
+var jaegerIndexColumnComments = map[string]string{
+	"timestamp":  "Span.start_time_unix_nano",
+	"traceID":    "Span.trace_id as the hex of the Jaeger trace id",
+	"service":    "Resource attribute service.name",
+	"operation":  "Span.name",
+	"durationUs": "Span.end_time_unix_nano - Span.start_time_unix_nano in microseconds",
+	"tags":       "Distinct tags of the Jaeger span, its process and its logs",
+}
+
+// createJaegerTables creates the tables of the jaeger traces schema.
+func createJaegerTables(ctx context.Context, cfg *Config, db *sql.DB) error {
+	objects := cfg.schemaObjectsFor(cfg.Traces.SignalConfig)
+	if objects.Tables {
+		if _, err := db.ExecContext(internal.QueryContext(ctx, "create_table"), objects.tableDDL(renderCreateJaegerSpansTableSQL(cfg))); err != nil {
+			return fmt.Errorf("exec create jaeger spans table sql: %w", err)
+		}
+		if _, err := db.ExecContext(internal.QueryContext(ctx, "create_table"), objects.tableDDL(renderCreateJaegerIndexTableSQL(cfg))); err != nil {
+			return fmt.Errorf("exec create jaeger index table sql: %w", err)
+		}
+		ttlExpr := generateTTLExpr(cfg.TTL, "timestamp")
+		for _, table := range []string{cfg.jaegerSpansTableName(), cfg.jaegerIndexTableName()} {
+			if err := updateTTL(ctx, cfg, db, cfg.Traces.SignalConfig, table, ttlExpr); err != nil {
+				return err
+			}
+		}
+	}
+	if objects.MaterializedViews {
+		if _, err := db.ExecContext(internal.QueryContext(ctx, "create_view"), renderJaegerOperationsViewSQL(cfg)); err != nil {
+			return fmt.Errorf("exec create jaeger operations view sql: %w", err)
+		}
+	}
+	return addProjections(ctx, cfg, db, cfg.Traces.SignalConfig, cfg.jaegerSpansTableName(), cfg.jaegerIndexTableName())
+}
+
+func renderCreateJaegerSpansTableSQL(cfg *Config) string {
+	ddl := fmt.Sprintf(createJaegerSpansTableSQL, internal.QuoteIdentifier(cfg.jaegerSpansTableName()), cfg.clusterStringFor(cfg.Traces.SignalConfig), cfg.tableEngineStringFor(cfg.Traces.SignalConfig), internal.PartitionExpr(cfg.partitionByFor(cfg.Traces.SignalConfig), "timestamp"), generateTTLExpr(cfg.TTL, "timestamp"))
+	return cfg.columnOptions().Apply(cfg.jaegerSpansTableName(), internal.CommentColumns(ddl, jaegerSpansColumnComments))
+}
+
+func renderCreateJaegerIndexTableSQL(cfg *Config) string {
+	ddl := fmt.Sprintf(createJaegerIndexTableSQL, internal.QuoteIdentifier(cfg.jaegerIndexTableName()), cfg.clusterStringFor(cfg.Traces.SignalConfig), cfg.tableEngineStringFor(cfg.Traces.SignalConfig), internal.PartitionExpr(cfg.partitionByFor(cfg.Traces.SignalConfig), "timestamp"), generateTTLExpr(cfg.TTL, "timestamp"))
+	return cfg.columnOptions().Apply(cfg.jaegerIndexTableName(), internal.CommentColumns(ddl, jaegerIndexColumnComments))
+}
+
+func renderJaegerOperationsViewSQL(cfg *Config) string {
+	engine, _ := cfg.mergeTreeVariantFor(cfg.Traces.SignalConfig, "Summing")
+	return fmt.Sprintf(createJaegerOperationsViewSQL, internal.QuoteIdentifier(cfg.jaegerOperationsTableName()), cfg.clusterStringFor(cfg.Traces.SignalConfig),
+		engine, generateTTLExpr(cfg.TTL, "date"), internal.QuoteIdentifier(cfg.Database), internal.QuoteIdentifier(cfg.jaegerIndexTableName()))
+}
+
+func renderInsertJaegerSpansSQL(cfg *Config) string {
+	return fmt.Sprintf(insertJaegerSpansSQLTemplate, internal.QuoteIdentifier(cfg.jaegerSpansTableName()))
+}
+
+func renderInsertJaegerIndexSQL(cfg *Config) string {
+	return fmt.Sprintf(insertJaegerIndexSQLTemplate, internal.QuoteIdentifier(cfg.jaegerIndexTableName()))
+}
+
+// pushJaegerSpans writes the spans into the spans and index tables of the jaeger schema, each in its own transaction.
+func (e *tracesExporter) pushJaegerSpans(ctx context.Context, td ptrace.Traces) error {
+	ctx = e.cfg.queryContext(ctx)
+	attributes := e.cfg.attributeFilter(e.cfg.Traces.SignalConfig)
+	var spans []internal.JaegerSpan
+	for i := range td.ResourceSpans().Len() {
+		rs := td.ResourceSpans().At(i)
+		for j := range rs.ScopeSpans().Len() {
+			ss := rs.ScopeSpans().At(j)
+			for k := range ss.Spans().Len() {
+				spans = append(spans, internal.NewJaegerSpan(rs.Resource(), ss.Scope(), ss.Spans().At(k), attributes))
+			}
+		}
+	}
+
+	spansCtx, observe := internal.ObserveInsert(internal.InsertContext(ctx, "insert_spans"), e.cfg.jaegerSpansTableName())
+	err := doWithTx(spansCtx, e.client, func(spansCtx context.Context, tx *sql.Tx) error {
+		statement, err := tx.PrepareContext(spansCtx, e.insertSQL)
+		if err != nil {
+			return fmt.Errorf("PrepareContext:%w", err)
+		}
+		defer func() {
+			_ = statement.Close()
+		}()
+		for _, span := range spans {
+			model, err := span.Marshal()
+			if err != nil {
+				return fmt.Errorf("marshal jaeger span: %w", err)
+			}
+			if _, err := internal.ExecRow(spansCtx, statement, span.StartTime, span.TraceIDString(), string(model)); err != nil {
+				return fmt.Errorf("ExecContext:%w", err)
+			}
+		}
+		return nil
+	})
+	observe(err)
+	if err != nil {
+		return fmt.Errorf("insert jaeger spans: %w", err)
+	}
+
+	indexCtx, observe := internal.ObserveInsert(internal.InsertContext(ctx, "insert_span_index"), e.cfg.jaegerIndexTableName())
+	err = doWithTx(indexCtx, e.client, func(indexCtx context.Context, tx *sql.Tx) error {
+		statement, err := tx.PrepareContext(indexCtx, e.insertIndexSQL)
+		if err != nil {
+			return fmt.Errorf("PrepareContext:%w", err)
+		}
+		defer func() {
+			_ = statement.Close()
+		}()
+		for _, span := range spans {
+			keys, values := span.IndexTags()
+			_, err := internal.ExecRow(indexCtx, statement,
+				span.StartTime,
+				span.TraceIDString(),
+				span.Process.ServiceName,
+				span.OperationName,
+				uint64(span.Duration.Microseconds()),
+				keys,
+				values,
+			)
+			if err != nil {
+				return fmt.Errorf("ExecContext:%w", err)
+			}
+		}
+		return nil
+	})
+	observe(err)
+	if err != nil {
+		return fmt.Errorf("insert jaeger index: %w", err)
+	}
+	e.telemetry.recordTruncatedAttributeValues(ctx, "traces", attributes)
+	return nil
+}
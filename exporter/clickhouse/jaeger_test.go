@@ -0,0 +1,53 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package clickhouseexporter
+
+import (
+	"database/sql/driver"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTracesExporter_jaegerSchema(t *testing.T) {
+	var queries []string
+	inserts := map[string][]driver.Value{}
+	initClickhouseTestServer(t, func(query string, values []driver.Value) error {
+		if strings.HasPrefix(query, "INSERT") {
+			inserts[getQueryFirstLine(query)] = values
+			return nil
+		}
+		queries = append(queries, query)
+		return nil
+	})
+	exporter := newTestTracesExporter(t, defaultEndpoint, withDriverName(t.Name()), func(cfg *Config) {
+		cfg.Database = "otel"
+		cfg.TTL = 72 * time.Hour
+		cfg.Traces.Schema = tracesSchemaJaeger
+	})
+	var firstLines []string
+	for _, query := range queries {
+		firstLines = append(firstLines, getQueryFirstLine(query))
+	}
+	require.Equal(t, []string{
+		"CREATE DATABASE IF NOT EXISTS `otel`",
+		"CREATE TABLE IF NOT EXISTS `jaeger_spans_local`",
+		"CREATE TABLE IF NOT EXISTS `jaeger_index_local`",
+		"CREATE MATERIALIZED VIEW IF NOT EXISTS `jaeger_operations_local`",
+	}, firstLines)
+	require.Contains(t, queries[2], "TTL timestamp + toIntervalDay(3)")
+	require.Contains(t, queries[3], "ENGINE = SummingMergeTree()\n")
+	require.Contains(t, queries[3], "FROM `otel`.`jaeger_index_local`")
+
+	mustPushTracesData(t, exporter, simpleTraces(1))
+	spans := inserts["INSERT INTO `jaeger_spans_local` (timestamp, traceID, model) VALUES (?, ?, ?)"]
+	require.Len(t, spans, 3)
+	require.Equal(t, "1020300000000000000000000000000", spans[1])
+	require.Contains(t, spans[2], `"operation_name":"call db"`)
+	index := inserts["INSERT INTO `jaeger_index_local` (timestamp, traceID, service, operation, durationUs, tags.key, tags.value) VALUES (?, ?, ?, ?, ?, ?, ?)"]
+	require.Equal(t, []driver.Value{spans[0], spans[1], "test-service", "call db", uint64(60_000_000)}, index[:5])
+	require.Contains(t, index[5], "span.kind")
+}
@@ -0,0 +1,82 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package clickhouseexporter // import "github.com/foyer-work/otel-distribution/exporter/clickhouse"
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// jwtExpiryMargin is how long before its expiry a token read from a file is read again.
+const jwtExpiryMargin = time.Minute
+
+// jwtSource returns the token authenticating new connections. A token read from a file is cached
+// until it is about to expire or the refresh interval elapses.
+type jwtSource struct {
+	cfg JWTConfig
+
+	mu        sync.Mutex
+	token     string
+	expiresAt time.Time
+	refreshAt time.Time
+}
+
+func newJWTSource(cfg JWTConfig) *jwtSource {
+	return &jwtSource{cfg: cfg}
+}
+
+func (s *jwtSource) getJWT(context.Context) (string, error) {
+	if s.cfg.TokenFile == "" {
+		return string(s.cfg.Token), nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if s.token != "" && now.Before(s.refreshAt) {
+		return s.token, nil
+	}
+	data, err := os.ReadFile(s.cfg.TokenFile)
+	if err != nil {
+		// Keep using a token that is still valid while the file is being rotated.
+		if s.token != "" && now.Before(s.expiresAt) {
+			return s.token, nil
+		}
+		return "", fmt.Errorf("read jwt token file: %w", err)
+	}
+
+	s.token = strings.TrimSpace(string(data))
+	s.expiresAt = jwtExpiry(s.token)
+	s.refreshAt = s.expiresAt.Add(-jwtExpiryMargin)
+	if s.cfg.RefreshInterval > 0 && (s.expiresAt.IsZero() || now.Add(s.cfg.RefreshInterval).Before(s.refreshAt)) {
+		s.refreshAt = now.Add(s.cfg.RefreshInterval)
+	}
+	return s.token, nil
+}
+
+// jwtExpiry returns the time of the exp claim of token, zero if it has none.
+// The token is not verified, clickhouse does so when authenticating the connection.
+func jwtExpiry(token string) time.Time {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return time.Time{}
+	}
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Exp == 0 {
+		return time.Time{}
+	}
+	return time.Unix(claims.Exp, 0)
+}
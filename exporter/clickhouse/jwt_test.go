@@ -0,0 +1,71 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package clickhouseexporter
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testJWT(exp time.Time) string {
+	payload := base64.RawURLEncoding.EncodeToString(fmt.Appendf(nil, `{"sub":"otelcol","exp":%d}`, exp.Unix()))
+	return "eyJhbGciOiJIUzI1NiJ9." + payload + ".c2lnbmF0dXJl"
+}
+
+func TestJWTSource(t *testing.T) {
+	t.Run("static token", func(t *testing.T) {
+		token, err := newJWTSource(JWTConfig{Token: "static"}).getJWT(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, "static", token)
+	})
+
+	t.Run("token file", func(t *testing.T) {
+		tokenFile := filepath.Join(t.TempDir(), "token")
+		first := testJWT(time.Now().Add(time.Hour))
+		require.NoError(t, os.WriteFile(tokenFile, []byte(first+"\n"), 0o600))
+
+		source := newJWTSource(JWTConfig{TokenFile: tokenFile})
+		token, err := source.getJWT(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, first, token)
+
+		// The cached token is used until it is about to expire, even if the file is gone.
+		require.NoError(t, os.Remove(tokenFile))
+		token, err = source.getJWT(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, first, token)
+
+		second := testJWT(time.Now().Add(2 * time.Hour))
+		require.NoError(t, os.WriteFile(tokenFile, []byte(second), 0o600))
+		source.refreshAt = time.Now()
+		token, err = source.getJWT(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, second, token)
+	})
+
+	t.Run("expired token", func(t *testing.T) {
+		tokenFile := filepath.Join(t.TempDir(), "token")
+		require.NoError(t, os.WriteFile(tokenFile, []byte(testJWT(time.Now().Add(-time.Minute))), 0o600))
+
+		source := newJWTSource(JWTConfig{TokenFile: tokenFile})
+		_, err := source.getJWT(context.Background())
+		require.NoError(t, err)
+		require.NoError(t, os.Remove(tokenFile))
+		_, err = source.getJWT(context.Background())
+		require.ErrorContains(t, err, "read jwt token file")
+	})
+}
+
+func TestJWTExpiry(t *testing.T) {
+	exp := time.Unix(1893456000, 0)
+	require.Equal(t, exp, jwtExpiry(testJWT(exp)))
+	require.True(t, jwtExpiry("opaque-token").IsZero())
+}
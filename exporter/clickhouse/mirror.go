@@ -0,0 +1,138 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package clickhouseexporter // import "github.com/foyer-work/otel-distribution/exporter/clickhouse"
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"sync"
+
+	"go.opentelemetry.io/collector/component"
+	"go.uber.org/zap"
+)
+
+// maxMirrorRetries bounds the batches a mirror remembers as written into the primary cluster while their mirror
+// write is retried, the oldest being forgotten first, e.g. once their retries are exhausted.
+const maxMirrorRetries = 128
+
+// mirror writes a copy of the batches into the mirror cluster or the shadow tables through an exporter of its own.
+// A nil mirror writes nothing.
+type mirror[T comparable] struct {
+	name        string
+	required    bool
+	sampleRatio float64
+	signal      string
+	logger      *zap.Logger
+	failed      func(ctx context.Context, signal string)
+
+	push     func(context.Context, T) error
+	start    component.StartFunc
+	shutdown component.ShutdownFunc
+
+	mu      sync.Mutex
+	retries []T
+}
+
+// newMirror returns the mirror of signal, writing every batch through push into the exporter started by start.
+func newMirror[T comparable](cfg *Config, signal string, logger *zap.Logger, telemetry *exporterTelemetry, push func(context.Context, T) error, start component.StartFunc, shutdown component.ShutdownFunc) *mirror[T] {
+	return &mirror[T]{
+		name:        "mirror",
+		required:    cfg.Mirror.Required,
+		sampleRatio: 1,
+		signal:      signal,
+		logger:      logger,
+		failed:      telemetry.recordMirrorFailure,
+		push:        push,
+		start:       start,
+		shutdown:    shutdown,
+	}
+}
+
+// newShadow returns the best-effort writer of the shadow tables of signal, writing a sample of the batches
+// through push into the exporter started by start.
+func newShadow[T comparable](cfg *Config, signal string, logger *zap.Logger, telemetry *exporterTelemetry, push func(context.Context, T) error, start component.StartFunc, shutdown component.ShutdownFunc) *mirror[T] {
+	return &mirror[T]{
+		name:        "shadow",
+		sampleRatio: cfg.Shadow.SampleRatio,
+		signal:      signal,
+		logger:      logger,
+		failed:      telemetry.recordShadowFailure,
+		push:        push,
+		start:       start,
+		shutdown:    shutdown,
+	}
+}
+
+// wrap wraps push to also write the sampled batches into the mirror once pushed. The batches failing to be written
+// into a best-effort mirror are logged and counted, and succeed. The retries of the batches failing to be written
+// into a required mirror write them only into the mirror. push is returned unchanged if m is nil.
+func (m *mirror[T]) wrap(push func(context.Context, T) error) func(context.Context, T) error {
+	if m == nil {
+		return push
+	}
+	return func(ctx context.Context, data T) error {
+		if !m.retried(data) {
+			if err := push(ctx, data); err != nil {
+				return err
+			}
+			if m.sampleRatio < 1 && rand.Float64() >= m.sampleRatio {
+				return nil
+			}
+		}
+		err := m.push(ctx, data)
+		switch {
+		case err == nil:
+			return nil
+		case m.required:
+			err = fmt.Errorf("%s: %w", m.name, err)
+		default:
+			m.logger.Warn("failed to write batch into the "+m.name, zap.String("signal", m.signal), zap.Error(err))
+			m.failed(ctx, m.signal)
+			return nil
+		}
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if len(m.retries) == maxMirrorRetries {
+			m.retries = slices.Delete(m.retries, 0, 1)
+		}
+		m.retries = append(m.retries, data)
+		return err
+	}
+}
+
+// retried reports whether data is the retry of a batch already written into the primary cluster, forgetting it.
+func (m *mirror[T]) retried(data T) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := slices.Index(m.retries, data)
+	if i < 0 {
+		return false
+	}
+	m.retries = slices.Delete(m.retries, i, i+1)
+	return true
+}
+
+// lifecycle returns start and shutdown also starting and shutting down the exporter of the mirror. A best-effort
+// mirror failing to start doesn't fail the start. They are returned unchanged if m is nil.
+func (m *mirror[T]) lifecycle(start component.StartFunc, shutdown component.ShutdownFunc) (component.StartFunc, component.ShutdownFunc) {
+	if m == nil {
+		return start, shutdown
+	}
+	return func(ctx context.Context, host component.Host) error {
+			if err := start(ctx, host); err != nil {
+				return err
+			}
+			err := m.start(ctx, host)
+			if err != nil && !m.required {
+				m.logger.Warn("failed to start the "+m.name, zap.String("signal", m.signal), zap.Error(err))
+				return nil
+			}
+			return err
+		}, func(ctx context.Context) error {
+			return errors.Join(shutdown(ctx), m.shutdown(ctx))
+		}
+}
@@ -0,0 +1,179 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package clickhouseexporter
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/config/configopaque"
+	"go.opentelemetry.io/collector/exporter/exportertest"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/foyer-work/otel-distribution/exporter/clickhouse/internal/metadata"
+)
+
+func TestLogsExporter_mirror(t *testing.T) {
+	var inserts, creates int
+	initClickhouseTestServer(t, func(query string, _ []driver.Value) error {
+		switch {
+		case strings.HasPrefix(query, "INSERT"):
+			inserts++
+		case strings.HasPrefix(strings.TrimSpace(query), "CREATE TABLE"):
+			creates++
+		}
+		return nil
+	})
+	cfg := withDefaultConfig(withDriverName(t.Name()), func(cfg *Config) {
+		cfg.Endpoint = defaultEndpoint
+		cfg.Metrics.Enabled = false
+		cfg.QueueSettings.Enabled = false
+		cfg.Mirror = MirrorConfig{Enabled: true, Endpoints: []string{"mirror:9000"}, Required: true}
+	})
+	exporter, err := NewFactory().CreateLogs(context.Background(), exportertest.NewNopSettings(metadata.Type), cfg)
+	require.NoError(t, err)
+	require.NoError(t, exporter.Start(context.Background(), componenttest.NewNopHost()))
+	defer func() {
+		require.NoError(t, exporter.Shutdown(context.Background()))
+	}()
+	require.Equal(t, 2, creates, "the logs table is created on both clusters")
+
+	require.NoError(t, exporter.ConsumeLogs(context.Background(), simpleLogs(1)))
+	require.Equal(t, 2, inserts, "the batch is inserted into both clusters")
+}
+
+func TestMirror(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	telemetry := newTestTelemetry(t, reader)
+	errPrimary, errMirror := errors.New("primary down"), errors.New("mirror down")
+
+	var mirrored []string
+	var mirrorErr error
+	newTestMirror := func(required bool) *mirror[string] {
+		cfg := withDefaultConfig(func(cfg *Config) {
+			cfg.Mirror = MirrorConfig{Enabled: true, Endpoints: []string{"mirror:9000"}, Required: required}
+		})
+		return newMirror(cfg, "logs", zaptest.NewLogger(t), telemetry, func(_ context.Context, batch string) error {
+			mirrored = append(mirrored, batch)
+			return mirrorErr
+		}, nil, nil)
+	}
+	primary := func(_ context.Context, batch string) error {
+		if batch == "failing" {
+			return errPrimary
+		}
+		return nil
+	}
+
+	push := newTestMirror(false).wrap(primary)
+	require.NoError(t, push(context.Background(), "a"))
+	require.ErrorIs(t, push(context.Background(), "failing"), errPrimary)
+	mirrorErr = errMirror
+	require.NoError(t, push(context.Background(), "b"), "best-effort mirror failures don't fail the batch")
+	require.Equal(t, []string{"a", "b"}, mirrored, "batches failing on the primary cluster aren't mirrored")
+
+	push = newTestMirror(true).wrap(primary)
+	require.ErrorIs(t, push(context.Background(), "c"), errMirror)
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	require.Len(t, rm.ScopeMetrics[0].Metrics, 1)
+	require.Equal(t, "otelcol_exporter_clickhouse_mirror_failed_batches", rm.ScopeMetrics[0].Metrics[0].Name)
+	require.Equal(t, int64(1), rm.ScopeMetrics[0].Metrics[0].Data.(metricdata.Sum[int64]).DataPoints[0].Value)
+
+	var m *mirror[string]
+	require.NotNil(t, m.wrap(primary), "a nil mirror pushes unchanged")
+
+	t.Run("required retry", func(t *testing.T) {
+		var primaries []string
+		push := newTestMirror(true).wrap(func(_ context.Context, batch string) error {
+			primaries = append(primaries, batch)
+			return nil
+		})
+		mirrored, mirrorErr = nil, errMirror
+		require.ErrorIs(t, push(context.Background(), "d"), errMirror)
+		mirrorErr = nil
+		require.NoError(t, push(context.Background(), "d"))
+		require.NoError(t, push(context.Background(), "d"))
+		require.Equal(t, []string{"d", "d"}, primaries, "the retry writes the batch only into the mirror")
+		require.Equal(t, []string{"d", "d", "d"}, mirrored)
+	})
+	t.Run("best-effort canceled", func(t *testing.T) {
+		var primaries []string
+		push := newTestMirror(false).wrap(func(_ context.Context, batch string) error {
+			primaries = append(primaries, batch)
+			return nil
+		})
+		mirrored, mirrorErr = nil, context.Canceled
+		require.NoError(t, push(context.Background(), "e"), "best-effort mirror cancellations don't fail the batch")
+		mirrorErr = nil
+		require.NoError(t, push(context.Background(), "e"))
+		require.Equal(t, []string{"e", "e"}, primaries, "the batch isn't remembered as a retry")
+	})
+}
+
+func TestMirror_lifecycle(t *testing.T) {
+	var calls []string
+	start := func(name string, err error) component.StartFunc {
+		return func(context.Context, component.Host) error {
+			calls = append(calls, "start "+name)
+			return err
+		}
+	}
+	shutdown := func(name string) component.ShutdownFunc {
+		return func(context.Context) error {
+			calls = append(calls, "shutdown "+name)
+			return nil
+		}
+	}
+	errStart := errors.New("unreachable")
+	for _, required := range []bool{false, true} {
+		calls = nil
+		m := &mirror[string]{required: required, signal: "logs", logger: zaptest.NewLogger(t), start: start("mirror", errStart), shutdown: shutdown("mirror")}
+		startFunc, shutdownFunc := m.lifecycle(start("primary", nil), shutdown("primary"))
+		err := startFunc(context.Background(), componenttest.NewNopHost())
+		if required {
+			require.ErrorIs(t, err, errStart)
+		} else {
+			require.NoError(t, err, "a best-effort mirror failing to start doesn't fail the start")
+		}
+		require.NoError(t, shutdownFunc(context.Background()))
+		require.Equal(t, []string{"start primary", "start mirror", "shutdown primary", "shutdown mirror"}, calls)
+	}
+}
+
+func TestConfig_mirrorConfig(t *testing.T) {
+	cfg := withDefaultConfig(func(cfg *Config) {
+		cfg.Endpoint = defaultEndpoint
+		cfg.Username = "primary"
+		cfg.HealthCheckInterval = 0
+		cfg.Mirror = MirrorConfig{Enabled: true}
+	})
+	require.ErrorIs(t, cfg.Validate(), errConfigMirror)
+
+	cfg.Mirror.Endpoints = []string{"mirror-1:9000", "mirror-2:9000"}
+	cfg.Mirror.Username = "mirror"
+	cfg.Mirror.Password = configopaque.String("secret")
+	require.NoError(t, cfg.Validate())
+
+	mirrorCfg := cfg.mirrorConfig()
+	require.Empty(t, mirrorCfg.Endpoint)
+	require.Equal(t, []string{"mirror-1:9000", "mirror-2:9000"}, mirrorCfg.Endpoints)
+	require.Equal(t, "mirror", mirrorCfg.Username)
+	require.True(t, mirrorCfg.LazyConnect, "a best-effort mirror connects in the background")
+	require.False(t, mirrorCfg.Mirror.Enabled)
+	require.Equal(t, cfg.LogsTableName, mirrorCfg.LogsTableName)
+	require.Equal(t, "primary", cfg.Username, "the configuration is unchanged")
+
+	cfg.Mirror.Required = true
+	require.False(t, cfg.mirrorConfig().LazyConnect)
+}
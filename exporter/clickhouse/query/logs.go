@@ -0,0 +1,114 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package query // import "github.com/foyer-work/otel-distribution/exporter/clickhouse/query"
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// LogRecord is a log record of the logs table.
+type LogRecord struct {
+	Timestamp          time.Time
+	TraceID            string
+	SpanID             string
+	SeverityText       string
+	SeverityNumber     uint8
+	ServiceName        string
+	Body               string
+	ResourceAttributes map[string]string
+	LogAttributes      map[string]string
+}
+
+// LogFilter selects the log records queried by QueryLogs. The zero value matches the latest log records.
+type LogFilter struct {
+	// Start and End bound the timestamps of the log records, unbounded if zero.
+	Start, End time.Time
+	// ServiceName matches the service of the log records, if not empty.
+	ServiceName string
+	// MinSeverity matches the log records of at least this severity number.
+	MinSeverity uint8
+	// BodyContains matches the log records whose body contains it, if not empty.
+	BodyContains string
+	// TraceID matches the log records of the trace of this hex id, if not empty.
+	TraceID string
+	// Attributes match the log records having each attribute in their resource or log attributes.
+	Attributes map[string]string
+	// Tenant matches the log records of the tenant, if not empty.
+	Tenant string
+	// Limit bounds the log records returned, the latest first. Default is 100.
+	Limit int
+}
+
+var logColumns = []string{
+	"Timestamp", "TraceId", "SpanId", "SeverityText", "SeverityNumber", "ServiceName", "Body",
+	"ResourceAttributes", "LogAttributes",
+}
+
+// QueryLogs returns the log records matching filter, the latest first.
+func (c *Client) QueryLogs(ctx context.Context, filter LogFilter) ([]LogRecord, error) {
+	if filter.TraceID != "" && !validTraceID(filter.TraceID) {
+		return nil, fmt.Errorf("%w: %q", errInvalidTraceID, filter.TraceID)
+	}
+	columns, err := c.tableColumns(ctx, c.cfg.LogsTable)
+	if err != nil {
+		return nil, err
+	}
+	query, args, err := c.renderQueryLogs(columns, filter)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := c.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query %s: %w", c.qualified(c.cfg.LogsTable), err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	var records []LogRecord
+	for rows.Next() {
+		var (
+			record                  LogRecord
+			resourceAttrs, logAttrs string
+		)
+		if err := rows.Scan(&record.Timestamp, &record.TraceID, &record.SpanID, &record.SeverityText,
+			&record.SeverityNumber, &record.ServiceName, &record.Body, &resourceAttrs, &logAttrs); err != nil {
+			return nil, fmt.Errorf("scan log record: %w", err)
+		}
+		if record.ResourceAttributes, err = parseAttributes(resourceAttrs); err != nil {
+			return nil, err
+		}
+		if record.LogAttributes, err = parseAttributes(logAttrs); err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("query %s: %w", c.qualified(c.cfg.LogsTable), err)
+	}
+	return records, nil
+}
+
+func (c *Client) renderQueryLogs(columns map[string]string, filter LogFilter) (string, []any, error) {
+	w := where{columns: columns}
+	w.timeRange("Timestamp", filter.Start, filter.End)
+	w.equal(c.cfg.TenantColumn, filter.Tenant)
+	w.equal("ServiceName", filter.ServiceName)
+	if filter.MinSeverity > 0 {
+		w.add("SeverityNumber >= ?", filter.MinSeverity)
+	}
+	if filter.BodyContains != "" {
+		w.add("position(Body, ?) > 0", filter.BodyContains)
+	}
+	w.traceID("TraceId", strings.ToLower(filter.TraceID))
+	w.attributes(filter.Attributes, "ResourceAttributes", "LogAttributes")
+	if w.err != nil {
+		return "", nil, w.err
+	}
+	query := fmt.Sprintf("SELECT %s FROM %s%s ORDER BY Timestamp DESC LIMIT %d",
+		selectColumns(columns, logColumns...), c.qualified(c.cfg.LogsTable), w.String(), limit(filter.Limit))
+	return query, w.args, nil
+}
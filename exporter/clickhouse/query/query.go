@@ -0,0 +1,285 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package query reads back the logs, traces and metrics written by the exporter, so that tools and extensions
+// don't have to write SQL against its tables. The queries adapt to the schema variants of the tables, read from
+// system.columns: hex String or binary FixedString(16) ids, JSON, Map or String attributes, per type or unified
+// metrics tables, and tenant columns.
+package query // import "github.com/foyer-work/otel-distribution/exporter/clickhouse/query"
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/foyer-work/otel-distribution/exporter/clickhouse/internal"
+)
+
+// defaultLimit bounds the rows returned by the searches without a limit.
+const defaultLimit = 100
+
+// Config names the tables of the exporter. The zero value queries the default tables of the default database.
+type Config struct {
+	// Database holds the tables. Default is `default`.
+	Database string
+	// LogsTable is the logs table. Default is `otel_logs`.
+	LogsTable string
+	// TracesTable is the traces table. Default is `otel_traces`.
+	TracesTable string
+	// MetricsTable is the unified metrics table, or the prefix of the gauge and sum tables of the per type
+	// metrics schema, `<metrics_table>_gauge` and `<metrics_table>_sum`. Default is `otel_metrics`.
+	MetricsTable string
+	// TenantColumn is the column holding the tenant of the rows. Default is `Tenant`.
+	TenantColumn string
+}
+
+// Client queries the tables of the exporter through db, a database opened with the clickhouse driver.
+type Client struct {
+	db  *sql.DB
+	cfg Config
+
+	mu      sync.Mutex
+	columns map[string]map[string]string
+}
+
+var (
+	errNoTable        = errors.New("table not found")
+	errMissingColumn  = errors.New("table lacks the column")
+	errInvalidTraceID = errors.New("trace id must be 32 hex characters")
+)
+
+// NewClient returns a Client querying the tables named by cfg through db.
+func NewClient(db *sql.DB, cfg Config) *Client {
+	if cfg.Database == "" {
+		cfg.Database = "default"
+	}
+	if cfg.LogsTable == "" {
+		cfg.LogsTable = "otel_logs"
+	}
+	if cfg.TracesTable == "" {
+		cfg.TracesTable = "otel_traces"
+	}
+	if cfg.MetricsTable == "" {
+		cfg.MetricsTable = "otel_metrics"
+	}
+	if cfg.TenantColumn == "" {
+		cfg.TenantColumn = "Tenant"
+	}
+	return &Client{db: db, cfg: cfg, columns: map[string]map[string]string{}}
+}
+
+// tableColumns returns the types of the columns of table by name, errNoTable if it doesn't exist.
+// The columns are read once per table.
+func (c *Client) tableColumns(ctx context.Context, table string) (map[string]string, error) {
+	c.mu.Lock()
+	columns, ok := c.columns[table]
+	c.mu.Unlock()
+	if ok {
+		return columns, nil
+	}
+	rows, err := c.db.QueryContext(ctx, "SELECT name, type FROM system.columns WHERE database = ? AND table = ?", c.cfg.Database, table)
+	if err != nil {
+		return nil, fmt.Errorf("list columns of %s: %w", c.qualified(table), err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	columns = map[string]string{}
+	for rows.Next() {
+		var name, typ string
+		if err := rows.Scan(&name, &typ); err != nil {
+			return nil, fmt.Errorf("list columns of %s: %w", c.qualified(table), err)
+		}
+		columns[name] = typ
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list columns of %s: %w", c.qualified(table), err)
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("%w: %s", errNoTable, c.qualified(table))
+	}
+	c.mu.Lock()
+	c.columns[table] = columns
+	c.mu.Unlock()
+	return columns, nil
+}
+
+func (c *Client) qualified(table string) string {
+	return internal.QuoteIdentifier(c.cfg.Database) + "." + internal.QuoteIdentifier(table)
+}
+
+// selectColumns returns the expressions selecting names from a table of columns, ids as hex strings and
+// attributes as JSON strings, whatever their types. The columns the table lacks are selected as defaults.
+func selectColumns(columns map[string]string, names ...string) string {
+	exprs := make([]string, len(names))
+	for i, name := range names {
+		typ, ok := columns[name]
+		quoted := internal.QuoteIdentifier(name)
+		switch {
+		case !ok && strings.HasSuffix(name, "Attributes"):
+			exprs[i] = "'{}'"
+		case !ok:
+			exprs[i] = "''"
+		case isIDColumn(name) && strings.HasPrefix(typ, "FixedString"):
+			exprs[i] = fmt.Sprintf("lower(hex(%s))", quoted)
+		case strings.HasSuffix(name, "Attributes") && typ != "String":
+			exprs[i] = fmt.Sprintf("toJSONString(%s)", quoted)
+		default:
+			exprs[i] = quoted
+		}
+	}
+	return strings.Join(exprs, ", ")
+}
+
+func isIDColumn(name string) bool {
+	return strings.HasSuffix(name, "TraceId") || strings.HasSuffix(name, "SpanId")
+}
+
+// where accumulates the conditions of a query and their arguments.
+type where struct {
+	columns    map[string]string
+	conditions []string
+	args       []any
+	err        error
+}
+
+func (w *where) add(condition string, args ...any) {
+	w.conditions = append(w.conditions, condition)
+	w.args = append(w.args, args...)
+}
+
+// require records errMissingColumn if the table lacks column, returning whether it has it.
+func (w *where) require(column string) bool {
+	if _, ok := w.columns[column]; !ok {
+		w.err = errors.Join(w.err, fmt.Errorf("%w %s", errMissingColumn, column))
+		return false
+	}
+	return true
+}
+
+// timeRange matches the rows of column between start and end, unbounded if zero.
+func (w *where) timeRange(column string, start, end time.Time) {
+	if !start.IsZero() {
+		w.add(internal.QuoteIdentifier(column)+" >= fromUnixTimestamp64Nano(?)", start.UnixNano())
+	}
+	if !end.IsZero() {
+		w.add(internal.QuoteIdentifier(column)+" < fromUnixTimestamp64Nano(?)", end.UnixNano())
+	}
+}
+
+// equal matches the rows whose column is value, if value isn't empty.
+func (w *where) equal(column, value string) {
+	if value != "" && w.require(column) {
+		w.add(internal.QuoteIdentifier(column)+" = ?", value)
+	}
+}
+
+// traceID matches the rows whose column is the hex trace id id.
+func (w *where) traceID(column, id string) {
+	if id == "" || !w.require(column) {
+		return
+	}
+	if strings.HasPrefix(w.columns[column], "FixedString") {
+		w.add(internal.QuoteIdentifier(column)+" = unhex(?)", id)
+		return
+	}
+	w.add(internal.QuoteIdentifier(column)+" = ?", id)
+}
+
+// attributes matches the rows having each of attrs in one of the attributes columns.
+func (w *where) attributes(attrs map[string]string, columns ...string) {
+	for _, key := range slices.Sorted(maps.Keys(attrs)) {
+		var matches []string
+		var args []any
+		for _, column := range columns {
+			if match, bound, ok := attributeMatch(column, w.columns[column], key); ok {
+				matches = append(matches, match)
+				args = append(args, bound...)
+				args = append(args, attrs[key])
+			}
+		}
+		if len(matches) == 0 {
+			w.err = errors.Join(w.err, fmt.Errorf("%w %s", errMissingColumn, strings.Join(columns, " or ")))
+			return
+		}
+		w.add("("+strings.Join(matches, " OR ")+")", args...)
+	}
+}
+
+// attributeMatch returns the condition of the attribute key being in column of typ, with its arguments before
+// the bound value, false if it isn't an attributes column. Attributes are Map(LowCardinality(String), String),
+// JSON or, on servers without the JSON type, String columns holding JSON. The paths of JSON columns can't be
+// bound, so the key is quoted into the condition.
+func attributeMatch(column, typ, key string) (string, []any, bool) {
+	name := internal.QuoteIdentifier(column)
+	switch {
+	case strings.HasPrefix(typ, "Map("):
+		return name + "[?] = ?", []any{key}, true
+	case strings.HasPrefix(typ, "JSON"):
+		return fmt.Sprintf("toString(%s.%s) = ?", name, internal.QuoteIdentifier(key)), nil, true
+	case typ == "String":
+		return fmt.Sprintf("JSONExtractString(%s, ?) = ?", name), []any{key}, true
+	default:
+		return "", nil, false
+	}
+}
+
+func (w *where) String() string {
+	if len(w.conditions) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(w.conditions, " AND ")
+}
+
+// parseAttributes parses the attributes selected as a JSON string by selectColumns, the nested objects of JSON
+// columns flattened into keys joined with dots, and the values other than strings into their JSON encoding.
+func parseAttributes(s string) (map[string]string, error) {
+	var object map[string]any
+	if err := json.Unmarshal([]byte(s), &object); err != nil {
+		return nil, fmt.Errorf("parse attributes: %w", err)
+	}
+	attrs := make(map[string]string, len(object))
+	flattenAttributes(attrs, "", object)
+	return attrs, nil
+}
+
+func flattenAttributes(attrs map[string]string, prefix string, object map[string]any) {
+	for key, value := range object {
+		switch v := value.(type) {
+		case map[string]any:
+			flattenAttributes(attrs, prefix+key+".", v)
+		case string:
+			attrs[prefix+key] = v
+		default:
+			encoded, _ := json.Marshal(v)
+			attrs[prefix+key] = string(encoded)
+		}
+	}
+}
+
+func limit(n int) int {
+	if n <= 0 {
+		return defaultLimit
+	}
+	return n
+}
+
+// validTraceID reports whether id is 32 hex characters.
+func validTraceID(id string) bool {
+	if len(id) != 32 {
+		return false
+	}
+	for _, r := range id {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", r) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,129 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package query
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	mapColumns = map[string]string{
+		"Timestamp":          "DateTime64(9)",
+		"TraceId":            "String",
+		"SpanId":             "String",
+		"ServiceName":        "LowCardinality(String)",
+		"ResourceAttributes": "Map(LowCardinality(String), String)",
+		"SpanAttributes":     "Map(LowCardinality(String), String)",
+	}
+	jsonColumns = map[string]string{
+		"Timestamp":          "DateTime64(9)",
+		"TraceId":            "FixedString(16)",
+		"SpanId":             "FixedString(8)",
+		"ServiceName":        "LowCardinality(String)",
+		"Tenant":             "LowCardinality(String)",
+		"ResourceAttributes": "JSON",
+		"SpanAttributes":     "String",
+	}
+)
+
+func TestClient_renderFindTraceByID(t *testing.T) {
+	c := NewClient(nil, Config{Database: "otel"})
+	start := time.Unix(10, 0)
+
+	query, args, err := c.renderFindTraceByID(mapColumns, "0102", start, start.Add(time.Second))
+	require.NoError(t, err)
+	require.Contains(t, query, "SELECT `Timestamp`, `TraceId`, `SpanId`, '', ")
+	require.Contains(t, query, "`ServiceName`, '', '', '', toJSONString(`ResourceAttributes`), toJSONString(`SpanAttributes`) FROM `otel`.`otel_traces` "+
+		"WHERE `TraceId` = ? AND `Timestamp` >= fromUnixTimestamp64Nano(?) AND `Timestamp` < fromUnixTimestamp64Nano(?) ORDER BY Timestamp")
+	require.Equal(t, []any{"0102", int64(10e9), int64(11e9)}, args)
+
+	query, args, err = c.renderFindTraceByID(jsonColumns, "0102", time.Time{}, time.Time{})
+	require.NoError(t, err)
+	require.Contains(t, query, "lower(hex(`TraceId`)), lower(hex(`SpanId`))", "binary ids are selected as hex")
+	require.Contains(t, query, "toJSONString(`ResourceAttributes`), `SpanAttributes`", "attributes are selected as JSON")
+	require.Contains(t, query, "WHERE `TraceId` = unhex(?) ORDER BY")
+	require.Equal(t, []any{"0102"}, args)
+}
+
+func TestClient_renderSearchSpans(t *testing.T) {
+	c := NewClient(nil, Config{})
+	filter := SpanFilter{
+		ServiceName: "api",
+		Attributes:  map[string]string{"user.id": "u1", "http.route": "/"},
+		MinDuration: time.Millisecond,
+		Tenant:      "acme",
+	}
+
+	query, args, err := c.renderSearchSpans(jsonColumns, filter)
+	require.NoError(t, err)
+	require.Contains(t, query, "FROM `default`.`otel_traces` WHERE `Tenant` = ? AND `ServiceName` = ? AND Duration >= ? AND "+
+		"(toString(`ResourceAttributes`.`http.route`) = ? OR JSONExtractString(`SpanAttributes`, ?) = ?) AND "+
+		"(toString(`ResourceAttributes`.`user.id`) = ? OR JSONExtractString(`SpanAttributes`, ?) = ?) "+
+		"ORDER BY Timestamp DESC LIMIT 100")
+	require.Equal(t, []any{"acme", "api", int64(time.Millisecond), "/", "http.route", "/", "u1", "user.id", "u1"}, args)
+
+	query, _, err = c.renderSearchSpans(mapColumns, SpanFilter{Attributes: map[string]string{"user.id": "u1"}, Limit: 5})
+	require.NoError(t, err)
+	require.Contains(t, query, "WHERE (`ResourceAttributes`[?] = ? OR `SpanAttributes`[?] = ?) ORDER BY Timestamp DESC LIMIT 5")
+
+	_, _, err = c.renderSearchSpans(mapColumns, filter)
+	require.ErrorIs(t, err, errMissingColumn, "tenants are only matched in tables with a tenant column")
+}
+
+func TestClient_renderQueryLogs(t *testing.T) {
+	c := NewClient(nil, Config{LogsTable: "logs"})
+	columns := map[string]string{
+		"Timestamp":     "DateTime64(9)",
+		"TraceId":       "FixedString(16)",
+		"Body":          "String",
+		"LogAttributes": "JSON",
+	}
+
+	query, args, err := c.renderQueryLogs(columns, LogFilter{MinSeverity: 17, BodyContains: "timeout", TraceID: "ABCD"})
+	require.NoError(t, err)
+	require.Contains(t, query, "SELECT `Timestamp`, lower(hex(`TraceId`)), '', '', '', '', `Body`, '{}', toJSONString(`LogAttributes`) FROM `default`.`logs` "+
+		"WHERE SeverityNumber >= ? AND position(Body, ?) > 0 AND `TraceId` = unhex(?) ORDER BY Timestamp DESC LIMIT 100")
+	require.Equal(t, []any{uint8(17), "timeout", "abcd"}, args)
+}
+
+func TestClient_renderSeriesRange(t *testing.T) {
+	c := NewClient(nil, Config{})
+	columns := map[string]string{"ServiceName": "LowCardinality(String)", "Attributes": "Map(LowCardinality(String), String)"}
+	perType := []seriesTable{{name: "otel_metrics_gauge", columns: columns}, {name: "otel_metrics_sum", columns: columns}}
+
+	query, args, err := c.renderSeriesRange(perType, SeriesFilter{MetricName: "requests", Attributes: map[string]string{"route": "/"}})
+	require.NoError(t, err)
+	require.Equal(t, "SELECT ServiceName, attributes, time, value FROM ("+
+		"SELECT ServiceName, toJSONString(`Attributes`) AS attributes, TimeUnix AS time, Value AS value FROM `default`.`otel_metrics_gauge` WHERE MetricName = ? AND (`Attributes`[?] = ?) UNION ALL "+
+		"SELECT ServiceName, toJSONString(`Attributes`) AS attributes, TimeUnix AS time, Value AS value FROM `default`.`otel_metrics_sum` WHERE MetricName = ? AND (`Attributes`[?] = ?)"+
+		") ORDER BY ServiceName, attributes, time", query)
+	require.Equal(t, []any{"requests", "route", "/", "requests", "route", "/"}, args)
+
+	unified := []seriesTable{{name: "otel_metrics", columns: columns, metricTypes: []string{"Gauge", "Sum"}}}
+	query, _, err = c.renderSeriesRange(unified, SeriesFilter{MetricName: "requests", Step: time.Minute})
+	require.NoError(t, err)
+	require.Contains(t, query, "toStartOfInterval(TimeUnix, toIntervalMillisecond(60000)) AS time, avg(Value) AS value FROM `default`.`otel_metrics` "+
+		"WHERE MetricName = ? AND MetricType IN ('Gauge', 'Sum') GROUP BY ServiceName, attributes, time")
+
+	_, _, err = c.renderSeriesRange(unified, SeriesFilter{MetricName: "requests", Tenant: "acme"})
+	require.ErrorIs(t, err, errMissingColumn)
+}
+
+func TestParseAttributes(t *testing.T) {
+	attrs, err := parseAttributes(`{"http":{"route":"/","status_code":200},"user.id":"u1","tags":["a"]}`)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"http.route": "/", "http.status_code": "200", "user.id": "u1", "tags": `["a"]`}, attrs)
+
+	_, err = parseAttributes("user.id=u1")
+	require.Error(t, err)
+}
+
+func TestValidTraceID(t *testing.T) {
+	require.True(t, validTraceID("0102030405060708090A0B0C0D0E0F10"))
+	require.False(t, validTraceID("0102"))
+	require.False(t, validTraceID("0102030405060708090a0b0c0d0e0f1g"))
+}
@@ -0,0 +1,171 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package query // import "github.com/foyer-work/otel-distribution/exporter/clickhouse/query"
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/foyer-work/otel-distribution/exporter/clickhouse/internal"
+)
+
+// Series is the datapoints of a gauge or sum metric of a service with the same attributes.
+type Series struct {
+	ServiceName string
+	Attributes  map[string]string
+	Points      []Point
+}
+
+// Point is a datapoint of a Series.
+type Point struct {
+	Time  time.Time
+	Value float64
+}
+
+// SeriesFilter selects the series returned by SeriesRange.
+type SeriesFilter struct {
+	// MetricName is the name of the gauge or sum metric. Required.
+	MetricName string
+	// Start and End bound the times of the datapoints, unbounded if zero.
+	Start, End time.Time
+	// ServiceName matches the service of the series, if not empty.
+	ServiceName string
+	// Attributes match the series having each attribute in their datapoint attributes.
+	Attributes map[string]string
+	// Tenant matches the series of the tenant, if not empty.
+	Tenant string
+	// Step averages the datapoints of the series over intervals of this length, starting at the epoch, if positive.
+	Step time.Duration
+}
+
+var (
+	errNoMetricName = errors.New("metric name is required")
+	errInvalidStep  = errors.New("step must be at least a millisecond")
+)
+
+// SeriesRange returns the series of the gauge or sum metric matching filter, their datapoints ordered by time.
+// The datapoints are read from the unified metrics table if it exists, otherwise from the gauge and sum tables.
+func (c *Client) SeriesRange(ctx context.Context, filter SeriesFilter) ([]*Series, error) {
+	if filter.MetricName == "" {
+		return nil, errNoMetricName
+	}
+	if filter.Step > 0 && filter.Step < time.Millisecond {
+		return nil, fmt.Errorf("%w: %s", errInvalidStep, filter.Step)
+	}
+	tables, err := c.seriesTables(ctx)
+	if err != nil {
+		return nil, err
+	}
+	query, args, err := c.renderSeriesRange(tables, filter)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := c.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query metric %s: %w", filter.MetricName, err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	var (
+		series []*Series
+		last   *Series
+		key    string
+	)
+	for rows.Next() {
+		var (
+			serviceName, attrs string
+			point              Point
+		)
+		if err := rows.Scan(&serviceName, &attrs, &point.Time, &point.Value); err != nil {
+			return nil, fmt.Errorf("scan datapoint: %w", err)
+		}
+		// The rows are ordered by series, so the datapoints of a series are consecutive.
+		if last == nil || serviceName+"\x00"+attrs != key {
+			key = serviceName + "\x00" + attrs
+			last = &Series{ServiceName: serviceName}
+			if last.Attributes, err = parseAttributes(attrs); err != nil {
+				return nil, err
+			}
+			series = append(series, last)
+		}
+		last.Points = append(last.Points, point)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("query metric %s: %w", filter.MetricName, err)
+	}
+	return series, nil
+}
+
+// seriesTable is a table holding the datapoints of gauges or sums, with the values of MetricType matched in
+// the unified metrics table.
+type seriesTable struct {
+	name        string
+	columns     map[string]string
+	metricTypes []string
+}
+
+// seriesTables returns the unified metrics table if it exists, otherwise the existing gauge and sum tables.
+func (c *Client) seriesTables(ctx context.Context) ([]seriesTable, error) {
+	columns, err := c.tableColumns(ctx, c.cfg.MetricsTable)
+	if err != nil && !errors.Is(err, errNoTable) {
+		return nil, err
+	}
+	if _, ok := columns["MetricType"]; ok {
+		return []seriesTable{{name: c.cfg.MetricsTable, columns: columns, metricTypes: []string{"Gauge", "Sum"}}}, nil
+	}
+	var tables []seriesTable
+	for _, suffix := range []string{"_gauge", "_sum"} {
+		columns, err := c.tableColumns(ctx, c.cfg.MetricsTable+suffix)
+		if errors.Is(err, errNoTable) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		tables = append(tables, seriesTable{name: c.cfg.MetricsTable + suffix, columns: columns})
+	}
+	if len(tables) == 0 {
+		return nil, fmt.Errorf("%w: %s, %s_gauge or %s_sum", errNoTable, c.qualified(c.cfg.MetricsTable), c.cfg.MetricsTable, c.cfg.MetricsTable)
+	}
+	return tables, nil
+}
+
+func (c *Client) renderSeriesRange(tables []seriesTable, filter SeriesFilter) (string, []any, error) {
+	selects := make([]string, len(tables))
+	var args []any
+	for i, table := range tables {
+		w := where{columns: table.columns}
+		w.add("MetricName = ?", filter.MetricName)
+		if len(table.metricTypes) > 0 {
+			quoted := make([]string, len(table.metricTypes))
+			for j, metricType := range table.metricTypes {
+				quoted[j] = internal.QuoteString(metricType)
+			}
+			w.add("MetricType IN (" + strings.Join(quoted, ", ") + ")")
+		}
+		w.timeRange("TimeUnix", filter.Start, filter.End)
+		w.equal(c.cfg.TenantColumn, filter.Tenant)
+		w.equal("ServiceName", filter.ServiceName)
+		w.attributes(filter.Attributes, "Attributes")
+		if w.err != nil {
+			return "", nil, fmt.Errorf("%s: %w", c.qualified(table.name), w.err)
+		}
+		attrs := selectColumns(table.columns, "Attributes")
+		if filter.Step > 0 {
+			selects[i] = fmt.Sprintf("SELECT ServiceName, %s AS attributes, toStartOfInterval(TimeUnix, toIntervalMillisecond(%d)) AS time, avg(Value) AS value FROM %s%s GROUP BY ServiceName, attributes, time",
+				attrs, filter.Step.Milliseconds(), c.qualified(table.name), w.String())
+		} else {
+			selects[i] = fmt.Sprintf("SELECT ServiceName, %s AS attributes, TimeUnix AS time, Value AS value FROM %s%s",
+				attrs, c.qualified(table.name), w.String())
+		}
+		args = append(args, w.args...)
+	}
+	query := fmt.Sprintf("SELECT ServiceName, attributes, time, value FROM (%s) ORDER BY ServiceName, attributes, time",
+		strings.Join(selects, " UNION ALL "))
+	return query, args, nil
+}
@@ -0,0 +1,178 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package query // import "github.com/foyer-work/otel-distribution/exporter/clickhouse/query"
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Span is a span of the traces table.
+type Span struct {
+	Timestamp          time.Time
+	TraceID            string
+	SpanID             string
+	ParentSpanID       string
+	TraceState         string
+	Name               string
+	Kind               string
+	ServiceName        string
+	Duration           time.Duration
+	StatusCode         string
+	StatusMessage      string
+	ResourceAttributes map[string]string
+	SpanAttributes     map[string]string
+}
+
+// SpanFilter selects the spans searched by SearchSpans. The zero value matches the latest spans.
+type SpanFilter struct {
+	// Start and End bound the timestamps of the spans, unbounded if zero.
+	Start, End time.Time
+	// ServiceName and SpanName match the service and name of the spans, if not empty.
+	ServiceName, SpanName string
+	// Attributes match the spans having each attribute in their resource or span attributes.
+	Attributes map[string]string
+	// MinDuration matches the spans lasting at least as long.
+	MinDuration time.Duration
+	// Tenant matches the spans of the tenant, if not empty.
+	Tenant string
+	// Limit bounds the spans returned, the latest first. Default is 100.
+	Limit int
+}
+
+var spanColumns = []string{
+	"Timestamp", "TraceId", "SpanId", "ParentSpanId", "TraceState", "SpanName", "SpanKind", "ServiceName",
+	"Duration", "StatusCode", "StatusMessage", "ResourceAttributes", "SpanAttributes",
+}
+
+// FindTraceByID returns the spans of the trace of the hex id traceID, ordered by timestamp, none if it isn't
+// found. The search is bounded by the start and end of the trace of the trace id lookup table, if it exists.
+func (c *Client) FindTraceByID(ctx context.Context, traceID string) ([]Span, error) {
+	if !validTraceID(traceID) {
+		return nil, fmt.Errorf("%w: %q", errInvalidTraceID, traceID)
+	}
+	traceID = strings.ToLower(traceID)
+	columns, err := c.tableColumns(ctx, c.cfg.TracesTable)
+	if err != nil {
+		return nil, err
+	}
+	start, end, err := c.traceTimeRange(ctx, traceID)
+	if err != nil {
+		return nil, err
+	}
+	query, args, err := c.renderFindTraceByID(columns, traceID, start, end)
+	if err != nil {
+		return nil, err
+	}
+	return c.querySpans(ctx, query, args)
+}
+
+func (c *Client) renderFindTraceByID(columns map[string]string, traceID string, start, end time.Time) (string, []any, error) {
+	w := where{columns: columns}
+	w.traceID("TraceId", traceID)
+	w.timeRange("Timestamp", start, end)
+	if w.err != nil {
+		return "", nil, w.err
+	}
+	query := fmt.Sprintf("SELECT %s FROM %s%s ORDER BY Timestamp",
+		selectColumns(columns, spanColumns...), c.qualified(c.cfg.TracesTable), w.String())
+	return query, w.args, nil
+}
+
+// traceTimeRange returns the bounds of the trace traceID of the trace id lookup table, zero if the table
+// doesn't exist or lacks the trace. The bounds are in seconds, so the end is rounded up to the next one.
+func (c *Client) traceTimeRange(ctx context.Context, traceID string) (start, end time.Time, err error) {
+	table := c.cfg.TracesTable + "_trace_id_ts"
+	columns, err := c.tableColumns(ctx, table)
+	if errors.Is(err, errNoTable) {
+		return time.Time{}, time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	w := where{columns: columns}
+	w.traceID("TraceId", traceID)
+	if w.err != nil {
+		return time.Time{}, time.Time{}, w.err
+	}
+	query := fmt.Sprintf("SELECT min(Start), max(End) FROM %s%s HAVING count() > 0", c.qualified(table), w.String())
+	err = c.db.QueryRowContext(ctx, query, w.args...).Scan(&start, &end)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("find trace %s in %s: %w", traceID, c.qualified(table), err)
+	}
+	return start, end.Add(time.Second), nil
+}
+
+// SearchSpans returns the spans matching filter, the latest first.
+func (c *Client) SearchSpans(ctx context.Context, filter SpanFilter) ([]Span, error) {
+	columns, err := c.tableColumns(ctx, c.cfg.TracesTable)
+	if err != nil {
+		return nil, err
+	}
+	query, args, err := c.renderSearchSpans(columns, filter)
+	if err != nil {
+		return nil, err
+	}
+	return c.querySpans(ctx, query, args)
+}
+
+func (c *Client) renderSearchSpans(columns map[string]string, filter SpanFilter) (string, []any, error) {
+	w := where{columns: columns}
+	w.timeRange("Timestamp", filter.Start, filter.End)
+	w.equal(c.cfg.TenantColumn, filter.Tenant)
+	w.equal("ServiceName", filter.ServiceName)
+	w.equal("SpanName", filter.SpanName)
+	if filter.MinDuration > 0 {
+		w.add("Duration >= ?", filter.MinDuration.Nanoseconds())
+	}
+	w.attributes(filter.Attributes, "ResourceAttributes", "SpanAttributes")
+	if w.err != nil {
+		return "", nil, w.err
+	}
+	query := fmt.Sprintf("SELECT %s FROM %s%s ORDER BY Timestamp DESC LIMIT %d",
+		selectColumns(columns, spanColumns...), c.qualified(c.cfg.TracesTable), w.String(), limit(filter.Limit))
+	return query, w.args, nil
+}
+
+func (c *Client) querySpans(ctx context.Context, query string, args []any) ([]Span, error) {
+	rows, err := c.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query %s: %w", c.qualified(c.cfg.TracesTable), err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	var spans []Span
+	for rows.Next() {
+		var (
+			span                     Span
+			duration                 uint64
+			resourceAttrs, spanAttrs string
+		)
+		if err := rows.Scan(&span.Timestamp, &span.TraceID, &span.SpanID, &span.ParentSpanID, &span.TraceState,
+			&span.Name, &span.Kind, &span.ServiceName, &duration, &span.StatusCode, &span.StatusMessage,
+			&resourceAttrs, &spanAttrs); err != nil {
+			return nil, fmt.Errorf("scan span: %w", err)
+		}
+		span.Duration = time.Duration(duration)
+		if span.ResourceAttributes, err = parseAttributes(resourceAttrs); err != nil {
+			return nil, err
+		}
+		if span.SpanAttributes, err = parseAttributes(spanAttrs); err != nil {
+			return nil, err
+		}
+		spans = append(spans, span)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("query %s: %w", c.qualified(c.cfg.TracesTable), err)
+	}
+	return spans, nil
+}
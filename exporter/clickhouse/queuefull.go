@@ -0,0 +1,176 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package clickhouseexporter // import "github.com/foyer-work/otel-distribution/exporter/clickhouse"
+
+import (
+	"context"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/exporter"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.uber.org/zap"
+)
+
+// dropOldestBufferSize is the number of batches waiting for room in the sending queue with the drop_oldest policy.
+const dropOldestBufferSize = 64
+
+// bufferedBatch is a batch waiting for room in the sending queue.
+type bufferedBatch[T any] struct {
+	ctx  context.Context
+	data T
+}
+
+// dropOldestBuffer implements the drop_oldest queue full policy in front of an exporter whose sending
+// queue blocks when full: batches wait in the buffer for room in the queue, and the oldest are dropped
+// when the buffer is full too, so that receivers are never blocked.
+type dropOldestBuffer[T any] struct {
+	next      component.Component
+	consume   func(context.Context, T) error
+	signal    string
+	logger    *zap.Logger
+	telemetry *exporterTelemetry
+
+	batches chan bufferedBatch[T]
+	cancel  context.CancelFunc
+	done    chan struct{}
+}
+
+func newDropOldestBuffer[T any](next component.Component, consume func(context.Context, T) error, signal string, logger *zap.Logger, telemetry *exporterTelemetry) *dropOldestBuffer[T] {
+	return &dropOldestBuffer[T]{
+		next:      next,
+		consume:   consume,
+		signal:    signal,
+		logger:    logger,
+		telemetry: telemetry,
+		batches:   make(chan bufferedBatch[T], dropOldestBufferSize),
+	}
+}
+
+// Start starts the exporter, then forwards the buffered batches to it.
+func (b *dropOldestBuffer[T]) Start(ctx context.Context, host component.Host) error {
+	if err := b.next.Start(ctx, host); err != nil {
+		return err
+	}
+	var runCtx context.Context
+	runCtx, b.cancel = context.WithCancel(context.Background())
+	b.done = make(chan struct{})
+	go b.run(runCtx)
+	return nil
+}
+
+// Shutdown forwards the remaining buffered batches until ctx is done, then shuts the exporter down.
+func (b *dropOldestBuffer[T]) Shutdown(ctx context.Context) error {
+	if b.cancel != nil {
+		b.cancel()
+		<-b.done
+	}
+drain:
+	for ctx.Err() == nil {
+		select {
+		case batch := <-b.batches:
+			b.forward(ctx, batch)
+		default:
+			break drain
+		}
+	}
+	return b.next.Shutdown(ctx)
+}
+
+// Capabilities returns the capabilities of the exporter, which doesn't mutate data.
+func (*dropOldestBuffer[T]) Capabilities() consumer.Capabilities {
+	return consumer.Capabilities{MutatesData: false}
+}
+
+// enqueue buffers a batch, dropping the oldest batches if the buffer is full.
+func (b *dropOldestBuffer[T]) enqueue(ctx context.Context, data T) error {
+	// The batch outlives the call, keep the values of ctx but not its cancellation.
+	batch := bufferedBatch[T]{ctx: context.WithoutCancel(ctx), data: data}
+	for {
+		select {
+		case b.batches <- batch:
+			return nil
+		default:
+		}
+		select {
+		case <-b.batches:
+			b.logger.Debug("sending queue is full, dropped oldest batch", zap.String("signal", b.signal))
+			b.telemetry.recordDroppedBatch(ctx, b.signal)
+		default:
+		}
+	}
+}
+
+func (b *dropOldestBuffer[T]) run(ctx context.Context) {
+	defer close(b.done)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case batch := <-b.batches:
+			b.forward(ctx, batch)
+		}
+	}
+}
+
+// forward passes a batch to the exporter, waiting for room in its queue until cancel is done.
+func (b *dropOldestBuffer[T]) forward(cancel context.Context, batch bufferedBatch[T]) {
+	ctx, cancelBatch := context.WithCancel(batch.ctx)
+	defer cancelBatch()
+	stop := context.AfterFunc(cancel, cancelBatch)
+	defer stop()
+	if err := b.consume(ctx, batch.data); err != nil {
+		b.logger.Debug("failed to queue buffered batch", zap.String("signal", b.signal), zap.Error(err))
+	}
+}
+
+type dropOldestLogs struct {
+	*dropOldestBuffer[plog.Logs]
+}
+
+func (e dropOldestLogs) ConsumeLogs(ctx context.Context, ld plog.Logs) error {
+	return e.enqueue(ctx, ld)
+}
+
+type dropOldestTraces struct {
+	*dropOldestBuffer[ptrace.Traces]
+}
+
+func (e dropOldestTraces) ConsumeTraces(ctx context.Context, td ptrace.Traces) error {
+	return e.enqueue(ctx, td)
+}
+
+type dropOldestMetrics struct {
+	*dropOldestBuffer[pmetric.Metrics]
+}
+
+func (e dropOldestMetrics) ConsumeMetrics(ctx context.Context, md pmetric.Metrics) error {
+	return e.enqueue(ctx, md)
+}
+
+// withQueueFullPolicyLogs applies the drop_oldest policy of cfg to exp, which is returned unchanged for other policies.
+func withQueueFullPolicyLogs(cfg *Config, logger *zap.Logger, telemetry *exporterTelemetry, exp exporter.Logs) exporter.Logs {
+	if cfg.Logs.QueueFullPolicy != queueFullPolicyDropOldest || !cfg.QueueSettings.Enabled {
+		return exp
+	}
+	return dropOldestLogs{newDropOldestBuffer(exp, exp.ConsumeLogs, "logs", logger, telemetry)}
+}
+
+// withQueueFullPolicyTraces applies the drop_oldest policy of cfg to exp, which is returned unchanged for other policies.
+func withQueueFullPolicyTraces(cfg *Config, logger *zap.Logger, telemetry *exporterTelemetry, exp exporter.Traces) exporter.Traces {
+	if cfg.Traces.QueueFullPolicy != queueFullPolicyDropOldest || !cfg.QueueSettings.Enabled {
+		return exp
+	}
+	return dropOldestTraces{newDropOldestBuffer(exp, exp.ConsumeTraces, "traces", logger, telemetry)}
+}
+
+// withQueueFullPolicyMetrics applies the drop_oldest policy of cfg to exp, which is returned unchanged for other policies.
+func withQueueFullPolicyMetrics(cfg *Config, logger *zap.Logger, telemetry *exporterTelemetry, exp exporter.Metrics) exporter.Metrics {
+	if cfg.Metrics.QueueFullPolicy != queueFullPolicyDropOldest || !cfg.QueueSettings.Enabled {
+		return exp
+	}
+	return dropOldestMetrics{newDropOldestBuffer(exp, exp.ConsumeMetrics, "metrics", logger, telemetry)}
+}
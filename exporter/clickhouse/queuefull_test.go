@@ -0,0 +1,83 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package clickhouseexporter
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestDropOldestBuffer(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		queued  []int
+		started = make(chan struct{}, 1)
+		unblock = make(chan struct{})
+	)
+	// The sending queue is full until unblocked.
+	consume := func(ctx context.Context, ld plog.Logs) error {
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		select {
+		case <-unblock:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		queued = append(queued, ld.LogRecordCount())
+		return nil
+	}
+	next := struct {
+		component.StartFunc
+		component.ShutdownFunc
+	}{}
+	exp := dropOldestLogs{newDropOldestBuffer(next, consume, "logs", zaptest.NewLogger(t), nil)}
+	require.NoError(t, exp.Start(context.Background(), componenttest.NewNopHost()))
+
+	require.NoError(t, exp.ConsumeLogs(context.Background(), simpleLogs(1)))
+	<-started
+	for i := 2; i <= dropOldestBufferSize+2; i++ {
+		require.NoError(t, exp.ConsumeLogs(context.Background(), simpleLogs(i)), "receivers are not blocked")
+	}
+	close(unblock)
+
+	want := []int{1}
+	for i := 3; i <= dropOldestBufferSize+2; i++ {
+		want = append(want, i)
+	}
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(queued) == len(want)
+	}, 5*time.Second, time.Millisecond)
+	require.NoError(t, exp.Shutdown(context.Background()))
+	require.Equal(t, want, queued, "the oldest waiting batch is dropped")
+}
+
+func TestConfig_queueFullPolicy(t *testing.T) {
+	cfg := withDefaultConfig(func(cfg *Config) {
+		cfg.Endpoint = defaultEndpoint
+		cfg.Logs.QueueFullPolicy = queueFullPolicyDropOldest
+		cfg.Traces.QueueFullPolicy = queueFullPolicyBlock
+	})
+	require.NoError(t, cfg.Validate())
+	require.True(t, cfg.queueSettingsFor(cfg.Logs.SignalConfig).BlockOnOverflow)
+	require.True(t, cfg.queueSettingsFor(cfg.Traces.SignalConfig).BlockOnOverflow)
+	require.Equal(t, cfg.QueueSettings, cfg.queueSettingsFor(cfg.Metrics.SignalConfig))
+
+	cfg.Metrics.QueueFullPolicy = "drop"
+	require.ErrorIs(t, cfg.Validate(), errConfigQueueFullPolicy)
+	require.ErrorContains(t, cfg.Validate(), "metrics::queue_full_policy")
+}
@@ -0,0 +1,135 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package clickhouseexporter // import "github.com/foyer-work/otel-distribution/exporter/clickhouse"
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/collector/exporter/exporterhelper"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.uber.org/zap"
+)
+
+const (
+	quotaActionDrop  = "drop"
+	quotaActionDefer = "defer"
+)
+
+// quotaEvictInterval is how often the buckets of the tenants that haven't used their quota are forgotten.
+const quotaEvictInterval = time.Minute
+
+// withQuotas drops or defers the batches of the tenants over their quota, as configured by cfg.Quotas.
+// usage returns the rows and bytes of a batch. Deferred batches fail with a retryable error delaying
+// their retry until the quota of their tenant refills.
+func withQuotas[T any](cfg *Config, signal string, logger *zap.Logger, telemetry *exporterTelemetry, push func(context.Context, T) error, usage func(T) (rows, bytes int)) func(context.Context, T) error {
+	if !cfg.Quotas.Enabled {
+		return push
+	}
+	quotas := newTenantQuotas(cfg.Quotas)
+	return func(ctx context.Context, data T) error {
+		tenant := cfg.tenant(ctx)
+		rows, bytes := usage(data)
+		wait, ok := quotas.admit(tenant, rows, bytes, time.Now())
+		if ok {
+			return push(ctx, data)
+		}
+		telemetry.recordQuotaExceeded(ctx, signal, tenant, cfg.Quotas.Action, rows)
+		if cfg.Quotas.Action == quotaActionDrop {
+			logger.Debug("dropping a batch over the quota of its tenant", zap.String("signal", signal), zap.String("tenant", tenant), zap.Int("rows", rows))
+			return nil
+		}
+		return exporterhelper.NewThrottleRetry(fmt.Errorf("tenant %q is over its %s quota", tenant, signal), wait)
+	}
+}
+
+// tenantQuotas limits the rows and bytes each tenant writes per second with token buckets holding up to
+// burst of their rate. A batch is admitted while the buckets of its tenant aren't empty, possibly overdrawing
+// them, so that the batches larger than the burst still pass once the buckets refill.
+type tenantQuotas struct {
+	cfg QuotasConfig
+
+	mu      sync.Mutex
+	buckets map[string]*quotaBuckets
+	evicted time.Time
+}
+
+// quotaBuckets holds the rows and bytes a tenant may still write.
+type quotaBuckets struct {
+	rows, bytes float64
+	updated     time.Time
+}
+
+func newTenantQuotas(cfg QuotasConfig) *tenantQuotas {
+	return &tenantQuotas{cfg: cfg, buckets: map[string]*quotaBuckets{}}
+}
+
+// admit takes rows and bytes from the buckets of tenant at now if they aren't empty, otherwise it returns
+// how long until they are no longer empty.
+func (q *tenantQuotas) admit(tenant string, rows, bytes int, now time.Time) (time.Duration, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.evict(now)
+	rowsRate, bytesRate := q.cfg.rates(tenant)
+	burst := q.cfg.Burst.Seconds()
+	b, ok := q.buckets[tenant]
+	if !ok {
+		b = &quotaBuckets{rows: rowsRate * burst, bytes: bytesRate * burst, updated: now}
+		q.buckets[tenant] = b
+	}
+	elapsed := now.Sub(b.updated).Seconds()
+	b.rows = min(b.rows+elapsed*rowsRate, rowsRate*burst)
+	b.bytes = min(b.bytes+elapsed*bytesRate, bytesRate*burst)
+	b.updated = now
+
+	var wait time.Duration
+	if rowsRate > 0 && b.rows <= 0 {
+		wait = max(wait, time.Duration(-b.rows/rowsRate*float64(time.Second))+time.Millisecond)
+	}
+	if bytesRate > 0 && b.bytes <= 0 {
+		wait = max(wait, time.Duration(-b.bytes/bytesRate*float64(time.Second))+time.Millisecond)
+	}
+	if wait > 0 {
+		return wait, false
+	}
+	if rowsRate > 0 {
+		b.rows -= float64(rows)
+	}
+	if bytesRate > 0 {
+		b.bytes -= float64(bytes)
+	}
+	return 0, true
+}
+
+// evict forgets the buckets that have refilled, at most once per quotaEvictInterval.
+func (q *tenantQuotas) evict(now time.Time) {
+	if now.Sub(q.evicted) < quotaEvictInterval {
+		return
+	}
+	q.evicted = now
+	burst := q.cfg.Burst.Seconds()
+	for tenant, b := range q.buckets {
+		rowsRate, bytesRate := q.cfg.rates(tenant)
+		elapsed := now.Sub(b.updated).Seconds()
+		if b.rows+elapsed*rowsRate >= rowsRate*burst && b.bytes+elapsed*bytesRate >= bytesRate*burst {
+			delete(q.buckets, tenant)
+		}
+	}
+}
+
+func logsUsage(ld plog.Logs) (rows, bytes int) {
+	return ld.LogRecordCount(), (&plog.ProtoMarshaler{}).LogsSize(ld)
+}
+
+func tracesUsage(td ptrace.Traces) (rows, bytes int) {
+	return td.SpanCount(), (&ptrace.ProtoMarshaler{}).TracesSize(td)
+}
+
+func metricsUsage(md pmetric.Metrics) (rows, bytes int) {
+	return md.DataPointCount(), (&pmetric.ProtoMarshaler{}).MetricsSize(md)
+}
@@ -0,0 +1,76 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package clickhouseexporter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/client"
+	"go.opentelemetry.io/collector/consumer/consumererror"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.uber.org/zap"
+)
+
+func TestTenantQuotas_admit(t *testing.T) {
+	quotas := newTenantQuotas(QuotasConfig{
+		RowsPerSecond: 10,
+		Burst:         time.Second,
+		Tenants:       map[string]TenantQuotaConfig{"big": {RowsPerSecond: 100}},
+	})
+	now := time.Now()
+
+	// The burst is admitted, overdrawing the bucket.
+	_, ok := quotas.admit("acme", 15, 0, now)
+	require.True(t, ok)
+	wait, ok := quotas.admit("acme", 1, 0, now)
+	require.False(t, ok)
+	require.Equal(t, 500*time.Millisecond+time.Millisecond, wait)
+
+	// The other tenants have their own buckets.
+	_, ok = quotas.admit("big", 50, 0, now)
+	require.True(t, ok)
+	_, ok = quotas.admit("big", 50, 0, now)
+	require.True(t, ok)
+
+	_, ok = quotas.admit("acme", 1, 0, now.Add(wait))
+	require.True(t, ok)
+
+	// The bytes are unlimited without a rate.
+	_, ok = quotas.admit("other", 1, 1<<30, now)
+	require.True(t, ok)
+}
+
+func TestWithQuotas(t *testing.T) {
+	ctx := client.NewContext(context.Background(), client.Info{
+		Metadata: client.NewMetadata(map[string][]string{"x-tenant-id": {"acme"}}),
+	})
+	for _, action := range []string{quotaActionDrop, quotaActionDefer} {
+		t.Run(action, func(t *testing.T) {
+			cfg := withDefaultConfig(func(cfg *Config) {
+				cfg.Tenant.MetadataKey = "x-tenant-id"
+				cfg.Quotas.Enabled = true
+				cfg.Quotas.RowsPerSecond = 1
+				cfg.Quotas.Action = action
+			})
+			pushed := 0
+			push := withQuotas(cfg, "logs", zap.NewNop(), nil, func(context.Context, plog.Logs) error {
+				pushed++
+				return nil
+			}, logsUsage)
+
+			require.NoError(t, push(ctx, simpleLogs(2)))
+			err := push(ctx, simpleLogs(2))
+			if action == quotaActionDrop {
+				require.NoError(t, err)
+			} else {
+				require.ErrorContains(t, err, `tenant "acme" is over its logs quota`)
+				require.False(t, consumererror.IsPermanent(err))
+			}
+			require.Equal(t, 1, pushed)
+		})
+	}
+}
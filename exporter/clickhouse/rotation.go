@@ -0,0 +1,82 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package clickhouseexporter // import "github.com/foyer-work/otel-distribution/exporter/clickhouse"
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+
+	"github.com/foyer-work/otel-distribution/exporter/clickhouse/internal"
+)
+
+const (
+	rotationPeriodWeekly  = "weekly"
+	rotationPeriodMonthly = "monthly"
+)
+
+// language=ClickHouse SQL
+const createRotationMergeTableSQL = `CREATE TABLE IF NOT EXISTS %s %s AS %s ENGINE = Merge(%s, %s)`
+
+// validRotation returns whether the period of the rotation is valid and the logs and traces tables can be rotated.
+func (cfg *Config) validRotation() bool {
+	if cfg.Rotation.Period != rotationPeriodWeekly && cfg.Rotation.Period != rotationPeriodMonthly || cfg.jaegerSchema() {
+		return false
+	}
+	tables := []string{cfg.LogsTableName, cfg.TracesTableName}
+	for _, route := range slices.Concat(cfg.Logs.TableRoutes, cfg.Traces.TableRoutes) {
+		tables = append(tables, route.TableName)
+	}
+	for _, table := range tables {
+		if internal.TableTemplate(table).IsTemplate() {
+			return false
+		}
+	}
+	return true
+}
+
+// rotatedTableName returns the template of the tables of the periods of table if rotated, table otherwise.
+func (cfg *Config) rotatedTableName(table string) string {
+	switch {
+	case !cfg.Rotation.Enabled:
+		return table
+	case cfg.Rotation.Period == rotationPeriodWeekly:
+		return table + "_%Gw%V"
+	default:
+		return table + "_%Y%m"
+	}
+}
+
+// rotationTablesRegexp returns the regexp matching the names of the tables of the periods of table.
+func (cfg *Config) rotationTablesRegexp(table string) string {
+	if cfg.Rotation.Period == rotationPeriodWeekly {
+		return "^" + table + "_[0-9]{4}w[0-9]{2}$"
+	}
+	return "^" + table + "_[0-9]{6}$"
+}
+
+// rotationMergeTableName returns the name of the Merge table reading the tables of the periods of table.
+func rotationMergeTableName(table string) string {
+	return table + "_all"
+}
+
+// renderCreateRotationMergeTableSQL renders the Merge table reading the tables of the periods of table, with the
+// columns of the table of the current period.
+func renderCreateRotationMergeTableSQL(cfg *Config, signal SignalConfig, table, current string) string {
+	return fmt.Sprintf(createRotationMergeTableSQL, internal.QuoteIdentifier(rotationMergeTableName(table)), cfg.clusterStringFor(signal),
+		internal.QuoteIdentifier(current), internal.QuoteString(cfg.Database), internal.QuoteString(cfg.rotationTablesRegexp(table)))
+}
+
+// createRotationMergeTable creates the Merge table reading the tables of the periods of table, current being the
+// table of the current period.
+func createRotationMergeTable(ctx context.Context, cfg *Config, db *sql.DB, signal SignalConfig, table, current string) error {
+	if !cfg.schemaObjectsFor(signal).Tables {
+		return nil
+	}
+	if _, err := db.ExecContext(internal.QueryContext(ctx, "create_table"), renderCreateRotationMergeTableSQL(cfg, signal, table, current)); err != nil {
+		return fmt.Errorf("exec create merge table of %s sql: %w", table, err)
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package clickhouseexporter
+
+import (
+	"database/sql/driver"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogsExporter_rotation(t *testing.T) {
+	var queries, inserts []string
+	initClickhouseTestServer(t, func(query string, _ []driver.Value) error {
+		switch {
+		case strings.HasPrefix(query, "INSERT"):
+			inserts = append(inserts, getQueryFirstLine(query))
+		case !strings.HasPrefix(query, "SELECT"):
+			queries = append(queries, query)
+		}
+		return nil
+	})
+	exporter := newTestLogsExporter(t, defaultEndpoint, withDriverName(t.Name()), func(cfg *Config) {
+		cfg.TTL = 72 * time.Hour
+		cfg.Rotation.Enabled = true
+	})
+	current := "otel_logs_" + time.Now().UTC().Format("200601")
+	require.Len(t, queries, 2)
+	require.Contains(t, queries[0], "CREATE TABLE IF NOT EXISTS `"+current+"`")
+	require.NotContains(t, queries[0], "TTL", "the tables of past periods are archived whole")
+	require.Equal(t, "CREATE TABLE IF NOT EXISTS `otel_logs_all`  AS `"+current+"` ENGINE = Merge('default', '^otel_logs_[0-9]{6}$')", queries[1])
+
+	mustPushLogsData(t, exporter, simpleLogs(1))
+	require.Contains(t, queries[2], "CREATE TABLE IF NOT EXISTS `otel_logs_202312`")
+	require.Equal(t, []string{"INSERT INTO `otel_logs_202312`"}, inserts)
+}
+
+func TestConfig_rotatedTableName(t *testing.T) {
+	cfg := withDefaultConfig()
+	require.Equal(t, "otel_traces", cfg.rotatedTableName("otel_traces"))
+
+	cfg.Rotation.Enabled = true
+	require.Equal(t, "otel_traces_%Y%m", cfg.rotatedTableName("otel_traces"))
+	require.Equal(t, "^otel_traces_[0-9]{6}$", cfg.rotationTablesRegexp("otel_traces"))
+
+	cfg.Rotation.Period = rotationPeriodWeekly
+	require.Equal(t, "otel_traces_%Gw%V", cfg.rotatedTableName("otel_traces"))
+	require.Equal(t, "^otel_traces_[0-9]{4}w[0-9]{2}$", cfg.rotationTablesRegexp("otel_traces"))
+}
@@ -0,0 +1,458 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package clickhouseexporter // import "github.com/foyer-work/otel-distribution/exporter/clickhouse"
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.uber.org/zap"
+
+	"github.com/foyer-work/otel-distribution/exporter/clickhouse/internal"
+)
+
+// schemaCompatContribV0126 renders the tables of the v0.126.0 contrib exporter, with its default Map attributes.
+const schemaCompatContribV0126 = "contrib-v0.126"
+
+// schemaCompatVersions are the supported values of schema_compat.
+var schemaCompatVersions = []string{schemaCompatContribV0126}
+
+// The tables of the contrib schema are those created by the upstream exporter, column for column.
+const (
+	// language=ClickHouse SQL
+	createContribLogsTableSQL = `
+CREATE TABLE IF NOT EXISTS %s %s (
+	Timestamp DateTime64(9) CODEC(Delta(8), ZSTD(1)),
+	TimestampTime DateTime DEFAULT toDateTime(Timestamp),
+	TraceId String CODEC(ZSTD(1)),
+	SpanId String CODEC(ZSTD(1)),
+	TraceFlags UInt8,
+	SeverityText LowCardinality(String) CODEC(ZSTD(1)),
+	SeverityNumber UInt8,
+	ServiceName LowCardinality(String) CODEC(ZSTD(1)),
+	Body String CODEC(ZSTD(1)),
+	ResourceSchemaUrl LowCardinality(String) CODEC(ZSTD(1)),
+	ResourceAttributes Map(LowCardinality(String), String) CODEC(ZSTD(1)),
+	ScopeSchemaUrl LowCardinality(String) CODEC(ZSTD(1)),
+	ScopeName String CODEC(ZSTD(1)),
+	ScopeVersion LowCardinality(String) CODEC(ZSTD(1)),
+	ScopeAttributes Map(LowCardinality(String), String) CODEC(ZSTD(1)),
+	LogAttributes Map(LowCardinality(String), String) CODEC(ZSTD(1)),
+
+	INDEX idx_trace_id TraceId TYPE bloom_filter(0.001) GRANULARITY 1,
+	INDEX idx_res_attr_key mapKeys(ResourceAttributes) TYPE bloom_filter(0.01) GRANULARITY 1,
+	INDEX idx_res_attr_value mapValues(ResourceAttributes) TYPE bloom_filter(0.01) GRANULARITY 1,
+	INDEX idx_scope_attr_key mapKeys(ScopeAttributes) TYPE bloom_filter(0.01) GRANULARITY 1,
+	INDEX idx_scope_attr_value mapValues(ScopeAttributes) TYPE bloom_filter(0.01) GRANULARITY 1,
+	INDEX idx_log_attr_key mapKeys(LogAttributes) TYPE bloom_filter(0.01) GRANULARITY 1,
+	INDEX idx_log_attr_value mapValues(LogAttributes) TYPE bloom_filter(0.01) GRANULARITY 1,
+	INDEX idx_body Body TYPE tokenbf_v1(32768, 3, 0) GRANULARITY 8
+) ENGINE = %s
+PARTITION BY toDate(TimestampTime)
+PRIMARY KEY (ServiceName, TimestampTime)
+ORDER BY (ServiceName, TimestampTime, Timestamp)
+%s
+SETTINGS index_granularity = 8192, ttl_only_drop_parts = 1;
+`
+	// language=ClickHouse SQL
+	insertContribLogsSQLTemplate = `INSERT INTO %s (
+                        Timestamp,
+                        TraceId,
+                        SpanId,
+                        TraceFlags,
+                        SeverityText,
+                        SeverityNumber,
+                        ServiceName,
+                        Body,
+                        ResourceSchemaUrl,
+                        ResourceAttributes,
+                        ScopeSchemaUrl,
+                        ScopeName,
+                        ScopeVersion,
+                        ScopeAttributes,
+                        LogAttributes
+                        ) VALUES (
+                                  ?,
+                                  ?,
+                                  ?,
+                                  ?,
+                                  ?,
+                                  ?,
+                                  ?,
+                                  ?,
+                                  ?,
+                                  ?,
+                                  ?,
+                                  ?,
+                                  ?,
+                                  ?,
+                                  ?
+                                  )`
+	// language=ClickHouse SQL
+	createContribTracesTableSQL = `
+CREATE TABLE IF NOT EXISTS %s %s (
+	Timestamp DateTime64(9) CODEC(Delta, ZSTD(1)),
+	TraceId String CODEC(ZSTD(1)),
+	SpanId String CODEC(ZSTD(1)),
+	ParentSpanId String CODEC(ZSTD(1)),
+	TraceState String CODEC(ZSTD(1)),
+	SpanName LowCardinality(String) CODEC(ZSTD(1)),
+	SpanKind LowCardinality(String) CODEC(ZSTD(1)),
+	ServiceName LowCardinality(String) CODEC(ZSTD(1)),
+	ResourceAttributes Map(LowCardinality(String), String) CODEC(ZSTD(1)),
+	ScopeName String CODEC(ZSTD(1)),
+	ScopeVersion String CODEC(ZSTD(1)),
+	SpanAttributes Map(LowCardinality(String), String) CODEC(ZSTD(1)),
+	Duration UInt64 CODEC(ZSTD(1)),
+	StatusCode LowCardinality(String) CODEC(ZSTD(1)),
+	StatusMessage String CODEC(ZSTD(1)),
+	Events Nested (
+		Timestamp DateTime64(9),
+		Name LowCardinality(String),
+		Attributes Map(LowCardinality(String), String)
+	) CODEC(ZSTD(1)),
+	Links Nested (
+		TraceId String,
+		SpanId String,
+		TraceState String,
+		Attributes Map(LowCardinality(String), String)
+	) CODEC(ZSTD(1)),
+	INDEX idx_trace_id TraceId TYPE bloom_filter(0.001) GRANULARITY 1,
+	INDEX idx_res_attr_key mapKeys(ResourceAttributes) TYPE bloom_filter(0.01) GRANULARITY 1,
+	INDEX idx_res_attr_value mapValues(ResourceAttributes) TYPE bloom_filter(0.01) GRANULARITY 1,
+	INDEX idx_span_attr_key mapKeys(SpanAttributes) TYPE bloom_filter(0.01) GRANULARITY 1,
+	INDEX idx_span_attr_value mapValues(SpanAttributes) TYPE bloom_filter(0.01) GRANULARITY 1,
+	INDEX idx_duration Duration TYPE minmax GRANULARITY 1
+) ENGINE = %s
+PARTITION BY toDate(Timestamp)
+ORDER BY (ServiceName, SpanName, toDateTime(Timestamp))
+%s
+SETTINGS index_granularity=8192, ttl_only_drop_parts = 1;
+`
+	// language=ClickHouse SQL
+	insertContribTracesSQLTemplate = `INSERT INTO %s (
+                        Timestamp,
+                        TraceId,
+                        SpanId,
+                        ParentSpanId,
+                        TraceState,
+                        SpanName,
+                        SpanKind,
+                        ServiceName,
+                        ResourceAttributes,
+                        ScopeName,
+                        ScopeVersion,
+                        SpanAttributes,
+                        Duration,
+                        StatusCode,
+                        StatusMessage,
+                        Events.Timestamp,
+                        Events.Name,
+                        Events.Attributes,
+                        Links.TraceId,
+                        Links.SpanId,
+                        Links.TraceState,
+                        Links.Attributes
+                        ) VALUES (
+                                  ?,
+                                  ?,
+                                  ?,
+                                  ?,
+                                  ?,
+                                  ?,
+                                  ?,
+                                  ?,
+                                  ?,
+                                  ?,
+                                  ?,
+                                  ?,
+                                  ?,
+                                  ?,
+                                  ?,
+                                  ?,
+                                  ?,
+                                  ?,
+                                  ?,
+                                  ?,
+                                  ?,
+                                  ?
+                                  )`
+	// language=ClickHouse SQL
+	createContribTraceIDTsTableSQL = `
+CREATE TABLE IF NOT EXISTS %s %s (
+     TraceId String CODEC(ZSTD(1)),
+     Start DateTime CODEC(Delta, ZSTD(1)),
+     End DateTime CODEC(Delta, ZSTD(1)),
+     INDEX idx_trace_id TraceId TYPE bloom_filter(0.01) GRANULARITY 1
+) ENGINE = %s
+PARTITION BY toDate(Start)
+ORDER BY (TraceId, Start)
+%s
+SETTINGS index_granularity=8192, ttl_only_drop_parts = 1;
+`
+	// language=ClickHouse SQL
+	createContribTraceIDTsMaterializedViewSQL = `
+CREATE MATERIALIZED VIEW IF NOT EXISTS %s %s
+TO %s.%s
+AS SELECT
+	TraceId,
+	min(Timestamp) as Start,
+	max(Timestamp) as End
+FROM
+%s.%s
+WHERE TraceId != ''
+GROUP BY TraceId;
+`
+)
+
+// contribSchema reports whether the logs and traces tables are those of the upstream contrib exporter.
+func (cfg *Config) contribSchema() bool {
+	return cfg.SchemaCompat != ""
+}
+
+// validSchemaCompat reports whether schema_compat is supported and the options it conflicts with are unset.
+func (cfg *Config) validSchemaCompat() bool {
+	return slices.Contains(schemaCompatVersions, cfg.SchemaCompat) &&
+		!cfg.Metrics.Enabled && !cfg.jaegerSchema() && !cfg.separateEventsLinks() &&
+		!cfg.Tenant.Enabled && !cfg.Dimensions.Enabled && !cfg.Rotation.Enabled && !cfg.Logs.ErrorLogs.Enabled &&
+		len(cfg.Logs.TableRoutes) == 0 && len(cfg.Traces.TableRoutes) == 0 &&
+		!internal.TableTemplate(cfg.LogsTableName).IsTemplate() && !internal.TableTemplate(cfg.TracesTableName).IsTemplate()
+}
+
+// createContribLogsTable creates the logs table of the contrib schema.
+func createContribLogsTable(ctx context.Context, cfg *Config, db *sql.DB) error {
+	if objects := cfg.schemaObjectsFor(cfg.Logs.SignalConfig); objects.Tables {
+		if _, err := db.ExecContext(internal.QueryContext(ctx, "create_table"), objects.tableDDL(renderCreateContribLogsTableSQL(cfg))); err != nil {
+			return fmt.Errorf("exec create logs table sql: %w", err)
+		}
+		if err := updateTTL(ctx, cfg, db, cfg.Logs.SignalConfig, cfg.LogsTableName, generateTTLExpr(cfg.TTL, "TimestampTime")); err != nil {
+			return err
+		}
+	}
+	return addProjections(ctx, cfg, db, cfg.Logs.SignalConfig, cfg.LogsTableName)
+}
+
+// createContribTracesTable creates the traces table of the contrib schema, its trace id lookup table and
+// materialized view.
+func createContribTracesTable(ctx context.Context, cfg *Config, db *sql.DB) error {
+	objects := cfg.schemaObjectsFor(cfg.Traces.SignalConfig)
+	if objects.Tables {
+		if _, err := db.ExecContext(internal.QueryContext(ctx, "create_table"), objects.tableDDL(renderCreateContribTracesTableSQL(cfg))); err != nil {
+			return fmt.Errorf("exec create traces table sql: %w", err)
+		}
+		if _, err := db.ExecContext(internal.QueryContext(ctx, "create_table"), objects.tableDDL(renderCreateContribTraceIDTsTableSQL(cfg))); err != nil {
+			return fmt.Errorf("exec create traceID timestamp table sql: %w", err)
+		}
+		if err := updateTTL(ctx, cfg, db, cfg.Traces.SignalConfig, cfg.TracesTableName, generateTTLExpr(cfg.TTL, "toDateTime(Timestamp)")); err != nil {
+			return err
+		}
+		if err := updateTTL(ctx, cfg, db, cfg.Traces.SignalConfig, cfg.traceIDTsTableName(), generateTTLExpr(cfg.TTL, "toDateTime(Start)")); err != nil {
+			return err
+		}
+	}
+	if objects.MaterializedViews {
+		if _, err := db.ExecContext(internal.QueryContext(ctx, "create_view"), renderContribTraceIDTsMaterializedViewSQL(cfg)); err != nil {
+			return fmt.Errorf("exec create traceID timestamp view sql: %w", err)
+		}
+	}
+	return addProjections(ctx, cfg, db, cfg.Traces.SignalConfig, cfg.TracesTableName, cfg.traceIDTsTableName())
+}
+
+func renderCreateContribLogsTableSQL(cfg *Config) string {
+	return fmt.Sprintf(createContribLogsTableSQL, internal.QuoteIdentifier(cfg.LogsTableName), cfg.clusterStringFor(cfg.Logs.SignalConfig),
+		cfg.tableEngineStringFor(cfg.Logs.SignalConfig), generateTTLExpr(cfg.TTL, "TimestampTime"))
+}
+
+func renderInsertContribLogsSQL(cfg *Config) string {
+	return fmt.Sprintf(insertContribLogsSQLTemplate, internal.QuoteIdentifier(cfg.LogsTableName))
+}
+
+func renderCreateContribTracesTableSQL(cfg *Config) string {
+	return fmt.Sprintf(createContribTracesTableSQL, internal.QuoteIdentifier(cfg.TracesTableName), cfg.clusterStringFor(cfg.Traces.SignalConfig),
+		cfg.tableEngineStringFor(cfg.Traces.SignalConfig), generateTTLExpr(cfg.TTL, "toDateTime(Timestamp)"))
+}
+
+func renderInsertContribTracesSQL(cfg *Config) string {
+	return fmt.Sprintf(insertContribTracesSQLTemplate, internal.QuoteIdentifier(cfg.TracesTableName))
+}
+
+func renderCreateContribTraceIDTsTableSQL(cfg *Config) string {
+	return fmt.Sprintf(createContribTraceIDTsTableSQL, internal.QuoteIdentifier(cfg.traceIDTsTableName()), cfg.clusterStringFor(cfg.Traces.SignalConfig),
+		cfg.tableEngineStringFor(cfg.Traces.SignalConfig), generateTTLExpr(cfg.TTL, "toDateTime(Start)"))
+}
+
+func renderContribTraceIDTsMaterializedViewSQL(cfg *Config) string {
+	database := internal.QuoteIdentifier(cfg.Database)
+	return fmt.Sprintf(createContribTraceIDTsMaterializedViewSQL, internal.QuoteIdentifier(cfg.TracesTableName+"_trace_id_ts_mv"),
+		cfg.clusterStringFor(cfg.Traces.SignalConfig), database, internal.QuoteIdentifier(cfg.traceIDTsTableName()),
+		database, internal.QuoteIdentifier(cfg.TracesTableName))
+}
+
+// pushContribLogs writes the log records into the logs table of the contrib schema.
+func (e *logsExporter) pushContribLogs(ctx context.Context, ld plog.Logs) error {
+	ctx, observe := internal.ObserveInsert(internal.InsertContext(e.cfg.queryContext(ctx), "insert_logs"), e.cfg.LogsTableName)
+	start := time.Now()
+	attributes := e.cfg.attributeFilter(e.cfg.Logs.SignalConfig)
+	err := doWithTx(ctx, e.client, func(ctx context.Context, tx *sql.Tx) error {
+		statement, err := tx.PrepareContext(ctx, e.insertSQL)
+		if err != nil {
+			return fmt.Errorf("PrepareContext:%w", err)
+		}
+		defer func() {
+			_ = statement.Close()
+		}()
+
+		for i := range ld.ResourceLogs().Len() {
+			logs := ld.ResourceLogs().At(i)
+			res := logs.Resource()
+			resURL := logs.SchemaUrl()
+			resAttr := attributes.Map(res.Attributes())
+			serviceName := internal.GetServiceName(res.Attributes())
+
+			for j := range logs.ScopeLogs().Len() {
+				rs := logs.ScopeLogs().At(j).LogRecords()
+				scopeURL := logs.ScopeLogs().At(j).SchemaUrl()
+				scopeName := logs.ScopeLogs().At(j).Scope().Name()
+				scopeVersion := logs.ScopeLogs().At(j).Scope().Version()
+				scopeAttr := attributes.Map(logs.ScopeLogs().At(j).Scope().Attributes())
+
+				for k := range rs.Len() {
+					r := rs.At(k)
+
+					timestamp := r.Timestamp()
+					if timestamp == 0 {
+						timestamp = r.ObservedTimestamp()
+					}
+
+					_, err = internal.ExecRow(ctx, statement,
+						timestamp.AsTime(),
+						internal.TraceIDToHexOrEmptyString(r.TraceID()),
+						internal.SpanIDToHexOrEmptyString(r.SpanID()),
+						uint8(r.Flags()),
+						r.SeverityText(),
+						uint8(r.SeverityNumber()),
+						serviceName,
+						r.Body().AsString(),
+						resURL,
+						resAttr,
+						scopeURL,
+						scopeName,
+						scopeVersion,
+						scopeAttr,
+						attributes.Map(r.Attributes()),
+					)
+					if err != nil {
+						return fmt.Errorf("ExecContext:%w", err)
+					}
+				}
+			}
+		}
+		return nil
+	})
+	observe(err)
+	if err == nil {
+		e.telemetry.recordTruncatedAttributeValues(ctx, "logs", attributes)
+	}
+	duration := time.Since(start)
+	e.logger.Debug("insert logs", zap.Int("records", ld.LogRecordCount()),
+		zap.String("cost", duration.String()))
+	return err
+}
+
+// pushContribSpans writes the spans into the traces table of the contrib schema.
+func (e *tracesExporter) pushContribSpans(ctx context.Context, td ptrace.Traces) error {
+	ctx, observe := internal.ObserveInsert(internal.InsertContext(e.cfg.queryContext(ctx), "insert_spans"), e.cfg.TracesTableName)
+	start := time.Now()
+	attributes := e.cfg.attributeFilter(e.cfg.Traces.SignalConfig)
+	err := doWithTx(ctx, e.client, func(ctx context.Context, tx *sql.Tx) error {
+		statement, err := tx.PrepareContext(ctx, e.insertSQL)
+		if err != nil {
+			return fmt.Errorf("PrepareContext:%w", err)
+		}
+		defer func() {
+			_ = statement.Close()
+		}()
+		for i := range td.ResourceSpans().Len() {
+			spans := td.ResourceSpans().At(i)
+			res := spans.Resource()
+			resAttr := attributes.Map(res.Attributes())
+			serviceName := internal.GetServiceName(res.Attributes())
+
+			for j := range spans.ScopeSpans().Len() {
+				rs := spans.ScopeSpans().At(j).Spans()
+				scopeName := spans.ScopeSpans().At(j).Scope().Name()
+				scopeVersion := spans.ScopeSpans().At(j).Scope().Version()
+				for k := range rs.Len() {
+					r := rs.At(k)
+					name, spanAttributes := e.spanName(r)
+					status := r.Status()
+					eventTimes, eventNames, eventAttrs := convertContribEvents(r.Events(), attributes)
+					linksTraceIDs, linksSpanIDs, linksTraceStates, linksAttrs := convertContribLinks(r.Links(), attributes)
+					_, err = internal.ExecRow(ctx, statement,
+						r.StartTimestamp().AsTime(),
+						internal.TraceIDToHexOrEmptyString(r.TraceID()),
+						internal.SpanIDToHexOrEmptyString(r.SpanID()),
+						internal.SpanIDToHexOrEmptyString(r.ParentSpanID()),
+						r.TraceState().AsRaw(),
+						name,
+						r.Kind().String(),
+						serviceName,
+						resAttr,
+						scopeName,
+						scopeVersion,
+						attributes.Map(spanAttributes),
+						r.EndTimestamp().AsTime().Sub(r.StartTimestamp().AsTime()).Nanoseconds(),
+						status.Code().String(),
+						status.Message(),
+						eventTimes,
+						eventNames,
+						eventAttrs,
+						linksTraceIDs,
+						linksSpanIDs,
+						linksTraceStates,
+						linksAttrs,
+					)
+					if err != nil {
+						return fmt.Errorf("ExecContext:%w", err)
+					}
+				}
+			}
+		}
+		return nil
+	})
+	observe(err)
+	if err == nil {
+		e.telemetry.recordTruncatedAttributeValues(ctx, "traces", attributes)
+	}
+	duration := time.Since(start)
+	e.logger.Debug("insert traces", zap.Int("records", td.SpanCount()),
+		zap.String("cost", duration.String()))
+	return err
+}
+
+func convertContribEvents(events ptrace.SpanEventSlice, filter *internal.AttributeFilter) (times []time.Time, names []string, attrs []map[string]string) {
+	for i := range events.Len() {
+		event := events.At(i)
+		times = append(times, event.Timestamp().AsTime())
+		names = append(names, event.Name())
+		attrs = append(attrs, filter.Map(event.Attributes()))
+	}
+	return
+}
+
+func convertContribLinks(links ptrace.SpanLinkSlice, filter *internal.AttributeFilter) (traceIDs []string, spanIDs []string, states []string, attrs []map[string]string) {
+	for i := range links.Len() {
+		link := links.At(i)
+		traceIDs = append(traceIDs, internal.TraceIDToHexOrEmptyString(link.TraceID()))
+		spanIDs = append(spanIDs, internal.SpanIDToHexOrEmptyString(link.SpanID()))
+		states = append(states, link.TraceState().AsRaw())
+		attrs = append(attrs, filter.Map(link.Attributes()))
+	}
+	return
+}
@@ -0,0 +1,85 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package clickhouseexporter
+
+import (
+	"database/sql/driver"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogsExporter_schemaCompat(t *testing.T) {
+	var queries []string
+	var values []driver.Value
+	initClickhouseTestServer(t, func(query string, v []driver.Value) error {
+		if strings.HasPrefix(query, "INSERT") {
+			values = v
+		}
+		if !strings.HasPrefix(query, "SELECT") {
+			queries = append(queries, query)
+		}
+		return nil
+	})
+	exporter := newTestLogsExporter(t, defaultEndpoint, withDriverName(t.Name()), func(cfg *Config) {
+		cfg.SchemaCompat = schemaCompatContribV0126
+		cfg.Metrics.Enabled = false
+		cfg.Logs.TraceIDLookup = true
+	})
+	require.Len(t, queries, 1, "trace_id_lookup doesn't apply")
+	require.Equal(t, renderCreateContribLogsTableSQL(exporter.cfg), queries[0])
+	require.Contains(t, queries[0], "\tLogAttributes Map(LowCardinality(String), String) CODEC(ZSTD(1)),\n")
+	require.Contains(t, queries[0], "INDEX idx_log_attr_key mapKeys(LogAttributes) TYPE bloom_filter(0.01) GRANULARITY 1,")
+
+	mustPushLogsData(t, exporter, simpleLogs(1))
+	require.Equal(t, renderInsertContribLogsSQL(exporter.cfg), queries[1])
+	require.Len(t, values, 15)
+	require.Equal(t, "01020300000000000000000000000000", values[1])
+	require.Equal(t, []driver.Value{uint8(0), "error", uint8(18), "test-service", "error message"}, values[3:8])
+	require.Equal(t, map[string]string{"service.name": "test-service"}, values[9])
+	require.Equal(t, map[string]string{"lib": "clickhouse"}, values[13])
+	require.Equal(t, map[string]string{"service.namespace": "default"}, values[14])
+}
+
+func TestTracesExporter_schemaCompat(t *testing.T) {
+	var queries []string
+	var values []driver.Value
+	initClickhouseTestServer(t, func(query string, v []driver.Value) error {
+		if strings.HasPrefix(query, "INSERT") {
+			values = v
+			return nil
+		}
+		if !strings.HasPrefix(query, "SELECT") {
+			queries = append(queries, getQueryFirstLine(query))
+		}
+		return nil
+	})
+	exporter := newTestTracesExporter(t, defaultEndpoint, withDriverName(t.Name()), func(cfg *Config) {
+		cfg.Database = "otel"
+		cfg.TTL = 72 * time.Hour
+		cfg.SchemaCompat = schemaCompatContribV0126
+		cfg.Metrics.Enabled = false
+		cfg.Traces.RootSpans.Enabled = true
+	})
+	require.Equal(t, []string{
+		"CREATE DATABASE IF NOT EXISTS `otel`",
+		"CREATE TABLE IF NOT EXISTS `otel_traces`",
+		"CREATE TABLE IF NOT EXISTS `otel_traces_trace_id_ts`",
+		"CREATE MATERIALIZED VIEW IF NOT EXISTS `otel_traces_trace_id_ts_mv`",
+	}, queries, "root_spans doesn't apply")
+	ddl := renderCreateContribTracesTableSQL(exporter.cfg)
+	require.Contains(t, ddl, "\tEvents Nested (\n\t\tTimestamp DateTime64(9),\n\t\tName LowCardinality(String),\n\t\tAttributes Map(LowCardinality(String), String)\n\t) CODEC(ZSTD(1)),\n")
+	require.Contains(t, ddl, ") ENGINE = MergeTree()\nPARTITION BY toDate(Timestamp)\nORDER BY (ServiceName, SpanName, toDateTime(Timestamp))\nTTL toDateTime(Timestamp) + toIntervalDay(3)\nSETTINGS index_granularity=8192, ttl_only_drop_parts = 1;")
+
+	mustPushTracesData(t, exporter, simpleTraces(1))
+	require.Len(t, values, 22)
+	require.Equal(t, []driver.Value{"01020300000000000000000000000000", "0102030000000000", "0102040000000000", "trace state", "call db", "Internal", "test-service"}, values[1:8])
+	require.Equal(t, map[string]string{"service.name": "v"}, values[11])
+	require.Equal(t, []driver.Value{int64(time.Minute), "Error", "error"}, values[12:15])
+	require.Equal(t, []map[string]string{{"level": "info"}}, values[17])
+	require.Equal(t, []string{"01020500000000000000000000000000"}, values[18])
+	require.Equal(t, []map[string]string{{"k": "v"}}, values[21])
+}
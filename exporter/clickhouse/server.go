@@ -0,0 +1,80 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package clickhouseexporter // import "github.com/foyer-work/otel-distribution/exporter/clickhouse"
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+
+	"go.uber.org/zap"
+
+	"github.com/foyer-work/otel-distribution/exporter/clickhouse/internal"
+)
+
+const (
+	// language=ClickHouse SQL
+	selectServerSQL = `SELECT displayName()`
+	// language=ClickHouse SQL
+	selectVersionSQL = `SELECT version()`
+)
+
+// serverInfo describes the server the exporter connected to on start,
+// which is detected in the background with lazy startup.
+type serverInfo struct {
+	mu          sync.RWMutex
+	displayName string
+	version     string
+}
+
+// detect queries the server description, whose parts the server can't be queried for are left empty.
+func (s *serverInfo) detect(ctx context.Context, logger *zap.Logger, db *sql.DB) {
+	var displayName, version string
+	if err := db.QueryRowContext(internal.QueryContext(ctx, "server_info"), selectServerSQL).Scan(&displayName); err != nil {
+		logger.Debug("unable to detect server display name", zap.Error(err))
+	}
+	if err := db.QueryRowContext(internal.QueryContext(ctx, "server_info"), selectVersionSQL).Scan(&version); err != nil {
+		logger.Debug("unable to detect server version", zap.Error(err))
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.displayName, s.version = displayName, version
+}
+
+// DisplayName returns the display_name of the server, empty until detected.
+func (s *serverInfo) DisplayName() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.displayName
+}
+
+// Version returns the version of the server, empty until detected.
+func (s *serverInfo) Version() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.version
+}
+
+// adaptSchema adapts the tables created with cfg for signal to the features of the server: unless
+// schema_fallback is disabled, JSON columns are created as String if the server lacks the JSON type.
+// Features the server can't be queried for are assumed supported.
+func adaptSchema(ctx context.Context, logger *zap.Logger, cfg *Config, db *sql.DB, server *serverInfo, signal SignalConfig) error {
+	if !cfg.schemaObjectsFor(signal).Tables {
+		return nil
+	}
+	err := checkFeature(ctx, logger, db, featureJSONType)
+	if err == nil {
+		return nil
+	}
+	if version := server.Version(); version != "" {
+		err = fmt.Errorf("clickhouse %s: %w", version, err)
+	}
+	if !cfg.SchemaFallback {
+		return fmt.Errorf("%w, or enable schema_fallback to create JSON columns as String", err)
+	}
+	logger.Warn("creating JSON columns as String", zap.Error(err))
+	cfg.jsonFallback = true
+	return nil
+}
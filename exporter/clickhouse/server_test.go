@@ -0,0 +1,43 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package clickhouseexporter
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestLogsExporter_schemaFallback(t *testing.T) {
+	var ddl string
+	initClickhouseTestServerWithRows(t, func(query string, _ []driver.Value) error {
+		if strings.Contains(query, "CREATE TABLE") {
+			ddl = query
+		}
+		return nil
+	}, func(query string, _ []driver.Value) []string {
+		if query == selectVersionSQL {
+			return []string{"24.3.1.2672"}
+		}
+		// The JSON type settings are unknown.
+		return nil
+	})
+
+	exporter := newTestLogsExporter(t, defaultEndpoint, withDriverName(t.Name()))
+	require.True(t, exporter.cfg.jsonFallback)
+	require.Contains(t, ddl, "\tLogAttributes String COMMENT 'LogRecord.attributes' CODEC(ZSTD(1)),\n")
+	require.NotContains(t, ddl, "JSON")
+
+	exporter, err := newLogsExporter(zaptest.NewLogger(t), withTestExporterConfig(withDriverName(t.Name()), func(cfg *Config) {
+		cfg.SchemaFallback = false
+	})(defaultEndpoint))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = exporter.shutdown(context.Background()) })
+	require.EqualError(t, exporter.start(context.Background(), nil),
+		"clickhouse 24.3.1.2672: the server does not support the JSON type, or enable schema_fallback to create JSON columns as String")
+}
@@ -0,0 +1,109 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package clickhouseexporter // import "github.com/foyer-work/otel-distribution/exporter/clickhouse"
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/foyer-work/otel-distribution/exporter/clickhouse/internal"
+)
+
+const (
+	createServiceMetadataTableSQL = `
+CREATE TABLE IF NOT EXISTS %s %s (
+	ServiceName LowCardinality(String),
+%s
+) ENGINE = %s
+ORDER BY ServiceName;
+`
+	createServiceMetadataDictSQL = `
+CREATE DICTIONARY IF NOT EXISTS %s %s (
+	ServiceName String,
+%s
+)
+PRIMARY KEY ServiceName
+SOURCE(CLICKHOUSE(DB %s TABLE %s))
+LIFETIME(MIN 0 MAX %d)
+LAYOUT(COMPLEX_KEY_HASHED());
+`
+	addServiceMetadataColumnSQL = `ADD COLUMN IF NOT EXISTS %s String ALIAS dictGetOrDefault(%s, %s, tuple(ServiceName), '')`
+)
+
+// validServiceMetadata returns whether the attributes of metadata are distinct column names and its
+// lifetime positive whole seconds.
+func validServiceMetadata(metadata ServiceMetadataConfig) bool {
+	seen := make(map[string]bool, len(metadata.Attributes))
+	for _, attribute := range metadata.Attributes {
+		if !columnNameRegexp.MatchString(attribute) || seen[attribute] {
+			return false
+		}
+		seen[attribute] = true
+	}
+	return len(metadata.Attributes) != 0 && metadata.Lifetime > 0 && metadata.Lifetime%time.Second == 0
+}
+
+// serviceMetadataColumn returns the name of the ALIAS column looking attribute up, e.g. ServiceTeam for team.
+func serviceMetadataColumn(attribute string) string {
+	return "Service" + strings.ToUpper(attribute[:1]) + attribute[1:]
+}
+
+func renderCreateServiceMetadataTableSQL(cfg *Config, signal SignalConfig) string {
+	columns := make([]string, 0, len(cfg.ServiceMetadata.Attributes))
+	for _, attribute := range cfg.ServiceMetadata.Attributes {
+		columns = append(columns, fmt.Sprintf("\t%s String", attribute))
+	}
+	// The latest metadata of a service replaces the previous.
+	engine, ok := cfg.mergeTreeVariantFor(signal, "Replacing")
+	if !ok {
+		engine = cfg.tableEngineStringFor(signal)
+	}
+	return fmt.Sprintf(createServiceMetadataTableSQL, internal.QuoteIdentifier(cfg.serviceMetadataTableName()),
+		cfg.clusterStringFor(signal), strings.Join(columns, ",\n"), engine)
+}
+
+func renderCreateServiceMetadataDictSQL(cfg *Config, signal SignalConfig) string {
+	columns := make([]string, 0, len(cfg.ServiceMetadata.Attributes))
+	for _, attribute := range cfg.ServiceMetadata.Attributes {
+		columns = append(columns, fmt.Sprintf("\t%s String DEFAULT ''", attribute))
+	}
+	return fmt.Sprintf(createServiceMetadataDictSQL, internal.QuoteIdentifier(cfg.serviceMetadataDictName()),
+		cfg.clusterStringFor(signal), strings.Join(columns, ",\n"),
+		internal.QuoteString(cfg.Database), internal.QuoteString(cfg.serviceMetadataTableName()),
+		int64(cfg.ServiceMetadata.Lifetime/time.Second))
+}
+
+// renderAddServiceMetadataColumnsSQL renders the ALTER TABLE adding the ALIAS columns looking the service
+// metadata up to table.
+func renderAddServiceMetadataColumnsSQL(cfg *Config, signal SignalConfig, table string) string {
+	dict := internal.QuoteString(cfg.Database + "." + cfg.serviceMetadataDictName())
+	columns := make([]string, 0, len(cfg.ServiceMetadata.Attributes))
+	for _, attribute := range cfg.ServiceMetadata.Attributes {
+		columns = append(columns, fmt.Sprintf(addServiceMetadataColumnSQL, serviceMetadataColumn(attribute), dict, internal.QuoteString(attribute)))
+	}
+	return fmt.Sprintf("ALTER TABLE %s %s %s", internal.QuoteIdentifier(table), cfg.clusterStringFor(signal), strings.Join(columns, ", "))
+}
+
+// createServiceMetadata creates the service metadata table and dictionary of the database of cfg, and adds the
+// columns looking the metadata up to tables.
+func createServiceMetadata(ctx context.Context, cfg *Config, db *sql.DB, signal SignalConfig, tables ...string) error {
+	if !cfg.ServiceMetadata.Enabled || !cfg.schemaObjectsFor(signal).Tables {
+		return nil
+	}
+	if _, err := db.ExecContext(internal.QueryContext(ctx, "create_table"), renderCreateServiceMetadataTableSQL(cfg, signal)); err != nil {
+		return fmt.Errorf("exec create service metadata table sql: %w", err)
+	}
+	if _, err := db.ExecContext(internal.QueryContext(ctx, "create_dictionary"), renderCreateServiceMetadataDictSQL(cfg, signal)); err != nil {
+		return fmt.Errorf("exec create service metadata dictionary sql: %w", err)
+	}
+	for _, table := range tables {
+		if _, err := db.ExecContext(internal.QueryContext(ctx, "add_column"), renderAddServiceMetadataColumnsSQL(cfg, signal, table)); err != nil {
+			return fmt.Errorf("add service metadata columns to %s: %w", table, err)
+		}
+	}
+	return nil
+}
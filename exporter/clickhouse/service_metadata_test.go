@@ -0,0 +1,50 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package clickhouseexporter
+
+import (
+	"database/sql/driver"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTracesExporter_serviceMetadata(t *testing.T) {
+	var queries []string
+	initClickhouseTestServer(t, func(query string, _ []driver.Value) error {
+		if !strings.HasPrefix(query, "SELECT") {
+			queries = append(queries, query)
+		}
+		return nil
+	})
+	newTestTracesExporter(t, defaultEndpoint, withDriverName(t.Name()), func(cfg *Config) {
+		cfg.Database = "otel"
+		cfg.Traces.RootSpans.Enabled = true
+		cfg.ServiceMetadata.Enabled = true
+		cfg.ServiceMetadata.Attributes = []string{"team", "tier"}
+	})
+
+	var table, dict string
+	var alters []string
+	for _, query := range queries {
+		switch {
+		case strings.Contains(query, "CREATE TABLE IF NOT EXISTS `otel_service_metadata`"):
+			table = query
+		case strings.Contains(query, "CREATE DICTIONARY"):
+			dict = query
+		case strings.HasPrefix(query, "ALTER TABLE") && strings.Contains(query, "ADD COLUMN"):
+			alters = append(alters, query)
+		}
+	}
+	require.Contains(t, table, "\tServiceName LowCardinality(String),\n\tteam String,\n\ttier String\n) ENGINE = ReplacingMergeTree()\nORDER BY ServiceName;")
+	require.Contains(t, dict, "CREATE DICTIONARY IF NOT EXISTS `otel_service_metadata_dict`  (\n\tServiceName String,\n\tteam String DEFAULT '',\n\ttier String DEFAULT ''\n)")
+	require.Contains(t, dict, "SOURCE(CLICKHOUSE(DB 'otel' TABLE 'otel_service_metadata'))\nLIFETIME(MIN 0 MAX 300)\n")
+	require.Equal(t, []string{
+		"ALTER TABLE `otel_traces`  ADD COLUMN IF NOT EXISTS ServiceTeam String ALIAS dictGetOrDefault('otel.otel_service_metadata_dict', 'team', tuple(ServiceName), ''), " +
+			"ADD COLUMN IF NOT EXISTS ServiceTier String ALIAS dictGetOrDefault('otel.otel_service_metadata_dict', 'tier', tuple(ServiceName), '')",
+		"ALTER TABLE `otel_traces_root_spans`  ADD COLUMN IF NOT EXISTS ServiceTeam String ALIAS dictGetOrDefault('otel.otel_service_metadata_dict', 'team', tuple(ServiceName), ''), " +
+			"ADD COLUMN IF NOT EXISTS ServiceTier String ALIAS dictGetOrDefault('otel.otel_service_metadata_dict', 'tier', tuple(ServiceName), '')",
+	}, alters)
+}
@@ -0,0 +1,131 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package clickhouseexporter
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/exporter/exportertest"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/foyer-work/otel-distribution/exporter/clickhouse/internal/metadata"
+)
+
+func TestLogsExporter_shadow(t *testing.T) {
+	var mu sync.Mutex
+	var queries []string
+	var inserts int
+	initClickhouseTestServer(t, func(query string, _ []driver.Value) error {
+		mu.Lock()
+		defer mu.Unlock()
+		switch query = strings.TrimSpace(query); {
+		case strings.HasPrefix(query, "INSERT"):
+			inserts++
+		case strings.HasPrefix(query, "CREATE"):
+			queries = append(queries, query)
+		}
+		return nil
+	})
+	cfg := withDefaultConfig(withDriverName(t.Name()), func(cfg *Config) {
+		cfg.Endpoint = defaultEndpoint
+		cfg.Metrics.Enabled = false
+		cfg.QueueSettings.Enabled = false
+		cfg.Shadow = ShadowConfig{Enabled: true, Database: "otel_next", SampleRatio: 1, IDEncoding: "binary"}
+	})
+	exporter, err := NewFactory().CreateLogs(context.Background(), exportertest.NewNopSettings(metadata.Type), cfg)
+	require.NoError(t, err)
+	require.NoError(t, exporter.Start(context.Background(), componenttest.NewNopHost()))
+	defer func() {
+		require.NoError(t, exporter.Shutdown(context.Background()))
+	}()
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(queries) == 3
+	}, 5*time.Second, 10*time.Millisecond, "the shadow table is created in the background")
+	require.Contains(t, queries[0], "\tTraceId String COMMENT", "the logs table keeps its schema")
+	require.Equal(t, "CREATE DATABASE IF NOT EXISTS `otel_next`", queries[1])
+	require.Contains(t, queries[2], "\tTraceId FixedString(16) COMMENT", "the shadow table has the candidate schema")
+
+	// The batches are inserted into the shadow table once its exporter is ready.
+	var batches int
+	require.Eventually(t, func() bool {
+		if err := exporter.ConsumeLogs(context.Background(), simpleLogs(1)); err != nil {
+			return false
+		}
+		batches++
+		mu.Lock()
+		defer mu.Unlock()
+		return inserts == batches+1
+	}, 5*time.Second, 10*time.Millisecond, "the sampled batch is inserted into the shadow table too")
+}
+
+func TestShadow(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	telemetry := newTestTelemetry(t, reader)
+
+	var shadowed int
+	cfg := withDefaultConfig(func(cfg *Config) {
+		cfg.Shadow = ShadowConfig{Enabled: true, Database: "otel_next"}
+	})
+	shadow := newShadow(cfg, "traces", zaptest.NewLogger(t), telemetry, func(context.Context, string) error {
+		shadowed++
+		return errors.New("unknown table")
+	}, nil, nil)
+	push := shadow.wrap(func(context.Context, string) error { return nil })
+	require.NoError(t, push(context.Background(), "a"))
+	require.Zero(t, shadowed, "no batch is sampled with a zero sample ratio")
+
+	shadow.sampleRatio = 1
+	require.NoError(t, push(context.Background(), "a"), "shadow failures don't fail the batch")
+	require.Equal(t, 1, shadowed)
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	require.Len(t, rm.ScopeMetrics[0].Metrics, 1)
+	require.Equal(t, "otelcol_exporter_clickhouse_shadow_failed_batches", rm.ScopeMetrics[0].Metrics[0].Name)
+}
+
+func TestConfig_shadowConfig(t *testing.T) {
+	cfg := withDefaultConfig(func(cfg *Config) {
+		cfg.Endpoint = defaultEndpoint
+		cfg.Database = "otel"
+		cfg.Logs.PartitionBy = "hourly"
+		cfg.ColumnCodecs = map[string]string{"Body": "ZSTD(3)", "TraceId": "ZSTD(1)"}
+		cfg.Shadow.Enabled = true
+	})
+	require.ErrorIs(t, cfg.Validate(), errConfigShadow)
+	cfg.Shadow.Database = "otel"
+	require.ErrorIs(t, cfg.Validate(), errConfigShadow, "the shadow database differs from the exporter database")
+	cfg.Shadow.Database = "otel_next"
+	cfg.Shadow.SampleRatio = 2
+	require.ErrorIs(t, cfg.Validate(), errConfigShadow)
+	cfg.Shadow.SampleRatio = 0.5
+	cfg.Shadow.IDEncoding = "base64"
+	require.ErrorIs(t, cfg.Validate(), errConfigIDEncoding, "the shadow configuration is validated")
+
+	cfg.Shadow.IDEncoding = ""
+	cfg.Shadow.PartitionBy = "weekly"
+	cfg.Shadow.ColumnCodecs = map[string]string{"Body": "LZ4"}
+	require.NoError(t, cfg.Validate())
+
+	shadowCfg := cfg.shadowConfig()
+	require.Equal(t, "otel_next", shadowCfg.Database)
+	require.Equal(t, "weekly", shadowCfg.PartitionBy)
+	require.Empty(t, shadowCfg.Logs.PartitionBy, "the signal overrides are replaced")
+	require.Equal(t, map[string]string{"Body": "LZ4", "TraceId": "ZSTD(1)"}, shadowCfg.ColumnCodecs)
+	require.Equal(t, map[string]string{"Body": "ZSTD(3)", "TraceId": "ZSTD(1)"}, cfg.ColumnCodecs, "the configuration is unchanged")
+	require.True(t, shadowCfg.LazyConnect)
+	require.False(t, shadowCfg.Shadow.Enabled)
+}
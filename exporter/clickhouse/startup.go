@@ -0,0 +1,106 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package clickhouseexporter // import "github.com/foyer-work/otel-distribution/exporter/clickhouse"
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componentstatus"
+	"go.uber.org/zap"
+)
+
+var errNotReady = errors.New("clickhouse exporter is not ready")
+
+// startupRunner runs the setup of an exporter, connecting and creating the schema,
+// retried as configured by startup_retry.
+// With lazy_connect the setup is retried in the background until it succeeds, so that
+// a clickhouse outage doesn't prevent the collector from starting; batches fail meanwhile.
+// The zero value is ready.
+type startupRunner struct {
+	mu     sync.Mutex
+	err    error
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// start runs setup, in the background if cfg.LazyConnect is set.
+func (r *startupRunner) start(ctx context.Context, host component.Host, logger *zap.Logger, cfg *Config, setup func(context.Context) error) error {
+	retry := cfg.StartupRetry
+	if !cfg.LazyConnect {
+		if retry.Timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, retry.Timeout)
+			defer cancel()
+		}
+		if err := r.retry(ctx, host, logger, retry.Interval, retry.MaxAttempts, setup); err != nil {
+			return err
+		}
+		r.setErr(nil)
+		return nil
+	}
+
+	r.setErr(errNotReady)
+	ctx, r.cancel = context.WithCancel(context.WithoutCancel(ctx))
+	r.done = make(chan struct{})
+	go func() {
+		defer close(r.done)
+		if err := r.retry(ctx, host, logger, retry.Interval, 0, setup); err == nil {
+			componentstatus.ReportStatus(host, componentstatus.NewEvent(componentstatus.StatusOK))
+			r.setErr(nil)
+		}
+	}()
+	return nil
+}
+
+// retry runs setup until it succeeds, maxAttempts (unlimited if zero) were made or ctx is done.
+// Failed attempts are reported through the component status.
+func (r *startupRunner) retry(ctx context.Context, host component.Host, logger *zap.Logger, interval time.Duration, maxAttempts int, setup func(context.Context) error) error {
+	for attempt := 1; ; attempt++ {
+		err := setup(ctx)
+		if err == nil {
+			return nil
+		}
+		r.setErr(fmt.Errorf("%w: %w", errNotReady, err))
+		if ctx.Err() != nil {
+			return errors.Join(err, ctx.Err())
+		}
+		if maxAttempts > 0 && attempt >= maxAttempts {
+			return err
+		}
+		logger.Warn("clickhouse exporter setup failed, retrying", zap.Int("attempt", attempt), zap.Duration("interval", interval), zap.Error(err))
+		componentstatus.ReportStatus(host, componentstatus.NewRecoverableErrorEvent(err))
+		select {
+		case <-ctx.Done():
+			return errors.Join(err, ctx.Err())
+		case <-time.After(interval):
+		}
+	}
+}
+
+// ready returns nil once the setup succeeded, otherwise why it didn't.
+func (r *startupRunner) ready() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// stop cancels the background setup and waits for it to return.
+func (r *startupRunner) stop() {
+	if r.cancel == nil {
+		return
+	}
+	r.cancel()
+	<-r.done
+}
+
+func (r *startupRunner) setErr(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.err = err
+}
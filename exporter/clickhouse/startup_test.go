@@ -0,0 +1,97 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package clickhouseexporter
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component/componentstatus"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestLogsExporter_lazyConnect(t *testing.T) {
+	var creates atomic.Int32
+	initClickhouseTestServer(t, func(query string, _ []driver.Value) error {
+		if strings.HasPrefix(getQueryFirstLine(query), "CREATE TABLE") && creates.Add(1) == 1 {
+			return errors.New("connection refused")
+		}
+		return nil
+	})
+	exporter, err := newLogsExporter(zaptest.NewLogger(t), withTestExporterConfig(withDriverName(t.Name()), func(cfg *Config) {
+		cfg.LazyConnect = true
+		cfg.StartupRetry.Interval = time.Millisecond
+	})(defaultEndpoint))
+	require.NoError(t, err)
+	host := &statusHost{Host: componenttest.NewNopHost()}
+	require.NoError(t, exporter.start(context.Background(), host))
+	t.Cleanup(func() { _ = exporter.shutdown(context.Background()) })
+
+	require.Eventually(t, func() bool {
+		return exporter.startup.ready() == nil
+	}, time.Second, time.Millisecond)
+	require.EqualValues(t, 2, creates.Load())
+	mustPushLogsData(t, exporter, simpleLogs(1))
+
+	require.Len(t, host.events, 2)
+	require.Equal(t, componentstatus.StatusRecoverableError, host.events[0].Status())
+	require.Equal(t, componentstatus.StatusOK, host.events[1].Status())
+
+	t.Run("not ready", func(t *testing.T) {
+		var runner startupRunner
+		block := make(chan struct{})
+		cfg := withDefaultConfig(func(cfg *Config) { cfg.LazyConnect = true })
+		require.NoError(t, runner.start(context.Background(), nil, zaptest.NewLogger(t), cfg, func(ctx context.Context) error {
+			select {
+			case <-block:
+			case <-ctx.Done():
+			}
+			return ctx.Err()
+		}))
+		require.ErrorIs(t, runner.ready(), errNotReady)
+		runner.stop()
+		close(block)
+	})
+}
+
+func TestStartupRunner_retry(t *testing.T) {
+	cfg := withDefaultConfig(func(cfg *Config) {
+		cfg.StartupRetry = StartupRetryConfig{MaxAttempts: 3, Interval: time.Millisecond}
+	})
+	host := &statusHost{Host: componenttest.NewNopHost()}
+	var attempts int
+	var runner startupRunner
+	err := runner.start(context.Background(), host, zaptest.NewLogger(t), cfg, func(context.Context) error {
+		attempts++
+		return errors.New("connection refused")
+	})
+	require.EqualError(t, err, "connection refused")
+	require.Equal(t, 3, attempts)
+	require.Len(t, host.events, 2, "failures before the last attempt are reported")
+
+	attempts = 0
+	require.NoError(t, runner.start(context.Background(), host, zaptest.NewLogger(t), cfg, func(context.Context) error {
+		attempts++
+		if attempts < 2 {
+			return errors.New("connection refused")
+		}
+		return nil
+	}))
+	require.NoError(t, runner.ready())
+
+	t.Run("timeout", func(t *testing.T) {
+		cfg.StartupRetry = StartupRetryConfig{Timeout: 10 * time.Millisecond, Interval: time.Millisecond}
+		err := runner.start(context.Background(), nil, zaptest.NewLogger(t), cfg, func(context.Context) error {
+			return errors.New("connection refused")
+		})
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+}
@@ -0,0 +1,107 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package clickhouseexporter // import "github.com/foyer-work/otel-distribution/exporter/clickhouse"
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/ottl"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/ottl/contexts/ottllog"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/ottl/contexts/ottlspan"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/ottl/ottlfuncs"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// logsTableRoute writes the log records matching its conditions through exporter. The routes write their
+// records in the order of the configuration, those of a templated table name into its tables in no
+// particular order.
+type logsTableRoute struct {
+	conditions ottl.ConditionSequence[ottllog.TransformContext]
+	exporter   *logsExporter
+}
+
+// tracesTableRoute writes the spans matching its conditions through exporter. The routes write their
+// spans in the order of the configuration, those of a templated table name into its tables in no
+// particular order.
+type tracesTableRoute struct {
+	conditions ottl.ConditionSequence[ottlspan.TransformContext]
+	exporter   *tracesExporter
+}
+
+// parseLogsTableRoutes parses the conditions of routes in the log context, ORing those of each route.
+func parseLogsTableRoutes(settings component.TelemetrySettings, routes []TableRouteConfig) ([]ottl.ConditionSequence[ottllog.TransformContext], error) {
+	if len(routes) == 0 {
+		return nil, nil
+	}
+	parser, err := ottllog.NewParser(ottlfuncs.StandardConverters[ottllog.TransformContext](), settings)
+	if err != nil {
+		return nil, err
+	}
+	sequences := make([]ottl.ConditionSequence[ottllog.TransformContext], 0, len(routes))
+	for i, route := range routes {
+		conditions, err := parser.ParseConditions(route.Conditions)
+		if err != nil {
+			return nil, fmt.Errorf("logs::table_routes::%d: %w", i, errors.Join(errConfigTableRoutes, err))
+		}
+		sequences = append(sequences, ottl.NewConditionSequence(conditions, settings,
+			ottl.WithLogicOperation[ottllog.TransformContext](ottl.Or),
+			ottl.WithConditionSequenceErrorMode[ottllog.TransformContext](ottl.IgnoreError)))
+	}
+	return sequences, nil
+}
+
+// parseTracesTableRoutes parses the conditions of routes in the span context, ORing those of each route.
+func parseTracesTableRoutes(settings component.TelemetrySettings, routes []TableRouteConfig) ([]ottl.ConditionSequence[ottlspan.TransformContext], error) {
+	if len(routes) == 0 {
+		return nil, nil
+	}
+	parser, err := ottlspan.NewParser(ottlfuncs.StandardConverters[ottlspan.TransformContext](), settings)
+	if err != nil {
+		return nil, err
+	}
+	sequences := make([]ottl.ConditionSequence[ottlspan.TransformContext], 0, len(routes))
+	for i, route := range routes {
+		conditions, err := parser.ParseConditions(route.Conditions)
+		if err != nil {
+			return nil, fmt.Errorf("traces::table_routes::%d: %w", i, errors.Join(errConfigTableRoutes, err))
+		}
+		sequences = append(sequences, ottl.NewConditionSequence(conditions, settings,
+			ottl.WithLogicOperation[ottlspan.TransformContext](ottl.Or),
+			ottl.WithConditionSequenceErrorMode[ottlspan.TransformContext](ottl.IgnoreError)))
+	}
+	return sequences, nil
+}
+
+// splitLogsByTableRoute groups the log records of ld by the index of the first table route they match,
+// "" for those matching none.
+func splitLogsByTableRoute(ctx context.Context, routes []logsTableRoute, ld plog.Logs) map[string]plog.Logs {
+	return splitLogs(ld, func(rl plog.ResourceLogs, sl plog.ScopeLogs, r plog.LogRecord) string {
+		tCtx := ottllog.NewTransformContext(r, sl.Scope(), rl.Resource(), sl, rl)
+		for i, route := range routes {
+			if match, _ := route.conditions.Eval(ctx, tCtx); match {
+				return strconv.Itoa(i)
+			}
+		}
+		return ""
+	})
+}
+
+// splitTracesByTableRoute groups the spans of td by the index of the first table route they match,
+// "" for those matching none.
+func splitTracesByTableRoute(ctx context.Context, routes []tracesTableRoute, td ptrace.Traces) map[string]ptrace.Traces {
+	return splitTraces(td, func(rs ptrace.ResourceSpans, ss ptrace.ScopeSpans, span ptrace.Span) string {
+		tCtx := ottlspan.NewTransformContext(span, ss.Scope(), rs.Resource(), ss, rs)
+		for i, route := range routes {
+			if match, _ := route.conditions.Eval(ctx, tCtx); match {
+				return strconv.Itoa(i)
+			}
+		}
+		return ""
+	})
+}
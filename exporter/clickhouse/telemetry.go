@@ -0,0 +1,280 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package clickhouseexporter // import "github.com/foyer-work/otel-distribution/exporter/clickhouse"
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/consumer/consumererror"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+
+	"github.com/foyer-work/otel-distribution/exporter/clickhouse/internal"
+	"github.com/foyer-work/otel-distribution/exporter/clickhouse/internal/metadata"
+)
+
+// exporterTelemetry records the internal telemetry of an exporter. A nil exporterTelemetry records nothing.
+type exporterTelemetry struct {
+	tracer              trace.Tracer
+	skippedRows         metric.Int64Counter
+	tooManyPartsInserts metric.Int64Counter
+	throttleDelay       metric.Float64Gauge
+	droppedBatches      metric.Int64Counter
+	insertedRows        metric.Int64Counter
+	insertedBytes       metric.Int64Counter
+	failedRows          metric.Int64Counter
+	failedBytes         metric.Int64Counter
+	insertDuration      metric.Float64Histogram
+	insertRetries       metric.Int64Counter
+	limitedDataPoints   metric.Int64Counter
+	truncatedBodies     metric.Int64Counter
+	truncatedAttrValues metric.Int64Counter
+	quotaExceededRows   metric.Int64Counter
+	mirrorFailures      metric.Int64Counter
+	shadowFailures      metric.Int64Counter
+}
+
+func newExporterTelemetry(settings component.TelemetrySettings) (*exporterTelemetry, error) {
+	meter := settings.MeterProvider.Meter(metadata.ScopeName)
+	t := &exporterTelemetry{tracer: settings.TracerProvider.Tracer(metadata.ScopeName)}
+	var errs, err error
+	t.skippedRows, err = meter.Int64Counter("otelcol_exporter_clickhouse_skipped_rows",
+		metric.WithDescription("Number of rows skipped because they could not be converted to the column types of their table."),
+		metric.WithUnit("{rows}"))
+	errs = errors.Join(errs, err)
+	t.tooManyPartsInserts, err = meter.Int64Counter("otelcol_exporter_clickhouse_too_many_parts",
+		metric.WithDescription("Number of inserts rejected by clickhouse with TOO_MANY_PARTS."),
+		metric.WithUnit("{inserts}"))
+	errs = errors.Join(errs, err)
+	t.throttleDelay, err = meter.Float64Gauge("otelcol_exporter_clickhouse_throttle_delay",
+		metric.WithDescription("Delay applied before inserts while clickhouse has too many parts."),
+		metric.WithUnit("s"))
+	errs = errors.Join(errs, err)
+	t.droppedBatches, err = meter.Int64Counter("otelcol_exporter_clickhouse_dropped_batches",
+		metric.WithDescription("Number of batches dropped by the drop_oldest queue full policy."),
+		metric.WithUnit("{batches}"))
+	errs = errors.Join(errs, err)
+	t.insertedRows, err = meter.Int64Counter("otelcol_exporter_clickhouse_inserted_rows",
+		metric.WithDescription("Number of rows inserted, per table."),
+		metric.WithUnit("{rows}"))
+	errs = errors.Join(errs, err)
+	t.insertedBytes, err = meter.Int64Counter("otelcol_exporter_clickhouse_inserted_bytes",
+		metric.WithDescription("Approximate uncompressed size of the rows inserted, per table."),
+		metric.WithUnit("By"))
+	errs = errors.Join(errs, err)
+	t.failedRows, err = meter.Int64Counter("otelcol_exporter_clickhouse_failed_rows",
+		metric.WithDescription("Number of rows of failed inserts, per table."),
+		metric.WithUnit("{rows}"))
+	errs = errors.Join(errs, err)
+	t.failedBytes, err = meter.Int64Counter("otelcol_exporter_clickhouse_failed_bytes",
+		metric.WithDescription("Approximate uncompressed size of the rows of failed inserts, per table."),
+		metric.WithUnit("By"))
+	errs = errors.Join(errs, err)
+	t.insertDuration, err = meter.Float64Histogram("otelcol_exporter_clickhouse_insert_duration",
+		metric.WithDescription("Duration of inserts, per table and outcome."),
+		metric.WithUnit("s"))
+	errs = errors.Join(errs, err)
+	t.insertRetries, err = meter.Int64Counter("otelcol_exporter_clickhouse_insert_retries",
+		metric.WithDescription("Number of failed batches retried with retry_on_failure."),
+		metric.WithUnit("{batches}"))
+	errs = errors.Join(errs, err)
+	t.limitedDataPoints, err = meter.Int64Counter("otelcol_exporter_clickhouse_cardinality_limited_datapoints",
+		metric.WithDescription("Number of datapoints of series above the cardinality limit of their metric, dropped or aggregated, per metric."),
+		metric.WithUnit("{datapoints}"))
+	errs = errors.Join(errs, err)
+	t.truncatedBodies, err = meter.Int64Counter("otelcol_exporter_clickhouse_truncated_log_bodies",
+		metric.WithDescription("Number of log bodies truncated to max_body_bytes, per table."),
+		metric.WithUnit("{records}"))
+	errs = errors.Join(errs, err)
+	t.truncatedAttrValues, err = meter.Int64Counter("otelcol_exporter_clickhouse_truncated_attribute_values",
+		metric.WithDescription("Number of attribute values truncated to max_attribute_value_bytes, per signal."),
+		metric.WithUnit("{values}"))
+	errs = errors.Join(errs, err)
+	t.quotaExceededRows, err = meter.Int64Counter("otelcol_exporter_clickhouse_quota_exceeded_rows",
+		metric.WithDescription("Number of rows of the batches dropped or deferred because their tenant was over its quota, per tenant."),
+		metric.WithUnit("{rows}"))
+	errs = errors.Join(errs, err)
+	t.mirrorFailures, err = meter.Int64Counter("otelcol_exporter_clickhouse_mirror_failed_batches",
+		metric.WithDescription("Number of batches failing to be written into the best-effort mirror cluster."),
+		metric.WithUnit("{batches}"))
+	errs = errors.Join(errs, err)
+	t.shadowFailures, err = meter.Int64Counter("otelcol_exporter_clickhouse_shadow_failed_batches",
+		metric.WithDescription("Number of sampled batches failing to be written into the shadow tables."),
+		metric.WithUnit("{batches}"))
+	errs = errors.Join(errs, err)
+	if errs != nil {
+		return nil, errs
+	}
+	return t, nil
+}
+
+// observeInserts returns a copy of ctx on which the inserts into each table are recorded and traced,
+// failed inserts are logged with the fields of insertErrorFields and all are shown on the debug page.
+// Templated tables push through child exporters, observed on the context of the parent.
+func (t *exporterTelemetry) observeInserts(ctx context.Context, logger *zap.Logger, server *serverInfo, debug *debugStats) context.Context {
+	if internal.InsertsObserved(ctx) {
+		return ctx
+	}
+	ctx = internal.WithInsertObserver(ctx, func(ctx context.Context, table string, rows, bytes int64, duration time.Duration, err error) {
+		if err != nil {
+			logger.Warn("insert failed", insertErrorFields(ctx, table, server, err)...)
+		}
+		debug.recordInsert(table, rows, duration, err)
+		if t != nil {
+			t.recordInsert(ctx, table, rows, bytes, duration, err)
+		}
+	})
+	if t == nil {
+		return ctx
+	}
+	return internal.WithInsertTracer(ctx, t.tracer)
+}
+
+// insertErrorFields returns the structured fields describing a failed insert: the affected table, the
+// query_id and server of the insert when known, and the code and name of the clickhouse exception, if any,
+// so that alerting can key off specific error classes.
+func insertErrorFields(ctx context.Context, table string, server *serverInfo, err error) []zap.Field {
+	fields := []zap.Field{zap.String("table", table)}
+	if queryID := internal.QueryID(ctx); queryID != "" {
+		fields = append(fields, zap.String("query_id", queryID))
+	}
+	if displayName := server.DisplayName(); displayName != "" {
+		fields = append(fields, zap.String("server_display_name", displayName))
+	}
+	var exception *clickhouse.Exception
+	if errors.As(err, &exception) {
+		fields = append(fields,
+			zap.Int32("error_code", exception.Code),
+			zap.String("error_name", exception.Name),
+			zap.String("error_message", exception.Message),
+		)
+	}
+	return append(fields, zap.Error(err))
+}
+
+func (t *exporterTelemetry) recordInsert(ctx context.Context, table string, rows, bytes int64, duration time.Duration, err error) {
+	tableAttr := metric.WithAttributes(attribute.String("table", table))
+	outcome := "success"
+	if err != nil {
+		outcome = "failure"
+		t.failedRows.Add(ctx, rows, tableAttr)
+		t.failedBytes.Add(ctx, bytes, tableAttr)
+	} else {
+		t.insertedRows.Add(ctx, rows, tableAttr)
+		t.insertedBytes.Add(ctx, bytes, tableAttr)
+	}
+	t.insertDuration.Record(ctx, duration.Seconds(), metric.WithAttributes(attribute.String("table", table), attribute.String("outcome", outcome)))
+}
+
+// withRetryCount wraps push to count the failed batches that retry_on_failure retries, those failing with
+// an error that is not permanent. The last attempt of a batch is counted too, as push can't tell it apart.
+func withRetryCount[T any](t *exporterTelemetry, cfg *Config, signal string, push func(context.Context, T) error) func(context.Context, T) error {
+	if t == nil || !cfg.BackOffConfig.Enabled {
+		return push
+	}
+	return func(ctx context.Context, data T) error {
+		err := push(ctx, data)
+		if err != nil && !consumererror.IsPermanent(err) && !errors.Is(err, context.Canceled) {
+			t.insertRetries.Add(ctx, 1, metric.WithAttributes(attribute.String("signal", signal)))
+		}
+		return err
+	}
+}
+
+// skipInvalidRows returns a copy of ctx on which the rows failing to bind are skipped if enabled by cfg,
+// and a function logging and counting the rows skipped on it once the push is done.
+func (t *exporterTelemetry) skipInvalidRows(ctx context.Context, cfg *Config, logger *zap.Logger, signal string) (context.Context, func()) {
+	// Templated tables push through child exporters sharing the skipped rows of the parent.
+	if !cfg.SkipInvalidRows || internal.SkippingRows(ctx) {
+		return ctx, func() {}
+	}
+	skipped := &internal.SkippedRows{}
+	return internal.WithSkippedRows(ctx, skipped), func() {
+		count, err := skipped.Count()
+		if count == 0 {
+			return
+		}
+		logger.Warn("skipped rows failing to bind", zap.String("signal", signal), zap.Int64("rows", count), zap.Error(err))
+		if t != nil {
+			t.skippedRows.Add(ctx, count, metric.WithAttributes(attribute.String("signal", signal)))
+		}
+	}
+}
+
+// recordTooManyParts counts an insert rejected with TOO_MANY_PARTS.
+func (t *exporterTelemetry) recordTooManyParts(ctx context.Context, signal string) {
+	if t != nil {
+		t.tooManyPartsInserts.Add(ctx, 1, metric.WithAttributes(attribute.String("signal", signal)))
+	}
+}
+
+// recordThrottleDelay records the delay applied before inserts.
+func (t *exporterTelemetry) recordThrottleDelay(ctx context.Context, signal string, delay time.Duration) {
+	if t != nil {
+		t.throttleDelay.Record(ctx, delay.Seconds(), metric.WithAttributes(attribute.String("signal", signal)))
+	}
+}
+
+// recordDroppedBatch counts a batch dropped because the sending queue was full.
+func (t *exporterTelemetry) recordDroppedBatch(ctx context.Context, signal string) {
+	if t != nil {
+		t.droppedBatches.Add(ctx, 1, metric.WithAttributes(attribute.String("signal", signal)))
+	}
+}
+
+// recordCardinalityLimits logs the metrics exceeding their cardinality limit and counts their limited datapoints.
+func (t *exporterTelemetry) recordCardinalityLimits(ctx context.Context, logger *zap.Logger, cfg CardinalityLimitConfig, limits internal.CardinalityLimits) {
+	for _, name := range limits.Exceeded {
+		logger.Warn("metric exceeds the cardinality limit, the datapoints of its new series are limited",
+			zap.String("metric", name), zap.Int("max_series_per_metric", cfg.MaxSeriesPerMetric), zap.String("action", cfg.Action))
+	}
+	if t == nil {
+		return
+	}
+	for name, count := range limits.DataPoints {
+		t.limitedDataPoints.Add(ctx, int64(count), metric.WithAttributes(attribute.String("metric", name)))
+	}
+}
+
+// recordTruncatedBodies counts the log bodies of the records inserted into table that were truncated.
+func (t *exporterTelemetry) recordTruncatedBodies(ctx context.Context, table string, count int64) {
+	if t != nil && count > 0 {
+		t.truncatedBodies.Add(ctx, count, metric.WithAttributes(attribute.String("table", table)))
+	}
+}
+
+// recordTruncatedAttributeValues counts the attribute values of signal truncated by filter.
+func (t *exporterTelemetry) recordTruncatedAttributeValues(ctx context.Context, signal string, filter *internal.AttributeFilter) {
+	if count := filter.Truncated(); t != nil && count > 0 {
+		t.truncatedAttrValues.Add(ctx, count, metric.WithAttributes(attribute.String("signal", signal)))
+	}
+}
+
+// recordQuotaExceeded counts the rows of a batch of tenant over its quota, dropped or deferred by action.
+func (t *exporterTelemetry) recordQuotaExceeded(ctx context.Context, signal, tenant, action string, rows int) {
+	if t != nil {
+		t.quotaExceededRows.Add(ctx, int64(rows), metric.WithAttributes(
+			attribute.String("signal", signal), attribute.String("tenant", tenant), attribute.String("action", action)))
+	}
+}
+
+// recordMirrorFailure counts a batch of signal failing to be written into the mirror.
+func (t *exporterTelemetry) recordMirrorFailure(ctx context.Context, signal string) {
+	if t != nil {
+		t.mirrorFailures.Add(ctx, 1, metric.WithAttributes(attribute.String("signal", signal)))
+	}
+}
+
+// recordShadowFailure counts a batch of signal failing to be written into the shadow tables.
+func (t *exporterTelemetry) recordShadowFailure(ctx context.Context, signal string) {
+	if t != nil {
+		t.shadowFailures.Add(ctx, 1, metric.WithAttributes(attribute.String("signal", signal)))
+	}
+}
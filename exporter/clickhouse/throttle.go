@@ -0,0 +1,95 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package clickhouseexporter // import "github.com/foyer-work/otel-distribution/exporter/clickhouse"
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"go.uber.org/zap"
+)
+
+// tooManyPartsCode is the clickhouse error code of an insert rejected because the partition has too many parts.
+const tooManyPartsCode = 252
+
+func isTooManyParts(err error) bool {
+	var exception *clickhouse.Exception
+	return errors.As(err, &exception) && exception.Code == tooManyPartsCode
+}
+
+// insertThrottle delays inserts while clickhouse rejects them with TOO_MANY_PARTS, doubling the delay
+// on each rejection and halving it on each successful insert. Meanwhile the sending queue keeps
+// accumulating data, so that fewer and larger batches are inserted until the merges catch up.
+type insertThrottle struct {
+	cfg       TooManyPartsBackoffConfig
+	signal    string
+	logger    *zap.Logger
+	telemetry *exporterTelemetry
+
+	mu    sync.Mutex
+	delay time.Duration
+	until time.Time
+}
+
+func newInsertThrottle(cfg TooManyPartsBackoffConfig, signal string, logger *zap.Logger, telemetry *exporterTelemetry) *insertThrottle {
+	return &insertThrottle{cfg: cfg, signal: signal, logger: logger, telemetry: telemetry}
+}
+
+// wait blocks until the next insert is allowed or ctx is done.
+func (t *insertThrottle) wait(ctx context.Context) error {
+	t.mu.Lock()
+	wait := time.Until(t.until)
+	t.mu.Unlock()
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// observe adjusts the delay to the outcome of an insert.
+func (t *insertThrottle) observe(ctx context.Context, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	switch {
+	case isTooManyParts(err):
+		t.delay = min(max(2*t.delay, t.cfg.InitialInterval), t.cfg.MaxInterval)
+		t.logger.Warn("clickhouse has too many parts, slowing down inserts", zap.String("signal", t.signal), zap.Duration("delay", t.delay), zap.Error(err))
+		t.telemetry.recordTooManyParts(ctx, t.signal)
+	case err == nil && t.delay > 0:
+		t.delay /= 2
+		if t.delay < t.cfg.InitialInterval {
+			t.delay = 0
+			t.logger.Info("clickhouse accepts inserts again, no longer slowing them down", zap.String("signal", t.signal))
+		}
+	default:
+		return
+	}
+	t.until = time.Now().Add(t.delay)
+	t.telemetry.recordThrottleDelay(ctx, t.signal, t.delay)
+}
+
+// withThrottle wraps push to wait for t before each insert. push is returned unchanged if t is disabled.
+func withThrottle[T any](t *insertThrottle, push func(context.Context, T) error) func(context.Context, T) error {
+	if !t.cfg.Enabled {
+		return push
+	}
+	return func(ctx context.Context, data T) error {
+		if err := t.wait(ctx); err != nil {
+			return err
+		}
+		err := push(ctx, data)
+		t.observe(ctx, err)
+		return err
+	}
+}
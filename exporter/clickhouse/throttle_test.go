@@ -0,0 +1,69 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package clickhouseexporter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/stretchr/testify/require"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestInsertThrottle(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	telemetry := newTestTelemetry(t, reader)
+	throttle := newInsertThrottle(TooManyPartsBackoffConfig{Enabled: true, InitialInterval: 10 * time.Millisecond, MaxInterval: 30 * time.Millisecond}, "logs", zaptest.NewLogger(t), telemetry)
+
+	tooManyParts := fmt.Errorf("insert: %w", &clickhouse.Exception{Code: tooManyPartsCode, Message: "Too many parts (300)"})
+	push := withThrottle(throttle, func(_ context.Context, err error) error { return err })
+
+	var delays []time.Duration
+	for _, err := range []error{tooManyParts, tooManyParts, tooManyParts, errors.New("connection refused"), nil, nil} {
+		_ = push(context.Background(), err)
+		delays = append(delays, throttle.delay)
+	}
+	require.Equal(t, []time.Duration{
+		10 * time.Millisecond,
+		20 * time.Millisecond,
+		30 * time.Millisecond,
+		30 * time.Millisecond,
+		15 * time.Millisecond,
+		0,
+	}, delays)
+
+	throttle.observe(context.Background(), tooManyParts)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.ErrorIs(t, push(ctx, nil), context.Canceled, "inserts wait for the delay")
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	metrics := map[string]metricdata.Aggregation{}
+	for _, m := range rm.ScopeMetrics[0].Metrics {
+		metrics[m.Name] = m.Data
+	}
+	require.Equal(t, int64(4), metrics["otelcol_exporter_clickhouse_too_many_parts"].(metricdata.Sum[int64]).DataPoints[0].Value)
+	require.InDelta(t, 0.01, metrics["otelcol_exporter_clickhouse_throttle_delay"].(metricdata.Gauge[float64]).DataPoints[0].Value, 1e-9)
+
+	t.Run("disabled", func(t *testing.T) {
+		cfg := withDefaultConfig(func(cfg *Config) {
+			cfg.Endpoint = defaultEndpoint
+			cfg.TooManyPartsBackoff.MaxInterval = 0
+		})
+		require.ErrorIs(t, cfg.Validate(), errConfigTooManyParts)
+
+		cfg.TooManyPartsBackoff.Enabled = false
+		require.NoError(t, cfg.Validate())
+		throttle := newInsertThrottle(cfg.TooManyPartsBackoff, "logs", zaptest.NewLogger(t), nil)
+		_ = withThrottle(throttle, func(context.Context, error) error { return nil })(context.Background(), tooManyParts)
+		require.Zero(t, throttle.delay)
+	})
+}
@@ -0,0 +1,112 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package clickhouseexporter // import "github.com/foyer-work/otel-distribution/exporter/clickhouse"
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componentstatus"
+	"go.uber.org/zap"
+
+	"github.com/foyer-work/otel-distribution/exporter/clickhouse/internal"
+)
+
+const (
+	// language=ClickHouse SQL
+	checkGrantSQL = `CHECK GRANT %s`
+	// language=ClickHouse SQL
+	selectSettingSQL = `SELECT value FROM system.settings WHERE name = ?`
+)
+
+// serverFeature is a server capability the tables of a signal depend on,
+// available when any of its settings is enabled.
+type serverFeature struct {
+	name     string
+	settings []string
+}
+
+var (
+	featureJSONType      = serverFeature{name: "the JSON type", settings: []string{"enable_json_type", "allow_experimental_json_type"}}
+	featureFlattenNested = serverFeature{name: "Nested column flattening", settings: []string{"flatten_nested"}}
+)
+
+// allTables stands for the tables of a signal whose names are only known when written to.
+const allTables = "*"
+
+// verifyStart checks that the exporter user may create the schema objects of a signal and insert
+// into its tables, and that the server supports the features they depend on. Failures are logged and
+// reported through the component status instead of surfacing with the first batch.
+// Checks the server can't answer, e.g. CHECK GRANT on versions before 24.5, are skipped.
+func verifyStart(ctx context.Context, host component.Host, logger *zap.Logger, cfg *Config, db *sql.DB, signal SignalConfig, tables []string, features ...serverFeature) {
+	err := errors.Join(verifyGrants(ctx, logger, cfg, db, signal, tables), verifyFeatures(ctx, logger, db, features))
+	if err == nil {
+		return
+	}
+	logger.Warn("startup verification failed", zap.Error(err))
+	componentstatus.ReportStatus(host, componentstatus.NewRecoverableErrorEvent(err))
+}
+
+func verifyGrants(ctx context.Context, logger *zap.Logger, cfg *Config, db *sql.DB, signal SignalConfig, tables []string) (err error) {
+	database := internal.QuoteIdentifier(cfg.Database)
+	var grants []string
+	if objects := cfg.schemaObjectsFor(signal); objects.Tables {
+		grants = append(grants, "CREATE TABLE ON "+database+".*")
+	}
+	for _, table := range tables {
+		if table != allTables {
+			table = internal.QuoteIdentifier(table)
+		}
+		grants = append(grants, "INSERT ON "+database+"."+table)
+	}
+
+	for _, grant := range grants {
+		var granted bool
+		if e := db.QueryRowContext(internal.QueryContext(ctx, "check_grant"), fmt.Sprintf(checkGrantSQL, grant)).Scan(&granted); e != nil {
+			logger.Debug("unable to check grant", zap.String("grant", grant), zap.Error(e))
+			continue
+		}
+		if !granted {
+			err = errors.Join(err, fmt.Errorf("the exporter user is missing the grant %s", grant))
+		}
+	}
+	return err
+}
+
+func verifyFeatures(ctx context.Context, logger *zap.Logger, db *sql.DB, features []serverFeature) (err error) {
+	for _, feature := range features {
+		err = errors.Join(err, checkFeature(ctx, logger, db, feature))
+	}
+	return err
+}
+
+// checkFeature returns an error describing why feature is unavailable, nil if it is supported
+// or if the server can't be queried for it.
+func checkFeature(ctx context.Context, logger *zap.Logger, db *sql.DB, feature serverFeature) error {
+	supported, known := false, false
+	for _, setting := range feature.settings {
+		var value string
+		e := db.QueryRowContext(internal.QueryContext(ctx, "check_setting"), selectSettingSQL, setting).Scan(&value)
+		if errors.Is(e, sql.ErrNoRows) {
+			continue
+		}
+		if e != nil {
+			logger.Debug("unable to check setting", zap.String("setting", setting), zap.Error(e))
+			return nil
+		}
+		known = true
+		supported = supported || value == "1" || strings.EqualFold(value, "true")
+	}
+	switch {
+	case !known:
+		return fmt.Errorf("the server does not support %s", feature.name)
+	case !supported:
+		return fmt.Errorf("%s is disabled, enable one of the settings %s", feature.name, strings.Join(feature.settings, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package clickhouseexporter
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componentstatus"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.uber.org/zap/zaptest"
+)
+
+type statusHost struct {
+	component.Host
+	events []*componentstatus.Event
+}
+
+func (h *statusHost) Report(event *componentstatus.Event) {
+	h.events = append(h.events, event)
+}
+
+func TestTracesExporter_verifyStart(t *testing.T) {
+	var checks []string
+	initClickhouseTestServerWithRows(t, func(string, []driver.Value) error {
+		return nil
+	}, func(query string, values []driver.Value) []string {
+		switch {
+		case query == selectServerSQL:
+			return []string{"clickhouse-01"}
+		case query == selectVersionSQL:
+			return []string{"25.3.1.1"}
+		case strings.HasPrefix(query, "CHECK GRANT"):
+			checks = append(checks, query)
+			if strings.HasPrefix(query, "CHECK GRANT INSERT") {
+				return []string{"0"}
+			}
+			return []string{"1"}
+		case values[0] == "flatten_nested":
+			return []string{"0"}
+		case values[0] == "enable_json_type":
+			return []string{"1"}
+		}
+		return nil
+	})
+	exporter, err := newTracesExporter(zaptest.NewLogger(t), withTestExporterConfig(withDriverName(t.Name()))(defaultEndpoint))
+	require.NoError(t, err)
+	host := &statusHost{Host: componenttest.NewNopHost()}
+	require.NoError(t, exporter.start(context.Background(), host))
+	t.Cleanup(func() { _ = exporter.shutdown(context.Background()) })
+
+	require.Equal(t, []string{
+		"CHECK GRANT CREATE TABLE ON `default`.*",
+		"CHECK GRANT INSERT ON `default`.`otel_traces`",
+	}, checks)
+	require.Equal(t, "clickhouse-01", exporter.server.DisplayName())
+	require.Equal(t, "25.3.1.1", exporter.server.Version())
+	require.Len(t, host.events, 1)
+	require.Equal(t, componentstatus.StatusRecoverableError, host.events[0].Status())
+	require.EqualError(t, host.events[0].Err(), "the exporter user is missing the grant INSERT ON `default`.`otel_traces`\n"+
+		"Nested column flattening is disabled, enable one of the settings flatten_nested")
+}
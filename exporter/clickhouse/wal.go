@@ -0,0 +1,277 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package clickhouseexporter // import "github.com/foyer-work/otel-distribution/exporter/clickhouse"
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/consumer/consumererror"
+	"go.uber.org/zap"
+
+	"github.com/foyer-work/otel-distribution/exporter/clickhouse/internal"
+)
+
+const writeAheadLogFileExt = ".wal"
+
+// writeAheadLog appends batches to a directory and acknowledges them, inserting them
+// in the background in the order they were written and removing them once inserted.
+type writeAheadLog[T any] struct {
+	cfg       WriteAheadLogConfig
+	signal    string
+	tenant    TenantConfig
+	timeout   time.Duration
+	logger    *zap.Logger
+	push      func(context.Context, T) error
+	marshal   func(T) ([]byte, error)
+	unmarshal func([]byte) (T, error)
+
+	// pending is the batch of the file being inserted, only used by the background inserts.
+	pending walBatch[T]
+
+	mu     sync.Mutex
+	seq    int64
+	notify chan struct{}
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// walBatch is a batch decoded from a file of the write-ahead log.
+type walBatch[T any] struct {
+	file   string
+	tenant string
+	data   T
+	err    error
+}
+
+// withWriteAheadLog wraps push to append batches to the write-ahead log, returning the log inserting them.
+// push is returned unchanged with a nil log if the write-ahead log is disabled.
+func withWriteAheadLog[T any](cfg *Config, signal string, logger *zap.Logger, push func(context.Context, T) error, marshal func(T) ([]byte, error), unmarshal func([]byte) (T, error)) (func(context.Context, T) error, *writeAheadLog[T]) {
+	if !cfg.WriteAheadLog.Enabled {
+		return push, nil
+	}
+	w := &writeAheadLog[T]{
+		cfg:       cfg.WriteAheadLog,
+		signal:    signal,
+		tenant:    cfg.Tenant,
+		logger:    logger,
+		push:      push,
+		marshal:   marshal,
+		unmarshal: unmarshal,
+		notify:    make(chan struct{}, 1),
+	}
+	// The dead letter queue applies the timeout to each of its attempts.
+	if !cfg.DeadLetterQueue.Enabled {
+		w.timeout = cfg.TimeoutSettings.Timeout
+	}
+	return w.append, w
+}
+
+// lifecycle returns start and shutdown running the write-ahead log after start and stopping it before shutdown.
+// They are returned unchanged if w is nil.
+func (w *writeAheadLog[T]) lifecycle(start component.StartFunc, shutdown component.ShutdownFunc) (component.StartFunc, component.ShutdownFunc) {
+	if w == nil {
+		return start, shutdown
+	}
+	return func(ctx context.Context, host component.Host) error {
+			if err := start(ctx, host); err != nil {
+				return err
+			}
+			return w.start()
+		}, func(ctx context.Context) error {
+			w.stop()
+			return shutdown(ctx)
+		}
+}
+
+// append writes a batch to a new file, synced to disk before it is acknowledged, and wakes up the background
+// inserts. The file starts with the tenant of the client that sent the batch, restored when it is inserted.
+func (w *writeAheadLog[T]) append(ctx context.Context, data T) error {
+	batch, err := w.marshal(data)
+	if err != nil {
+		return consumererror.NewPermanent(err)
+	}
+	tenant := internal.ClientTenant(ctx, w.tenant.AuthAttribute, w.tenant.MetadataKey)
+	body := binary.AppendUvarint(make([]byte, 0, binary.MaxVarintLen64+len(tenant)+len(batch)), uint64(len(tenant)))
+	body = append(append(body, tenant...), batch...)
+
+	w.mu.Lock()
+	// File names must be unique and ordered even if batches are appended within the clock resolution.
+	w.seq = max(w.seq+1, time.Now().UnixNano())
+	name := filepath.Join(w.cfg.Directory, fmt.Sprintf("%020d-%s%s", w.seq, w.signal, writeAheadLogFileExt))
+	w.mu.Unlock()
+
+	if err := writeFileSync(name, body); err != nil {
+		return fmt.Errorf("write to write-ahead log: %w", err)
+	}
+	select {
+	case w.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// start creates the directory and inserts the batches in the background, starting with those left by a previous run.
+func (w *writeAheadLog[T]) start() error {
+	if err := os.MkdirAll(w.cfg.Directory, 0o750); err != nil {
+		return fmt.Errorf("create write-ahead log directory: %w", err)
+	}
+	var ctx context.Context
+	ctx, w.cancel = context.WithCancel(context.Background())
+	w.done = make(chan struct{})
+	go w.run(ctx)
+	return nil
+}
+
+// stop cancels the background inserts and waits for them to return.
+// The batches not inserted yet are kept for the next start.
+func (w *writeAheadLog[T]) stop() {
+	if w.cancel == nil {
+		return
+	}
+	w.cancel()
+	<-w.done
+}
+
+func (w *writeAheadLog[T]) run(ctx context.Context) {
+	defer close(w.done)
+	for {
+		wait := w.notify
+		var retry <-chan time.Time
+		if err := w.replay(ctx); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			w.logger.Warn("failed to insert batch from write-ahead log, retrying", zap.String("signal", w.signal), zap.Duration("interval", w.cfg.RetryInterval), zap.Error(err))
+			wait, retry = nil, time.After(w.cfg.RetryInterval)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-wait:
+		case <-retry:
+		}
+	}
+}
+
+// replay inserts the batches of the directory, oldest first, stopping at the first one failing.
+func (w *writeAheadLog[T]) replay(ctx context.Context) error {
+	names, err := w.files()
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		if err := w.insert(ctx, filepath.Join(w.cfg.Directory, name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// insert pushes the batch of a file on behalf of the tenant that sent it and removes it once inserted.
+// Batches that can't be decoded or are rejected permanently are dropped. A batch failing to be inserted is
+// kept decoded, its retries pushing the same batch, e.g. so that a required mirror retries only its own write.
+func (w *writeAheadLog[T]) insert(ctx context.Context, file string) error {
+	if w.pending.file != file {
+		body, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		w.pending = w.decode(file, body)
+	}
+	if err := w.pending.err; err != nil {
+		w.logger.Error("dropping undecodable batch from write-ahead log", zap.String("file", filepath.Base(file)), zap.Error(err))
+	} else if err := w.pushWithTimeout(internal.WithClientTenant(ctx, w.pending.tenant, w.tenant.AuthAttribute, w.tenant.MetadataKey), w.pending.data); err != nil {
+		if !consumererror.IsPermanent(err) || ctx.Err() != nil {
+			return err
+		}
+		w.logger.Error("dropping rejected batch from write-ahead log", zap.String("file", filepath.Base(file)), zap.Error(err))
+	}
+	w.pending = walBatch[T]{}
+	if err := os.Remove(file); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// decode returns the tenant and batch of the body of a file.
+func (w *writeAheadLog[T]) decode(file string, body []byte) walBatch[T] {
+	batch := walBatch[T]{file: file}
+	tenantLen, n := binary.Uvarint(body)
+	if n <= 0 || tenantLen > uint64(len(body)-n) {
+		batch.err = errors.New("invalid tenant header")
+		return batch
+	}
+	batch.tenant = string(body[n : n+int(tenantLen)])
+	batch.data, batch.err = w.unmarshal(body[n+int(tenantLen):])
+	return batch
+}
+
+func (w *writeAheadLog[T]) pushWithTimeout(ctx context.Context, data T) error {
+	if w.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, w.timeout)
+		defer cancel()
+	}
+	return w.push(ctx, data)
+}
+
+// files returns the names of the batches of the signal, oldest first.
+func (w *writeAheadLog[T]) files() ([]string, error) {
+	entries, err := os.ReadDir(w.cfg.Directory)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), "-"+w.signal+writeAheadLogFileExt) {
+			names = append(names, entry.Name())
+		}
+	}
+	// File names start with the zero padded write time.
+	slices.Sort(names)
+	return names, nil
+}
+
+// writeFileSync writes data to a temporary file synced to disk, then renames it to name and syncs the directory,
+// so that after a crash the file is either missing or complete.
+func writeFileSync(name string, data []byte) error {
+	tmp := name + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, name); err != nil {
+		return err
+	}
+	dir, err := os.Open(filepath.Dir(name))
+	if err != nil {
+		return err
+	}
+	if err := dir.Sync(); err != nil {
+		_ = dir.Close()
+		return err
+	}
+	return dir.Close()
+}
@@ -0,0 +1,130 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package clickhouseexporter
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/client"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer/consumererror"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestWriteAheadLog(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "wal")
+	cfg := withDefaultConfig(func(cfg *Config) {
+		cfg.Endpoint = defaultEndpoint
+		cfg.WriteAheadLog = WriteAheadLogConfig{Enabled: true, Directory: dir, RetryInterval: time.Millisecond}
+	})
+	require.NoError(t, cfg.Validate())
+
+	var (
+		mu       sync.Mutex
+		down     = true
+		inserted []int
+	)
+	insert := func(_ context.Context, ld plog.Logs) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if down {
+			return errors.New("clickhouse is down")
+		}
+		if ld.LogRecordCount() == 4 {
+			return consumererror.NewPermanent(errors.New("cannot parse"))
+		}
+		inserted = append(inserted, ld.LogRecordCount())
+		return nil
+	}
+	newLog := func() (func(context.Context, plog.Logs) error, *writeAheadLog[plog.Logs]) {
+		return withWriteAheadLog(cfg, "logs", zaptest.NewLogger(t), insert, (&plog.ProtoMarshaler{}).MarshalLogs, (&plog.ProtoUnmarshaler{}).UnmarshalLogs)
+	}
+
+	// Batches appended before a restart are inserted on the next start.
+	push, wal := newLog()
+	start, shutdown := wal.lifecycle(func(context.Context, component.Host) error { return nil }, func(context.Context) error { return nil })
+	require.NoError(t, start(context.Background(), componenttest.NewNopHost()))
+	for i := 1; i <= 4; i++ {
+		require.NoError(t, push(context.Background(), simpleLogs(i)))
+	}
+	require.NoError(t, shutdown(context.Background()))
+	files, err := filepath.Glob(filepath.Join(dir, "*-logs.wal"))
+	require.NoError(t, err)
+	require.Len(t, files, 4, "batches are kept until inserted")
+
+	mu.Lock()
+	down = false
+	mu.Unlock()
+	push, wal = newLog()
+	require.NoError(t, wal.start())
+	require.NoError(t, push(context.Background(), simpleLogs(5)))
+	require.Eventually(t, func() bool {
+		files, err := filepath.Glob(filepath.Join(dir, "*-logs.wal"))
+		return err == nil && len(files) == 0
+	}, 5*time.Second, time.Millisecond)
+	wal.stop()
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Equal(t, []int{1, 2, 3, 5}, inserted, "batches are inserted in order, permanent errors are dropped")
+
+	t.Run("tenant", func(t *testing.T) {
+		var (
+			mu      sync.Mutex
+			tenants []driver.Value
+		)
+		initClickhouseTestServer(t, func(query string, values []driver.Value) error {
+			if strings.HasPrefix(query, "INSERT") {
+				mu.Lock()
+				tenants = append(tenants, values[len(values)-1])
+				mu.Unlock()
+			}
+			return nil
+		})
+		exporter := newTestLogsExporter(t, defaultEndpoint, withDriverName(t.Name()), func(cfg *Config) {
+			cfg.Tenant = TenantConfig{Enabled: true, Column: "Tenant", MetadataKey: "x-tenant-id"}
+			cfg.WriteAheadLog = WriteAheadLogConfig{Enabled: true, Directory: filepath.Join(t.TempDir(), "wal"), RetryInterval: time.Millisecond}
+		})
+		push, wal := withWriteAheadLog(exporter.cfg, "logs", zaptest.NewLogger(t), exporter.pushLogsData, (&plog.ProtoMarshaler{}).MarshalLogs, (&plog.ProtoUnmarshaler{}).UnmarshalLogs)
+		require.NoError(t, wal.start())
+		defer wal.stop()
+
+		ctx := client.NewContext(context.Background(), client.Info{
+			Metadata: client.NewMetadata(map[string][]string{"x-tenant-id": {"acme"}}),
+		})
+		require.NoError(t, push(ctx, simpleLogs(2)))
+		require.NoError(t, push(context.Background(), simpleLogs(1)))
+		require.Eventually(t, func() bool {
+			mu.Lock()
+			defer mu.Unlock()
+			return len(tenants) == 3
+		}, 5*time.Second, time.Millisecond)
+		mu.Lock()
+		defer mu.Unlock()
+		require.Equal(t, []driver.Value{"acme", "acme", ""}, tenants, "batches are inserted on behalf of the tenant that sent them")
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		cfg.WriteAheadLog.Enabled = false
+		_, wal := newLog()
+		require.Nil(t, wal)
+
+		cfg.WriteAheadLog = WriteAheadLogConfig{Enabled: true}
+		require.ErrorIs(t, cfg.Validate(), errConfigWriteAheadLog)
+
+		cfg.WriteAheadLog = WriteAheadLogConfig{Enabled: true, Directory: dir + "/"}
+		cfg.DeadLetterQueue = DeadLetterQueueConfig{Enabled: true, Directory: dir}
+		require.ErrorIs(t, cfg.Validate(), errConfigWriteAheadLogDir)
+	})
+}
@@ -0,0 +1,108 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package clickhousereceiver // import "github.com/foyer-work/otel-distribution/receiver/clickhouse"
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/collector/config/configopaque"
+	"go.opentelemetry.io/collector/config/configtls"
+)
+
+// Config defines configuration for clickhouse receiver.
+type Config struct {
+	driverName string // for testing
+
+	// CollectionInterval is how often the system tables are scraped. Default is 30s.
+	CollectionInterval time.Duration `mapstructure:"collection_interval"`
+	// Timeout bounds the queries of a scrape. Default is 10s.
+	Timeout time.Duration `mapstructure:"timeout"`
+	// Endpoints are the `host:port` addresses of the clickhouse server using the native protocol. The first
+	// reachable one is scraped, so list the replicas of a server rather than the servers of a cluster, which
+	// each need their own receiver. Default is `localhost:9000`.
+	Endpoints []string `mapstructure:"endpoints"`
+	// TLS configures the connection to the clickhouse server. TLS is disabled if unset.
+	TLS *configtls.ClientConfig `mapstructure:"tls"`
+	// Username is the authentication username.
+	Username string `mapstructure:"username"`
+	// Password is the authentication password, reference it through a confmap provider,
+	// e.g. `${env:CLICKHOUSE_PASSWORD}`, to keep it out of the config file.
+	Password configopaque.String `mapstructure:"password"`
+	// Metrics selects the system tables scraped.
+	Metrics MetricsConfig `mapstructure:"metrics"`
+	// Databases restricts the table metrics to the parts of these databases. All databases but `system`
+	// and `INFORMATION_SCHEMA` by default.
+	Databases []string `mapstructure:"databases"`
+}
+
+// MetricsConfig selects the system tables scraped. All of them are scraped by default.
+type MetricsConfig struct {
+	// Metrics scrapes system.metrics, the current values of the server, e.g. queries and connections running,
+	// into `clickhouse.metrics.<metric>` gauges.
+	Metrics bool `mapstructure:"metrics"`
+	// Events scrapes system.events, the counts of events since the server started, e.g. queries, inserted rows
+	// and failed queries, into `clickhouse.events.<event>` cumulative sums.
+	Events bool `mapstructure:"events"`
+	// AsynchronousMetrics scrapes system.asynchronous_metrics, the values computed in the background, e.g. memory
+	// and replica delays, into `clickhouse.asynchronous_metrics.<metric>` gauges.
+	AsynchronousMetrics bool `mapstructure:"asynchronous_metrics"`
+	// Parts scrapes the active parts of system.parts into the `clickhouse.table.parts`, `clickhouse.table.rows`
+	// and `clickhouse.table.bytes` gauges of each table.
+	Parts bool `mapstructure:"parts"`
+	// Disks scrapes system.disks into the `clickhouse.disk.free` and `clickhouse.disk.total` gauges of each disk.
+	Disks bool `mapstructure:"disks"`
+}
+
+var (
+	errConfigNoEndpoint         = errors.New("endpoints must be specified")
+	errConfigInvalidEndpoint    = errors.New("endpoints must be host:port addresses")
+	errConfigCollectionInterval = errors.New("collection_interval must be positive")
+	errConfigTimeout            = errors.New("timeout must be positive and at most the collection_interval")
+	errConfigNoMetrics          = errors.New("metrics must enable at least one system table")
+)
+
+// Validate the clickhouse receiver configuration.
+func (cfg *Config) Validate() (err error) {
+	if len(cfg.Endpoints) == 0 {
+		err = errors.Join(err, errConfigNoEndpoint)
+	}
+	for _, endpoint := range cfg.Endpoints {
+		if _, _, splitErr := net.SplitHostPort(endpoint); splitErr != nil {
+			err = errors.Join(err, fmt.Errorf("%w: %q", errConfigInvalidEndpoint, endpoint))
+		}
+	}
+	if cfg.CollectionInterval <= 0 {
+		err = errors.Join(err, errConfigCollectionInterval)
+	}
+	if cfg.Timeout <= 0 || cfg.Timeout > cfg.CollectionInterval {
+		err = errors.Join(err, errConfigTimeout)
+	}
+	if m := cfg.Metrics; !m.Metrics && !m.Events && !m.AsynchronousMetrics && !m.Parts && !m.Disks {
+		err = errors.Join(err, errConfigNoMetrics)
+	}
+	return err
+}
+
+// buildDSN returns the DSN of the endpoints, with the credentials and TLS flags of the configuration.
+func (cfg *Config) buildDSN() string {
+	dsnURL := url.URL{Scheme: "clickhouse", Host: strings.Join(cfg.Endpoints, ",")}
+	if cfg.Username != "" {
+		dsnURL.User = url.UserPassword(cfg.Username, string(cfg.Password))
+	}
+	queryParams := dsnURL.Query()
+	if cfg.TLS != nil && !cfg.TLS.Insecure {
+		queryParams.Set("secure", "true")
+		if cfg.TLS.InsecureSkipVerify {
+			queryParams.Set("skip_verify", "true")
+		}
+	}
+	queryParams.Set("connection_open_strategy", "in_order")
+	dsnURL.RawQuery = queryParams.Encode()
+	return dsnURL.String()
+}
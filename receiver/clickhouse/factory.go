@@ -0,0 +1,54 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:generate mdatagen metadata.yaml
+
+package clickhousereceiver // import "github.com/foyer-work/otel-distribution/receiver/clickhouse"
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/receiver"
+
+	"github.com/foyer-work/otel-distribution/receiver/clickhouse/internal/metadata"
+)
+
+const clickhouseDriverName = "clickhouse"
+
+// NewFactory creates a factory for ClickHouse receiver.
+func NewFactory() receiver.Factory {
+	return receiver.NewFactory(
+		metadata.Type,
+		createDefaultConfig,
+		receiver.WithMetrics(createMetricsReceiver, metadata.MetricsStability),
+	)
+}
+
+func createDefaultConfig() component.Config {
+	return &Config{
+		driverName: clickhouseDriverName,
+
+		CollectionInterval: 30 * time.Second,
+		Timeout:            10 * time.Second,
+		Endpoints:          []string{"localhost:9000"},
+		Metrics: MetricsConfig{
+			Metrics:             true,
+			Events:              true,
+			AsynchronousMetrics: true,
+			Parts:               true,
+			Disks:               true,
+		},
+	}
+}
+
+func createMetricsReceiver(
+	_ context.Context,
+	set receiver.Settings,
+	cfg component.Config,
+	next consumer.Metrics,
+) (receiver.Metrics, error) {
+	return newMetricsReceiver(cfg.(*Config), set, next), nil
+}
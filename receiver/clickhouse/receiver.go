@@ -0,0 +1,98 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package clickhousereceiver // import "github.com/foyer-work/otel-distribution/receiver/clickhouse"
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+
+	_ "github.com/ClickHouse/clickhouse-go/v2" // For register database driver.
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/receiver"
+	"go.uber.org/zap"
+)
+
+// metricsReceiver scrapes the system tables of a clickhouse server every collection interval.
+type metricsReceiver struct {
+	cfg    *Config
+	logger *zap.Logger
+	next   consumer.Metrics
+
+	db     *sql.DB
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func newMetricsReceiver(cfg *Config, set receiver.Settings, next consumer.Metrics) *metricsReceiver {
+	return &metricsReceiver{
+		cfg:    cfg,
+		logger: set.Logger,
+		next:   next,
+	}
+}
+
+// Start opens the connection and scrapes in the background, the first scrape right away. An unreachable
+// server doesn't fail the start, its scrapes failing until it is reachable.
+func (r *metricsReceiver) Start(_ context.Context, _ component.Host) error {
+	db, err := sql.Open(r.cfg.driverName, r.cfg.buildDSN())
+	if err != nil {
+		return err
+	}
+	// The scrapes don't overlap, a single connection is enough.
+	db.SetMaxOpenConns(1)
+	r.db = db
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		ticker := time.NewTicker(r.cfg.CollectionInterval)
+		defer ticker.Stop()
+		for {
+			r.scrapeAndConsume(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return nil
+}
+
+// Shutdown stops the scrapes and closes the connection.
+func (r *metricsReceiver) Shutdown(_ context.Context) error {
+	if r.cancel != nil {
+		r.cancel()
+	}
+	r.wg.Wait()
+	if r.db != nil {
+		return r.db.Close()
+	}
+	return nil
+}
+
+// scrapeAndConsume sends the metrics of a scrape to the next consumer, those of the system tables scraped
+// successfully if others fail.
+func (r *metricsReceiver) scrapeAndConsume(ctx context.Context) {
+	scrapeCtx, cancel := context.WithTimeout(ctx, r.cfg.Timeout)
+	defer cancel()
+	md, err := newScraper(r.cfg, r.db).scrape(scrapeCtx, time.Now())
+	if err != nil {
+		if ctx.Err() != nil {
+			return
+		}
+		r.logger.Warn("failed to scrape clickhouse", zap.Error(err))
+	}
+	if md.DataPointCount() == 0 {
+		return
+	}
+	if err := r.next.ConsumeMetrics(ctx, md); err != nil {
+		r.logger.Warn("failed to consume clickhouse metrics", zap.Error(err))
+	}
+}
@@ -0,0 +1,47 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package clickhousereceiver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/receiver/receivertest"
+
+	"github.com/foyer-work/otel-distribution/receiver/clickhouse/internal/metadata"
+)
+
+func TestMetricsReceiver(t *testing.T) {
+	initClickhouseTestServer(t, serverRows())
+	cfg := createDefaultConfig().(*Config)
+	cfg.driverName = t.Name()
+	cfg.CollectionInterval = 10 * time.Millisecond
+	sink := new(consumertest.MetricsSink)
+
+	r, err := NewFactory().CreateMetrics(context.Background(), receivertest.NewNopSettings(metadata.Type), cfg, sink)
+	require.NoError(t, err)
+	require.NoError(t, r.Start(context.Background(), componenttest.NewNopHost()))
+	require.Eventually(t, func() bool {
+		return len(sink.AllMetrics()) >= 2
+	}, 5*time.Second, 10*time.Millisecond, "the system tables are scraped every collection interval")
+	require.NoError(t, r.Shutdown(context.Background()))
+	require.Equal(t, 8, sink.AllMetrics()[0].MetricCount())
+}
+
+func TestMetricsReceiver_unreachable(t *testing.T) {
+	initClickhouseTestServer(t, testRows{})
+	cfg := createDefaultConfig().(*Config)
+	cfg.driverName = t.Name()
+	sink := new(consumertest.MetricsSink)
+
+	r, err := NewFactory().CreateMetrics(context.Background(), receivertest.NewNopSettings(metadata.Type), cfg, sink)
+	require.NoError(t, err)
+	require.NoError(t, r.Start(context.Background(), componenttest.NewNopHost()), "an unreachable server doesn't fail the start")
+	require.NoError(t, r.Shutdown(context.Background()))
+	require.Empty(t, sink.AllMetrics())
+}
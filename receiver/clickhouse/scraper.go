@@ -0,0 +1,206 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package clickhousereceiver // import "github.com/foyer-work/otel-distribution/receiver/clickhouse"
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+
+	"github.com/foyer-work/otel-distribution/receiver/clickhouse/internal/metadata"
+)
+
+// language=ClickHouse SQL
+const (
+	serverSQL              = "SELECT hostName(), version(), uptime()"
+	metricsSQL             = "SELECT metric, value, description FROM system.metrics"
+	eventsSQL              = "SELECT event, value, description FROM system.events"
+	asynchronousMetricsSQL = "SELECT metric, value, description FROM system.asynchronous_metrics"
+	partsSQL               = "SELECT database, table, count(), sum(rows), sum(bytes_on_disk) FROM system.parts WHERE active AND %s GROUP BY database, table"
+	disksSQL               = "SELECT name, free_space, total_space FROM system.disks"
+)
+
+// scraper converts the rows of the system tables of a scrape into metrics.
+type scraper struct {
+	cfg     *Config
+	db      *sql.DB
+	metrics pmetric.MetricSlice
+	now     pcommon.Timestamp
+	started pcommon.Timestamp
+}
+
+func newScraper(cfg *Config, db *sql.DB) *scraper {
+	return &scraper{cfg: cfg, db: db}
+}
+
+// scrape returns the metrics of the system tables enabled by the configuration, those scraped successfully
+// along with the errors of the others.
+func (s *scraper) scrape(ctx context.Context, now time.Time) (pmetric.Metrics, error) {
+	md := pmetric.NewMetrics()
+	var host, version string
+	var uptime uint32
+	if err := s.db.QueryRowContext(ctx, serverSQL).Scan(&host, &version, &uptime); err != nil {
+		return md, fmt.Errorf("query server: %w", err)
+	}
+	rm := md.ResourceMetrics().AppendEmpty()
+	rm.Resource().Attributes().PutStr("service.name", "clickhouse")
+	rm.Resource().Attributes().PutStr("service.version", version)
+	rm.Resource().Attributes().PutStr("server.address", host)
+	sm := rm.ScopeMetrics().AppendEmpty()
+	sm.Scope().SetName(metadata.ScopeName)
+
+	s.metrics = sm.Metrics()
+	s.now = pcommon.NewTimestampFromTime(now)
+	s.started = pcommon.NewTimestampFromTime(now.Add(-time.Duration(uptime) * time.Second))
+
+	var err error
+	if s.cfg.Metrics.Metrics {
+		err = errors.Join(err, s.scrapeValues(ctx, metricsSQL, "clickhouse.metrics.", false))
+	}
+	if s.cfg.Metrics.Events {
+		err = errors.Join(err, s.scrapeValues(ctx, eventsSQL, "clickhouse.events.", true))
+	}
+	if s.cfg.Metrics.AsynchronousMetrics {
+		err = errors.Join(err, s.scrapeValues(ctx, asynchronousMetricsSQL, "clickhouse.asynchronous_metrics.", false))
+	}
+	if s.cfg.Metrics.Parts {
+		err = errors.Join(err, s.scrapeParts(ctx))
+	}
+	if s.cfg.Metrics.Disks {
+		err = errors.Join(err, s.scrapeDisks(ctx))
+	}
+	// The gauges of the parts and disks without rows are dropped.
+	s.metrics.RemoveIf(func(m pmetric.Metric) bool {
+		return m.Type() == pmetric.MetricTypeGauge && m.Gauge().DataPoints().Len() == 0
+	})
+	return md, err
+}
+
+// scrapeValues converts the name, value and description rows of query into a metric per row, cumulative sums
+// since the server started if sum, gauges otherwise.
+func (s *scraper) scrapeValues(ctx context.Context, query, prefix string, sum bool) error {
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("query %s: %w", strings.TrimSuffix(prefix, "."), err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	for rows.Next() {
+		var (
+			name, description string
+			value             float64
+		)
+		if err := rows.Scan(&name, &value, &description); err != nil {
+			return fmt.Errorf("scan %s: %w", strings.TrimSuffix(prefix, "."), err)
+		}
+		m := s.metrics.AppendEmpty()
+		m.SetName(prefix + name)
+		m.SetDescription(description)
+		var dp pmetric.NumberDataPoint
+		if sum {
+			m.SetEmptySum().SetIsMonotonic(true)
+			m.Sum().SetAggregationTemporality(pmetric.AggregationTemporalityCumulative)
+			dp = m.Sum().DataPoints().AppendEmpty()
+			dp.SetStartTimestamp(s.started)
+		} else {
+			dp = m.SetEmptyGauge().DataPoints().AppendEmpty()
+		}
+		dp.SetTimestamp(s.now)
+		dp.SetDoubleValue(value)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("query %s: %w", strings.TrimSuffix(prefix, "."), err)
+	}
+	return nil
+}
+
+// scrapeParts converts the active parts of the tables of the configured databases into the parts, rows and
+// bytes gauges of each table.
+func (s *scraper) scrapeParts(ctx context.Context) error {
+	filter := "database NOT IN ('system', 'INFORMATION_SCHEMA', 'information_schema')"
+	var args []any
+	if len(s.cfg.Databases) > 0 {
+		filter = "database IN (?" + strings.Repeat(", ?", len(s.cfg.Databases)-1) + ")"
+		for _, database := range s.cfg.Databases {
+			args = append(args, database)
+		}
+	}
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf(partsSQL, filter), args...)
+	if err != nil {
+		return fmt.Errorf("query parts: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	parts := s.gauge("clickhouse.table.parts", "Active parts of the table.", "{part}")
+	records := s.gauge("clickhouse.table.rows", "Rows of the active parts of the table.", "{row}")
+	bytes := s.gauge("clickhouse.table.bytes", "Bytes on disk of the active parts of the table.", "By")
+	for rows.Next() {
+		var (
+			database, table           string
+			count, rowCount, byteSize uint64
+		)
+		if err := rows.Scan(&database, &table, &count, &rowCount, &byteSize); err != nil {
+			return fmt.Errorf("scan parts: %w", err)
+		}
+		for _, dp := range []pmetric.NumberDataPoint{s.dataPoint(parts, count), s.dataPoint(records, rowCount), s.dataPoint(bytes, byteSize)} {
+			dp.Attributes().PutStr("db.namespace", database)
+			dp.Attributes().PutStr("db.collection.name", table)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("query parts: %w", err)
+	}
+	return nil
+}
+
+// scrapeDisks converts the disks into the free and total bytes gauges of each disk.
+func (s *scraper) scrapeDisks(ctx context.Context) error {
+	rows, err := s.db.QueryContext(ctx, disksSQL)
+	if err != nil {
+		return fmt.Errorf("query disks: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	free := s.gauge("clickhouse.disk.free", "Free space of the disk.", "By")
+	total := s.gauge("clickhouse.disk.total", "Total space of the disk.", "By")
+	for rows.Next() {
+		var (
+			name             string
+			freeSpace, space uint64
+		)
+		if err := rows.Scan(&name, &freeSpace, &space); err != nil {
+			return fmt.Errorf("scan disks: %w", err)
+		}
+		s.dataPoint(free, freeSpace).Attributes().PutStr("clickhouse.disk", name)
+		s.dataPoint(total, space).Attributes().PutStr("clickhouse.disk", name)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("query disks: %w", err)
+	}
+	return nil
+}
+
+func (s *scraper) gauge(name, description, unit string) pmetric.Gauge {
+	m := s.metrics.AppendEmpty()
+	m.SetName(name)
+	m.SetDescription(description)
+	m.SetUnit(unit)
+	return m.SetEmptyGauge()
+}
+
+func (s *scraper) dataPoint(gauge pmetric.Gauge, value uint64) pmetric.NumberDataPoint {
+	dp := gauge.DataPoints().AppendEmpty()
+	dp.SetTimestamp(s.now)
+	dp.SetIntValue(int64(value))
+	return dp
+}
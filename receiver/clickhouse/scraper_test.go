@@ -0,0 +1,151 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package clickhousereceiver
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+// testRows are the rows returned by the test driver for the queries starting with their key.
+type testRows map[string][][]driver.Value
+
+// initClickhouseTestServer registers a test driver named after the test, returning rows for the queries
+// and failing the others.
+func initClickhouseTestServer(t *testing.T, rows testRows) {
+	sql.Register(t.Name(), &testClickhouseDriver{rows: rows})
+}
+
+func serverRows() testRows {
+	return testRows{
+		serverSQL:              {{"ch-1", "25.3.1", uint32(60)}},
+		metricsSQL:             {{"Query", int64(2), "Queries running"}},
+		eventsSQL:              {{"InsertedRows", uint64(1000), "Rows inserted"}},
+		asynchronousMetricsSQL: {{"MemoryResident", 1.5e9, "Resident memory"}},
+		"SELECT database, table": {
+			{"otel", "otel_logs", uint64(3), uint64(100), uint64(4096)},
+			{"otel", "otel_traces", uint64(1), uint64(10), uint64(512)},
+		},
+		disksSQL: {{"default", uint64(10), uint64(100)}},
+	}
+}
+
+type testClickhouseDriver struct {
+	rows testRows
+}
+
+func (d *testClickhouseDriver) Open(string) (driver.Conn, error) {
+	return &testClickhouseDriverConn{rows: d.rows}, nil
+}
+
+type testClickhouseDriverConn struct {
+	rows testRows
+}
+
+func (c *testClickhouseDriverConn) QueryContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Rows, error) {
+	for prefix, rows := range c.rows {
+		if strings.HasPrefix(query, prefix) {
+			return &testClickhouseDriverRows{rows: rows, columns: len(rows[0])}, nil
+		}
+	}
+	return nil, errors.New("unknown table")
+}
+
+func (*testClickhouseDriverConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("not supported")
+}
+
+func (*testClickhouseDriverConn) Close() error {
+	return nil
+}
+
+func (*testClickhouseDriverConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("not supported")
+}
+
+type testClickhouseDriverRows struct {
+	rows    [][]driver.Value
+	columns int
+}
+
+func (r *testClickhouseDriverRows) Columns() []string {
+	return make([]string, r.columns)
+}
+
+func (*testClickhouseDriverRows) Close() error {
+	return nil
+}
+
+func (r *testClickhouseDriverRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+func newTestScraper(t *testing.T, cfg *Config) *scraper {
+	db, err := sql.Open(t.Name(), "")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+	return newScraper(cfg, db)
+}
+
+func TestScraper_scrape(t *testing.T) {
+	initClickhouseTestServer(t, serverRows())
+	now := time.Unix(1000, 0)
+
+	md, err := newTestScraper(t, createDefaultConfig().(*Config)).scrape(context.Background(), now)
+	require.NoError(t, err)
+	rm := md.ResourceMetrics().At(0)
+	host, _ := rm.Resource().Attributes().Get("server.address")
+	require.Equal(t, "ch-1", host.Str())
+
+	metrics := map[string]pmetric.Metric{}
+	for i := 0; i < rm.ScopeMetrics().At(0).Metrics().Len(); i++ {
+		m := rm.ScopeMetrics().At(0).Metrics().At(i)
+		metrics[m.Name()] = m
+	}
+	require.Len(t, metrics, 8)
+	require.Equal(t, 2.0, metrics["clickhouse.metrics.Query"].Gauge().DataPoints().At(0).DoubleValue())
+	require.Equal(t, "Queries running", metrics["clickhouse.metrics.Query"].Description())
+
+	inserted := metrics["clickhouse.events.InsertedRows"].Sum()
+	require.True(t, inserted.IsMonotonic())
+	require.Equal(t, pmetric.AggregationTemporalityCumulative, inserted.AggregationTemporality())
+	require.Equal(t, time.Unix(940, 0).UTC(), inserted.DataPoints().At(0).StartTimestamp().AsTime(), "the events are counted since the server started")
+
+	rows := metrics["clickhouse.table.rows"].Gauge().DataPoints()
+	require.Equal(t, 2, rows.Len())
+	require.Equal(t, int64(100), rows.At(0).IntValue())
+	table, _ := rows.At(0).Attributes().Get("db.collection.name")
+	require.Equal(t, "otel_logs", table.Str())
+	require.Equal(t, int64(10), metrics["clickhouse.disk.free"].Gauge().DataPoints().At(0).IntValue())
+}
+
+func TestScraper_scrape_partial(t *testing.T) {
+	rows := serverRows()
+	delete(rows, eventsSQL)
+	delete(rows, disksSQL)
+	initClickhouseTestServer(t, rows)
+	cfg := createDefaultConfig().(*Config)
+	cfg.Metrics.AsynchronousMetrics = false
+
+	md, err := newTestScraper(t, cfg).scrape(context.Background(), time.Now())
+	require.ErrorContains(t, err, "query clickhouse.events")
+	require.ErrorContains(t, err, "query disks")
+	require.Equal(t, 4, md.MetricCount(), "the metrics of the other tables are scraped")
+}